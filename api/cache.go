@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/revocation"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	CacheTokensPath   = "/caches/tokens"
+	CacheSubjectsPath = "/caches/subjects"
+)
+
+var tokenHashPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+type cacheHandlerRegistry interface {
+	x.RegistryWriter
+	x.RegistryLogger
+	revocation.Registry
+}
+
+type CacheHandler struct {
+	r cacheHandlerRegistry
+}
+
+func NewCacheHandler(r cacheHandlerRegistry) *CacheHandler {
+	return &CacheHandler{r: r}
+}
+
+func (h *CacheHandler) SetRoutes(r *x.RouterAPI) {
+	r.DELETE(CacheTokensPath+"/:hash", h.deleteToken)
+	r.DELETE(CacheSubjectsPath+"/*subject", h.deleteSubject)
+}
+
+// swagger:route DELETE /caches/tokens/{hash} api purgeTokenCache
+//
+// Purge cached results of a token
+//
+// Purges all authentication and authorization results cached by this instance for the token. The token is identified
+// by the hex encoded SHA-256 hash of the raw token. The token itself is not revoked.
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       204: emptyResponse
+//       400: genericError
+//       500: genericError
+func (h *CacheHandler) deleteToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	hash := strings.ToLower(ps.ByName("hash"))
+	if !tokenHashPattern.MatchString(hash) {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason("The token hash must be a hex encoded SHA-256 hash.")))
+		return
+	}
+
+	h.r.RevocationStore().InvalidateToken(r.Context(), hash)
+	h.r.Logger().WithField("token_hash", hash).Info("Purged cached results of token.")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// swagger:route DELETE /caches/subjects/{subject} api purgeSubjectCache
+//
+// Purge cached results of a subject
+//
+// Purges all authentication and authorization results cached by this instance for the subject. The subject itself
+// is not revoked.
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       204: emptyResponse
+//       400: genericError
+//       500: genericError
+func (h *CacheHandler) deleteSubject(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// The subject is matched using a catch-all parameter because it may contain slashes.
+	subject := strings.TrimPrefix(ps.ByName("subject"), "/")
+	if subject == "" {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason("The subject must not be empty.")))
+		return
+	}
+
+	h.r.RevocationStore().Invalidate(r.Context(), revocation.Event{Subject: subject})
+	h.r.Logger().WithField("subject", subject).Info("Purged cached results of subject.")
+	w.WriteHeader(http.StatusNoContent)
+}
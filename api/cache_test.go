@@ -0,0 +1,51 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/revocation"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestCacheHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	r := internal.NewRegistry(conf)
+
+	router := x.NewAPIRouter()
+	r.CacheHandler().SetRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{path: api.CacheTokensPath + "/" + revocation.TokenHash("token"), status: http.StatusNoContent},
+		{path: api.CacheTokensPath + "/not-a-hash", status: http.StatusBadRequest},
+		{path: api.CacheSubjectsPath + "/alice", status: http.StatusNoContent},
+		{path: api.CacheSubjectsPath + "/users/alice", status: http.StatusNoContent},
+		{path: api.CacheSubjectsPath + "/", status: http.StatusBadRequest},
+	} {
+		t.Run("path="+tc.path, func(t *testing.T) {
+			req, err := http.NewRequest("DELETE", server.URL+tc.path, nil)
+			require.NoError(t, err)
+
+			res, err := server.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tc.status, res.StatusCode)
+		})
+	}
+
+	// Purging caches does not revoke the subject.
+	assert.False(t, r.RevocationStore().IsRevoked(context.Background(), "alice", "", time.Now().Add(-time.Minute)))
+}
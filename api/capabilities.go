@@ -0,0 +1,141 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/pipeline/authz"
+	pe "github.com/ory/oathkeeper/pipeline/errors"
+	"github.com/ory/oathkeeper/pipeline/mutate"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	CapabilitiesPath = "/version/capabilities"
+)
+
+type capabilitiesHandlerRegistry interface {
+	x.RegistryWriter
+	rule.Registry
+	authn.Registry
+	authz.Registry
+	mutate.Registry
+	pe.Registry
+
+	BuildVersion() string
+	BuildHash() string
+	BuildDate() string
+}
+
+type CapabilitiesHandler struct {
+	c configuration.Provider
+	r capabilitiesHandlerRegistry
+}
+
+// The capabilities of an instance
+// swagger:model capabilities
+type capabilities struct {
+	// Version is the version of the build.
+	Version string `json:"version"`
+
+	// Hash is the git commit of the build.
+	Hash string `json:"hash"`
+
+	// Date is the date of the build.
+	Date string `json:"date"`
+
+	// Authenticators are the IDs of the enabled authenticators.
+	Authenticators []string `json:"authenticators"`
+
+	// Authorizers are the IDs of the enabled authorizers.
+	Authorizers []string `json:"authorizers"`
+
+	// Mutators are the IDs of the enabled mutators.
+	Mutators []string `json:"mutators"`
+
+	// ErrorHandlers are the IDs of the enabled error handlers.
+	ErrorHandlers []string `json:"error_handlers"`
+
+	// Repositories are the locations of the access rule repositories with credentials removed.
+	Repositories []string `json:"repositories"`
+
+	// Schemas are the hex encoded SHA-256 digests of the JSON Schemas the configuration is validated with. The digest
+	// changes with every change of the schema.
+	Schemas map[string]string `json:"schemas"`
+}
+
+// swagger:response capabilities
+type swaggerCapabilitiesResponse struct {
+	// in: body
+	Body capabilities
+}
+
+func NewCapabilitiesHandler(c configuration.Provider, r capabilitiesHandlerRegistry) *CapabilitiesHandler {
+	return &CapabilitiesHandler{c: c, r: r}
+}
+
+func (h *CapabilitiesHandler) SetRoutes(r *x.RouterAPI) {
+	r.GET(CapabilitiesPath, h.get)
+}
+
+// swagger:route GET /version/capabilities api getCapabilities
+//
+// Get the capabilities of this instance
+//
+// Returns the build version, the enabled authenticators, authorizers, mutators and error handlers, the access rule
+// repositories and the digests of the configuration schemas of this instance. Fleet tooling can compare the response
+// of all instances to verify that they run the expected capability set.
+//
+// Be aware that if you are running multiple nodes of this service, the capabilities will never refer to the cluster
+// state, only to a single instance.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: capabilities
+//       500: genericError
+func (h *CapabilitiesHandler) get(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	digest, err := configuration.SchemaDigest("config.schema.json")
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	statuses := h.r.RuleFetcher().Status()
+	repositories := make([]string, len(statuses))
+	for k, s := range statuses {
+		repositories[k] = s.URL
+	}
+
+	h.r.Writer().Write(w, r, &capabilities{
+		Version:        h.r.BuildVersion(),
+		Hash:           h.r.BuildHash(),
+		Date:           h.r.BuildDate(),
+		Authenticators: enabledHandlers(h.r.AvailablePipelineAuthenticators(), h.c.AuthenticatorIsEnabled),
+		Authorizers:    enabledHandlers(h.r.AvailablePipelineAuthorizers(), h.c.AuthorizerIsEnabled),
+		Mutators:       enabledHandlers(h.r.AvailablePipelineMutators(), h.c.MutatorIsEnabled),
+		ErrorHandlers:  enabledHandlers(h.r.AvailablePipelineErrorHandlers().IDs(), h.c.ErrorHandlerIsEnabled),
+		Repositories:   repositories,
+		Schemas:        map[string]string{"config": digest},
+	})
+}
+
+// enabledHandlers returns the sorted IDs of the enabled handlers.
+func enabledHandlers(available []string, isEnabled func(id string) bool) []string {
+	enabled := make([]string, 0, len(available))
+	for _, id := range available {
+		if isEnabled(id) {
+			enabled = append(enabled, id)
+		}
+	}
+	sort.Strings(enabled)
+	return enabled
+}
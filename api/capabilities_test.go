@@ -0,0 +1,66 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestCapabilitiesHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyAccessRuleRepositories, []string{"consul://consul:8500/oathkeeper/rules?token=secret"})
+	r := internal.NewRegistry(conf)
+	r.WithBuildInfo("v0.38.0", "3d2e1f", "2020-09-01T00:00:00Z")
+
+	router := x.NewAPIRouter()
+	r.CapabilitiesHandler().SetRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	res, err := server.Client().Get(server.URL + api.CapabilitiesPath)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var capabilities struct {
+		Version        string            `json:"version"`
+		Hash           string            `json:"hash"`
+		Date           string            `json:"date"`
+		Authenticators []string          `json:"authenticators"`
+		Authorizers    []string          `json:"authorizers"`
+		Mutators       []string          `json:"mutators"`
+		ErrorHandlers  []string          `json:"error_handlers"`
+		Repositories   []string          `json:"repositories"`
+		Schemas        map[string]string `json:"schemas"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&capabilities))
+
+	assert.Equal(t, "v0.38.0", capabilities.Version)
+	assert.Equal(t, "3d2e1f", capabilities.Hash)
+	assert.Equal(t, "2020-09-01T00:00:00Z", capabilities.Date)
+	assert.Equal(t, []string{"anonymous", "noop"}, capabilities.Authenticators)
+	assert.Equal(t, []string{"allow"}, capabilities.Authorizers)
+	assert.Equal(t, []string{"noop"}, capabilities.Mutators)
+	assert.Equal(t, []string{"json"}, capabilities.ErrorHandlers)
+	assert.Equal(t, []string{"consul://consul:8500/oathkeeper/rules?token=REDACTED"}, capabilities.Repositories)
+
+	digest, err := configuration.SchemaDigest("config.schema.json")
+	require.NoError(t, err)
+	assert.Len(t, digest, 64)
+	assert.Equal(t, map[string]string{"config": digest}, capabilities.Schemas)
+}
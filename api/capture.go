@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/capture"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	CapturesPath = "/captures"
+
+	defaultCaptureDuration = 5 * time.Minute
+	maxCaptureDuration     = time.Hour
+	defaultCaptureSize     = 100
+	maxCaptureSize         = 1000
+)
+
+type captureHandlerRegistry interface {
+	x.RegistryWriter
+	x.RegistryLogger
+	rule.Registry
+	capture.Registry
+}
+
+type CaptureHandler struct {
+	r captureHandlerRegistry
+}
+
+// A capture of the requests matching an access rule
+// swagger:response capture
+type swaggerCaptureResponse struct {
+	// in: body
+	Body capture.Capture
+}
+
+// swagger:parameters startCapture getCapture deleteCapture
+type swaggerCaptureParameters struct {
+	// The ID of the access rule.
+	// in: path
+	// required: true
+	ID string `json:"id"`
+}
+
+// swagger:model startCapture
+type startCapture struct {
+	// Duration is how long requests are captured, for example "10m". Defaults to "5m" and may be at most "1h".
+	Duration string `json:"duration"`
+
+	// Size is the maximum number of snapshots kept. Defaults to 100 and may be at most 1000.
+	Size int `json:"size"`
+}
+
+func NewCaptureHandler(r captureHandlerRegistry) *CaptureHandler {
+	return &CaptureHandler{r: r}
+}
+
+func (h *CaptureHandler) SetRoutes(r *x.RouterAPI) {
+	r.POST(CapturesPath+"/:id", h.start)
+	r.GET(CapturesPath+"/:id", h.get)
+	r.DELETE(CapturesPath+"/:id", h.stop)
+}
+
+// swagger:route POST /captures/{id} api startCapture
+//
+// Start capturing requests of a rule
+//
+// Starts a time-boxed capture of the requests matching the access rule. For each request, a sanitized snapshot of
+// the request, the access decision, and the response is kept in a ring buffer. Bodies are never recorded and
+// credentials such as the Authorization and Cookie headers are redacted. Starting a capture discards the snapshots
+// of a previous capture of the same rule.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       201: capture
+//       400: genericError
+//       404: genericError
+//       500: genericError
+func (h *CaptureHandler) start(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("id")
+	if _, err := h.r.RuleRepository().Get(r.Context(), id); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	var p startCapture
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The request body is malformed: %s", err)))
+			return
+		}
+	}
+
+	duration := defaultCaptureDuration
+	if p.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(p.Duration); err != nil || duration <= 0 || duration > maxCaptureDuration {
+			h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf(`The duration must be positive and at most "%s".`, maxCaptureDuration)))
+			return
+		}
+	}
+
+	size := defaultCaptureSize
+	if p.Size != 0 {
+		if p.Size < 0 || p.Size > maxCaptureSize {
+			h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The size must be positive and at most %d.", maxCaptureSize)))
+			return
+		}
+		size = p.Size
+	}
+
+	c := h.r.CaptureRecorder().Start(r.Context(), id, duration, size)
+	h.r.Logger().
+		WithField("rule_id", id).
+		WithField("expires_at", c.ExpiresAt).
+		Info("Started capturing requests of access rule.")
+	h.r.Writer().WriteCreated(w, r, CapturesPath+"/"+id, c)
+}
+
+// swagger:route GET /captures/{id} api getCapture
+//
+// Retrieve the captured requests of a rule
+//
+// Returns the capture of the access rule including all snapshots recorded so far, oldest first. Snapshots remain
+// available after the capture expired until the capture is deleted or restarted.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: capture
+//       404: genericError
+//       500: genericError
+func (h *CaptureHandler) get(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	c, ok := h.r.CaptureRecorder().Get(r.Context(), ps.ByName("id"))
+	if !ok {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("No capture exists for this access rule.")))
+		return
+	}
+
+	h.r.Writer().Write(w, r, c)
+}
+
+// swagger:route DELETE /captures/{id} api deleteCapture
+//
+// Stop capturing requests of a rule
+//
+// Stops the capture of the access rule and discards all recorded snapshots.
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       204: emptyResponse
+//       500: genericError
+func (h *CaptureHandler) stop(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.r.CaptureRecorder().Stop(r.Context(), ps.ByName("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
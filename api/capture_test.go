@@ -0,0 +1,116 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/capture"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/redaction"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestCaptureHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerDenyIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	r := internal.NewRegistry(conf)
+
+	router := x.NewAPIRouter()
+	r.CaptureHandler().SetRoutes(router)
+	n := negroni.New(r.DecisionHandler())
+	n.UseHandler(router)
+	server := httptest.NewServer(n)
+	defer server.Close()
+
+	r.RuleRepository().(*rule.RepositoryMemory).WithRules([]rule.Rule{
+		{
+			ID:             "allow",
+			Match:          &rule.Match{Methods: []string{"GET"}, URL: server.URL + "/allow"},
+			Authenticators: []rule.Handler{{Handler: "anonymous"}},
+			Authorizer:     rule.Handler{Handler: "allow"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+		},
+		{
+			ID:             "deny",
+			Match:          &rule.Match{Methods: []string{"GET"}, URL: server.URL + "/deny"},
+			Authenticators: []rule.Handler{{Handler: "anonymous"}},
+			Authorizer:     rule.Handler{Handler: "deny"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+		},
+	})
+
+	do := func(t *testing.T, method, path string, body []byte, expectCode int) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("X-Api-Key", "secret")
+		res, err := server.Client().Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, expectCode, res.StatusCode)
+		return res
+	}
+
+	get := func(t *testing.T, id string) capture.Capture {
+		res := do(t, "GET", api.CapturesPath+"/"+id, nil, http.StatusOK)
+		defer res.Body.Close()
+
+		var c capture.Capture
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&c))
+		return c
+	}
+
+	t.Run("case=unknown rule", func(t *testing.T) {
+		do(t, "POST", api.CapturesPath+"/unknown", nil, http.StatusNotFound).Body.Close()
+	})
+
+	t.Run("case=invalid duration", func(t *testing.T) {
+		do(t, "POST", api.CapturesPath+"/allow", []byte(`{"duration":"2h"}`), http.StatusBadRequest).Body.Close()
+	})
+
+	t.Run("case=no capture", func(t *testing.T) {
+		do(t, "GET", api.CapturesPath+"/allow", nil, http.StatusNotFound).Body.Close()
+	})
+
+	t.Run("case=captures decisions", func(t *testing.T) {
+		do(t, "POST", api.CapturesPath+"/allow", []byte(`{"duration":"1m","size":2}`), http.StatusCreated).Body.Close()
+		do(t, "POST", api.CapturesPath+"/deny", nil, http.StatusCreated).Body.Close()
+
+		for i := 0; i < 3; i++ {
+			do(t, "GET", api.DecisionPath+"/allow", nil, http.StatusOK).Body.Close()
+		}
+		do(t, "GET", api.DecisionPath+"/deny", nil, http.StatusForbidden).Body.Close()
+
+		c := get(t, "allow")
+		assert.Equal(t, 2, c.Size)
+		require.Len(t, c.Snapshots, 2)
+		assert.True(t, c.Snapshots[0].Decision.Granted)
+		assert.Equal(t, "anonymous", c.Snapshots[0].Decision.Subject)
+		assert.Equal(t, http.StatusOK, c.Snapshots[0].Response.StatusCode)
+		assert.Equal(t, redaction.RedactedValue, c.Snapshots[0].Request.Header.Get("X-Api-Key"))
+
+		c = get(t, "deny")
+		require.Len(t, c.Snapshots, 1)
+		assert.False(t, c.Snapshots[0].Decision.Granted)
+		assert.NotEmpty(t, c.Snapshots[0].Decision.Error)
+		assert.Equal(t, http.StatusForbidden, c.Snapshots[0].Response.StatusCode)
+	})
+
+	t.Run("case=delete", func(t *testing.T) {
+		do(t, "DELETE", api.CapturesPath+"/allow", nil, http.StatusNoContent).Body.Close()
+		do(t, "GET", api.CapturesPath+"/allow", nil, http.StatusNotFound).Body.Close()
+	})
+}
@@ -22,7 +22,15 @@ package api
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/urfave/negroni"
+
+	"github.com/ory/oathkeeper/capture"
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/explain"
 	"github.com/ory/oathkeeper/pipeline/authn"
 	"github.com/ory/oathkeeper/x"
 
@@ -37,17 +45,21 @@ const (
 type decisionHandlerRegistry interface {
 	x.RegistryWriter
 	x.RegistryLogger
+	credentials.SignerRegistry
+	capture.Registry
+	events.Registry
 
 	RuleMatcher() rule.Matcher
 	ProxyRequestHandler() *proxy.RequestHandler
 }
 
 type DecisionHandler struct {
+	c configuration.Provider
 	r decisionHandlerRegistry
 }
 
-func NewJudgeHandler(r decisionHandlerRegistry) *DecisionHandler {
-	return &DecisionHandler{r: r}
+func NewJudgeHandler(c configuration.Provider, r decisionHandlerRegistry) *DecisionHandler {
+	return &DecisionHandler{c: c, r: r}
 }
 
 func (h *DecisionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
@@ -75,6 +87,9 @@ func (h *DecisionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, next
 // request to the upstream server, returns 200 (request should be allowed), 401 (unauthorized), or 403 (forbidden)
 // status codes. This endpoint can be used to integrate with other API Proxies like Ambassador, Kong, Envoy, and many more.
 //
+// If signed decisions are enabled, granted requests additionally carry a short-lived JSON Web Token which binds the
+// decision to the subject, the request method and URL, and the headers returned by this endpoint.
+//
 //     Schemes: http, https
 //
 //     Responses:
@@ -95,34 +110,91 @@ func (h *DecisionHandler) decisions(w http.ResponseWriter, r *http.Request) {
 		fields["subject"] = sess.Subject
 	}
 
+	var rl *rule.Rule
+	var decision capture.Decision
+	if h.r.EventBus().HasSubscribers() {
+		start := time.Now()
+		ew := negroni.NewResponseWriter(w)
+		w = ew
+		defer func() {
+			h.r.EventBus().Publish(proxy.NewDecisionEvent("decisions", r, rl, decision, ew.Status(), time.Since(start)))
+		}()
+	}
+
+	r = h.r.ProxyRequestHandler().SampleExplanation(r)
+	trace := explain.TraceFromContext(r.Context())
+
+	timer := proxy.NewStageTimer(r)
+	timer.Start(proxy.StageMatch)
 	rl, err := h.r.RuleMatcher().Match(r.Context(), r.Method, r.URL)
+	timer.Stop()
 	if err != nil {
+		trace.Step(proxy.StageMatch, "", explain.ResultFailed, err)
 		h.r.Logger().WithError(err).
 			WithFields(fields).
 			WithField("granted", false).
 			Warn("Access request denied")
+		decision.Error = err.Error()
+
 		h.r.ProxyRequestHandler().HandleError(w, r, rl, err)
 		return
 	}
 
+	trace.Step(proxy.StageMatch, "", explain.ResultPassed, nil)
+
+	if snapshot := proxy.NewCaptureSnapshot(r.Context(), h.r.CaptureRecorder(), r, rl); snapshot != nil {
+		cw := negroni.NewResponseWriter(w)
+		w = cw
+		defer func() {
+			snapshot.Decision = decision
+			snapshot.Response = capture.Response{StatusCode: cw.Status(), Header: cw.Header()}
+			h.r.CaptureRecorder().Record(r.Context(), rl.ID, *snapshot)
+		}()
+	}
+
+	logger := h.r.ProxyRequestHandler().RuleLogger(rl)
 	s, err := h.r.ProxyRequestHandler().HandleRequest(r, rl)
 	if err != nil {
-		h.r.Logger().WithError(err).
+		logger.WithError(err).
 			WithFields(fields).
 			WithField("granted", false).
 			Warn("Access request denied")
+		decision.Error = err.Error()
 
 		h.r.ProxyRequestHandler().HandleError(w, r, rl, err)
 		return
 	}
+	defer authn.ReleaseSession(s)
+
+	header := http.Header{}
+	for k := range s.Header {
+		header.Set(k, s.Header.Get(k))
+	}
+	proxy.ApplyAuthorizationHeaderMode(header, r.Header.Get("Authorization"), s.Header.Get("Authorization"), rl)
+
+	if h.c.DecisionSigningIsEnabled() {
+		token, err := h.signDecision(r, rl, s.Subject, header)
+		if err != nil {
+			logger.WithError(err).
+				WithFields(fields).
+				WithField("granted", false).
+				Warn("Access request denied because the decision could not be signed")
+			decision.Error = err.Error()
+
+			h.r.ProxyRequestHandler().HandleError(w, r, rl, err)
+			return
+		}
+		header.Set(h.c.DecisionSigningHeader(), token)
+	}
 
-	h.r.Logger().
+	logger.
 		WithFields(fields).
 		WithField("granted", true).
 		Info("Access request granted")
+	decision = capture.Decision{Granted: true, Subject: s.Subject, Header: header}
 
-	for k := range s.Header {
-		w.Header().Set(k, s.Header.Get(k))
+	for k := range header {
+		w.Header().Set(k, header.Get(k))
 	}
 
 	w.WriteHeader(http.StatusOK)
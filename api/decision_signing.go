@@ -0,0 +1,65 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/rule"
+)
+
+// DecisionHeaderHash returns the hex encoded SHA-256 hash of the headers returned by the decisions API. The hash
+// is computed over the lower-cased header names in lexical order, each followed by a colon, the header value,
+// and a new line.
+func DecisionHeaderHash(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		_, _ = fmt.Fprintf(h, "%s:%s\n", strings.ToLower(name), header.Get(name))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// signDecision issues a JSON Web Token binding the decision to the subject, the request method (`htm`), the
+// request URL without query and fragment (`htu`), and the hash of the returned headers (`hsh`).
+func (h *DecisionHandler) signDecision(r *http.Request, rl *rule.Rule, subject string, header http.Header) (string, error) {
+	jwks := h.c.DecisionSigningJWKSURL()
+	if jwks == nil {
+		return "", errors.WithStack(herodot.ErrInternalServerError.WithReason("Signed decisions are enabled but no JSON Web Key Set is configured."))
+	}
+
+	now := time.Now().UTC()
+	htu := url.URL{Scheme: r.URL.Scheme, Host: r.URL.Host, Path: r.URL.Path}
+	claims := jwt.MapClaims{
+		"sub": subject,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(h.c.DecisionSigningTTL()).Unix(),
+		"jti": uuid.New(),
+		"htm": r.Method,
+		"htu": htu.String(),
+		"hsh": DecisionHeaderHash(header),
+		"rid": rl.ID,
+	}
+	if issuer := h.c.DecisionSigningIssuer(); issuer != "" {
+		claims["iss"] = issuer
+	}
+
+	return h.r.CredentialsSigner().Sign(r.Context(), jwks, claims)
+}
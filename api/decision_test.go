@@ -25,12 +25,18 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
+	"github.com/dgrijalva/jwt-go"
+
 	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
 
 	"github.com/urfave/negroni"
 
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/credentials"
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/internal"
 
@@ -312,3 +318,48 @@ func TestDecisionAPI(t *testing.T) {
 		})
 	}
 }
+
+func TestDecisionAPISigned(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorHeaderIsEnabled, true)
+	viper.Set(configuration.ViperKeyDecisionSigningIsEnabled, true)
+	viper.Set(configuration.ViperKeyDecisionSigningJWKSURL, "file://../test/stub/jwks-rsa-single.json")
+	viper.Set(configuration.ViperKeyDecisionSigningIssuer, "https://oathkeeper/")
+	reg := internal.NewRegistry(conf)
+
+	n := negroni.New(reg.DecisionHandler())
+	n.UseHandler(httprouter.New())
+
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	reg.RuleRepository().(*rule.RepositoryMemory).WithRules([]rule.Rule{{
+		ID:             "signed",
+		Match:          &rule.Match{Methods: []string{"GET"}, URL: ts.URL + "/signed/<[0-9]+>"},
+		Authenticators: []rule.Handler{{Handler: "anonymous"}},
+		Authorizer:     rule.Handler{Handler: "allow"},
+		Mutators:       []rule.Handler{{Handler: "header", Config: []byte(`{"headers":{"X-User":"{{ print .Subject }}"}}`)}},
+	}})
+
+	res, err := http.Get(ts.URL + "/decisions/signed/1234?foo=bar")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "anonymous", res.Header.Get("X-User"))
+
+	token, err := reg.CredentialsVerifier().Verify(context.Background(), res.Header.Get("X-Oathkeeper-Decision"), &credentials.ValidationContext{
+		Algorithms: []string{"RS256"},
+		KeyURLs:    []url.URL{*urlx.ParseOrPanic("file://../test/stub/jwks-rsa-single.json")},
+		Issuers:    []string{"https://oathkeeper/"},
+	})
+	require.NoError(t, err)
+
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, "anonymous", claims["sub"])
+	assert.Equal(t, "GET", claims["htm"])
+	assert.Equal(t, ts.URL+"/signed/1234", claims["htu"])
+	assert.Equal(t, "signed", claims["rid"])
+	assert.Equal(t, api.DecisionHeaderHash(http.Header{"X-User": {"anonymous"}}), claims["hsh"])
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	EventsPath = "/events"
+
+	eventsBuffer    = 256
+	eventsKeepAlive = 15 * time.Second
+)
+
+type eventsHandlerRegistry interface {
+	x.RegistryWriter
+	events.Registry
+}
+
+type EventsHandler struct {
+	r eventsHandlerRegistry
+}
+
+// An event published on the event stream
+// swagger:response event
+type swaggerEventResponse struct {
+	// in: body
+	Body events.Event
+}
+
+// swagger:parameters streamEvents
+type swaggerStreamEventsParameters struct {
+	// A filter expression selecting the events, for example "type:decision rule:users outcome:denied /admin". The
+	// terms "type", "rule", "subject", and "outcome" must match exactly, other terms must be contained in the event.
+	//
+	// in: query
+	Filter string `json:"filter"`
+}
+
+func NewEventsHandler(r eventsHandlerRegistry) *EventsHandler {
+	return &EventsHandler{r: r}
+}
+
+func (h *EventsHandler) SetRoutes(r *x.RouterAPI) {
+	r.GET(EventsPath, h.stream)
+}
+
+// swagger:route GET /events api streamEvents
+//
+// Stream events
+//
+// This endpoint streams the access control decisions of the proxy and the decisions API and the reloads of access
+// rule repositories as server-sent events. Each event is a single "data" line containing the JSON encoded event.
+// Secrets are redacted from the requested URL and from errors.
+//
+// Events are dropped if the client does not keep up. The client is then sent an event of type "dropped" with the
+// number of events it missed.
+//
+//     Produces:
+//     - text/event-stream
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: event
+//       400: genericError
+//       500: genericError
+func (h *EventsHandler) stream(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	filter, err := events.ParseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(helper.ErrBadRequest.WithReasonf("Invalid filter: %s", err)))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.r.Writer().WriteError(w, r, errors.New("The response writer does not support streaming."))
+		return
+	}
+
+	s := h.r.EventBus().Subscribe(eventsBuffer, filter)
+	defer s.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	var dropped uint64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-s.Events():
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+
+		if total := s.Dropped(); total > dropped {
+			if err := writeEvent(w, events.Event{Type: events.TypeDropped, Time: time.Now().UTC(), Dropped: total - dropped}); err != nil {
+				return
+			}
+			dropped = total
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, b)
+	return errors.WithStack(err)
+}
@@ -0,0 +1,129 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestEventsHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerDenyIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	r := internal.NewRegistry(conf)
+	r.RuleRepository().(*rule.RepositoryMemory).WithRules([]rule.Rule{
+		{
+			ID:             "allowed",
+			Match:          &rule.Match{URL: "http://<[^/]+>/allowed", Methods: []string{"GET"}},
+			Authenticators: []rule.Handler{{Handler: "anonymous"}},
+			Authorizer:     rule.Handler{Handler: "allow"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+		},
+		{
+			ID:             "denied",
+			Match:          &rule.Match{URL: "http://<[^/]+>/denied", Methods: []string{"GET"}},
+			Authenticators: []rule.Handler{{Handler: "anonymous"}},
+			Authorizer:     rule.Handler{Handler: "deny"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+		},
+	})
+
+	router := x.NewAPIRouter()
+	r.EventsHandler().SetRoutes(router)
+	n := negroni.New()
+	n.Use(r.DecisionHandler())
+	n.UseHandler(router)
+	server := httptest.NewServer(n)
+	defer server.Close()
+
+	t.Run("case=rejects invalid filters", func(t *testing.T) {
+		res, err := server.Client().Get(server.URL + api.EventsPath + "?filter=outcome:maybe")
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	// The subscription is active once the headers were received.
+	subscribe := func(t *testing.T, filter string) *http.Response {
+		res, err := server.Client().Get(server.URL + api.EventsPath + "?filter=" + url.QueryEscape(filter))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+		return res
+	}
+
+	read := func(t *testing.T, res *http.Response, n int) []events.Event {
+		var received []events.Event
+		scanner := bufio.NewScanner(res.Body)
+		for len(received) < n && scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+				var e events.Event
+				require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e))
+				received = append(received, e)
+			}
+		}
+		require.Len(t, received, n)
+		return received
+	}
+
+	all := subscribe(t, "")
+	defer all.Body.Close()
+	denied := subscribe(t, "type:decision outcome:denied")
+	defer denied.Body.Close()
+
+	for _, path := range []string{"/allowed?access_token=secret", "/denied", "/unknown"} {
+		res, err := server.Client().Get(server.URL + api.DecisionPath + path)
+		require.NoError(t, err)
+		res.Body.Close()
+	}
+
+	received := read(t, all, 3)
+	filtered := read(t, denied, 2)
+	assert.Equal(t, received[1:], filtered)
+
+	for k, tc := range []struct {
+		ruleID  string
+		granted bool
+		code    int
+		path    string
+	}{
+		{ruleID: "allowed", granted: true, code: http.StatusOK, path: "/allowed?access_token=[REDACTED]"},
+		{ruleID: "denied", code: http.StatusForbidden, path: "/denied"},
+		{code: http.StatusNotFound, path: "/unknown"},
+	} {
+		e := received[k]
+		assert.Equal(t, events.TypeDecision, e.Type, "%d", k)
+		require.NotNil(t, e.Decision, "%d", k)
+		assert.Equal(t, "decisions", e.Decision.Interface, "%d", k)
+		assert.Equal(t, tc.ruleID, e.Decision.RuleID, "%d", k)
+		assert.Equal(t, tc.granted, e.Decision.Granted, "%d", k)
+		assert.Equal(t, tc.code, e.Decision.StatusCode, "%d", k)
+		assert.Equal(t, "GET", e.Decision.Method, "%d", k)
+		assert.True(t, strings.HasSuffix(e.Decision.URL, tc.path), "%d: %s", k, e.Decision.URL)
+		if tc.granted {
+			assert.Equal(t, "anonymous", e.Decision.Subject, "%d", k)
+			assert.Empty(t, e.Decision.Error, "%d", k)
+		} else {
+			assert.NotEmpty(t, e.Decision.Error, "%d", k)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/explain"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	ExplanationsPath = "/explanations"
+)
+
+type explanationHandlerRegistry interface {
+	x.RegistryWriter
+	explain.Registry
+}
+
+type ExplanationHandler struct {
+	r explanationHandlerRegistry
+}
+
+// The trace of the pipeline of a denied request
+// swagger:response explanation
+type swaggerExplanationResponse struct {
+	// in: body
+	Body explain.Explanation
+}
+
+// swagger:parameters getExplanation
+type swaggerExplanationParameters struct {
+	// The ID of the request, as returned in the X-Request-Id header of the denial.
+	// in: path
+	// required: true
+	RequestID string `json:"request_id"`
+}
+
+func NewExplanationHandler(r explanationHandlerRegistry) *ExplanationHandler {
+	return &ExplanationHandler{r: r}
+}
+
+func (h *ExplanationHandler) SetRoutes(r *x.RouterAPI) {
+	r.GET(ExplanationsPath+"/:request_id", h.get)
+}
+
+// swagger:route GET /explanations/{request_id} api getExplanation
+//
+// Retrieve the explanation of a denied request
+//
+// Returns the trace of the pipeline of a denied request: the matched access rule and the outcome of each
+// authenticator, the authorizer, and each mutator. Explanations are only stored for the sampled fraction of requests
+// configured by `explanations.sample_rate`. Bodies are never recorded and secrets are redacted.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: explanation
+//       404: genericError
+//       500: genericError
+func (h *ExplanationHandler) get(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	e, ok, err := h.r.ExplanationRecorder().Get(r.Context(), ps.ByName("request_id"))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	} else if !ok {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("No explanation exists for this request.")))
+		return
+	}
+
+	h.r.Writer().Write(w, r, e)
+}
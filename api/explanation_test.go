@@ -0,0 +1,114 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/explain"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/redaction"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestExplanationHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerDenyIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyExplanationsSampleRate, 1)
+	defer viper.Set(configuration.ViperKeyExplanationsSampleRate, 0)
+	r := internal.NewRegistry(conf)
+
+	router := x.NewAPIRouter()
+	r.ExplanationHandler().SetRoutes(router)
+	n := negroni.New(r.DecisionHandler())
+	n.UseHandler(router)
+	server := httptest.NewServer(n)
+	defer server.Close()
+
+	r.RuleRepository().(*rule.RepositoryMemory).WithRules([]rule.Rule{
+		{
+			ID:             "allow",
+			Match:          &rule.Match{Methods: []string{"GET"}, URL: server.URL + "/allow"},
+			Authenticators: []rule.Handler{{Handler: "anonymous"}},
+			Authorizer:     rule.Handler{Handler: "allow"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+		},
+		{
+			ID:             "deny",
+			Match:          &rule.Match{Methods: []string{"GET"}, URL: server.URL + "/deny"},
+			Authenticators: []rule.Handler{{Handler: "anonymous"}},
+			Authorizer:     rule.Handler{Handler: "deny"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+		},
+	})
+
+	do := func(t *testing.T, path, requestID string, expectCode int) *http.Response {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Cookie", "session=secret")
+		if requestID != "" {
+			req.Header.Set(explain.RequestIDHeader, requestID)
+		}
+		res, err := server.Client().Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, expectCode, res.StatusCode)
+		return res
+	}
+
+	t.Run("case=unknown request", func(t *testing.T) {
+		do(t, api.ExplanationsPath+"/unknown", "", http.StatusNotFound).Body.Close()
+	})
+
+	t.Run("case=granted requests are not explained", func(t *testing.T) {
+		res := do(t, api.DecisionPath+"/allow", "granted", http.StatusOK)
+		res.Body.Close()
+		assert.Empty(t, res.Header.Get(explain.RequestIDHeader))
+
+		do(t, api.ExplanationsPath+"/granted", "", http.StatusNotFound).Body.Close()
+	})
+
+	t.Run("case=denied requests are explained", func(t *testing.T) {
+		res := do(t, api.DecisionPath+"/deny", "denied", http.StatusForbidden)
+		res.Body.Close()
+		assert.Equal(t, "denied", res.Header.Get(explain.RequestIDHeader))
+
+		res = do(t, api.ExplanationsPath+"/denied", "", http.StatusOK)
+		defer res.Body.Close()
+
+		var e explain.Explanation
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&e))
+		assert.Equal(t, "denied", e.RequestID)
+		assert.Equal(t, "deny", e.RuleID)
+		assert.Equal(t, http.StatusForbidden, e.StatusCode)
+		assert.NotEmpty(t, e.Error)
+		assert.Equal(t, redaction.RedactedValue, e.Request.Header.Get("Cookie"))
+
+		var steps []string
+		for _, s := range e.Steps {
+			steps = append(steps, s.Stage+"/"+s.Handler+"/"+s.Result)
+		}
+		assert.Equal(t, []string{"match//passed", "authentication/anonymous/passed", "authorization/deny/failed"}, steps)
+	})
+
+	t.Run("case=requests without an ID get one", func(t *testing.T) {
+		res := do(t, api.DecisionPath+"/deny", "", http.StatusForbidden)
+		res.Body.Close()
+
+		id := res.Header.Get(explain.RequestIDHeader)
+		require.NotEmpty(t, id)
+		do(t, api.ExplanationsPath+"/"+id, "", http.StatusOK).Body.Close()
+	})
+}
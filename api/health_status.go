@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/ory/oathkeeper/health"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	HealthStatusPath = "/health/status"
+)
+
+type healthStatusHandlerRegistry interface {
+	x.RegistryWriter
+	health.Registry
+}
+
+type HealthStatusHandler struct {
+	r healthStatusHandlerRegistry
+}
+
+// swagger:response healthDependencyStatus
+type swaggerHealthStatusResponse struct {
+	// in: body
+	Body health.Status
+}
+
+func NewHealthStatusHandler(r healthStatusHandlerRegistry) *HealthStatusHandler {
+	return &HealthStatusHandler{r: r}
+}
+
+func (h *HealthStatusHandler) SetRoutes(r *x.RouterAPI) {
+	r.GET(HealthStatusPath, h.get)
+}
+
+// swagger:route GET /health/status api getHealthStatus
+//
+// Get the health of the dependencies
+//
+// Returns the health of the dependencies of this instance, such as the access rule repositories, JSON Web Key Sets
+// and token introspection endpoints. An unhealthy hard dependency makes the instance not ready, an unhealthy soft
+// dependency marks it as degraded while it stays ready.
+//
+// Be aware that if you are running multiple nodes of this service, the health status will never refer to the cluster
+// state, only to a single instance.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: healthDependencyStatus
+//       503: healthDependencyStatus
+func (h *HealthStatusHandler) get(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	status := h.r.HealthChecker().Status()
+
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	h.r.Writer().WriteCode(w, r, code, &status)
+}
@@ -0,0 +1,62 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/health"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestHealthStatusHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAccessRuleStagingIsEnabled, true)
+	defer viper.Set(configuration.ViperKeyAccessRuleStagingIsEnabled, false)
+	r := internal.NewRegistry(conf)
+
+	router := x.NewAPIRouter()
+	r.HealthStatusHandler().SetRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	get := func(t *testing.T, code int) health.Status {
+		res, err := server.Client().Get(server.URL + api.HealthStatusPath)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, code, res.StatusCode)
+
+		var status health.Status
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+		return status
+	}
+
+	t.Run("case=not ready until the access rules are activated", func(t *testing.T) {
+		status := get(t, http.StatusServiceUnavailable)
+		assert.Equal(t, health.StatusUnavailable, status.Status)
+		assert.False(t, status.Ready)
+		assert.Equal(t, health.WeightHard, status.Dependencies["access_rules"].Weight)
+		assert.False(t, status.Dependencies["access_rules"].Healthy)
+	})
+
+	t.Run("case=soft dependencies only degrade the instance", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyHealthDependencies+".access_rules", health.WeightSoft)
+		defer viper.Set(configuration.ViperKeyHealthDependencies+".access_rules", "")
+
+		status := get(t, http.StatusOK)
+		assert.Equal(t, health.StatusDegraded, status.Status)
+		assert.True(t, status.Ready)
+		assert.True(t, status.Degraded)
+		assert.Equal(t, health.WeightSoft, status.Dependencies["access_rules"].Weight)
+		assert.True(t, status.Dependencies["jwks"].Healthy)
+	})
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/lockout"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	LockoutsPath = "/lockouts"
+)
+
+type lockoutHandlerRegistry interface {
+	x.RegistryWriter
+	x.RegistryLogger
+	lockout.Registry
+}
+
+type LockoutHandler struct {
+	r lockoutHandlerRegistry
+}
+
+// The locked keys and the lockout statistics
+// swagger:model lockouts
+type lockouts struct {
+	// Locked are the keys which are currently locked.
+	Locked []lockout.Status `json:"locked"`
+
+	// Stats counts failed attempts, lockouts, delayed and rejected requests since this instance started.
+	Stats lockout.Stats `json:"stats"`
+}
+
+// swagger:response lockouts
+type swaggerLockoutsResponse struct {
+	// in: body
+	Body lockouts
+}
+
+// swagger:parameters unlock
+type swaggerLockoutParameters struct {
+	// The locked key, for example "ip:192.0.2.1" or "subject:alice".
+	// in: path
+	// required: true
+	Key string `json:"key"`
+}
+
+func NewLockoutHandler(r lockoutHandlerRegistry) *LockoutHandler {
+	return &LockoutHandler{r: r}
+}
+
+func (h *LockoutHandler) SetRoutes(r *x.RouterAPI) {
+	r.GET(LockoutsPath, h.list)
+	r.DELETE(LockoutsPath+"/*key", h.unlock)
+}
+
+// swagger:route GET /lockouts api listLockouts
+//
+// List locked keys
+//
+// Returns the keys (IP addresses, token prefixes and subjects) which are locked because they failed to authenticate
+// too often, and statistics about failed attempts and lockouts.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: lockouts
+//       500: genericError
+func (h *LockoutHandler) list(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	locked, err := h.r.LockoutTracker().Locked(r.Context())
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, &lockouts{Locked: locked, Stats: h.r.LockoutTracker().Stats()})
+}
+
+// swagger:route DELETE /lockouts/{key} api unlock
+//
+// Unlock a key
+//
+// Forgets all failed authentication attempts of the key and unlocks it.
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       204: emptyResponse
+//       400: genericError
+//       500: genericError
+func (h *LockoutHandler) unlock(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// The key is matched using a catch-all parameter because subjects may contain slashes.
+	key := strings.TrimPrefix(ps.ByName("key"), "/")
+	if key == "" {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason("The key must not be empty.")))
+		return
+	}
+
+	if err := h.r.LockoutTracker().Reset(r.Context(), []string{key}); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Logger().WithField("key", key).Info("Unlocked key.")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestLockoutHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyLockoutLockAfter, 1)
+	viper.Set(configuration.ViperKeyLockoutDelayAfter, 0)
+	r := internal.NewRegistry(conf)
+
+	router := x.NewAPIRouter()
+	r.LockoutHandler().SetRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx := context.Background()
+	require.NoError(t, r.LockoutTracker().Fail(ctx, []string{"subject:users/alice"}))
+
+	list := func() (l struct {
+		Locked []struct {
+			Key string `json:"key"`
+		} `json:"locked"`
+		Stats struct {
+			Lockouts int `json:"lockouts"`
+		} `json:"stats"`
+	}) {
+		res, err := server.Client().Get(server.URL + api.LockoutsPath)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&l))
+		return l
+	}
+
+	l := list()
+	require.Len(t, l.Locked, 1)
+	assert.Equal(t, "subject:users/alice", l.Locked[0].Key)
+	assert.Equal(t, 1, l.Stats.Lockouts)
+
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{path: api.LockoutsPath + "/subject:users/alice", status: http.StatusNoContent},
+		{path: api.LockoutsPath + "/", status: http.StatusBadRequest},
+	} {
+		t.Run("path="+tc.path, func(t *testing.T) {
+			req, err := http.NewRequest("DELETE", server.URL+tc.path, nil)
+			require.NoError(t, err)
+
+			res, err := server.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tc.status, res.StatusCode)
+		})
+	}
+
+	assert.Len(t, list().Locked, 0)
+}
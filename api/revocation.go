@@ -0,0 +1,187 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/revocation"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	RevocationBackChannelLogoutPath = "/revocation/backchannel-logout"
+	RevocationEventsPath            = "/revocation/events"
+)
+
+type revocationHandlerRegistry interface {
+	x.RegistryWriter
+	x.RegistryLogger
+	credentials.VerifierRegistry
+	revocation.Registry
+}
+
+type RevocationHandler struct {
+	c configuration.Provider
+	r revocationHandlerRegistry
+}
+
+func NewRevocationHandler(c configuration.Provider, r revocationHandlerRegistry) *RevocationHandler {
+	return &RevocationHandler{c: c, r: r}
+}
+
+func (h *RevocationHandler) SetRoutes(r *x.RouterAPI) {
+	r.POST(RevocationBackChannelLogoutPath, h.backChannelLogout)
+	r.POST(RevocationEventsPath, h.events)
+}
+
+// swagger:route POST /revocation/backchannel-logout api backChannelLogout
+//
+// OpenID Connect Back-Channel Logout
+//
+// This endpoint receives OpenID Connect Back-Channel Logout Tokens (form parameter `logout_token`) and revokes
+// the referenced subject or session. Credentials of revoked sessions are rejected from then on.
+//
+//     Consumes:
+//     - application/x-www-form-urlencoded
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: emptyResponse
+//       400: genericError
+//       404: genericError
+//       500: genericError
+func (h *RevocationHandler) backChannelLogout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Cache-Control", "no-store")
+	if !h.c.RevocationIsEnabled() {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("Revocation is disabled.")))
+		return
+	}
+
+	claims, err := h.verify(r, r.PostFormValue("logout_token"))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	e, err := revocation.EventFromLogoutToken(claims)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, herodot.ErrBadRequest.WithReason(err.Error()).WithTrace(err))
+		return
+	}
+
+	if err := h.revoke(r, *e); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// swagger:route POST /revocation/events api receiveSecurityEvent
+//
+// Receive Security Events
+//
+// This endpoint receives Security Event Tokens (RFC 8417) using push-based delivery (RFC 8935). CAEP
+// `session-revoked` as well as RISC `sessions-revoked` and `account-disabled` events revoke the referenced
+// subject or session, other events are acknowledged but ignored.
+//
+//     Consumes:
+//     - application/secevent+jwt
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       202: emptyResponse
+//       400: genericError
+//       404: genericError
+//       500: genericError
+func (h *RevocationHandler) events(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !h.c.RevocationIsEnabled() {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("Revocation is disabled.")))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(err))
+		return
+	}
+
+	claims, err := h.verify(r, strings.TrimSpace(string(body)))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	events, err := revocation.EventsFromSecurityEventToken(claims)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, herodot.ErrBadRequest.WithReason(err.Error()).WithTrace(err))
+		return
+	}
+
+	for _, e := range events {
+		if err := h.revoke(r, e); err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *RevocationHandler) verify(r *http.Request, token string) (jwt.MapClaims, error) {
+	if token == "" {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("The request does not contain a token."))
+	}
+
+	jwksu, err := h.c.ParseURLs(h.c.RevocationJWKSURLs())
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := h.r.CredentialsVerifier().Verify(r.Context(), token, &credentials.ValidationContext{
+		Algorithms: h.c.RevocationAllowedAlgorithms(),
+		KeyURLs:    jwksu,
+		Issuers:    h.c.RevocationTrustedIssuers(),
+		Audiences:  h.c.RevocationTargetAudience(),
+	})
+	if err != nil {
+		return nil, herodot.ErrBadRequest.WithReasonf("The token could not be verified: %s", err).WithTrace(err)
+	}
+
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Expected JSON Web Token claims to be of type jwt.MapClaims but got: %T", t.Claims))
+	}
+
+	return claims, nil
+}
+
+func (h *RevocationHandler) revoke(r *http.Request, e revocation.Event) error {
+	if err := h.r.RevocationStore().Revoke(r.Context(), e); err != nil {
+		return err
+	}
+
+	h.r.Logger().
+		WithField("subject", e.Subject).
+		WithField("session_id", e.SessionID).
+		WithField("revoked_at", e.Time).
+		Info("Revoked sessions.")
+	return nil
+}
@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/revocation"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestRevocationHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyRevocationJWKSURLs, []string{"file://../test/stub/jwks-rsa-single.json"})
+	viper.Set(configuration.ViperKeyRevocationTrustedIssuers, []string{"https://idp/"})
+	r := internal.NewRegistry(conf)
+
+	router := x.NewAPIRouter()
+	r.RevocationHandler().SetRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	sign := func(t *testing.T, claims jwt.MapClaims) string {
+		token, err := r.CredentialsSigner().Sign(context.Background(), urlx.ParseOrPanic("file://../test/stub/jwks-rsa-single.json"), claims)
+		require.NoError(t, err)
+		return token
+	}
+
+	issuedBefore := time.Now().Add(-time.Minute)
+
+	t.Run("case=disabled", func(t *testing.T) {
+		res, err := server.Client().PostForm(server.URL+api.RevocationBackChannelLogoutPath, url.Values{"logout_token": {"foo"}})
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	viper.Set(configuration.ViperKeyRevocationIsEnabled, true)
+
+	t.Run("case=backchannel logout", func(t *testing.T) {
+		token := sign(t, jwt.MapClaims{
+			"iss":    "https://idp/",
+			"iat":    time.Now().Unix(),
+			"sid":    "session-1",
+			"events": map[string]interface{}{revocation.EventTypeBackChannelLogout: map[string]interface{}{}},
+		})
+
+		res, err := server.Client().PostForm(server.URL+api.RevocationBackChannelLogoutPath, url.Values{"logout_token": {token}})
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		assert.True(t, r.RevocationStore().IsRevoked(context.Background(), "", "session-1", issuedBefore))
+		assert.False(t, r.RevocationStore().IsRevoked(context.Background(), "", "session-2", issuedBefore))
+	})
+
+	t.Run("case=security event", func(t *testing.T) {
+		token := sign(t, jwt.MapClaims{
+			"iss":    "https://idp/",
+			"iat":    time.Now().Unix(),
+			"events": map[string]interface{}{revocation.EventTypeCAEPSessionRevoked: map[string]interface{}{}},
+			"sub_id": map[string]interface{}{"format": "iss_sub", "iss": "https://idp/", "sub": "alice"},
+		})
+
+		res, err := server.Client().Post(server.URL+api.RevocationEventsPath, "application/secevent+jwt", strings.NewReader(token))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+		assert.True(t, r.RevocationStore().IsRevoked(context.Background(), "alice", "", issuedBefore))
+		assert.False(t, r.RevocationStore().IsRevoked(context.Background(), "alice", "", time.Now().Add(time.Minute)))
+	})
+
+	for _, tc := range []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{name: "untrusted issuer", claims: jwt.MapClaims{"iss": "https://other-idp/", "sub": "alice", "events": map[string]interface{}{revocation.EventTypeBackChannelLogout: map[string]interface{}{}}}},
+		{name: "missing event", claims: jwt.MapClaims{"iss": "https://idp/", "sub": "alice"}},
+		{name: "nonce", claims: jwt.MapClaims{"iss": "https://idp/", "sub": "alice", "nonce": "foo", "events": map[string]interface{}{revocation.EventTypeBackChannelLogout: map[string]interface{}{}}}},
+	} {
+		t.Run("case=invalid/"+tc.name, func(t *testing.T) {
+			res, err := server.Client().PostForm(server.URL+api.RevocationBackChannelLogoutPath, url.Values{"logout_token": {sign(t, tc.claims)}})
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		})
+	}
+}
@@ -21,14 +21,18 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/rule"
 	"github.com/ory/oathkeeper/x"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
 
+	"github.com/ory/herodot"
 	"github.com/ory/oathkeeper/helper"
 	"github.com/ory/x/pagination"
 )
@@ -37,22 +41,45 @@ const (
 	RulesPath = "/rules"
 )
 
+// rulesStatus is the status of the access rule repositories.
+//
+// swagger:model rulesStatus
+type rulesStatus struct {
+	// Repositories is the status of each access rule repository.
+	Repositories []rule.RepositoryStatus `json:"repositories"`
+
+	// Conflicts are the IDs shared by more than one access rule.
+	Conflicts []rule.Conflict `json:"conflicts"`
+
+	// Expired are the access rules whose expiry date has passed.
+	Expired []rule.ExpiredRule `json:"expired"`
+
+	// Warnings are the problems of the access rules which do not prevent them from being used, for example
+	// deprecated fields or suspicious regular expressions.
+	Warnings []rule.Warning `json:"warnings"`
+}
+
 type RuleHandler struct {
+	c configuration.Provider
 	r ruleHandlerRegistry
 }
 
 type ruleHandlerRegistry interface {
 	x.RegistryWriter
 	rule.Registry
+	rule.ManagedRegistry
 }
 
-func NewRuleHandler(r ruleHandlerRegistry) *RuleHandler {
-	return &RuleHandler{r: r}
+func NewRuleHandler(c configuration.Provider, r ruleHandlerRegistry) *RuleHandler {
+	return &RuleHandler{c: c, r: r}
 }
 
 func (h *RuleHandler) SetRoutes(r *x.RouterAPI) {
 	r.GET(RulesPath, h.listRules)
+	r.PUT(RulesPath, h.putRules)
 	r.GET(RulesPath+"/:id", h.getRules)
+	r.PUT(RulesPath+"/:id", h.putRule)
+	r.DELETE(RulesPath+"/:id", h.deleteRule)
 }
 
 // swagger:route GET /rules api listRules
@@ -60,7 +87,10 @@ func (h *RuleHandler) SetRoutes(r *x.RouterAPI) {
 // List all rules
 //
 // This method returns an array of all rules that are stored in the backend. This is useful if you want to get a full
-// view of what rules you have currently in place.
+// view of what rules you have currently in place. The ETag header identifies the version of the access rules managed
+// through this API and can be used in the If-Match header of a bulk update.
+//
+// The rules can be filtered by their owner and by a selector of their labels, for example "tier=critical,region!=eu".
 //
 //     Consumes:
 //     - application/json
@@ -72,10 +102,24 @@ func (h *RuleHandler) SetRoutes(r *x.RouterAPI) {
 //
 //     Responses:
 //       200: rules
+//       400: genericError
 //       500: genericError
 func (h *RuleHandler) listRules(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	limit, offset := pagination.Parse(r, 50, 0, 500)
-	rules, err := h.r.RuleRepository().List(r.Context(), limit, offset)
+
+	owner := r.URL.Query().Get("owner")
+	selector, err := rule.ParseLabelSelector(r.URL.Query().Get("labels"))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason(err.Error())))
+		return
+	}
+
+	var rules []rule.Rule
+	if owner == "" && selector.Empty() {
+		rules, err = h.r.RuleRepository().List(r.Context(), limit, offset)
+	} else {
+		rules, err = h.filterRules(r, owner, selector, limit, offset)
+	}
 	if err != nil {
 		h.r.Writer().WriteError(w, r, err)
 		return
@@ -85,14 +129,44 @@ func (h *RuleHandler) listRules(w http.ResponseWriter, r *http.Request, _ httpro
 		rules = make([]rule.Rule, 0)
 	}
 
+	w.Header().Set("ETag", h.r.RuleManagedRepository().ETag())
 	h.r.Writer().Write(w, r, rules)
 }
 
+// filterRules returns the page of rules owned by the owner, if set, whose labels match the selector.
+func (h *RuleHandler) filterRules(r *http.Request, owner string, selector *rule.LabelSelector, limit, offset int) ([]rule.Rule, error) {
+	count, err := h.r.RuleRepository().Count(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := h.r.RuleRepository().List(r.Context(), count, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []rule.Rule
+	for _, rl := range all {
+		if (owner == "" || rl.Owner == owner) && selector.Matches(rl.Labels()) {
+			rules = append(rules, rl)
+		}
+	}
+
+	if offset >= len(rules) {
+		return nil, nil
+	}
+	if end := offset + limit; end < len(rules) {
+		return rules[offset:end], nil
+	}
+	return rules[offset:], nil
+}
+
 // swagger:route GET /rules/{id} api getRule
 //
 // Retrieve a rule
 //
-// Use this method to retrieve a rule from the storage. If it does not exist you will receive a 404 error.
+// Use this method to retrieve a rule from the storage. If it does not exist you will receive a 404 error. The ETag
+// header identifies the version of the rule and can be used in the If-Match header of an update.
 //
 //     Consumes:
 //     - application/json
@@ -107,6 +181,12 @@ func (h *RuleHandler) listRules(w http.ResponseWriter, r *http.Request, _ httpro
 //       404: genericError
 //       500: genericError
 func (h *RuleHandler) getRules(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// The router does not allow a static path next to the ID parameter.
+	if ps.ByName("id") == "status" {
+		h.status(w, r)
+		return
+	}
+
 	rl, err := h.r.RuleRepository().Get(r.Context(), ps.ByName("id"))
 	if errors.Cause(err) == helper.ErrResourceNotFound {
 		h.r.Writer().WriteErrorCode(w, r, http.StatusNotFound, err)
@@ -116,5 +196,248 @@ func (h *RuleHandler) getRules(w http.ResponseWriter, r *http.Request, ps httpro
 		return
 	}
 
+	w.Header().Set("ETag", rl.ETag())
 	h.r.Writer().Write(w, r, rl)
 }
+
+// swagger:route GET /rules/status api getRulesStatus
+//
+// Status of the access rule repositories
+//
+// This endpoint returns the status of each access rule repository, the IDs shared by more than one access rule,
+// which shadow each other, the access rules whose expiry date has passed, and the warnings of all access rules. The ID
+// "status" is reserved for this endpoint, an access rule with this ID can not be retrieved.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: rulesStatus
+//       500: genericError
+func (h *RuleHandler) status(w http.ResponseWriter, r *http.Request) {
+	conflicts := h.r.RuleFetcher().Conflicts()
+	if conflicts == nil {
+		conflicts = make([]rule.Conflict, 0)
+	}
+
+	count, err := h.r.RuleRepository().Count(r.Context())
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	rules, err := h.r.RuleRepository().List(r.Context(), count, 0)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	expired := rule.ExpiredRules(rules, time.Now())
+	if expired == nil {
+		expired = make([]rule.ExpiredRule, 0)
+	}
+
+	warnings := rule.Warnings(h.r.RuleValidator(), rules)
+	if warnings == nil {
+		warnings = make([]rule.Warning, 0)
+	}
+
+	h.r.Writer().Write(w, r, &rulesStatus{
+		Repositories: h.r.RuleFetcher().Status(),
+		Conflicts:    conflicts,
+		Expired:      expired,
+		Warnings:     warnings,
+	})
+}
+
+// swagger:route PUT /rules/{id} api putRule
+//
+// Create or replace a rule
+//
+// Use this method to create or replace a rule managed through this API. Putting the same rule twice is a no-op. Use
+// the If-Match header with the ETag of the rule to make sure that it was not modified in the meantime, or the
+// If-None-Match header with the value "*" to only create it. Requires the "api://" access rule repository.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: rule
+//       201: rule
+//       400: genericError
+//       409: genericError
+//       412: genericError
+//       500: genericError
+func (h *RuleHandler) putRule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.managed(); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	var rl rule.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rl); err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(helper.ErrBadRequest.WithReasonf("Unable to decode the access rule: %s", err)))
+		return
+	}
+
+	id := ps.ByName("id")
+	if rl.ID == "" {
+		rl.ID = id
+	} else if rl.ID != id {
+		h.r.Writer().WriteError(w, r, errors.WithStack(helper.ErrBadRequest.WithReasonf("The access rule ID %s does not match the ID %s of the URL.", rl.ID, id)))
+		return
+	}
+
+	if err := h.validate(r, []rule.Rule{rl}); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	created, err := h.r.RuleManagedRepository().Put(rl, preconditions(r))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", rl.ETag())
+	if created {
+		h.r.Writer().WriteCreated(w, r, RulesPath+"/"+rl.ID, &rl)
+		return
+	}
+	h.r.Writer().Write(w, r, &rl)
+}
+
+// swagger:route DELETE /rules/{id} api deleteRule
+//
+// Delete a rule
+//
+// Use this method to delete a rule managed through this API. Use the If-Match header with the ETag of the rule to
+// make sure that it was not modified in the meantime.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       204: emptyResponse
+//       404: genericError
+//       409: genericError
+//       412: genericError
+//       500: genericError
+func (h *RuleHandler) deleteRule(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.managed(); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if err := h.r.RuleManagedRepository().Delete(ps.ByName("id"), preconditions(r)); errors.Cause(err) == helper.ErrResourceNotFound {
+		h.r.Writer().WriteErrorCode(w, r, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// swagger:route PUT /rules api putRules
+//
+// Replace all rules
+//
+// Use this method to replace all rules managed through this API with the given set of rules. The response lists which
+// rules were created, updated, deleted and left unchanged. Set the "dry_run" query parameter to "true" to only compute
+// the changes. Use the If-Match header with the ETag of the managed rules to make sure that they were not modified in
+// the meantime. Requires the "api://" access rule repository.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: ruleDiff
+//       400: genericError
+//       409: genericError
+//       412: genericError
+//       500: genericError
+func (h *RuleHandler) putRules(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if err := h.managed(); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	var rules []rule.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(helper.ErrBadRequest.WithReasonf("Unable to decode the access rules: %s", err)))
+		return
+	}
+
+	if err := h.validate(r, rules); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	diff, err := h.r.RuleManagedRepository().Replace(rules, preconditions(r), r.URL.Query().Get("dry_run") == "true")
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", h.r.RuleManagedRepository().ETag())
+	h.r.Writer().Write(w, r, diff)
+}
+
+// managed returns an error if the access rules managed through this API are not loaded.
+func (h *RuleHandler) managed() error {
+	for _, repository := range h.c.AccessRuleRepositories() {
+		if rule.IsManagedRepository(repository.Scheme) {
+			return nil
+		}
+	}
+	return errors.WithStack(helper.ErrResourceConflict.
+		WithReason(`Access rules can only be managed through the API if the "api://" access rule repository is configured.`))
+}
+
+// validate rejects invalid rules and rules whose ID is used by another access rule repository.
+func (h *RuleHandler) validate(r *http.Request, rules []rule.Rule) error {
+	for k := range rules {
+		rl := &rules[k]
+		if err := h.r.RuleValidator().Validate(rl); err != nil {
+			return errors.WithStack(helper.ErrBadRequest.WithReasonf("The access rule %s is invalid: %s", rl.ID, err))
+		}
+
+		if _, err := h.r.RuleRepository().Get(r.Context(), rl.ID); err == nil {
+			if _, err := h.r.RuleManagedRepository().Get(rl.ID); err != nil {
+				return errors.WithStack(helper.ErrResourceConflict.
+					WithReasonf("The access rule ID %s is used by another access rule repository.", rl.ID))
+			}
+		}
+	}
+
+	if h.c.AccessRuleStagingIsEnabled() {
+		if err := h.r.RuleStager().Stage(r.Context(), rules); err != nil {
+			return errors.WithStack(helper.ErrBadRequest.WithReason(err.Error()))
+		}
+	}
+
+	return nil
+}
+
+func preconditions(r *http.Request) rule.Preconditions {
+	return rule.Preconditions{IfMatch: r.Header.Get("If-Match"), IfNoneMatch: r.Header.Get("If-None-Match")}
+}
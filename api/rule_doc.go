@@ -20,7 +20,11 @@
 
 package api
 
-import "github.com/ory/oathkeeper/rule"
+import (
+	"time"
+
+	"github.com/ory/oathkeeper/rule"
+)
 
 // A rule
 // swagger:response rule
@@ -46,6 +50,14 @@ type swaggerListRulesParameters struct {
 	// The offset from where to start looking.
 	// in: query
 	Offset int `json:"offset"`
+
+	// Only return rules owned by this owner.
+	// in: query
+	Owner string `json:"owner"`
+
+	// Only return rules whose labels match this selector, for example "tier=critical,region!=eu".
+	// in: query
+	Labels string `json:"labels"`
 }
 
 // swagger:parameters getRule
@@ -55,6 +67,57 @@ type swaggerGetRuleParameters struct {
 	ID string `json:"id"`
 }
 
+// swagger:parameters putRule
+type swaggerPutRuleParameters struct {
+	// in: path
+	// required: true
+	ID string `json:"id"`
+
+	// Only replace the rule if its ETag matches.
+	// in: header
+	IfMatch string `json:"If-Match"`
+
+	// Set to "*" to only create the rule.
+	// in: header
+	IfNoneMatch string `json:"If-None-Match"`
+
+	// in: body
+	Body swaggerRule
+}
+
+// swagger:parameters deleteRule
+type swaggerDeleteRuleParameters struct {
+	// in: path
+	// required: true
+	ID string `json:"id"`
+
+	// Only delete the rule if its ETag matches.
+	// in: header
+	IfMatch string `json:"If-Match"`
+}
+
+// swagger:parameters putRules
+type swaggerPutRulesParameters struct {
+	// Only compute the changes without applying them.
+	// in: query
+	DryRun bool `json:"dry_run"`
+
+	// Only replace the rules if the ETag of the managed rules matches.
+	// in: header
+	IfMatch string `json:"If-Match"`
+
+	// in: body
+	// type: array
+	Body []swaggerRule
+}
+
+// The changes of replacing all rules
+// swagger:response ruleDiff
+type swaggerRuleDiffResponse struct {
+	// in: body
+	Body rule.ManagedDiff
+}
+
 // swagger:model ruleMatch
 type swaggerRuleMatch struct {
 	// An array of HTTP methods (e.g. GET, POST, PUT, DELETE, ...). When ORY Oathkeeper searches for rules
@@ -95,6 +158,24 @@ type swaggerRule struct {
 	// Description is a human readable description of this rule.
 	Description string `json:"description"`
 
+	// Owner is the team or person owning this rule.
+	Owner string `json:"owner,omitempty"`
+
+	// Metadata is free-form information about this rule.
+	Metadata *rule.Metadata `json:"metadata,omitempty"`
+
+	// DocsURL, if set, is the URL of the documentation of this rule. Error responses link to it.
+	DocsURL string `json:"docs_url,omitempty"`
+
+	// ExpiresAt, if set, is the time this rule expires at.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// ActiveFrom, if set, is the time this rule starts matching requests at.
+	ActiveFrom *time.Time `json:"active_from,omitempty"`
+
+	// ActiveUntil, if set, is the time this rule stops matching requests at.
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+
 	// Match defines the URL that this rule should match.
 	Match swaggerRuleMatch `json:"match"`
 
@@ -24,6 +24,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
@@ -31,6 +33,7 @@ import (
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/x"
 
+	"github.com/ory/viper"
 	"github.com/ory/x/pointerx"
 
 	"github.com/ory/oathkeeper/internal"
@@ -155,4 +158,190 @@ func TestHandler(t *testing.T) {
 		})
 
 	})
+
+	t.Run("case=filters rules by owner and labels", func(t *testing.T) {
+		labeled := make([]rule.Rule, len(rulesRegexp))
+		copy(labeled, rulesRegexp)
+		labeled[0].Owner = "payments"
+		labeled[0].Metadata = &rule.Metadata{Labels: map[string]string{"tier": "critical"}}
+		labeled[1].Owner = "identity"
+		labeled[1].Metadata = &rule.Metadata{Labels: map[string]string{"tier": "best-effort"}}
+		reg.RuleRepository().(*rule.RepositoryMemory).WithRules(labeled)
+		require.NoError(t, reg.RuleRepository().SetMatchingStrategy(context.Background(), configuration.Regexp))
+
+		list := func(query string) (int, []rule.Rule) {
+			res, err := http.Get(server.URL + "/rules?" + query)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			var rules []rule.Rule
+			if res.StatusCode == http.StatusOK {
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&rules))
+			}
+			return res.StatusCode, rules
+		}
+
+		for _, tc := range []struct {
+			query  string
+			expect []string
+		}{
+			{query: "owner=payments", expect: []string{"foo1"}},
+			{query: "labels=tier%3Dbest-effort", expect: []string{"foo2"}},
+			{query: "labels=tier%21%3Dcritical&owner=payments", expect: []string{}},
+			{query: "labels=tier&limit=1&offset=1", expect: []string{"foo2"}},
+		} {
+			code, rules := list(tc.query)
+			require.Equal(t, http.StatusOK, code, tc.query)
+
+			ids := make([]string, len(rules))
+			for k, rl := range rules {
+				ids[k] = rl.ID
+			}
+			assert.Equal(t, tc.expect, ids, tc.query)
+		}
+
+		code, _ := list("labels=%3Dcritical")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestHandlerManagedRules(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	reg := internal.NewRegistry(conf)
+
+	router := x.NewAPIRouter()
+	reg.RuleHandler().SetRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	newRule := func(id, upstream string) rule.Rule {
+		return rule.Rule{
+			ID:             id,
+			Match:          &rule.Match{URL: "https://localhost/" + id, Methods: []string{"GET"}},
+			Authenticators: []rule.Handler{{Handler: "noop"}},
+			Authorizer:     rule.Handler{Handler: "allow"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+			Upstream:       rule.Upstream{URL: upstream},
+		}
+	}
+
+	do := func(t *testing.T, method, path string, body interface{}, header http.Header) *http.Response {
+		var b bytes.Buffer
+		require.NoError(t, json.NewEncoder(&b).Encode(body))
+		req, err := http.NewRequest(method, server.URL+path, &b)
+		require.NoError(t, err)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		raw, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		res.Body = ioutil.NopCloser(bytes.NewReader(raw))
+		return res
+	}
+
+	t.Run("case=rejects writes without the api repository", func(t *testing.T) {
+		res := do(t, "PUT", "/rules/foo", newRule("foo", "http://upstream/"), nil)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+	})
+
+	viper.Set(configuration.ViperKeyAccessRuleRepositories, []string{"api://"})
+
+	var etag string
+	t.Run("case=creates a rule", func(t *testing.T) {
+		res := do(t, "PUT", "/rules/foo", newRule("foo", "http://upstream/"), nil)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		etag = res.Header.Get("ETag")
+		assert.NotEmpty(t, etag)
+
+		stored, err := reg.RuleManagedRepository().Get("foo")
+		require.NoError(t, err)
+		assert.Equal(t, "http://upstream/", stored.Upstream.URL)
+	})
+
+	t.Run("case=putting the same rule is idempotent", func(t *testing.T) {
+		changed := reg.RuleManagedRepository().Changed()
+		res := do(t, "PUT", "/rules/foo", newRule("foo", "http://upstream/"), nil)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, etag, res.Header.Get("ETag"))
+
+		select {
+		case <-changed:
+			t.Fatal("the managed rules must not change")
+		default:
+		}
+	})
+
+	t.Run("case=rejects invalid rules", func(t *testing.T) {
+		rl := newRule("foo", "http://upstream/")
+		rl.Authenticators = nil
+		res := do(t, "PUT", "/rules/foo", rl, nil)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("case=rejects mismatching IDs", func(t *testing.T) {
+		res := do(t, "PUT", "/rules/foo", newRule("bar", "http://upstream/"), nil)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("case=checks preconditions", func(t *testing.T) {
+		res := do(t, "PUT", "/rules/foo", newRule("foo", "http://other/"), http.Header{"If-Match": {`"outdated"`}})
+		assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+		res = do(t, "PUT", "/rules/foo", newRule("foo", "http://other/"), http.Header{"If-None-Match": {"*"}})
+		assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+		res = do(t, "PUT", "/rules/foo", newRule("foo", "http://other/"), http.Header{"If-Match": {etag}})
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotEqual(t, etag, res.Header.Get("ETag"))
+	})
+
+	t.Run("case=rejects IDs of other repositories", func(t *testing.T) {
+		reg.RuleRepository().(*rule.RepositoryMemory).WithRules([]rule.Rule{newRule("static", "http://upstream/")})
+		defer reg.RuleRepository().(*rule.RepositoryMemory).WithRules(nil)
+
+		res := do(t, "PUT", "/rules/static", newRule("static", "http://upstream/"), nil)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+	})
+
+	t.Run("case=replaces all rules", func(t *testing.T) {
+		rules := []rule.Rule{newRule("foo", "http://other/"), newRule("bar", "http://upstream/")}
+
+		res := do(t, "PUT", "/rules?dry_run=true", rules, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var diff rule.ManagedDiff
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&diff))
+		assert.Equal(t, rule.ManagedDiff{Created: []string{"bar"}, Updated: []string{}, Deleted: []string{}, Unchanged: []string{"foo"}}, diff)
+		assert.Len(t, reg.RuleManagedRepository().List(), 1)
+
+		res = do(t, "PUT", "/rules", rules, http.Header{"If-Match": {`"outdated"`}})
+		assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+		res = do(t, "PUT", "/rules", rules, http.Header{"If-Match": {reg.RuleManagedRepository().ETag()}})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, reg.RuleManagedRepository().ETag(), res.Header.Get("ETag"))
+		assert.Len(t, reg.RuleManagedRepository().List(), 2)
+
+		res = do(t, "PUT", "/rules", rules[1:], nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&diff))
+		assert.Equal(t, rule.ManagedDiff{Created: []string{}, Updated: []string{}, Deleted: []string{"foo"}, Unchanged: []string{"bar"}}, diff)
+	})
+
+	t.Run("case=deletes a rule", func(t *testing.T) {
+		res := do(t, "DELETE", "/rules/bar", nil, http.Header{"If-Match": {`"outdated"`}})
+		assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+		res = do(t, "DELETE", "/rules/bar", nil, nil)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		res = do(t, "DELETE", "/rules/bar", nil, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
 }
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/pipeline/authz"
+	pe "github.com/ory/oathkeeper/pipeline/errors"
+	"github.com/ory/oathkeeper/pipeline/mutate"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	SchemasPath = "/schemas/pipeline"
+)
+
+type schemaHandlerRegistry interface {
+	x.RegistryWriter
+	authn.Registry
+	authz.Registry
+	mutate.Registry
+	pe.Registry
+}
+
+type SchemaHandler struct {
+	r schemaHandlerRegistry
+}
+
+// The IDs of the pipeline handlers by kind
+// swagger:model pipelineSchemas
+type pipelineSchemas struct {
+	// Authenticators are the IDs of all authenticators, their schemas are served at
+	// /schemas/pipeline/authenticators/{id}.
+	Authenticators []string `json:"authenticators"`
+
+	// Authorizers are the IDs of all authorizers, their schemas are served at /schemas/pipeline/authorizers/{id}.
+	Authorizers []string `json:"authorizers"`
+
+	// Mutators are the IDs of all mutators, their schemas are served at /schemas/pipeline/mutators/{id}.
+	Mutators []string `json:"mutators"`
+
+	// Errors are the IDs of all error handlers, their schemas are served at /schemas/pipeline/errors/{id}.
+	Errors []string `json:"errors"`
+}
+
+// swagger:response pipelineSchemas
+type swaggerPipelineSchemasResponse struct {
+	// in: body
+	Body pipelineSchemas
+}
+
+// The JSON Schema of the configuration of a pipeline handler
+// swagger:response pipelineSchema
+type swaggerPipelineSchemaResponse struct {
+	// in: body
+	Body map[string]interface{}
+}
+
+// swagger:parameters getPipelineSchema
+type swaggerPipelineSchemaParameters struct {
+	// The kind of the pipeline handler: authenticators, authorizers, mutators, or errors.
+	// in: path
+	// required: true
+	Kind string `json:"kind"`
+
+	// The ID of the pipeline handler, for example jwt.
+	// in: path
+	// required: true
+	ID string `json:"id"`
+}
+
+func NewSchemaHandler(r schemaHandlerRegistry) *SchemaHandler {
+	return &SchemaHandler{r: r}
+}
+
+func (h *SchemaHandler) SetRoutes(r *x.RouterAPI) {
+	r.GET(SchemasPath, h.list)
+	r.GET(SchemasPath+"/:kind/:id", h.get)
+}
+
+// swagger:route GET /schemas/pipeline api listPipelineSchemas
+//
+// List the pipeline handlers with a configuration schema
+//
+// Returns the IDs of all authenticators, authorizers, mutators, and error handlers of this instance, whether they
+// are enabled or not. The JSON Schema of the configuration of each handler is served at
+// /schemas/pipeline/{kind}/{id}.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: pipelineSchemas
+func (h *SchemaHandler) list(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	schemas := &pipelineSchemas{
+		Authenticators: h.r.AvailablePipelineAuthenticators(),
+		Authorizers:    h.r.AvailablePipelineAuthorizers(),
+		Mutators:       h.r.AvailablePipelineMutators(),
+		Errors:         h.r.AvailablePipelineErrorHandlers().IDs(),
+	}
+	for _, ids := range [][]string{schemas.Authenticators, schemas.Authorizers, schemas.Mutators, schemas.Errors} {
+		sort.Strings(ids)
+	}
+
+	h.r.Writer().Write(w, r, schemas)
+}
+
+// swagger:route GET /schemas/pipeline/{kind}/{id} api getPipelineSchema
+//
+// Get the configuration schema of a pipeline handler
+//
+// Returns the JSON Schema (draft-07) which the configuration of the pipeline handler, both in the global
+// configuration and in access rules, is validated with. The schema is self-contained and can be used by rule editors
+// for validation and autocompletion.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: pipelineSchema
+//       404: genericError
+//       500: genericError
+func (h *SchemaHandler) get(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	kind, id := ps.ByName("kind"), ps.ByName("id")
+
+	var err error
+	switch kind {
+	case "authenticators":
+		_, err = h.r.PipelineAuthenticator(id)
+	case "authorizers":
+		_, err = h.r.PipelineAuthorizer(id)
+	case "mutators":
+		_, err = h.r.PipelineMutator(id)
+	case "errors":
+		_, err = h.r.PipelineErrorHandler(id)
+	default:
+		err = errors.Errorf("unknown kind %s", kind)
+	}
+	if err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReasonf("The pipeline handler %s/%s does not exist.", kind, id)))
+		return
+	}
+
+	schema, err := configuration.PipelineSchema(kind, id)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(schema)
+}
@@ -0,0 +1,92 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/gojsonschema"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestSchemaHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	r := internal.NewRegistry(conf)
+
+	router := x.NewAPIRouter()
+	r.SchemaHandler().SetRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	get := func(t *testing.T, path string) (*http.Response, []byte) {
+		res, err := server.Client().Get(server.URL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, body
+	}
+
+	t.Run("case=lists all pipeline handlers", func(t *testing.T) {
+		res, body := get(t, api.SchemasPath)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var schemas struct {
+			Authenticators []string `json:"authenticators"`
+			Authorizers    []string `json:"authorizers"`
+			Mutators       []string `json:"mutators"`
+			Errors         []string `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal(body, &schemas))
+		assert.Contains(t, schemas.Authenticators, "jwt")
+		assert.Contains(t, schemas.Authorizers, "allow")
+		assert.Contains(t, schemas.Mutators, "header")
+		assert.Equal(t, []string{"json", "redirect", "www_authenticate"}, schemas.Errors)
+	})
+
+	t.Run("case=every listed handler has a schema", func(t *testing.T) {
+		for kind, ids := range map[string][]string{
+			"authenticators": r.AvailablePipelineAuthenticators(),
+			"authorizers":    r.AvailablePipelineAuthorizers(),
+			"mutators":       r.AvailablePipelineMutators(),
+			"errors":         r.AvailablePipelineErrorHandlers().IDs(),
+		} {
+			for _, id := range ids {
+				res, body := get(t, api.SchemasPath+"/"+kind+"/"+id)
+				assert.Equal(t, http.StatusOK, res.StatusCode, "%s/%s: %s", kind, id, body)
+			}
+		}
+	})
+
+	t.Run("case=the schema is self-contained", func(t *testing.T) {
+		res, body := get(t, api.SchemasPath+"/authenticators/jwt")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(body))
+		require.NoError(t, err)
+
+		result, err := schema.Validate(gojsonschema.NewStringLoader(`{"jwks_urls":["https://issuer.example.org/.well-known/jwks.json"]}`))
+		require.NoError(t, err)
+		assert.True(t, result.Valid(), "%v", result.Errors())
+
+		result, err = schema.Validate(gojsonschema.NewStringLoader(`{"jwks_urls":"https://issuer.example.org/.well-known/jwks.json"}`))
+		require.NoError(t, err)
+		assert.False(t, result.Valid())
+	})
+
+	t.Run("case=unknown handlers are not found", func(t *testing.T) {
+		for _, path := range []string{"/authenticators/not-a-handler", "/rules/jwt", "/authorizers/jwt"} {
+			res, _ := get(t, api.SchemasPath+path)
+			assert.Equal(t, http.StatusNotFound, res.StatusCode, path)
+		}
+	})
+}
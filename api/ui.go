@@ -0,0 +1,253 @@
+package api
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/gobuffalo/packr/v2"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	UIPath         = "/ui"
+	UIStatusPath   = "/ui/status"
+	UISimulatePath = "/ui/simulate"
+)
+
+var ui = packr.New("ui", "./ui")
+
+type uiHandlerRegistry interface {
+	x.RegistryWriter
+	rule.Registry
+
+	DecisionHandler() *DecisionHandler
+}
+
+type UIHandler struct {
+	c configuration.Provider
+	r uiHandlerRegistry
+}
+
+// The status of the access rules
+// swagger:model uiStatus
+type uiStatus struct {
+	// MatchingStrategy is the matching strategy of the access rules.
+	MatchingStrategy configuration.MatchingStrategy `json:"matching_strategy"`
+
+	// Rules is the number of active access rules.
+	Rules int `json:"rules"`
+
+	// Repositories is the status of each access rule repository.
+	Repositories []rule.RepositoryStatus `json:"repositories"`
+}
+
+// A request to simulate
+// swagger:model uiSimulation
+type uiSimulation struct {
+	// Method is the HTTP method of the request.
+	Method string `json:"method"`
+
+	// URL is the absolute URL of the request, for example "https://api.example.com/users".
+	URL string `json:"url"`
+
+	// Header are the headers of the request.
+	Header map[string]string `json:"header"`
+
+	// Body is the body of the request.
+	Body string `json:"body"`
+}
+
+// The outcome of a simulated request
+// swagger:model uiSimulationResult
+type uiSimulationResult struct {
+	// RuleID is the ID of the matching access rule, if any.
+	RuleID string `json:"rule_id,omitempty"`
+
+	// StatusCode is the status code the decisions API responded with.
+	StatusCode int `json:"status_code"`
+
+	// Header are the headers the decisions API responded with.
+	Header http.Header `json:"header"`
+
+	// Body is the body the decisions API responded with.
+	Body string `json:"body"`
+}
+
+// swagger:response uiStatus
+type swaggerUIStatusResponse struct {
+	// in: body
+	Body uiStatus
+}
+
+// swagger:response uiSimulationResult
+type swaggerUISimulationResultResponse struct {
+	// in: body
+	Body uiSimulationResult
+}
+
+// swagger:parameters simulateDecision
+type swaggerUISimulationParameters struct {
+	// in: body
+	// required: true
+	Body uiSimulation
+}
+
+func NewUIHandler(c configuration.Provider, r uiHandlerRegistry) *UIHandler {
+	return &UIHandler{c: c, r: r}
+}
+
+func (h *UIHandler) SetRoutes(r *x.RouterAPI) {
+	r.GET(UIPath, h.index)
+	r.GET(UIStatusPath, h.status)
+	r.POST(UISimulatePath, h.simulate)
+}
+
+// enabled writes a 404 error and returns false if the admin UI is disabled.
+func (h *UIHandler) enabled(w http.ResponseWriter, r *http.Request) bool {
+	if !h.c.AdminUIIsEnabled() {
+		h.r.Writer().WriteErrorCode(w, r, http.StatusNotFound, errors.WithStack(helper.ErrResourceNotFound))
+		return false
+	}
+	return true
+}
+
+func (h *UIHandler) index(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !h.enabled(w, r) {
+		return
+	}
+
+	page, err := ui.Find("index.html")
+	if err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	_, _ = w.Write(page)
+}
+
+// swagger:route GET /ui/status api getUIStatus
+//
+// Status of the access rules
+//
+// This endpoint returns the number of active access rules and the status of each access rule repository. It is
+// only available if the admin UI is enabled.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: uiStatus
+//       404: genericError
+//       500: genericError
+func (h *UIHandler) status(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !h.enabled(w, r) {
+		return
+	}
+
+	count, err := h.r.RuleRepository().Count(r.Context())
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	strategy, err := h.r.RuleRepository().MatchingStrategy(r.Context())
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, &uiStatus{
+		MatchingStrategy: strategy,
+		Rules:            count,
+		Repositories:     h.r.RuleFetcher().Status(),
+	})
+}
+
+// swagger:route POST /ui/simulate api simulateDecision
+//
+// Simulate an access control decision
+//
+// This endpoint sends the given request to the decisions API and returns the matching access rule and the response.
+// The request runs through the complete pipeline, so authenticators and authorizers may call remote services. It is
+// only available if the admin UI is enabled.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http, https
+//
+//     Responses:
+//       200: uiSimulationResult
+//       400: genericError
+//       404: genericError
+//       500: genericError
+func (h *UIHandler) simulate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !h.enabled(w, r) {
+		return
+	}
+
+	var s uiSimulation
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(helper.ErrBadRequest.WithReasonf("Unable to decode the request: %s", err)))
+		return
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		h.r.Writer().WriteError(w, r, errors.WithStack(helper.ErrBadRequest.WithReasonf(`The URL "%s" is not absolute.`, s.URL)))
+		return
+	}
+
+	if s.Method == "" {
+		s.Method = "GET"
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(s.Method), DecisionPath+u.RequestURI(), strings.NewReader(s.Body))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(helper.ErrBadRequest.WithReason(err.Error())))
+		return
+	}
+	req = req.WithContext(r.Context())
+	req.Host = u.Host
+	req.RemoteAddr = r.RemoteAddr
+	for name, value := range s.Header {
+		req.Header.Set(name, value)
+	}
+	if u.Scheme == "https" {
+		// The decisions API derives the scheme of the request from the connection.
+		req.TLS = &tls.ConnectionState{}
+	}
+
+	var result uiSimulationResult
+	if rl, err := h.r.RuleMatcher().Match(r.Context(), req.Method, u); err == nil {
+		result.RuleID = rl.ID
+	}
+
+	rec := httptest.NewRecorder()
+	h.r.DecisionHandler().ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	result.StatusCode = rec.Code
+	result.Header = rec.Header()
+	result.Body = rec.Body.String()
+	h.r.Writer().Write(w, r, &result)
+}
@@ -0,0 +1,130 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+func TestUIHandler(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyAccessRuleRepositories, []string{"consul://consul:8500/oathkeeper/rules?token=secret"})
+	r := internal.NewRegistry(conf)
+	r.RuleRepository().(*rule.RepositoryMemory).WithRules([]rule.Rule{{
+		ID:             "users",
+		Match:          &rule.Match{URL: "https://api.example.com/users", Methods: []string{"GET"}},
+		Authenticators: []rule.Handler{{Handler: "noop"}},
+		Authorizer:     rule.Handler{Handler: "allow"},
+		Mutators:       []rule.Handler{{Handler: "noop"}},
+	}})
+
+	router := x.NewAPIRouter()
+	r.UIHandler().SetRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	do := func(t *testing.T, method, path string, body interface{}) (*http.Response, []byte) {
+		var b bytes.Buffer
+		require.NoError(t, json.NewEncoder(&b).Encode(body))
+		req, err := http.NewRequest(method, server.URL+path, &b)
+		require.NoError(t, err)
+		res, err := server.Client().Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		raw, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, raw
+	}
+
+	t.Run("case=is not available if disabled", func(t *testing.T) {
+		for _, path := range []string{api.UIPath, api.UIStatusPath} {
+			res, _ := do(t, "GET", path, nil)
+			assert.Equal(t, http.StatusNotFound, res.StatusCode, path)
+		}
+	})
+
+	viper.Set(configuration.ViperKeyAdminUIIsEnabled, true)
+
+	t.Run("case=serves the page", func(t *testing.T) {
+		res, body := do(t, "GET", api.UIPath, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Contains(t, res.Header.Get("Content-Type"), "text/html")
+		assert.Contains(t, string(body), "Decision Simulator")
+	})
+
+	t.Run("case=returns the status without credentials", func(t *testing.T) {
+		res, body := do(t, "GET", api.UIStatusPath, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var status struct {
+			Rules        int `json:"rules"`
+			Repositories []struct {
+				URL string `json:"url"`
+			} `json:"repositories"`
+		}
+		require.NoError(t, json.Unmarshal(body, &status))
+		assert.Equal(t, 1, status.Rules)
+		require.Len(t, status.Repositories, 1)
+		assert.Equal(t, "consul://consul:8500/oathkeeper/rules?token=REDACTED", status.Repositories[0].URL)
+	})
+
+	for k, tc := range []struct {
+		d        string
+		request  map[string]interface{}
+		status   int
+		ruleID   string
+		decision int
+	}{
+		{
+			d:        "allowed by the matching rule",
+			request:  map[string]interface{}{"method": "GET", "url": "https://api.example.com/users"},
+			status:   http.StatusOK,
+			ruleID:   "users",
+			decision: http.StatusOK,
+		},
+		{
+			d:        "no matching rule",
+			request:  map[string]interface{}{"method": "POST", "url": "https://api.example.com/users"},
+			status:   http.StatusOK,
+			decision: http.StatusNotFound,
+		},
+		{
+			d:       "relative url",
+			request: map[string]interface{}{"url": "/users"},
+			status:  http.StatusBadRequest,
+		},
+	} {
+		t.Run("case="+tc.d, func(t *testing.T) {
+			res, body := do(t, "POST", api.UISimulatePath, tc.request)
+			require.Equal(t, tc.status, res.StatusCode, "%d: %s", k, body)
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var result struct {
+				RuleID     string `json:"rule_id"`
+				StatusCode int    `json:"status_code"`
+			}
+			require.NoError(t, json.Unmarshal(body, &result))
+			assert.Equal(t, tc.ruleID, result.RuleID)
+			assert.Equal(t, tc.decision, result.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package bench
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/proxy"
+)
+
+// Target executes a request of the workload and returns the status code of the decision.
+type Target interface {
+	Do(ctx context.Context, r Request, timings proxy.StageTimings) (int, error)
+}
+
+// InProcess sends requests directly to the decisions API handler. The durations of the stages of the pipeline and
+// the allocations are only measured in-process.
+type InProcess struct {
+	h *api.DecisionHandler
+}
+
+func NewInProcess(h *api.DecisionHandler) *InProcess {
+	return &InProcess{h: h}
+}
+
+func (t *InProcess) Do(ctx context.Context, r Request, timings proxy.StageTimings) (int, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest(r.Method, api.DecisionPath+u.RequestURI(), nil)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	req = req.WithContext(proxy.WithStageTimings(ctx, timings))
+	req.Host = u.Host
+	req.RemoteAddr = "127.0.0.1:0"
+	req.Header = r.Header.Clone()
+	if u.Scheme == "https" {
+		// The decisions API derives the scheme of the request from the connection.
+		req.TLS = &tls.ConnectionState{}
+	}
+
+	rec := httptest.NewRecorder()
+	t.h.ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return rec.Code, nil
+}
+
+// Remote sends requests to the decisions API of a running instance. The host of the requested URL is sent as the
+// Host header, the scheme is the one of the endpoint.
+type Remote struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewRemote(endpoint string, concurrency int) *Remote {
+	return &Remote{
+		endpoint: endpoint,
+		client: &http.Client{
+			Transport: &http.Transport{MaxIdleConnsPerHost: concurrency},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (t *Remote) Do(ctx context.Context, r Request, _ proxy.StageTimings) (int, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest(r.Method, strings.TrimRight(t.endpoint, "/")+api.DecisionPath+u.RequestURI(), nil)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Host = u.Host
+	req.Header = r.Header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	return res.StatusCode, nil
+}
+
+// Options configure a benchmark.
+type Options struct {
+	// Requests is the number of requests sent, the workload is repeated as often as needed.
+	Requests int
+
+	// Concurrency is the number of requests sent at the same time.
+	Concurrency int
+}
+
+// Percentiles summarize a set of durations.
+type Percentiles struct {
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+func percentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	at := func(p float64) time.Duration {
+		return durations[int(p*float64(len(durations)-1))]
+	}
+	return Percentiles{P50: at(0.5), P95: at(0.95), P99: at(0.99), Max: durations[len(durations)-1]}
+}
+
+// Report is the result of a benchmark.
+type Report struct {
+	Requests int           `json:"requests"`
+	Errors   int           `json:"errors"`
+	Duration time.Duration `json:"duration"`
+
+	// Throughput is the number of decisions per second.
+	Throughput float64 `json:"throughput"`
+
+	// StatusCodes counts the decisions by status code.
+	StatusCodes map[int]int `json:"status_codes"`
+
+	// Latency summarizes the latency of the decisions.
+	Latency Percentiles `json:"latency"`
+
+	// Stages summarizes the durations of the stages of the pipeline, keyed by the name of the stage. It is only set
+	// for in-process benchmarks.
+	Stages map[string]Percentiles `json:"stages,omitempty"`
+
+	// AllocsPerDecision and BytesPerDecision are the heap allocations per decision. They are only set for in-process
+	// benchmarks and include the allocations of the benchmark itself.
+	AllocsPerDecision uint64 `json:"allocs_per_decision,omitempty"`
+	BytesPerDecision  uint64 `json:"bytes_per_decision,omitempty"`
+}
+
+// Run replays the workload against the target.
+func Run(ctx context.Context, target Target, workload []Request, o Options) (*Report, error) {
+	if len(workload) == 0 {
+		return nil, errors.New("the workload does not contain any requests")
+	}
+	if o.Requests <= 0 {
+		o.Requests = len(workload)
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+
+	_, inProcess := target.(*InProcess)
+
+	var lock sync.Mutex
+	report := &Report{Requests: o.Requests, StatusCodes: map[int]int{}}
+	latencies := make([]time.Duration, 0, o.Requests)
+	stages := map[string][]time.Duration{}
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := 0; i < o.Requests; i++ {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	for w := 0; w < o.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var timings proxy.StageTimings
+				if inProcess {
+					timings = proxy.StageTimings{}
+				}
+
+				start := time.Now()
+				code, err := target.Do(ctx, workload[i%len(workload)], timings)
+				latency := time.Since(start)
+
+				lock.Lock()
+				if err != nil {
+					report.Errors++
+				} else {
+					report.StatusCodes[code]++
+					latencies = append(latencies, latency)
+					for stage, d := range timings {
+						stages[stage] = append(stages[stage], d)
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report.Duration = time.Since(started)
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	report.Throughput = float64(o.Requests) / report.Duration.Seconds()
+	report.Latency = percentiles(latencies)
+	if inProcess {
+		report.AllocsPerDecision = (after.Mallocs - before.Mallocs) / uint64(o.Requests)
+		report.BytesPerDecision = (after.TotalAlloc - before.TotalAlloc) / uint64(o.Requests)
+		report.Stages = map[string]Percentiles{}
+		for stage, durations := range stages {
+			report.Stages[stage] = percentiles(durations)
+		}
+	}
+
+	return report, nil
+}
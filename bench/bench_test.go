@@ -0,0 +1,134 @@
+package bench_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/bench"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/proxy"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestLoadWorkload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oathkeeper-bench")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for k, tc := range []struct {
+		content   string
+		expect    []bench.Request
+		expectErr bool
+	}{
+		{
+			content: `[{"url":"https://api.example.com/users"},{"method":"post","url":"https://api.example.com/users","header":{"Authorization":["Bearer token"]}}]`,
+			expect: []bench.Request{
+				{Method: "GET", URL: "https://api.example.com/users"},
+				{Method: "POST", URL: "https://api.example.com/users", Header: http.Header{"Authorization": {"Bearer token"}}},
+			},
+		},
+		{
+			content: `{"rule_id":"users","snapshots":[{"request":{"method":"DELETE","url":"https://api.example.com/users/1"}}]}`,
+			expect:  []bench.Request{{Method: "DELETE", URL: "https://api.example.com/users/1"}},
+		},
+		{content: `[]`, expectErr: true},
+		{content: `[{"url":"/users"}]`, expectErr: true},
+	} {
+		path := filepath.Join(dir, "workload.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte(tc.content), 0600))
+
+		workload, err := bench.LoadWorkload(path)
+		if tc.expectErr {
+			assert.Error(t, err, "case %d", k)
+			continue
+		}
+		require.NoError(t, err, "case %d", k)
+		assert.Equal(t, tc.expect, workload, "case %d", k)
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	workload := []bench.Request{{URL: "https://api.example.com", Header: http.Header{"Authorization": {"[REDACTED]"}, "Accept": {"*/*"}}}}
+	replaced := bench.WithHeader(workload, http.Header{"Authorization": {"Bearer token"}})
+	assert.Equal(t, http.Header{"Authorization": {"Bearer token"}, "Accept": {"*/*"}}, replaced[0].Header)
+	assert.Equal(t, "[REDACTED]", workload[0].Header.Get("Authorization"))
+}
+
+func TestRun(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthenticatorUnauthorizedIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	reg := internal.NewRegistry(conf)
+
+	reg.RuleRepository().(*rule.RepositoryMemory).WithRules([]rule.Rule{
+		{
+			ID:             "allowed",
+			Match:          &rule.Match{Methods: []string{"GET"}, URL: "<https?>://api.example.com/allowed"},
+			Authenticators: []rule.Handler{{Handler: "anonymous"}},
+			Authorizer:     rule.Handler{Handler: "allow"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+		},
+		{
+			ID:             "denied",
+			Match:          &rule.Match{Methods: []string{"GET"}, URL: "<https?>://api.example.com/denied"},
+			Authenticators: []rule.Handler{{Handler: "unauthorized"}},
+			Authorizer:     rule.Handler{Handler: "allow"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+		},
+	})
+
+	workload := []bench.Request{
+		{Method: "GET", URL: "https://api.example.com/allowed"},
+		{Method: "GET", URL: "https://api.example.com/denied"},
+	}
+
+	t.Run("case=in-process", func(t *testing.T) {
+		report, err := bench.Run(context.Background(), bench.NewInProcess(reg.DecisionHandler()), workload, bench.Options{Requests: 100, Concurrency: 4})
+		require.NoError(t, err)
+
+		assert.Equal(t, 100, report.Requests)
+		assert.Equal(t, 0, report.Errors)
+		assert.Equal(t, map[int]int{http.StatusOK: 50, http.StatusUnauthorized: 50}, report.StatusCodes)
+		assert.True(t, report.Throughput > 0)
+		assert.True(t, report.Latency.P99 >= report.Latency.P50)
+		assert.True(t, report.AllocsPerDecision > 0)
+		for _, stage := range []string{proxy.StageMatch, proxy.StageAuthentication, proxy.StageAuthorization, proxy.StageMutation} {
+			assert.Contains(t, report.Stages, stage)
+		}
+
+		var out bytes.Buffer
+		require.NoError(t, report.Write(&out))
+		assert.Contains(t, out.String(), "50 × 200, 50 × 401")
+		assert.Contains(t, out.String(), "authentication")
+	})
+
+	t.Run("case=remote", func(t *testing.T) {
+		n := negroni.New(reg.DecisionHandler())
+		n.UseHandler(httprouter.New())
+		ts := httptest.NewServer(n)
+		defer ts.Close()
+
+		report, err := bench.Run(context.Background(), bench.NewRemote(ts.URL+"/", 2), workload, bench.Options{Requests: 10, Concurrency: 2})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[int]int{http.StatusOK: 5, http.StatusUnauthorized: 5}, report.StatusCodes)
+		assert.Nil(t, report.Stages)
+		assert.Zero(t, report.AllocsPerDecision)
+	})
+}
@@ -0,0 +1,56 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ory/oathkeeper/proxy"
+)
+
+// stageOrder is the order in which the stages of the pipeline are run.
+var stageOrder = []string{proxy.StageMatch, proxy.StageAuthentication, proxy.StageAuthorization, proxy.StageMutation}
+
+// Write writes a human readable summary of the report.
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Requests:\t%d (%d errors)\n", r.Requests, r.Errors)
+	fmt.Fprintf(tw, "Duration:\t%s\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(tw, "Throughput:\t%.1f decisions/s\n", r.Throughput)
+
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	fmt.Fprint(tw, "Status codes:\t")
+	for k, code := range codes {
+		if k > 0 {
+			fmt.Fprint(tw, ", ")
+		}
+		fmt.Fprintf(tw, "%d × %d", r.StatusCodes[code], code)
+	}
+	fmt.Fprintln(tw)
+
+	if r.Stages != nil {
+		fmt.Fprintf(tw, "Allocations:\t%d allocs/decision, %d B/decision\n", r.AllocsPerDecision, r.BytesPerDecision)
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "\tP50\tP95\tP99\tMAX")
+	writePercentiles(tw, "decision", r.Latency)
+	for _, stage := range stageOrder {
+		if p, ok := r.Stages[stage]; ok {
+			writePercentiles(tw, "  "+stage, p)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func writePercentiles(w io.Writer, name string, p Percentiles) {
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, p.P50, p.P95, p.P99, p.Max)
+}
@@ -0,0 +1,110 @@
+// Package bench replays a workload against the decisions API of ORY Oathkeeper, either in-process or over HTTP, and
+// reports the throughput, the latency of the decisions and of the stages of the pipeline, and the allocations.
+package bench
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/capture"
+)
+
+// Request is a request of a workload.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// LoadWorkload reads a workload from a file. The file either contains a JSON array of requests or a capture as
+// returned by the management API, in which case the captured requests are replayed.
+func LoadWorkload(path string) ([]Request, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var workload []Request
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(raw, &workload); err != nil {
+			return nil, errors.Wrapf(err, "unable to decode the workload %s", path)
+		}
+	} else {
+		var c capture.Capture
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return nil, errors.Wrapf(err, "unable to decode the capture %s", path)
+		}
+		for _, s := range c.Snapshots {
+			workload = append(workload, Request{Method: s.Request.Method, URL: s.Request.URL, Header: s.Request.Header})
+		}
+	}
+
+	if len(workload) == 0 {
+		return nil, errors.Errorf("the workload %s does not contain any requests", path)
+	}
+
+	for k := range workload {
+		if err := workload[k].validate(); err != nil {
+			return nil, errors.Wrapf(err, "request %d of the workload %s is invalid", k, path)
+		}
+	}
+
+	return workload, nil
+}
+
+// ParseHeaders parses headers given as "Name: value".
+func ParseHeaders(headers []string) (http.Header, error) {
+	parsed := http.Header{}
+	for _, h := range headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf(`the header "%s" must be formatted as "Name: value"`, h)
+		}
+		parsed.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return parsed, nil
+}
+
+// Synthetic returns a workload consisting of a single request.
+func Synthetic(method, u string, header http.Header) ([]Request, error) {
+	r := Request{Method: method, URL: u, Header: header}
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	return []Request{r}, nil
+}
+
+// WithHeader returns a copy of the workload whose requests carry the given headers, replacing headers of the same
+// name. Captured credentials are redacted, so they must be replaced before a capture is replayed.
+func WithHeader(workload []Request, header http.Header) []Request {
+	replaced := make([]Request, len(workload))
+	for k, r := range workload {
+		r.Header = r.Header.Clone()
+		if r.Header == nil {
+			r.Header = http.Header{}
+		}
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		replaced[k] = r
+	}
+	return replaced
+}
+
+func (r *Request) validate() error {
+	if r.Method == "" {
+		r.Method = http.MethodGet
+	}
+	r.Method = strings.ToUpper(r.Method)
+
+	u, err := url.Parse(r.URL)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return errors.Errorf(`the URL "%s" is not absolute`, r.URL)
+	}
+	return nil
+}
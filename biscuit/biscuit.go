@@ -0,0 +1,790 @@
+// Package biscuit verifies and authorizes Biscuit tokens (https://www.biscuitsec.org). Biscuits are public-key
+// signed capability tokens which can be attenuated offline by appending blocks containing Datalog checks.
+//
+// The package implements the version 3 format with Ed25519 signatures and the default scope, in which rules and
+// checks of a block trust the authority block, the block itself, and the authorizer. Tokens using third-party
+// blocks, custom scopes, or check kinds other than "check if" are rejected.
+package biscuit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	algorithmEd25519 = 0
+
+	// symbolOffset is the index of the first symbol defined by a token, lower indexes refer to defaultSymbols.
+	symbolOffset = 1024
+
+	minSchemaVersion = 3
+	maxSchemaVersion = 4
+)
+
+var (
+	ErrMalformed        = errors.New("biscuit: token is malformed")
+	ErrInvalidSignature = errors.New("biscuit: token signature is invalid")
+	ErrUnsupported      = errors.New("biscuit: token uses an unsupported feature")
+)
+
+var defaultSymbols = []string{
+	"read", "write", "resource", "operation", "right", "time", "role", "owner", "tenant", "namespace",
+	"user", "team", "service", "admin", "email", "group", "member", "ip_address", "client", "client_ip",
+	"domain", "path", "version", "cluster", "node", "hostname", "nonce", "query",
+}
+
+// Block is the authority block or an attenuation block of a Biscuit.
+type Block struct {
+	Facts   []Predicate
+	Rules   []Rule
+	Checks  []Check
+	Context string
+}
+
+// Biscuit is a token whose signatures were verified.
+type Biscuit struct {
+	// Blocks contains the authority block first.
+	Blocks []*Block
+
+	// RevocationIDs are the hex encodable signatures of the blocks which uniquely identify the token and all
+	// tokens derived from it.
+	RevocationIDs [][]byte
+
+	signed []signedBlock
+	proof  proof
+	table  []string
+}
+
+type signedBlock struct {
+	data      []byte
+	nextKey   ed25519.PublicKey
+	signature []byte
+}
+
+type proof struct {
+	nextSecret     []byte
+	finalSignature []byte
+}
+
+// Parse decodes the base64 encoded token and verifies its signatures. The authority block must be signed by one
+// of the root keys.
+func Parse(token string, roots []ed25519.PublicKey) (*Biscuit, error) {
+	b, err := decode(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.verify(roots); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Mint creates a token signed by the root key containing the authority block and optionally attenuation blocks.
+func Mint(root ed25519.PrivateKey, authority *Block, blocks ...*Block) (string, error) {
+	b := &Biscuit{}
+	if err := b.append(root, authority); err != nil {
+		return "", err
+	}
+
+	for _, block := range blocks {
+		if err := b.append(ed25519.NewKeyFromSeed(b.proof.nextSecret), block); err != nil {
+			return "", err
+		}
+	}
+
+	return b.encode(), nil
+}
+
+// Attenuate appends a block to the token. This does not require any key, the token must not be sealed.
+func Attenuate(token string, block *Block) (string, error) {
+	b, err := decode(token)
+	if err != nil {
+		return "", err
+	}
+
+	if len(b.proof.nextSecret) != ed25519.SeedSize {
+		return "", errors.New("biscuit: sealed tokens can not be attenuated")
+	}
+
+	if err := b.append(ed25519.NewKeyFromSeed(b.proof.nextSecret), block); err != nil {
+		return "", err
+	}
+
+	return b.encode(), nil
+}
+
+// Authorize runs the rules of all blocks on their facts and the ambient facts provided by the authorizer and
+// returns an error unless all checks succeed.
+func (b *Biscuit) Authorize(ambient []Predicate) error {
+	if len(b.Blocks) > maxBlocks {
+		return errors.WithStack(ErrUnsupported)
+	}
+
+	w := newWorld()
+	for _, f := range ambient {
+		if _, err := w.add(f, originAuthorizer); err != nil {
+			return err
+		}
+	}
+
+	var rules []scopedRule
+	for i, block := range b.Blocks {
+		for _, f := range block.Facts {
+			if _, err := w.add(f, blockOrigin(i)); err != nil {
+				return err
+			}
+		}
+		for _, r := range block.Rules {
+			rules = append(rules, scopedRule{rule: r, block: i})
+		}
+	}
+
+	if err := w.run(rules); err != nil {
+		return err
+	}
+
+	for i, block := range b.Blocks {
+		for _, c := range block.Checks {
+			var ok bool
+			for _, q := range c.Queries {
+				if err := w.query(q, trusted(i), func(map[string]Term, origin) bool {
+					ok = true
+					return false
+				}); err != nil {
+					return err
+				}
+				if ok {
+					break
+				}
+			}
+
+			if !ok {
+				return errors.Errorf("biscuit: check of block %d failed: %s", i, c)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AuthorityFacts returns the facts of the authority block.
+func (b *Biscuit) AuthorityFacts() []Predicate {
+	return b.Blocks[0].Facts
+}
+
+func (b *Biscuit) verify(roots []ed25519.PublicKey) error {
+	if len(b.signed) == 0 {
+		return errors.WithStack(ErrMalformed)
+	}
+
+	var valid bool
+	for _, root := range roots {
+		if len(root) == ed25519.PublicKeySize && ed25519.Verify(root, b.signed[0].payload(), b.signed[0].signature) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return errors.WithStack(ErrInvalidSignature)
+	}
+
+	for i := 1; i < len(b.signed); i++ {
+		if !ed25519.Verify(b.signed[i-1].nextKey, b.signed[i].payload(), b.signed[i].signature) {
+			return errors.WithStack(ErrInvalidSignature)
+		}
+	}
+
+	last := b.signed[len(b.signed)-1]
+	switch {
+	case len(b.proof.nextSecret) == ed25519.SeedSize:
+		public := ed25519.NewKeyFromSeed(b.proof.nextSecret).Public().(ed25519.PublicKey)
+		if !bytes.Equal(public, last.nextKey) {
+			return errors.WithStack(ErrInvalidSignature)
+		}
+	case len(b.proof.finalSignature) == ed25519.SignatureSize:
+		if !ed25519.Verify(last.nextKey, append(last.payload(), last.signature...), b.proof.finalSignature) {
+			return errors.WithStack(ErrInvalidSignature)
+		}
+	default:
+		return errors.WithStack(ErrMalformed)
+	}
+
+	return nil
+}
+
+// payload returns the signed data of the block: the serialized block, the algorithm of the next key as 32 bit
+// little endian integer, and the next key.
+func (s signedBlock) payload() []byte {
+	var algorithm [4]byte
+	binary.LittleEndian.PutUint32(algorithm[:], algorithmEd25519)
+
+	payload := make([]byte, 0, len(s.data)+len(algorithm)+len(s.nextKey))
+	payload = append(payload, s.data...)
+	payload = append(payload, algorithm[:]...)
+	return append(payload, s.nextKey...)
+}
+
+func (b *Biscuit) append(key ed25519.PrivateKey, block *Block) error {
+	nextPublic, nextPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	data, err := b.encodeBlock(block)
+	if err != nil {
+		return err
+	}
+
+	s := signedBlock{data: data, nextKey: nextPublic}
+	s.signature = ed25519.Sign(key, s.payload())
+
+	b.Blocks = append(b.Blocks, block)
+	b.signed = append(b.signed, s)
+	b.RevocationIDs = append(b.RevocationIDs, s.signature)
+	b.proof = proof{nextSecret: nextPrivate.Seed()}
+	return nil
+}
+
+func decode(token string) (*Biscuit, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.NewReplacer("+", "-", "/", "_").Replace(token), "="))
+	if err != nil || len(raw) == 0 {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+
+	fields, err := protoFields(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Biscuit{}
+	for _, f := range fields {
+		switch f.number {
+		case 2, 3:
+			s, err := decodeSignedBlock(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			if (f.number == 2) != (len(b.signed) == 0) {
+				return nil, errors.WithStack(ErrMalformed)
+			}
+			b.signed = append(b.signed, *s)
+		case 4:
+			proofFields, err := protoFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, pf := range proofFields {
+				switch pf.number {
+				case 1:
+					b.proof.nextSecret = pf.bytes
+				case 2:
+					b.proof.finalSignature = pf.bytes
+				}
+			}
+		}
+	}
+
+	if len(b.signed) == 0 {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+
+	for _, s := range b.signed {
+		block, err := b.decodeBlock(s.data)
+		if err != nil {
+			return nil, err
+		}
+		b.Blocks = append(b.Blocks, block)
+		b.RevocationIDs = append(b.RevocationIDs, s.signature)
+	}
+
+	return b, nil
+}
+
+func decodeSignedBlock(data []byte) (*signedBlock, error) {
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var s signedBlock
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			s.data = f.bytes
+		case 2:
+			keyFields, err := protoFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, kf := range keyFields {
+				switch {
+				case kf.number == 1 && kf.varint != algorithmEd25519:
+					return nil, errors.WithStack(ErrUnsupported)
+				case kf.number == 2:
+					s.nextKey = kf.bytes
+				}
+			}
+		case 3:
+			s.signature = f.bytes
+		case 4:
+			// External signatures are used by third-party blocks.
+			return nil, errors.WithStack(ErrUnsupported)
+		}
+	}
+
+	if s.data == nil || len(s.nextKey) != ed25519.PublicKeySize || len(s.signature) != ed25519.SignatureSize {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+	return &s, nil
+}
+
+func (b *Biscuit) decodeBlock(data []byte) (*Block, error) {
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Symbols must be known before facts, rules, and checks can be decoded.
+	for _, f := range fields {
+		if f.number == 1 {
+			b.table = append(b.table, string(f.bytes))
+		}
+	}
+
+	d := &blockDecoder{table: b.table}
+	block := &Block{}
+	version := uint64(minSchemaVersion)
+	for _, f := range fields {
+		switch f.number {
+		case 2:
+			block.Context = string(f.bytes)
+		case 3:
+			version = f.varint
+		case 4:
+			pf, err := protoFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			if len(pf) != 1 || pf[0].number != 1 {
+				return nil, errors.WithStack(ErrMalformed)
+			}
+			p, err := d.predicate(pf[0].bytes)
+			if err != nil {
+				return nil, err
+			}
+			block.Facts = append(block.Facts, *p)
+		case 5:
+			r, err := d.rule(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			block.Rules = append(block.Rules, *r)
+		case 6:
+			c, err := d.check(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			block.Checks = append(block.Checks, *c)
+		case 7, 8:
+			// Scopes and the public keys they reference are not supported.
+			return nil, errors.WithStack(ErrUnsupported)
+		}
+	}
+
+	if version < minSchemaVersion || version > maxSchemaVersion {
+		return nil, errors.WithStack(ErrUnsupported)
+	}
+
+	for _, f := range block.Facts {
+		for _, t := range f.Terms {
+			if t.Kind == TermVariable {
+				return nil, errors.WithStack(ErrMalformed)
+			}
+		}
+	}
+
+	return block, nil
+}
+
+type blockDecoder struct {
+	table []string
+}
+
+func (d *blockDecoder) symbol(i uint64) (string, error) {
+	if i < symbolOffset {
+		if i >= uint64(len(defaultSymbols)) {
+			return "", errors.WithStack(ErrMalformed)
+		}
+		return defaultSymbols[i], nil
+	}
+
+	if i-symbolOffset >= uint64(len(d.table)) {
+		return "", errors.WithStack(ErrMalformed)
+	}
+	return d.table[i-symbolOffset], nil
+}
+
+func (d *blockDecoder) predicate(data []byte) (*Predicate, error) {
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Predicate
+	var named bool
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			if p.Name, err = d.symbol(f.varint); err != nil {
+				return nil, err
+			}
+			named = true
+		case 2:
+			t, err := d.term(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			p.Terms = append(p.Terms, *t)
+		}
+	}
+
+	if !named {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+	return &p, nil
+}
+
+func (d *blockDecoder) term(data []byte) (*Term, error) {
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != 1 {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+
+	f := fields[0]
+	switch f.number {
+	case 1:
+		name, err := d.symbol(f.varint)
+		if err != nil {
+			return nil, err
+		}
+		t := Variable(name)
+		return &t, nil
+	case 2:
+		t := Integer(int64(f.varint))
+		return &t, nil
+	case 3:
+		s, err := d.symbol(f.varint)
+		if err != nil {
+			return nil, err
+		}
+		t := String(s)
+		return &t, nil
+	case 4:
+		return &Term{Kind: TermDate, Date: f.varint}, nil
+	case 5:
+		t := Bytes(f.bytes)
+		return &t, nil
+	case 6:
+		t := Bool(f.varint != 0)
+		return &t, nil
+	case 7:
+		elements, err := protoFields(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+		t := Set()
+		for _, e := range elements {
+			if e.number != 1 {
+				continue
+			}
+			element, err := d.term(e.bytes)
+			if err != nil {
+				return nil, err
+			}
+			if element.Kind == TermVariable || element.Kind == TermSet {
+				return nil, errors.WithStack(ErrMalformed)
+			}
+			t.Set = append(t.Set, *element)
+		}
+		return &t, nil
+	}
+	return nil, errors.WithStack(ErrUnsupported)
+}
+
+func (d *blockDecoder) rule(data []byte) (*Rule, error) {
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Rule
+	var headed bool
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			p, err := d.predicate(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			r.Head, headed = *p, true
+		case 2:
+			p, err := d.predicate(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			r.Body = append(r.Body, *p)
+		case 3:
+			e, err := d.expression(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			r.Expressions = append(r.Expressions, e)
+		case 4:
+			return nil, errors.WithStack(ErrUnsupported)
+		}
+	}
+
+	if !headed {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+	return &r, nil
+}
+
+func (d *blockDecoder) check(data []byte) (*Check, error) {
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Check
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			r, err := d.rule(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			c.Queries = append(c.Queries, *r)
+		case 2:
+			// Only "check if" (kind 0) is supported, other kinds such as "check all" are rejected.
+			if f.varint != 0 {
+				return nil, errors.WithStack(ErrUnsupported)
+			}
+		}
+	}
+	return &c, nil
+}
+
+func (d *blockDecoder) expression(data []byte) (Expression, error) {
+	fields, err := protoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var e Expression
+	for _, f := range fields {
+		if f.number != 1 {
+			continue
+		}
+
+		opFields, err := protoFields(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+		if len(opFields) != 1 {
+			return nil, errors.WithStack(ErrMalformed)
+		}
+
+		switch op := opFields[0]; op.number {
+		case 1:
+			t, err := d.term(op.bytes)
+			if err != nil {
+				return nil, err
+			}
+			e = append(e, Value(*t))
+		case 2, 3:
+			kind, err := protoFields(op.bytes)
+			if err != nil {
+				return nil, err
+			}
+			var k uint64
+			for _, kf := range kind {
+				if kf.number == 1 {
+					k = kf.varint
+				}
+			}
+			if op.number == 2 {
+				if k > uint64(UnaryLength) {
+					return nil, errors.WithStack(ErrUnsupported)
+				}
+				e = append(e, Unary(UnaryKind(k)))
+			} else {
+				if k > uint64(BinaryNotEqual) {
+					return nil, errors.WithStack(ErrUnsupported)
+				}
+				e = append(e, BinaryOp(BinaryKind(k)))
+			}
+		default:
+			return nil, errors.WithStack(ErrUnsupported)
+		}
+	}
+	return e, nil
+}
+
+func (b *Biscuit) encode() string {
+	var w protoWriter
+	for i, s := range b.signed {
+		var sw, kw protoWriter
+		sw.bytes(1, s.data)
+		kw.varint(1, algorithmEd25519)
+		kw.bytes(2, s.nextKey)
+		sw.bytes(2, kw.buf)
+		sw.bytes(3, s.signature)
+
+		number := 3
+		if i == 0 {
+			number = 2
+		}
+		w.bytes(number, sw.buf)
+	}
+
+	var pw protoWriter
+	pw.bytes(1, b.proof.nextSecret)
+	w.bytes(4, pw.buf)
+
+	return base64.URLEncoding.EncodeToString(w.buf)
+}
+
+func (b *Biscuit) encodeBlock(block *Block) ([]byte, error) {
+	e := &blockEncoder{table: b.table}
+
+	// The symbols are only known after the block was encoded, so the block is encoded in two passes.
+	var body protoWriter
+	if block.Context != "" {
+		body.bytes(2, []byte(block.Context))
+	}
+	body.varint(3, minSchemaVersion)
+	for _, f := range block.Facts {
+		for _, t := range f.Terms {
+			if t.Kind == TermVariable {
+				return nil, errors.New("biscuit: facts must not contain variables")
+			}
+		}
+		var fw protoWriter
+		fw.bytes(1, e.predicate(f))
+		body.bytes(4, fw.buf)
+	}
+	for _, r := range block.Rules {
+		body.bytes(5, e.rule(r))
+	}
+	for _, c := range block.Checks {
+		var cw protoWriter
+		for _, q := range c.Queries {
+			cw.bytes(1, e.rule(q))
+		}
+		body.bytes(6, cw.buf)
+	}
+
+	var w protoWriter
+	for _, s := range e.table[len(b.table):] {
+		w.bytes(1, []byte(s))
+	}
+	b.table = e.table
+
+	return append(w.buf, body.buf...), nil
+}
+
+type blockEncoder struct {
+	table []string
+}
+
+func (e *blockEncoder) symbol(s string) uint64 {
+	for i, d := range defaultSymbols {
+		if d == s {
+			return uint64(i)
+		}
+	}
+	for i, t := range e.table {
+		if t == s {
+			return uint64(symbolOffset + i)
+		}
+	}
+	e.table = append(e.table, s)
+	return uint64(symbolOffset + len(e.table) - 1)
+}
+
+func (e *blockEncoder) predicate(p Predicate) []byte {
+	var w protoWriter
+	w.varint(1, e.symbol(p.Name))
+	for _, t := range p.Terms {
+		w.bytes(2, e.term(t))
+	}
+	return w.buf
+}
+
+func (e *blockEncoder) term(t Term) []byte {
+	var w protoWriter
+	switch t.Kind {
+	case TermVariable:
+		w.varint(1, e.symbol(t.Variable))
+	case TermInteger:
+		w.varint(2, uint64(t.Integer))
+	case TermString:
+		w.varint(3, e.symbol(t.Str))
+	case TermDate:
+		w.varint(4, t.Date)
+	case TermBytes:
+		w.bytes(5, t.Bytes)
+	case TermBool:
+		var v uint64
+		if t.Bool {
+			v = 1
+		}
+		w.varint(6, v)
+	case TermSet:
+		var sw protoWriter
+		for _, element := range t.Set {
+			sw.bytes(1, e.term(element))
+		}
+		w.bytes(7, sw.buf)
+	}
+	return w.buf
+}
+
+func (e *blockEncoder) rule(r Rule) []byte {
+	var w protoWriter
+	w.bytes(1, e.predicate(r.Head))
+	for _, p := range r.Body {
+		w.bytes(2, e.predicate(p))
+	}
+	for _, expression := range r.Expressions {
+		var ew protoWriter
+		for _, op := range expression {
+			var ow, kw protoWriter
+			switch op.Kind {
+			case OpValue:
+				ow.bytes(1, e.term(op.Value))
+			case OpUnary:
+				kw.varint(1, uint64(op.Unary))
+				ow.bytes(2, kw.buf)
+			case OpBinary:
+				kw.varint(1, uint64(op.Binary))
+				ow.bytes(3, kw.buf)
+			}
+			ew.bytes(1, ow.buf)
+		}
+		w.bytes(3, ew.buf)
+	}
+	return w.buf
+}
@@ -0,0 +1,189 @@
+package biscuit
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func pred(name string, terms ...Term) Predicate {
+	return Predicate{Name: name, Terms: terms}
+}
+
+func check(body []Predicate, expressions ...Expression) Check {
+	return Check{Queries: []Rule{{Head: pred("query"), Body: body, Expressions: expressions}}}
+}
+
+func TestBiscuit(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	roots := []ed25519.PublicKey{other, public}
+
+	now := time.Now()
+	ambient := func(method, path string) []Predicate {
+		return []Predicate{
+			pred("method", String(method)),
+			pred("path", String(path)),
+			pred("time", Date(now)),
+		}
+	}
+
+	authority := &Block{
+		Facts: []Predicate{
+			pred("user", String("alice")),
+			pred("right", String("/articles/"), String("read")),
+		},
+		Rules: []Rule{{
+			Head: pred("can", Variable("action")),
+			Body: []Predicate{pred("right", Variable("prefix"), Variable("action")), pred("path", Variable("path"))},
+			Expressions: []Expression{
+				{Value(Variable("path")), Value(Variable("prefix")), BinaryOp(BinaryPrefix)},
+			},
+		}},
+		Checks: []Check{
+			check([]Predicate{pred("method", String("GET")), pred("can", String("read"))}),
+		},
+	}
+
+	t.Run("case=authority", func(t *testing.T) {
+		token, err := Mint(private, authority)
+		require.NoError(t, err)
+
+		b, err := Parse(token, roots)
+		require.NoError(t, err)
+		assert.Equal(t, authority.Facts, b.AuthorityFacts())
+		assert.Len(t, b.RevocationIDs, 1)
+
+		require.NoError(t, b.Authorize(ambient("GET", "/articles/1")))
+		require.Error(t, b.Authorize(ambient("POST", "/articles/1")))
+		require.Error(t, b.Authorize(ambient("GET", "/users/1")))
+	})
+
+	t.Run("case=attenuation", func(t *testing.T) {
+		token, err := Mint(private, authority)
+		require.NoError(t, err)
+
+		attenuated, err := Attenuate(token, &Block{
+			Checks: []Check{
+				check([]Predicate{pred("path", Variable("p"))}, Expression{Value(Variable("p")), Value(String("/articles/public/")), BinaryOp(BinaryPrefix)}),
+				check([]Predicate{pred("time", Variable("t"))}, Expression{Value(Variable("t")), Value(Date(now.Add(time.Hour))), BinaryOp(BinaryLessThan)}),
+			},
+		})
+		require.NoError(t, err)
+
+		b, err := Parse(attenuated, roots)
+		require.NoError(t, err)
+		assert.Len(t, b.Blocks, 2)
+
+		require.NoError(t, b.Authorize(ambient("GET", "/articles/public/1")))
+		require.Error(t, b.Authorize(ambient("GET", "/articles/private/1")))
+
+		expired, err := Attenuate(token, &Block{
+			Checks: []Check{
+				check([]Predicate{pred("time", Variable("t"))}, Expression{Value(Variable("t")), Value(Date(now.Add(-time.Hour))), BinaryOp(BinaryLessThan)}),
+			},
+		})
+		require.NoError(t, err)
+		b, err = Parse(expired, roots)
+		require.NoError(t, err)
+		require.Error(t, b.Authorize(ambient("GET", "/articles/1")))
+	})
+
+	t.Run("case=attenuation blocks can not grant rights", func(t *testing.T) {
+		token, err := Mint(private, authority, &Block{
+			Facts: []Predicate{pred("right", String("/"), String("read"))},
+			Rules: []Rule{{Head: pred("can", String("read")), Body: []Predicate{pred("method", String("GET"))}}},
+		})
+		require.NoError(t, err)
+
+		b, err := Parse(token, roots)
+		require.NoError(t, err)
+		require.Error(t, b.Authorize(ambient("GET", "/users/1")))
+		require.NoError(t, b.Authorize(ambient("GET", "/articles/1")))
+	})
+
+	t.Run("case=invalid signatures", func(t *testing.T) {
+		token, err := Mint(private, authority, &Block{})
+		require.NoError(t, err)
+
+		_, err = Parse(token, []ed25519.PublicKey{other})
+		assert.Equal(t, ErrInvalidSignature, errors.Cause(err))
+
+		b, err := decode(token)
+		require.NoError(t, err)
+		b.signed[1].data = append([]byte{}, b.signed[0].data...)
+		_, err = Parse(b.encode(), roots)
+		assert.Equal(t, ErrInvalidSignature, errors.Cause(err))
+
+		b, err = decode(token)
+		require.NoError(t, err)
+		b.proof.nextSecret = make([]byte, ed25519.SeedSize)
+		_, err = Parse(b.encode(), roots)
+		assert.Equal(t, ErrInvalidSignature, errors.Cause(err))
+
+		// Removing attenuation blocks must invalidate the token.
+		b, err = decode(token)
+		require.NoError(t, err)
+		b.signed = b.signed[:1]
+		_, err = Parse(b.encode(), roots)
+		assert.Equal(t, ErrInvalidSignature, errors.Cause(err))
+	})
+
+	t.Run("case=malformed", func(t *testing.T) {
+		for _, token := range []string{
+			"",
+			"not base64!",
+			"eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28ifQ.",
+			base64.URLEncoding.EncodeToString([]byte{0x12, 0x05, 0x01}),
+		} {
+			_, err := Parse(token, roots)
+			assert.Error(t, err, token)
+		}
+	})
+
+	t.Run("case=unsupported check kind", func(t *testing.T) {
+		var d blockDecoder
+		var w protoWriter
+		w.varint(2, 1)
+		_, err := d.check(w.buf)
+		assert.Equal(t, ErrUnsupported, errors.Cause(err))
+	})
+}
+
+func TestExpressions(t *testing.T) {
+	for k, tc := range []struct {
+		e      Expression
+		expect bool
+		err    bool
+	}{
+		{e: Expression{Value(Integer(1)), Value(Integer(2)), BinaryOp(BinaryLessThan)}, expect: true},
+		{e: Expression{Value(Integer(3)), Value(Integer(2)), BinaryOp(BinaryMul), Value(Integer(6)), BinaryOp(BinaryEqual)}, expect: true},
+		{e: Expression{Value(String("abc")), Value(String("b")), BinaryOp(BinaryContains)}, expect: true},
+		{e: Expression{Value(String("abc")), Value(String("^a.c$")), BinaryOp(BinaryRegex)}, expect: true},
+		{e: Expression{Value(String("abc")), Value(String("c")), BinaryOp(BinarySuffix)}, expect: true},
+		{e: Expression{Value(Set(String("a"), String("b"))), Value(String("a")), BinaryOp(BinaryContains)}, expect: true},
+		{e: Expression{Value(Set(String("a"), String("b"))), Value(Set(String("c"))), BinaryOp(BinaryContains)}, expect: false},
+		{e: Expression{Value(Set(String("a"))), Value(Set(String("b"))), BinaryOp(BinaryUnion), Unary(UnaryLength), Value(Integer(2)), BinaryOp(BinaryEqual)}, expect: true},
+		{e: Expression{Value(Bool(true)), Value(Bool(false)), BinaryOp(BinaryAnd), Unary(UnaryNegate)}, expect: true},
+		{e: Expression{Value(Integer(1)), Value(String("1")), BinaryOp(BinaryEqual)}, err: true},
+		{e: Expression{Value(Integer(1)), Value(Integer(0)), BinaryOp(BinaryDiv)}, err: true},
+		{e: Expression{Value(Integer(1 << 62)), Value(Integer(1 << 62)), BinaryOp(BinaryAdd)}, err: true},
+		{e: Expression{Value(Variable("unbound"))}, err: true},
+		{e: Expression{Value(Integer(1))}, err: true},
+	} {
+		ok, err := tc.e.evaluate(map[string]Term{})
+		if tc.err {
+			assert.Error(t, err, "%d", k)
+			continue
+		}
+		require.NoError(t, err, "%d", k)
+		assert.Equal(t, tc.expect, ok, "%d", k)
+	}
+}
@@ -0,0 +1,607 @@
+package biscuit
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxFacts      = 1000
+	maxIterations = 100
+)
+
+type TermKind int
+
+const (
+	TermVariable TermKind = iota
+	TermInteger
+	TermString
+	TermDate
+	TermBytes
+	TermBool
+	TermSet
+)
+
+// Term is a value of a Datalog predicate or expression.
+type Term struct {
+	Kind     TermKind
+	Variable string
+	Integer  int64
+	Str      string
+	Date     uint64
+	Bytes    []byte
+	Bool     bool
+	Set      []Term
+}
+
+func Variable(name string) Term { return Term{Kind: TermVariable, Variable: name} }
+func Integer(i int64) Term      { return Term{Kind: TermInteger, Integer: i} }
+func String(s string) Term      { return Term{Kind: TermString, Str: s} }
+func Date(t time.Time) Term     { return Term{Kind: TermDate, Date: uint64(t.Unix())} }
+func Bytes(b []byte) Term       { return Term{Kind: TermBytes, Bytes: b} }
+func Bool(b bool) Term          { return Term{Kind: TermBool, Bool: b} }
+func Set(terms ...Term) Term    { return Term{Kind: TermSet, Set: terms} }
+
+func (t Term) equal(o Term) bool {
+	return t.Kind == o.Kind && t.key() == o.key()
+}
+
+// key returns a canonical representation of the term which is equal for equal terms.
+func (t Term) key() string {
+	if t.Kind != TermSet {
+		return t.format()
+	}
+
+	keys := make([]string, len(t.Set))
+	for i, e := range t.Set {
+		keys[i] = e.key()
+	}
+	sort.Strings(keys)
+	return "[" + strings.Join(unique(keys), ", ") + "]"
+}
+
+func (t Term) format() string {
+	switch t.Kind {
+	case TermVariable:
+		return "$" + t.Variable
+	case TermInteger:
+		return strconv.FormatInt(t.Integer, 10)
+	case TermString:
+		return strconv.Quote(t.Str)
+	case TermDate:
+		return time.Unix(int64(t.Date), 0).UTC().Format(time.RFC3339)
+	case TermBytes:
+		return fmt.Sprintf("hex:%x", t.Bytes)
+	case TermBool:
+		return strconv.FormatBool(t.Bool)
+	case TermSet:
+		return t.key()
+	}
+	return "?"
+}
+
+func (t Term) String() string {
+	return t.format()
+}
+
+// Predicate is a fact if none of its terms is a variable.
+type Predicate struct {
+	Name  string
+	Terms []Term
+}
+
+func (p Predicate) String() string {
+	terms := make([]string, len(p.Terms))
+	for i, t := range p.Terms {
+		terms[i] = t.String()
+	}
+	return p.Name + "(" + strings.Join(terms, ", ") + ")"
+}
+
+func (p Predicate) key() string {
+	terms := make([]string, len(p.Terms))
+	for i, t := range p.Terms {
+		terms[i] = t.key()
+	}
+	return p.Name + "(" + strings.Join(terms, ", ") + ")"
+}
+
+type OpKind int
+
+const (
+	OpValue OpKind = iota
+	OpUnary
+	OpBinary
+)
+
+type UnaryKind int
+
+const (
+	UnaryNegate UnaryKind = iota
+	UnaryParens
+	UnaryLength
+)
+
+type BinaryKind int
+
+const (
+	BinaryLessThan BinaryKind = iota
+	BinaryGreaterThan
+	BinaryLessOrEqual
+	BinaryGreaterOrEqual
+	BinaryEqual
+	BinaryContains
+	BinaryPrefix
+	BinarySuffix
+	BinaryRegex
+	BinaryAdd
+	BinarySub
+	BinaryMul
+	BinaryDiv
+	BinaryAnd
+	BinaryOr
+	BinaryIntersection
+	BinaryUnion
+	BinaryBitwiseAnd
+	BinaryBitwiseOr
+	BinaryBitwiseXor
+	BinaryNotEqual
+)
+
+// Op is an operation of an expression. Expressions are evaluated using a stack.
+type Op struct {
+	Kind   OpKind
+	Value  Term
+	Unary  UnaryKind
+	Binary BinaryKind
+}
+
+func Value(t Term) Op          { return Op{Kind: OpValue, Value: t} }
+func Unary(k UnaryKind) Op     { return Op{Kind: OpUnary, Unary: k} }
+func BinaryOp(k BinaryKind) Op { return Op{Kind: OpBinary, Binary: k} }
+
+// Expression is a list of operations in reverse polish notation which must evaluate to true.
+type Expression []Op
+
+// Rule derives the head from the facts matching the body for which all expressions are true.
+type Rule struct {
+	Head        Predicate
+	Body        []Predicate
+	Expressions []Expression
+}
+
+// Check succeeds if any of its queries matches at least once.
+type Check struct {
+	Queries []Rule
+}
+
+func (c Check) String() string {
+	queries := make([]string, len(c.Queries))
+	for i, q := range c.Queries {
+		var parts []string
+		for _, p := range q.Body {
+			parts = append(parts, p.String())
+		}
+		for range q.Expressions {
+			parts = append(parts, "<expression>")
+		}
+		queries[i] = strings.Join(parts, ", ")
+	}
+	return "check if " + strings.Join(queries, " or ")
+}
+
+// origin is the set of blocks a fact was derived from. The authorizer is represented by the highest bit.
+type origin uint64
+
+const (
+	originAuthorizer origin = 1 << 63
+	maxBlocks               = 63
+)
+
+func blockOrigin(block int) origin {
+	return 1 << uint(block)
+}
+
+// trusted returns the origins which rules and checks of the block may use. This is the default scope of Biscuit:
+// the authority block, the block itself, and the authorizer.
+func trusted(block int) origin {
+	return blockOrigin(0) | blockOrigin(block) | originAuthorizer
+}
+
+type fact struct {
+	predicate Predicate
+	origin    origin
+}
+
+type world struct {
+	facts []fact
+	known map[string]bool
+}
+
+func newWorld() *world {
+	return &world{known: map[string]bool{}}
+}
+
+func (w *world) add(p Predicate, o origin) (bool, error) {
+	key := fmt.Sprintf("%s@%d", p.key(), o)
+	if w.known[key] {
+		return false, nil
+	}
+	if len(w.facts) >= maxFacts {
+		return false, errors.Errorf("biscuit: more than %d facts were generated", maxFacts)
+	}
+
+	w.known[key] = true
+	w.facts = append(w.facts, fact{predicate: p, origin: o})
+	return true, nil
+}
+
+type scopedRule struct {
+	rule  Rule
+	block int
+}
+
+// run applies all rules until no new facts are generated.
+func (w *world) run(rules []scopedRule) error {
+	for i := 0; i < maxIterations; i++ {
+		var added bool
+		for _, r := range rules {
+			var derived []fact
+			if err := w.query(r.rule, trusted(r.block), func(b map[string]Term, o origin) bool {
+				derived = append(derived, fact{predicate: substitute(r.rule.Head, b), origin: o | blockOrigin(r.block)})
+				return true
+			}); err != nil {
+				return err
+			}
+
+			for _, f := range derived {
+				ok, err := w.add(f.predicate, f.origin)
+				if err != nil {
+					return err
+				}
+				added = added || ok
+			}
+		}
+
+		if !added {
+			return nil
+		}
+	}
+	return errors.Errorf("biscuit: rules did not converge within %d iterations", maxIterations)
+}
+
+// query calls fn for each combination of trusted facts matching the body of the rule for which all expressions
+// are true, until fn returns false.
+func (w *world) query(r Rule, trust origin, fn func(bindings map[string]Term, o origin) bool) error {
+	for _, t := range r.Head.Terms {
+		if t.Kind == TermVariable && !bound(r.Body, t.Variable) {
+			return errors.Errorf(`biscuit: variable "%s" of rule head is not bound by the body`, t.Variable)
+		}
+	}
+
+	var match func(body []Predicate, bindings map[string]Term, o origin) bool
+	match = func(body []Predicate, bindings map[string]Term, o origin) bool {
+		if len(body) == 0 {
+			for _, e := range r.Expressions {
+				if ok, err := e.evaluate(bindings); err != nil || !ok {
+					return true
+				}
+			}
+			return fn(bindings, o)
+		}
+
+		for _, f := range w.facts {
+			if f.origin&^trust != 0 {
+				continue
+			}
+
+			next, ok := unify(body[0], f.predicate, bindings)
+			if !ok {
+				continue
+			}
+
+			if !match(body[1:], next, o|f.origin) {
+				return false
+			}
+		}
+		return true
+	}
+
+	match(r.Body, map[string]Term{}, 0)
+	return nil
+}
+
+func bound(body []Predicate, variable string) bool {
+	for _, p := range body {
+		for _, t := range p.Terms {
+			if t.Kind == TermVariable && t.Variable == variable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unify(p, f Predicate, bindings map[string]Term) (map[string]Term, bool) {
+	if p.Name != f.Name || len(p.Terms) != len(f.Terms) {
+		return nil, false
+	}
+
+	next := make(map[string]Term, len(bindings)+len(p.Terms))
+	for k, v := range bindings {
+		next[k] = v
+	}
+
+	for i, t := range p.Terms {
+		if t.Kind != TermVariable {
+			if !t.equal(f.Terms[i]) {
+				return nil, false
+			}
+			continue
+		}
+
+		if v, ok := next[t.Variable]; ok {
+			if !v.equal(f.Terms[i]) {
+				return nil, false
+			}
+			continue
+		}
+		next[t.Variable] = f.Terms[i]
+	}
+
+	return next, true
+}
+
+func substitute(p Predicate, bindings map[string]Term) Predicate {
+	terms := make([]Term, len(p.Terms))
+	for i, t := range p.Terms {
+		if t.Kind == TermVariable {
+			t = bindings[t.Variable]
+		}
+		terms[i] = t
+	}
+	return Predicate{Name: p.Name, Terms: terms}
+}
+
+func (e Expression) evaluate(bindings map[string]Term) (bool, error) {
+	var stack []Term
+	pop := func() (Term, error) {
+		if len(stack) == 0 {
+			return Term{}, errors.New("biscuit: expression stack is empty")
+		}
+		t := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return t, nil
+	}
+
+	for _, op := range e {
+		switch op.Kind {
+		case OpValue:
+			t := op.Value
+			if t.Kind == TermVariable {
+				v, ok := bindings[t.Variable]
+				if !ok {
+					return false, errors.Errorf(`biscuit: variable "%s" is not bound`, t.Variable)
+				}
+				t = v
+			}
+			stack = append(stack, t)
+		case OpUnary:
+			v, err := pop()
+			if err != nil {
+				return false, err
+			}
+			r, err := evaluateUnary(op.Unary, v)
+			if err != nil {
+				return false, err
+			}
+			stack = append(stack, r)
+		case OpBinary:
+			right, err := pop()
+			if err != nil {
+				return false, err
+			}
+			left, err := pop()
+			if err != nil {
+				return false, err
+			}
+			r, err := evaluateBinary(op.Binary, left, right)
+			if err != nil {
+				return false, err
+			}
+			stack = append(stack, r)
+		default:
+			return false, errors.New("biscuit: unknown operation")
+		}
+	}
+
+	if len(stack) != 1 || stack[0].Kind != TermBool {
+		return false, errors.New("biscuit: expression must evaluate to a boolean")
+	}
+	return stack[0].Bool, nil
+}
+
+var errInvalidType = errors.New("biscuit: invalid operand type")
+
+func evaluateUnary(k UnaryKind, v Term) (Term, error) {
+	switch k {
+	case UnaryNegate:
+		if v.Kind == TermBool {
+			return Bool(!v.Bool), nil
+		}
+	case UnaryParens:
+		return v, nil
+	case UnaryLength:
+		switch v.Kind {
+		case TermString:
+			return Integer(int64(len(v.Str))), nil
+		case TermBytes:
+			return Integer(int64(len(v.Bytes))), nil
+		case TermSet:
+			return Integer(int64(len(unique(Set(v.Set...).keys())))), nil
+		}
+	}
+	return Term{}, errors.WithStack(errInvalidType)
+}
+
+func (t Term) keys() []string {
+	keys := make([]string, len(t.Set))
+	for i, e := range t.Set {
+		keys[i] = e.key()
+	}
+	return keys
+}
+
+func evaluateBinary(k BinaryKind, l, r Term) (Term, error) {
+	switch k {
+	case BinaryEqual, BinaryNotEqual:
+		if l.Kind != r.Kind {
+			return Term{}, errors.WithStack(errInvalidType)
+		}
+		return Bool(l.equal(r) == (k == BinaryEqual)), nil
+	case BinaryLessThan, BinaryGreaterThan, BinaryLessOrEqual, BinaryGreaterOrEqual:
+		var a, b int64
+		switch {
+		case l.Kind == TermInteger && r.Kind == TermInteger:
+			a, b = l.Integer, r.Integer
+		case l.Kind == TermDate && r.Kind == TermDate:
+			a, b = int64(l.Date), int64(r.Date)
+		default:
+			return Term{}, errors.WithStack(errInvalidType)
+		}
+		switch k {
+		case BinaryLessThan:
+			return Bool(a < b), nil
+		case BinaryGreaterThan:
+			return Bool(a > b), nil
+		case BinaryLessOrEqual:
+			return Bool(a <= b), nil
+		default:
+			return Bool(a >= b), nil
+		}
+	case BinaryContains:
+		switch {
+		case l.Kind == TermString && r.Kind == TermString:
+			return Bool(strings.Contains(l.Str, r.Str)), nil
+		case l.Kind == TermSet && r.Kind == TermSet:
+			return Bool(subset(r, l)), nil
+		case l.Kind == TermSet:
+			return Bool(subset(Set(r), l)), nil
+		}
+	case BinaryPrefix:
+		if l.Kind == TermString && r.Kind == TermString {
+			return Bool(strings.HasPrefix(l.Str, r.Str)), nil
+		}
+	case BinarySuffix:
+		if l.Kind == TermString && r.Kind == TermString {
+			return Bool(strings.HasSuffix(l.Str, r.Str)), nil
+		}
+	case BinaryRegex:
+		if l.Kind == TermString && r.Kind == TermString {
+			re, err := regexp.Compile(r.Str)
+			if err != nil {
+				return Term{}, errors.WithStack(err)
+			}
+			return Bool(re.MatchString(l.Str)), nil
+		}
+	case BinaryAdd:
+		if l.Kind == TermString && r.Kind == TermString {
+			return String(l.Str + r.Str), nil
+		}
+		return arithmetic(k, l, r)
+	case BinarySub, BinaryMul, BinaryDiv, BinaryBitwiseAnd, BinaryBitwiseOr, BinaryBitwiseXor:
+		return arithmetic(k, l, r)
+	case BinaryAnd, BinaryOr:
+		if l.Kind == TermBool && r.Kind == TermBool {
+			if k == BinaryAnd {
+				return Bool(l.Bool && r.Bool), nil
+			}
+			return Bool(l.Bool || r.Bool), nil
+		}
+	case BinaryIntersection, BinaryUnion:
+		if l.Kind == TermSet && r.Kind == TermSet {
+			var result []Term
+			if k == BinaryUnion {
+				result = append(result, l.Set...)
+				result = append(result, r.Set...)
+			} else {
+				for _, e := range l.Set {
+					if subset(Set(e), r) {
+						result = append(result, e)
+					}
+				}
+			}
+			return Set(result...), nil
+		}
+	}
+	return Term{}, errors.WithStack(errInvalidType)
+}
+
+func arithmetic(k BinaryKind, l, r Term) (Term, error) {
+	if l.Kind != TermInteger || r.Kind != TermInteger {
+		return Term{}, errors.WithStack(errInvalidType)
+	}
+
+	a, b := l.Integer, r.Integer
+	var result int64
+	switch k {
+	case BinaryAdd:
+		result = a + b
+		if (result > a) != (b > 0) {
+			return Term{}, errors.New("biscuit: integer overflow")
+		}
+	case BinarySub:
+		result = a - b
+		if (result < a) != (b > 0) {
+			return Term{}, errors.New("biscuit: integer overflow")
+		}
+	case BinaryMul:
+		result = a * b
+		if a != 0 && (result/a != b || (a == -1 && b == -1<<63)) {
+			return Term{}, errors.New("biscuit: integer overflow")
+		}
+	case BinaryDiv:
+		if b == 0 || (a == -1<<63 && b == -1) {
+			return Term{}, errors.New("biscuit: invalid division")
+		}
+		result = a / b
+	case BinaryBitwiseAnd:
+		result = a & b
+	case BinaryBitwiseOr:
+		result = a | b
+	case BinaryBitwiseXor:
+		result = a ^ b
+	}
+	return Integer(result), nil
+}
+
+// subset returns true if all elements of a are elements of b.
+func subset(a, b Term) bool {
+	keys := map[string]bool{}
+	for _, k := range b.keys() {
+		keys[k] = true
+	}
+	for _, k := range a.keys() {
+		if !keys[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func unique(sorted []string) []string {
+	sort.Strings(sorted)
+	var result []string
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
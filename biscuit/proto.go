@@ -0,0 +1,98 @@
+package biscuit
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements the subset of the protocol buffers wire format which is required to encode and decode
+// the Biscuit schema without generated code.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+type protoField struct {
+	number int
+	wire   int
+	varint uint64
+	bytes  []byte
+}
+
+// protoFields decodes all fields of a message. Fields using the fixed size wire types are skipped because the
+// Biscuit schema does not use them.
+func protoFields(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.WithStack(ErrMalformed)
+		}
+		data = data[n:]
+
+		f := protoField{number: int(tag >> 3), wire: int(tag & 7)}
+		if f.number == 0 {
+			return nil, errors.WithStack(ErrMalformed)
+		}
+
+		switch f.wire {
+		case wireVarint:
+			if f.varint, n = binary.Uvarint(data); n <= 0 {
+				return nil, errors.WithStack(ErrMalformed)
+			}
+			data = data[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return nil, errors.WithStack(ErrMalformed)
+			}
+			f.bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, errors.WithStack(ErrMalformed)
+			}
+			data = data[8:]
+			continue
+		case wireFixed32:
+			if len(data) < 4 {
+				return nil, errors.WithStack(ErrMalformed)
+			}
+			data = data[4:]
+			continue
+		default:
+			return nil, errors.WithStack(ErrMalformed)
+		}
+
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) tag(number, wire int) {
+	w.buf = appendUvarint(w.buf, uint64(number<<3|wire))
+}
+
+func (w *protoWriter) varint(number int, v uint64) {
+	w.tag(number, wireVarint)
+	w.buf = appendUvarint(w.buf, v)
+}
+
+func (w *protoWriter) bytes(number int, v []byte) {
+	w.tag(number, wireBytes)
+	w.buf = appendUvarint(w.buf, uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutUvarint(b[:], v)]...)
+}
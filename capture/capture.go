@@ -0,0 +1,87 @@
+package capture
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Capture describes a time-boxed capture of the requests matching an access rule.
+type Capture struct {
+	// RuleID is the ID of the access rule whose requests are captured.
+	RuleID string `json:"rule_id"`
+
+	// StartedAt is the point in time at which the capture was started.
+	StartedAt time.Time `json:"started_at"`
+
+	// ExpiresAt is the point in time at which the capture stops recording.
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// Size is the maximum number of snapshots kept, older snapshots are discarded first.
+	Size int `json:"size"`
+
+	// Snapshots are the recorded snapshots, oldest first.
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// Snapshot is a sanitized record of a single request, the access decision, and the response. Bodies are never
+// recorded and secrets are redacted by the recorder.
+type Snapshot struct {
+	Time     time.Time `json:"time"`
+	Request  Request   `json:"request"`
+	Decision Decision  `json:"decision"`
+	Response Response  `json:"response"`
+}
+
+// Request is the incoming request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+}
+
+// Decision is the access decision made for the request.
+type Decision struct {
+	Granted bool `json:"granted"`
+
+	// Subject is the authenticated subject, if any.
+	Subject string `json:"subject,omitempty"`
+
+	// Error is the reason the request was denied or failed, if any.
+	Error string `json:"error,omitempty"`
+
+	// UpstreamError is why the upstream could not be reached, if it could not: "connect", "timeout", "tls", or
+	// "unknown".
+	UpstreamError string `json:"upstream_error,omitempty"`
+
+	// Header contains the headers added or changed by the mutators.
+	Header http.Header `json:"header,omitempty"`
+}
+
+// Response is the response returned to the client.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+}
+
+// Recorder records snapshots of requests matching access rules for which a capture was started.
+type Recorder interface {
+	// Start starts capturing the requests of the rule for the given duration, replacing any existing capture of it.
+	Start(ctx context.Context, ruleID string, duration time.Duration, size int) Capture
+
+	// Stop stops capturing the requests of the rule and discards the recorded snapshots.
+	Stop(ctx context.Context, ruleID string)
+
+	// IsCapturing returns true if requests of the rule are currently captured.
+	IsCapturing(ctx context.Context, ruleID string) bool
+
+	// Record records the snapshot if requests of the rule are currently captured.
+	Record(ctx context.Context, ruleID string, s Snapshot)
+
+	// Get returns the capture of the rule, including the recorded snapshots, and false if no capture exists.
+	Get(ctx context.Context, ruleID string) (Capture, bool)
+}
+
+type Registry interface {
+	CaptureRecorder() Recorder
+}
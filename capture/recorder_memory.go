@@ -0,0 +1,105 @@
+package capture
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+var _ Recorder = new(RecorderMemory)
+
+// RecorderMemory keeps the snapshots of each capture in a ring buffer in memory. Secrets are redacted from
+// snapshots before they are recorded.
+type RecorderMemory struct {
+	sync.RWMutex
+
+	redactor *redaction.Redactor
+	captures map[string]*ring
+}
+
+// ring is a capture whose snapshots form a ring buffer, next is the index the next snapshot is written to.
+type ring struct {
+	capture Capture
+	next    int
+}
+
+func NewRecorderMemory(redactor *redaction.Redactor) *RecorderMemory {
+	return &RecorderMemory{redactor: redactor, captures: map[string]*ring{}}
+}
+
+func (m *RecorderMemory) Start(_ context.Context, ruleID string, duration time.Duration, size int) Capture {
+	now := time.Now().UTC()
+	c := Capture{
+		RuleID:    ruleID,
+		StartedAt: now,
+		ExpiresAt: now.Add(duration),
+		Size:      size,
+		Snapshots: make([]Snapshot, 0, size),
+	}
+
+	m.Lock()
+	m.captures[ruleID] = &ring{capture: c}
+	m.Unlock()
+
+	return Capture{RuleID: c.RuleID, StartedAt: c.StartedAt, ExpiresAt: c.ExpiresAt, Size: c.Size, Snapshots: []Snapshot{}}
+}
+
+func (m *RecorderMemory) Stop(_ context.Context, ruleID string) {
+	m.Lock()
+	delete(m.captures, ruleID)
+	m.Unlock()
+}
+
+func (m *RecorderMemory) IsCapturing(_ context.Context, ruleID string) bool {
+	m.RLock()
+	defer m.RUnlock()
+
+	r, ok := m.captures[ruleID]
+	return ok && time.Now().Before(r.capture.ExpiresAt)
+}
+
+func (m *RecorderMemory) Record(_ context.Context, ruleID string, s Snapshot) {
+	m.Lock()
+	defer m.Unlock()
+
+	r, ok := m.captures[ruleID]
+	if !ok || !s.Time.Before(r.capture.ExpiresAt) || r.capture.Size <= 0 {
+		return
+	}
+
+	s = m.redact(s)
+	if len(r.capture.Snapshots) < r.capture.Size {
+		r.capture.Snapshots = append(r.capture.Snapshots, s)
+	} else {
+		r.capture.Snapshots[r.next] = s
+	}
+	r.next = (r.next + 1) % r.capture.Size
+}
+
+func (m *RecorderMemory) Get(_ context.Context, ruleID string) (Capture, bool) {
+	m.RLock()
+	defer m.RUnlock()
+
+	r, ok := m.captures[ruleID]
+	if !ok {
+		return Capture{}, false
+	}
+
+	c := r.capture
+	c.Snapshots = make([]Snapshot, 0, len(r.capture.Snapshots))
+	c.Snapshots = append(c.Snapshots, r.capture.Snapshots[r.next:]...)
+	c.Snapshots = append(c.Snapshots, r.capture.Snapshots[:r.next]...)
+
+	return c, true
+}
+
+func (m *RecorderMemory) redact(s Snapshot) Snapshot {
+	s.Request.URL = m.redactor.String(s.Request.URL)
+	s.Request.Header = m.redactor.Header(s.Request.Header)
+	s.Decision.Error = m.redactor.String(s.Decision.Error)
+	s.Decision.Header = m.redactor.Header(s.Decision.Header)
+	s.Response.Header = m.redactor.Header(s.Response.Header)
+	return s
+}
@@ -0,0 +1,102 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+func TestRecorderMemory(t *testing.T) {
+	ctx := context.Background()
+	redactor, err := redaction.NewRedactor(nil, nil)
+	require.NoError(t, err)
+	m := NewRecorderMemory(redactor)
+
+	record := func(id string, n int) {
+		for i := 0; i < n; i++ {
+			m.Record(ctx, id, Snapshot{Time: time.Now(), Request: Request{URL: fmt.Sprintf("/%d", i)}})
+		}
+	}
+
+	urls := func(c Capture) (urls []string) {
+		for _, s := range c.Snapshots {
+			urls = append(urls, s.Request.URL)
+		}
+		return
+	}
+
+	t.Run("case=not capturing", func(t *testing.T) {
+		assert.False(t, m.IsCapturing(ctx, "rule-1"))
+		record("rule-1", 1)
+		_, ok := m.Get(ctx, "rule-1")
+		assert.False(t, ok)
+	})
+
+	t.Run("case=records until full", func(t *testing.T) {
+		m.Start(ctx, "rule-1", time.Minute, 3)
+		assert.True(t, m.IsCapturing(ctx, "rule-1"))
+
+		record("rule-1", 2)
+		c, ok := m.Get(ctx, "rule-1")
+		require.True(t, ok)
+		assert.Equal(t, []string{"/0", "/1"}, urls(c))
+	})
+
+	t.Run("case=discards oldest snapshots", func(t *testing.T) {
+		m.Start(ctx, "rule-1", time.Minute, 3)
+		record("rule-1", 5)
+		c, ok := m.Get(ctx, "rule-1")
+		require.True(t, ok)
+		assert.Equal(t, []string{"/2", "/3", "/4"}, urls(c))
+	})
+
+	t.Run("case=expired", func(t *testing.T) {
+		m.Start(ctx, "rule-2", -time.Second, 3)
+		assert.False(t, m.IsCapturing(ctx, "rule-2"))
+		record("rule-2", 1)
+		c, ok := m.Get(ctx, "rule-2")
+		require.True(t, ok)
+		assert.Empty(t, c.Snapshots)
+	})
+
+	t.Run("case=stop", func(t *testing.T) {
+		m.Stop(ctx, "rule-1")
+		assert.False(t, m.IsCapturing(ctx, "rule-1"))
+		_, ok := m.Get(ctx, "rule-1")
+		assert.False(t, ok)
+	})
+}
+
+func TestRecorderMemoryRedaction(t *testing.T) {
+	ctx := context.Background()
+	redactor, err := redaction.NewRedactor([]string{"X-Session"}, nil)
+	require.NoError(t, err)
+	m := NewRecorderMemory(redactor)
+
+	m.Start(ctx, "rule-1", time.Minute, 1)
+	m.Record(ctx, "rule-1", Snapshot{
+		Time: time.Now(),
+		Request: Request{
+			URL:    "/api?access_token=secret",
+			Header: http.Header{"Authorization": {"bearer secret"}, "Accept": {"application/json"}},
+		},
+		Decision: Decision{Header: http.Header{"X-Session": {"secret"}}},
+		Response: Response{Header: http.Header{"Set-Cookie": {"session=secret"}}},
+	})
+
+	c, ok := m.Get(ctx, "rule-1")
+	require.True(t, ok)
+	require.Len(t, c.Snapshots, 1)
+	s := c.Snapshots[0]
+	assert.Equal(t, "/api?access_token="+redaction.RedactedValue, s.Request.URL)
+	assert.Equal(t, http.Header{"Authorization": {redaction.RedactedValue}, "Accept": {"application/json"}}, s.Request.Header)
+	assert.Equal(t, redaction.RedactedValue, s.Decision.Header.Get("X-Session"))
+	assert.Equal(t, redaction.RedactedValue, s.Response.Header.Get("Set-Cookie"))
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/viperx"
+
+	"github.com/ory/oathkeeper/bench"
+	"github.com/ory/oathkeeper/driver"
+	"github.com/ory/oathkeeper/x"
+)
+
+// benchCmd represents the bench command
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure the throughput and latency of access control decisions",
+	Long: `Replays a workload against the decisions API and reports the decisions per second, the latency of the
+decisions and, if run in-process, the latency of each stage of the pipeline and the allocations per decision.
+
+The workload is either a single synthetic request given by --url, --method and --header, or a file given by
+--workload containing a JSON array of requests ({"method": "GET", "url": "https://...", "header": {...}}) or a
+capture as returned by "GET /captures/{id}" of the management API. Captured credentials are redacted, use --header
+to replace them.
+
+If --endpoint is set, requests are sent to the decisions API of that instance. Otherwise the access rules and the
+pipeline are loaded in-process from the configuration file given by --config.
+
+Usage example:
+
+	oathkeeper bench --config config.yml --url https://api.example.com/users -H "Authorization: Bearer token"
+	oathkeeper bench --endpoint http://localhost:4456/ --workload capture.json --requests 100000 --concurrency 50
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Header values may contain commas, so they are not read as a string slice.
+		raw, err := cmd.Flags().GetStringArray("header")
+		cmdx.Must(err, "Unable to read --header: %s", err)
+		headers, err := bench.ParseHeaders(raw)
+		cmdx.Must(err, "Invalid --header: %s", err)
+
+		var workload []bench.Request
+		if path := flagx.MustGetString(cmd, "workload"); path != "" {
+			workload, err = bench.LoadWorkload(path)
+			cmdx.Must(err, "Unable to load the workload: %s", err)
+			workload = bench.WithHeader(workload, headers)
+		} else if u := flagx.MustGetString(cmd, "url"); u != "" {
+			workload, err = bench.Synthetic(flagx.MustGetString(cmd, "method"), u, headers)
+			cmdx.Must(err, "Invalid --url: %s", err)
+		} else {
+			cmdx.Fatalf("Please specify the workload using the --workload or the --url flag, for more information use `oathkeeper help bench`")
+		}
+
+		concurrency := flagx.MustGetInt(cmd, "concurrency")
+
+		var target bench.Target
+		if endpoint := flagx.MustGetString(cmd, "endpoint"); endpoint != "" {
+			_, err := url.ParseRequestURI(endpoint)
+			cmdx.Must(err, `Unable to parse endpoint URL "%s": %s`, endpoint, err)
+			target = bench.NewRemote(endpoint, concurrency)
+		} else {
+			logger = viperx.InitializeConfig("oathkeeper", "", logger)
+			// Denied requests are logged as warnings which would dominate the benchmark.
+			if l, ok := logger.(*logrus.Logger); ok {
+				l.SetLevel(logrus.ErrorLevel)
+			}
+
+			d := driver.NewDefaultDriver(logger, x.Version, x.Commit, x.Date, true)
+			d.Registry().Init()
+
+			deadline := time.Now().Add(time.Second * 10)
+			for {
+				count, err := d.Registry().RuleRepository().Count(context.Background())
+				cmdx.Must(err, "Unable to count the access rules: %s", err)
+				if count > 0 {
+					break
+				} else if time.Now().After(deadline) {
+					cmdx.Fatalf("No access rules were loaded, please check the access rule repositories of the configuration.")
+				}
+				time.Sleep(time.Millisecond * 100)
+			}
+
+			target = bench.NewInProcess(d.Registry().DecisionHandler())
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		go func() {
+			<-interrupt
+			cancel()
+		}()
+
+		report, err := bench.Run(ctx, target, workload, bench.Options{
+			Requests:    flagx.MustGetInt(cmd, "requests"),
+			Concurrency: concurrency,
+		})
+		cmdx.Must(err, "Unable to run the benchmark: %s", err)
+
+		if flagx.MustGetBool(cmd, "json") {
+			out, err := json.MarshalIndent(report, "", "  ")
+			cmdx.Must(err, "Unable to encode the report: %s", err)
+			fmt.Println(string(out))
+			return
+		}
+		cmdx.Must(report.Write(os.Stdout), "Unable to write the report")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(benchCmd)
+
+	benchCmd.Flags().StringP("endpoint", "e", "", "The endpoint URL of ORY Oathkeeper's management API. If empty, the pipeline runs in-process.")
+	benchCmd.Flags().StringP("workload", "w", "", "A file containing a JSON array of requests or a capture.")
+	benchCmd.Flags().String("url", "", "The URL of a synthetic request, used if --workload is not set.")
+	benchCmd.Flags().String("method", "GET", "The HTTP method of the synthetic request.")
+	benchCmd.Flags().StringArrayP("header", "H", []string{}, `A header ("Name: value") added to all requests, replacing headers of the same name.`)
+	benchCmd.Flags().Int("requests", 10000, "The number of requests sent, the workload is repeated as often as needed.")
+	benchCmd.Flags().Int("concurrency", 10, "The number of requests sent at the same time.")
+	benchCmd.Flags().Bool("json", false, "Print the report as JSON.")
+}
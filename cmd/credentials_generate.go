@@ -15,8 +15,10 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -24,6 +26,8 @@ import (
 	"github.com/ory/x/cmdx"
 	"github.com/ory/x/flagx"
 	"github.com/ory/x/jwksx"
+
+	"github.com/ory/oathkeeper/persistence/sqlite"
 )
 
 // credentialsGenerateCmd represents the generate command
@@ -34,7 +38,8 @@ var credentialsGenerateCmd = &cobra.Command{
 
 $ oathkeeper credentials generate --alg ES256 > jwks.json
 $ oathkeeper credentials generate --alg RS256 > jwks.json
-$ oathkeeper credentials generate --alg RS256 --bits 4096 > jwks.json`,
+$ oathkeeper credentials generate --alg RS256 --bits 4096 > jwks.json
+$ oathkeeper credentials generate --alg RS256 --persist "sqlite:///var/lib/oathkeeper/db.sqlite?set=id_token"`,
 	Run: func(cmd *cobra.Command, args []string) {
 		key, err := jwksx.GenerateSigningKeys(
 			flagx.MustGetString(cmd, "kid"),
@@ -43,6 +48,22 @@ $ oathkeeper credentials generate --alg RS256 --bits 4096 > jwks.json`,
 		)
 		cmdx.Must(err, "Unable to generate key: %s", err)
 
+		if persist := flagx.MustGetString(cmd, "persist"); persist != "" {
+			u, err := url.Parse(persist)
+			cmdx.Must(err, "Unable to parse database URL: %s", err)
+
+			p, err := sqlite.Open(u)
+			cmdx.Must(err, "Unable to open database: %s", err)
+			defer p.Close()
+
+			b, err := json.Marshal(key)
+			cmdx.Must(err, "Unable to encode key to JSON: %s", err)
+
+			err = p.SetJSONWebKeySet(context.Background(), sqlite.JSONWebKeySetID(u), b)
+			cmdx.Must(err, "Unable to store key: %s", err)
+			return
+		}
+
 		d := json.NewEncoder(os.Stdout)
 		d.SetIndent("", "  ")
 		err = d.Encode(key)
@@ -55,6 +76,7 @@ func init() {
 
 	credentialsGenerateCmd.Flags().String("alg", "", fmt.Sprintf("Generate a key to be used for one of the following algorithms: %v", jwksx.GenerateSigningKeysAvailableAlgorithms()))
 	credentialsGenerateCmd.Flags().String("kid", "", "The JSON Web Key ID (kid) to be used. A random value will be used if left empty.")
+	credentialsGenerateCmd.Flags().String("persist", "", "Store the key in the given SQLite database (e.g. sqlite:///var/lib/oathkeeper/db.sqlite?set=id_token) instead of printing it.")
 	credentialsGenerateCmd.Flags().Int("bits", 0, "The key size in bits. If left empty will default to a secure value for the selected algorithm.")
 
 	cmdx.Must(credentialsGenerateCmd.MarkFlagRequired("alg"), "")
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/flagx"
+)
+
+// credentialsTokenCmd represents the token command
+var credentialsTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Fetch an OAuth 2.0 access token to test access rules with",
+	Long: `Fetches an OAuth 2.0 access token using the client credentials grant or, if --device-authorization-url
+is set, the device authorization grant. The token is printed as JSON. If --request is set, the token is instead
+sent as a bearer token in a request to the given URL, typically a route of a local ORY Oathkeeper proxy, and the
+response is printed. The command fails if the response has an error status code.
+
+Examples:
+
+$ oathkeeper credentials token --token-url https://idp.example.com/oauth2/token \
+    --client-id my-client --client-secret my-secret --scope read
+$ oathkeeper credentials token --token-url https://idp.example.com/oauth2/token \
+    --client-id my-client --client-secret my-secret --request http://127.0.0.1:4455/api/users
+$ oathkeeper credentials token --token-url https://idp.example.com/oauth2/token \
+    --device-authorization-url https://idp.example.com/oauth2/device/auth --client-id my-cli`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		tokenURL := flagx.MustGetString(cmd, "token-url")
+		clientID := flagx.MustGetString(cmd, "client-id")
+		clientSecret := flagx.MustGetString(cmd, "client-secret")
+		scope := flagx.MustGetStringSlice(cmd, "scope")
+
+		params := url.Values{}
+		for _, audience := range flagx.MustGetStringSlice(cmd, "audience") {
+			params.Add("audience", audience)
+		}
+
+		var token *credentialsToken
+		if deviceURL := flagx.MustGetString(cmd, "device-authorization-url"); deviceURL != "" {
+			t, err := deviceToken(ctx, cmd.ErrOrStderr(), deviceURL, tokenURL, clientID, clientSecret, scope, params)
+			if err != nil {
+				return errors.Wrap(err, "unable to fetch token using the device authorization grant")
+			}
+			token = t
+		} else {
+			t, err := (&clientcredentials.Config{
+				ClientID:       clientID,
+				ClientSecret:   clientSecret,
+				TokenURL:       tokenURL,
+				Scopes:         scope,
+				EndpointParams: params,
+			}).Token(ctx)
+			if err != nil {
+				return errors.Wrap(err, "unable to fetch token using the client credentials grant")
+			}
+			token = &credentialsToken{AccessToken: t.AccessToken, TokenType: t.TokenType, RefreshToken: t.RefreshToken}
+			if !t.Expiry.IsZero() {
+				token.ExpiresIn = int64(time.Until(t.Expiry).Seconds())
+			}
+		}
+
+		target := flagx.MustGetString(cmd, "request")
+		if target == "" {
+			fmt.Fprintln(cmd.OutOrStdout(), cmdx.FormatResponse(token))
+			return nil
+		}
+
+		req, err := http.NewRequest(flagx.MustGetString(cmd, "method"), target, nil)
+		if err != nil {
+			return errors.Wrap(err, "unable to create request")
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "unable to send request")
+		}
+		defer res.Body.Close()
+
+		dump, err := httputil.DumpResponse(res, true)
+		if err != nil {
+			return errors.Wrap(err, "unable to read response")
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(dump))
+
+		if res.StatusCode >= http.StatusBadRequest {
+			return errors.Errorf("the request failed with status code %d", res.StatusCode)
+		}
+		return nil
+	},
+}
+
+// credentialsPollingUnit is the unit of the polling interval of the device authorization grant.
+var credentialsPollingUnit = time.Second
+
+// credentialsToken is a token response of the OAuth 2.0 token endpoint.
+type credentialsToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// credentialsError is an error response of the OAuth 2.0 token and device authorization endpoints.
+type credentialsError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// deviceToken fetches a token using the device authorization grant (RFC 8628). The user is asked to authorize the
+// device on w, while the token endpoint is polled until the authorization is granted, denied or expired.
+func deviceToken(ctx context.Context, w io.Writer, deviceURL, tokenURL, clientID, clientSecret string, scope []string, params url.Values) (*credentialsToken, error) {
+	form := url.Values{"client_id": {clientID}}
+	if len(scope) > 0 {
+		form.Set("scope", strings.Join(scope, " "))
+	}
+	for k, v := range params {
+		form[k] = v
+	}
+
+	var authorization struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int64  `json:"expires_in"`
+		Interval                int64  `json:"interval"`
+	}
+	if e, err := postCredentialsForm(ctx, deviceURL, clientID, clientSecret, form, &authorization); err != nil {
+		return nil, err
+	} else if e.Error != "" {
+		return nil, errors.Errorf("%s: %s", e.Error, e.Description)
+	}
+
+	if authorization.VerificationURIComplete != "" {
+		fmt.Fprintf(w, "To authorize this device, open %s\n", authorization.VerificationURIComplete)
+	} else {
+		fmt.Fprintf(w, "To authorize this device, open %s and enter the code %s\n", authorization.VerificationURI, authorization.UserCode)
+	}
+
+	interval := 5 * credentialsPollingUnit
+	if authorization.Interval > 0 {
+		interval = time.Duration(authorization.Interval) * credentialsPollingUnit
+	}
+	expiresAt := time.Now().Add(time.Duration(authorization.ExpiresIn) * credentialsPollingUnit)
+
+	form = url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {authorization.DeviceCode},
+		"client_id":   {clientID},
+	}
+	for {
+		if authorization.ExpiresIn > 0 && time.Now().After(expiresAt) {
+			return nil, errors.New("the device code expired before the device was authorized")
+		}
+		time.Sleep(interval)
+
+		var token credentialsToken
+		e, err := postCredentialsForm(ctx, tokenURL, clientID, clientSecret, form, &token)
+		if err != nil {
+			return nil, err
+		}
+
+		switch e.Error {
+		case "":
+			return &token, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * credentialsPollingUnit
+		default:
+			return nil, errors.Errorf("%s: %s", e.Error, e.Description)
+		}
+	}
+}
+
+// postCredentialsForm posts the form to an OAuth 2.0 endpoint and decodes a successful response into v. OAuth 2.0
+// error responses are returned as credentialsError, so that the caller can react to them.
+func postCredentialsForm(ctx context.Context, endpoint, clientID, clientSecret string, form url.Values, v interface{}) (*credentialsError, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		return new(credentialsError), errors.WithStack(json.NewDecoder(res.Body).Decode(v))
+	}
+
+	var e credentialsError
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error == "" {
+		return nil, errors.Errorf("%s responded with status code %d", endpoint, res.StatusCode)
+	}
+	return &e, nil
+}
+
+func init() {
+	credentialsCmd.AddCommand(credentialsTokenCmd)
+
+	credentialsTokenCmd.Flags().String("token-url", "", "The OAuth 2.0 token endpoint.")
+	credentialsTokenCmd.Flags().String("client-id", "", "The OAuth 2.0 client ID.")
+	credentialsTokenCmd.Flags().String("client-secret", "", "The OAuth 2.0 client secret. May be empty for public clients using the device authorization grant.")
+	credentialsTokenCmd.Flags().StringSlice("scope", []string{}, "The OAuth 2.0 scope to request, may be repeated.")
+	credentialsTokenCmd.Flags().StringSlice("audience", []string{}, "The audience to request, may be repeated.")
+	credentialsTokenCmd.Flags().String("device-authorization-url", "", "Use the device authorization grant with this device authorization endpoint instead of the client credentials grant.")
+	credentialsTokenCmd.Flags().String("request", "", "Send a request with the token to this URL, e.g. a route of a local proxy, and print the response instead of the token.")
+	credentialsTokenCmd.Flags().String("method", http.MethodGet, "The HTTP method of the request sent with --request.")
+
+	cmdx.Must(credentialsTokenCmd.MarkFlagRequired("token-url"), "")
+	cmdx.Must(credentialsTokenCmd.MarkFlagRequired("client-id"), "")
+}
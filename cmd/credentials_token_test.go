@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialsToken(t *testing.T) {
+	credentialsPollingUnit = time.Millisecond
+	defer func() { credentialsPollingUnit = time.Second }()
+
+	var polls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/device":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      r.PostForm.Get("scope"),
+				"user_code":        "ABCD-EFGH",
+				"verification_uri": "https://idp.example.com/device",
+				"expires_in":       600,
+				"interval":         1,
+			})
+		case "/token":
+			if r.PostForm.Get("grant_type") == "client_credentials" {
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "client-token", "token_type": "bearer"})
+				return
+			}
+
+			// The device code is the requested scope and decides how the authorization proceeds.
+			n := atomic.AddInt32(&polls, 1)
+			switch code := r.PostForm.Get("device_code"); {
+			case code == "granted" && n > 2:
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "device-token", "token_type": "bearer"})
+			case code == "granted" && n == 2:
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "slow_down"})
+			case code == "granted":
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+			case code == "expired":
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "expired_token", "error_description": "The device code expired."})
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		case "/broken":
+			_, _ = w.Write([]byte("not json"))
+		case "/protected":
+			if r.Header.Get("Authorization") != "Bearer client-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("method=deviceToken", func(t *testing.T) {
+		device := func(code string) (*credentialsToken, error) {
+			atomic.StoreInt32(&polls, 0)
+			return deviceToken(context.Background(), ioutil.Discard, ts.URL+"/device", ts.URL+"/token", "cli", "", []string{code}, url.Values{})
+		}
+
+		t.Run("case=should poll until the authorization is granted", func(t *testing.T) {
+			token, err := device("granted")
+			require.NoError(t, err)
+			assert.Equal(t, "device-token", token.AccessToken)
+			assert.Equal(t, int32(3), atomic.LoadInt32(&polls))
+		})
+
+		t.Run("case=should fail if the device code expired", func(t *testing.T) {
+			_, err := device("expired")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "expired_token")
+		})
+
+		t.Run("case=should fail if the token endpoint fails", func(t *testing.T) {
+			_, err := device("broken")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "status code 500")
+		})
+	})
+
+	t.Run("method=postCredentialsForm", func(t *testing.T) {
+		var v map[string]interface{}
+
+		t.Run("case=should return OAuth 2.0 errors", func(t *testing.T) {
+			e, err := postCredentialsForm(context.Background(), ts.URL+"/token", "cli", "", url.Values{"device_code": {"expired"}}, &v)
+			require.NoError(t, err)
+			assert.Equal(t, "expired_token", e.Error)
+			assert.Equal(t, "The device code expired.", e.Description)
+		})
+
+		t.Run("case=should fail without an OAuth 2.0 error", func(t *testing.T) {
+			_, err := postCredentialsForm(context.Background(), ts.URL+"/unknown", "cli", "", url.Values{}, &v)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "status code 404")
+		})
+
+		t.Run("case=should fail if the response is malformed", func(t *testing.T) {
+			_, err := postCredentialsForm(context.Background(), ts.URL+"/broken", "cli", "", url.Values{}, &v)
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("method=command", func(t *testing.T) {
+		args := []string{"credentials", "token", "--token-url", ts.URL + "/token", "--client-id", "cli", "--client-secret", "secret"}
+
+		RootCmd.SetArgs(args)
+		require.NoError(t, RootCmd.Execute())
+
+		RootCmd.SetArgs(append(args, "--request", ts.URL+"/protected"))
+		require.NoError(t, RootCmd.Execute())
+
+		RootCmd.SetArgs(append(args, "--request", ts.URL+"/unknown"))
+		require.Error(t, RootCmd.Execute())
+	})
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/flagx"
+
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/monitor"
+)
+
+const monitorRefreshInterval = 250 * time.Millisecond
+
+// monitorCmd represents the monitor command
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Tail access control decisions in a live terminal view",
+	Long: `Connects to the event stream of ORY Oathkeeper's management API and shows the access control decisions
+of the proxy and the decisions API as they happen: the matching access rule, the subject, the outcome, and the
+latency, along with the p50 and p95 latency of the shown decisions.
+
+Decisions can be filtered using flags or, while the monitor is running, by pressing "/" and typing a filter
+expression such as "rule:users subject:alice outcome:denied /admin". Press "p" to pause, "c" to clear, and "q" to
+quit. If the output is not a terminal or --plain is set, one line is printed per decision instead.
+
+Usage example:
+
+	oathkeeper monitor --endpoint=http://localhost:4456/
+	oathkeeper monitor --endpoint=http://localhost:4456/ --outcome denied --plain
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		endpoint := flagx.MustGetString(cmd, "endpoint")
+		if endpoint == "" {
+			cmdx.Fatalf("Please specify the endpoint url using the --endpoint flag, for more information use `oathkeeper help monitor`")
+		}
+		_, err := url.ParseRequestURI(endpoint)
+		cmdx.Must(err, `Unable to parse endpoint URL "%s": %s`, endpoint, err)
+
+		filter := events.Filter{
+			RuleID:  flagx.MustGetString(cmd, "rule"),
+			Subject: flagx.MustGetString(cmd, "subject"),
+			Outcome: flagx.MustGetString(cmd, "outcome"),
+			Text:    flagx.MustGetString(cmd, "filter"),
+		}
+		cmdx.Must(filter.Validate(), "Invalid --outcome: %s", filter.Validate())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		go func() {
+			<-interrupt
+			cancel()
+		}()
+
+		client := &http.Client{}
+		if flagx.MustGetBool(cmd, "plain") || !terminal.IsTerminal(int(os.Stdout.Fd())) {
+			filter.Type = events.TypeDecision
+			monitor.Stream(ctx, client, endpoint, filter, func(e events.Event) {
+				if e.Type == events.TypeDropped {
+					fmt.Fprintf(os.Stderr, "Dropped %d events because the monitor did not keep up\n", e.Dropped)
+				} else if e.Decision != nil {
+					fmt.Println(monitor.FormatLine(e))
+				}
+			}, func(err error) {
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Unable to stream events, reconnecting: %s\n", err)
+				}
+			})
+			return
+		}
+
+		view := monitor.NewView(endpoint, filter, flagx.MustGetInt(cmd, "history"))
+		// The filter can be changed while the monitor is running, so all events are streamed.
+		go monitor.Stream(ctx, client, endpoint, events.Filter{}, view.Add, view.SetStatus)
+
+		if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+			state, err := terminal.MakeRaw(fd)
+			cmdx.Must(err, "Unable to read from the terminal: %s", err)
+			defer terminal.Restore(fd, state) // nolint: errcheck
+
+			go func() {
+				key := make([]byte, 1)
+				for {
+					if _, err := os.Stdin.Read(key); err != nil || view.HandleKey(key[0]) {
+						cancel()
+						return
+					}
+				}
+			}()
+		}
+
+		// Use the alternate screen and hide the cursor while the monitor is running.
+		fmt.Print("\x1b[?1049h\x1b[?25l")
+		defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+		ticker := time.NewTicker(monitorRefreshInterval)
+		defer ticker.Stop()
+		for {
+			width, height, err := terminal.GetSize(int(os.Stdout.Fd()))
+			if err != nil {
+				width, height = 80, 24
+			}
+			_ = view.Render(os.Stdout, width, height)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(monitorCmd)
+
+	monitorCmd.Flags().StringP("endpoint", "e", "", "The endpoint URL of ORY Oathkeeper's management API")
+	monitorCmd.Flags().String("rule", "", "Only show decisions of the access rule with this ID.")
+	monitorCmd.Flags().String("subject", "", "Only show decisions of this subject.")
+	monitorCmd.Flags().String("outcome", "", `Only show decisions with this outcome, "allowed" or "denied".`)
+	monitorCmd.Flags().String("filter", "", "Only show decisions whose rule ID, subject, method, URL, or error contains this text.")
+	monitorCmd.Flags().Bool("plain", false, "Print one line per decision instead of the live view.")
+	monitorCmd.Flags().Int("history", 1000, "The number of decisions kept for the live view and its statistics.")
+}
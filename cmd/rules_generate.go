@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/flagx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/rule"
+)
+
+// rulesGenerateCmd represents the generate command
+var rulesGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate access rules from an OpenAPI document",
+	Long: `Generates an access rule for each operation of an OpenAPI 3 or Swagger 2 document and prints
+them. Path templates are converted to matchers and security schemes are mapped to authenticators:
+
+- HTTP bearer with bearer format JWT and OpenID Connect: jwt
+- Other HTTP bearer and OAuth 2.0: oauth2_introspection
+- API keys in cookies: cookie_session
+
+Other security schemes must be mapped using --authenticator. Operations without security requirements
+use the anonymous authenticator, operations whose security schemes can not be mapped use the unauthorized
+authenticator. Review the generated access rules and add the configuration of the authenticators before
+importing them.
+
+Usage example:
+
+	oathkeeper rules generate --openapi spec.yaml --upstream http://my-backend-service > rules.yaml
+	oathkeeper rules generate --openapi spec.json --upstream http://my-backend-service \
+		--base-url https://api.example.com/v1 --authenticator api_key=oauth2_introspection --format json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		file := flagx.MustGetString(cmd, "openapi")
+		document, err := ioutil.ReadFile(file)
+		cmdx.Must(err, "Unable to read file %s: %s", file, err)
+
+		authenticators := map[string]string{}
+		for _, mapping := range flagx.MustGetStringSlice(cmd, "authenticator") {
+			parts := strings.SplitN(mapping, "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				cmdx.Fatalf(`Value "%s" of --authenticator must look like <security-scheme>=<authenticator>`, mapping)
+			}
+			authenticators[parts[0]] = parts[1]
+		}
+
+		rules, warnings, err := rule.GenerateFromOpenAPI(document, rule.OpenAPIOptions{
+			BaseURL:          flagx.MustGetString(cmd, "base-url"),
+			Upstream:         flagx.MustGetString(cmd, "upstream"),
+			MatchingStrategy: configuration.MatchingStrategy(flagx.MustGetString(cmd, "matching-strategy")),
+			IDPrefix:         flagx.MustGetString(cmd, "id-prefix"),
+			Authorizer:       flagx.MustGetString(cmd, "authorizer"),
+			Mutators:         flagx.MustGetStringSlice(cmd, "mutator"),
+			Authenticators:   authenticators,
+		})
+		cmdx.Must(err, "Unable to generate access rules: %s", err)
+
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+
+		out, err := json.MarshalIndent(rules, "", "  ")
+		cmdx.Must(err, "Unable to encode access rules: %s", err)
+
+		switch format := flagx.MustGetString(cmd, "format"); format {
+		case "json":
+		case "yaml":
+			out, err = yaml.JSONToYAML(out)
+			cmdx.Must(err, "Unable to encode access rules: %s", err)
+		default:
+			cmdx.Fatalf(`Value "%s" of --format must be "json" or "yaml"`, format)
+		}
+
+		fmt.Println(strings.TrimSpace(string(out)))
+	},
+}
+
+func init() {
+	rulesCmd.AddCommand(rulesGenerateCmd)
+
+	rulesGenerateCmd.Flags().String("openapi", "", "The OpenAPI 3 or Swagger 2 document in JSON or YAML format.")
+	rulesGenerateCmd.Flags().String("upstream", "", "The URL requests are forwarded to.")
+	rulesGenerateCmd.Flags().String("base-url", "", "The URL the API is served at. Defaults to the first server of the document.")
+	rulesGenerateCmd.Flags().String("matching-strategy", string(configuration.Regexp), `The matching strategy of the access rules, "regexp" or "glob".`)
+	rulesGenerateCmd.Flags().String("id-prefix", "", "A prefix for the IDs of the access rules.")
+	rulesGenerateCmd.Flags().String("authorizer", "allow", "The authorizer of the access rules.")
+	rulesGenerateCmd.Flags().StringSlice("mutator", []string{"noop"}, "The mutators of the access rules.")
+	rulesGenerateCmd.Flags().StringSlice("authenticator", []string{}, "Maps a security scheme to an authenticator, for example api_key=oauth2_introspection.")
+	rulesGenerateCmd.Flags().String("format", "yaml", `The output format, "json" or "yaml".`)
+
+	cmdx.Must(rulesGenerateCmd.MarkFlagRequired("openapi"), "")
+	cmdx.Must(rulesGenerateCmd.MarkFlagRequired("upstream"), "")
+}
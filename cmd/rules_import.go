@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/x/cmdx"
+
+	"github.com/ory/oathkeeper/persistence/sqlite"
+)
+
+// rulesImportCmd represents the import command
+var rulesImportCmd = &cobra.Command{
+	Use:   "import <sqlite-url> <file> [<file>...]",
+	Short: "Import access rules into a SQLite database",
+	Long: `Replaces all access rules stored in the given SQLite database with the access rules
+from the given JSON or YAML files. Requires a binary built with SQLite support.
+
+Usage example:
+
+	oathkeeper rules import sqlite:///var/lib/oathkeeper/db.sqlite rules.json more-rules.yaml
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdx.MinArgs(cmd, args, 2)
+
+		u, err := url.Parse(args[0])
+		cmdx.Must(err, "Unable to parse database URL: %s", err)
+
+		rules := map[string]json.RawMessage{}
+		for _, file := range args[1:] {
+			b, err := ioutil.ReadFile(file)
+			cmdx.Must(err, "Unable to read file %s: %s", file, err)
+
+			b, err = yaml.YAMLToJSON(b)
+			cmdx.Must(err, "Unable to decode file %s: %s", file, err)
+
+			var items []json.RawMessage
+			cmdx.Must(json.Unmarshal(b, &items), "Unable to decode file %s: expected an array of access rules", file)
+
+			for _, item := range items {
+				id := gjson.GetBytes(item, "id").String()
+				if id == "" {
+					cmdx.Fatalf("Access rule in file %s has no ID: %s", file, item)
+				} else if _, ok := rules[id]; ok {
+					cmdx.Fatalf("Access rule ID %s is used more than once", id)
+				}
+				rules[id] = item
+			}
+		}
+
+		p, err := sqlite.Open(u)
+		cmdx.Must(err, "Unable to open database: %s", err)
+		defer p.Close()
+
+		err = p.SetAccessRules(context.Background(), rules)
+		cmdx.Must(err, "Unable to store access rules: %s", err)
+
+		fmt.Printf("Imported %d access rules.\n", len(rules))
+	},
+}
+
+func init() {
+	rulesCmd.AddCommand(rulesImportCmd)
+}
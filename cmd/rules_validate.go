@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/viperx"
+
+	"github.com/ory/oathkeeper/driver"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+// rulesValidateCmd represents the validate command
+var rulesValidateCmd = &cobra.Command{
+	Use:   "validate <file> [<file>...]",
+	Short: "Validate access rules against the configuration",
+	Long: `Validates the access rules of the given JSON or YAML files with the handlers and their configuration
+from the configuration file and prints the errors and warnings of each access rule. Exits with a non-zero
+status if an access rule is invalid. Warnings, such as deprecated fields, suspicious regular expressions
+and authenticators which are never used, do not change the exit status.
+
+Usage example:
+
+	oathkeeper rules validate --config oathkeeper.yml rules.json more-rules.yaml
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdx.MinArgs(cmd, args, 1)
+
+		logger = viperx.InitializeConfig("oathkeeper", "", logger)
+		v := driver.NewDefaultDriver(logger, x.Version, x.Commit, x.Date, true).Registry().RuleValidator()
+
+		var invalid, warnings int
+		for _, file := range args {
+			b, err := ioutil.ReadFile(file)
+			cmdx.Must(err, "Unable to read file %s: %s", file, err)
+
+			b, err = yaml.YAMLToJSON(b)
+			cmdx.Must(err, "Unable to decode file %s: %s", file, err)
+
+			var rules []rule.Rule
+			err = json.Unmarshal(b, &rules)
+			cmdx.Must(err, "Unable to decode file %s: %s", file, err)
+
+			for k := range rules {
+				rl := &rules[k]
+				if err := v.Validate(rl); err != nil {
+					invalid++
+					fmt.Printf("%s: Error: Access rule \"%s\" is invalid: %s\n", file, rl.ID, validationReason(err))
+				}
+
+				for _, w := range v.Warnings(rl) {
+					warnings++
+					fmt.Printf("%s: Warning: %s\n", file, w)
+				}
+			}
+		}
+
+		if invalid > 0 {
+			cmdx.Fatalf("%d access rules are invalid, %d warnings.", invalid, warnings)
+		}
+		fmt.Printf("All access rules are valid, %d warnings.\n", warnings)
+	},
+}
+
+// validationReason returns the reason of a validation error, which herodot errors do not include in their message.
+func validationReason(err error) string {
+	if e, ok := errors.Cause(err).(*herodot.DefaultError); ok && e.ReasonField != "" {
+		return e.ReasonField
+	}
+	return err.Error()
+}
+
+func init() {
+	rulesCmd.AddCommand(rulesValidateCmd)
+}
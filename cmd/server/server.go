@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/http3"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -32,11 +34,34 @@ import (
 	"github.com/ory/oathkeeper/api"
 	"github.com/ory/oathkeeper/driver"
 	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/fips"
+	"github.com/ory/oathkeeper/proxy"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/tlsrevocation"
 	"github.com/ory/oathkeeper/x"
 )
 
 func runProxy(d driver.Driver, n *negroni.Negroni, logger *logrus.Logger) func() {
+	return serveProxy(d, n, logger, configuration.DefaultProxyListener, d.Configuration().ProxyServeAddress(), cert("proxy", logger), d.Configuration().ServeTLS("proxy"))
+}
+
+func runProxyListener(d driver.Driver, n *negroni.Negroni, logger *logrus.Logger, l configuration.ProxyListener) func() {
+	certs, err := tlsx.Certificate(l.TLS.Cert.Base64, l.TLS.Key.Base64, l.TLS.Cert.Path, l.TLS.Key.Path)
+	if err == nil {
+		logger.Infof("Setting up HTTPS for proxy listener %s", l.Name)
+	} else if errors.Cause(err) != tlsx.ErrNoCertificatesConfigured {
+		logger.WithError(err).Fatalf("Unable to load HTTPS TLS Certificate for proxy listener %s", l.Name)
+	} else {
+		logger.Infof("TLS has not been configured for proxy listener %s, skipping", l.Name)
+		certs = nil
+	}
+
+	return serveProxy(d, n, logger, l.Name, l.Address(), certs, l.TLS)
+}
+
+func serveProxy(d driver.Driver, n *negroni.Negroni, logger *logrus.Logger, listener, addr string, certs []tls.Certificate, t configuration.ListenerTLS) func() {
 	return func() {
+		health := proxy.NewHealthMiddleware(d.Configuration(), d.Registry().HealthHandler())
 		proxy := d.Registry().Proxy()
 
 		handler := &httputil.ReverseProxy{
@@ -44,22 +69,41 @@ func runProxy(d driver.Driver, n *negroni.Negroni, logger *logrus.Logger) func()
 			Transport: proxy,
 		}
 
-		n.Use(reqlog.NewMiddlewareFromLogger(logger, "oathkeeper-proxy").ExcludePaths(healthx.ReadyCheckPath, healthx.AliveCheckPath))
+		n.UseFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+			next(w, r.WithContext(rule.WithListener(r.Context(), listener)))
+		})
+		n.Use(reqlog.NewMiddlewareFromLogger(logger, "oathkeeper-proxy").ExcludePaths(healthx.ReadyCheckPath, healthx.AliveCheckPath, d.Configuration().ProxyHealthAlivePath(), d.Configuration().ProxyHealthReadyPath()))
+		n.UseFunc(health)
 		n.UseHandler(handler)
 
 		h := corsx.Initialize(n, logger, "serve.proxy")
-		certs := cert("proxy", logger)
 
-		addr := d.Configuration().ProxyServeAddress()
 		server := graceful.WithDefaults(&http.Server{
 			Addr:         addr,
 			Handler:      h,
-			TLSConfig:    &tls.Config{Certificates: certs},
+			TLSConfig:    tlsConfig(d, logger, listener, t, certs),
 			ReadTimeout:  d.Configuration().ProxyReadTimeout(),
 			WriteTimeout: d.Configuration().ProxyWriteTimeout(),
 			IdleTimeout:  d.Configuration().ProxyIdleTimeout(),
 		})
 
+		h3 := http3Server(d, logger, listener, server, certs)
+		if h3 != nil {
+			// Clients learn about HTTP/3 from the Alt-Svc header of responses sent over HTTP/1.1 and HTTP/2, and keep
+			// using those if QUIC is blocked on their network.
+			server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = h3.SetQuicHeaders(w.Header())
+				h.ServeHTTP(w, r)
+			})
+
+			go func() {
+				logger.Infof("Listening on https://%s using HTTP/3", addr)
+				if err := h3.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					logger.WithError(err).Errorf("HTTP/3 server of proxy listener %s stopped, clients fall back to HTTP/1.1 and HTTP/2", listener)
+				}
+			}()
+		}
+
 		if err := graceful.Graceful(func() error {
 			if certs != nil {
 				logger.Printf("Listening on https://%s", addr)
@@ -67,7 +111,12 @@ func runProxy(d driver.Driver, n *negroni.Negroni, logger *logrus.Logger) func()
 			}
 			logger.Infof("Listening on http://%s", addr)
 			return server.ListenAndServe()
-		}, server.Shutdown); err != nil {
+		}, func(ctx context.Context) error {
+			if h3 != nil {
+				_ = h3.Close()
+			}
+			return server.Shutdown(ctx)
+		}); err != nil {
 			logger.Fatalf("Unable to gracefully shutdown HTTP(s) server because %v", err)
 			return
 		}
@@ -75,12 +124,39 @@ func runProxy(d driver.Driver, n *negroni.Negroni, logger *logrus.Logger) func()
 	}
 }
 
+// http3Server returns the experimental HTTP/3 server of a proxy listener, which serves the same handler as the
+// HTTP/1.1 and HTTP/2 server on the UDP port with the same number, or nil if HTTP/3 is disabled.
+func http3Server(d driver.Driver, logger logrus.FieldLogger, listener string, server *http.Server, certs []tls.Certificate) *http3.Server {
+	if !d.Configuration().ProxyHTTP3(listener).Enabled {
+		return nil
+	}
+	if certs == nil {
+		logger.Fatalf("HTTP/3 requires TLS to be configured for proxy listener %s", listener)
+	}
+
+	return &http3.Server{Server: &http.Server{
+		Addr:      server.Addr,
+		Handler:   server.Handler,
+		TLSConfig: server.TLSConfig,
+	}}
+}
+
 func runAPI(d driver.Driver, n *negroni.Negroni, logger *logrus.Logger) func() {
 	return func() {
 		router := x.NewAPIRouter()
 		d.Registry().RuleHandler().SetRoutes(router)
 		d.Registry().HealthHandler().SetRoutes(router.Router, true)
 		d.Registry().CredentialHandler().SetRoutes(router)
+		d.Registry().RevocationHandler().SetRoutes(router)
+		d.Registry().CacheHandler().SetRoutes(router)
+		d.Registry().CaptureHandler().SetRoutes(router)
+		d.Registry().ExplanationHandler().SetRoutes(router)
+		d.Registry().LockoutHandler().SetRoutes(router)
+		d.Registry().UIHandler().SetRoutes(router)
+		d.Registry().EventsHandler().SetRoutes(router)
+		d.Registry().CapabilitiesHandler().SetRoutes(router)
+		d.Registry().HealthStatusHandler().SetRoutes(router)
+		d.Registry().SchemaHandler().SetRoutes(router)
 
 		n.Use(reqlog.NewMiddlewareFromLogger(logger, "oathkeeper-api").ExcludePaths(healthx.ReadyCheckPath, healthx.AliveCheckPath))
 		n.Use(d.Registry().DecisionHandler()) // This needs to be the last entry, otherwise the judge API won't work
@@ -93,7 +169,7 @@ func runAPI(d driver.Driver, n *negroni.Negroni, logger *logrus.Logger) func() {
 		server := graceful.WithDefaults(&http.Server{
 			Addr:      addr,
 			Handler:   h,
-			TLSConfig: &tls.Config{Certificates: certs},
+			TLSConfig: tlsConfig(d, logger, "api", d.Configuration().ServeTLS("api"), certs),
 		})
 
 		if err := graceful.Graceful(func() error {
@@ -111,6 +187,33 @@ func runAPI(d driver.Driver, n *negroni.Negroni, logger *logrus.Logger) func() {
 	}
 }
 
+// tlsConfig returns the TLS configuration of a listener, restricted to the FIPS policy if it is enforced.
+func tlsConfig(d driver.Driver, logger logrus.FieldLogger, listener string, t configuration.ListenerTLS, certs []tls.Certificate) *tls.Config {
+	c, err := t.Config(certs)
+	if err != nil {
+		logger.WithError(err).Fatalf("Unable to configure TLS for listener %s", listener)
+	}
+
+	if rv := t.ClientAuth.Revocation; rv.Enabled {
+		checker := tlsrevocation.NewChecker(logger, rv.PolicyOrDefault(), rv.Uses("ocsp"), rv.Uses("crl"), rv.TTL())
+		c.VerifyPeerCertificate = checker.VerifyPeerCertificate
+	}
+
+	if t.OCSPStapling && len(certs) > 0 {
+		stapler, err := tlsrevocation.NewStapler(tlsrevocation.NewChecker(logger, tlsrevocation.PolicySoftFail, true, false, time.Hour), certs)
+		if err != nil {
+			logger.WithError(err).Fatalf("Unable to staple OCSP responses for listener %s", listener)
+		}
+		// All handshakes must use the stapler, which is only the case if no certificates are set.
+		c.Certificates = nil
+		c.GetCertificate = stapler.GetCertificate
+	}
+	if d.Configuration().FIPSIsEnabled() {
+		fips.ConfigureTLS(c)
+	}
+	return c
+}
+
 func cert(daemon string, logger logrus.FieldLogger) []tls.Certificate {
 	cert, err := tlsx.Certificate(
 		viper.GetString("serve."+daemon+".tls.cert.base64"),
@@ -156,8 +259,32 @@ func RunServe(version, build, date string) func(cmd *cobra.Command, args []strin
 
 		logger := logrusx.New()
 		d := driver.NewDefaultDriver(logger, version, build, date, true)
+		if err := rule.ValidateSignatureSchemes(d.Configuration()); err != nil {
+			logger.WithError(err).Fatal("The access rule repositories can not be verified.")
+		}
 		d.Registry().Init()
 
+		if d.Configuration().FIPSIsEnabled() {
+			if err := driver.ValidateFIPS(context.Background(), d.Configuration(), d.Registry()); err != nil {
+				logger.WithError(err).Fatal("The configuration violates the FIPS policy.")
+			}
+
+			// Outgoing requests, e.g. to upstreams and to fetch JSON Web Key Sets, are restricted as well.
+			if t, ok := http.DefaultTransport.(*http.Transport); ok {
+				if t.TLSClientConfig == nil {
+					t.TLSClientConfig = new(tls.Config)
+				}
+				fips.ConfigureTLS(t.TLSClientConfig)
+			}
+			logger.Info("FIPS policy is enforced.")
+		}
+
+		// Outgoing requests, e.g. to upstreams, introspection endpoints and to fetch JSON Web Key Sets, are sent to
+		// the addresses of the host overrides.
+		if t, ok := http.DefaultTransport.(*http.Transport); ok {
+			t.DialContext = x.OverrideHostsDialer(d.Configuration().HostOverrides, t.DialContext)
+		}
+
 		adminmw := negroni.New()
 		publicmw := negroni.New()
 
@@ -192,7 +319,8 @@ func RunServe(version, build, date string) func(cmd *cobra.Command, args []strin
 		adminmw.Use(metrics)
 		publicmw.Use(metrics)
 
-		if tracer := d.Registry().Tracer(); tracer.IsLoaded() {
+		tracer := d.Registry().Tracer()
+		if tracer.IsLoaded() {
 			adminmw.Use(tracer)
 			publicmw.Use(tracer)
 		}
@@ -202,6 +330,15 @@ func RunServe(version, build, date string) func(cmd *cobra.Command, args []strin
 			runAPI(d, adminmw, logger),
 			runProxy(d, publicmw, logger),
 		}
+
+		for _, l := range d.Configuration().ProxyListeners() {
+			listenermw := negroni.New()
+			listenermw.Use(metrics)
+			if tracer.IsLoaded() {
+				listenermw.Use(tracer)
+			}
+			tasks = append(tasks, runProxyListener(d, listenermw, logger, l))
+		}
 		wg.Add(len(tasks))
 		for _, t := range tasks {
 			go func(t func()) {
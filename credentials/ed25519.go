@@ -0,0 +1,80 @@
+package credentials
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// ErrEdDSAVerification is returned if the signature of a JSON Web Token signed with EdDSA is invalid.
+var ErrEdDSAVerification = errors.New("credentials: EdDSA verification failed")
+
+// SigningMethodEdDSA signs and verifies JSON Web Tokens with Ed25519 keys as specified in RFC 8037. It is registered
+// as algorithm "EdDSA" which github.com/dgrijalva/jwt-go does not support on its own.
+var SigningMethodEdDSA = new(signingMethodEdDSA)
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+type signingMethodEdDSA struct{}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+// Verify expects key to be an ed25519.PublicKey.
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	k, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	} else if len(k) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKey
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(k, []byte(signingString), sig) {
+		return ErrEdDSAVerification
+	}
+	return nil
+}
+
+// Sign expects key to be an ed25519.PrivateKey.
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	k, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	} else if len(k) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKey
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(k, []byte(signingString))), nil
+}
+
+// Ed25519PublicKeys returns all Ed25519 public keys of the sets, restricted to the key with the given ID if it is
+// not empty. Private keys are converted to their public counterpart.
+func Ed25519PublicKeys(sets []jose.JSONWebKeySet, kid string) []ed25519.PublicKey {
+	var keys []ed25519.PublicKey
+	for _, set := range sets {
+		for _, k := range set.Keys {
+			if kid != "" && k.KeyID != kid {
+				continue
+			}
+
+			switch key := k.Key.(type) {
+			case ed25519.PublicKey:
+				keys = append(keys, key)
+			case ed25519.PrivateKey:
+				keys = append(keys, key.Public().(ed25519.PublicKey))
+			}
+		}
+	}
+	return keys
+}
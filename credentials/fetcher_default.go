@@ -21,6 +21,7 @@
 package credentials
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -28,16 +29,21 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 	"gopkg.in/square/go-jose.v2"
 
 	"github.com/ory/herodot"
 	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/persistence/sqlite"
+	"github.com/ory/oathkeeper/x"
 )
 
 type reasoner interface {
@@ -54,14 +60,18 @@ type FetcherDefault struct {
 	client      *http.Client
 	keys        map[string]jose.JSONWebKeySet
 	fetchedAt   map[string]time.Time
+	errs        map[string]error
 	l           logrus.FieldLogger
+	flights     singleflight.Group
+
+	tls func(host string) *x.TLSClientConfig
 }
 
 // NewFetcherDefault returns a new JWKS Fetcher with:
 //
-// - cancelAfter: If reached, the fetcher will stop waiting for responses and return an error.
-// - waitForResponse: While the fetcher might stop waiting for responses, we will give the server more time to respond
-//		and add the keys to the registry unless waitForResponse is reached in which case we'll terminate the request.
+//   - cancelAfter: If reached, the fetcher will stop waiting for responses and return an error.
+//   - waitForResponse: While the fetcher might stop waiting for responses, we will give the server more time to respond
+//     and add the keys to the registry unless waitForResponse is reached in which case we'll terminate the request.
 func NewFetcherDefault(l logrus.FieldLogger, cancelAfter time.Duration, ttl time.Duration) *FetcherDefault {
 	return &FetcherDefault{
 		cancelAfter: cancelAfter,
@@ -69,10 +79,35 @@ func NewFetcherDefault(l logrus.FieldLogger, cancelAfter time.Duration, ttl time
 		ttl:         ttl,
 		keys:        make(map[string]jose.JSONWebKeySet),
 		fetchedAt:   make(map[string]time.Time),
+		errs:        make(map[string]error),
 		client:      httpx.NewResilientClientLatencyToleranceHigh(nil),
 	}
 }
 
+// SetTLSConfigs sets the function returning the TLS settings used to fetch JSON Web Key Sets from a host
+// ("hostname:port"). The default settings apply if it returns nil.
+func (s *FetcherDefault) SetTLSConfigs(tls func(host string) *x.TLSClientConfig) {
+	s.tls = tls
+}
+
+// httpClient returns the client fetching the JSON Web Key Set of the location.
+func (s *FetcherDefault) httpClient(location url.URL) (*http.Client, error) {
+	if s.tls == nil {
+		return s.client, nil
+	}
+
+	c := s.tls(location.Host)
+	if c.IsZero() {
+		return s.client, nil
+	}
+
+	transport, err := x.TLSTransport(c)
+	if err != nil {
+		return nil, err
+	}
+	return httpx.NewResilientClientLatencyToleranceHigh(transport), nil
+}
+
 func (s *FetcherDefault) ResolveSets(ctx context.Context, locations []url.URL) ([]jose.JSONWebKeySet, error) {
 	if set := s.set(locations); set != nil {
 		return set, nil
@@ -192,13 +227,54 @@ func (s *FetcherDefault) resolveAll(done chan struct{}, errs chan error, locatio
 
 func (s *FetcherDefault) resolve(wg *sync.WaitGroup, errs chan error, location url.URL) {
 	defer wg.Done()
+
+	// Concurrent requests for the keys of the same location are collapsed into one fetch.
+	_, err, _ := s.flights.Do(location.String(), func() (interface{}, error) {
+		return nil, s.fetch(location)
+	})
+
+	s.Lock()
+	if err != nil {
+		s.errs[location.String()] = err
+	} else {
+		delete(s.errs, location.String())
+	}
+	s.Unlock()
+
+	if err != nil {
+		errs <- err
+	}
+}
+
+// HealthCheck returns an error if the last fetch of a JSON Web Key Set failed.
+func (s *FetcherDefault) HealthCheck() error {
+	s.RLock()
+	defer s.RUnlock()
+
+	locations := make([]string, 0, len(s.errs))
+	for location := range s.errs {
+		locations = append(locations, location)
+	}
+	if len(locations) == 0 {
+		return nil
+	}
+	sort.Strings(locations)
+
+	err := s.errs[locations[0]]
+	if r, ok := errors.Cause(err).(reasoner); ok && r.Reason() != "" {
+		return errors.New(r.Reason())
+	}
+	return err
+}
+
+func (s *FetcherDefault) fetch(location url.URL) error {
 	var reader io.Reader
 
 	switch location.Scheme {
 	case "file":
 		f, err := os.Open(strings.Replace(location.String(), "file://", "", 1))
 		if err != nil {
-			errs <- errors.WithStack(herodot.
+			return errors.WithStack(herodot.
 				ErrInternalServerError.
 				WithReasonf(
 					`Unable to fetch JSON Web Keys from location "%s" because "%s".`,
@@ -206,17 +282,42 @@ func (s *FetcherDefault) resolve(wg *sync.WaitGroup, errs chan error, location u
 					err,
 				),
 			)
-			return
 		}
 		defer f.Close()
 
 		reader = f
+	case sqlite.Scheme:
+		keys, err := s.resolveSQLite(location)
+		if err != nil {
+			return errors.WithStack(herodot.
+				ErrInternalServerError.
+				WithReasonf(
+					`Unable to fetch JSON Web Keys from location "%s" because "%s".`,
+					location.String(),
+					err,
+				),
+			)
+		}
+
+		reader = bytes.NewReader(keys)
 	case "https":
 		fallthrough
 	case "http":
-		res, err := s.client.Get(location.String())
+		client, err := s.httpClient(location)
+		if err != nil {
+			return errors.WithStack(herodot.
+				ErrInternalServerError.
+				WithReasonf(
+					`Unable to fetch JSON Web Keys from location "%s" because "%s".`,
+					location.String(),
+					err,
+				),
+			)
+		}
+
+		res, err := client.Get(location.String())
 		if err != nil {
-			errs <- errors.WithStack(herodot.
+			return errors.WithStack(herodot.
 				ErrInternalServerError.
 				WithReasonf(
 					`Unable to fetch JSON Web Keys from location "%s" because "%s".`,
@@ -224,12 +325,11 @@ func (s *FetcherDefault) resolve(wg *sync.WaitGroup, errs chan error, location u
 					err,
 				),
 			)
-			return
 		}
 		defer res.Body.Close()
 
 		if res.StatusCode < 200 || res.StatusCode >= 400 {
-			errs <- errors.WithStack(herodot.
+			return errors.WithStack(herodot.
 				ErrInternalServerError.
 				WithReasonf(
 					`Expected successful status code from location "%s", but received code "%d".`,
@@ -237,12 +337,11 @@ func (s *FetcherDefault) resolve(wg *sync.WaitGroup, errs chan error, location u
 					res.StatusCode,
 				),
 			)
-			return
 		}
 
 		reader = res.Body
 	default:
-		errs <- errors.WithStack(herodot.
+		return errors.WithStack(herodot.
 			ErrInternalServerError.
 			WithReasonf(
 				`Unable to fetch JSON Web Keys from location "%s" because URL scheme "%s" is not supported.`,
@@ -250,12 +349,11 @@ func (s *FetcherDefault) resolve(wg *sync.WaitGroup, errs chan error, location u
 				location.Scheme,
 			),
 		)
-		return
 	}
 
 	var set jose.JSONWebKeySet
 	if err := json.NewDecoder(reader).Decode(&set); err != nil {
-		errs <- errors.WithStack(herodot.
+		return errors.WithStack(herodot.
 			ErrInternalServerError.
 			WithReasonf(
 				`Unable to decode JSON Web Keys from location "%s" because "%s".`,
@@ -263,11 +361,21 @@ func (s *FetcherDefault) resolve(wg *sync.WaitGroup, errs chan error, location u
 				err,
 			),
 		)
-		return
 	}
 
 	s.Lock()
 	s.keys[location.String()] = set
 	s.fetchedAt[location.String()] = time.Now().UTC()
 	s.Unlock()
+	return nil
+}
+
+func (s *FetcherDefault) resolveSQLite(location url.URL) ([]byte, error) {
+	p, err := sqlite.Open(&location)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+
+	return p.JSONWebKeySet(context.Background(), sqlite.JSONWebKeySetID(&location))
 }
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -147,3 +149,31 @@ func TestFetcherDefault(t *testing.T) {
 		assert.True(t, check("8e884167-1300-4f58-8cc1-81af68f878a8"))
 	})
 }
+
+func TestFetcherDefaultCollapsesConcurrentFetches(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(time.Millisecond * 100)
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write(sets[0])
+	}))
+	defer server.Close()
+
+	s := NewFetcherDefault(logrus.New(), time.Second*5, time.Minute)
+	uris := []url.URL{*urlx.ParseOrPanic(server.URL)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := s.ResolveKey(context.Background(), uris, "c61308cc-faef-4b98-99c3-839f513ac296", "sig")
+			require.NoError(t, err)
+			assert.Equal(t, "c61308cc-faef-4b98-99c3-839f513ac296", key.KeyID)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
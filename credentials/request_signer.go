@@ -0,0 +1,134 @@
+package credentials
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	RequestSigningTypeHMAC = "hmac"
+	RequestSigningTypeJWT  = "jwt"
+
+	DefaultRequestSigningHeader = "X-Oathkeeper-Signature"
+	DefaultRequestSigningTTL    = time.Minute
+)
+
+// RequestSigningConfig configures how requests made by Oathkeeper to remote services (e.g. authorizers or
+// hydrators) are signed, allowing those services to verify that the call originates from Oathkeeper.
+type RequestSigningConfig struct {
+	// Type is either "hmac" or "jwt".
+	Type string `json:"type"`
+
+	// Header is the name of the header which carries the signature. Defaults to "X-Oathkeeper-Signature".
+	Header string `json:"header"`
+
+	// Algorithm is the hash function used by the "hmac" type and may be either "sha256" (default) or "sha512".
+	Algorithm string `json:"algorithm"`
+
+	// Secret is the shared secret used by the "hmac" type.
+	Secret string `json:"secret"`
+
+	// JWKSURL is the location of the JSON Web Key Set used by the "jwt" type.
+	JWKSURL string `json:"jwks_url"`
+
+	// Issuer sets the "iss" claim of tokens issued by the "jwt" type.
+	Issuer string `json:"issuer"`
+
+	// TTL sets how long tokens issued by the "jwt" type are valid. Defaults to one minute.
+	TTL string `json:"ttl"`
+}
+
+// SignRequest signs r, whose body must be equal to body, according to c. The signer is only used
+// for signatures of type "jwt".
+//
+// Signatures of type "hmac" are sent in the form of `t=<unix timestamp>,v1=<hex encoded hmac>` where the hmac
+// is computed over the timestamp, the request method, the request URL, and the hex encoded SHA-256
+// hash of the body, each separated by a new line.
+//
+// Signatures of type "jwt" are sent as a JSON Web Token which contains, besides the standard claims, the request method
+// (`htm`), the request URL (`htu`), and the hex encoded SHA-256 hash of the body (`bsh`).
+func SignRequest(ctx context.Context, s Signer, r *http.Request, body []byte, c *RequestSigningConfig) error {
+	header := c.Header
+	if header == "" {
+		header = DefaultRequestSigningHeader
+	}
+
+	bodyHash := sha256.Sum256(body)
+	now := time.Now().UTC()
+
+	switch c.Type {
+	case RequestSigningTypeHMAC:
+		var h func() hash.Hash
+		switch strings.ToLower(c.Algorithm) {
+		case "", "sha256":
+			h = sha256.New
+		case "sha512":
+			h = sha512.New
+		default:
+			return errors.Errorf(`credentials: request signing algorithm "%s" is not supported`, c.Algorithm)
+		}
+
+		if c.Secret == "" {
+			return errors.New("credentials: request signing of type hmac requires a secret")
+		}
+
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		mac := hmac.New(h, []byte(c.Secret))
+		_, _ = fmt.Fprintf(mac, "%s\n%s\n%s\n%s", timestamp, r.Method, r.URL.String(), hex.EncodeToString(bodyHash[:]))
+		r.Header.Set(header, fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil))))
+	case RequestSigningTypeJWT:
+		if s == nil {
+			return errors.New("credentials: request signing of type jwt requires a signer")
+		}
+
+		location, err := url.Parse(c.JWKSURL)
+		if err != nil || c.JWKSURL == "" {
+			return errors.Errorf(`credentials: request signing of type jwt requires a valid jwks_url but got "%s"`, c.JWKSURL)
+		}
+
+		ttl := DefaultRequestSigningTTL
+		if c.TTL != "" {
+			if ttl, err = time.ParseDuration(c.TTL); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		claims := jwt.MapClaims{
+			"aud": r.URL.String(),
+			"iat": now.Unix(),
+			"nbf": now.Unix(),
+			"exp": now.Add(ttl).Unix(),
+			"jti": uuid.New(),
+			"htm": r.Method,
+			"htu": r.URL.String(),
+			"bsh": hex.EncodeToString(bodyHash[:]),
+		}
+		if c.Issuer != "" {
+			claims["iss"] = c.Issuer
+		}
+
+		token, err := s.Sign(ctx, location, claims)
+		if err != nil {
+			return err
+		}
+		r.Header.Set(header, token)
+	default:
+		return errors.Errorf(`credentials: request signing type "%s" is not supported`, c.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package credentials
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/urlx"
+)
+
+func TestSignRequest(t *testing.T) {
+	body := []byte(`{"subject":"foo"}`)
+	bodyHash := sha256.Sum256(body)
+
+	newRequest := func(t *testing.T) *http.Request {
+		r, err := http.NewRequest("POST", "https://remote/path", nil)
+		require.NoError(t, err)
+		return r
+	}
+
+	t.Run("type=hmac", func(t *testing.T) {
+		r := newRequest(t)
+		require.NoError(t, SignRequest(context.Background(), nil, r, body, &RequestSigningConfig{Type: "hmac", Secret: "secret"}))
+
+		parts := strings.Split(r.Header.Get(DefaultRequestSigningHeader), ",")
+		require.Len(t, parts, 2)
+		timestamp := strings.TrimPrefix(parts[0], "t=")
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		_, _ = fmt.Fprintf(mac, "%s\nPOST\nhttps://remote/path\n%s", timestamp, hex.EncodeToString(bodyHash[:]))
+		assert.Equal(t, "v1="+hex.EncodeToString(mac.Sum(nil)), parts[1])
+	})
+
+	t.Run("type=hmac/header=custom", func(t *testing.T) {
+		r := newRequest(t)
+		require.NoError(t, SignRequest(context.Background(), nil, r, body, &RequestSigningConfig{Type: "hmac", Secret: "secret", Header: "X-Signature", Algorithm: "sha512"}))
+		assert.Empty(t, r.Header.Get(DefaultRequestSigningHeader))
+		assert.NotEmpty(t, r.Header.Get("X-Signature"))
+	})
+
+	t.Run("type=jwt", func(t *testing.T) {
+		r := newRequest(t)
+		signer := NewSignerDefault(newDefaultSignerMockRegistry())
+		require.NoError(t, SignRequest(context.Background(), signer, r, body, &RequestSigningConfig{
+			Type:    "jwt",
+			JWKSURL: "file://../test/stub/jwks-rsa-single.json",
+			Issuer:  "https://oathkeeper/",
+		}))
+
+		verifier := NewVerifierDefault(newDefaultSignerMockRegistry())
+		token, err := verifier.Verify(context.Background(), r.Header.Get(DefaultRequestSigningHeader), &ValidationContext{
+			Algorithms: []string{"RS256"},
+			KeyURLs:    []url.URL{*urlx.ParseOrPanic("file://../test/stub/jwks-rsa-single.json")},
+		})
+		require.NoError(t, err)
+
+		claims := token.Claims.(jwt.MapClaims)
+		assert.Equal(t, "POST", claims["htm"])
+		assert.Equal(t, "https://remote/path", claims["htu"])
+		assert.Equal(t, "https://oathkeeper/", claims["iss"])
+		assert.Equal(t, hex.EncodeToString(bodyHash[:]), claims["bsh"])
+	})
+
+	for k, tc := range []*RequestSigningConfig{
+		{Type: "unknown"},
+		{Type: "hmac"},
+		{Type: "hmac", Secret: "secret", Algorithm: "md5"},
+		{Type: "jwt"},
+		{Type: "jwt", JWKSURL: "file://../test/stub/jwks-rsa-single.json", TTL: "not-a-duration"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/invalid", k), func(t *testing.T) {
+			signer := NewSignerDefault(newDefaultSignerMockRegistry())
+			require.Error(t, SignRequest(context.Background(), signer, newRequest(t), body, tc))
+		})
+	}
+}
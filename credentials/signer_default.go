@@ -16,19 +16,32 @@ import (
 var _ Signer = new(DefaultSigner)
 
 type DefaultSigner struct {
-	r FetcherRegistry
+	r            FetcherRegistry
+	keyValidator func(key *jose.JSONWebKey) error
 }
 
 func NewSignerDefault(r FetcherRegistry) *DefaultSigner {
 	return &DefaultSigner{r: r}
 }
 
+// WithKeyValidator sets a function which must accept a signing key before it is used.
+func (s *DefaultSigner) WithKeyValidator(v func(key *jose.JSONWebKey) error) *DefaultSigner {
+	s.keyValidator = v
+	return s
+}
+
 func (s *DefaultSigner) Sign(ctx context.Context, location *url.URL, claims jwt.Claims) (string, error) {
 	key, id, err := s.key(ctx, location)
 	if err != nil {
 		return "", err
 	}
 
+	if s.keyValidator != nil {
+		if err := s.keyValidator(key); err != nil {
+			return "", err
+		}
+	}
+
 	method := jwt.GetSigningMethod(key.Algorithm)
 	if method == nil {
 		return "", errors.Errorf(`credentials: signing key "%s" declares unsupported algorithm "%s"`, key.KeyID, key.Algorithm)
@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
 
 	"github.com/ory/x/urlx"
 )
@@ -46,6 +47,14 @@ func TestSignerDefault(t *testing.T) {
 		})
 	}
 
+	t.Run("case=key validator", func(t *testing.T) {
+		signer := NewSignerDefault(newDefaultSignerMockRegistry()).WithKeyValidator(func(key *jose.JSONWebKey) error {
+			return errors.Errorf("rejected %s", key.Algorithm)
+		})
+
+		_, err := signer.Sign(context.Background(), urlx.ParseOrPanic("file://../test/stub/jwks-rsa-single.json"), jwt.MapClaims{"sub": "foo"})
+		require.EqualError(t, err, "rejected RS256")
+	})
 }
 
 func verify(t *testing.T, token string, f Fetcher, u string) (*jwt.Token, error) {
@@ -0,0 +1,23 @@
+package credentials
+
+import (
+	"gopkg.in/square/go-jose.v2"
+)
+
+// SymmetricKeys returns all symmetric keys (JSON Web Keys of type "oct") of the sets, restricted to the key with the
+// given ID if it is not empty.
+func SymmetricKeys(sets []jose.JSONWebKeySet, kid string) [][]byte {
+	var keys [][]byte
+	for _, set := range sets {
+		for _, k := range set.Keys {
+			if kid != "" && k.KeyID != kid {
+				continue
+			}
+
+			if key, ok := k.Key.([]byte); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
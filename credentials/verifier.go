@@ -3,6 +3,7 @@ package credentials
 import (
 	"context"
 	"net/url"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 
@@ -28,4 +29,7 @@ type ValidationContext struct {
 	ScopeStrategy fosite.ScopeStrategy
 	Scope         []string
 	KeyURLs       []url.URL
+
+	// Leeway is the clock skew tolerated when validating the "exp", "iat" and "nbf" claims.
+	Leeway time.Duration
 }
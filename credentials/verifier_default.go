@@ -5,9 +5,11 @@ import (
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"fmt"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
 
 	"github.com/ory/fosite"
 	"github.com/ory/herodot"
@@ -34,8 +36,9 @@ func (v *VerifierDefault) Verify(
 	token string,
 	r *ValidationContext,
 ) (*jwt.Token, error) {
-	// Parse the token.
-	t, err := jwt.ParseWithClaims(token, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+	// Parse the token. The time based claims are validated afterwards to tolerate the leeway.
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	t, err := parser.ParseWithClaims(token, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if !stringslice.Has(r.Algorithms, fmt.Sprintf("%s", token.Header["alg"])) {
 			return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason(fmt.Sprintf(`JSON Web Token used signing method "%s" which is not allowed.`, token.Header["alg"])))
 		}
@@ -73,12 +76,19 @@ func (v *VerifierDefault) Verify(
 			if k, ok := key.Key.([]byte); ok {
 				return k, nil
 			}
+		case *signingMethodEdDSA:
+			if k, ok := key.Key.(ed25519.PublicKey); ok {
+				return k, nil
+			}
 		default:
 			return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`This request object uses unsupported signing algorithm "%s".`, token.Header["alg"]))
 		}
 
 		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`The signing key algorithm does not match the algorithm from the token header.`))
 	})
+	if err == nil {
+		err = validateTimeClaims(t.Claims, r.Leeway)
+	}
 	if err != nil {
 		if e, ok := errors.Cause(err).(*jwt.ValidationError); ok {
 			if _, ok := errors.Cause(e.Inner).(*herodot.DefaultError); !ok {
@@ -129,6 +139,28 @@ func (v *VerifierDefault) Verify(
 	return t, nil
 }
 
+// validateTimeClaims validates the "exp", "iat" and "nbf" claims like jwt.MapClaims does, but tolerates clocks which
+// are off by up to leeway.
+func validateTimeClaims(claims jwt.Claims, leeway time.Duration) error {
+	c, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return claims.Valid()
+	}
+
+	now := time.Now().Unix()
+	skew := int64(leeway / time.Second)
+	if !c.VerifyExpiresAt(now-skew, false) {
+		return jwt.NewValidationError("Token is expired", jwt.ValidationErrorExpired)
+	}
+	if !c.VerifyIssuedAt(now+skew, false) {
+		return jwt.NewValidationError("Token used before issued", jwt.ValidationErrorIssuedAt)
+	}
+	if !c.VerifyNotBefore(now+skew, false) {
+		return jwt.NewValidationError("Token is not valid yet", jwt.ValidationErrorNotValidYet)
+	}
+	return nil
+}
+
 func scope(claims map[string]interface{}) ([]string, string) {
 	var ok bool
 	var interim interface{}
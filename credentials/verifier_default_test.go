@@ -293,6 +293,89 @@ func TestVerifierDefault(t *testing.T) {
 			}, "file://../test/stub/jwks-hs.json"),
 			expectErr: true,
 		},
+		{
+			d: "should pass when expired within the leeway",
+			c: &ValidationContext{
+				Algorithms: []string{"HS256"},
+				KeyURLs:    []url.URL{*urlx.ParseOrPanic("file://../test/stub/jwks-hs.json")},
+				Leeway:     time.Minute,
+			},
+			token: sign(jwt.MapClaims{
+				"sub": "sub",
+				"exp": now.Add(-30 * time.Second).Unix(),
+			}, "file://../test/stub/jwks-hs.json"),
+		},
+		{
+			d: "should fail when expired for longer than the leeway",
+			c: &ValidationContext{
+				Algorithms: []string{"HS256"},
+				KeyURLs:    []url.URL{*urlx.ParseOrPanic("file://../test/stub/jwks-hs.json")},
+				Leeway:     time.Minute,
+			},
+			token: sign(jwt.MapClaims{
+				"sub": "sub",
+				"exp": now.Add(-2 * time.Minute).Unix(),
+			}, "file://../test/stub/jwks-hs.json"),
+			expectErr: true,
+		},
+		{
+			d: "should pass when nbf and iat are in the future within the leeway",
+			c: &ValidationContext{
+				Algorithms: []string{"HS256"},
+				KeyURLs:    []url.URL{*urlx.ParseOrPanic("file://../test/stub/jwks-hs.json")},
+				Leeway:     time.Minute,
+			},
+			token: sign(jwt.MapClaims{
+				"sub": "sub",
+				"exp": now.Add(time.Hour).Unix(),
+				"nbf": now.Add(30 * time.Second).Unix(),
+				"iat": now.Add(30 * time.Second).Unix(),
+			}, "file://../test/stub/jwks-hs.json"),
+		},
+		{
+			d: "should pass with EdDSA",
+			c: &ValidationContext{
+				Algorithms: []string{"EdDSA"},
+				KeyURLs:    []url.URL{*urlx.ParseOrPanic("file://../test/stub/jwks-ed25519.json")},
+			},
+			token: sign(jwt.MapClaims{
+				"sub": "sub",
+				"exp": now.Add(time.Hour).Unix(),
+			}, "file://../test/stub/jwks-ed25519.json"),
+			expectClaims: jwt.MapClaims{
+				"sub": "sub",
+				"exp": float64(now.Add(time.Hour).Unix()),
+				"scp": []string{},
+			},
+		},
+		{
+			d: "should pass with ES512",
+			c: &ValidationContext{
+				Algorithms: []string{"ES512"},
+				KeyURLs:    []url.URL{*urlx.ParseOrPanic("file://../test/stub/jwks-ecdsa-p521.json")},
+			},
+			token: sign(jwt.MapClaims{
+				"sub": "sub",
+				"exp": now.Add(time.Hour).Unix(),
+			}, "file://../test/stub/jwks-ecdsa-p521.json"),
+			expectClaims: jwt.MapClaims{
+				"sub": "sub",
+				"exp": float64(now.Add(time.Hour).Unix()),
+				"scp": []string{},
+			},
+		},
+		{
+			d: "should fail when an EdDSA signature does not match",
+			c: &ValidationContext{
+				Algorithms: []string{"EdDSA"},
+				KeyURLs:    []url.URL{*urlx.ParseOrPanic("file://../test/stub/jwks-ed25519.json")},
+			},
+			token: sign(jwt.MapClaims{
+				"sub": "sub",
+				"exp": now.Add(time.Hour).Unix(),
+			}, "file://../test/stub/jwks-ed25519.json") + "A",
+			expectErr: true,
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
 			claims, err := verifier.Verify(context.Background(), tc.token, tc.c)
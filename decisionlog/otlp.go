@@ -0,0 +1,296 @@
+// Package decisionlog exports a record of every access control decision as an OpenTelemetry log record using
+// OTLP/HTTP, so decisions land in the same backend as the traces of the requests and can be correlated by trace ID.
+package decisionlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+)
+
+// ScopeName is the name of the instrumentation scope of the exported log records.
+const ScopeName = "github.com/ory/oathkeeper"
+
+// Severity numbers as defined by the OpenTelemetry logs data model.
+const (
+	SeverityInfo  = 9
+	SeverityWarn  = 13
+	SeverityError = 17
+)
+
+type Registry interface {
+	DecisionLogExporter() *Exporter
+}
+
+// Exporter subscribes to the decisions published on the event bus and exports them in batches to the configured
+// OTLP/HTTP logs endpoint. Decisions are dropped if the endpoint can not keep up.
+type Exporter struct {
+	c      configuration.Provider
+	bus    *events.Bus
+	logger logrus.FieldLogger
+	client *http.Client
+}
+
+func NewExporter(c configuration.Provider, bus *events.Bus, logger logrus.FieldLogger) *Exporter {
+	return &Exporter{
+		c:      c,
+		bus:    bus,
+		logger: logger,
+		client: httpx.NewResilientClientLatencyToleranceSmall(nil),
+	}
+}
+
+// Run exports decisions until the context is canceled. Decisions are only consumed while an endpoint is configured.
+func (e *Exporter) Run(ctx context.Context) {
+	var s *events.Subscription
+	defer func() {
+		if s != nil {
+			s.Close()
+		}
+	}()
+
+	var batch []events.Event
+	var dropped uint64
+	for {
+		interval := e.c.DecisionLogOTLPFlushInterval()
+		if interval <= 0 {
+			interval = time.Second * 5
+		}
+		size := e.c.DecisionLogOTLPBatchSize()
+		if size <= 0 {
+			size = 512
+		}
+
+		if e.c.DecisionLogOTLPEndpoint() == "" {
+			if s != nil {
+				s.Close()
+				s = nil
+			}
+			batch = nil
+		} else if s == nil {
+			s = e.bus.Subscribe(size*4, events.Filter{Type: events.TypeDecision})
+			dropped = 0
+		}
+
+		var received <-chan events.Event
+		if s != nil {
+			received = s.Events()
+		}
+
+		timeout := time.After(interval)
+	collect:
+		for len(batch) < size {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timeout:
+				break collect
+			case ev := <-received:
+				batch = append(batch, ev)
+			}
+		}
+
+		if len(batch) > 0 {
+			if err := e.Export(ctx, batch); err != nil {
+				e.logger.WithError(err).WithField("records", len(batch)).Warn("Unable to export decision records")
+			}
+			batch = batch[:0]
+		}
+
+		if s != nil && s.Dropped() > dropped {
+			e.logger.WithField("records", s.Dropped()-dropped).Warn("Dropped decision records because the exporter did not keep up")
+			dropped = s.Dropped()
+		}
+	}
+}
+
+// Export sends the decisions to the configured endpoint as a single OTLP/HTTP logs request using JSON encoding.
+func (e *Exporter) Export(ctx context.Context, decisions []events.Event) error {
+	records := make([]LogRecord, 0, len(decisions))
+	for _, ev := range decisions {
+		if ev.Decision != nil {
+			records = append(records, NewLogRecord(ev))
+		}
+	}
+
+	body, err := json.Marshal(&exportLogsRequest{ResourceLogs: []resourceLogs{{
+		Resource:  resource{Attributes: []Attribute{stringAttribute("service.name", e.c.TracingServiceName())}},
+		ScopeLogs: []scopeLogs{{Scope: scope{Name: ScopeName}, LogRecords: records}},
+	}}})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.c.DecisionLogOTLPEndpoint(), bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for name, value := range e.c.DecisionLogOTLPHeaders() {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf("expected a 2xx status code but got %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+type exportLogsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []Attribute `json:"attributes"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []LogRecord `json:"logRecords"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+// LogRecord is an OpenTelemetry log record in the JSON encoding of OTLP.
+type LogRecord struct {
+	// TimeUnixNano is the time of the decision in nanoseconds since the Unix epoch. 64 bit integers are encoded as
+	// strings.
+	TimeUnixNano   string      `json:"timeUnixNano"`
+	SeverityNumber int         `json:"severityNumber"`
+	SeverityText   string      `json:"severityText"`
+	Body           Value       `json:"body"`
+	Attributes     []Attribute `json:"attributes"`
+
+	// TraceID is the hex encoded 16 byte trace ID of the request, if tracing is enabled.
+	TraceID string `json:"traceId,omitempty"`
+}
+
+// Attribute is a key-value pair of a log record.
+type Attribute struct {
+	Key   string `json:"key"`
+	Value Value  `json:"value"`
+}
+
+// Value holds exactly one of its fields.
+type Value struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func stringAttribute(key, value string) Attribute {
+	return Attribute{Key: key, Value: Value{StringValue: &value}}
+}
+
+func intAttribute(key string, value int) Attribute {
+	v := strconv.Itoa(value)
+	return Attribute{Key: key, Value: Value{IntValue: &v}}
+}
+
+func doubleAttribute(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: Value{DoubleValue: &value}}
+}
+
+func boolAttribute(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: Value{BoolValue: &value}}
+}
+
+// NewLogRecord returns the log record of a decision event. HTTP and end user attributes follow the OpenTelemetry
+// semantic conventions, attributes specific to ORY Oathkeeper are prefixed with "oathkeeper.".
+func NewLogRecord(e events.Event) LogRecord {
+	d := e.Decision
+	body := fmt.Sprintf("%s %s %s", d.Method, d.URL, d.Outcome())
+
+	r := LogRecord{
+		TimeUnixNano:   strconv.FormatInt(e.Time.UnixNano(), 10),
+		SeverityNumber: SeverityInfo,
+		SeverityText:   "INFO",
+		Body:           Value{StringValue: &body},
+		Attributes: []Attribute{
+			stringAttribute("http.method", d.Method),
+			stringAttribute("http.url", d.URL),
+			intAttribute("http.status_code", d.StatusCode),
+			stringAttribute("oathkeeper.interface", d.Interface),
+			stringAttribute("oathkeeper.decision", d.Outcome()),
+			boolAttribute("oathkeeper.granted", d.Granted),
+			doubleAttribute("oathkeeper.latency_ms", d.LatencyMS),
+		},
+		TraceID: normalizeTraceID(d.TraceID),
+	}
+
+	switch {
+	case d.StatusCode >= 500:
+		r.SeverityNumber, r.SeverityText = SeverityError, "ERROR"
+	case !d.Granted:
+		r.SeverityNumber, r.SeverityText = SeverityWarn, "WARN"
+	}
+
+	if d.RuleID != "" {
+		r.Attributes = append(r.Attributes, stringAttribute("oathkeeper.rule_id", d.RuleID))
+	}
+	if d.RuleOwner != "" {
+		r.Attributes = append(r.Attributes, stringAttribute("oathkeeper.rule_owner", d.RuleOwner))
+	}
+	keys := make([]string, 0, len(d.RuleLabels))
+	for key := range d.RuleLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.Attributes = append(r.Attributes, stringAttribute("oathkeeper.rule_label."+key, d.RuleLabels[key]))
+	}
+	if d.Subject != "" {
+		r.Attributes = append(r.Attributes, stringAttribute("enduser.id", d.Subject))
+	}
+	if d.Error != "" {
+		r.Attributes = append(r.Attributes, stringAttribute("oathkeeper.error", d.Error))
+	}
+	if d.UpstreamError != "" {
+		r.Attributes = append(r.Attributes, stringAttribute("oathkeeper.upstream_error", d.UpstreamError))
+	}
+
+	return r
+}
+
+// normalizeTraceID returns the trace ID as 32 lower case hex characters, padding 64 bit trace IDs with zeros. It
+// returns an empty string if the trace ID is not valid.
+func normalizeTraceID(id string) string {
+	id = strings.ToLower(id)
+	if id == "" || len(id) > 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(strings.Repeat("0", len(id)%2) + id); err != nil {
+		return ""
+	}
+	return strings.Repeat("0", 32-len(id)) + id
+}
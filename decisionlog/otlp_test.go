@@ -0,0 +1,158 @@
+package decisionlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/decisionlog"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/redaction"
+)
+
+type exportRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []decisionlog.Attribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []decisionlog.LogRecord `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+func attributes(r decisionlog.LogRecord) map[string]decisionlog.Value {
+	values := map[string]decisionlog.Value{}
+	for _, a := range r.Attributes {
+		values[a.Key] = a.Value
+	}
+	return values
+}
+
+func TestExporter(t *testing.T) {
+	received := make(chan exportRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var req exportRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		received <- req
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyDecisionLogOTLPEndpoint, server.URL+"/v1/logs")
+	viper.Set(configuration.ViperKeyDecisionLogOTLPHeaders, map[string]interface{}{"Authorization": "Bearer secret"})
+	viper.Set(configuration.ViperKeyDecisionLogOTLPBatchSize, 2)
+	viper.Set(configuration.ViperKeyDecisionLogOTLPFlushInterval, "100ms")
+
+	redactor, err := redaction.NewRedactor(nil, nil)
+	require.NoError(t, err)
+	bus := events.NewBus(redactor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go decisionlog.NewExporter(conf, bus, logrus.New()).Run(ctx)
+
+	for !bus.HasSubscribers() {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	now := time.Now().UTC()
+	bus.Publish(events.Event{Type: events.TypeRuleReload, Time: now, RuleReload: &events.RuleReload{Repository: "file:///etc/rules.json"}})
+	bus.Publish(events.Event{Type: events.TypeDecision, Time: now, Decision: &events.Decision{
+		Interface: "proxy", RuleID: "users", RuleOwner: "identity", RuleLabels: map[string]string{"tier": "critical"}, Subject: "alice", Method: "GET", URL: "https://api.example.com/users",
+		Granted: true, StatusCode: 200, LatencyMS: 1.5, TraceID: "5a1b2c3d4e5f6071",
+	}})
+	bus.Publish(events.Event{Type: events.TypeDecision, Time: now, Decision: &events.Decision{
+		Interface: "decisions", Method: "POST", URL: "https://api.example.com/admin",
+		StatusCode: 404, Error: "Requested url does not match any rules",
+	}})
+
+	var req exportRequest
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no decision records were exported")
+	}
+
+	require.Len(t, req.ResourceLogs, 1)
+	require.Len(t, req.ResourceLogs[0].Resource.Attributes, 1)
+	assert.Equal(t, "service.name", req.ResourceLogs[0].Resource.Attributes[0].Key)
+	require.Len(t, req.ResourceLogs[0].ScopeLogs, 1)
+	assert.Equal(t, decisionlog.ScopeName, req.ResourceLogs[0].ScopeLogs[0].Scope.Name)
+
+	records := req.ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+
+	allowed := records[0]
+	assert.Equal(t, decisionlog.SeverityInfo, allowed.SeverityNumber)
+	assert.Equal(t, "GET https://api.example.com/users allowed", *allowed.Body.StringValue)
+	assert.Equal(t, "00000000000000005a1b2c3d4e5f6071", allowed.TraceID)
+	assert.NotEmpty(t, allowed.TimeUnixNano)
+	values := attributes(allowed)
+	assert.Equal(t, "GET", *values["http.method"].StringValue)
+	assert.Equal(t, "https://api.example.com/users", *values["http.url"].StringValue)
+	assert.Equal(t, "200", *values["http.status_code"].IntValue)
+	assert.Equal(t, "alice", *values["enduser.id"].StringValue)
+	assert.Equal(t, "users", *values["oathkeeper.rule_id"].StringValue)
+	assert.Equal(t, "identity", *values["oathkeeper.rule_owner"].StringValue)
+	assert.Equal(t, "critical", *values["oathkeeper.rule_label.tier"].StringValue)
+	assert.Equal(t, "allowed", *values["oathkeeper.decision"].StringValue)
+	assert.Equal(t, 1.5, *values["oathkeeper.latency_ms"].DoubleValue)
+
+	denied := records[1]
+	assert.Equal(t, decisionlog.SeverityWarn, denied.SeverityNumber)
+	assert.Empty(t, denied.TraceID)
+	values = attributes(denied)
+	assert.Equal(t, "denied", *values["oathkeeper.decision"].StringValue)
+	assert.Equal(t, "Requested url does not match any rules", *values["oathkeeper.error"].StringValue)
+	assert.NotContains(t, values, "enduser.id")
+	assert.NotContains(t, values, "oathkeeper.rule_id")
+}
+
+func TestNewLogRecord(t *testing.T) {
+	for k, tc := range []struct {
+		d             events.Decision
+		expectTraceID string
+		expectLevel   int
+	}{
+		{
+			d:             events.Decision{Granted: true, StatusCode: 200, TraceID: "4BF92F3577B34DA6A3CE929D0E0E4736"},
+			expectTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			expectLevel:   decisionlog.SeverityInfo,
+		},
+		{
+			d:           events.Decision{Granted: true, StatusCode: 502, TraceID: "not-a-trace-id"},
+			expectLevel: decisionlog.SeverityError,
+		},
+		{
+			d:             events.Decision{StatusCode: 403, TraceID: "abc"},
+			expectTraceID: "00000000000000000000000000000abc",
+			expectLevel:   decisionlog.SeverityWarn,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			r := decisionlog.NewLogRecord(events.Event{Type: events.TypeDecision, Time: time.Now(), Decision: &tc.d})
+			assert.Equal(t, tc.expectTraceID, r.TraceID)
+			assert.Equal(t, tc.expectLevel, r.SeverityNumber)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package configuration
+
+import (
+	"net"
+	"strings"
+
+	"github.com/ory/oathkeeper/x"
+)
+
+// JSONWebKeySetTLS are the TLS settings used to fetch JSON Web Key Sets from a host, configured at `jwks_tls`.
+type JSONWebKeySetTLS struct {
+	// Host is the hostname, or hostname and port, the settings apply to.
+	Host string `json:"host"`
+
+	x.TLSClientConfig
+}
+
+// Matches returns true if the settings apply to the host ("hostname" or "hostname:port") of a URL. Settings for a
+// hostname apply to all of its ports.
+func (t JSONWebKeySetTLS) Matches(host string) bool {
+	if strings.EqualFold(t.Host, host) {
+		return true
+	}
+	hostname, _, err := net.SplitHostPort(host)
+	return err == nil && strings.EqualFold(t.Host, hostname)
+}
@@ -0,0 +1,176 @@
+package configuration
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultProxyListener is the name of the proxy listener configured at `serve.proxy`.
+const DefaultProxyListener = "default"
+
+// ProxyListener is an additional proxy listener configured at `serve.proxy.listeners`.
+type ProxyListener struct {
+	// Name identifies the listener. Access rules are only served by the listeners they name.
+	Name string `json:"name"`
+
+	Host string `json:"host"`
+	Port int    `json:"port"`
+
+	TLS ListenerTLS `json:"tls"`
+
+	Errors ListenerErrors `json:"errors"`
+
+	SecurityHeaders ListenerSecurityHeaders `json:"security_headers"`
+
+	HTTP3 ListenerHTTP3 `json:"http3"`
+}
+
+// ListenerHTTP3 configures the experimental HTTP/3 support of a proxy listener.
+type ListenerHTTP3 struct {
+	// Enabled serves HTTP/3 over QUIC on the UDP port with the same number as the TCP port of the listener and
+	// advertises it using the Alt-Svc header. Clients which can not use HTTP/3 keep using HTTP/1.1 and HTTP/2. The
+	// listener must be configured with TLS.
+	Enabled bool `json:"enabled"`
+}
+
+// ListenerTLS configures HTTPS for a listener.
+type ListenerTLS struct {
+	Key  ListenerTLSSource `json:"key"`
+	Cert ListenerTLSSource `json:"cert"`
+
+	// MinVersion and MaxVersion restrict the TLS versions (e.g. "1.2") which may be negotiated.
+	MinVersion string `json:"min_version"`
+	MaxVersion string `json:"max_version"`
+
+	// CipherSuites are the names of the TLS 1.2 cipher suites which may be negotiated, in order of preference.
+	CipherSuites []string `json:"cipher_suites"`
+
+	// CurvePreferences are the names of the elliptic curves used for key exchange, in order of preference.
+	CurvePreferences []string `json:"curve_preferences"`
+
+	ClientAuth ListenerTLSClientAuth `json:"client_auth"`
+
+	// OCSPStapling staples OCSP responses to the certificates of the listener.
+	OCSPStapling bool `json:"ocsp_stapling"`
+}
+
+// ListenerTLSClientAuth configures whether clients must present certificates.
+type ListenerTLSClientAuth struct {
+	// Mode is one of "none", "request", "require", and "verify".
+	Mode string `json:"mode"`
+
+	// CA contains the PEM-encoded certificates client certificates are verified against if Mode is "verify".
+	CA ListenerTLSSource `json:"ca"`
+
+	Revocation ListenerTLSRevocation `json:"revocation"`
+}
+
+// ListenerTLSRevocation configures the revocation checking of verified client certificates.
+type ListenerTLSRevocation struct {
+	Enabled bool `json:"enabled"`
+
+	// Policy is either "soft_fail" (default) or "hard_fail" and decides whether client certificates whose revocation
+	// status can not be determined are accepted.
+	Policy string `json:"policy"`
+
+	// Methods are "ocsp" and "crl". OCSP is tried first if both are used, which is the default.
+	Methods []string `json:"methods"`
+
+	// CacheTTL is the maximum duration revocation statuses and lists are cached for, defaults to one hour.
+	CacheTTL string `json:"cache_ttl"`
+}
+
+// Uses returns true if the revocation status is determined using the method.
+func (r *ListenerTLSRevocation) Uses(method string) bool {
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyOrDefault returns the configured policy or "soft_fail".
+func (r *ListenerTLSRevocation) PolicyOrDefault() string {
+	if r.Policy == "" {
+		return "soft_fail"
+	}
+	return r.Policy
+}
+
+// TTL returns the configured cache TTL or one hour.
+func (r *ListenerTLSRevocation) TTL() time.Duration {
+	ttl, err := time.ParseDuration(r.CacheTTL)
+	if err != nil || ttl <= 0 {
+		return time.Hour
+	}
+	return ttl
+}
+
+// ListenerTLSSource is a PEM-encoded file given either by its path or as a base64 encoded string.
+type ListenerTLSSource struct {
+	Path   string `json:"path"`
+	Base64 string `json:"base64"`
+}
+
+// ListenerErrors overrides the error handling of a proxy listener.
+type ListenerErrors struct {
+	// Fallback overrides `errors.fallback` for requests served by this listener.
+	Fallback []string `json:"fallback"`
+}
+
+// Security header profiles.
+const (
+	SecurityHeadersProfileNone   = "none"
+	SecurityHeadersProfileStrict = "strict"
+)
+
+// ListenerSecurityHeaders configures the security headers a proxy listener adds to proxied and error responses.
+type ListenerSecurityHeaders struct {
+	// Profile is either "none" (default), which adds no headers, or "strict".
+	Profile string `json:"profile"`
+
+	// StrictTransportSecurity, ContentTypeOptions, ReferrerPolicy, and FrameOptions override the values of the
+	// profile.
+	StrictTransportSecurity string `json:"strict_transport_security"`
+	ContentTypeOptions      string `json:"content_type_options"`
+	ReferrerPolicy          string `json:"referrer_policy"`
+	FrameOptions            string `json:"frame_options"`
+}
+
+// SecurityHeadersProfile returns the headers of a security header profile.
+func SecurityHeadersProfile(profile string) http.Header {
+	h := http.Header{}
+	if profile == SecurityHeadersProfileStrict {
+		h.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		h.Set("X-Frame-Options", "DENY")
+	}
+	return h
+}
+
+// Headers returns the headers of the profile with the overrides applied.
+func (s *ListenerSecurityHeaders) Headers() http.Header {
+	h := SecurityHeadersProfile(s.Profile)
+	for name, value := range map[string]string{
+		"Strict-Transport-Security": s.StrictTransportSecurity,
+		"X-Content-Type-Options":    s.ContentTypeOptions,
+		"Referrer-Policy":           s.ReferrerPolicy,
+		"X-Frame-Options":           s.FrameOptions,
+	} {
+		if value != "" {
+			h.Set(name, value)
+		}
+	}
+	return h
+}
+
+// Address returns the address the listener should listen on.
+func (l *ProxyListener) Address() string {
+	return fmt.Sprintf("%s:%d", l.Host, l.Port)
+}
@@ -0,0 +1,120 @@
+package configuration
+
+import (
+	"reflect"
+	"sync"
+)
+
+// parsedConfigKey identifies a configuration of a pipeline handler decoded into a destination of a given type. The
+// hash covers the handler, the configuration of the access rule, and the time the configuration file changed.
+type parsedConfigKey struct {
+	hash uint64
+	typ  reflect.Type
+}
+
+// parsedConfigCache keeps the decoded configurations of pipeline handlers so that the configuration of an access
+// rule is not decoded on every request. Callers receive deep copies and may modify them.
+type parsedConfigCache struct {
+	sync.RWMutex
+	values map[parsedConfigKey]reflect.Value
+}
+
+func newParsedConfigCache() *parsedConfigCache {
+	return &parsedConfigCache{values: map[parsedConfigKey]reflect.Value{}}
+}
+
+// load copies the cached configuration into dest and returns true, or returns false if none was cached.
+func (c *parsedConfigCache) load(hash uint64, dest interface{}) bool {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() {
+		return false
+	}
+
+	c.RLock()
+	v, ok := c.values[parsedConfigKey{hash: hash, typ: d.Type()}]
+	c.RUnlock()
+	if !ok {
+		return false
+	}
+
+	d.Elem().Set(deepcopyValue(v))
+	return true
+}
+
+func (c *parsedConfigCache) store(hash uint64, dest interface{}) {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() {
+		return
+	}
+
+	c.Lock()
+	c.values[parsedConfigKey{hash: hash, typ: d.Type()}] = deepcopyValue(d.Elem())
+	c.Unlock()
+}
+
+func (c *parsedConfigCache) reset() {
+	c.Lock()
+	c.values = map[parsedConfigKey]reflect.Value{}
+	c.Unlock()
+}
+
+// deepcopyValue copies the exported fields, pointers, slices and maps of v. It covers values decoded from JSON,
+// which are free of cycles.
+func deepcopyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepcopyValue(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepcopyValue(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(c, v)
+			return c
+		}
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepcopyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepcopyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepcopyValue(iter.Value()))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(deepcopyValue(v.Field(i)))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
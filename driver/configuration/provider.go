@@ -1,21 +1,86 @@
 package configuration
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gobuffalo/packr/v2"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/ory/fosite"
 	"github.com/ory/x/tracing"
 
 	"github.com/rs/cors"
+
+	"github.com/ory/oathkeeper/x"
 )
 
 var schemas = packr.New("schemas", "../../.schema")
 
+// SchemaDigest returns the hex encoded SHA-256 digest of an embedded JSON Schema, for example "config.schema.json".
+// The digest changes with every change of the schema and thus identifies its version.
+func SchemaDigest(name string) (string, error) {
+	raw, err := schemas.Find(name)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(raw)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// PipelineSchema returns the JSON Schema of the configuration of a pipeline handler, for example of kind
+// "authenticators" and id "jwt". The schema is self-contained: the definitions of the configuration schema are
+// embedded so that it can be used without it.
+func PipelineSchema(kind, id string) (json.RawMessage, error) {
+	rawComponentSchema, err := schemas.Find(fmt.Sprintf("pipeline/%s.%s.schema.json", kind, id))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	rawRootSchema, err := schemas.Find("config.schema.json")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var component map[string]interface{}
+	if err := json.Unmarshal(rawComponentSchema, &component); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var root struct {
+		Definitions map[string]interface{} `json:"definitions"`
+	}
+	if err := json.Unmarshal(rawRootSchema, &root); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	schema := map[string]interface{}{}
+	if ref, ok := component["$ref"].(string); ok {
+		name := ref[strings.LastIndex(ref, "/")+1:]
+		definition, ok := root.Definitions[name].(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("the schema of %s %s references unknown definition %s", kind, id, name)
+		}
+		for k, v := range definition {
+			schema[k] = v
+		}
+	}
+
+	schema["$id"] = fmt.Sprintf("/.schema/pipeline/%s.%s.schema.json", kind, id)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["definitions"] = root.Definitions
+
+	out, err := json.Marshal(schema)
+	return out, errors.WithStack(err)
+}
+
 const (
 	ForbiddenStrategyErrorType = "forbidden"
 )
@@ -30,6 +95,25 @@ const (
 	Glob   MatchingStrategy = "glob"
 )
 
+// Possible severities of duplicate access rule IDs.
+const (
+	DuplicateRuleIDsWarn  = "warn"
+	DuplicateRuleIDsError = "error"
+)
+
+// Possible handlings of expired access rules.
+const (
+	ExpiredRulesWarn    = "warn"
+	ExpiredRulesDisable = "disable"
+)
+
+// Possible modes of the Forwarded header sent to upstreams.
+const (
+	ForwardedModeAppend  = "append"
+	ForwardedModeReplace = "replace"
+	ForwardedModeOff     = "off"
+)
+
 type Provider interface {
 	CORSEnabled(iface string) bool
 	CORSOptions(iface string) cors.Options
@@ -45,9 +129,113 @@ type Provider interface {
 
 	AccessRuleRepositories() []url.URL
 	AccessRuleMatchingStrategy() MatchingStrategy
+	AccessRuleInline() json.RawMessage
+	AccessRuleStagingIsEnabled() bool
+	AccessRuleNamespace(repository url.URL) string
+	AccessRuleDuplicateIDs() string
+	AccessRuleExpiredRules() string
+	AccessRuleNATSURL() *url.URL
+	AccessRuleNATSSubject() string
+	AccessRuleVerificationJWKSURLs() []url.URL
+	ResetPipelineConfigCache()
 
 	ProxyServeAddress() string
+	ProxyListeners() []ProxyListener
 	APIServeAddress() string
+	ServeTLS(iface string) ListenerTLS
+	ProxySecurityHeaders(listener string) ListenerSecurityHeaders
+	ProxyHTTP3(listener string) ListenerHTTP3
+	ProxyForwardedMode() string
+	ProxyForwardedBy() string
+	ProxyHealthEnabled() bool
+	ProxyHealthAlivePath() string
+	ProxyHealthReadyPath() string
+
+	DecisionSigningIsEnabled() bool
+	DecisionSigningJWKSURL() *url.URL
+	DecisionSigningIssuer() string
+	DecisionSigningTTL() time.Duration
+	DecisionSigningHeader() string
+
+	RevocationIsEnabled() bool
+	RevocationJWKSURLs() []string
+	RevocationTrustedIssuers() []string
+	RevocationTargetAudience() []string
+	RevocationAllowedAlgorithms() []string
+	RevocationTTL() time.Duration
+
+	RiskIsEnabled() bool
+	RiskRemote() string
+	RiskGeoHeaders() map[string]string
+	RiskDenyAbove() float64
+	RiskStepUpAbove() float64
+	RiskClaim() string
+	RiskFailOpen() bool
+
+	LockoutIsEnabled() bool
+	LockoutKeys() []string
+	LockoutTokenPrefixLength() int
+	LockoutWindow() time.Duration
+	LockoutDelayAfter() int
+	LockoutDelay() time.Duration
+	LockoutMaxDelay() time.Duration
+	LockoutLockAfter() int
+	LockoutLockDuration() time.Duration
+
+	OverloadIsEnabled() bool
+	OverloadMaxInFlight() int
+	OverloadMaxLatency() time.Duration
+	OverloadLatencyWindow() time.Duration
+	OverloadShedPercentage() map[string]int
+	OverloadMaxInFlightPerPriority() map[string]int
+	OverloadUpstreamLimitIsEnabled() bool
+	OverloadUpstreamLimitInitial() int
+	OverloadUpstreamLimitMin() int
+	OverloadUpstreamLimitMax() int
+	OverloadUpstreamLimitLatencyTolerance() float64
+	OverloadUpstreamLimitBackoff() float64
+
+	HoneypotWebhookURL() string
+	HoneypotWebhookFormat() string
+
+	AdminUIIsEnabled() bool
+
+	Webhooks() []Webhook
+	WebhookThresholdWindow() time.Duration
+	WebhookThresholdMinRequests() int
+	WebhookThresholdDenyRate() float64
+	WebhookThresholdErrorRate() float64
+
+	DecisionLogOTLPEndpoint() string
+	DecisionLogOTLPHeaders() map[string]string
+	DecisionLogOTLPBatchSize() int
+	DecisionLogOTLPFlushInterval() time.Duration
+
+	ExplanationsSampleRate() float64
+	ExplanationsStore() string
+	ExplanationsSize() int
+	ExplanationsFile() string
+
+	StatsDAddress() string
+	StatsDPrefix() string
+	StatsDTagFormat() string
+	StatsDTags() []string
+	StatsDRuleLabels() []string
+	StatsDFlushInterval() time.Duration
+	SLOWindows() []time.Duration
+
+	RemoteResponseMaxBodySize() int64
+	RemoteResponseTimeout() time.Duration
+	TrustedProxies() []*net.IPNet
+	HostOverrides() map[string]string
+	JSONWebKeySetTLSConfig(host string) *x.TLSClientConfig
+	HealthDependencyWeight(dependency string) string
+
+	FIPSIsEnabled() bool
+	StrictModeIsEnabled() bool
+
+	RedactionHeaders() []string
+	RedactionPatterns() []string
 
 	ToScopeStrategy(value string, key string) fosite.ScopeStrategy
 	ParseURLs(sources []string) ([]url.URL, error)
@@ -62,6 +250,7 @@ type ProviderErrorHandlers interface {
 	ErrorHandlerConfig(id string, override json.RawMessage, dest interface{}) error
 	ErrorHandlerIsEnabled(id string) bool
 	ErrorHandlerFallbackSpecificity() []string
+	ErrorHandlerFallbackSpecificityFor(listener string) []string
 }
 type ProviderAuthenticators interface {
 	AuthenticatorConfig(id string, overrides json.RawMessage, destination interface{}) error
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash/crc64"
+	"net"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +17,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
 
 	"github.com/ory/viper"
 
@@ -27,6 +30,7 @@ import (
 	"github.com/ory/x/urlx"
 	"github.com/ory/x/viperx"
 
+	"github.com/ory/oathkeeper/fips"
 	"github.com/ory/oathkeeper/x"
 )
 
@@ -43,10 +47,182 @@ const (
 	ViperKeyProxyIdleTimeout           = "serve.proxy.timeout.idle"
 	ViperKeyProxyServeAddressHost      = "serve.proxy.host"
 	ViperKeyProxyServeAddressPort      = "serve.proxy.port"
+	ViperKeyProxyListeners             = "serve.proxy.listeners"
+	ViperKeyProxySecurityHeaders       = "serve.proxy.security_headers"
+	ViperKeyProxyHTTP3IsEnabled        = "serve.proxy.http3.enabled"
+	ViperKeyProxyForwardedMode         = "serve.proxy.forwarded.mode"
+	ViperKeyProxyForwardedBy           = "serve.proxy.forwarded.by"
+	ViperKeyProxyHealthEnabled         = "serve.proxy.health.enabled"
+	ViperKeyProxyHealthAlivePath       = "serve.proxy.health.alive_path"
+	ViperKeyProxyHealthReadyPath       = "serve.proxy.health.ready_path"
 	ViperKeyAPIServeAddressHost        = "serve.api.host"
 	ViperKeyAPIServeAddressPort        = "serve.api.port"
 	ViperKeyAccessRuleRepositories     = "access_rules.repositories"
 	ViperKeyAccessRuleMatchingStrategy = "access_rules.matching_strategy"
+	ViperKeyAccessRuleStagingIsEnabled = "access_rules.staging.enabled"
+	ViperKeyAccessRuleInline           = "access_rules.inline"
+	ViperKeyAccessRuleNamespaces       = "access_rules.namespaces"
+	ViperKeyAccessRuleDuplicateIDs     = "access_rules.duplicate_ids"
+	ViperKeyAccessRuleExpiredRules     = "access_rules.expired_rules"
+	ViperKeyAccessRuleNotifications    = "access_rules.notifications"
+	ViperKeyAccessRuleNATSURL          = "access_rules.notifications.nats.url"
+	ViperKeyAccessRuleNATSSubject      = "access_rules.notifications.nats.subject"
+	ViperKeyAccessRuleVerificationJWKS = "access_rules.verification.jwks_urls"
+)
+
+// Decisions
+const (
+	ViperKeyDecisionSigningIsEnabled = "serve.api.decisions.signing.enabled"
+	ViperKeyDecisionSigningJWKSURL   = "serve.api.decisions.signing.jwks_url"
+	ViperKeyDecisionSigningIssuer    = "serve.api.decisions.signing.issuer"
+	ViperKeyDecisionSigningTTL       = "serve.api.decisions.signing.ttl"
+	ViperKeyDecisionSigningHeader    = "serve.api.decisions.signing.header"
+)
+
+// Revocation
+const (
+	ViperKeyRevocationIsEnabled         = "revocation.enabled"
+	ViperKeyRevocationJWKSURLs          = "revocation.jwks_urls"
+	ViperKeyRevocationTrustedIssuers    = "revocation.trusted_issuers"
+	ViperKeyRevocationTargetAudience    = "revocation.target_audience"
+	ViperKeyRevocationAllowedAlgorithms = "revocation.allowed_algorithms"
+	ViperKeyRevocationTTL               = "revocation.ttl"
+)
+
+// FIPS
+const (
+	ViperKeyFIPSIsEnabled = "fips.enabled"
+)
+
+// Strict Mode
+const (
+	ViperKeyStrictModeIsEnabled = "strict.enabled"
+)
+
+// Risk
+const (
+	ViperKeyRiskIsEnabled   = "risk.enabled"
+	ViperKeyRiskRemote      = "risk.remote"
+	ViperKeyRiskGeoHeaders  = "risk.geo_headers"
+	ViperKeyRiskDenyAbove   = "risk.deny_above"
+	ViperKeyRiskStepUpAbove = "risk.step_up_above"
+	ViperKeyRiskClaim       = "risk.claim"
+	ViperKeyRiskFailOpen    = "risk.fail_open"
+)
+
+// Lockout
+const (
+	ViperKeyLockoutIsEnabled         = "lockout.enabled"
+	ViperKeyLockoutKeys              = "lockout.keys"
+	ViperKeyLockoutTokenPrefixLength = "lockout.token_prefix_length"
+	ViperKeyLockoutWindow            = "lockout.window"
+	ViperKeyLockoutDelayAfter        = "lockout.delay_after"
+	ViperKeyLockoutDelay             = "lockout.delay"
+	ViperKeyLockoutMaxDelay          = "lockout.max_delay"
+	ViperKeyLockoutLockAfter         = "lockout.lock_after"
+	ViperKeyLockoutLockDuration      = "lockout.lock_duration"
+)
+
+// Overload
+const (
+	ViperKeyOverloadIsEnabled      = "overload.enabled"
+	ViperKeyOverloadMaxInFlight    = "overload.max_in_flight"
+	ViperKeyOverloadMaxLatency     = "overload.max_latency"
+	ViperKeyOverloadLatencyWindow  = "overload.latency_window"
+	ViperKeyOverloadShedPercentage = "overload.shed_percentage"
+	ViperKeyOverloadMaxInFlightPer = "overload.max_in_flight_per_priority"
+
+	ViperKeyOverloadUpstreamLimitIsEnabled        = "overload.upstream_limit.enabled"
+	ViperKeyOverloadUpstreamLimitInitial          = "overload.upstream_limit.initial"
+	ViperKeyOverloadUpstreamLimitMin              = "overload.upstream_limit.min"
+	ViperKeyOverloadUpstreamLimitMax              = "overload.upstream_limit.max"
+	ViperKeyOverloadUpstreamLimitLatencyTolerance = "overload.upstream_limit.latency_tolerance"
+	ViperKeyOverloadUpstreamLimitBackoff          = "overload.upstream_limit.backoff"
+)
+
+// Honeypot
+const (
+	ViperKeyHoneypotWebhookURL    = "honeypot.webhook.url"
+	ViperKeyHoneypotWebhookFormat = "honeypot.webhook.format"
+)
+
+// Admin UI
+const (
+	ViperKeyAdminUIIsEnabled = "serve.api.ui.enabled"
+)
+
+// Webhooks
+const (
+	ViperKeyWebhooks                    = "webhooks.endpoints"
+	ViperKeyWebhookThresholdWindow      = "webhooks.thresholds.window"
+	ViperKeyWebhookThresholdMinRequests = "webhooks.thresholds.min_requests"
+	ViperKeyWebhookThresholdDenyRate    = "webhooks.thresholds.deny_rate"
+	ViperKeyWebhookThresholdErrorRate   = "webhooks.thresholds.error_rate"
+)
+
+// Decision Log
+const (
+	ViperKeyDecisionLogOTLPEndpoint      = "decision_log.otlp.endpoint"
+	ViperKeyDecisionLogOTLPHeaders       = "decision_log.otlp.headers"
+	ViperKeyDecisionLogOTLPBatchSize     = "decision_log.otlp.batch_size"
+	ViperKeyDecisionLogOTLPFlushInterval = "decision_log.otlp.flush_interval"
+)
+
+// Explanations
+const (
+	ViperKeyExplanationsSampleRate = "explanations.sample_rate"
+	ViperKeyExplanationsStore      = "explanations.store"
+	ViperKeyExplanationsSize       = "explanations.size"
+	ViperKeyExplanationsFile       = "explanations.file"
+)
+
+// Metrics
+const (
+	ViperKeyStatsDAddress       = "metrics.statsd.address"
+	ViperKeyStatsDPrefix        = "metrics.statsd.prefix"
+	ViperKeyStatsDTagFormat     = "metrics.statsd.tag_format"
+	ViperKeyStatsDTags          = "metrics.statsd.tags"
+	ViperKeyStatsDRuleLabels    = "metrics.statsd.rule_labels"
+	ViperKeyStatsDFlushInterval = "metrics.statsd.flush_interval"
+	ViperKeySLOWindows          = "metrics.slo.windows"
+)
+
+// Remote Responses
+const (
+	ViperKeyRemoteResponseMaxBodySize = "remote_responses.max_body_size"
+	ViperKeyRemoteResponseTimeout     = "remote_responses.timeout"
+)
+
+// Trusted Proxies
+const (
+	ViperKeyTrustedProxies = "trusted_proxies"
+)
+
+// Host Overrides
+const (
+	ViperKeyHosts = "hosts"
+)
+
+// JSON Web Key Set TLS
+const (
+	ViperKeyJWKSTLS = "jwks_tls"
+)
+
+// Health
+const (
+	ViperKeyHealthDependencies = "health.dependencies"
+)
+
+// defaultHealthDependencyWeights are the weights of dependencies which are not configured. Only the activation of the
+// access rules is required for readiness by default.
+var defaultHealthDependencyWeights = map[string]string{
+	"access_rules": "hard",
+}
+
+// Redaction
+const (
+	ViperKeyRedactionHeaders  = "redaction.headers"
+	ViperKeyRedactionPatterns = "redaction.patterns"
 )
 
 // Authorizers
@@ -55,6 +231,8 @@ const (
 
 	ViperKeyAuthorizerDenyIsEnabled = "authorizers.deny.enabled"
 
+	ViperKeyAuthorizerDevicePostureIsEnabled = "authorizers.device_posture.enabled"
+
 	ViperKeyAuthorizerKetoEngineACPORYIsEnabled = "authorizers.keto_engine_acp_ory.enabled"
 
 	ViperKeyAuthorizerRemoteJSONIsEnabled = "authorizers.remote_json.enabled"
@@ -72,6 +250,10 @@ const (
 
 	ViperKeyMutatorIDTokenIsEnabled = "mutators.id_token.enabled"
 	ViperKeyMutatorIDTokenJWKSURL   = "mutators.id_token.config.jwks_url"
+
+	ViperKeyMutatorJWTPayloadIsEnabled = "mutators.jwt_payload.enabled"
+
+	ViperKeyMutatorPASETOIsEnabled = "mutators.paseto.enabled"
 )
 
 // Authenticators
@@ -94,6 +276,57 @@ const (
 	// oauth2_token_introspection
 	ViperKeyAuthenticatorOAuth2TokenIntrospectionIsEnabled = "authenticators.oauth2_introspection.enabled"
 
+	// api_key
+	ViperKeyAuthenticatorAPIKeyIsEnabled = "authenticators.api_key.enabled"
+
+	// aws_iam
+	ViperKeyAuthenticatorAWSIAMIsEnabled = "authenticators.aws_iam.enabled"
+
+	// azure_managed_identity
+	ViperKeyAuthenticatorAzureManagedIdentityIsEnabled = "authenticators.azure_managed_identity.enabled"
+
+	// basic_auth
+	ViperKeyAuthenticatorBasicAuthIsEnabled = "authenticators.basic_auth.enabled"
+
+	// biscuit
+	ViperKeyAuthenticatorBiscuitIsEnabled = "authenticators.biscuit.enabled"
+
+	// ci_oidc
+	ViperKeyAuthenticatorCIOIDCIsEnabled = "authenticators.ci_oidc.enabled"
+
+	// gcp_id_token
+	ViperKeyAuthenticatorGCPIDTokenIsEnabled = "authenticators.gcp_id_token.enabled"
+
+	// hmac
+	ViperKeyAuthenticatorHMACIsEnabled = "authenticators.hmac.enabled"
+
+	// kerberos
+	ViperKeyAuthenticatorKerberosIsEnabled = "authenticators.kerberos.enabled"
+
+	// kubernetes_service_account
+	ViperKeyAuthenticatorKubernetesServiceAccountIsEnabled = "authenticators.kubernetes_service_account.enabled"
+
+	// ldap
+	ViperKeyAuthenticatorLDAPIsEnabled = "authenticators.ldap.enabled"
+
+	// macaroon
+	ViperKeyAuthenticatorMacaroonIsEnabled = "authenticators.macaroon.enabled"
+
+	// mtls
+	ViperKeyAuthenticatorMTLSIsEnabled = "authenticators.mtls.enabled"
+
+	// remote
+	ViperKeyAuthenticatorRemoteIsEnabled = "authenticators.remote.enabled"
+
+	// saml
+	ViperKeyAuthenticatorSAMLIsEnabled = "authenticators.saml.enabled"
+
+	// paseto
+	ViperKeyAuthenticatorPASETOIsEnabled = "authenticators.paseto.enabled"
+
+	// token_exchange
+	ViperKeyAuthenticatorTokenExchangeIsEnabled = "authenticators.token_exchange.enabled"
+
 	// unauthorized
 	ViperKeyAuthenticatorUnauthorizedIsEnabled = "authenticators.unauthorized.enabled"
 )
@@ -115,6 +348,8 @@ type ViperProvider struct {
 
 	configMutex sync.RWMutex
 	configCache map[uint64]json.RawMessage
+
+	parsedCache *parsedConfigCache
 }
 
 func NewViperProvider(l logrus.FieldLogger) *ViperProvider {
@@ -122,6 +357,7 @@ func NewViperProvider(l logrus.FieldLogger) *ViperProvider {
 		l:            l,
 		enabledCache: make(map[uint64]bool),
 		configCache:  make(map[uint64]json.RawMessage),
+		parsedCache:  newParsedConfigCache(),
 	}
 }
 
@@ -135,11 +371,577 @@ func (v *ViperProvider) AccessRuleRepositories() []url.URL {
 	return repositories
 }
 
+// AccessRuleInline returns the access rules defined directly in the configuration as a JSON array
+// or nil if no such rules are defined.
+func (v *ViperProvider) AccessRuleInline() json.RawMessage {
+	rules, ok := viper.Get(ViperKeyAccessRuleInline).([]interface{})
+	if !ok || len(rules) == 0 {
+		return nil
+	}
+
+	out, err := json.Marshal(toJSONCompatible(rules))
+	if err != nil {
+		v.l.WithError(err).Errorf(`Configuration key "%s" is malformed.`, ViperKeyAccessRuleInline)
+		return nil
+	}
+
+	return out
+}
+
+// toJSONCompatible converts the map[interface{}]interface{} values produced by the YAML decoder
+// to map[string]interface{} so that they can be encoded as JSON.
+func toJSONCompatible(value interface{}) interface{} {
+	switch t := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			out[fmt.Sprintf("%v", k)] = toJSONCompatible(v)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			out[k] = toJSONCompatible(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for k, v := range t {
+			out[k] = toJSONCompatible(v)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
 // AccessRuleMatchingStrategy returns current MatchingStrategy.
 func (v *ViperProvider) AccessRuleMatchingStrategy() MatchingStrategy {
 	return MatchingStrategy(viperx.GetString(v.l, ViperKeyAccessRuleMatchingStrategy, ""))
 }
 
+// AccessRuleStagingIsEnabled returns true if fetched access rules must be staged before they are activated.
+func (v *ViperProvider) AccessRuleStagingIsEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyAccessRuleStagingIsEnabled, false)
+}
+
+// AccessRuleNamespace returns the namespace prefixed to the IDs of the access rules of the repository or an empty
+// string if the IDs are used as is.
+func (v *ViperProvider) AccessRuleNamespace(repository url.URL) string {
+	namespaces, ok := viper.Get(ViperKeyAccessRuleNamespaces).([]interface{})
+	if !ok {
+		return ""
+	}
+
+	for _, n := range namespaces {
+		n, ok := toJSONCompatible(n).(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		source, _ := n["repository"].(string)
+		namespace, _ := n["namespace"].(string)
+		if u, err := url.Parse(source); err == nil && cleanRepository(*u) == cleanRepository(repository) {
+			return namespace
+		}
+	}
+	return ""
+}
+
+// cleanRepository returns the URL of the repository with the path of local files cleaned, so that
+// "file://./rules.json" and "file://rules.json" are the same repository.
+func cleanRepository(repository url.URL) string {
+	if repository.Scheme == "file" {
+		return "file://" + filepath.Clean(strings.TrimPrefix(repository.String(), "file://"))
+	}
+	return repository.String()
+}
+
+// AccessRuleDuplicateIDs returns how access rules sharing an ID are handled, either by logging a warning (the
+// default) or by rejecting the access rules of the repository introducing the duplicate.
+func (v *ViperProvider) AccessRuleDuplicateIDs() string {
+	return viperx.GetString(v.l, ViperKeyAccessRuleDuplicateIDs, DuplicateRuleIDsWarn)
+}
+
+// AccessRuleExpiredRules returns how access rules past their expiry date are handled, either by logging a warning
+// (the default) or by no longer matching requests.
+func (v *ViperProvider) AccessRuleExpiredRules() string {
+	return viperx.GetString(v.l, ViperKeyAccessRuleExpiredRules, ExpiredRulesWarn)
+}
+
+// AccessRuleNATSURL returns the URL of the NATS server to subscribe to for access rule change notifications
+// or nil if notifications are disabled.
+func (v *ViperProvider) AccessRuleNATSURL() *url.URL {
+	value := viperx.GetString(v.l, ViperKeyAccessRuleNATSURL, "")
+	if value == "" {
+		return nil
+	}
+	return v.getURL(value, ViperKeyAccessRuleNATSURL)
+}
+
+// AccessRuleNATSSubject returns the NATS subject to subscribe to for access rule change notifications.
+func (v *ViperProvider) AccessRuleNATSSubject() string {
+	return viperx.GetString(v.l, ViperKeyAccessRuleNATSSubject, "oathkeeper.access_rules.changed")
+}
+
+// AccessRuleVerificationJWKSURLs returns the URLs of the JSON Web Key Sets verifying the signatures of access rule
+// files. If none are configured, signatures are not verified.
+func (v *ViperProvider) AccessRuleVerificationJWKSURLs() []url.URL {
+	sources := viperx.GetStringSlice(v.l, ViperKeyAccessRuleVerificationJWKS, []string{})
+	locations := make([]url.URL, len(sources))
+	for k, source := range sources {
+		locations[k] = *urlx.ParseOrFatal(v.l, source)
+	}
+	return locations
+}
+
+// DecisionSigningIsEnabled returns true if the decisions API responds with a signed decision JSON Web Token.
+func (v *ViperProvider) DecisionSigningIsEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyDecisionSigningIsEnabled, false)
+}
+
+// DecisionSigningJWKSURL returns the location of the JSON Web Key Set used to sign decisions or nil if it is not set.
+func (v *ViperProvider) DecisionSigningJWKSURL() *url.URL {
+	value := viperx.GetString(v.l, ViperKeyDecisionSigningJWKSURL, "")
+	if value == "" {
+		return nil
+	}
+	return v.getURL(value, ViperKeyDecisionSigningJWKSURL)
+}
+
+// DecisionSigningIssuer returns the issuer of signed decisions.
+func (v *ViperProvider) DecisionSigningIssuer() string {
+	return viperx.GetString(v.l, ViperKeyDecisionSigningIssuer, "")
+}
+
+// DecisionSigningTTL returns how long signed decisions are valid.
+func (v *ViperProvider) DecisionSigningTTL() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyDecisionSigningTTL, time.Second*10)
+}
+
+// DecisionSigningHeader returns the name of the response header carrying the signed decision.
+func (v *ViperProvider) DecisionSigningHeader() string {
+	return viperx.GetString(v.l, ViperKeyDecisionSigningHeader, "X-Oathkeeper-Decision")
+}
+
+// RevocationIsEnabled returns true if back-channel logout tokens and security events revoking sessions are accepted.
+func (v *ViperProvider) RevocationIsEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyRevocationIsEnabled, false)
+}
+
+// RevocationJWKSURLs returns the locations of the JSON Web Key Sets used to verify revocation tokens.
+func (v *ViperProvider) RevocationJWKSURLs() []string {
+	return viperx.GetStringSlice(v.l, ViperKeyRevocationJWKSURLs, []string{})
+}
+
+// RevocationTrustedIssuers returns the issuers revocation tokens are accepted from.
+func (v *ViperProvider) RevocationTrustedIssuers() []string {
+	return viperx.GetStringSlice(v.l, ViperKeyRevocationTrustedIssuers, []string{})
+}
+
+// RevocationTargetAudience returns the audiences revocation tokens must be intended for.
+func (v *ViperProvider) RevocationTargetAudience() []string {
+	return viperx.GetStringSlice(v.l, ViperKeyRevocationTargetAudience, []string{})
+}
+
+// RevocationAllowedAlgorithms returns the algorithms revocation tokens may be signed with.
+func (v *ViperProvider) RevocationAllowedAlgorithms() []string {
+	return viperx.GetStringSlice(v.l, ViperKeyRevocationAllowedAlgorithms, []string{"RS256"})
+}
+
+// RevocationTTL returns how long revoked subjects and sessions are remembered.
+func (v *ViperProvider) RevocationTTL() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyRevocationTTL, time.Hour*24)
+}
+
+// FIPSIsEnabled returns true if only FIPS approved algorithms, cipher suites and TLS versions may be used. The policy
+// is always enforced if ORY Oathkeeper was built with the "fips" build tag.
+func (v *ViperProvider) FIPSIsEnabled() bool {
+	return fips.BuildEnabled || viperx.GetBool(v.l, ViperKeyFIPSIsEnabled, false)
+}
+
+// StrictModeIsEnabled returns true if access rules and handler configurations containing fields which are not known
+// to ORY Oathkeeper, for example misspelled ones, must be rejected instead of the fields being ignored.
+func (v *ViperProvider) StrictModeIsEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyStrictModeIsEnabled, false)
+}
+
+// RiskIsEnabled returns true if rules may score the risk of authenticated requests.
+func (v *ViperProvider) RiskIsEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyRiskIsEnabled, false)
+}
+
+// RiskRemote returns the URL of the risk engine.
+func (v *ViperProvider) RiskRemote() string {
+	return viperx.GetString(v.l, ViperKeyRiskRemote, "")
+}
+
+// RiskGeoHeaders returns the request headers, keyed by the name of the geo signal, carrying the location of the
+// client as determined by a CDN or load balancer.
+func (v *ViperProvider) RiskGeoHeaders() map[string]string {
+	return viper.GetStringMapString(ViperKeyRiskGeoHeaders)
+}
+
+// RiskDenyAbove returns the risk score above which requests are denied.
+func (v *ViperProvider) RiskDenyAbove() float64 {
+	return viperx.GetFloat64(v.l, ViperKeyRiskDenyAbove, 1)
+}
+
+// RiskStepUpAbove returns the risk score above which step-up authentication is required.
+func (v *ViperProvider) RiskStepUpAbove() float64 {
+	return viperx.GetFloat64(v.l, ViperKeyRiskStepUpAbove, 1)
+}
+
+// RiskClaim returns the key of the session's extra field the risk score is added to.
+func (v *ViperProvider) RiskClaim() string {
+	return viperx.GetString(v.l, ViperKeyRiskClaim, "risk_score")
+}
+
+// RiskFailOpen returns true if requests are accepted when the risk engine is unavailable.
+func (v *ViperProvider) RiskFailOpen() bool {
+	return viperx.GetBool(v.l, ViperKeyRiskFailOpen, false)
+}
+
+// LockoutIsEnabled returns true if failed authentication attempts are tracked.
+func (v *ViperProvider) LockoutIsEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyLockoutIsEnabled, false)
+}
+
+// LockoutKeys returns the properties of a request ("ip", "token_prefix", "subject") failed authentication attempts
+// are counted by.
+func (v *ViperProvider) LockoutKeys() []string {
+	return viperx.GetStringSlice(v.l, ViperKeyLockoutKeys, []string{"ip", "subject"})
+}
+
+// LockoutTokenPrefixLength returns how many characters of a token identify it when counting failed attempts by
+// token prefix.
+func (v *ViperProvider) LockoutTokenPrefixLength() int {
+	return viperx.GetInt(v.l, ViperKeyLockoutTokenPrefixLength, 8)
+}
+
+// LockoutWindow returns for how long failed authentication attempts are counted.
+func (v *ViperProvider) LockoutWindow() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyLockoutWindow, time.Minute*10)
+}
+
+// LockoutDelayAfter returns the number of failed attempts after which requests are delayed.
+func (v *ViperProvider) LockoutDelayAfter() int {
+	return viperx.GetInt(v.l, ViperKeyLockoutDelayAfter, 3)
+}
+
+// LockoutDelay returns the initial delay, it doubles with every further failed attempt.
+func (v *ViperProvider) LockoutDelay() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyLockoutDelay, time.Second)
+}
+
+// LockoutMaxDelay returns the maximum delay.
+func (v *ViperProvider) LockoutMaxDelay() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyLockoutMaxDelay, time.Second*10)
+}
+
+// LockoutLockAfter returns the number of failed attempts after which requests are rejected.
+func (v *ViperProvider) LockoutLockAfter() int {
+	return viperx.GetInt(v.l, ViperKeyLockoutLockAfter, 10)
+}
+
+// LockoutLockDuration returns for how long requests are rejected once the lock threshold was reached.
+func (v *ViperProvider) LockoutLockDuration() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyLockoutLockDuration, time.Minute*15)
+}
+
+// OverloadIsEnabled returns true if requests are shed while the service is overloaded.
+func (v *ViperProvider) OverloadIsEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyOverloadIsEnabled, false)
+}
+
+// OverloadMaxInFlight returns the number of requests in flight above which the service is overloaded, 0 if
+// unlimited.
+func (v *ViperProvider) OverloadMaxInFlight() int {
+	return viperx.GetInt(v.l, ViperKeyOverloadMaxInFlight, 0)
+}
+
+// OverloadMaxLatency returns the 99th percentile of the latency above which the service is overloaded, 0 if
+// unlimited.
+func (v *ViperProvider) OverloadMaxLatency() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyOverloadMaxLatency, 0)
+}
+
+// OverloadLatencyWindow returns how long latencies are taken into account.
+func (v *ViperProvider) OverloadLatencyWindow() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyOverloadLatencyWindow, time.Second*10)
+}
+
+// OverloadShedPercentage returns the percentage of requests shed while the service is overloaded by the priority
+// ("low", "normal") of their access rule.
+func (v *ViperProvider) OverloadShedPercentage() map[string]int {
+	return map[string]int{
+		"low":    viperx.GetInt(v.l, ViperKeyOverloadShedPercentage+".low", 100),
+		"normal": viperx.GetInt(v.l, ViperKeyOverloadShedPercentage+".normal", 0),
+	}
+}
+
+// OverloadMaxInFlightPerPriority returns the number of requests in flight above which requests are rejected by the
+// priority ("low", "normal", "critical") of their access rule, 0 if unlimited.
+func (v *ViperProvider) OverloadMaxInFlightPerPriority() map[string]int {
+	return map[string]int{
+		"low":      viperx.GetInt(v.l, ViperKeyOverloadMaxInFlightPer+".low", 0),
+		"normal":   viperx.GetInt(v.l, ViperKeyOverloadMaxInFlightPer+".normal", 0),
+		"critical": viperx.GetInt(v.l, ViperKeyOverloadMaxInFlightPer+".critical", 0),
+	}
+}
+
+// OverloadUpstreamLimitIsEnabled returns true if the requests in flight per upstream are limited adaptively.
+func (v *ViperProvider) OverloadUpstreamLimitIsEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyOverloadUpstreamLimitIsEnabled, false)
+}
+
+// OverloadUpstreamLimitInitial returns the concurrency limit of an upstream before any of its requests completed.
+func (v *ViperProvider) OverloadUpstreamLimitInitial() int {
+	return viperx.GetInt(v.l, ViperKeyOverloadUpstreamLimitInitial, 20)
+}
+
+// OverloadUpstreamLimitMin returns the lower bound of the concurrency limit of an upstream.
+func (v *ViperProvider) OverloadUpstreamLimitMin() int {
+	return viperx.GetInt(v.l, ViperKeyOverloadUpstreamLimitMin, 1)
+}
+
+// OverloadUpstreamLimitMax returns the upper bound of the concurrency limit of an upstream.
+func (v *ViperProvider) OverloadUpstreamLimitMax() int {
+	return viperx.GetInt(v.l, ViperKeyOverloadUpstreamLimitMax, 1000)
+}
+
+// OverloadUpstreamLimitLatencyTolerance returns the factor by which the latency of a request may exceed the baseline
+// latency of its upstream before the concurrency limit is decreased.
+func (v *ViperProvider) OverloadUpstreamLimitLatencyTolerance() float64 {
+	return viperx.GetFloat64(v.l, ViperKeyOverloadUpstreamLimitLatencyTolerance, 2)
+}
+
+// OverloadUpstreamLimitBackoff returns the factor the concurrency limit of an upstream is multiplied with when it is
+// decreased.
+func (v *ViperProvider) OverloadUpstreamLimitBackoff() float64 {
+	return viperx.GetFloat64(v.l, ViperKeyOverloadUpstreamLimitBackoff, 0.9)
+}
+
+// HoneypotWebhookURL returns the URL alerts of honeypot rules are sent to.
+func (v *ViperProvider) HoneypotWebhookURL() string {
+	return viperx.GetString(v.l, ViperKeyHoneypotWebhookURL, "")
+}
+
+// HoneypotWebhookFormat returns the format ("json" or "slack") of alerts of honeypot rules.
+func (v *ViperProvider) HoneypotWebhookFormat() string {
+	return viperx.GetString(v.l, ViperKeyHoneypotWebhookFormat, "json")
+}
+
+// AdminUIIsEnabled returns true if the admin UI is served by the API.
+func (v *ViperProvider) AdminUIIsEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyAdminUIIsEnabled, false)
+}
+
+// Webhooks returns the webhooks notifications are sent to.
+func (v *ViperProvider) Webhooks() []Webhook {
+	value, ok := viper.Get(ViperKeyWebhooks).([]interface{})
+	if !ok || len(value) == 0 {
+		return nil
+	}
+
+	var webhooks []Webhook
+	if err := jsonRoundTrip(toJSONCompatible(value), &webhooks); err != nil {
+		v.l.WithError(err).Errorf(`Configuration key "%s" is malformed.`, ViperKeyWebhooks)
+		return nil
+	}
+
+	return webhooks
+}
+
+// WebhookThresholdWindow returns the window over which the deny and error rates of access rules are computed.
+func (v *ViperProvider) WebhookThresholdWindow() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyWebhookThresholdWindow, time.Minute*5)
+}
+
+// WebhookThresholdMinRequests returns the number of requests an access rule must receive within the window before
+// its rates are compared to the thresholds.
+func (v *ViperProvider) WebhookThresholdMinRequests() int {
+	return viperx.GetInt(v.l, ViperKeyWebhookThresholdMinRequests, 20)
+}
+
+// WebhookThresholdDenyRate returns the share of denied requests of an access rule which triggers a notification,
+// 0 disables it.
+func (v *ViperProvider) WebhookThresholdDenyRate() float64 {
+	return viperx.GetFloat64(v.l, ViperKeyWebhookThresholdDenyRate, 0)
+}
+
+// WebhookThresholdErrorRate returns the share of requests of an access rule failing with a 5xx status code which
+// triggers a notification, 0 disables it.
+func (v *ViperProvider) WebhookThresholdErrorRate() float64 {
+	return viperx.GetFloat64(v.l, ViperKeyWebhookThresholdErrorRate, 0)
+}
+
+// DecisionLogOTLPEndpoint returns the OTLP/HTTP logs endpoint decision records are exported to, for example
+// "http://otel-collector:4318/v1/logs". Decision records are not exported if it is empty.
+func (v *ViperProvider) DecisionLogOTLPEndpoint() string {
+	return viperx.GetString(v.l, ViperKeyDecisionLogOTLPEndpoint, "")
+}
+
+// DecisionLogOTLPHeaders returns the headers sent with every export request, for example to authenticate at the
+// backend.
+func (v *ViperProvider) DecisionLogOTLPHeaders() map[string]string {
+	return viper.GetStringMapString(ViperKeyDecisionLogOTLPHeaders)
+}
+
+// DecisionLogOTLPBatchSize returns the maximum number of decision records exported with one request.
+func (v *ViperProvider) DecisionLogOTLPBatchSize() int {
+	return viperx.GetInt(v.l, ViperKeyDecisionLogOTLPBatchSize, 512)
+}
+
+// DecisionLogOTLPFlushInterval returns how long decision records are batched at most before they are exported.
+func (v *ViperProvider) DecisionLogOTLPFlushInterval() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyDecisionLogOTLPFlushInterval, time.Second*5)
+}
+
+// ExplanationsSampleRate returns the fraction of requests whose pipeline is traced to explain denials.
+func (v *ViperProvider) ExplanationsSampleRate() float64 {
+	return viperx.GetFloat64(v.l, ViperKeyExplanationsSampleRate, 0)
+}
+
+// ExplanationsStore returns where explanations are stored, "memory" or "file".
+func (v *ViperProvider) ExplanationsStore() string {
+	return viperx.GetString(v.l, ViperKeyExplanationsStore, "memory")
+}
+
+// ExplanationsSize returns the number of explanations kept in memory.
+func (v *ViperProvider) ExplanationsSize() int {
+	return viperx.GetInt(v.l, ViperKeyExplanationsSize, 1000)
+}
+
+// ExplanationsFile returns the file explanations are appended to.
+func (v *ViperProvider) ExplanationsFile() string {
+	return viperx.GetString(v.l, ViperKeyExplanationsFile, "")
+}
+
+// StatsDAddress returns the UDP address ("host:port") of the StatsD server metrics are sent to. Metrics are not sent
+// if it is empty.
+func (v *ViperProvider) StatsDAddress() string {
+	return viperx.GetString(v.l, ViperKeyStatsDAddress, "")
+}
+
+// StatsDPrefix returns the prefix of the names of all metrics.
+func (v *ViperProvider) StatsDPrefix() string {
+	return viperx.GetString(v.l, ViperKeyStatsDPrefix, "oathkeeper.")
+}
+
+// StatsDTagFormat returns how tags are appended to metrics ("datadog", "influxdb" or "none").
+func (v *ViperProvider) StatsDTagFormat() string {
+	return viperx.GetString(v.l, ViperKeyStatsDTagFormat, "datadog")
+}
+
+// StatsDTags returns the tags ("key:value") added to all metrics.
+func (v *ViperProvider) StatsDTags() []string {
+	return viperx.GetStringSlice(v.l, ViperKeyStatsDTags, []string{})
+}
+
+// StatsDRuleLabels returns the keys of the access rule labels which are added as tags to the metrics of requests.
+func (v *ViperProvider) StatsDRuleLabels() []string {
+	return viperx.GetStringSlice(v.l, ViperKeyStatsDRuleLabels, []string{})
+}
+
+// StatsDFlushInterval returns how long metrics are buffered at most before they are sent.
+func (v *ViperProvider) StatsDFlushInterval() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyStatsDFlushInterval, time.Second)
+}
+
+// SLOWindows returns the windows over which the service level indicators and burn rates of access rules are
+// computed.
+func (v *ViperProvider) SLOWindows() []time.Duration {
+	var windows []time.Duration
+	for _, value := range viperx.GetStringSlice(v.l, ViperKeySLOWindows, []string{"5m", "1h", "6h"}) {
+		window, err := time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			v.l.WithError(err).Errorf(`Value "%s" of configuration key "%s" is not a valid duration.`, value, ViperKeySLOWindows)
+			continue
+		}
+		windows = append(windows, window)
+	}
+	return windows
+}
+
+// RemoteResponseMaxBodySize returns the maximum size in bytes of response bodies read from remote services such as
+// token introspection, session check, hydrator and authorizer endpoints. Zero disables the limit.
+func (v *ViperProvider) RemoteResponseMaxBodySize() int64 {
+	return int64(viperx.GetInt(v.l, ViperKeyRemoteResponseMaxBodySize, 1<<20))
+}
+
+// RemoteResponseTimeout returns how long pipeline handlers wait for remote services to respond, including reading the
+// response body. Zero disables the timeout.
+func (v *ViperProvider) RemoteResponseTimeout() time.Duration {
+	return viperx.GetDuration(v.l, ViperKeyRemoteResponseTimeout, time.Second*10)
+}
+
+// TrustedProxies returns the networks of the proxies whose forwarding headers are used to derive the client's IP
+// address. The loopback and private networks are trusted by default.
+func (v *ViperProvider) TrustedProxies() []*net.IPNet {
+	trusted, invalid := x.ParseTrustedProxies(viperx.GetStringSlice(v.l, ViperKeyTrustedProxies, []string{
+		"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7",
+	}))
+	for _, network := range invalid {
+		v.l.Errorf(`Value "%s" of configuration key "%s" is neither an IP address nor a CIDR range and is ignored.`, network, ViperKeyTrustedProxies)
+	}
+	return trusted
+}
+
+// HostOverrides returns the addresses outgoing connections to a hostname, or a hostname and port, are made to instead
+// of resolving the hostname.
+func (v *ViperProvider) HostOverrides() map[string]string {
+	return viper.GetStringMapString(ViperKeyHosts)
+}
+
+// HealthDependencyWeight returns whether the dependency is required for readiness ("hard") or only marks the instance
+// as degraded if it is unhealthy ("soft").
+func (v *ViperProvider) HealthDependencyWeight(dependency string) string {
+	if weight := viperx.GetString(v.l, ViperKeyHealthDependencies+"."+dependency, ""); weight != "" {
+		return weight
+	}
+	if weight, ok := defaultHealthDependencyWeights[dependency]; ok {
+		return weight
+	}
+	return "soft"
+}
+
+// JSONWebKeySetTLSConfig returns the TLS settings used to fetch JSON Web Key Sets from the host ("hostname" or
+// "hostname:port"), or nil if the defaults apply.
+func (v *ViperProvider) JSONWebKeySetTLSConfig(host string) *x.TLSClientConfig {
+	value, ok := viper.Get(ViperKeyJWKSTLS).([]interface{})
+	if !ok || len(value) == 0 {
+		return nil
+	}
+
+	var configs []JSONWebKeySetTLS
+	if err := jsonRoundTrip(toJSONCompatible(value), &configs); err != nil {
+		v.l.WithError(err).Errorf(`Configuration key "%s" is malformed.`, ViperKeyJWKSTLS)
+		return nil
+	}
+
+	for k := range configs {
+		if configs[k].Matches(host) {
+			if configs[k].InsecureSkipVerify {
+				v.l.Warnf(`TLS certificate verification of JSON Web Key Sets fetched from "%s" is disabled. This is insecure and must never be used in production.`, host)
+			}
+			return &configs[k].TLSClientConfig
+		}
+	}
+	return nil
+}
+
+// RedactionHeaders returns the headers whose values are redacted in addition to the default ones.
+func (v *ViperProvider) RedactionHeaders() []string {
+	return viperx.GetStringSlice(v.l, ViperKeyRedactionHeaders, []string{})
+}
+
+// RedactionPatterns returns the regular expressions matching secrets which are redacted in addition to the default
+// ones.
+func (v *ViperProvider) RedactionPatterns() []string {
+	return viperx.GetStringSlice(v.l, ViperKeyRedactionPatterns, []string{})
+}
+
 func (v *ViperProvider) CORSEnabled(iface string) bool {
 	return corsx.IsEnabled(v.l, "serve."+iface)
 }
@@ -168,6 +970,137 @@ func (v *ViperProvider) ProxyServeAddress() string {
 	)
 }
 
+// ProxyListeners returns the additional proxy listeners.
+func (v *ViperProvider) ProxyListeners() []ProxyListener {
+	value, ok := viper.Get(ViperKeyProxyListeners).([]interface{})
+	if !ok || len(value) == 0 {
+		return nil
+	}
+
+	var listeners []ProxyListener
+	if err := jsonRoundTrip(toJSONCompatible(value), &listeners); err != nil {
+		v.l.WithError(err).Errorf(`Configuration key "%s" is malformed.`, ViperKeyProxyListeners)
+		return nil
+	}
+
+	return listeners
+}
+
+// ServeTLS returns the TLS configuration of the "proxy" or the "api" listener.
+func (v *ViperProvider) ServeTLS(iface string) ListenerTLS {
+	prefix := "serve." + iface + ".tls."
+	source := func(key string) ListenerTLSSource {
+		return ListenerTLSSource{
+			Path:   viperx.GetString(v.l, prefix+key+".path", ""),
+			Base64: viperx.GetString(v.l, prefix+key+".base64", ""),
+		}
+	}
+
+	return ListenerTLS{
+		Key:              source("key"),
+		Cert:             source("cert"),
+		MinVersion:       viperx.GetString(v.l, prefix+"min_version", ""),
+		MaxVersion:       viperx.GetString(v.l, prefix+"max_version", ""),
+		CipherSuites:     viperx.GetStringSlice(v.l, prefix+"cipher_suites", []string{}),
+		CurvePreferences: viperx.GetStringSlice(v.l, prefix+"curve_preferences", []string{}),
+		ClientAuth: ListenerTLSClientAuth{
+			Mode: viperx.GetString(v.l, prefix+"client_auth.mode", "none"),
+			CA:   source("client_auth.ca"),
+			Revocation: ListenerTLSRevocation{
+				Enabled:  viperx.GetBool(v.l, prefix+"client_auth.revocation.enabled", false),
+				Policy:   viperx.GetString(v.l, prefix+"client_auth.revocation.policy", "soft_fail"),
+				Methods:  viperx.GetStringSlice(v.l, prefix+"client_auth.revocation.methods", []string{}),
+				CacheTTL: viperx.GetString(v.l, prefix+"client_auth.revocation.cache_ttl", "1h"),
+			},
+		},
+		OCSPStapling: viperx.GetBool(v.l, prefix+"ocsp_stapling", false),
+	}
+}
+
+// ProxySecurityHeaders returns the security headers configuration of the given proxy listener.
+func (v *ViperProvider) ProxySecurityHeaders(listener string) ListenerSecurityHeaders {
+	if listener != DefaultProxyListener {
+		for _, l := range v.ProxyListeners() {
+			if l.Name == listener {
+				return l.SecurityHeaders
+			}
+		}
+		return ListenerSecurityHeaders{}
+	}
+
+	prefix := ViperKeyProxySecurityHeaders + "."
+	return ListenerSecurityHeaders{
+		Profile:                 viperx.GetString(v.l, prefix+"profile", SecurityHeadersProfileNone),
+		StrictTransportSecurity: viperx.GetString(v.l, prefix+"strict_transport_security", ""),
+		ContentTypeOptions:      viperx.GetString(v.l, prefix+"content_type_options", ""),
+		ReferrerPolicy:          viperx.GetString(v.l, prefix+"referrer_policy", ""),
+		FrameOptions:            viperx.GetString(v.l, prefix+"frame_options", ""),
+	}
+}
+
+// ProxyHTTP3 returns the HTTP/3 configuration of the given proxy listener.
+func (v *ViperProvider) ProxyHTTP3(listener string) ListenerHTTP3 {
+	if listener != DefaultProxyListener {
+		for _, l := range v.ProxyListeners() {
+			if l.Name == listener {
+				return l.HTTP3
+			}
+		}
+		return ListenerHTTP3{}
+	}
+
+	return ListenerHTTP3{
+		Enabled: viperx.GetBool(v.l, ViperKeyProxyHTTP3IsEnabled, false),
+	}
+}
+
+// ProxyForwardedMode returns how the Forwarded header sent to upstreams is generated, one of "append", "replace",
+// and "off".
+func (v *ViperProvider) ProxyForwardedMode() string {
+	return viperx.GetString(v.l, ViperKeyProxyForwardedMode, ForwardedModeAppend)
+}
+
+// ProxyForwardedBy returns the "by" parameter of the Forwarded header sent to upstreams. If empty, the address of
+// the listener which received the request is used.
+func (v *ViperProvider) ProxyForwardedBy() string {
+	return viperx.GetString(v.l, ViperKeyProxyForwardedBy, "")
+}
+
+// ProxyHealthEnabled returns true if the health checks are served by the proxy listeners.
+func (v *ViperProvider) ProxyHealthEnabled() bool {
+	return viperx.GetBool(v.l, ViperKeyProxyHealthEnabled, false)
+}
+
+// ProxyHealthAlivePath returns the path of the alive check served by the proxy listeners. Requests to it are not
+// matched against access rules.
+func (v *ViperProvider) ProxyHealthAlivePath() string {
+	return viperx.GetString(v.l, ViperKeyProxyHealthAlivePath, "/health/alive")
+}
+
+// ProxyHealthReadyPath returns the path of the readiness check served by the proxy listeners. Requests to it are not
+// matched against access rules.
+func (v *ViperProvider) ProxyHealthReadyPath() string {
+	return viperx.GetString(v.l, ViperKeyProxyHealthReadyPath, "/health/ready")
+}
+
+// ErrorHandlerFallbackSpecificityFor returns the fallback error handlers for the given proxy listener.
+func (v *ViperProvider) ErrorHandlerFallbackSpecificityFor(listener string) []string {
+	for _, l := range v.ProxyListeners() {
+		if l.Name == listener && len(l.Errors.Fallback) > 0 {
+			return l.Errors.Fallback
+		}
+	}
+	return v.ErrorHandlerFallbackSpecificity()
+}
+
+func jsonRoundTrip(in interface{}, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(json.Unmarshal(b, out))
+}
+
 func (v *ViperProvider) APIServeAddress() string {
 	return fmt.Sprintf(
 		"%s:%d",
@@ -262,6 +1195,10 @@ func (v *ViperProvider) PipelineConfig(prefix, id string, override json.RawMessa
 		return errors.WithStack(err)
 	}
 
+	if v.parsedCache.load(hash, dest) {
+		return nil
+	}
+
 	v.configMutex.RLock()
 	c, ok := v.configCache[hash]
 	v.configMutex.RUnlock()
@@ -271,6 +1208,7 @@ func (v *ViperProvider) PipelineConfig(prefix, id string, override json.RawMessa
 			if err := json.NewDecoder(bytes.NewBuffer(c)).Decode(dest); err != nil {
 				return errors.WithStack(err)
 			}
+			v.parsedCache.store(hash, dest)
 		}
 
 		return nil
@@ -302,6 +1240,15 @@ func (v *ViperProvider) PipelineConfig(prefix, id string, override json.RawMessa
 		if err := json.NewDecoder(bytes.NewBuffer(marshalled)).Decode(dest); err != nil {
 			return errors.WithStack(err)
 		}
+
+		if v.StrictModeIsEnabled() {
+			unknown, err := x.UnknownFields(marshalled, dest)
+			if err != nil {
+				return err
+			} else if len(unknown) > 0 {
+				return errors.Errorf(`strict mode is enabled but the configuration of %s "%s" contains unknown fields: config.%s`, prefix, id, strings.Join(unknown, ", config."))
+			}
+		}
 	}
 
 	rawComponentSchema, err := schemas.Find(fmt.Sprintf("pipeline/%s.%s.schema.json", strings.Split(prefix, ".")[0], id))
@@ -330,13 +1277,33 @@ func (v *ViperProvider) PipelineConfig(prefix, id string, override json.RawMessa
 		return errors.WithStack(result.Errors())
 	}
 
+	// The configuration is cached afterwards, so this is logged once per distinct configuration.
+	if gjson.GetBytes(marshalled, "tls.insecure_skip_verify").Bool() || gjson.GetBytes(marshalled, "api.tls.insecure_skip_verify").Bool() {
+		v.l.Warnf(`TLS certificate verification of %s "%s" is disabled. This is insecure and must never be used in production.`, prefix, id)
+	}
+
 	v.configMutex.Lock()
 	v.configCache[hash] = marshalled
 	v.configMutex.Unlock()
 
+	if dest != nil {
+		v.parsedCache.store(hash, dest)
+	}
+
 	return nil
 }
 
+// ResetPipelineConfigCache drops the validated and decoded configurations of pipeline handlers. Changes to the
+// configuration file already invalidate the cache, this is called whenever access rules are reloaded so that
+// configurations of removed or changed rules do not accumulate.
+func (v *ViperProvider) ResetPipelineConfigCache() {
+	v.configMutex.Lock()
+	v.configCache = make(map[uint64]json.RawMessage)
+	v.configMutex.Unlock()
+
+	v.parsedCache.reset()
+}
+
 func (v *ViperProvider) ErrorHandlerConfig(id string, override json.RawMessage, dest interface{}) error {
 	return v.PipelineConfig(ViperKeyErrors, id, override, dest)
 }
@@ -374,7 +1341,13 @@ func (v *ViperProvider) MutatorConfig(id string, override json.RawMessage, dest
 }
 
 func (v *ViperProvider) JSONWebKeyURLs() []string {
-	return viperx.GetStringSlice(v.l, ViperKeyMutatorIDTokenJWKSURL, []string{})
+	urls := viperx.GetStringSlice(v.l, ViperKeyMutatorIDTokenJWKSURL, []string{})
+	if v.DecisionSigningIsEnabled() {
+		if u := viperx.GetString(v.l, ViperKeyDecisionSigningJWKSURL, ""); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
 }
 
 func (v *ViperProvider) TracingServiceName() string {
@@ -21,6 +21,8 @@
 package configuration
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -32,3 +34,39 @@ func TestGetURL(t *testing.T) {
 	assert.Nil(t, v.getURL("", "key"))
 	assert.Nil(t, v.getURL("a", "key"))
 }
+
+func TestDeepcopyValue(t *testing.T) {
+	type nested struct {
+		Values []string
+	}
+	type config struct {
+		Name    string
+		Raw     json.RawMessage
+		Nested  *nested
+		Extra   map[string]interface{}
+		Headers map[string][]string
+		hidden  int
+	}
+
+	original := config{
+		Name:    "foo",
+		Raw:     json.RawMessage(`{"a":1}`),
+		Nested:  &nested{Values: []string{"a"}},
+		Extra:   map[string]interface{}{"list": []interface{}{"b"}},
+		Headers: map[string][]string{"X": {"c"}},
+		hidden:  1,
+	}
+
+	c := deepcopyValue(reflect.ValueOf(original)).Interface().(config)
+	assert.Equal(t, original, c)
+
+	c.Raw[0] = '['
+	c.Nested.Values[0] = "changed"
+	c.Extra["list"].([]interface{})[0] = "changed"
+	c.Headers["X"][0] = "changed"
+
+	assert.Equal(t, `{"a":1}`, string(original.Raw))
+	assert.Equal(t, "a", original.Nested.Values[0])
+	assert.Equal(t, "b", original.Extra["list"].([]interface{})[0])
+	assert.Equal(t, "c", original.Headers["X"][0])
+}
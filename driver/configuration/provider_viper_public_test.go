@@ -21,10 +21,10 @@ import (
 	_ "github.com/ory/jsonschema/v3/httploader"
 
 	. "github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
 	"github.com/ory/oathkeeper/pipeline/authn"
 	"github.com/ory/oathkeeper/pipeline/authz"
 	"github.com/ory/oathkeeper/pipeline/mutate"
-	"github.com/ory/oathkeeper/x"
 )
 
 func setup(t *testing.T) *ViperProvider {
@@ -83,6 +83,31 @@ func TestPipelineConfig(t *testing.T) {
 		assert.Equal(t, "15s", dec.Api.Retry.GiveUpAfter)
 	})
 
+	t.Run("case=should return copies of cached configurations", func(t *testing.T) {
+		p := setup(t)
+		override := json.RawMessage(`{"jwks_urls":["http://foo","http://bar"]}`)
+
+		for i := 0; i < 2; i++ {
+			var dec authn.AuthenticatorOAuth2JWTConfiguration
+			require.NoError(t, p.PipelineConfig("authenticators", "jwt", override, &dec))
+			assert.Equal(t, []string{"http://foo", "http://bar"}, dec.JWKSURLs)
+
+			// Modifying a configuration must not modify the cached one.
+			dec.JWKSURLs[0] = "http://modified"
+			dec.JWKSURLs = append(dec.JWKSURLs, "http://appended")
+		}
+
+		p.ResetPipelineConfigCache()
+
+		var dec authn.AuthenticatorOAuth2JWTConfiguration
+		require.NoError(t, p.PipelineConfig("authenticators", "jwt", override, &dec))
+		assert.Equal(t, []string{"http://foo", "http://bar"}, dec.JWKSURLs)
+
+		var raw json.RawMessage
+		require.NoError(t, p.PipelineConfig("authenticators", "jwt", override, &raw))
+		assert.Contains(t, string(raw), "http://foo")
+	})
+
 	t.Run("case=should pass array values", func(t *testing.T) {
 		var dec authn.AuthenticatorOAuth2JWTConfiguration
 		p := setup(t)
@@ -98,6 +123,19 @@ func TestPipelineConfig(t *testing.T) {
 			dec.JWKSURLs,
 		)
 	})
+
+	t.Run("case=should reject unknown fields in strict mode", func(t *testing.T) {
+		var dec authn.AuthenticatorOAuth2JWTConfiguration
+		p := setup(t)
+		viper.Set(ViperKeyStrictModeIsEnabled, true)
+		defer viper.Set(ViperKeyStrictModeIsEnabled, false)
+
+		require.NoError(t, p.PipelineConfig("authenticators", "jwt", json.RawMessage(`{"required_scope":["foo"]}`), &dec))
+
+		err := p.PipelineConfig("authenticators", "jwt", json.RawMessage(`{"required_scopes":["foo"],"token_from":{"headr":"X-Token"}}`), &dec)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `the configuration of authenticators "jwt" contains unknown fields: config.required_scopes, config.token_from.headr`)
+	})
 }
 
 /*
@@ -220,6 +258,23 @@ func TestViperProvider(t *testing.T) {
 				})
 			}
 		})
+
+		t.Run("group=http3", func(t *testing.T) {
+			assert.False(t, p.ProxyHTTP3(DefaultProxyListener).Enabled)
+
+			viper.Set(ViperKeyProxyHTTP3IsEnabled, true)
+			viper.Set(ViperKeyProxyListeners, []interface{}{
+				map[string]interface{}{"name": "mobile", "port": 4458, "http3": map[string]interface{}{"enabled": true}},
+				map[string]interface{}{"name": "internal", "port": 4457},
+			})
+			defer viper.Set(ViperKeyProxyHTTP3IsEnabled, false)
+			defer viper.Set(ViperKeyProxyListeners, nil)
+
+			assert.True(t, p.ProxyHTTP3(DefaultProxyListener).Enabled)
+			assert.True(t, p.ProxyHTTP3("mobile").Enabled)
+			assert.False(t, p.ProxyHTTP3("internal").Enabled)
+			assert.False(t, p.ProxyHTTP3("unknown").Enabled)
+		})
 	})
 
 	t.Run("group=access_rules", func(t *testing.T) {
@@ -287,7 +342,7 @@ func TestViperProvider(t *testing.T) {
 		})
 
 		t.Run("authenticator=oauth2_introspection", func(t *testing.T) {
-			a := authn.NewAuthenticatorOAuth2Introspection(p)
+			a := authn.NewAuthenticatorOAuth2Introspection(p, nil)
 			assert.True(t, p.AuthenticatorIsEnabled(a.GetID()))
 			require.NoError(t, a.Validate(nil))
 
@@ -335,7 +390,7 @@ func TestViperProvider(t *testing.T) {
 		})
 
 		t.Run("authorizer=remote_json", func(t *testing.T) {
-			a := authz.NewAuthorizerRemoteJSON(p)
+			a := authz.NewAuthorizerRemoteJSON(p, nil)
 			assert.True(t, p.AuthorizerIsEnabled(a.GetID()))
 			require.NoError(t, a.Validate(nil))
 
@@ -367,7 +422,7 @@ func TestViperProvider(t *testing.T) {
 		})
 
 		t.Run("mutator=hydrator", func(t *testing.T) {
-			a := mutate.NewMutatorHydrator(p, new(x.TestLoggerProvider))
+			a := mutate.NewMutatorHydrator(p, internal.NewRegistry(p))
 			assert.True(t, p.MutatorIsEnabled(a.GetID()))
 			require.NoError(t, a.Validate(nil))
 		})
@@ -421,7 +476,7 @@ func TestAuthenticatorOAuth2TokenIntrospectionPreAuthorization(t *testing.T) {
 		{enabled: true, id: "a", secret: "b", turl: "https://some-url", err: false},
 	} {
 		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
-			a := authn.NewAuthenticatorOAuth2Introspection(v)
+			a := authn.NewAuthenticatorOAuth2Introspection(v, nil)
 
 			config, err := a.Config(json.RawMessage(fmt.Sprintf(`{
 	"pre_authorization": {
@@ -0,0 +1,146 @@
+package configuration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_CBC_SHA":                  tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":                  tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":               tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":               tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+	"X25519": tls.X25519,
+}
+
+var tlsClientAuthModes = map[string]tls.ClientAuthType{
+	"":        tls.NoClientCert,
+	"none":    tls.NoClientCert,
+	"request": tls.RequestClientCert,
+	"require": tls.RequireAnyClientCert,
+	"verify":  tls.RequireAndVerifyClientCert,
+}
+
+// Config returns the TLS configuration of the listener using the given certificates. Options which are not set
+// keep the defaults of Go.
+func (t *ListenerTLS) Config(certs []tls.Certificate) (*tls.Config, error) {
+	c := &tls.Config{Certificates: certs}
+
+	var ok bool
+	if t.MinVersion != "" {
+		if c.MinVersion, ok = tlsVersions[t.MinVersion]; !ok {
+			return nil, errors.Errorf(`unknown minimum TLS version "%s"`, t.MinVersion)
+		}
+	}
+	if t.MaxVersion != "" {
+		if c.MaxVersion, ok = tlsVersions[t.MaxVersion]; !ok {
+			return nil, errors.Errorf(`unknown maximum TLS version "%s"`, t.MaxVersion)
+		}
+	}
+	if c.MinVersion != 0 && c.MaxVersion != 0 && c.MinVersion > c.MaxVersion {
+		return nil, errors.Errorf(`minimum TLS version "%s" exceeds maximum TLS version "%s"`, t.MinVersion, t.MaxVersion)
+	}
+
+	for _, name := range t.CipherSuites {
+		id, ok := tlsCipherSuites[name]
+		if !ok {
+			return nil, errors.Errorf(`unknown or insecure TLS cipher suite "%s"`, name)
+		}
+		c.CipherSuites = append(c.CipherSuites, id)
+	}
+	if len(c.CipherSuites) > 0 {
+		c.PreferServerCipherSuites = true
+	}
+
+	for _, name := range t.CurvePreferences {
+		id, ok := tlsCurves[name]
+		if !ok {
+			return nil, errors.Errorf(`unknown TLS curve "%s"`, name)
+		}
+		c.CurvePreferences = append(c.CurvePreferences, id)
+	}
+
+	if c.ClientAuth, ok = tlsClientAuthModes[t.ClientAuth.Mode]; !ok {
+		return nil, errors.Errorf(`unknown TLS client authentication mode "%s"`, t.ClientAuth.Mode)
+	}
+
+	if rv := t.ClientAuth.Revocation; rv.Enabled {
+		if c.ClientAuth != tls.RequireAndVerifyClientCert {
+			return nil, errors.New(`revocation checking of client certificates requires the client authentication mode "verify"`)
+		}
+		if policy := rv.PolicyOrDefault(); policy != "soft_fail" && policy != "hard_fail" {
+			return nil, errors.Errorf(`unknown revocation policy "%s"`, rv.Policy)
+		}
+		for _, m := range rv.Methods {
+			if m != "ocsp" && m != "crl" {
+				return nil, errors.Errorf(`unknown revocation method "%s"`, m)
+			}
+		}
+		if rv.CacheTTL != "" {
+			if _, err := time.ParseDuration(rv.CacheTTL); err != nil {
+				return nil, errors.Wrapf(err, `unable to parse revocation cache TTL "%s"`, rv.CacheTTL)
+			}
+		}
+	}
+
+	if c.ClientAuth == tls.RequireAndVerifyClientCert {
+		pool, err := t.ClientAuth.CA.certPool()
+		if err != nil {
+			return nil, err
+		}
+		c.ClientCAs = pool
+	}
+
+	return c, nil
+}
+
+func (s *ListenerTLSSource) certPool() (*x509.CertPool, error) {
+	var pem []byte
+	var err error
+	switch {
+	case s.Base64 != "":
+		if pem, err = base64.StdEncoding.DecodeString(s.Base64); err != nil {
+			return nil, errors.Wrap(err, "unable to decode the client certificate authorities")
+		}
+	case s.Path != "":
+		if pem, err = ioutil.ReadFile(s.Path); err != nil {
+			return nil, errors.Wrap(err, "unable to read the client certificate authorities")
+		}
+	default:
+		return nil, errors.New("client certificate authorities must be configured to verify client certificates")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("client certificate authorities do not contain a PEM-encoded certificate")
+	}
+	return pool, nil
+}
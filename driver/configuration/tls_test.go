@@ -0,0 +1,52 @@
+package configuration
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerTLSConfig(t *testing.T) {
+	t.Run("case=defaults", func(t *testing.T) {
+		c, err := new(ListenerTLS).Config(nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, c.MinVersion)
+		assert.Empty(t, c.CipherSuites)
+		assert.Equal(t, tls.NoClientCert, c.ClientAuth)
+	})
+
+	t.Run("case=configured", func(t *testing.T) {
+		c, err := (&ListenerTLS{
+			MinVersion:       "1.2",
+			MaxVersion:       "1.3",
+			CipherSuites:     []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			CurvePreferences: []string{"X25519", "P256"},
+			ClientAuth:       ListenerTLSClientAuth{Mode: "request"},
+		}).Config(nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, tls.VersionTLS12, c.MinVersion)
+		assert.EqualValues(t, tls.VersionTLS13, c.MaxVersion)
+		assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, c.CipherSuites)
+		assert.Equal(t, []tls.CurveID{tls.X25519, tls.CurveP256}, c.CurvePreferences)
+		assert.Equal(t, tls.RequestClientCert, c.ClientAuth)
+	})
+
+	for k, tc := range []ListenerTLS{
+		{MinVersion: "1.4"},
+		{MaxVersion: "3.0"},
+		{MinVersion: "1.3", MaxVersion: "1.2"},
+		{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		{CurvePreferences: []string{"P224"}},
+		{ClientAuth: ListenerTLSClientAuth{Mode: "optional"}},
+		{ClientAuth: ListenerTLSClientAuth{Mode: "verify"}},
+		{ClientAuth: ListenerTLSClientAuth{Mode: "verify", CA: ListenerTLSSource{Base64: "bm90IGEgY2VydGlmaWNhdGU="}}},
+		{ClientAuth: ListenerTLSClientAuth{Mode: "request", Revocation: ListenerTLSRevocation{Enabled: true}}},
+	} {
+		t.Run("case=invalid", func(t *testing.T) {
+			_, err := tc.Config(nil)
+			assert.Error(t, err, "%d", k)
+		})
+	}
+}
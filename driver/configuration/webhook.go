@@ -0,0 +1,25 @@
+package configuration
+
+// Webhook is a webhook notifications are POSTed to, configured at `webhooks.endpoints`.
+type Webhook struct {
+	URL string `json:"url"`
+
+	// Format is either "json" or "slack".
+	Format string `json:"format"`
+
+	// Events are the types of notifications sent to the webhook, all notifications are sent if it is empty.
+	Events []string `json:"events"`
+}
+
+// WantsEvent returns true if notifications of the given type are sent to the webhook.
+func (w Webhook) WantsEvent(t string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
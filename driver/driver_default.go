@@ -4,6 +4,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/redaction"
 )
 
 type DefaultDriver struct {
@@ -20,6 +21,11 @@ func NewDefaultDriver(l logrus.FieldLogger, version, build, date string, validat
 
 	r := NewRegistry(c).WithLogger(l).WithBuildInfo(version, build, date)
 
+	// Secrets are removed from all log entries, including those written by the router and by dependencies.
+	if logger, ok := l.(*logrus.Logger); ok {
+		logger.AddHook(redaction.NewHook(r.Redactor()))
+	}
+
 	return &DefaultDriver{r: r, c: c}
 }
 
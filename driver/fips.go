@@ -0,0 +1,90 @@
+package driver
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/fips"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/pipeline/mutate"
+)
+
+// ValidateFIPS returns an error if the FIPS policy is enforced and the global configuration of an enabled component
+// violates it. Access rules are validated against the policy when they are loaded and signing keys are validated
+// again whenever they are used.
+func ValidateFIPS(ctx context.Context, c configuration.Provider, r Registry) error {
+	if !c.FIPSIsEnabled() {
+		return nil
+	}
+
+	// The global configuration of handlers may be incomplete if access rules complete it, in that case it is
+	// validated together with the access rules.
+	if c.AuthenticatorIsEnabled("jwt") {
+		var jc authn.AuthenticatorOAuth2JWTConfiguration
+		if err := c.AuthenticatorConfig("jwt", nil, &jc); err == nil && len(jc.AllowedAlgorithms) > 0 {
+			if err := fips.ValidateAlgorithms(jc.AllowedAlgorithms); err != nil {
+				return errors.Wrap(err, "authenticator jwt")
+			}
+		}
+	}
+
+	if c.MutatorIsEnabled("id_token") {
+		var mc mutate.CredentialsIDTokenConfig
+		if err := c.MutatorConfig("id_token", nil, &mc); err == nil && mc.JWKSURL != "" {
+			u, err := url.Parse(mc.JWKSURL)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if err := validateFIPSSigningKeys(ctx, r, u); err != nil {
+				return errors.Wrap(err, "mutator id_token")
+			}
+		}
+	}
+
+	if c.DecisionSigningIsEnabled() && c.DecisionSigningJWKSURL() != nil {
+		if err := validateFIPSSigningKeys(ctx, r, c.DecisionSigningJWKSURL()); err != nil {
+			return errors.Wrap(err, "decision signing")
+		}
+	}
+
+	// QUIC uses its own TLS implementation instead of the validated one of the Go distribution.
+	if c.ProxyHTTP3(configuration.DefaultProxyListener).Enabled {
+		return errors.Errorf("proxy listener %s: HTTP/3 is not supported", configuration.DefaultProxyListener)
+	}
+	for _, l := range c.ProxyListeners() {
+		if l.HTTP3.Enabled {
+			return errors.Errorf("proxy listener %s: HTTP/3 is not supported", l.Name)
+		}
+	}
+
+	if c.RevocationIsEnabled() {
+		if err := fips.ValidateAlgorithms(c.RevocationAllowedAlgorithms()); err != nil {
+			return errors.Wrap(err, "revocation")
+		}
+	}
+
+	return nil
+}
+
+func validateFIPSSigningKeys(ctx context.Context, r Registry, location *url.URL) error {
+	sets, err := r.CredentialsFetcher().ResolveSets(ctx, []url.URL{*location})
+	if err != nil {
+		return err
+	}
+
+	for _, set := range sets {
+		for _, key := range set.Keys {
+			if key.IsPublic() {
+				continue
+			}
+			if err := fips.ValidateKey(&key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
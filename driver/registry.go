@@ -7,11 +7,24 @@ import (
 	"github.com/ory/oathkeeper/proxy"
 
 	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/capture"
 	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/decisionlog"
 	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/explain"
+	"github.com/ory/oathkeeper/health"
+	"github.com/ory/oathkeeper/honeypot"
+	"github.com/ory/oathkeeper/lockout"
+	"github.com/ory/oathkeeper/metrics"
+	"github.com/ory/oathkeeper/notification"
+	"github.com/ory/oathkeeper/overload"
 	"github.com/ory/oathkeeper/pipeline/authn"
 	"github.com/ory/oathkeeper/pipeline/authz"
 	"github.com/ory/oathkeeper/pipeline/mutate"
+	"github.com/ory/oathkeeper/redaction"
+	"github.com/ory/oathkeeper/revocation"
+	"github.com/ory/oathkeeper/risk"
 	"github.com/ory/oathkeeper/rule"
 	"github.com/ory/oathkeeper/x"
 	"github.com/ory/x/healthx"
@@ -33,6 +46,16 @@ type Registry interface {
 	RuleHandler() *api.RuleHandler
 	DecisionHandler() *api.DecisionHandler
 	CredentialHandler() *api.CredentialsHandler
+	RevocationHandler() *api.RevocationHandler
+	CacheHandler() *api.CacheHandler
+	CaptureHandler() *api.CaptureHandler
+	ExplanationHandler() *api.ExplanationHandler
+	LockoutHandler() *api.LockoutHandler
+	UIHandler() *api.UIHandler
+	EventsHandler() *api.EventsHandler
+	CapabilitiesHandler() *api.CapabilitiesHandler
+	SchemaHandler() *api.SchemaHandler
+	HealthStatusHandler() *api.HealthStatusHandler
 
 	Proxy() *proxy.Proxy
 	Tracer() *tracing.Tracer
@@ -43,10 +66,25 @@ type Registry interface {
 	errors.Registry
 
 	rule.Registry
+	rule.ManagedRegistry
 	credentials.FetcherRegistry
 	credentials.SignerRegistry
 	credentials.VerifierRegistry
 
+	revocation.Registry
+	capture.Registry
+	explain.Registry
+	events.Registry
+	redaction.Registry
+	risk.Registry
+	lockout.Registry
+	overload.Registry
+	honeypot.Registry
+	health.Registry
+	notification.Registry
+	decisionlog.Registry
+	metrics.Registry
+
 	x.RegistryWriter
 	x.RegistryLogger
 }
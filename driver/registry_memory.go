@@ -19,12 +19,26 @@ import (
 	"github.com/ory/x/healthx"
 
 	"github.com/ory/oathkeeper/api"
+	"github.com/ory/oathkeeper/capture"
 	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/decisionlog"
 	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/explain"
+	"github.com/ory/oathkeeper/fips"
+	"github.com/ory/oathkeeper/health"
+	"github.com/ory/oathkeeper/honeypot"
+	"github.com/ory/oathkeeper/lockout"
+	"github.com/ory/oathkeeper/metrics"
+	"github.com/ory/oathkeeper/notification"
+	"github.com/ory/oathkeeper/overload"
 	"github.com/ory/oathkeeper/pipeline/authn"
 	"github.com/ory/oathkeeper/pipeline/authz"
 	ep "github.com/ory/oathkeeper/pipeline/errors"
 	"github.com/ory/oathkeeper/pipeline/mutate"
+	"github.com/ory/oathkeeper/redaction"
+	"github.com/ory/oathkeeper/revocation"
+	"github.com/ory/oathkeeper/risk"
 	"github.com/ory/oathkeeper/rule"
 )
 
@@ -47,10 +61,49 @@ type RegistryMemory struct {
 	credentialsVerifier credentials.Verifier
 	credentialsSigner   credentials.Signer
 	ruleValidator       rule.Validator
+	ruleStager          rule.Stager
 	ruleRepository      *rule.RepositoryMemory
+	ruleManaged         *rule.ManagedRepository
 	apiRuleHandler      *api.RuleHandler
 	apiJudgeHandler     *api.DecisionHandler
 	healthxHandler      *healthx.Handler
+	healthChecker       *health.Checker
+
+	apiRevocationHandler *api.RevocationHandler
+	apiCacheHandler      *api.CacheHandler
+	revocationStore      revocation.Store
+
+	apiCaptureHandler *api.CaptureHandler
+	captureRecorder   capture.Recorder
+
+	apiExplanationHandler *api.ExplanationHandler
+	explanationRecorder   explain.Recorder
+
+	redactor *redaction.Redactor
+
+	riskScorer risk.Scorer
+
+	honeypotNotifier honeypot.Notifier
+
+	notificationDispatcher *notification.Dispatcher
+
+	decisionLogExporter *decisionlog.Exporter
+
+	statsd *metrics.StatsD
+
+	apiLockoutHandler *api.LockoutHandler
+	lockoutTracker    *lockout.Tracker
+	overloadShedder   *overload.Shedder
+	upstreamLimiter   *overload.UpstreamLimiter
+
+	apiUIHandler *api.UIHandler
+
+	apiEventsHandler *api.EventsHandler
+	eventBus         *events.Bus
+
+	apiCapabilitiesHandler *api.CapabilitiesHandler
+	apiHealthStatusHandler *api.HealthStatusHandler
+	apiSchemaHandler       *api.SchemaHandler
 
 	proxyRequestHandler *proxy.RequestHandler
 	proxyProxy          *proxy.Proxy
@@ -70,6 +123,9 @@ func (r *RegistryMemory) Init() {
 			r.Logger().WithError(err).Fatal("Access rule watcher terminated with an error.")
 		}
 	}()
+	go r.NotificationDispatcher().Run(context.Background())
+	go r.DecisionLogExporter().Run(context.Background())
+	go r.StatsD().Run(context.Background())
 	_ = r.RuleRepository()
 }
 
@@ -135,18 +191,67 @@ func (r *RegistryMemory) CredentialHandler() *api.CredentialsHandler {
 
 func (r *RegistryMemory) HealthHandler() *healthx.Handler {
 	if r.healthxHandler == nil {
-		r.healthxHandler = healthx.NewHandler(r.Writer(), r.BuildVersion(), healthx.ReadyCheckers{})
+		r.healthxHandler = healthx.NewHandler(r.Writer(), r.BuildVersion(), r.HealthChecker().ReadyCheckers())
 	}
 	return r.healthxHandler
 }
 
+// HealthChecker checks the dependencies whose health is reported by the readiness and status endpoints.
+func (r *RegistryMemory) HealthChecker() *health.Checker {
+	if r.healthChecker == nil {
+		r.healthChecker = health.NewChecker(r.c.HealthDependencyWeight,
+			health.Dependency{Name: "access_rules", Check: func() error {
+				if !r.c.AccessRuleStagingIsEnabled() {
+					return nil
+				}
+				_ = r.RuleRepository() // make sure `r.ruleRepository` is set
+				return r.ruleRepository.ReadyChecker()
+			}},
+			health.Dependency{Name: "rule_repositories", Check: func() error {
+				for _, s := range r.RuleFetcher().Status() {
+					if s.Error != "" {
+						return errors.Errorf("unable to load the access rules of %s: %s", s.URL, s.Error)
+					}
+				}
+				return nil
+			}},
+			health.Dependency{Name: "jwks", Check: func() error {
+				return healthCheck(r.CredentialsFetcher())
+			}},
+			health.Dependency{Name: "introspection", Check: func() error {
+				a, err := r.PipelineAuthenticator("oauth2_introspection")
+				if err != nil {
+					return nil
+				}
+				return healthCheck(a)
+			}},
+		)
+	}
+	return r.healthChecker
+}
+
+// healthCheck runs the health check of a component, if it has one.
+func healthCheck(component interface{}) error {
+	if c, ok := component.(interface{ HealthCheck() error }); ok {
+		return c.HealthCheck()
+	}
+	return nil
+}
+
 func (r *RegistryMemory) RuleValidator() rule.Validator {
 	if r.ruleValidator == nil {
-		r.ruleValidator = rule.NewValidatorDefault(r)
+		r.ruleValidator = rule.NewValidatorDefault(r.c, r)
 	}
 	return r.ruleValidator
 }
 
+func (r *RegistryMemory) RuleStager() rule.Stager {
+	if r.ruleStager == nil {
+		r.ruleStager = rule.NewStagerDefault(r)
+	}
+	return r.ruleStager
+}
+
 func (r *RegistryMemory) RuleRepository() rule.Repository {
 	if r.ruleRepository == nil {
 		r.ruleRepository = rule.NewRepositoryMemory(r)
@@ -154,6 +259,13 @@ func (r *RegistryMemory) RuleRepository() rule.Repository {
 	return r.ruleRepository
 }
 
+func (r *RegistryMemory) RuleManagedRepository() *rule.ManagedRepository {
+	if r.ruleManaged == nil {
+		r.ruleManaged = rule.NewManagedRepository()
+	}
+	return r.ruleManaged
+}
+
 func (r *RegistryMemory) Writer() herodot.Writer {
 	if r.writer == nil {
 		r.writer = herodot.NewJSONWriter(r.Logger())
@@ -170,21 +282,258 @@ func (r *RegistryMemory) Logger() logrus.FieldLogger {
 
 func (r *RegistryMemory) RuleHandler() *api.RuleHandler {
 	if r.apiRuleHandler == nil {
-		r.apiRuleHandler = api.NewRuleHandler(r)
+		r.apiRuleHandler = api.NewRuleHandler(r.c, r)
 	}
 	return r.apiRuleHandler
 }
 
 func (r *RegistryMemory) DecisionHandler() *api.DecisionHandler {
 	if r.apiJudgeHandler == nil {
-		r.apiJudgeHandler = api.NewJudgeHandler(r)
+		r.apiJudgeHandler = api.NewJudgeHandler(r.c, r)
 	}
 	return r.apiJudgeHandler
 }
 
+func (r *RegistryMemory) RevocationHandler() *api.RevocationHandler {
+	if r.apiRevocationHandler == nil {
+		r.apiRevocationHandler = api.NewRevocationHandler(r.c, r)
+	}
+	return r.apiRevocationHandler
+}
+
+func (r *RegistryMemory) CacheHandler() *api.CacheHandler {
+	if r.apiCacheHandler == nil {
+		r.apiCacheHandler = api.NewCacheHandler(r)
+	}
+	return r.apiCacheHandler
+}
+
+func (r *RegistryMemory) CaptureHandler() *api.CaptureHandler {
+	if r.apiCaptureHandler == nil {
+		r.apiCaptureHandler = api.NewCaptureHandler(r)
+	}
+	return r.apiCaptureHandler
+}
+
+func (r *RegistryMemory) CaptureRecorder() capture.Recorder {
+	if r.captureRecorder == nil {
+		r.captureRecorder = capture.NewRecorderMemory(r.Redactor())
+	}
+	return r.captureRecorder
+}
+
+func (r *RegistryMemory) ExplanationHandler() *api.ExplanationHandler {
+	if r.apiExplanationHandler == nil {
+		r.apiExplanationHandler = api.NewExplanationHandler(r)
+	}
+	return r.apiExplanationHandler
+}
+
+func (r *RegistryMemory) ExplanationRecorder() explain.Recorder {
+	if r.explanationRecorder == nil {
+		if r.c.ExplanationsStore() == "file" {
+			r.explanationRecorder = explain.NewRecorderFile(r.Redactor(), r.c.ExplanationsFile())
+		} else {
+			r.explanationRecorder = explain.NewRecorderMemory(r.Redactor(), r.c.ExplanationsSize())
+		}
+	}
+	return r.explanationRecorder
+}
+
+func (r *RegistryMemory) HoneypotNotifier() honeypot.Notifier {
+	if r.honeypotNotifier == nil {
+		r.honeypotNotifier = honeypot.NewNotifierWebhook(r.c.HoneypotWebhookURL, r.c.HoneypotWebhookFormat, r.Redactor(), r.Logger())
+	}
+	return r.honeypotNotifier
+}
+
+func (r *RegistryMemory) NotificationDispatcher() *notification.Dispatcher {
+	if r.notificationDispatcher == nil {
+		r.notificationDispatcher = notification.NewDispatcher(r.c, r.EventBus(), r.readiness, r.Logger())
+	}
+	return r.notificationDispatcher
+}
+
+func (r *RegistryMemory) DecisionLogExporter() *decisionlog.Exporter {
+	if r.decisionLogExporter == nil {
+		r.decisionLogExporter = decisionlog.NewExporter(r.c, r.EventBus(), r.Logger())
+	}
+	return r.decisionLogExporter
+}
+
+func (r *RegistryMemory) StatsD() *metrics.StatsD {
+	if r.statsd == nil {
+		r.statsd = metrics.NewStatsD(r.c, r.EventBus(), r.RuleRepository(), r.Logger())
+		r.statsd.SetHealth(r.HealthChecker().Status)
+	}
+	return r.statsd
+}
+
+// readiness returns the first error of the ready checks of the health handler.
+func (r *RegistryMemory) readiness() error {
+	for _, check := range r.HealthHandler().ReadyChecks {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RegistryMemory) LockoutHandler() *api.LockoutHandler {
+	if r.apiLockoutHandler == nil {
+		r.apiLockoutHandler = api.NewLockoutHandler(r)
+	}
+	return r.apiLockoutHandler
+}
+
+func (r *RegistryMemory) UIHandler() *api.UIHandler {
+	if r.apiUIHandler == nil {
+		r.apiUIHandler = api.NewUIHandler(r.c, r)
+	}
+	return r.apiUIHandler
+}
+
+func (r *RegistryMemory) EventsHandler() *api.EventsHandler {
+	if r.apiEventsHandler == nil {
+		r.apiEventsHandler = api.NewEventsHandler(r)
+	}
+	return r.apiEventsHandler
+}
+
+func (r *RegistryMemory) HealthStatusHandler() *api.HealthStatusHandler {
+	if r.apiHealthStatusHandler == nil {
+		r.apiHealthStatusHandler = api.NewHealthStatusHandler(r)
+	}
+	return r.apiHealthStatusHandler
+}
+
+func (r *RegistryMemory) CapabilitiesHandler() *api.CapabilitiesHandler {
+	if r.apiCapabilitiesHandler == nil {
+		r.apiCapabilitiesHandler = api.NewCapabilitiesHandler(r.c, r)
+	}
+	return r.apiCapabilitiesHandler
+}
+
+func (r *RegistryMemory) SchemaHandler() *api.SchemaHandler {
+	if r.apiSchemaHandler == nil {
+		r.apiSchemaHandler = api.NewSchemaHandler(r)
+	}
+	return r.apiSchemaHandler
+}
+
+func (r *RegistryMemory) EventBus() *events.Bus {
+	if r.eventBus == nil {
+		r.eventBus = events.NewBus(r.Redactor())
+	}
+	return r.eventBus
+}
+
+func (r *RegistryMemory) LockoutTracker() *lockout.Tracker {
+	if r.lockoutTracker == nil {
+		r.lockoutTracker = lockout.NewTracker(lockout.NewStoreMemory(), r.lockoutPolicy)
+	}
+	return r.lockoutTracker
+}
+
+func (r *RegistryMemory) lockoutPolicy() lockout.Policy {
+	return lockout.Policy{
+		Window:       r.c.LockoutWindow(),
+		DelayAfter:   r.c.LockoutDelayAfter(),
+		Delay:        r.c.LockoutDelay(),
+		MaxDelay:     r.c.LockoutMaxDelay(),
+		LockAfter:    r.c.LockoutLockAfter(),
+		LockDuration: r.c.LockoutLockDuration(),
+	}
+}
+
+func (r *RegistryMemory) OverloadShedder() *overload.Shedder {
+	if r.overloadShedder == nil {
+		r.overloadShedder = overload.NewShedder(r.overloadPolicy)
+	}
+	return r.overloadShedder
+}
+
+func (r *RegistryMemory) overloadPolicy() overload.Policy {
+	return overload.Policy{
+		MaxInFlight:            r.c.OverloadMaxInFlight(),
+		MaxLatency:             r.c.OverloadMaxLatency(),
+		LatencyWindow:          r.c.OverloadLatencyWindow(),
+		ShedPercentage:         r.c.OverloadShedPercentage(),
+		MaxInFlightPerPriority: r.c.OverloadMaxInFlightPerPriority(),
+	}
+}
+
+func (r *RegistryMemory) UpstreamLimiter() *overload.UpstreamLimiter {
+	if r.upstreamLimiter == nil {
+		r.upstreamLimiter = overload.NewUpstreamLimiter(r.upstreamPolicy)
+	}
+	return r.upstreamLimiter
+}
+
+func (r *RegistryMemory) upstreamPolicy() overload.UpstreamPolicy {
+	return overload.UpstreamPolicy{
+		Enabled:          r.c.OverloadUpstreamLimitIsEnabled(),
+		InitialLimit:     r.c.OverloadUpstreamLimitInitial(),
+		MinLimit:         r.c.OverloadUpstreamLimitMin(),
+		MaxLimit:         r.c.OverloadUpstreamLimitMax(),
+		LatencyTolerance: r.c.OverloadUpstreamLimitLatencyTolerance(),
+		Backoff:          r.c.OverloadUpstreamLimitBackoff(),
+	}
+}
+
+func (r *RegistryMemory) RiskScorer() risk.Scorer {
+	if r.riskScorer == nil {
+		r.riskScorer = risk.NewScorerRemote(r.c.RiskRemote, r.c.RemoteResponseMaxBodySize)
+	}
+	return r.riskScorer
+}
+
+func (r *RegistryMemory) Redactor() *redaction.Redactor {
+	if r.redactor == nil {
+		redactor, err := redaction.NewRedactor(r.c.RedactionHeaders(), r.c.RedactionPatterns())
+		if err != nil {
+			r.Logger().WithError(err).Fatalf("Unable to initialize redaction.")
+		}
+		r.redactor = redactor
+	}
+	return r.redactor
+}
+
+func (r *RegistryMemory) RevocationStore() revocation.Store {
+	if r.revocationStore == nil {
+		r.revocationStore = revocation.NewStoreMemory(r.c.RevocationTTL, r.revocationInvalidators)
+	}
+	return r.revocationStore
+}
+
+// revocationInvalidators returns all pipeline handlers, some of which may cache results belonging to a
+// session or token.
+func (r *RegistryMemory) revocationInvalidators() []interface{} {
+	r.prepareAuthn()
+	r.prepareAuthz()
+	r.prepareMutators()
+
+	r.RLock()
+	defer r.RUnlock()
+
+	var handlers []interface{}
+	for _, h := range r.authenticators {
+		handlers = append(handlers, h)
+	}
+	for _, h := range r.authorizers {
+		handlers = append(handlers, h)
+	}
+	for _, h := range r.mutators {
+		handlers = append(handlers, h)
+	}
+	return handlers
+}
+
 func (r *RegistryMemory) CredentialsFetcher() credentials.Fetcher {
 	if r.credentialsFetcher == nil {
-		r.credentialsFetcher = credentials.NewFetcherDefault(r.Logger(), time.Second, time.Second*30)
+		f := credentials.NewFetcherDefault(r.Logger(), time.Second, time.Second*30)
+		f.SetTLSConfigs(r.c.JSONWebKeySetTLSConfig)
+		r.credentialsFetcher = f
 	}
 
 	return r.credentialsFetcher
@@ -192,7 +541,11 @@ func (r *RegistryMemory) CredentialsFetcher() credentials.Fetcher {
 
 func (r *RegistryMemory) CredentialsSigner() credentials.Signer {
 	if r.credentialsSigner == nil {
-		r.credentialsSigner = credentials.NewSignerDefault(r)
+		signer := credentials.NewSignerDefault(r)
+		if r.c.FIPSIsEnabled() {
+			signer.WithKeyValidator(fips.ValidateKey)
+		}
+		r.credentialsSigner = signer
 	}
 
 	return r.credentialsSigner
@@ -342,11 +695,28 @@ func (r *RegistryMemory) prepareAuthn() {
 	if r.authenticators == nil {
 		interim := []authn.Authenticator{
 			authn.NewAuthenticatorAnonymous(r.c),
+			authn.NewAuthenticatorAPIKey(r.c),
+			authn.NewAuthenticatorAWSIAM(r.c),
+			authn.NewAuthenticatorAzureManagedIdentity(r.c, r),
+			authn.NewAuthenticatorBasicAuth(r.c),
+			authn.NewAuthenticatorBiscuit(r.c, r),
+			authn.NewAuthenticatorCIOIDC(r.c, r),
 			authn.NewAuthenticatorCookieSession(r.c),
+			authn.NewAuthenticatorGCPIDToken(r.c, r),
+			authn.NewAuthenticatorHMAC(r.c),
 			authn.NewAuthenticatorJWT(r.c, r),
+			authn.NewAuthenticatorKerberos(r.c),
+			authn.NewAuthenticatorKubernetesServiceAccount(r.c, r),
+			authn.NewAuthenticatorLDAP(r.c),
+			authn.NewAuthenticatorMacaroon(r.c),
+			authn.NewAuthenticatorMTLS(r.c),
 			authn.NewAuthenticatorNoOp(r.c),
 			authn.NewAuthenticatorOAuth2ClientCredentials(r.c),
-			authn.NewAuthenticatorOAuth2Introspection(r.c),
+			authn.NewAuthenticatorOAuth2Introspection(r.c, r),
+			authn.NewAuthenticatorPASETO(r.c, r),
+			authn.NewAuthenticatorRemote(r.c, r),
+			authn.NewAuthenticatorSAML(r.c),
+			authn.NewAuthenticatorTokenExchange(r.c, r),
 			authn.NewAuthenticatorUnauthorized(r.c),
 		}
 
@@ -364,8 +734,9 @@ func (r *RegistryMemory) prepareAuthz() {
 		interim := []authz.Authorizer{
 			authz.NewAuthorizerAllow(r.c),
 			authz.NewAuthorizerDeny(r.c),
+			authz.NewAuthorizerDevicePosture(r.c, r),
 			authz.NewAuthorizerKetoEngineACPORY(r.c),
-			authz.NewAuthorizerRemoteJSON(r.c),
+			authz.NewAuthorizerRemoteJSON(r.c, r),
 		}
 
 		r.authorizers = map[string]authz.Authorizer{}
@@ -383,6 +754,8 @@ func (r *RegistryMemory) prepareMutators() {
 			mutate.NewMutatorCookie(r.c),
 			mutate.NewMutatorHeader(r.c),
 			mutate.NewMutatorIDToken(r.c, r),
+			mutate.NewMutatorJWTPayload(r.c),
+			mutate.NewMutatorPASETO(r.c, r),
 			mutate.NewMutatorNoop(r.c),
 			mutate.NewMutatorHydrator(r.c, r),
 		}
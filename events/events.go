@@ -0,0 +1,199 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+const (
+	// TypeDecision is the type of events published for every access control decision.
+	TypeDecision = "decision"
+
+	// TypeRuleReload is the type of events published whenever an access rule repository is reloaded.
+	TypeRuleReload = "rule_reload"
+
+	// TypeDropped is the type of events telling a subscriber that events were dropped because it did not keep up.
+	TypeDropped = "dropped"
+)
+
+// Event is published on the event bus.
+type Event struct {
+	// Type is the type of the event.
+	Type string `json:"type"`
+
+	// Time is the point in time at which the event occurred.
+	Time time.Time `json:"time"`
+
+	// Decision is set for events of type "decision".
+	Decision *Decision `json:"decision,omitempty"`
+
+	// RuleReload is set for events of type "rule_reload".
+	RuleReload *RuleReload `json:"rule_reload,omitempty"`
+
+	// Dropped is the number of events dropped since the last event of type "dropped".
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// Decision is an access control decision made by the proxy or the decisions API.
+type Decision struct {
+	// Interface is either "proxy" or "decisions".
+	Interface string `json:"interface"`
+
+	// RuleID is the ID of the matching access rule, if any.
+	RuleID string `json:"rule_id,omitempty"`
+
+	// RuleOwner is the owner of the matching access rule, if any.
+	RuleOwner string `json:"rule_owner,omitempty"`
+
+	// RuleLabels are the labels of the matching access rule, if any.
+	RuleLabels map[string]string `json:"rule_labels,omitempty"`
+
+	// Subject is the authenticated subject, if any.
+	Subject string `json:"subject,omitempty"`
+
+	// Method is the HTTP method of the request.
+	Method string `json:"method"`
+
+	// URL is the requested URL.
+	URL string `json:"url"`
+
+	// Granted is true if the request was allowed.
+	Granted bool `json:"granted"`
+
+	// StatusCode is the status code returned to the client. For granted requests of the proxy it is the status code
+	// of the upstream.
+	StatusCode int `json:"status_code"`
+
+	// LatencyMS is the time in milliseconds ORY Oathkeeper took to reach the decision. The round trip to the upstream
+	// is not included.
+	LatencyMS float64 `json:"latency_ms"`
+
+	// Error is the reason the request was denied or failed, if any.
+	Error string `json:"error,omitempty"`
+
+	// UpstreamError is why the upstream could not be reached, if it could not: "connect", "timeout", "tls", or
+	// "unknown".
+	UpstreamError string `json:"upstream_error,omitempty"`
+
+	// TraceID is the ID of the trace of the request, if tracing is enabled.
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+// RuleReload is the reload of an access rule repository.
+type RuleReload struct {
+	// Repository is the location of the repository with credentials removed.
+	Repository string `json:"repository"`
+
+	// Rules is the number of access rules loaded from the repository.
+	Rules int `json:"rules"`
+
+	// Error is the reason the reload failed, if it failed. The previous access rules of the repository stay active.
+	Error string `json:"error,omitempty"`
+}
+
+type Registry interface {
+	EventBus() *Bus
+}
+
+// Bus fans events out to all subscribers. Publishing never blocks: events are dropped for subscribers which do not
+// keep up. Secrets are redacted from events before they are published.
+type Bus struct {
+	sync.RWMutex
+
+	redactor    *redaction.Redactor
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events published on the bus which match its filter.
+type Subscription struct {
+	dropped uint64
+
+	bus    *Bus
+	filter Filter
+	events chan Event
+}
+
+func NewBus(redactor *redaction.Redactor) *Bus {
+	return &Bus{redactor: redactor, subscribers: map[*Subscription]struct{}{}}
+}
+
+// Subscribe returns a subscription to the events matching the filter which buffers up to buffer events.
+func (b *Bus) Subscribe(buffer int, f Filter) *Subscription {
+	s := &Subscription{bus: b, filter: f, events: make(chan Event, buffer)}
+
+	b.Lock()
+	b.subscribers[s] = struct{}{}
+	b.Unlock()
+
+	return s
+}
+
+// HasSubscribers returns true if at least one subscription is active. Publishers use it to skip building events
+// nobody receives.
+func (b *Bus) HasSubscribers() bool {
+	b.RLock()
+	defer b.RUnlock()
+	return len(b.subscribers) > 0
+}
+
+// Publish sends the event to all subscribers.
+func (b *Bus) Publish(e Event) {
+	b.RLock()
+	defer b.RUnlock()
+
+	if len(b.subscribers) == 0 {
+		return
+	}
+
+	e = b.redact(e)
+	for s := range b.subscribers {
+		if !s.filter.Match(e) {
+			continue
+		}
+
+		select {
+		case s.events <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+func (b *Bus) redact(e Event) Event {
+	if e.Decision != nil {
+		d := *e.Decision
+		d.URL = b.redactor.String(d.URL)
+		d.Error = b.redactor.String(d.Error)
+		e.Decision = &d
+	}
+	if e.RuleReload != nil {
+		r := *e.RuleReload
+		r.Error = b.redactor.String(r.Error)
+		e.RuleReload = &r
+	}
+	return e
+}
+
+// Events returns the channel the events are delivered on. It is closed when the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns the total number of events dropped because the buffer of the subscription was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.bus.Lock()
+	defer s.bus.Unlock()
+
+	if _, ok := s.bus.subscribers[s]; ok {
+		delete(s.bus.subscribers, s)
+		close(s.events)
+	}
+}
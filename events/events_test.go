@@ -0,0 +1,48 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+func TestBus(t *testing.T) {
+	redactor, err := redaction.NewRedactor(nil, nil)
+	require.NoError(t, err)
+	b := NewBus(redactor)
+
+	assert.False(t, b.HasSubscribers())
+	b.Publish(Event{Type: TypeDecision, Decision: &Decision{URL: "http://localhost/"}})
+
+	fast, slow, denied := b.Subscribe(10, Filter{}), b.Subscribe(1, Filter{}), b.Subscribe(1, Filter{Outcome: OutcomeDenied})
+	assert.True(t, b.HasSubscribers())
+
+	b.Publish(Event{Type: TypeDecision, Decision: &Decision{URL: "http://localhost/a", Granted: true}})
+	b.Publish(Event{Type: TypeDecision, Decision: &Decision{URL: "http://localhost/b?access_token=foo"}})
+
+	require.Len(t, fast.Events(), 2)
+	assert.Equal(t, "http://localhost/a", (<-fast.Events()).Decision.URL)
+	assert.Equal(t, "http://localhost/b?access_token="+redaction.RedactedValue, (<-fast.Events()).Decision.URL)
+	assert.EqualValues(t, 0, fast.Dropped())
+
+	require.Len(t, slow.Events(), 1)
+	assert.Equal(t, "http://localhost/a", (<-slow.Events()).Decision.URL)
+	assert.EqualValues(t, 1, slow.Dropped())
+
+	require.Len(t, denied.Events(), 1)
+	assert.Equal(t, "http://localhost/b?access_token="+redaction.RedactedValue, (<-denied.Events()).Decision.URL)
+	assert.EqualValues(t, 0, denied.Dropped())
+	denied.Close()
+
+	fast.Close()
+	fast.Close()
+	_, ok := <-fast.Events()
+	assert.False(t, ok)
+
+	slow.Close()
+	assert.False(t, b.HasSubscribers())
+	b.Publish(Event{Type: TypeDecision, Decision: &Decision{URL: "http://localhost/"}})
+}
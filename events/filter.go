@@ -0,0 +1,152 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	OutcomeAllowed = "allowed"
+	OutcomeDenied  = "denied"
+)
+
+// Filter selects events. Empty fields match every event.
+type Filter struct {
+	// Type is the type of the event.
+	Type string
+
+	// RuleID is the ID of the access rule of a decision.
+	RuleID string
+
+	// Subject is the authenticated subject of a decision.
+	Subject string
+
+	// Outcome is the outcome of a decision, either "allowed" or "denied".
+	Outcome string
+
+	// Text must be contained in the rule ID, the subject, the method, the URL, or the error of a decision, or in the
+	// repository or the error of a rule reload.
+	Text string
+}
+
+// ParseFilter parses a filter expression such as "rule:users subject:alice outcome:denied /admin". Terms without a
+// known prefix are matched as text.
+func ParseFilter(expression string) (Filter, error) {
+	var f Filter
+	var text []string
+	for _, term := range strings.Fields(expression) {
+		parts := strings.SplitN(term, ":", 2)
+		if len(parts) != 2 {
+			text = append(text, term)
+			continue
+		}
+
+		switch parts[0] {
+		case "type":
+			f.Type = parts[1]
+		case "rule":
+			f.RuleID = parts[1]
+		case "subject":
+			f.Subject = parts[1]
+		case "outcome":
+			f.Outcome = parts[1]
+		default:
+			text = append(text, term)
+		}
+	}
+	f.Text = strings.Join(text, " ")
+
+	return f, f.Validate()
+}
+
+// Validate returns an error if the type or the outcome is unknown.
+func (f Filter) Validate() error {
+	switch f.Type {
+	case "", TypeDecision, TypeRuleReload:
+	default:
+		return fmt.Errorf(`type "%s" must be "%s" or "%s"`, f.Type, TypeDecision, TypeRuleReload)
+	}
+
+	switch f.Outcome {
+	case "", OutcomeAllowed, OutcomeDenied:
+		return nil
+	}
+	return fmt.Errorf(`outcome "%s" must be "%s" or "%s"`, f.Outcome, OutcomeAllowed, OutcomeDenied)
+}
+
+// String returns the filter expression.
+func (f Filter) String() string {
+	var terms []string
+	if f.Type != "" {
+		terms = append(terms, "type:"+f.Type)
+	}
+	if f.RuleID != "" {
+		terms = append(terms, "rule:"+f.RuleID)
+	}
+	if f.Subject != "" {
+		terms = append(terms, "subject:"+f.Subject)
+	}
+	if f.Outcome != "" {
+		terms = append(terms, "outcome:"+f.Outcome)
+	}
+	if f.Text != "" {
+		terms = append(terms, f.Text)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Match returns true if the event passes the filter. Rule reloads never pass filters on the rule ID, the subject,
+// or the outcome.
+func (f Filter) Match(e Event) bool {
+	if f.Type != "" && e.Type != f.Type {
+		return false
+	}
+
+	var fields []string
+	switch {
+	case e.Decision != nil:
+		if !f.matchDecision(e.Decision) {
+			return false
+		}
+		fields = []string{e.Decision.RuleID, e.Decision.Subject, e.Decision.Method, e.Decision.URL, e.Decision.Error}
+	case e.RuleReload != nil:
+		if f.RuleID != "" || f.Subject != "" || f.Outcome != "" {
+			return false
+		}
+		fields = []string{e.RuleReload.Repository, e.RuleReload.Error}
+	}
+
+	if f.Text == "" {
+		return true
+	}
+
+	text := strings.ToLower(f.Text)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) matchDecision(d *Decision) bool {
+	switch {
+	case f.RuleID != "" && d.RuleID != f.RuleID:
+		return false
+	case f.Subject != "" && d.Subject != f.Subject:
+		return false
+	case f.Outcome == OutcomeAllowed && !d.Granted:
+		return false
+	case f.Outcome == OutcomeDenied && d.Granted:
+		return false
+	}
+	return true
+}
+
+// Outcome returns "allowed" or "denied".
+func (d *Decision) Outcome() string {
+	if d.Granted {
+		return OutcomeAllowed
+	}
+	return OutcomeDenied
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	t.Run("method=ParseFilter", func(t *testing.T) {
+		for k, tc := range []struct {
+			expression string
+			expected   Filter
+			expectErr  bool
+		}{
+			{expression: "", expected: Filter{}},
+			{expression: "rule:users subject:alice outcome:denied", expected: Filter{RuleID: "users", Subject: "alice", Outcome: "denied"}},
+			{expression: "/admin  outcome:allowed https://", expected: Filter{Outcome: "allowed", Text: "/admin https://"}},
+			{expression: "type:rule_reload consul", expected: Filter{Type: "rule_reload", Text: "consul"}},
+			{expression: "outcome:maybe", expectErr: true},
+			{expression: "type:unknown", expectErr: true},
+		} {
+			f, err := ParseFilter(tc.expression)
+			if tc.expectErr {
+				require.Error(t, err, "%d", k)
+				continue
+			}
+			require.NoError(t, err, "%d", k)
+			assert.Equal(t, tc.expected, f, "%d", k)
+
+			parsed, err := ParseFilter(f.String())
+			require.NoError(t, err, "%d", k)
+			assert.Equal(t, f, parsed, "%d", k)
+		}
+	})
+
+	t.Run("method=Match", func(t *testing.T) {
+		decision := Event{Type: TypeDecision, Decision: &Decision{
+			RuleID: "users", Subject: "alice", Method: "GET", URL: "https://api.example.com/Admin", Granted: true,
+		}}
+		reload := Event{Type: TypeRuleReload, RuleReload: &RuleReload{Repository: "consul://consul:8500/rules", Error: "timeout"}}
+
+		for k, tc := range []struct {
+			filter   Filter
+			decision bool
+			reload   bool
+		}{
+			{filter: Filter{}, decision: true, reload: true},
+			{filter: Filter{Type: TypeDecision}, decision: true},
+			{filter: Filter{Type: TypeRuleReload}, reload: true},
+			{filter: Filter{RuleID: "users", Subject: "alice", Outcome: OutcomeAllowed}, decision: true},
+			{filter: Filter{RuleID: "orders"}},
+			{filter: Filter{Subject: "bob"}},
+			{filter: Filter{Outcome: OutcomeDenied}},
+			{filter: Filter{Text: "/admin"}, decision: true},
+			{filter: Filter{Text: "Timeout"}, reload: true},
+			{filter: Filter{Text: "/orders"}},
+		} {
+			assert.Equal(t, tc.decision, tc.filter.Match(decision), "%d", k)
+			assert.Equal(t, tc.reload, tc.filter.Match(reload), "%d", k)
+		}
+	})
+}
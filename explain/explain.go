@@ -0,0 +1,181 @@
+package explain
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+// RequestIDHeader is the header carrying the ID of a request. Denied requests whose pipeline was traced are answered
+// with it, so the explanation can be retrieved.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength limits the length of request IDs chosen by clients.
+const maxRequestIDLength = 128
+
+// Results of the steps of a trace.
+const (
+	ResultPassed         = "passed"
+	ResultFailed         = "failed"
+	ResultSkipped        = "skipped"
+	ResultNotResponsible = "not_responsible"
+)
+
+// Explanation is the trace of the pipeline of a denied request.
+type Explanation struct {
+	// RequestID is the ID of the request, either taken from its X-Request-Id header or generated.
+	RequestID string `json:"request_id"`
+
+	Time time.Time `json:"time"`
+
+	// RuleID is the ID of the access rule matching the request, if any.
+	RuleID  string  `json:"rule_id,omitempty"`
+	Request Request `json:"request"`
+
+	// Steps are the steps of the pipeline the request passed, in order.
+	Steps []Step `json:"steps"`
+
+	// Error is the reason the request was denied.
+	Error string `json:"error"`
+
+	// StatusCode is the status code of the response sent to the client.
+	StatusCode int `json:"status_code"`
+}
+
+// Request is the denied request. Bodies are never recorded and secrets are redacted by the recorder.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+}
+
+// Step is the outcome of a single step of the pipeline, for example of one authenticator.
+type Step struct {
+	// Stage is the stage of the pipeline, for example "authentication".
+	Stage string `json:"stage"`
+
+	// Handler is the handler of the step, if any, for example "jwt".
+	Handler string `json:"handler,omitempty"`
+
+	// Result is one of "passed", "failed", "skipped", and "not_responsible".
+	Result string `json:"result"`
+
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Trace collects the steps of a sampled request. A nil trace collects nothing, so its methods may be called for
+// requests which were not sampled.
+type Trace struct {
+	sync.Mutex
+
+	explanation Explanation
+	last        time.Time
+}
+
+// NewTrace starts the trace of the request.
+func NewTrace(r *http.Request) *Trace {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		id = uuid.New().String()
+	}
+
+	now := time.Now()
+	return &Trace{
+		last: now,
+		explanation: Explanation{
+			RequestID: id,
+			Time:      now.UTC(),
+			Request: Request{
+				Method: r.Method,
+				URL:    r.URL.String(),
+				Header: r.Header.Clone(),
+			},
+			Steps: []Step{},
+		},
+	}
+}
+
+// RequestID returns the ID of the traced request.
+func (t *Trace) RequestID() string {
+	if t == nil {
+		return ""
+	}
+	return t.explanation.RequestID
+}
+
+// Step adds a step to the trace. Its duration is the time passed since the previous step.
+func (t *Trace) Step(stage, handler, result string, err error) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+	s := Step{Stage: stage, Handler: handler, Result: result, Duration: now.Sub(t.last)}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	t.explanation.Steps = append(t.explanation.Steps, s)
+	t.last = now
+}
+
+// Explanation returns the explanation of the denied request.
+func (t *Trace) Explanation(ruleID string, err error, code int) Explanation {
+	t.Lock()
+	defer t.Unlock()
+
+	e := t.explanation
+	e.Steps = append([]Step{}, t.explanation.Steps...)
+	e.RuleID = ruleID
+	e.StatusCode = code
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+type contextKey int
+
+const contextKeyTrace contextKey = iota + 1
+
+// WithTrace returns a context making the pipeline add its steps to the trace.
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, contextKeyTrace, t)
+}
+
+// TraceFromContext returns the trace of the context or nil if the request is not traced.
+func TraceFromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(contextKeyTrace).(*Trace)
+	return t
+}
+
+// Recorder stores the explanations of denied requests.
+type Recorder interface {
+	// Record stores the explanation, replacing an existing explanation of a request with the same ID.
+	Record(ctx context.Context, e Explanation) error
+
+	// Get returns the explanation of the request and false if none is stored.
+	Get(ctx context.Context, requestID string) (Explanation, bool, error)
+}
+
+type Registry interface {
+	ExplanationRecorder() Recorder
+}
+
+func redact(redactor *redaction.Redactor, e Explanation) Explanation {
+	e.Request.URL = redactor.String(e.Request.URL)
+	e.Request.Header = redactor.Header(e.Request.Header)
+	e.Error = redactor.String(e.Error)
+	for k := range e.Steps {
+		e.Steps[k].Error = redactor.String(e.Steps[k].Error)
+	}
+	return e
+}
@@ -0,0 +1,83 @@
+package explain
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+var _ Recorder = new(RecorderFile)
+
+// maxExplanationSize limits the size of a single line of the file.
+const maxExplanationSize = 1 << 20
+
+// RecorderFile appends explanations to a file, one JSON object per line. The file is never truncated by ORY
+// Oathkeeper, it should be rotated by external means. Secrets are redacted from explanations before they are
+// recorded.
+type RecorderFile struct {
+	sync.Mutex
+
+	redactor *redaction.Redactor
+	path     string
+}
+
+func NewRecorderFile(redactor *redaction.Redactor, path string) *RecorderFile {
+	return &RecorderFile{redactor: redactor, path: path}
+}
+
+func (f *RecorderFile) Record(_ context.Context, e Explanation) error {
+	raw, err := json.Marshal(redact(f.redactor, e))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err := file.Write(append(raw, '\n')); err != nil {
+		_ = file.Close()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(file.Close())
+}
+
+// Get scans the file for the explanation of the request. If the file contains several, the last one is returned.
+func (f *RecorderFile) Get(_ context.Context, requestID string) (Explanation, bool, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return Explanation{}, false, nil
+	} else if err != nil {
+		return Explanation{}, false, errors.WithStack(err)
+	}
+	defer file.Close()
+
+	var found Explanation
+	var ok bool
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxExplanationSize)
+	for scanner.Scan() {
+		var e Explanation
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Lines may be truncated if the file was rotated while they were written.
+			continue
+		}
+		if e.RequestID == requestID {
+			found, ok = e, true
+		}
+	}
+	return found, ok, errors.WithStack(scanner.Err())
+}
@@ -0,0 +1,61 @@
+package explain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+var _ Recorder = new(RecorderMemory)
+
+// RecorderMemory keeps the most recent explanations in a ring buffer in memory. Secrets are redacted from
+// explanations before they are recorded.
+type RecorderMemory struct {
+	sync.RWMutex
+
+	redactor     *redaction.Redactor
+	explanations []Explanation
+	next         int
+	index        map[string]int
+}
+
+func NewRecorderMemory(redactor *redaction.Redactor, size int) *RecorderMemory {
+	if size < 1 {
+		size = 1
+	}
+	return &RecorderMemory{redactor: redactor, explanations: make([]Explanation, 0, size), index: map[string]int{}}
+}
+
+func (m *RecorderMemory) Record(_ context.Context, e Explanation) error {
+	e = redact(m.redactor, e)
+
+	m.Lock()
+	defer m.Unlock()
+
+	if i, ok := m.index[e.RequestID]; ok {
+		m.explanations[i] = e
+		return nil
+	}
+
+	if len(m.explanations) < cap(m.explanations) {
+		m.explanations = append(m.explanations, e)
+	} else {
+		delete(m.index, m.explanations[m.next].RequestID)
+		m.explanations[m.next] = e
+	}
+	m.index[e.RequestID] = m.next
+	m.next = (m.next + 1) % cap(m.explanations)
+	return nil
+}
+
+func (m *RecorderMemory) Get(_ context.Context, requestID string) (Explanation, bool, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	i, ok := m.index[requestID]
+	if !ok {
+		return Explanation{}, false, nil
+	}
+	return m.explanations[i], true, nil
+}
@@ -0,0 +1,85 @@
+package explain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+func TestRecorders(t *testing.T) {
+	ctx := context.Background()
+	redactor, err := redaction.NewRedactor(nil, nil)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "explanations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	explanation := func(id string) Explanation {
+		r, err := http.NewRequest("GET", "https://example.org/?token=secret", nil)
+		require.NoError(t, err)
+		r.Header.Set("Authorization", "Bearer secret")
+		r.Header.Set(RequestIDHeader, id)
+
+		trace := NewTrace(r)
+		trace.Step("authentication", "jwt", ResultFailed, errors.New("token=secret is invalid"))
+		return trace.Explanation("rule-1", errors.New("denied"), http.StatusUnauthorized)
+	}
+
+	for name, recorder := range map[string]Recorder{
+		"memory": NewRecorderMemory(redactor, 2),
+		"file":   NewRecorderFile(redactor, filepath.Join(dir, "explanations.jsonl")),
+	} {
+		t.Run("recorder="+name, func(t *testing.T) {
+			_, ok, err := recorder.Get(ctx, "request-0")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			for i := 0; i < 3; i++ {
+				require.NoError(t, recorder.Record(ctx, explanation(fmt.Sprintf("request-%d", i))))
+			}
+
+			e, ok, err := recorder.Get(ctx, "request-2")
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, "rule-1", e.RuleID)
+			assert.Equal(t, http.StatusUnauthorized, e.StatusCode)
+			assert.Equal(t, redaction.RedactedValue, e.Request.Header.Get("Authorization"))
+			assert.NotContains(t, e.Request.URL, "secret")
+			require.Len(t, e.Steps, 1)
+			assert.Equal(t, "jwt", e.Steps[0].Handler)
+			assert.NotContains(t, e.Steps[0].Error, "secret")
+		})
+	}
+
+	t.Run("case=the memory recorder discards the oldest explanations", func(t *testing.T) {
+		m := NewRecorderMemory(redactor, 2)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, m.Record(ctx, explanation(fmt.Sprintf("request-%d", i))))
+		}
+
+		_, ok, _ := m.Get(ctx, "request-0")
+		assert.False(t, ok)
+		for _, id := range []string{"request-1", "request-2"} {
+			_, ok, _ := m.Get(ctx, id)
+			assert.True(t, ok, id)
+		}
+	})
+
+	t.Run("case=a nil trace collects nothing", func(t *testing.T) {
+		var trace *Trace
+		trace.Step("authentication", "jwt", ResultPassed, nil)
+		assert.Empty(t, trace.RequestID())
+		assert.Nil(t, TraceFromContext(ctx))
+	})
+}
@@ -0,0 +1,7 @@
+// +build !fips
+
+package fips
+
+// BuildEnabled is true if ORY Oathkeeper was built with the "fips" build tag, which enforces the FIPS policy
+// regardless of the configuration.
+const BuildEnabled = false
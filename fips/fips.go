@@ -0,0 +1,135 @@
+package fips
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Algorithms are the JSON Web Signature algorithms approved by FIPS 186-4 and FIPS 198-1.
+var Algorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"HS256", "HS384", "HS512",
+}
+
+// CipherSuites are the TLS 1.2 cipher suites approved by NIST SP 800-52. The cipher suites of TLS 1.3 are not
+// configurable and all of them use approved algorithms.
+var CipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// CurvePreferences are the elliptic curves approved for key exchange.
+var CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// MinTLSVersion is the lowest TLS version which may be negotiated.
+const MinTLSVersion = tls.VersionTLS12
+
+const (
+	minRSABits  = 2048
+	minHMACSize = 14 // 112 bits
+)
+
+// ValidateAlgorithms returns an error if any of the algorithms is not approved.
+func ValidateAlgorithms(algorithms []string) error {
+	for _, alg := range algorithms {
+		if !isApproved(alg) {
+			return errors.Errorf(`fips: algorithm "%s" is not approved, use one of: %v`, alg, Algorithms)
+		}
+	}
+	return nil
+}
+
+// ValidateKey returns an error if the key's algorithm is not approved or the key is too weak.
+func ValidateKey(key *jose.JSONWebKey) error {
+	if !isApproved(key.Algorithm) {
+		return errors.Errorf(`fips: key "%s" declares algorithm "%s" which is not approved`, key.KeyID, key.Algorithm)
+	}
+
+	switch k := key.Key.(type) {
+	case *rsa.PrivateKey:
+		return validateRSA(key.KeyID, &k.PublicKey)
+	case *rsa.PublicKey:
+		return validateRSA(key.KeyID, k)
+	case *ecdsa.PrivateKey:
+		return validateCurve(key.KeyID, k.Curve)
+	case *ecdsa.PublicKey:
+		return validateCurve(key.KeyID, k.Curve)
+	case []byte:
+		if len(k) < minHMACSize {
+			return errors.Errorf(`fips: key "%s" must be at least %d bytes long`, key.KeyID, minHMACSize)
+		}
+		return nil
+	default:
+		return errors.Errorf(`fips: key "%s" has type %T which is not approved`, key.KeyID, key.Key)
+	}
+}
+
+// ConfigureTLS restricts the TLS configuration to approved versions, cipher suites and curves. Cipher suites and
+// curves which are configured already and approved are kept in their order of preference.
+func ConfigureTLS(c *tls.Config) *tls.Config {
+	if c.MinVersion < MinTLSVersion {
+		c.MinVersion = MinTLSVersion
+	}
+
+	var suites []uint16
+	for _, s := range c.CipherSuites {
+		for _, approved := range CipherSuites {
+			if s == approved {
+				suites = append(suites, s)
+			}
+		}
+	}
+	if len(suites) == 0 {
+		suites = CipherSuites
+	}
+
+	var curves []tls.CurveID
+	for _, cv := range c.CurvePreferences {
+		for _, approved := range CurvePreferences {
+			if cv == approved {
+				curves = append(curves, cv)
+			}
+		}
+	}
+	if len(curves) == 0 {
+		curves = CurvePreferences
+	}
+
+	c.CipherSuites = suites
+	c.CurvePreferences = curves
+	c.PreferServerCipherSuites = true
+	return c
+}
+
+func isApproved(alg string) bool {
+	for _, a := range Algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func validateRSA(kid string, k *rsa.PublicKey) error {
+	if k.N.BitLen() < minRSABits {
+		return errors.Errorf(`fips: key "%s" must be at least %d bits long`, kid, minRSABits)
+	}
+	return nil
+}
+
+func validateCurve(kid string, c elliptic.Curve) error {
+	switch c {
+	case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		return nil
+	}
+	return errors.Errorf(`fips: key "%s" uses curve %s which is not approved`, kid, c.Params().Name)
+}
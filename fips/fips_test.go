@@ -0,0 +1,73 @@
+package fips
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestValidateAlgorithms(t *testing.T) {
+	require.NoError(t, ValidateAlgorithms([]string{"RS256", "ES384", "PS512", "HS256"}))
+	require.NoError(t, ValidateAlgorithms(nil))
+	require.Error(t, ValidateAlgorithms([]string{"RS256", "EdDSA"}))
+	require.Error(t, ValidateAlgorithms([]string{"none"}))
+}
+
+func TestValidateKey(t *testing.T) {
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for k, tc := range []struct {
+		key       jose.JSONWebKey
+		expectErr bool
+	}{
+		{key: jose.JSONWebKey{Key: rsa2048, Algorithm: "RS256"}},
+		{key: jose.JSONWebKey{Key: &rsa2048.PublicKey, Algorithm: "PS256"}},
+		{key: jose.JSONWebKey{Key: rsa1024, Algorithm: "RS256"}, expectErr: true},
+		{key: jose.JSONWebKey{Key: rsa2048, Algorithm: "none"}, expectErr: true},
+		{key: jose.JSONWebKey{Key: p256, Algorithm: "ES256"}},
+		{key: jose.JSONWebKey{Key: p224, Algorithm: "ES256"}, expectErr: true},
+		{key: jose.JSONWebKey{Key: ed, Algorithm: "EdDSA"}, expectErr: true},
+		{key: jose.JSONWebKey{Key: []byte("0123456789abcdef"), Algorithm: "HS256"}},
+		{key: jose.JSONWebKey{Key: []byte("secret"), Algorithm: "HS256"}, expectErr: true},
+	} {
+		err := ValidateKey(&tc.key)
+		if tc.expectErr {
+			assert.Error(t, err, "%d", k)
+		} else {
+			assert.NoError(t, err, "%d", k)
+		}
+	}
+}
+
+func TestConfigureTLS(t *testing.T) {
+	c := ConfigureTLS(&tls.Config{MinVersion: tls.VersionTLS10})
+	assert.EqualValues(t, tls.VersionTLS12, c.MinVersion)
+	assert.Equal(t, CipherSuites, c.CipherSuites)
+
+	c = ConfigureTLS(&tls.Config{MinVersion: tls.VersionTLS13})
+	assert.EqualValues(t, tls.VersionTLS13, c.MinVersion)
+
+	c = ConfigureTLS(&tls.Config{
+		CipherSuites:     []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP384},
+	})
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, c.CipherSuites)
+	assert.Equal(t, []tls.CurveID{tls.CurveP384}, c.CurvePreferences)
+}
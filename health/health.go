@@ -0,0 +1,125 @@
+// Package health reports the health of the dependencies of ORY Oathkeeper. Dependencies are either hard or soft: an
+// unhealthy hard dependency makes the instance not ready, so that it is taken out of load balancing, while an unhealthy
+// soft dependency only marks the instance as degraded.
+package health
+
+import (
+	"github.com/ory/x/healthx"
+)
+
+const (
+	// WeightHard marks dependencies the instance is not ready without.
+	WeightHard = "hard"
+
+	// WeightSoft marks dependencies the instance is degraded but still ready without.
+	WeightSoft = "soft"
+
+	// StatusOK is the status of an instance whose dependencies are healthy.
+	StatusOK = "ok"
+
+	// StatusDegraded is the status of a ready instance with unhealthy soft dependencies.
+	StatusDegraded = "degraded"
+
+	// StatusUnavailable is the status of an instance with unhealthy hard dependencies.
+	StatusUnavailable = "unavailable"
+)
+
+// Dependency is a dependency whose health is checked.
+type Dependency struct {
+	// Name identifies the dependency in the configuration and the status.
+	Name string
+
+	// Check returns an error if the dependency is unhealthy.
+	Check func() error
+}
+
+// Status is the health of an instance and its dependencies.
+//
+// swagger:model instanceHealthStatus
+type Status struct {
+	// Status is "ok", "degraded" or "unavailable".
+	Status string `json:"status"`
+
+	// Ready is false if a hard dependency is unhealthy.
+	Ready bool `json:"ready"`
+
+	// Degraded is true if a soft dependency is unhealthy.
+	Degraded bool `json:"degraded"`
+
+	// Dependencies are the states of the dependencies by name.
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// DependencyStatus is the health of a dependency.
+type DependencyStatus struct {
+	// Weight is "hard" or "soft".
+	Weight string `json:"weight"`
+
+	// Healthy is false if the check of the dependency failed.
+	Healthy bool `json:"healthy"`
+
+	// Error is the reason the check failed.
+	Error string `json:"error,omitempty"`
+}
+
+type Registry interface {
+	HealthChecker() *Checker
+}
+
+// Checker checks the health of dependencies. Weights are looked up on every check, so that changes of the
+// configuration apply immediately.
+type Checker struct {
+	weight       func(dependency string) string
+	dependencies []Dependency
+}
+
+func NewChecker(weight func(dependency string) string, dependencies ...Dependency) *Checker {
+	return &Checker{weight: weight, dependencies: dependencies}
+}
+
+// Status checks all dependencies.
+func (c *Checker) Status() Status {
+	s := Status{Status: StatusOK, Ready: true, Dependencies: make(map[string]DependencyStatus, len(c.dependencies))}
+	for _, d := range c.dependencies {
+		ds := DependencyStatus{Weight: c.weightOf(d.Name), Healthy: true}
+		if err := d.Check(); err != nil {
+			ds.Healthy = false
+			ds.Error = err.Error()
+			if ds.Weight == WeightHard {
+				s.Ready = false
+			} else {
+				s.Degraded = true
+			}
+		}
+		s.Dependencies[d.Name] = ds
+	}
+
+	if !s.Ready {
+		s.Status = StatusUnavailable
+	} else if s.Degraded {
+		s.Status = StatusDegraded
+	}
+	return s
+}
+
+// ReadyCheckers returns the checks of the readiness endpoint. Only hard dependencies can fail them.
+func (c *Checker) ReadyCheckers() healthx.ReadyCheckers {
+	checks := make(healthx.ReadyCheckers, len(c.dependencies))
+	for _, d := range c.dependencies {
+		d := d
+		checks[d.Name] = func() error {
+			if c.weightOf(d.Name) != WeightHard {
+				return nil
+			}
+			return d.Check()
+		}
+	}
+	return checks
+}
+
+func (c *Checker) weightOf(dependency string) string {
+	if c.weight(dependency) == WeightHard {
+		return WeightHard
+	}
+	return WeightSoft
+}
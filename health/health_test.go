@@ -0,0 +1,66 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	weights := map[string]string{"rules": WeightHard}
+	failing := map[string]bool{}
+	check := func(name string) func() error {
+		return func() error {
+			if failing[name] {
+				return errors.Errorf("%s is unavailable", name)
+			}
+			return nil
+		}
+	}
+
+	c := NewChecker(
+		func(dependency string) string { return weights[dependency] },
+		Dependency{Name: "rules", Check: check("rules")},
+		Dependency{Name: "jwks", Check: check("jwks")},
+	)
+
+	ready := func() error {
+		for _, check := range c.ReadyCheckers() {
+			if err := check(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s := c.Status()
+	assert.Equal(t, StatusOK, s.Status)
+	assert.True(t, s.Ready)
+	assert.False(t, s.Degraded)
+	assert.Equal(t, DependencyStatus{Weight: WeightSoft, Healthy: true}, s.Dependencies["jwks"])
+	require.NoError(t, ready())
+
+	failing["jwks"] = true
+	s = c.Status()
+	assert.Equal(t, StatusDegraded, s.Status)
+	assert.True(t, s.Ready)
+	assert.True(t, s.Degraded)
+	assert.Equal(t, DependencyStatus{Weight: WeightSoft, Error: "jwks is unavailable"}, s.Dependencies["jwks"])
+	require.NoError(t, ready(), "soft dependencies do not fail the readiness checks")
+
+	weights["jwks"] = WeightHard
+	s = c.Status()
+	assert.Equal(t, StatusUnavailable, s.Status)
+	assert.False(t, s.Ready)
+	assert.False(t, s.Degraded)
+	require.Error(t, ready())
+
+	failing["rules"], weights["jwks"] = true, WeightSoft
+	s = c.Status()
+	assert.Equal(t, StatusUnavailable, s.Status)
+	assert.False(t, s.Ready)
+	assert.True(t, s.Degraded)
+	require.Error(t, ready())
+}
@@ -35,6 +35,15 @@ type BearerTokenLocation struct {
 	Cookie         *string `json:"cookie"`
 }
 
+// CookieName returns the name of the cookie the token is read from, or an empty string if it is not read from a
+// cookie.
+func (l *BearerTokenLocation) CookieName() string {
+	if l == nil || l.Header != nil || l.QueryParameter != nil || l.Cookie == nil {
+		return ""
+	}
+	return *l.Cookie
+}
+
 func BearerTokenFromRequest(r *http.Request, tokenLocation *BearerTokenLocation) string {
 	if tokenLocation != nil {
 		if tokenLocation.Header != nil {
@@ -73,4 +73,9 @@ var (
 		CodeField:   http.StatusBadRequest,
 		StatusField: http.StatusText(http.StatusBadRequest),
 	}
+	ErrPreconditionFailed = &herodot.DefaultError{
+		ErrorField:  "The resource was modified since it was last retrieved",
+		CodeField:   http.StatusPreconditionFailed,
+		StatusField: http.StatusText(http.StatusPreconditionFailed),
+	}
 )
@@ -0,0 +1,142 @@
+// Package honeypot sends alerts when requests match access rules marked as honeypots, for example rules for retired
+// paths which legitimate clients never request anymore.
+package honeypot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+)
+
+// Alert describes a request which matched a honeypot rule. Secrets are redacted before the alert is sent.
+type Alert struct {
+	Time      time.Time   `json:"time"`
+	RuleID    string      `json:"rule_id"`
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Host      string      `json:"host"`
+	RemoteIP  string      `json:"remote_ip"`
+	UserAgent string      `json:"user_agent"`
+	Header    http.Header `json:"header"`
+}
+
+// Notifier sends alerts.
+type Notifier interface {
+	// Notify sends the alert in the background.
+	Notify(a Alert)
+}
+
+type Registry interface {
+	HoneypotNotifier() Notifier
+}
+
+var _ Notifier = new(NotifierWebhook)
+
+// NotifierWebhook logs alerts and POSTs them to a webhook, either as JSON or as a Slack-compatible message. Alerts
+// are queued and dropped if the webhook can not keep up, so scanners can not exhaust resources.
+type NotifierWebhook struct {
+	url      func() string
+	format   func() string
+	redactor *redaction.Redactor
+	logger   logrus.FieldLogger
+	client   *http.Client
+	queue    chan Alert
+}
+
+// NewNotifierWebhook creates a new NotifierWebhook and starts sending queued alerts. The url function returns the
+// webhook URL, alerts are only logged if it is empty.
+func NewNotifierWebhook(url, format func() string, redactor *redaction.Redactor, logger logrus.FieldLogger) *NotifierWebhook {
+	n := &NotifierWebhook{
+		url:      url,
+		format:   format,
+		redactor: redactor,
+		logger:   logger,
+		client:   httpx.NewResilientClientLatencyToleranceSmall(nil),
+		queue:    make(chan Alert, 100),
+	}
+	go n.run()
+	return n
+}
+
+func (n *NotifierWebhook) Notify(a Alert) {
+	a.URL = n.redactor.String(a.URL)
+	a.Header = n.redactor.Header(a.Header)
+
+	n.logger.
+		WithField("rule_id", a.RuleID).
+		WithField("http_method", a.Method).
+		WithField("http_url", a.URL).
+		WithField("http_user_agent", a.UserAgent).
+		WithField("remote_ip", a.RemoteIP).
+		Warn("A request matched a honeypot access rule")
+
+	if n.url() == "" {
+		return
+	}
+
+	select {
+	case n.queue <- a:
+	default:
+		n.logger.WithField("rule_id", a.RuleID).Warn("Dropped honeypot alert because too many alerts are queued")
+	}
+}
+
+func (n *NotifierWebhook) run() {
+	for a := range n.queue {
+		if err := n.Send(context.Background(), a); err != nil {
+			n.logger.WithError(err).WithField("rule_id", a.RuleID).Warn("Unable to send honeypot alert")
+		}
+	}
+}
+
+// Send POSTs the alert to the webhook.
+func (n *NotifierWebhook) Send(ctx context.Context, a Alert) error {
+	var payload interface{} = a
+	if n.format() == FormatSlack {
+		details, err := json.MarshalIndent(a, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		payload = map[string]string{
+			"text": fmt.Sprintf("Honeypot rule `%s` matched %s %s from %s\n```%s```", a.RuleID, a.Method, a.URL, a.RemoteIP, details),
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.url(), bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf("expected a 2xx status code but got %d", res.StatusCode)
+	}
+
+	return nil
+}
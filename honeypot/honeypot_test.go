@@ -0,0 +1,74 @@
+package honeypot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/redaction"
+)
+
+func TestNotifierWebhook(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	redactor, err := redaction.NewRedactor(nil, nil)
+	require.NoError(t, err)
+
+	format := FormatJSON
+	n := NewNotifierWebhook(func() string { return server.URL }, func() string { return format }, redactor, logrus.New())
+
+	alert := Alert{
+		RuleID:   "retired",
+		Method:   "GET",
+		URL:      "http://my-app/wp-admin/",
+		RemoteIP: "192.0.2.1",
+		Header:   http.Header{"Authorization": {"Basic YWRtaW46YWRtaW4="}, "Accept": {"*/*"}},
+	}
+
+	t.Run("format=json", func(t *testing.T) {
+		n.Notify(alert)
+
+		select {
+		case body := <-received:
+			assert.Equal(t, "retired", body["rule_id"])
+			assert.Equal(t, "192.0.2.1", body["remote_ip"])
+			header := body["header"].(map[string]interface{})
+			assert.Equal(t, []interface{}{"[REDACTED]"}, header["Authorization"])
+			assert.Equal(t, []interface{}{"*/*"}, header["Accept"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("no alert was received")
+		}
+	})
+
+	t.Run("format=slack", func(t *testing.T) {
+		format = FormatSlack
+		require.NoError(t, n.Send(context.Background(), alert))
+
+		body := <-received
+		assert.Contains(t, body["text"], "Honeypot rule `retired` matched GET http://my-app/wp-admin/ from 192.0.2.1")
+	})
+
+	t.Run("case=webhook fails", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer failing.Close()
+
+		n := NewNotifierWebhook(func() string { return failing.URL }, func() string { return FormatJSON }, redactor, logrus.New())
+		require.Error(t, n.Send(context.Background(), alert))
+	})
+}
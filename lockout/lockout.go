@@ -0,0 +1,209 @@
+// Package lockout protects against brute-force attacks by tracking failed authentication attempts. Requests are
+// delayed once a key (for example the client's IP address) failed too often and rejected once it failed even more.
+package lockout
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+)
+
+// ErrLocked is returned for requests whose key is locked.
+var ErrLocked = &herodot.DefaultError{
+	ErrorField:  "Too many failed authentication attempts, try again later",
+	CodeField:   http.StatusTooManyRequests,
+	StatusField: http.StatusText(http.StatusTooManyRequests),
+}
+
+// Policy defines when requests are delayed and when they are rejected.
+type Policy struct {
+	// Window is for how long failed attempts are counted, starting with the first one.
+	Window time.Duration
+
+	// DelayAfter is the number of failed attempts after which requests are delayed.
+	DelayAfter int
+
+	// Delay is the delay after DelayAfter failed attempts. It doubles with every further failed attempt.
+	Delay time.Duration
+
+	// MaxDelay caps the delay.
+	MaxDelay time.Duration
+
+	// LockAfter is the number of failed attempts after which requests are rejected.
+	LockAfter int
+
+	// LockDuration is for how long requests are rejected.
+	LockDuration time.Duration
+}
+
+// Status is the state of a key.
+type Status struct {
+	// Key identifies what failed, for example "ip:192.0.2.1".
+	Key string `json:"key"`
+
+	// Failures is the number of failed attempts in the current window.
+	Failures int `json:"failures"`
+
+	// LockedUntil is the point in time until which requests are rejected, if the key is locked.
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// Locked returns true if the key is locked at the given point in time.
+func (s *Status) Locked(now time.Time) bool {
+	return s.LockedUntil != nil && s.LockedUntil.After(now)
+}
+
+// Delay returns how long requests of the key are delayed according to the policy.
+func (s *Status) Delay(p Policy) time.Duration {
+	if p.DelayAfter <= 0 || s.Failures < p.DelayAfter {
+		return 0
+	}
+
+	delay := p.Delay
+	for i := p.DelayAfter; i < s.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// Store keeps track of failed attempts. Instances of ORY Oathkeeper sharing a store share lockouts.
+type Store interface {
+	// Fail records a failed attempt of the key and returns its updated status.
+	Fail(ctx context.Context, key string, p Policy) (*Status, error)
+
+	// Get returns the status of the key.
+	Get(ctx context.Context, key string) (*Status, error)
+
+	// Reset forgets all failed attempts of the key and unlocks it.
+	Reset(ctx context.Context, key string) error
+
+	// Locked returns the status of all locked keys.
+	Locked(ctx context.Context) ([]Status, error)
+}
+
+// Stats counts what the tracker did since the process started.
+type Stats struct {
+	// Failures is the number of failed attempts recorded.
+	Failures uint64 `json:"failures"`
+
+	// Lockouts is the number of times a key was locked.
+	Lockouts uint64 `json:"lockouts"`
+
+	// Delayed is the number of requests which were delayed.
+	Delayed uint64 `json:"delayed"`
+
+	// Rejected is the number of requests which were rejected because their key was locked.
+	Rejected uint64 `json:"rejected"`
+}
+
+// Tracker applies the policy to requests using the store.
+type Tracker struct {
+	// The counters are accessed atomically and must stay 64-bit aligned.
+	failures uint64
+	lockouts uint64
+	delayed  uint64
+	rejected uint64
+
+	s      Store
+	policy func() Policy
+}
+
+type Registry interface {
+	LockoutTracker() *Tracker
+}
+
+// NewTracker creates a new Tracker. The policy function returns the current policy.
+func NewTracker(s Store, policy func() Policy) *Tracker {
+	return &Tracker{s: s, policy: policy}
+}
+
+// Check returns ErrLocked if any of the keys is locked and otherwise waits as long as the policy demands for the
+// key with the most failed attempts.
+func (t *Tracker) Check(ctx context.Context, keys []string) error {
+	p := t.policy()
+	now := time.Now()
+
+	var delay time.Duration
+	for _, key := range keys {
+		s, err := t.s.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if s.Locked(now) {
+			atomic.AddUint64(&t.rejected, 1)
+			return errors.WithStack(ErrLocked.
+				WithReasonf("Authentication attempts of %s are locked until %s.", key, s.LockedUntil.Format(time.RFC3339)).
+				WithDetail("retry_after", strconv.Itoa(int(s.LockedUntil.Sub(now).Seconds())+1)))
+		}
+
+		if d := s.Delay(p); d > delay {
+			delay = d
+		}
+	}
+
+	if delay == 0 {
+		return nil
+	}
+
+	atomic.AddUint64(&t.delayed, 1)
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// Fail records a failed attempt for each of the keys.
+func (t *Tracker) Fail(ctx context.Context, keys []string) error {
+	p := t.policy()
+	now := time.Now()
+	for _, key := range keys {
+		s, err := t.s.Fail(ctx, key, p)
+		if err != nil {
+			return err
+		}
+
+		atomic.AddUint64(&t.failures, 1)
+		if s.Locked(now) && s.Failures == p.LockAfter {
+			atomic.AddUint64(&t.lockouts, 1)
+		}
+	}
+	return nil
+}
+
+// Reset forgets the failed attempts of each of the keys.
+func (t *Tracker) Reset(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := t.s.Reset(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Locked returns the status of all locked keys.
+func (t *Tracker) Locked(ctx context.Context) ([]Status, error) {
+	return t.s.Locked(ctx)
+}
+
+// Stats returns what the tracker did since the process started.
+func (t *Tracker) Stats() Stats {
+	return Stats{
+		Failures: atomic.LoadUint64(&t.failures),
+		Lockouts: atomic.LoadUint64(&t.lockouts),
+		Delayed:  atomic.LoadUint64(&t.delayed),
+		Rejected: atomic.LoadUint64(&t.rejected),
+	}
+}
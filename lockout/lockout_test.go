@@ -0,0 +1,88 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+)
+
+func TestStatusDelay(t *testing.T) {
+	p := Policy{DelayAfter: 3, Delay: time.Second, MaxDelay: 5 * time.Second}
+	for k, tc := range []struct {
+		failures int
+		expect   time.Duration
+	}{
+		{failures: 0, expect: 0},
+		{failures: 2, expect: 0},
+		{failures: 3, expect: time.Second},
+		{failures: 4, expect: 2 * time.Second},
+		{failures: 5, expect: 4 * time.Second},
+		{failures: 6, expect: 5 * time.Second},
+		{failures: 100, expect: 5 * time.Second},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			assert.Equal(t, tc.expect, (&Status{Failures: tc.failures}).Delay(p))
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	ctx := context.Background()
+	p := Policy{Window: time.Minute, DelayAfter: 2, Delay: 10 * time.Millisecond, MaxDelay: 20 * time.Millisecond, LockAfter: 4, LockDuration: time.Minute}
+	tr := NewTracker(NewStoreMemory(), func() Policy { return p })
+	keys := []string{"ip:192.0.2.1", "subject:alice"}
+
+	require.NoError(t, tr.Check(ctx, keys))
+	require.NoError(t, tr.Fail(ctx, keys))
+	require.NoError(t, tr.Fail(ctx, keys))
+
+	// Delayed but not locked.
+	start := time.Now()
+	require.NoError(t, tr.Check(ctx, keys))
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+
+	require.NoError(t, tr.Fail(ctx, keys))
+	require.NoError(t, tr.Fail(ctx, keys))
+
+	err := tr.Check(ctx, keys)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, herodot.ToDefaultError(err, "").StatusCode())
+
+	locked, err := tr.Locked(ctx)
+	require.NoError(t, err)
+	assert.Len(t, locked, 2)
+
+	// Unlocking the subject does not unlock the IP address.
+	require.NoError(t, tr.Reset(ctx, []string{"subject:alice"}))
+	require.NoError(t, tr.Check(ctx, []string{"subject:alice"}))
+	require.Error(t, tr.Check(ctx, []string{"ip:192.0.2.1"}))
+
+	assert.Equal(t, Stats{Failures: 8, Lockouts: 2, Delayed: 1, Rejected: 2}, tr.Stats())
+}
+
+func TestStoreMemoryWindow(t *testing.T) {
+	ctx := context.Background()
+	s := NewStoreMemory()
+	p := Policy{Window: 10 * time.Millisecond, LockAfter: 10}
+
+	status, err := s.Fail(ctx, "ip:192.0.2.1", p)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Failures)
+
+	time.Sleep(20 * time.Millisecond)
+
+	status, err = s.Get(ctx, "ip:192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, status.Failures)
+
+	status, err = s.Fail(ctx, "ip:192.0.2.1", p)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Failures)
+}
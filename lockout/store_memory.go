@@ -0,0 +1,110 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Store = new(StoreMemory)
+
+type memoryEntry struct {
+	failures    int
+	firstAt     time.Time
+	lockedUntil time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time, window time.Duration) bool {
+	if !e.lockedUntil.IsZero() {
+		return !e.lockedUntil.After(now)
+	}
+	return !e.firstAt.Add(window).After(now)
+}
+
+// StoreMemory keeps failed attempts in memory. Lockouts are not shared between instances.
+type StoreMemory struct {
+	sync.RWMutex
+
+	window  time.Duration
+	entries map[string]*memoryEntry
+}
+
+// NewStoreMemory creates a new StoreMemory.
+func NewStoreMemory() *StoreMemory {
+	return &StoreMemory{entries: map[string]*memoryEntry{}}
+}
+
+func (s *StoreMemory) Fail(_ context.Context, key string, p Policy) (*Status, error) {
+	now := time.Now()
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.window = p.Window
+	s.gc(now)
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &memoryEntry{firstAt: now}
+		s.entries[key] = e
+	}
+
+	e.failures++
+	if p.LockAfter > 0 && e.failures >= p.LockAfter && e.lockedUntil.IsZero() {
+		e.lockedUntil = now.Add(p.LockDuration)
+	}
+
+	return e.status(key), nil
+}
+
+func (s *StoreMemory) Get(_ context.Context, key string) (*Status, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.expired(time.Now(), s.window) {
+		return &Status{Key: key}, nil
+	}
+	return e.status(key), nil
+}
+
+func (s *StoreMemory) Reset(_ context.Context, key string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *StoreMemory) Locked(_ context.Context) ([]Status, error) {
+	now := time.Now()
+
+	s.RLock()
+	defer s.RUnlock()
+
+	locked := make([]Status, 0)
+	for key, e := range s.entries {
+		if e.lockedUntil.After(now) {
+			locked = append(locked, *e.status(key))
+		}
+	}
+	return locked, nil
+}
+
+// gc removes expired entries, the caller must hold the write lock.
+func (s *StoreMemory) gc(now time.Time) {
+	for key, e := range s.entries {
+		if e.expired(now, s.window) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func (e *memoryEntry) status(key string) *Status {
+	s := &Status{Key: key, Failures: e.failures}
+	if !e.lockedUntil.IsZero() {
+		lockedUntil := e.lockedUntil
+		s.LockedUntil = &lockedUntil
+	}
+	return s
+}
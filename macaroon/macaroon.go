@@ -0,0 +1,225 @@
+// Package macaroon implements verification of macaroons in the version 2 binary format with first-party caveats.
+// Signatures are computed like in libmacaroons and gopkg.in/macaroon.v2, so macaroons minted by those libraries
+// can be verified using the same root key.
+package macaroon
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	fieldEOS        = 0
+	fieldLocation   = 1
+	fieldIdentifier = 2
+	fieldVID        = 4
+	fieldSignature  = 6
+
+	version2 = 2
+)
+
+var (
+	ErrMalformed        = errors.New("macaroon: macaroon is malformed")
+	ErrInvalidSignature = errors.New("macaroon: macaroon signature is invalid")
+	ErrThirdParty       = errors.New("macaroon: third-party caveats are not supported")
+)
+
+// keyGenerator derives the signing key from the root key, as done by libmacaroons.
+var keyGenerator = []byte("macaroons-key-generator")
+
+// Caveat is a caveat of a macaroon. First-party caveats have no verification ID.
+type Caveat struct {
+	Location string
+	ID       []byte
+	VID      []byte
+}
+
+// Macaroon is a decoded macaroon.
+type Macaroon struct {
+	Location  string
+	ID        []byte
+	Caveats   []Caveat
+	Signature []byte
+}
+
+// New mints a macaroon without caveats.
+func New(rootKey, id []byte, location string) *Macaroon {
+	return &Macaroon{Location: location, ID: id, Signature: keyedHash(deriveKey(rootKey), id)}
+}
+
+// AddFirstPartyCaveat adds the caveat and updates the signature.
+func (m *Macaroon) AddFirstPartyCaveat(condition string) {
+	m.Caveats = append(m.Caveats, Caveat{ID: []byte(condition)})
+	m.Signature = keyedHash(m.Signature, []byte(condition))
+}
+
+// Conditions returns the conditions of all first-party caveats.
+func (m *Macaroon) Conditions() []string {
+	conditions := make([]string, 0, len(m.Caveats))
+	for _, c := range m.Caveats {
+		if len(c.VID) == 0 {
+			conditions = append(conditions, string(c.ID))
+		}
+	}
+	return conditions
+}
+
+// Verify checks the signature chain of the macaroon using the root key. It does not check the caveats.
+func (m *Macaroon) Verify(rootKey []byte) error {
+	sig := keyedHash(deriveKey(rootKey), m.ID)
+	for _, c := range m.Caveats {
+		if len(c.VID) > 0 {
+			return errors.WithStack(ErrThirdParty)
+		}
+		sig = keyedHash(sig, c.ID)
+	}
+
+	if !hmac.Equal(sig, m.Signature) {
+		return errors.WithStack(ErrInvalidSignature)
+	}
+	return nil
+}
+
+// Encode returns the URL-safe base64 encoded binary representation of the macaroon.
+func (m *Macaroon) Encode() string {
+	var b bytes.Buffer
+	b.WriteByte(version2)
+	if m.Location != "" {
+		writeField(&b, fieldLocation, []byte(m.Location))
+	}
+	writeField(&b, fieldIdentifier, m.ID)
+	b.WriteByte(fieldEOS)
+	for _, c := range m.Caveats {
+		if c.Location != "" {
+			writeField(&b, fieldLocation, []byte(c.Location))
+		}
+		writeField(&b, fieldIdentifier, c.ID)
+		if len(c.VID) > 0 {
+			writeField(&b, fieldVID, c.VID)
+		}
+		b.WriteByte(fieldEOS)
+	}
+	b.WriteByte(fieldEOS)
+	writeField(&b, fieldSignature, m.Signature)
+	return base64.RawURLEncoding.EncodeToString(b.Bytes())
+}
+
+// Decode parses a base64 encoded macaroon in the version 2 binary format. Both the standard and the URL-safe
+// alphabet are accepted, with or without padding.
+func Decode(token string) (*Macaroon, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.NewReplacer("+", "-", "/", "_").Replace(token), "="))
+	if err != nil || len(raw) == 0 || raw[0] != version2 {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+
+	d := &decoder{data: raw[1:]}
+	var m Macaroon
+
+	fields, err := d.section()
+	if err != nil {
+		return nil, err
+	}
+	m.Location = string(fields[fieldLocation])
+	m.ID = fields[fieldIdentifier]
+	if m.ID == nil {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+
+	for {
+		if len(d.data) == 0 {
+			return nil, errors.WithStack(ErrMalformed)
+		}
+		if d.data[0] == fieldEOS {
+			d.data = d.data[1:]
+			break
+		}
+
+		fields, err := d.section()
+		if err != nil {
+			return nil, err
+		}
+		if fields[fieldIdentifier] == nil {
+			return nil, errors.WithStack(ErrMalformed)
+		}
+		m.Caveats = append(m.Caveats, Caveat{
+			Location: string(fields[fieldLocation]),
+			ID:       fields[fieldIdentifier],
+			VID:      fields[fieldVID],
+		})
+	}
+
+	typ, sig, err := d.field()
+	if err != nil || typ != fieldSignature || len(sig) != sha256.Size {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+	m.Signature = sig
+
+	return &m, nil
+}
+
+type decoder struct {
+	data []byte
+}
+
+// section reads fields until the end of section marker.
+func (d *decoder) section() (map[byte][]byte, error) {
+	fields := map[byte][]byte{}
+	for {
+		if len(d.data) == 0 {
+			return nil, errors.WithStack(ErrMalformed)
+		}
+		if d.data[0] == fieldEOS {
+			d.data = d.data[1:]
+			return fields, nil
+		}
+
+		typ, value, err := d.field()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := fields[typ]; ok {
+			return nil, errors.WithStack(ErrMalformed)
+		}
+		fields[typ] = value
+	}
+}
+
+func (d *decoder) field() (byte, []byte, error) {
+	if len(d.data) == 0 {
+		return 0, nil, errors.WithStack(ErrMalformed)
+	}
+
+	typ := d.data[0]
+	length, n := binary.Uvarint(d.data[1:])
+	if n <= 0 || uint64(len(d.data)-1-n) < length {
+		return 0, nil, errors.WithStack(ErrMalformed)
+	}
+
+	start := 1 + n
+	value := d.data[start : start+int(length)]
+	d.data = d.data[start+int(length):]
+	return typ, value, nil
+}
+
+func writeField(b *bytes.Buffer, typ byte, value []byte) {
+	var length [binary.MaxVarintLen64]byte
+	b.WriteByte(typ)
+	b.Write(length[:binary.PutUvarint(length[:], uint64(len(value)))])
+	b.Write(value)
+}
+
+func deriveKey(rootKey []byte) []byte {
+	return keyedHash(keyGenerator, rootKey)
+}
+
+func keyedHash(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,81 @@
+package macaroon
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMacaroon(t *testing.T) {
+	rootKey := []byte("this is our super secret key; only we should know it")
+
+	t.Run("case=libmacaroons signatures", func(t *testing.T) {
+		m := New(rootKey, []byte("we used our secret key"), "http://mybank/")
+		assert.Equal(t, "e3d9e02908526c4c0039ae15114115d97fdd68bf2ba379b342aaf0f617d0552f", hex.EncodeToString(m.Signature))
+
+		m.AddFirstPartyCaveat("account = 3735928559")
+		assert.Equal(t, "1efe4763f290dbce0c1d08477367e11f4eee456a64933cf662d79772dbb82128", hex.EncodeToString(m.Signature))
+	})
+
+	t.Run("case=encode and decode", func(t *testing.T) {
+		m := New(rootKey, []byte("id"), "http://mybank/")
+		m.AddFirstPartyCaveat("subject alice")
+		m.AddFirstPartyCaveat("method GET")
+
+		for _, token := range []string{
+			m.Encode(),
+			base64.StdEncoding.EncodeToString(mustDecode(t, m.Encode())),
+		} {
+			decoded, err := Decode(token)
+			require.NoError(t, err)
+			assert.Equal(t, m, decoded)
+			assert.Equal(t, []string{"subject alice", "method GET"}, decoded.Conditions())
+			require.NoError(t, decoded.Verify(rootKey))
+			assert.Equal(t, ErrInvalidSignature, errors.Cause(decoded.Verify([]byte("other key"))))
+		}
+	})
+
+	t.Run("case=tampered caveat", func(t *testing.T) {
+		m := New(rootKey, []byte("id"), "")
+		m.AddFirstPartyCaveat("method GET")
+		m.Caveats[0].ID = []byte("method POST")
+
+		decoded, err := Decode(m.Encode())
+		require.NoError(t, err)
+		assert.Equal(t, ErrInvalidSignature, errors.Cause(decoded.Verify(rootKey)))
+	})
+
+	t.Run("case=third-party caveat", func(t *testing.T) {
+		m := New(rootKey, []byte("id"), "")
+		m.Caveats = append(m.Caveats, Caveat{Location: "http://auth/", ID: []byte("third-party"), VID: []byte("vid")})
+
+		decoded, err := Decode(m.Encode())
+		require.NoError(t, err)
+		assert.Empty(t, decoded.Conditions())
+		assert.Equal(t, ErrThirdParty, errors.Cause(decoded.Verify(rootKey)))
+	})
+
+	t.Run("case=malformed", func(t *testing.T) {
+		valid := mustDecode(t, New(rootKey, []byte("id"), "").Encode())
+		for _, token := range []string{
+			"",
+			"not base64!",
+			base64.RawURLEncoding.EncodeToString([]byte{1, 2, 3}),
+			base64.RawURLEncoding.EncodeToString(valid[:len(valid)-1]),
+			base64.RawURLEncoding.EncodeToString(valid[:5]),
+		} {
+			_, err := Decode(token)
+			assert.Equal(t, ErrMalformed, errors.Cause(err), token)
+		}
+	})
+}
+
+func mustDecode(t *testing.T, token string) []byte {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	require.NoError(t, err)
+	return raw
+}
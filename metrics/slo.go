@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/rule"
+)
+
+const (
+	// ObjectiveAvailability is met by requests which do not fail with a 5xx status code.
+	ObjectiveAvailability = "availability"
+
+	// ObjectiveLatency is met by decisions which are reached within the latency of the objective.
+	ObjectiveLatency = "latency"
+
+	// MetricSLI is the share of requests meeting an objective within a window.
+	MetricSLI = "slo.sli"
+
+	// MetricBurnRate is the rate at which the error budget of an objective is consumed within a window. A burn rate
+	// of 1 consumes exactly the error budget.
+	MetricBurnRate = "slo.burn_rate"
+
+	// MetricErrorBudgetRemaining is the share of the error budget of an objective left within the longest window.
+	MetricErrorBudgetRemaining = "slo.error_budget_remaining"
+
+	// bucketsPerWindow is the number of buckets the shortest window is divided into.
+	bucketsPerWindow = 10
+)
+
+// SLOReport is the service level indicator and burn rate of an objective of an access rule within a window.
+type SLOReport struct {
+	RuleID    string
+	Objective string
+	Window    time.Duration
+
+	// Target is the share of requests which must meet the objective.
+	Target float64
+
+	// Requests is the number of requests within the window.
+	Requests int
+
+	// SLI is the share of requests meeting the objective within the window, 1 if there were no requests.
+	SLI float64
+
+	// BurnRate is the share of failing requests divided by the share the objective allows to fail.
+	BurnRate float64
+}
+
+// ErrorBudgetRemaining returns the share of the error budget left within the window. It is negative if the budget
+// is exhausted.
+func (r SLOReport) ErrorBudgetRemaining() float64 {
+	return 1 - r.BurnRate
+}
+
+// SLOTracker counts the requests meeting and failing the service level objectives of access rules within a set of
+// sliding windows.
+type SLOTracker struct {
+	sync.Mutex
+	windows []time.Duration
+	bucket  time.Duration
+	rules   map[string]*sloRule
+}
+
+type sloRule struct {
+	slo     rule.SLO
+	latency time.Duration
+	buckets []sloBucket
+}
+
+type sloBucket struct {
+	index, total, errors, slow int64
+}
+
+// NewSLOTracker returns a tracker computing service level indicators within the given windows.
+func NewSLOTracker(windows []time.Duration) *SLOTracker {
+	windows = append([]time.Duration{}, windows...)
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+	if len(windows) == 0 {
+		windows = []time.Duration{time.Hour}
+	}
+
+	bucket := windows[0] / bucketsPerWindow
+	if bucket < time.Second {
+		bucket = time.Second
+	}
+
+	return &SLOTracker{windows: windows, bucket: bucket, rules: map[string]*sloRule{}}
+}
+
+// Windows returns the windows of the tracker ordered from shortest to longest.
+func (t *SLOTracker) Windows() []time.Duration {
+	return t.windows
+}
+
+// SetRules replaces the tracked access rules. Counts of access rules whose objectives did not change are kept.
+func (t *SLOTracker) SetRules(rules []rule.Rule) {
+	t.Lock()
+	defer t.Unlock()
+
+	size := int(math.Ceil(float64(t.windows[len(t.windows)-1]) / float64(t.bucket)))
+	next := map[string]*sloRule{}
+	for _, r := range rules {
+		if r.Observability == nil || r.Observability.SLO == nil {
+			continue
+		}
+
+		latency, err := r.Observability.SLO.LatencyThreshold()
+		if err != nil {
+			continue
+		}
+
+		if current, ok := t.rules[r.ID]; ok && sameObjectives(current.slo, *r.Observability.SLO) {
+			next[r.ID] = current
+			continue
+		}
+
+		next[r.ID] = &sloRule{slo: *r.Observability.SLO, latency: latency, buckets: make([]sloBucket, size)}
+	}
+	t.rules = next
+}
+
+func sameObjectives(a, b rule.SLO) bool {
+	equal := func(x, y *float64) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && *x == *y)
+	}
+	return a.Latency == b.Latency && equal(a.Availability, b.Availability) && equal(a.LatencyTarget, b.LatencyTarget)
+}
+
+// Record counts the decision if its access rule declares objectives.
+func (t *SLOTracker) Record(d *events.Decision, at time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	r, ok := t.rules[d.RuleID]
+	if !ok {
+		return
+	}
+
+	index := int64(at.UnixNano() / int64(t.bucket))
+	b := &r.buckets[index%int64(len(r.buckets))]
+	if b.index != index {
+		*b = sloBucket{index: index}
+	}
+
+	b.total++
+	if d.StatusCode >= 500 {
+		b.errors++
+	}
+	if r.latency > 0 && d.LatencyMS > float64(r.latency)/float64(time.Millisecond) {
+		b.slow++
+	}
+}
+
+// Report returns the reports of all objectives of all tracked access rules for every window.
+func (t *SLOTracker) Report(now time.Time) []SLOReport {
+	t.Lock()
+	defer t.Unlock()
+
+	ids := make([]string, 0, len(t.rules))
+	for id := range t.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	current := int64(now.UnixNano() / int64(t.bucket))
+	var reports []SLOReport
+	for _, id := range ids {
+		r := t.rules[id]
+		for _, window := range t.windows {
+			first := current - int64(window/t.bucket) + 1
+
+			var total, errs, slow int64
+			for _, b := range r.buckets {
+				if b.index >= first && b.index <= current {
+					total += b.total
+					errs += b.errors
+					slow += b.slow
+				}
+			}
+
+			if r.slo.Availability != nil {
+				reports = append(reports, newSLOReport(id, ObjectiveAvailability, window, *r.slo.Availability, total, errs))
+			}
+			if r.slo.LatencyTarget != nil && r.latency > 0 {
+				reports = append(reports, newSLOReport(id, ObjectiveLatency, window, *r.slo.LatencyTarget, total, slow))
+			}
+		}
+	}
+	return reports
+}
+
+func newSLOReport(id, objective string, window time.Duration, target float64, total, failed int64) SLOReport {
+	r := SLOReport{RuleID: id, Objective: objective, Window: window, Target: target, Requests: int(total), SLI: 1}
+	if total > 0 {
+		r.SLI = 1 - float64(failed)/float64(total)
+		r.BurnRate = (float64(failed) / float64(total)) / (1 - target)
+	}
+	return r
+}
+
+// formatWindow formats the window without trailing zero units, for example "5m" instead of "5m0s".
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/metrics"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func target(f float64) *float64 {
+	return &f
+}
+
+func TestSLOTracker(t *testing.T) {
+	tracker := metrics.NewSLOTracker([]time.Duration{time.Hour, time.Minute * 5})
+	assert.Equal(t, []time.Duration{time.Minute * 5, time.Hour}, tracker.Windows())
+
+	tracker.SetRules([]rule.Rule{
+		{ID: "users", Observability: &rule.Observability{SLO: &rule.SLO{
+			Availability: target(0.9), Latency: "50ms", LatencyTarget: target(0.5),
+		}}},
+		{ID: "untracked"},
+	})
+
+	now := time.Now()
+	earlier := now.Add(-time.Minute * 30)
+	for _, d := range []struct {
+		at      time.Time
+		code    int
+		latency float64
+	}{
+		{at: earlier, code: 500, latency: 10},
+		{at: earlier, code: 500, latency: 10},
+		{at: now, code: 200, latency: 10},
+		{at: now, code: 403, latency: 100},
+		{at: now, code: 502, latency: 10},
+		{at: now, code: 200, latency: 10},
+	} {
+		tracker.Record(&events.Decision{RuleID: "users", StatusCode: d.code, LatencyMS: d.latency}, d.at)
+	}
+	tracker.Record(&events.Decision{RuleID: "untracked", StatusCode: 500}, now)
+
+	reports := tracker.Report(now)
+	require.Len(t, reports, 4)
+
+	short := reports[0]
+	assert.Equal(t, metrics.ObjectiveAvailability, short.Objective)
+	assert.Equal(t, time.Minute*5, short.Window)
+	assert.Equal(t, 4, short.Requests)
+	assert.InDelta(t, 0.75, short.SLI, 0.0001)
+	assert.InDelta(t, 2.5, short.BurnRate, 0.0001)
+
+	assert.Equal(t, metrics.ObjectiveLatency, reports[1].Objective)
+	assert.InDelta(t, 0.75, reports[1].SLI, 0.0001)
+	assert.InDelta(t, 0.5, reports[1].BurnRate, 0.0001)
+
+	long := reports[2]
+	assert.Equal(t, metrics.ObjectiveAvailability, long.Objective)
+	assert.Equal(t, time.Hour, long.Window)
+	assert.Equal(t, 6, long.Requests)
+	assert.InDelta(t, 0.5, long.SLI, 0.0001)
+	assert.InDelta(t, -4, long.ErrorBudgetRemaining(), 0.0001)
+
+	t.Run("case=counts are kept if the objectives did not change", func(t *testing.T) {
+		tracker.SetRules([]rule.Rule{{ID: "users", Observability: &rule.Observability{SLO: &rule.SLO{
+			Availability: target(0.9), Latency: "50ms", LatencyTarget: target(0.5),
+		}}}})
+		assert.Equal(t, 6, tracker.Report(now)[2].Requests)
+
+		tracker.SetRules([]rule.Rule{{ID: "users", Observability: &rule.Observability{SLO: &rule.SLO{
+			Availability: target(0.99),
+		}}}})
+		reports := tracker.Report(now)
+		require.Len(t, reports, 2)
+		assert.Equal(t, 0, reports[1].Requests)
+		assert.Equal(t, float64(1), reports[1].SLI)
+	})
+}
+
+func TestStatsDSLOLines(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	s := metrics.NewStatsD(conf, nil, nil, logrus.New())
+
+	windows := []time.Duration{time.Minute * 5, time.Hour}
+	assert.Equal(t, []string{
+		"oathkeeper.slo.sli:0.99|g|#rule_id:users,objective:availability,window:5m",
+		"oathkeeper.slo.burn_rate:2|g|#rule_id:users,objective:availability,window:5m",
+		"oathkeeper.slo.sli:0.995|g|#rule_id:users,objective:availability,window:1h",
+		"oathkeeper.slo.burn_rate:1|g|#rule_id:users,objective:availability,window:1h",
+		"oathkeeper.slo.error_budget_remaining:0|g|#rule_id:users,objective:availability,window:1h",
+	}, s.SLOLines([]metrics.SLOReport{
+		{RuleID: "users", Objective: metrics.ObjectiveAvailability, Window: time.Minute * 5, SLI: 0.99, BurnRate: 2},
+		{RuleID: "users", Objective: metrics.ObjectiveAvailability, Window: time.Hour, SLI: 0.995, BurnRate: 1},
+	}, windows))
+}
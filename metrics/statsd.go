@@ -0,0 +1,341 @@
+// Package metrics sends metrics of the access control decisions, access rule reloads and the service level objectives
+// of access rules to a StatsD or DogStatsD server.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/health"
+	"github.com/ory/oathkeeper/rule"
+)
+
+const (
+	// TagFormatDatadog appends tags the way DogStatsD expects them: "name:1|c|#key:value".
+	TagFormatDatadog = "datadog"
+
+	// TagFormatInfluxDB appends tags to the metric name the way the StatsD input of Telegraf expects them:
+	// "name,key=value:1|c".
+	TagFormatInfluxDB = "influxdb"
+
+	// TagFormatNone drops all tags for servers which do not support them.
+	TagFormatNone = "none"
+
+	// MetricRequests counts the access control decisions.
+	MetricRequests = "requests"
+
+	// MetricDecisionLatency is the time ORY Oathkeeper took to reach a decision.
+	MetricDecisionLatency = "decision_latency"
+
+	// MetricRuleReloads counts the reloads of access rule repositories.
+	MetricRuleReloads = "rule_reloads"
+
+	// MetricUpstreamErrors counts the granted requests whose upstream could not be reached, by reason.
+	MetricUpstreamErrors = "upstream_errors"
+
+	// MetricExpiredRules is the number of access rules whose expiry date has passed.
+	MetricExpiredRules = "expired_rules"
+
+	// MetricReady is 1 if the instance is ready and 0 if a hard dependency is unhealthy.
+	MetricReady = "ready"
+
+	// MetricDegraded is 1 if a soft dependency is unhealthy and 0 otherwise.
+	MetricDegraded = "degraded"
+
+	// maxPacketSize keeps packets below the MTU of most networks.
+	maxPacketSize = 1432
+)
+
+type Registry interface {
+	StatsD() *StatsD
+}
+
+// StatsD subscribes to the event bus and sends the metrics to the configured StatsD server over UDP. Metrics are
+// buffered and sent at the flush interval or whenever a packet is full.
+type StatsD struct {
+	c      configuration.Provider
+	bus    *events.Bus
+	rules  rule.Repository
+	logger logrus.FieldLogger
+	health func() health.Status
+}
+
+func NewStatsD(c configuration.Provider, bus *events.Bus, rules rule.Repository, logger logrus.FieldLogger) *StatsD {
+	return &StatsD{c: c, bus: bus, rules: rules, logger: logger}
+}
+
+// SetHealth sets the function returning the health of the instance, which is sent as the gauges MetricReady and
+// MetricDegraded.
+func (s *StatsD) SetHealth(health func() health.Status) {
+	s.health = health
+}
+
+// Run sends metrics until the context is canceled. Events are only consumed while a StatsD server is configured.
+func (s *StatsD) Run(ctx context.Context) {
+	var sub *events.Subscription
+	var conn net.Conn
+	var address string
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	var buf bytes.Buffer
+	var slo *SLOTracker
+	var expired int
+	var expiredReported bool
+	for {
+		interval := s.c.StatsDFlushInterval()
+		if interval <= 0 {
+			interval = time.Second
+		}
+
+		if next := s.c.StatsDAddress(); next != address {
+			if conn != nil {
+				_ = conn.Close()
+				conn = nil
+			}
+			address = next
+			if address != "" {
+				var err error
+				if conn, err = net.Dial("udp", address); err != nil {
+					s.logger.WithError(errors.WithStack(err)).WithField("address", address).Warn("Unable to connect to the StatsD server")
+					address = ""
+				}
+			}
+		}
+
+		if conn == nil {
+			if sub != nil {
+				sub.Close()
+				sub = nil
+			}
+		} else if sub == nil {
+			sub = s.bus.Subscribe(4096, events.Filter{})
+		}
+
+		if windows := s.c.SLOWindows(); slo == nil || !reflect.DeepEqual(windows, slo.Windows()) {
+			slo = NewSLOTracker(windows)
+		}
+		if conn != nil {
+			expired = s.refreshRules(ctx, slo)
+		}
+
+		var received <-chan events.Event
+		if sub != nil {
+			received = sub.Events()
+		}
+
+		timeout := time.After(interval)
+	collect:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timeout:
+				break collect
+			case e := <-received:
+				if e.Decision != nil {
+					slo.Record(e.Decision, time.Now())
+				}
+				for _, line := range s.Lines(e) {
+					s.append(conn, &buf, line)
+				}
+			}
+		}
+
+		if conn != nil {
+			for _, line := range s.SLOLines(slo.Report(time.Now()), slo.Windows()) {
+				s.append(conn, &buf, line)
+			}
+			// The gauge is only sent while access rules are expired and once more when none are left, so servers
+			// which keep the last value of gauges do not report expired access rules forever.
+			if expired > 0 || expiredReported {
+				s.append(conn, &buf, s.line(MetricExpiredRules, strconv.Itoa(expired), "g", nil))
+				expiredReported = expired > 0
+			}
+			if s.health != nil {
+				status := s.health()
+				s.append(conn, &buf, s.line(MetricReady, gauge(status.Ready), "g", nil))
+				s.append(conn, &buf, s.line(MetricDegraded, gauge(status.Degraded), "g", nil))
+			}
+			s.write(conn, &buf)
+		}
+		buf.Reset()
+	}
+}
+
+// gauge returns the value of a boolean gauge.
+func gauge(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
+
+// refreshRules updates the access rules tracked by the SLO tracker and returns the number of expired access rules.
+func (s *StatsD) refreshRules(ctx context.Context, slo *SLOTracker) int {
+	count, err := s.rules.Count(ctx)
+	if err != nil {
+		s.logger.WithError(err).Debug("Unable to count the access rules")
+		return 0
+	}
+
+	rules, err := s.rules.List(ctx, count, 0)
+	if err != nil {
+		s.logger.WithError(err).Debug("Unable to list the access rules")
+		return 0
+	}
+
+	slo.SetRules(rules)
+	return len(rule.ExpiredRules(rules, time.Now()))
+}
+
+// append adds the line to the buffer and sends the buffer first if the line does not fit into the packet.
+func (s *StatsD) append(conn net.Conn, buf *bytes.Buffer, line string) {
+	if buf.Len() > 0 && buf.Len()+1+len(line) > maxPacketSize {
+		s.write(conn, buf)
+	}
+	if buf.Len() > 0 {
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(line)
+}
+
+func (s *StatsD) write(conn net.Conn, buf *bytes.Buffer) {
+	if buf.Len() == 0 {
+		return
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		s.logger.WithError(errors.WithStack(err)).Debug("Unable to send metrics to the StatsD server")
+	}
+	buf.Reset()
+}
+
+// Lines returns the StatsD lines of the event.
+func (s *StatsD) Lines(e events.Event) []string {
+	switch {
+	case e.Decision != nil:
+		d := e.Decision
+		tags := [][2]string{
+			{"interface", d.Interface},
+			{"method", d.Method},
+			{"rule_id", d.RuleID},
+			{"decision", d.Outcome()},
+			{"status_code", strconv.Itoa(d.StatusCode)},
+			{"owner", d.RuleOwner},
+		}
+		tags = append(tags, s.ruleLabelTags(d)...)
+		lines := []string{
+			s.line(MetricRequests, "1", "c", tags),
+			s.line(MetricDecisionLatency, strconv.FormatFloat(d.LatencyMS, 'f', -1, 64), "ms", tags),
+		}
+		if d.UpstreamError != "" {
+			lines = append(lines, s.line(MetricUpstreamErrors, "1", "c", [][2]string{
+				{"interface", d.Interface},
+				{"rule_id", d.RuleID},
+				{"reason", d.UpstreamError},
+				{"owner", d.RuleOwner},
+			}))
+		}
+		return lines
+	case e.RuleReload != nil:
+		result := "success"
+		if e.RuleReload.Error != "" {
+			result = "failure"
+		}
+		return []string{s.line(MetricRuleReloads, "1", "c", [][2]string{
+			{"repository", e.RuleReload.Repository},
+			{"result", result},
+		})}
+	}
+	return nil
+}
+
+// ruleLabelTags returns the tags of the configured labels of the matching access rule. Only configured labels are
+// added to keep the number of time series bounded.
+func (s *StatsD) ruleLabelTags(d *events.Decision) [][2]string {
+	var tags [][2]string
+	for _, key := range s.c.StatsDRuleLabels() {
+		if value, ok := d.RuleLabels[key]; ok {
+			tags = append(tags, [2]string{key, value})
+		}
+	}
+	return tags
+}
+
+// SLOLines returns the StatsD gauges of the SLO reports. The remaining error budget is only reported for the longest
+// window.
+func (s *StatsD) SLOLines(reports []SLOReport, windows []time.Duration) []string {
+	var lines []string
+	for _, r := range reports {
+		tags := [][2]string{
+			{"rule_id", r.RuleID},
+			{"objective", r.Objective},
+			{"window", formatWindow(r.Window)},
+		}
+		lines = append(lines,
+			s.line(MetricSLI, strconv.FormatFloat(r.SLI, 'f', -1, 64), "g", tags),
+			s.line(MetricBurnRate, strconv.FormatFloat(r.BurnRate, 'f', -1, 64), "g", tags),
+		)
+		if len(windows) > 0 && r.Window == windows[len(windows)-1] {
+			lines = append(lines, s.line(MetricErrorBudgetRemaining, strconv.FormatFloat(r.ErrorBudgetRemaining(), 'f', -1, 64), "g", tags))
+		}
+	}
+	return lines
+}
+
+func (s *StatsD) line(name, value, typ string, tags [][2]string) string {
+	name = s.c.StatsDPrefix() + name
+
+	for _, tag := range s.c.StatsDTags() {
+		if kv := strings.SplitN(tag, ":", 2); len(kv) == 2 {
+			tags = append(tags, [2]string{kv[0], kv[1]})
+		}
+	}
+
+	var b strings.Builder
+	switch s.c.StatsDTagFormat() {
+	case TagFormatNone:
+		b.WriteString(name + ":" + value + "|" + typ)
+	case TagFormatInfluxDB:
+		b.WriteString(name)
+		for _, tag := range tags {
+			if tag[1] != "" {
+				b.WriteString("," + sanitize(tag[0]) + "=" + sanitize(tag[1]))
+			}
+		}
+		b.WriteString(":" + value + "|" + typ)
+	default:
+		b.WriteString(name + ":" + value + "|" + typ)
+		sep := "|#"
+		for _, tag := range tags {
+			if tag[1] != "" {
+				b.WriteString(sep + sanitize(tag[0]) + ":" + sanitize(tag[1]))
+				sep = ","
+			}
+		}
+	}
+	return b.String()
+}
+
+var replacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "=", "_", ":", "_", " ", "_", "\n", "_")
+
+// sanitize replaces the characters which separate the parts of a StatsD line.
+func sanitize(value string) string {
+	return replacer.Replace(value)
+}
@@ -0,0 +1,137 @@
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/metrics"
+	"github.com/ory/oathkeeper/redaction"
+)
+
+func TestStatsDLines(t *testing.T) {
+	decision := events.Event{Type: events.TypeDecision, Decision: &events.Decision{
+		Interface: "proxy", Method: "GET", RuleID: "users", Granted: true, StatusCode: 200, LatencyMS: 2.5,
+	}}
+	reload := events.Event{Type: events.TypeRuleReload, RuleReload: &events.RuleReload{
+		Repository: "file:///etc/rules.json", Error: "unable to read",
+	}}
+
+	for k, tc := range []struct {
+		format     string
+		tags       []string
+		ruleLabels []string
+		e          events.Event
+		expect     []string
+	}{
+		{
+			format: metrics.TagFormatDatadog,
+			e:      decision,
+			expect: []string{
+				"oathkeeper.requests:1|c|#interface:proxy,method:GET,rule_id:users,decision:allowed,status_code:200",
+				"oathkeeper.decision_latency:2.5|ms|#interface:proxy,method:GET,rule_id:users,decision:allowed,status_code:200",
+			},
+		},
+		{
+			format: metrics.TagFormatInfluxDB,
+			tags:   []string{"env:production"},
+			e:      decision,
+			expect: []string{
+				"oathkeeper.requests,interface=proxy,method=GET,rule_id=users,decision=allowed,status_code=200,env=production:1|c",
+				"oathkeeper.decision_latency,interface=proxy,method=GET,rule_id=users,decision=allowed,status_code=200,env=production:2.5|ms",
+			},
+		},
+		{
+			format: metrics.TagFormatNone,
+			e:      decision,
+			expect: []string{"oathkeeper.requests:1|c", "oathkeeper.decision_latency:2.5|ms"},
+		},
+		{
+			format: metrics.TagFormatDatadog,
+			e: events.Event{Type: events.TypeDecision, Decision: &events.Decision{
+				Interface: "proxy", Method: "GET", RuleID: "users", Granted: true, StatusCode: 504, UpstreamError: "timeout",
+			}},
+			expect: []string{
+				"oathkeeper.requests:1|c|#interface:proxy,method:GET,rule_id:users,decision:allowed,status_code:504",
+				"oathkeeper.decision_latency:0|ms|#interface:proxy,method:GET,rule_id:users,decision:allowed,status_code:504",
+				"oathkeeper.upstream_errors:1|c|#interface:proxy,rule_id:users,reason:timeout",
+			},
+		},
+		{
+			format:     metrics.TagFormatDatadog,
+			ruleLabels: []string{"tier", "region"},
+			e: events.Event{Type: events.TypeDecision, Decision: &events.Decision{
+				Interface: "proxy", Method: "GET", RuleID: "users", RuleOwner: "identity", Granted: false, StatusCode: 403,
+				RuleLabels: map[string]string{"tier": "critical", "cost-center": "4711"},
+			}},
+			expect: []string{
+				"oathkeeper.requests:1|c|#interface:proxy,method:GET,rule_id:users,decision:denied,status_code:403,owner:identity,tier:critical",
+				"oathkeeper.decision_latency:0|ms|#interface:proxy,method:GET,rule_id:users,decision:denied,status_code:403,owner:identity,tier:critical",
+			},
+		},
+		{
+			format: metrics.TagFormatDatadog,
+			e:      reload,
+			expect: []string{"oathkeeper.rule_reloads:1|c|#repository:file_///etc/rules.json,result:failure"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			conf := internal.NewConfigurationWithDefaults()
+			viper.Set(configuration.ViperKeyStatsDTagFormat, tc.format)
+			viper.Set(configuration.ViperKeyStatsDTags, tc.tags)
+			viper.Set(configuration.ViperKeyStatsDRuleLabels, tc.ruleLabels)
+
+			s := metrics.NewStatsD(conf, nil, nil, logrus.New())
+			assert.Equal(t, tc.expect, s.Lines(tc.e))
+		})
+	}
+}
+
+func TestStatsDRun(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyStatsDAddress, server.LocalAddr().String())
+	viper.Set(configuration.ViperKeyStatsDFlushInterval, "50ms")
+
+	redactor, err := redaction.NewRedactor(nil, nil)
+	require.NoError(t, err)
+	bus := events.NewBus(redactor)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go metrics.NewStatsD(conf, bus, internal.NewRegistry(conf).RuleRepository(), logrus.New()).Run(ctx)
+
+	for !bus.HasSubscribers() {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	bus.Publish(events.Event{Type: events.TypeDecision, Decision: &events.Decision{
+		Interface: "decisions", Method: "POST", StatusCode: 403,
+	}})
+
+	require.NoError(t, server.SetReadDeadline(time.Now().Add(5*time.Second)))
+	packet := make([]byte, 1500)
+	n, _, err := server.ReadFrom(packet)
+	require.NoError(t, err)
+
+	lines := strings.Split(string(packet[:n]), "\n")
+	assert.Equal(t, []string{
+		"oathkeeper.requests:1|c|#interface:decisions,method:POST,decision:denied,status_code:403",
+		"oathkeeper.decision_latency:0|ms|#interface:decisions,method:POST,decision:denied,status_code:403",
+	}, lines)
+}
@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/events"
+)
+
+// reconnectDelay is the delay before reconnecting to the event stream.
+const reconnectDelay = time.Second
+
+// Stream connects to the event stream of the admin API at endpoint and calls fn for every event matching the filter.
+// The connection is reestablished until ctx is canceled. status is called with nil whenever the stream is connected
+// and with the error whenever the connection failed or was lost.
+func Stream(ctx context.Context, client *http.Client, endpoint string, f events.Filter, fn func(events.Event), status func(error)) {
+	for {
+		err := stream(ctx, client, endpoint, f, fn, status)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("the event stream was closed")
+		}
+		status(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func stream(ctx context.Context, client *http.Client, endpoint string, f events.Filter, fn func(events.Event), status func(error)) error {
+	req, err := http.NewRequest("GET", strings.TrimRight(endpoint, "/")+"/events?filter="+url.QueryEscape(f.String()), nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("expected status code %d but got %d", http.StatusOK, res.StatusCode)
+	}
+	status(nil)
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				var e events.Event
+				if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &e); err == nil {
+					fn(e)
+				}
+			}
+			data = data[:0]
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	return errors.WithStack(scanner.Err())
+}
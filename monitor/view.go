@@ -0,0 +1,311 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/oathkeeper/events"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorGreen = "\x1b[32m"
+	colorRed   = "\x1b[31m"
+	colorBold  = "\x1b[1m"
+
+	clearScreen = "\x1b[H\x1b[2J"
+)
+
+// View is the live terminal view of the monitor. It keeps the most recent decisions and renders those matching the
+// filter along with their statistics.
+type View struct {
+	sync.Mutex
+
+	// Endpoint is the URL of the admin API.
+	Endpoint string
+
+	// Filter selects the decisions shown.
+	Filter events.Filter
+
+	// Paused freezes the view, decisions received while paused are skipped.
+	Paused bool
+
+	// Editing is true while the filter expression is typed, Input is the expression typed so far.
+	Editing bool
+	Input   string
+
+	size      int
+	decisions []events.Event
+	received  int
+	dropped   uint64
+	reload    *events.Event
+	err       error
+	connected bool
+}
+
+// NewView returns a view keeping up to size decisions.
+func NewView(endpoint string, f events.Filter, size int) *View {
+	return &View{Endpoint: endpoint, Filter: f, size: size}
+}
+
+// Add adds the event to the view.
+func (v *View) Add(e events.Event) {
+	v.Lock()
+	defer v.Unlock()
+
+	switch {
+	case e.Type == events.TypeDropped:
+		v.dropped += e.Dropped
+		return
+	case e.RuleReload != nil:
+		v.reload = &e
+		return
+	case e.Decision == nil:
+		return
+	}
+
+	v.received++
+	if v.Paused {
+		return
+	}
+
+	v.decisions = append(v.decisions, e)
+	if len(v.decisions) > v.size {
+		v.decisions = v.decisions[len(v.decisions)-v.size:]
+	}
+}
+
+// SetStatus sets the status of the connection to the event stream, nil means connected.
+func (v *View) SetStatus(err error) {
+	v.Lock()
+	defer v.Unlock()
+	v.err, v.connected = err, err == nil
+}
+
+// Stats are the statistics of the shown decisions.
+type Stats struct {
+	Shown   int
+	Allowed int
+	Denied  int
+	P50     float64
+	P95     float64
+}
+
+func (v *View) shown() ([]events.Event, Stats) {
+	var shown []events.Event
+	var stats Stats
+	var latencies []float64
+	for _, e := range v.decisions {
+		if !v.Filter.Match(e) {
+			continue
+		}
+		shown = append(shown, e)
+		latencies = append(latencies, e.Decision.LatencyMS)
+		if e.Decision.Granted {
+			stats.Allowed++
+		} else {
+			stats.Denied++
+		}
+	}
+
+	stats.Shown = len(shown)
+	sort.Float64s(latencies)
+	stats.P50, stats.P95 = percentile(latencies, 0.5), percentile(latencies, 0.95)
+	return shown, stats
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+}
+
+// Stats returns the statistics of the decisions matching the filter.
+func (v *View) Stats() Stats {
+	v.Lock()
+	defer v.Unlock()
+	_, stats := v.shown()
+	return stats
+}
+
+// Render clears the terminal and draws the view for a terminal of the given size. Lines end with "\r\n" so the view
+// renders correctly in raw mode.
+func (v *View) Render(w io.Writer, width, height int) error {
+	v.Lock()
+	defer v.Unlock()
+
+	shown, stats := v.shown()
+
+	state := "connecting"
+	if v.connected {
+		state = "connected"
+	} else if v.err != nil {
+		state = "reconnecting: " + v.err.Error()
+	}
+	if v.dropped > 0 {
+		state += fmt.Sprintf(" - %d dropped", v.dropped)
+	}
+	if v.Paused {
+		state += " [paused]"
+	}
+
+	reload := ""
+	if r := v.reload; r != nil {
+		reload = fmt.Sprintf("Last rule reload at %s: %s loaded %d access rules",
+			r.Time.Local().Format("15:04:05"), r.RuleReload.Repository, r.RuleReload.Rules)
+		if r.RuleReload.Error != "" {
+			reload = fmt.Sprintf("Last rule reload at %s: %s failed: %s",
+				r.Time.Local().Format("15:04:05"), r.RuleReload.Repository, r.RuleReload.Error)
+		}
+	}
+
+	filter := v.Filter.String()
+	if v.Editing {
+		filter = v.Input + "_"
+	} else if filter == "" {
+		filter = "none"
+	}
+
+	lines := []string{
+		colorBold + fit("ORY Oathkeeper monitor - "+v.Endpoint+" - "+state, width) + colorReset,
+		fit("Filter: "+filter+"   (/ filter, p pause, c clear, q quit)", width),
+		fit(fmt.Sprintf("Decisions: %d shown, %d received   allowed %d   denied %d   p50 %s   p95 %s",
+			stats.Shown, v.received, stats.Allowed, stats.Denied, formatLatency(stats.P50), formatLatency(stats.P95)), width),
+		fit(reload, width),
+		colorBold + fit(row("TIME", "OUTCOME", "STATUS", "LATENCY", "RULE", "SUBJECT", "REQUEST"), width) + colorReset,
+	}
+
+	for k := len(shown) - 1; k >= 0 && len(lines) < height; k-- {
+		lines = append(lines, colorize(fit(FormatRow(shown[k]), width), shown[k].Decision.Granted))
+	}
+
+	_, err := io.WriteString(w, clearScreen+strings.Join(lines, "\r\n"))
+	return err
+}
+
+func row(time, outcome, status, latency, rule, subject, request string) string {
+	return fmt.Sprintf("%-8s  %-7s  %-6s  %-8s  %-24s  %-20s  %s",
+		time, outcome, status, latency, truncate(rule, 24), truncate(subject, 20), request)
+}
+
+// FormatRow formats the decision event as a table row.
+func FormatRow(e events.Event) string {
+	d := e.Decision
+	return row(
+		e.Time.Local().Format("15:04:05"),
+		d.Outcome(),
+		fmt.Sprintf("%d", d.StatusCode),
+		formatLatency(d.LatencyMS),
+		orDash(d.RuleID),
+		orDash(d.Subject),
+		d.Method+" "+d.URL,
+	)
+}
+
+// FormatLine formats the decision event as a single line for plain output.
+func FormatLine(e events.Event) string {
+	d := e.Decision
+	line := fmt.Sprintf("%s %s %d %s rule=%s subject=%s %s %s",
+		e.Time.Local().Format(time.RFC3339), d.Outcome(), d.StatusCode, formatLatency(d.LatencyMS),
+		orDash(d.RuleID), orDash(d.Subject), d.Method, d.URL)
+	if d.Error != "" {
+		line += fmt.Sprintf(" error=%q", d.Error)
+	}
+	return line
+}
+
+func formatLatency(ms float64) string {
+	if ms < 1 {
+		return fmt.Sprintf("%.0fus", ms*1000)
+	}
+	return fmt.Sprintf("%.1fms", ms)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string(r[:n])
+	}
+	return string(r[:n-1]) + "~"
+}
+
+// fit truncates the line to the width of the terminal.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	return truncate(s, width)
+}
+
+// colorize colors the outcome column of a row.
+func colorize(line string, granted bool) string {
+	const start, end = 10, 17
+	r := []rune(line)
+	if len(r) < end {
+		return line
+	}
+
+	color := colorRed
+	if granted {
+		color = colorGreen
+	}
+	return string(r[:start]) + color + string(r[start:end]) + colorReset + string(r[end:])
+}
+
+// HandleKey handles a key pressed in the interactive view and returns true if the monitor should quit.
+func (v *View) HandleKey(key byte) bool {
+	v.Lock()
+	defer v.Unlock()
+
+	if v.Editing {
+		switch key {
+		case '\r', '\n':
+			f, err := events.ParseFilter(v.Input)
+			if err != nil {
+				return false
+			}
+			v.Filter, v.Editing = f, false
+		case 27: // escape
+			v.Editing = false
+		case 127, 8: // backspace
+			if r := []rune(v.Input); len(r) > 0 {
+				v.Input = string(r[:len(r)-1])
+			}
+		case 3: // ctrl+c
+			return true
+		default:
+			if key >= 32 && key < 127 {
+				v.Input += string(key)
+			}
+		}
+		return false
+	}
+
+	switch key {
+	case 'q', 3:
+		return true
+	case 'p':
+		v.Paused = !v.Paused
+	case 'c':
+		v.decisions = nil
+	case '/':
+		v.Editing, v.Input = true, v.Filter.String()
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/events"
+)
+
+func decision(ruleID string, granted bool, latency float64) events.Event {
+	return events.Event{Type: events.TypeDecision, Time: time.Now(), Decision: &events.Decision{
+		RuleID: ruleID, Method: "GET", URL: "https://api.example.com/" + ruleID, Granted: granted, LatencyMS: latency,
+	}}
+}
+
+func TestView(t *testing.T) {
+	v := NewView("http://localhost:4456", events.Filter{}, 20)
+	for k := 1; k <= 25; k++ {
+		v.Add(decision("users", k%5 != 0, float64(k)))
+	}
+	v.Add(events.Event{Type: "other"})
+
+	stats := v.Stats()
+	assert.Equal(t, Stats{Shown: 20, Allowed: 16, Denied: 4, P50: 15, P95: 24}, stats)
+
+	t.Run("case=renders newest decisions first", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, v.Render(&out, 200, 8))
+		lines := strings.Split(out.String(), "\r\n")
+		require.Len(t, lines, 8)
+		assert.Contains(t, lines[0], "http://localhost:4456 - connecting")
+		assert.Contains(t, lines[2], "20 shown, 25 received")
+		assert.Contains(t, lines[5], "denied")
+		assert.Contains(t, lines[5], "25.0ms")
+		assert.Contains(t, lines[6], "allowed")
+		assert.Contains(t, lines[6], "24.0ms")
+	})
+
+	t.Run("case=shows rule reloads and dropped events", func(t *testing.T) {
+		v.Add(events.Event{Type: events.TypeDropped, Dropped: 3})
+		v.Add(events.Event{Type: events.TypeDropped, Dropped: 2})
+		v.Add(events.Event{Type: events.TypeRuleReload, Time: time.Now(), RuleReload: &events.RuleReload{
+			Repository: "file:///etc/rules.json", Error: "unexpected end of JSON input",
+		}})
+
+		var out bytes.Buffer
+		require.NoError(t, v.Render(&out, 200, 5))
+		lines := strings.Split(out.String(), "\r\n")
+		assert.Contains(t, lines[0], "5 dropped")
+		assert.Contains(t, lines[3], "file:///etc/rules.json failed: unexpected end of JSON input")
+		assert.Equal(t, 20, v.Stats().Shown)
+	})
+
+	t.Run("case=edits the filter", func(t *testing.T) {
+		for _, key := range []byte("/outcome:deniex") {
+			assert.False(t, v.HandleKey(key))
+		}
+		v.HandleKey(127)
+		v.HandleKey('d')
+		assert.True(t, v.Editing)
+		v.HandleKey('\r')
+		assert.False(t, v.Editing)
+		assert.Equal(t, events.Filter{Outcome: events.OutcomeDenied}, v.Filter)
+		assert.Equal(t, 4, v.Stats().Shown)
+
+		for _, key := range []byte("/x\x1b") {
+			v.HandleKey(key)
+		}
+		assert.Equal(t, events.Filter{Outcome: events.OutcomeDenied}, v.Filter)
+	})
+
+	t.Run("case=pauses and clears", func(t *testing.T) {
+		v.HandleKey('p')
+		v.Add(decision("users", false, 1))
+		assert.Equal(t, 4, v.Stats().Shown)
+
+		v.HandleKey('p')
+		v.HandleKey('c')
+		assert.Equal(t, 0, v.Stats().Shown)
+		assert.True(t, v.HandleKey('q'))
+	})
+}
+
+func TestStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/events", r.URL.Path)
+		assert.Equal(t, "type:decision", r.URL.Query().Get("filter"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "retry: 1000\n\n: keep-alive\n\n")
+		fmt.Fprint(w, `event: decision`+"\n"+`data: {"type":"decision","decision":{"rule_id":"users","granted":true}}`+"\n\n")
+		fmt.Fprint(w, "data: not json\n\n")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var received []events.Event
+	var statuses []error
+	Stream(ctx, ts.Client(), ts.URL+"/", events.Filter{Type: events.TypeDecision}, func(e events.Event) {
+		received = append(received, e)
+	}, func(err error) {
+		statuses = append(statuses, err)
+		if err != nil {
+			cancel()
+		}
+	})
+
+	require.Len(t, received, 1)
+	assert.Equal(t, "users", received[0].Decision.RuleID)
+	require.Len(t, statuses, 2)
+	assert.NoError(t, statuses[0])
+	assert.Error(t, statuses[1])
+}
@@ -0,0 +1,277 @@
+// Package notification POSTs notifications to webhooks when access rule repositories are reloaded, when the
+// readiness of ORY Oathkeeper changes, and when the deny or error rate of an access rule crosses a threshold.
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+)
+
+const (
+	// TypeRuleReload is sent whenever an access rule repository was reloaded or failed to reload.
+	TypeRuleReload = "rule_reload"
+
+	// TypeReadiness is sent whenever ORY Oathkeeper becomes ready or stops being ready.
+	TypeReadiness = "readiness"
+
+	// TypeRuleThreshold is sent whenever the deny or error rate of an access rule crosses its threshold.
+	TypeRuleThreshold = "rule_threshold"
+
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+
+	RateDeny  = "deny"
+	RateError = "error"
+)
+
+// Notification is POSTed to the webhooks.
+type Notification struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	// RuleReload is set for notifications of type "rule_reload".
+	RuleReload *events.RuleReload `json:"rule_reload,omitempty"`
+
+	// Readiness is set for notifications of type "readiness".
+	Readiness *Readiness `json:"readiness,omitempty"`
+
+	// Threshold is set for notifications of type "rule_threshold".
+	Threshold *Threshold `json:"threshold,omitempty"`
+}
+
+// Readiness is the readiness of ORY Oathkeeper.
+type Readiness struct {
+	Ready bool `json:"ready"`
+
+	// Error is the reason ORY Oathkeeper is not ready.
+	Error string `json:"error,omitempty"`
+}
+
+// Threshold describes the deny or error rate of an access rule crossing its threshold.
+type Threshold struct {
+	RuleID string `json:"rule_id"`
+
+	// Rate is either "deny" or "error".
+	Rate string `json:"rate"`
+
+	// Exceeded is true if the rate rose above the threshold and false if it fell below it again.
+	Exceeded bool `json:"exceeded"`
+
+	// Value is the rate within the window.
+	Value float64 `json:"value"`
+
+	// Threshold is the configured threshold.
+	Threshold float64 `json:"threshold"`
+
+	// Requests is the number of requests within the window.
+	Requests int `json:"requests"`
+
+	// Window is the window the rate is computed over, for example "5m0s".
+	Window string `json:"window"`
+}
+
+type Registry interface {
+	NotificationDispatcher() *Dispatcher
+}
+
+// Dispatcher watches the event bus and the readiness of ORY Oathkeeper and sends notifications to the configured
+// webhooks. Notifications are queued and dropped if the webhooks can not keep up.
+type Dispatcher struct {
+	sync.Mutex
+
+	c      configuration.Provider
+	bus    *events.Bus
+	ready  func() error
+	logger logrus.FieldLogger
+	client *http.Client
+	queue  chan Notification
+
+	rules      map[string]*ruleRates
+	readyKnown bool
+	wasReady   bool
+}
+
+// NewDispatcher creates a new Dispatcher. The ready function returns an error if ORY Oathkeeper is not ready.
+func NewDispatcher(c configuration.Provider, bus *events.Bus, ready func() error, logger logrus.FieldLogger) *Dispatcher {
+	return &Dispatcher{
+		c:      c,
+		bus:    bus,
+		ready:  ready,
+		logger: logger,
+		client: httpx.NewResilientClientLatencyToleranceSmall(nil),
+		queue:  make(chan Notification, 100),
+		rules:  map[string]*ruleRates{},
+	}
+}
+
+// Run dispatches notifications until the context is canceled. Events are only consumed while webhooks are
+// configured.
+func (d *Dispatcher) Run(ctx context.Context) {
+	go d.send(ctx)
+
+	var s *events.Subscription
+	defer func() {
+		if s != nil {
+			s.Close()
+		}
+	}()
+
+	for {
+		window := d.c.WebhookThresholdWindow()
+		if window <= 0 {
+			window = time.Minute * 5
+		}
+		interval := window / buckets
+
+		if len(d.c.Webhooks()) == 0 {
+			if s != nil {
+				s.Close()
+				s = nil
+			}
+		} else {
+			if s == nil {
+				s = d.bus.Subscribe(1000, events.Filter{})
+			}
+
+			d.checkReadiness()
+			for _, t := range d.evaluate(time.Now(), window) {
+				d.Notify(Notification{Type: TypeRuleThreshold, Time: time.Now().UTC(), Threshold: t})
+			}
+		}
+
+		var received <-chan events.Event
+		if s != nil {
+			received = s.Events()
+		}
+
+		timeout := time.After(interval)
+	consume:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timeout:
+				break consume
+			case e := <-received:
+				switch {
+				case e.RuleReload != nil:
+					d.Notify(Notification{Type: TypeRuleReload, Time: e.Time, RuleReload: e.RuleReload})
+				case e.Decision != nil:
+					d.record(time.Now(), window, e.Decision)
+				}
+			}
+		}
+	}
+}
+
+// checkReadiness sends a notification if the readiness changed since it was checked last.
+func (d *Dispatcher) checkReadiness() {
+	err := d.ready()
+	ready := err == nil
+	if d.readyKnown && d.wasReady != ready {
+		readiness := &Readiness{Ready: ready}
+		if err != nil {
+			readiness.Error = err.Error()
+		}
+		d.Notify(Notification{Type: TypeReadiness, Time: time.Now().UTC(), Readiness: readiness})
+	}
+	d.readyKnown, d.wasReady = true, ready
+}
+
+// Notify queues the notification for the webhooks.
+func (d *Dispatcher) Notify(n Notification) {
+	d.logger.WithField("notification", n.Type).Debug("Queueing webhook notification")
+
+	select {
+	case d.queue <- n:
+	default:
+		d.logger.WithField("notification", n.Type).Warn("Dropped webhook notification because too many notifications are queued")
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-d.queue:
+			for _, w := range d.c.Webhooks() {
+				if !w.WantsEvent(n.Type) {
+					continue
+				}
+				if err := d.Send(ctx, w, n); err != nil {
+					d.logger.WithError(err).WithField("notification", n.Type).Warn("Unable to send webhook notification")
+				}
+			}
+		}
+	}
+}
+
+// Send POSTs the notification to the webhook.
+func (d *Dispatcher) Send(ctx context.Context, w configuration.Webhook, n Notification) error {
+	var payload interface{} = n
+	if w.Format == FormatSlack {
+		details, err := json.MarshalIndent(n, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		payload = map[string]string{"text": fmt.Sprintf("%s\n```%s```", n.Summary(), details)}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf("expected a 2xx status code but got %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Summary returns a human readable summary of the notification.
+func (n Notification) Summary() string {
+	switch {
+	case n.RuleReload != nil && n.RuleReload.Error != "":
+		return fmt.Sprintf("Unable to reload access rules from `%s`: %s", n.RuleReload.Repository, n.RuleReload.Error)
+	case n.RuleReload != nil:
+		return fmt.Sprintf("Loaded %d access rules from `%s`", n.RuleReload.Rules, n.RuleReload.Repository)
+	case n.Readiness != nil && n.Readiness.Ready:
+		return "ORY Oathkeeper is ready"
+	case n.Readiness != nil:
+		return fmt.Sprintf("ORY Oathkeeper is not ready: %s", n.Readiness.Error)
+	case n.Threshold != nil && n.Threshold.Exceeded:
+		return fmt.Sprintf("The %s rate of access rule `%s` is %.1f%%, above the threshold of %.1f%%",
+			n.Threshold.Rate, n.Threshold.RuleID, n.Threshold.Value*100, n.Threshold.Threshold*100)
+	case n.Threshold != nil:
+		return fmt.Sprintf("The %s rate of access rule `%s` is %.1f%%, back below the threshold of %.1f%%",
+			n.Threshold.Rate, n.Threshold.RuleID, n.Threshold.Value*100, n.Threshold.Threshold*100)
+	}
+	return n.Type
+}
@@ -0,0 +1,143 @@
+package notification_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/notification"
+	"github.com/ory/oathkeeper/redaction"
+)
+
+func TestDispatcher(t *testing.T) {
+	received := make(chan notification.Notification, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notification.Notification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		received <- n
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyWebhooks, []interface{}{map[string]interface{}{"url": server.URL}})
+	viper.Set(configuration.ViperKeyWebhookThresholdWindow, "1s")
+	viper.Set(configuration.ViperKeyWebhookThresholdMinRequests, 5)
+	viper.Set(configuration.ViperKeyWebhookThresholdDenyRate, 0.5)
+
+	redactor, err := redaction.NewRedactor(nil, nil)
+	require.NoError(t, err)
+	bus := events.NewBus(redactor)
+
+	var lock sync.Mutex
+	var notReady error
+	d := notification.NewDispatcher(conf, bus, func() error {
+		lock.Lock()
+		defer lock.Unlock()
+		return notReady
+	}, logrus.New())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	for !bus.HasSubscribers() {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	next := func(t *testing.T, typ string) notification.Notification {
+		select {
+		case n := <-received:
+			require.Equal(t, typ, n.Type)
+			return n
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s notification was received", typ)
+		}
+		return notification.Notification{}
+	}
+
+	t.Run("case=rule reload", func(t *testing.T) {
+		bus.Publish(events.Event{Type: events.TypeRuleReload, RuleReload: &events.RuleReload{Repository: "file:///etc/rules.json", Rules: 3}})
+		n := next(t, notification.TypeRuleReload)
+		assert.Equal(t, "file:///etc/rules.json", n.RuleReload.Repository)
+		assert.Equal(t, 3, n.RuleReload.Rules)
+	})
+
+	t.Run("case=readiness", func(t *testing.T) {
+		lock.Lock()
+		notReady = errors.New("no access rules have been activated yet")
+		lock.Unlock()
+
+		n := next(t, notification.TypeReadiness)
+		assert.False(t, n.Readiness.Ready)
+		assert.Equal(t, "no access rules have been activated yet", n.Readiness.Error)
+
+		lock.Lock()
+		notReady = nil
+		lock.Unlock()
+
+		n = next(t, notification.TypeReadiness)
+		assert.True(t, n.Readiness.Ready)
+	})
+
+	t.Run("case=deny rate", func(t *testing.T) {
+		for k := 0; k < 10; k++ {
+			bus.Publish(events.Event{Type: events.TypeDecision, Decision: &events.Decision{
+				RuleID: "users", Granted: k < 4, StatusCode: 403,
+			}})
+		}
+
+		n := next(t, notification.TypeRuleThreshold)
+		assert.Equal(t, "users", n.Threshold.RuleID)
+		assert.Equal(t, notification.RateDeny, n.Threshold.Rate)
+		assert.True(t, n.Threshold.Exceeded)
+		assert.True(t, n.Threshold.Value >= 0.5, "%f", n.Threshold.Value)
+		assert.True(t, n.Threshold.Requests >= 5, "%d", n.Threshold.Requests)
+
+		// Without further requests, the rate falls below the threshold once the window passed.
+		n = next(t, notification.TypeRuleThreshold)
+		assert.Equal(t, "users", n.Threshold.RuleID)
+		assert.False(t, n.Threshold.Exceeded)
+	})
+}
+
+func TestDispatcherSend(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	redactor, err := redaction.NewRedactor(nil, nil)
+	require.NoError(t, err)
+	d := notification.NewDispatcher(internal.NewConfigurationWithDefaults(), events.NewBus(redactor), func() error { return nil }, logrus.New())
+
+	n := notification.Notification{Type: notification.TypeRuleThreshold, Threshold: &notification.Threshold{
+		RuleID: "users", Rate: notification.RateError, Exceeded: true, Value: 0.25, Threshold: 0.1, Requests: 40, Window: "5m0s",
+	}}
+	require.NoError(t, d.Send(context.Background(), configuration.Webhook{URL: server.URL, Format: notification.FormatSlack}, n))
+	assert.Contains(t, (<-received)["text"], "The error rate of access rule `users` is 25.0%, above the threshold of 10.0%")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	require.Error(t, d.Send(context.Background(), configuration.Webhook{URL: failing.URL}, n))
+}
@@ -0,0 +1,135 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/ory/oathkeeper/events"
+)
+
+// buckets is the number of buckets the window is divided into.
+const buckets = 10
+
+type bucket struct {
+	index   int64
+	total   int
+	denied  int
+	errored int
+}
+
+// ruleRates counts the decisions of an access rule within the window and remembers which thresholds are exceeded.
+type ruleRates struct {
+	buckets [buckets]bucket
+
+	denyExceeded  bool
+	errorExceeded bool
+}
+
+func bucketIndex(now time.Time, window time.Duration) int64 {
+	size := int64(window / buckets)
+	if size <= 0 {
+		size = 1
+	}
+	return now.UnixNano() / size
+}
+
+func (r *ruleRates) add(now time.Time, window time.Duration, d *events.Decision) {
+	index := bucketIndex(now, window)
+	b := &r.buckets[index%buckets]
+	if b.index != index {
+		*b = bucket{index: index}
+	}
+
+	b.total++
+	switch {
+	case d.StatusCode >= 500:
+		b.errored++
+	case !d.Granted:
+		b.denied++
+	}
+}
+
+func (r *ruleRates) sum(now time.Time, window time.Duration) (total, denied, errored int) {
+	index := bucketIndex(now, window)
+	for _, b := range r.buckets {
+		if b.index > index-buckets && b.index <= index {
+			total += b.total
+			denied += b.denied
+			errored += b.errored
+		}
+	}
+	return total, denied, errored
+}
+
+// record counts the decision if a threshold is configured.
+func (d *Dispatcher) record(now time.Time, window time.Duration, decision *events.Decision) {
+	if decision.RuleID == "" || (d.c.WebhookThresholdDenyRate() <= 0 && d.c.WebhookThresholdErrorRate() <= 0) {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	r, ok := d.rules[decision.RuleID]
+	if !ok {
+		r = new(ruleRates)
+		d.rules[decision.RuleID] = r
+	}
+	r.add(now, window, decision)
+}
+
+// evaluate returns the thresholds crossed since the last evaluation. Requests failing with a 5xx status code count
+// as errors, other requests which were not granted count as denied.
+func (d *Dispatcher) evaluate(now time.Time, window time.Duration) []*Threshold {
+	denyRate, errorRate, minRequests := d.c.WebhookThresholdDenyRate(), d.c.WebhookThresholdErrorRate(), d.c.WebhookThresholdMinRequests()
+
+	d.Lock()
+	defer d.Unlock()
+
+	var crossed []*Threshold
+	for id, r := range d.rules {
+		total, denied, errored := r.sum(now, window)
+		if total == 0 && !r.denyExceeded && !r.errorExceeded {
+			delete(d.rules, id)
+			continue
+		}
+
+		for _, c := range []struct {
+			rate      string
+			count     int
+			threshold float64
+			exceeded  *bool
+		}{
+			{rate: RateDeny, count: denied, threshold: denyRate, exceeded: &r.denyExceeded},
+			{rate: RateError, count: errored, threshold: errorRate, exceeded: &r.errorExceeded},
+		} {
+			var value float64
+			if total > 0 {
+				value = float64(c.count) / float64(total)
+			}
+
+			// With too few requests in the window, the state stays as it is.
+			exceeded := *c.exceeded
+			switch {
+			case c.threshold <= 0 || total == 0:
+				exceeded = false
+			case total >= minRequests:
+				exceeded = value >= c.threshold
+			}
+
+			if exceeded != *c.exceeded {
+				*c.exceeded = exceeded
+				crossed = append(crossed, &Threshold{
+					RuleID:    id,
+					Rate:      c.rate,
+					Exceeded:  exceeded,
+					Value:     value,
+					Threshold: c.threshold,
+					Requests:  total,
+					Window:    window.String(),
+				})
+			}
+		}
+	}
+
+	return crossed
+}
@@ -0,0 +1,214 @@
+// Package overload protects ORY Oathkeeper and the services behind it from overload. While too many requests are in
+// flight or the 99th percentile of their latency is too high, a percentage of the requests of access rules with a
+// lower priority is rejected so that requests of critical access rules keep being served. Additionally, the number of
+// requests in flight can be limited per priority so that no priority starves the others.
+package overload
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+)
+
+const (
+	PriorityLow      = "low"
+	PriorityNormal   = "normal"
+	PriorityCritical = "critical"
+)
+
+// Priorities are the priorities of access rules, from lowest to highest.
+var Priorities = []string{PriorityLow, PriorityNormal, PriorityCritical}
+
+// ErrOverloaded is returned for requests which are shed.
+var ErrOverloaded = &herodot.DefaultError{
+	ErrorField:  "The service is overloaded, try again later",
+	CodeField:   http.StatusServiceUnavailable,
+	StatusField: http.StatusText(http.StatusServiceUnavailable),
+}
+
+// evaluationInterval is how often the latency percentile is computed.
+const evaluationInterval = time.Second
+
+// maxSamples caps the number of latencies kept to compute the percentile.
+const maxSamples = 1024
+
+// Policy defines when the service is overloaded and which requests are shed.
+type Policy struct {
+	// MaxInFlight is the number of requests in flight above which the service is overloaded. 0 disables the check.
+	MaxInFlight int
+
+	// MaxLatency is the 99th percentile of the latency above which the service is overloaded. 0 disables the check.
+	MaxLatency time.Duration
+
+	// LatencyWindow is how long latencies are taken into account.
+	LatencyWindow time.Duration
+
+	// ShedPercentage is the percentage of requests shed while the service is overloaded, by priority. Requests of
+	// critical access rules are never shed.
+	ShedPercentage map[string]int
+
+	// MaxInFlightPerPriority is the number of requests in flight above which requests are rejected, by priority,
+	// regardless of whether the service is overloaded. 0 disables the limit.
+	MaxInFlightPerPriority map[string]int
+}
+
+// Stats describes the current load and counts what the shedder did since the process started.
+type Stats struct {
+	// InFlight is the number of requests in flight.
+	InFlight int64 `json:"in_flight"`
+
+	// InFlightPerPriority is the number of requests in flight by priority.
+	InFlightPerPriority map[string]int64 `json:"in_flight_per_priority"`
+
+	// LatencyP99 is the 99th percentile of the latency of recent requests.
+	LatencyP99 time.Duration `json:"latency_p99"`
+
+	// Shed is the number of requests which were shed.
+	Shed uint64 `json:"shed"`
+}
+
+type sample struct {
+	at      time.Time
+	latency time.Duration
+}
+
+// Shedder admits or sheds requests according to the policy.
+type Shedder struct {
+	// The counters are accessed atomically and must stay 64-bit aligned.
+	inFlight int64
+	shed     uint64
+
+	// inFlightPerPriority holds a counter per priority, the map itself is never modified.
+	inFlightPerPriority map[string]*int64
+
+	policy func() Policy
+
+	sync.Mutex
+	samples     []sample
+	next        int
+	p99         time.Duration
+	evaluatedAt time.Time
+}
+
+type Registry interface {
+	OverloadShedder() *Shedder
+	UpstreamLimiter() *UpstreamLimiter
+}
+
+// NewShedder creates a new Shedder. The policy function returns the current policy.
+func NewShedder(policy func() Policy) *Shedder {
+	s := &Shedder{policy: policy, inFlightPerPriority: map[string]*int64{}}
+	for _, priority := range Priorities {
+		s.inFlightPerPriority[priority] = new(int64)
+	}
+	return s
+}
+
+// Admit returns ErrOverloaded if too many requests of access rules with the given priority are in flight, or if the
+// service is overloaded and the request is shed. Otherwise the request is admitted and done must be called once it
+// was handled. Unknown priorities are treated as "normal".
+func (s *Shedder) Admit(priority string) (done func(), err error) {
+	counter, ok := s.inFlightPerPriority[priority]
+	if !ok {
+		priority = PriorityNormal
+		counter = s.inFlightPerPriority[priority]
+	}
+
+	p := s.policy()
+	inFlight := atomic.AddInt64(&s.inFlight, 1)
+	priorityInFlight := atomic.AddInt64(counter, 1)
+	release := func() {
+		atomic.AddInt64(&s.inFlight, -1)
+		atomic.AddInt64(counter, -1)
+	}
+
+	if limit := p.MaxInFlightPerPriority[priority]; limit > 0 && priorityInFlight > int64(limit) {
+		release()
+		atomic.AddUint64(&s.shed, 1)
+		return nil, errors.WithStack(ErrOverloaded.
+			WithReasonf("Too many requests of access rules with priority %s are in flight.", priority))
+	}
+
+	if priority != PriorityCritical && s.overloaded(p, inFlight) && rand.Intn(100) < p.ShedPercentage[priority] {
+		release()
+		atomic.AddUint64(&s.shed, 1)
+		return nil, errors.WithStack(ErrOverloaded.
+			WithReasonf("Requests of access rules with priority %s are shed because the service is overloaded.", priority))
+	}
+
+	started := time.Now()
+	return func() {
+		release()
+		s.record(started, time.Since(started))
+	}, nil
+}
+
+// Stats returns the current load and what the shedder did since the process started.
+func (s *Shedder) Stats() Stats {
+	perPriority := map[string]int64{}
+	for priority, counter := range s.inFlightPerPriority {
+		perPriority[priority] = atomic.LoadInt64(counter)
+	}
+
+	return Stats{
+		InFlight:            atomic.LoadInt64(&s.inFlight),
+		InFlightPerPriority: perPriority,
+		LatencyP99:          s.latencyP99(s.policy().LatencyWindow),
+		Shed:                atomic.LoadUint64(&s.shed),
+	}
+}
+
+func (s *Shedder) overloaded(p Policy, inFlight int64) bool {
+	if p.MaxInFlight > 0 && inFlight > int64(p.MaxInFlight) {
+		return true
+	}
+	return p.MaxLatency > 0 && s.latencyP99(p.LatencyWindow) > p.MaxLatency
+}
+
+func (s *Shedder) record(at time.Time, latency time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.samples) < maxSamples {
+		s.samples = append(s.samples, sample{at: at, latency: latency})
+		return
+	}
+	s.samples[s.next] = sample{at: at, latency: latency}
+	s.next = (s.next + 1) % maxSamples
+}
+
+// latencyP99 returns the 99th percentile of the latencies of the requests started within the window. It is computed
+// at most once per evaluationInterval.
+func (s *Shedder) latencyP99(window time.Duration) time.Duration {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.evaluatedAt) < evaluationInterval {
+		return s.p99
+	}
+	s.evaluatedAt = now
+
+	latencies := make([]time.Duration, 0, len(s.samples))
+	for _, sm := range s.samples {
+		if window <= 0 || now.Sub(sm.at) <= window {
+			latencies = append(latencies, sm.latency)
+		}
+	}
+
+	if len(latencies) == 0 {
+		s.p99 = 0
+		return 0
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	s.p99 = latencies[(len(latencies)*99-1)/100]
+	return s.p99
+}
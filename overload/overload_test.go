@@ -0,0 +1,103 @@
+package overload
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+)
+
+func TestShedderInFlight(t *testing.T) {
+	s := NewShedder(func() Policy {
+		return Policy{MaxInFlight: 1, ShedPercentage: map[string]int{PriorityLow: 100, PriorityNormal: 0}}
+	})
+
+	done, err := s.Admit(PriorityLow)
+	require.NoError(t, err, "the service is not overloaded yet")
+
+	_, err = s.Admit(PriorityLow)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, herodot.ToDefaultError(err, "").StatusCode())
+
+	for _, priority := range []string{PriorityNormal, "", PriorityCritical} {
+		other, err := s.Admit(priority)
+		require.NoError(t, err, priority)
+		other()
+	}
+
+	done()
+	done, err = s.Admit(PriorityLow)
+	require.NoError(t, err, "the service is no longer overloaded")
+	done()
+
+	stats := s.Stats()
+	assert.EqualValues(t, 0, stats.InFlight)
+	assert.EqualValues(t, 1, stats.Shed)
+}
+
+func TestShedderLatency(t *testing.T) {
+	s := NewShedder(func() Policy {
+		return Policy{MaxLatency: 10 * time.Millisecond, LatencyWindow: time.Minute, ShedPercentage: map[string]int{PriorityNormal: 100}}
+	})
+
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		s.record(now, time.Millisecond)
+	}
+	s.record(now, time.Second)
+	s.record(now, time.Second)
+
+	_, err := s.Admit(PriorityNormal)
+	require.Error(t, err, "more than one percent of the requests took longer than the maximum latency")
+	assert.Equal(t, time.Second, s.Stats().LatencyP99)
+
+	done, err := s.Admit(PriorityCritical)
+	require.NoError(t, err)
+	done()
+
+	_, err = s.Admit(PriorityLow)
+	require.NoError(t, err, "no percentage is configured for priority low")
+}
+
+func TestShedderLatencyWindow(t *testing.T) {
+	s := NewShedder(func() Policy {
+		return Policy{MaxLatency: 10 * time.Millisecond, LatencyWindow: time.Minute, ShedPercentage: map[string]int{PriorityNormal: 100}}
+	})
+
+	s.record(time.Now().Add(-time.Hour), time.Second)
+	done, err := s.Admit(PriorityNormal)
+	require.NoError(t, err, "latencies outside of the window are ignored")
+	done()
+}
+
+func TestShedderInFlightPerPriority(t *testing.T) {
+	s := NewShedder(func() Policy {
+		return Policy{MaxInFlightPerPriority: map[string]int{PriorityLow: 1}}
+	})
+
+	done, err := s.Admit(PriorityLow)
+	require.NoError(t, err)
+
+	_, err = s.Admit(PriorityLow)
+	require.Error(t, err, "the service is not overloaded but too many low priority requests are in flight")
+	assert.Equal(t, http.StatusServiceUnavailable, herodot.ToDefaultError(err, "").StatusCode())
+
+	for _, priority := range []string{PriorityNormal, PriorityCritical, "unknown"} {
+		other, err := s.Admit(priority)
+		require.NoError(t, err, priority)
+		defer other()
+	}
+
+	stats := s.Stats()
+	assert.EqualValues(t, 4, stats.InFlight)
+	assert.Equal(t, map[string]int64{PriorityLow: 1, PriorityNormal: 2, PriorityCritical: 1}, stats.InFlightPerPriority)
+
+	done()
+	done, err = s.Admit(PriorityLow)
+	require.NoError(t, err)
+	done()
+}
@@ -0,0 +1,168 @@
+package overload
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+)
+
+// ErrUpstreamOverloaded is returned for requests which exceed the concurrency limit of their upstream.
+var ErrUpstreamOverloaded = &herodot.DefaultError{
+	ErrorField:  "The upstream is overloaded, try again later",
+	CodeField:   http.StatusServiceUnavailable,
+	StatusField: http.StatusText(http.StatusServiceUnavailable),
+}
+
+// baselineWindow is how often the baseline latency of an upstream is renewed, so that it follows lasting changes of
+// the latency of the upstream.
+const baselineWindow = time.Minute
+
+// UpstreamPolicy defines how the concurrency limits of upstreams adapt.
+type UpstreamPolicy struct {
+	// Enabled en-/disables the concurrency limits.
+	Enabled bool
+
+	// InitialLimit is the limit of an upstream before any of its requests completed.
+	InitialLimit int
+
+	// MinLimit and MaxLimit bound the limit.
+	MinLimit int
+	MaxLimit int
+
+	// LatencyTolerance is the factor by which the latency of a request may exceed the baseline latency of the
+	// upstream before the limit is decreased.
+	LatencyTolerance float64
+
+	// Backoff is the factor the limit is multiplied with when it is decreased.
+	Backoff float64
+}
+
+// UpstreamStatus is the state of the concurrency limit of an upstream.
+type UpstreamStatus struct {
+	// Host is the host of the upstream.
+	Host string `json:"host"`
+
+	// Limit is the current number of requests which may be in flight.
+	Limit int `json:"limit"`
+
+	// InFlight is the number of requests in flight.
+	InFlight int `json:"in_flight"`
+
+	// Baseline is the lowest latency observed recently.
+	Baseline time.Duration `json:"baseline"`
+}
+
+type upstreamLimit struct {
+	limit    float64
+	inFlight int
+
+	// baseline is the lowest latency of the previous window, windowMin the lowest latency of the current one.
+	baseline      time.Duration
+	windowMin     time.Duration
+	windowStarted time.Time
+}
+
+// UpstreamLimiter limits the number of requests in flight per upstream. Like TCP congestion control, the limit of an
+// upstream grows additively while its requests complete quickly and shrinks multiplicatively (AIMD) once their latency
+// grows well beyond its baseline or they fail, protecting upstreams which slow down.
+type UpstreamLimiter struct {
+	policy func() UpstreamPolicy
+
+	sync.Mutex
+	limits map[string]*upstreamLimit
+}
+
+// NewUpstreamLimiter creates a new UpstreamLimiter. The policy function returns the current policy.
+func NewUpstreamLimiter(policy func() UpstreamPolicy) *UpstreamLimiter {
+	return &UpstreamLimiter{policy: policy, limits: map[string]*upstreamLimit{}}
+}
+
+// Acquire returns ErrUpstreamOverloaded if the concurrency limit of the upstream host is reached. Otherwise release
+// must be called once the request completed, with its latency and whether it failed because of the upstream. A latency
+// of 0 is not taken into account.
+func (l *UpstreamLimiter) Acquire(host string) (release func(latency time.Duration, failed bool), err error) {
+	p := l.policy()
+	if !p.Enabled {
+		return func(time.Duration, bool) {}, nil
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	u, ok := l.limits[host]
+	if !ok {
+		u = &upstreamLimit{limit: math.Max(1, float64(p.InitialLimit)), windowStarted: time.Now()}
+		l.limits[host] = u
+	}
+
+	if u.inFlight >= int(u.limit) {
+		return nil, errors.WithStack(ErrUpstreamOverloaded.
+			WithReasonf("The concurrency limit of %d requests in flight of upstream %s is reached.", int(u.limit), host))
+	}
+	u.inFlight++
+
+	var once sync.Once
+	return func(latency time.Duration, failed bool) {
+		once.Do(func() { l.release(u, p, latency, failed) })
+	}, nil
+}
+
+func (l *UpstreamLimiter) release(u *upstreamLimit, p UpstreamPolicy, latency time.Duration, failed bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	utilized := float64(u.inFlight) >= u.limit/2
+	u.inFlight--
+
+	if !failed && latency > 0 {
+		u.observe(latency)
+	}
+
+	baseline := u.currentBaseline()
+	if failed || (baseline > 0 && float64(latency) > float64(baseline)*p.LatencyTolerance) {
+		// At least one request must be let through, otherwise the limit could never grow again.
+		u.limit = math.Max(math.Max(1, float64(p.MinLimit)), u.limit*p.Backoff)
+	} else if utilized {
+		// The limit only grows while it is actually used, otherwise it would grow without bounds while idle.
+		u.limit = math.Min(float64(p.MaxLimit), u.limit+1/u.limit)
+	}
+}
+
+func (u *upstreamLimit) observe(latency time.Duration) {
+	if time.Since(u.windowStarted) >= baselineWindow {
+		u.baseline, u.windowMin, u.windowStarted = u.windowMin, 0, time.Now()
+	}
+	if u.windowMin == 0 || latency < u.windowMin {
+		u.windowMin = latency
+	}
+}
+
+func (u *upstreamLimit) currentBaseline() time.Duration {
+	switch {
+	case u.baseline == 0:
+		return u.windowMin
+	case u.windowMin == 0 || u.baseline < u.windowMin:
+		return u.baseline
+	default:
+		return u.windowMin
+	}
+}
+
+// Upstreams returns the state of the concurrency limits of all upstreams, sorted by host.
+func (l *UpstreamLimiter) Upstreams() []UpstreamStatus {
+	l.Lock()
+	defer l.Unlock()
+
+	statuses := make([]UpstreamStatus, 0, len(l.limits))
+	for host, u := range l.limits {
+		statuses = append(statuses, UpstreamStatus{Host: host, Limit: int(u.limit), InFlight: u.inFlight, Baseline: u.currentBaseline()})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Host < statuses[j].Host })
+	return statuses
+}
@@ -0,0 +1,79 @@
+package overload
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+)
+
+func TestUpstreamLimiter(t *testing.T) {
+	p := UpstreamPolicy{Enabled: true, InitialLimit: 2, MinLimit: 1, MaxLimit: 3, LatencyTolerance: 2, Backoff: 0.5}
+	l := NewUpstreamLimiter(func() UpstreamPolicy { return p })
+
+	limit := func() int {
+		for _, s := range l.Upstreams() {
+			if s.Host == "upstream" {
+				return s.Limit
+			}
+		}
+		return 0
+	}
+
+	t.Run("case=rejects requests above the limit", func(t *testing.T) {
+		first, err := l.Acquire("upstream")
+		require.NoError(t, err)
+		second, err := l.Acquire("upstream")
+		require.NoError(t, err)
+
+		_, err = l.Acquire("upstream")
+		require.Error(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, herodot.ToDefaultError(err, "").StatusCode())
+
+		other, err := l.Acquire("other-upstream")
+		require.NoError(t, err, "every upstream has its own limit")
+		other(time.Millisecond, false)
+
+		first(10*time.Millisecond, false)
+		second(10*time.Millisecond, false)
+		first(10*time.Millisecond, false)
+		assert.Equal(t, 0, l.Upstreams()[1].InFlight, "releasing twice has no effect")
+	})
+
+	t.Run("case=grows while requests are fast", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			release, err := l.Acquire("upstream")
+			require.NoError(t, err)
+			more, err := l.Acquire("upstream")
+			require.NoError(t, err)
+			release(10*time.Millisecond, false)
+			more(10*time.Millisecond, false)
+		}
+		assert.Equal(t, 3, limit(), "the limit is bounded by the maximum")
+	})
+
+	t.Run("case=shrinks once requests are slow or fail", func(t *testing.T) {
+		release, err := l.Acquire("upstream")
+		require.NoError(t, err)
+		release(time.Second, false)
+		assert.Equal(t, 1, limit())
+
+		release, err = l.Acquire("upstream")
+		require.NoError(t, err)
+		release(10*time.Millisecond, true)
+		assert.Equal(t, 1, limit(), "the limit is bounded by the minimum")
+	})
+
+	t.Run("case=does nothing when disabled", func(t *testing.T) {
+		p.Enabled = false
+		defer func() { p.Enabled = true }()
+		for i := 0; i < 5; i++ {
+			_, err := l.Acquire("upstream")
+			require.NoError(t, err)
+		}
+	})
+}
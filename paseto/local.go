@@ -0,0 +1,221 @@
+package paseto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/ory/x/stringslice"
+)
+
+// LocalKeySize is the size of the symmetric keys of local tokens.
+const LocalKeySize = 32
+
+// LocalVersions are all supported versions of the local purpose.
+var LocalVersions = []string{V4Local, V2Local}
+
+// Encrypt mints a local token using the given version, which must be one of LocalVersions. Version 2 encrypts the
+// claims using XChaCha20-Poly1305, version 4 using XChaCha20 and a BLAKE2b MAC.
+func Encrypt(version string, key []byte, claims map[string]interface{}, footer []byte) (string, error) {
+	if !stringslice.Has(LocalVersions, version) {
+		return "", errors.WithStack(ErrUnsupported)
+	}
+	if len(key) != LocalKeySize {
+		return "", errors.Errorf("paseto: the key must be %d bytes long", LocalKeySize)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	header := []byte(version + ".")
+	var body []byte
+	if version == V2Local {
+		body, err = encryptV2(key, header, payload, footer)
+	} else {
+		body, err = encryptV4(key, header, payload, footer)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	token := string(header) + base64.RawURLEncoding.EncodeToString(body)
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+
+	return token, nil
+}
+
+// Decrypt decrypts the token using the keys resolved from the footer and validates the "exp" and "nbf" claims. The
+// key function receives the raw footer which is empty if the token has none.
+func Decrypt(token string, versions []string, key func(footer []byte) ([][]byte, error)) (*Token, error) {
+	version, body, footer, err := split(token, LocalVersions, versions)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := key(footer)
+	if err != nil {
+		return nil, err
+	}
+
+	header := []byte(version + ".")
+	for _, k := range keys {
+		if len(k) != LocalKeySize {
+			continue
+		}
+
+		var payload []byte
+		if version == V2Local {
+			payload, err = decryptV2(k, header, body, footer)
+		} else {
+			payload, err = decryptV4(k, header, body, footer)
+		}
+		if err == nil {
+			return parse(version, payload, footer)
+		}
+	}
+
+	return nil, errors.WithStack(ErrDecryption)
+}
+
+func encryptV2(key, header, payload, footer []byte) ([]byte, error) {
+	b := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// The nonce is derived from the random bytes and the payload which protects against weak random number generators.
+	h, err := blake2b.New(chacha20poly1305.NonceSizeX, b)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	_, _ = h.Write(payload)
+	nonce := h.Sum(nil)
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return append(nonce, aead.Seal(nil, nonce, payload, pae(header, nonce, footer))...), nil
+}
+
+func decryptV2(key, header, body, footer []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+	nonce, ciphertext := body[:aead.NonceSize()], body[aead.NonceSize():]
+
+	payload, err := aead.Open(nil, nonce, ciphertext, pae(header, nonce, footer))
+	if err != nil {
+		return nil, errors.WithStack(ErrDecryption)
+	}
+	return payload, nil
+}
+
+const (
+	v4NonceSize = 32
+	v4MACSize   = 32
+)
+
+func encryptV4(key, header, payload, footer []byte) ([]byte, error) {
+	nonce := make([]byte, v4NonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	encryptionKey, counterNonce, authenticationKey, err := splitKeyV4(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(encryptionKey, counterNonce)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ciphertext := make([]byte, len(payload))
+	stream.XORKeyStream(ciphertext, payload)
+
+	mac, err := macV4(authenticationKey, header, nonce, ciphertext, footer)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(nonce, ciphertext...), mac...), nil
+}
+
+func decryptV4(key, header, body, footer []byte) ([]byte, error) {
+	if len(body) < v4NonceSize+v4MACSize {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+	nonce, ciphertext, tag := body[:v4NonceSize], body[v4NonceSize:len(body)-v4MACSize], body[len(body)-v4MACSize:]
+
+	encryptionKey, counterNonce, authenticationKey, err := splitKeyV4(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := macV4(authenticationKey, header, nonce, ciphertext, footer)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, tag) {
+		return nil, errors.WithStack(ErrDecryption)
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(encryptionKey, counterNonce)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	payload := make([]byte, len(ciphertext))
+	stream.XORKeyStream(payload, ciphertext)
+	return payload, nil
+}
+
+// splitKeyV4 derives the encryption key, the XChaCha20 nonce, and the authentication key of a version 4 token from
+// the symmetric key and the random nonce of the token.
+func splitKeyV4(key, nonce []byte) (encryptionKey, counterNonce, authenticationKey []byte, err error) {
+	tmp, err := blake2bSum(key, chacha20.KeySize+chacha20.NonceSizeX, []byte("paseto-encryption-key"), nonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	authenticationKey, err = blake2bSum(key, 32, []byte("paseto-auth-key-for-aead"), nonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return tmp[:chacha20.KeySize], tmp[chacha20.KeySize:], authenticationKey, nil
+}
+
+// macV4 authenticates the header, the nonce, the ciphertext, the footer and the implicit assertion, which is always
+// empty here, of a version 4 token.
+func macV4(authenticationKey, header, nonce, ciphertext, footer []byte) ([]byte, error) {
+	return blake2bSum(authenticationKey, v4MACSize, pae(header, nonce, ciphertext, footer, []byte{}))
+}
+
+func blake2bSum(key []byte, size int, pieces ...[]byte) ([]byte, error) {
+	h, err := blake2b.New(size, key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, p := range pieces {
+		_, _ = h.Write(p)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,201 @@
+// Package paseto implements the public and local purposes of Platform-Agnostic Security Tokens (PASETO) versions 2
+// and 4. Both versions sign public tokens using Ed25519 and differ only in how the signed message is encoded. Local
+// tokens are encrypted with a symmetric key, see Encrypt.
+package paseto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/x/stringslice"
+)
+
+const (
+	V2Public = "v2.public"
+	V4Public = "v4.public"
+	V2Local  = "v2.local"
+	V4Local  = "v4.local"
+)
+
+var (
+	ErrMalformed        = errors.New("paseto: token is malformed")
+	ErrUnsupported      = errors.New("paseto: token version or purpose is not supported")
+	ErrInvalidSignature = errors.New("paseto: token signature is invalid")
+	ErrExpired          = errors.New("paseto: token is expired")
+	ErrNotValidYet      = errors.New("paseto: token is not valid yet")
+	ErrDecryption       = errors.New("paseto: token could not be decrypted")
+)
+
+// Versions are all supported versions of the public purpose.
+var Versions = []string{V4Public, V2Public}
+
+// Token is a verified or decrypted PASETO token.
+type Token struct {
+	Version string
+	Claims  map[string]interface{}
+	Footer  []byte
+}
+
+// Footer is the JSON footer of tokens minted by ORY Oathkeeper.
+type Footer struct {
+	KeyID string `json:"kid,omitempty"`
+}
+
+// Sign mints a token using the given version, which must be one of Versions.
+func Sign(version string, key ed25519.PrivateKey, claims map[string]interface{}, footer []byte) (string, error) {
+	if !stringslice.Has(Versions, version) {
+		return "", errors.WithStack(ErrUnsupported)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	header := version + "."
+	signature := ed25519.Sign(key, preAuthEncode(version, []byte(header), payload, footer))
+
+	token := header + base64.RawURLEncoding.EncodeToString(append(payload, signature...))
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+
+	return token, nil
+}
+
+// Verify checks the signature of the token using the key resolved from the footer and validates the "exp" and "nbf"
+// claims. The key function receives the raw footer which is empty if the token has none.
+func Verify(token string, versions []string, key func(footer []byte) ([]ed25519.PublicKey, error)) (*Token, error) {
+	version, body, footer, err := split(token, Versions, versions)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < ed25519.SignatureSize {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+
+	keys, err := key(footer)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, signature := body[:len(body)-ed25519.SignatureSize], body[len(body)-ed25519.SignatureSize:]
+	message := preAuthEncode(version, []byte(version+"."), payload, footer)
+
+	var valid bool
+	for _, k := range keys {
+		if len(k) == ed25519.PublicKeySize && ed25519.Verify(k, message, signature) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, errors.WithStack(ErrInvalidSignature)
+	}
+
+	return parse(version, payload, footer)
+}
+
+// split returns the version, the decoded body, and the decoded footer of the token. The version must be one of the
+// supported versions and one of the allowed versions.
+func split(token string, supported, allowed []string) (version string, body, footer []byte, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 && len(parts) != 4 {
+		return "", nil, nil, errors.WithStack(ErrMalformed)
+	}
+
+	version = parts[0] + "." + parts[1]
+	if !stringslice.Has(supported, version) || !stringslice.Has(allowed, version) {
+		return "", nil, nil, errors.WithStack(ErrUnsupported)
+	}
+
+	if body, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, errors.WithStack(ErrMalformed)
+	}
+
+	if len(parts) == 4 {
+		if footer, err = base64.RawURLEncoding.DecodeString(parts[3]); err != nil {
+			return "", nil, nil, errors.WithStack(ErrMalformed)
+		}
+	}
+
+	return version, body, footer, nil
+}
+
+// parse decodes the claims of a verified or decrypted payload and validates the "exp" and "nbf" claims.
+func parse(version string, payload, footer []byte) (*Token, error) {
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.WithStack(ErrMalformed)
+	}
+
+	now := time.Now()
+	if exp, ok := Time(claims, "exp"); ok && !now.Before(exp) {
+		return nil, errors.WithStack(ErrExpired)
+	}
+	if nbf, ok := Time(claims, "nbf"); ok && now.Before(nbf) {
+		return nil, errors.WithStack(ErrNotValidYet)
+	}
+
+	return &Token{Version: version, Claims: claims, Footer: footer}, nil
+}
+
+// Time returns the time of a registered claim such as "exp", "nbf", or "iat" which PASETO encodes as RFC 3339 strings.
+func Time(claims map[string]interface{}, claim string) (time.Time, bool) {
+	v, ok := claims[claim].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// PrivateKey returns the first Ed25519 private key of the sets.
+func PrivateKey(sets []jose.JSONWebKeySet) (ed25519.PrivateKey, string, error) {
+	for _, set := range sets {
+		for _, k := range set.Keys {
+			if key, ok := k.Key.(ed25519.PrivateKey); ok {
+				return key, k.KeyID, nil
+			}
+		}
+	}
+	return nil, "", errors.New("paseto: no Ed25519 private key could be found")
+}
+
+// preAuthEncode returns the message which is signed. Version 4 additionally covers an implicit assertion which
+// is always empty here.
+func preAuthEncode(version string, pieces ...[]byte) []byte {
+	if version == V4Public {
+		pieces = append(pieces, []byte{})
+	}
+	return pae(pieces...)
+}
+
+func pae(pieces ...[]byte) []byte {
+	var b bytes.Buffer
+	le64 := func(n int) {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], uint64(n)&^(1<<63))
+		b.Write(buf[:])
+	}
+
+	le64(len(pieces))
+	for _, p := range pieces {
+		le64(len(p))
+		b.Write(p)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,139 @@
+package paseto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestPASETO(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	keys := func(keys ...ed25519.PublicKey) func([]byte) ([]ed25519.PublicKey, error) {
+		return func([]byte) ([]ed25519.PublicKey, error) {
+			return keys, nil
+		}
+	}
+	symmetric := func(keys ...[]byte) func([]byte) ([][]byte, error) {
+		return func([]byte) ([][]byte, error) {
+			return keys, nil
+		}
+	}
+
+	t.Run("case=official test vector 4-S-1", func(t *testing.T) {
+		sk, err := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2")
+		require.NoError(t, err)
+
+		// The token expired in 2022 which is only checked once the signature was verified.
+		_, err = Verify(
+			"v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA",
+			Versions,
+			keys(ed25519.PrivateKey(sk).Public().(ed25519.PublicKey)),
+		)
+		assert.Equal(t, ErrExpired, errors.Cause(err))
+	})
+
+	for _, version := range Versions {
+		t.Run("version="+version, func(t *testing.T) {
+			token, err := Sign(version, private, map[string]interface{}{"sub": "foo", "exp": time.Now().Add(time.Hour).Format(time.RFC3339)}, []byte(`{"kid":"bar"}`))
+			require.NoError(t, err)
+
+			var footer []byte
+			verified, err := Verify(token, Versions, func(f []byte) ([]ed25519.PublicKey, error) {
+				footer = f
+				return []ed25519.PublicKey{other, public}, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, version, verified.Version)
+			assert.Equal(t, "foo", verified.Claims["sub"])
+			assert.Equal(t, `{"kid":"bar"}`, string(footer))
+
+			_, err = Verify(token, Versions, keys(other))
+			assert.Equal(t, ErrInvalidSignature, errors.Cause(err))
+
+			_, err = Verify(token[:len(token)-2], Versions, keys(public))
+			assert.Error(t, err)
+		})
+	}
+
+	for _, version := range LocalVersions {
+		t.Run("version="+version, func(t *testing.T) {
+			key := make([]byte, LocalKeySize)
+			_, err := rand.Read(key)
+			require.NoError(t, err)
+			other := make([]byte, LocalKeySize)
+
+			token, err := Encrypt(version, key, map[string]interface{}{"sub": "foo", "exp": time.Now().Add(time.Hour).Format(time.RFC3339)}, []byte(`{"kid":"bar"}`))
+			require.NoError(t, err)
+
+			var footer []byte
+			decrypted, err := Decrypt(token, LocalVersions, func(f []byte) ([][]byte, error) {
+				footer = f
+				return [][]byte{other, key}, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, version, decrypted.Version)
+			assert.Equal(t, "foo", decrypted.Claims["sub"])
+			assert.Equal(t, `{"kid":"bar"}`, string(footer))
+
+			_, err = Decrypt(token, LocalVersions, symmetric(other))
+			assert.Equal(t, ErrDecryption, errors.Cause(err))
+
+			// The footer is authenticated.
+			parts := strings.Split(token, ".")
+			_, err = Decrypt(strings.Join(parts[:3], ".")+"."+base64.RawURLEncoding.EncodeToString([]byte(`{"kid":"baz"}`)), LocalVersions, symmetric(key))
+			assert.Equal(t, ErrDecryption, errors.Cause(err))
+
+			_, err = Decrypt(token[:len(token)-2], LocalVersions, symmetric(key))
+			assert.Error(t, err)
+
+			_, err = Verify(token, Versions, keys(public))
+			assert.Equal(t, ErrUnsupported, errors.Cause(err))
+		})
+	}
+
+	t.Run("case=disallowed version", func(t *testing.T) {
+		token, err := Sign(V2Public, private, map[string]interface{}{}, nil)
+		require.NoError(t, err)
+
+		_, err = Verify(token, []string{V4Public}, keys(public))
+		assert.Equal(t, ErrUnsupported, errors.Cause(err))
+	})
+
+	t.Run("case=time claims", func(t *testing.T) {
+		for claim, expected := range map[string]error{
+			"exp": ErrExpired,
+			"nbf": nil,
+		} {
+			token, err := Sign(V4Public, private, map[string]interface{}{claim: time.Now().Add(-time.Minute).Format(time.RFC3339)}, nil)
+			require.NoError(t, err)
+
+			_, err = Verify(token, Versions, keys(public))
+			assert.Equal(t, expected, errors.Cause(err), claim)
+		}
+
+		token, err := Sign(V4Public, private, map[string]interface{}{"nbf": time.Now().Add(time.Minute).Format(time.RFC3339)}, nil)
+		require.NoError(t, err)
+
+		_, err = Verify(token, Versions, keys(public))
+		assert.Equal(t, ErrNotValidYet, errors.Cause(err))
+	})
+
+	t.Run("case=malformed", func(t *testing.T) {
+		for _, token := range []string{"", "v4.public", "v4.public.!!!", "v4.public.AAAA", "v1.local.AAAA"} {
+			_, err := Verify(token, Versions, keys(public))
+			assert.Error(t, err, token)
+		}
+	})
+}
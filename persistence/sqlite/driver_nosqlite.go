@@ -0,0 +1,8 @@
+// +build !sqlite
+
+package sqlite
+
+const (
+	supported  = false
+	driverName = "sqlite3"
+)
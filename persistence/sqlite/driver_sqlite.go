@@ -0,0 +1,12 @@
+// +build sqlite
+
+package sqlite
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	supported  = true
+	driverName = "sqlite3"
+)
@@ -0,0 +1,150 @@
+// Package sqlite implements an embedded SQLite store for access rules and JSON Web Key Sets. It is meant for
+// single-node deployments where state should survive restarts without running an external database.
+//
+// SQLite support requires cgo and must be enabled by building ORY Oathkeeper with the `sqlite` build tag:
+//
+//	$ CGO_ENABLED=1 go build -tags sqlite .
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Scheme is the URL scheme used to reference SQLite databases, for example `sqlite:///var/lib/oathkeeper/db.sqlite`.
+const Scheme = "sqlite"
+
+// DefaultJSONWebKeySet is the name of the JSON Web Key Set used when the `set` query parameter is not set.
+const DefaultJSONWebKeySet = "default"
+
+// ErrNotSupported is returned if the binary was built without SQLite support.
+var ErrNotSupported = errors.New("sqlite: this binary was built without SQLite support, rebuild it using `-tags sqlite` and `CGO_ENABLED=1`")
+
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS oathkeeper_access_rules (
+	id TEXT NOT NULL PRIMARY KEY,
+	rule TEXT NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS oathkeeper_json_web_key_sets (
+	id TEXT NOT NULL PRIMARY KEY,
+	keys TEXT NOT NULL
+)`,
+}
+
+// Persister stores access rules and JSON Web Key Sets in a SQLite database.
+type Persister struct {
+	db *sql.DB
+}
+
+// Path returns the file system path of the database referenced by u.
+func Path(u *url.URL) string {
+	return filepath.Clean(u.Host + u.Path)
+}
+
+// JSONWebKeySetID returns the JSON Web Key Set ID referenced by u using the `set` query parameter.
+func JSONWebKeySetID(u *url.URL) string {
+	if id := u.Query().Get("set"); id != "" {
+		return id
+	}
+	return DefaultJSONWebKeySet
+}
+
+// Open opens (and if necessary creates) the SQLite database referenced by u.
+func Open(u *url.URL) (*Persister, error) {
+	if !supported {
+		return nil, errors.WithStack(ErrNotSupported)
+	}
+
+	if u.Scheme != Scheme {
+		return nil, errors.Errorf("sqlite: expected URL scheme %s but got: %s", Scheme, u.String())
+	}
+
+	p := Path(u)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	db, err := sql.Open(driverName, "file:"+p+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, errors.Wrapf(err, "sqlite: unable to open database %s", p)
+	}
+
+	for _, m := range migrations {
+		if _, err := db.Exec(m); err != nil {
+			_ = db.Close()
+			return nil, errors.Wrapf(err, "sqlite: unable to migrate database %s", p)
+		}
+	}
+
+	return &Persister{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (p *Persister) Close() error {
+	return p.db.Close()
+}
+
+// AccessRules returns all stored access rules ordered by their ID.
+func (p *Persister) AccessRules(ctx context.Context) ([]json.RawMessage, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT rule FROM oathkeeper_access_rules ORDER BY id`)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	var rules []json.RawMessage
+	for rows.Next() {
+		var rule string
+		if err := rows.Scan(&rule); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		rules = append(rules, json.RawMessage(rule))
+	}
+
+	return rules, errors.WithStack(rows.Err())
+}
+
+// SetAccessRules replaces all stored access rules in a single transaction. The map key is the rule's ID.
+func (p *Persister) SetAccessRules(ctx context.Context, rules map[string]json.RawMessage) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM oathkeeper_access_rules`); err != nil {
+		_ = tx.Rollback()
+		return errors.WithStack(err)
+	}
+
+	for id, rule := range rules {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO oathkeeper_access_rules (id, rule) VALUES (?, ?)`, id, string(rule)); err != nil {
+			_ = tx.Rollback()
+			return errors.WithStack(err)
+		}
+	}
+
+	return errors.WithStack(tx.Commit())
+}
+
+// JSONWebKeySet returns the JSON Web Key Set with the given ID.
+func (p *Persister) JSONWebKeySet(ctx context.Context, id string) (json.RawMessage, error) {
+	var keys string
+	if err := p.db.QueryRowContext(ctx, `SELECT keys FROM oathkeeper_json_web_key_sets WHERE id = ?`, id).Scan(&keys); err == sql.ErrNoRows {
+		return nil, errors.Errorf(`sqlite: JSON Web Key Set "%s" does not exist`, id)
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return json.RawMessage(keys), nil
+}
+
+// SetJSONWebKeySet creates or replaces the JSON Web Key Set with the given ID.
+func (p *Persister) SetJSONWebKeySet(ctx context.Context, id string, keys json.RawMessage) error {
+	_, err := p.db.ExecContext(ctx, `INSERT OR REPLACE INTO oathkeeper_json_web_key_sets (id, keys) VALUES (?, ?)`, id, string(keys))
+	return errors.WithStack(err)
+}
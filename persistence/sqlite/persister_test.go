@@ -0,0 +1,65 @@
+// +build sqlite
+
+package sqlite_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/persistence/sqlite"
+)
+
+func TestPersister(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oathkeeper-sqlite")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	u, err := url.Parse("sqlite://" + filepath.Join(dir, "db.sqlite") + "?set=id_token")
+	require.NoError(t, err)
+	assert.Equal(t, "id_token", sqlite.JSONWebKeySetID(u))
+
+	p, err := sqlite.Open(u)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	rules, err := p.AccessRules(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	require.NoError(t, p.SetAccessRules(ctx, map[string]json.RawMessage{
+		"b": json.RawMessage(`{"id":"b"}`),
+		"a": json.RawMessage(`{"id":"a"}`),
+	}))
+	require.NoError(t, p.SetAccessRules(ctx, map[string]json.RawMessage{
+		"c": json.RawMessage(`{"id":"c"}`),
+		"a": json.RawMessage(`{"id":"a"}`),
+	}))
+
+	_, err = p.JSONWebKeySet(ctx, "id_token")
+	require.Error(t, err)
+	require.NoError(t, p.SetJSONWebKeySet(ctx, "id_token", json.RawMessage(`{"keys":[]}`)))
+	require.NoError(t, p.Close())
+
+	// State must survive re-opening the database.
+	p, err = sqlite.Open(u)
+	require.NoError(t, err)
+	defer p.Close()
+
+	rules, err = p.AccessRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.JSONEq(t, `{"id":"a"}`, string(rules[0]))
+	assert.JSONEq(t, `{"id":"c"}`, string(rules[1]))
+
+	keys, err := p.JSONWebKeySet(ctx, "id_token")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"keys":[]}`, string(keys))
+}
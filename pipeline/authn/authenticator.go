@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/ory/herodot"
 
+	"github.com/ory/oathkeeper/helper"
 	"github.com/ory/oathkeeper/pipeline"
 )
 
@@ -37,16 +42,43 @@ func NewErrAuthenticatorMisconfigured(a Authenticator, err error) *herodot.Defau
 	)
 }
 
+// validateTokenAge returns an error if maxAge is set and the token was not issued within maxAge. Tokens without
+// an issued at time are rejected if maxAge is set.
+func validateTokenAge(maxAge string, issuedAt time.Time) error {
+	if maxAge == "" {
+		return nil
+	}
+
+	age, err := time.ParseDuration(maxAge)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if issuedAt.IsZero() {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The token does not specify when it was issued but a maximum token age is required."))
+	}
+
+	if time.Since(issuedAt) > age {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf("The token was issued more than %s ago.", age))
+	}
+
+	return nil
+}
+
 type AuthenticationSession struct {
 	Subject      string                 `json:"subject"`
 	Extra        map[string]interface{} `json:"extra"`
 	Header       http.Header            `json:"header"`
 	MatchContext MatchContext           `json:"match_context"`
+
+	// ConsumedCookies are the names of the cookies the authenticators read credentials from.
+	ConsumedCookies []string `json:"-"`
 }
 
 type MatchContext struct {
 	RegexpCaptureGroups []string `json:"regexp_capture_groups"`
 	URL                 *url.URL `json:"url"`
+	ClientIP            string   `json:"client_ip"`
 }
 
 func (a *AuthenticationSession) SetHeader(key, val string) {
@@ -55,3 +87,48 @@ func (a *AuthenticationSession) SetHeader(key, val string) {
 	}
 	a.Header.Set(key, val)
 }
+
+// ConsumeCookies records that the authenticator read credentials from the cookies. Empty names are ignored.
+func (a *AuthenticationSession) ConsumeCookies(names ...string) {
+	for _, name := range names {
+		if name != "" {
+			a.ConsumedCookies = append(a.ConsumedCookies, name)
+		}
+	}
+}
+
+var sessionPool = sync.Pool{
+	New: func() interface{} {
+		return new(AuthenticationSession)
+	},
+}
+
+// AcquireSession returns an empty session from the pool. Sessions which are no longer used should be returned to the
+// pool using ReleaseSession.
+func AcquireSession() *AuthenticationSession {
+	return sessionPool.Get().(*AuthenticationSession)
+}
+
+// ReleaseSession resets the session and returns it to the pool. The session must not be used afterwards.
+//
+// The maps of the session are dropped instead of cleared because authenticators may have assigned maps which are
+// shared, e.g. with a cache, and because headers of the session may still be referenced by captures or events.
+func ReleaseSession(s *AuthenticationSession) {
+	if s == nil {
+		return
+	}
+	*s = AuthenticationSession{}
+	sessionPool.Put(s)
+}
+
+// matchesAnyPattern reports whether the value matches one of the patterns where "*" matches any sequence of
+// characters.
+func matchesAnyPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		expr := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+		if regexp.MustCompile(expr).MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,311 @@
+package authn
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/x"
+)
+
+// apiKeyDefaultHeader is the header the key is read from unless key_from is set.
+const apiKeyDefaultHeader = "X-Api-Key"
+
+type AuthenticatorAPIKeyConfiguration struct {
+	KeyFrom *helper.BearerTokenLocation `json:"key_from"`
+
+	// Keys, KeysFile, KeysEnv and KeysURL are the sources of the keys. If a hash is listed by several sources, the
+	// entry of the first source in this order is used.
+	Keys     []AuthenticatorAPIKeyEntry `json:"keys"`
+	KeysFile string                     `json:"keys_file"`
+	KeysEnv  string                     `json:"keys_env"`
+	KeysURL  string                     `json:"keys_url"`
+
+	// CacheTTL is how long the keys fetched from KeysURL are used before they are fetched again.
+	CacheTTL string `json:"cache_ttl"`
+
+	cacheTTL time.Duration
+}
+
+// AuthenticatorAPIKeyEntry maps the hash of a key to the subject and the extra data of the session.
+type AuthenticatorAPIKeyEntry struct {
+	// Hash is the hex-encoded SHA-256 hash of the key.
+	Hash    string                 `json:"hash"`
+	Subject string                 `json:"subject"`
+	Extra   map[string]interface{} `json:"extra"`
+}
+
+type apiKeySource struct {
+	modTime   time.Time
+	fetchedAt time.Time
+	keys      map[string]*AuthenticatorAPIKeyEntry
+}
+
+// AuthenticatorAPIKey authenticates requests using static API keys. Keys are stored as SHA-256 hashes, which is
+// sufficient for randomly generated keys and allows looking keys up without comparing them to every entry.
+type AuthenticatorAPIKey struct {
+	c configuration.Provider
+
+	client  *http.Client
+	flights singleflight.Group
+
+	sync.Mutex
+	sources map[string]*apiKeySource
+}
+
+func NewAuthenticatorAPIKey(c configuration.Provider) *AuthenticatorAPIKey {
+	return &AuthenticatorAPIKey{
+		c:       c,
+		client:  httpx.NewResilientClientLatencyToleranceSmall(nil),
+		sources: map[string]*apiKeySource{},
+	}
+}
+
+func (a *AuthenticatorAPIKey) GetID() string {
+	return "api_key"
+}
+
+func (a *AuthenticatorAPIKey) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorAPIKey) Config(config json.RawMessage) (*AuthenticatorAPIKeyConfiguration, error) {
+	var c AuthenticatorAPIKeyConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if len(c.Keys) == 0 && c.KeysFile == "" && c.KeysEnv == "" && c.KeysURL == "" {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("at least one of keys, keys_file, keys_env and keys_url must be set"))
+	}
+
+	if _, err := indexAPIKeys(c.Keys); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if c.KeyFrom == nil {
+		header := apiKeyDefaultHeader
+		c.KeyFrom = &helper.BearerTokenLocation{Header: &header}
+	}
+
+	if c.CacheTTL == "" {
+		c.CacheTTL = "1m"
+	}
+	ttl, err := time.ParseDuration(c.CacheTTL)
+	if err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.WithStack(err))
+	}
+	c.cacheTTL = ttl
+
+	return &c, nil
+}
+
+func (a *AuthenticatorAPIKey) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	key := helper.BearerTokenFromRequest(r, cf.KeyFrom)
+	if key == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	entry, err := a.lookup(cf, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return err
+	} else if entry == nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The API key is invalid."))
+	}
+
+	session.Subject = entry.Subject
+	session.Extra = map[string]interface{}{}
+	for k, v := range entry.Extra {
+		session.Extra[k] = v
+	}
+	return nil
+}
+
+// lookup returns the entry of the hash or nil if no source lists it.
+func (a *AuthenticatorAPIKey) lookup(cf *AuthenticatorAPIKeyConfiguration, hash string) (*AuthenticatorAPIKeyEntry, error) {
+	inline, err := indexAPIKeys(cf.Keys)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := inline[hash]; ok {
+		return entry, nil
+	}
+
+	if cf.KeysEnv != "" {
+		keys, err := parseAPIKeys([]byte(os.Getenv(cf.KeysEnv)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse the keys of environment variable %s", cf.KeysEnv)
+		}
+		if entry, ok := keys[hash]; ok {
+			return entry, nil
+		}
+	}
+
+	if cf.KeysFile != "" {
+		keys, err := a.file(cf.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		if entry, ok := keys[hash]; ok {
+			return entry, nil
+		}
+	}
+
+	if cf.KeysURL != "" {
+		keys, err := a.remote(cf.KeysURL, cf.cacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		if entry, ok := keys[hash]; ok {
+			return entry, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// file returns the keys of the file. The file is parsed again once it was modified.
+func (a *AuthenticatorAPIKey) file(path string) (map[string]*AuthenticatorAPIKeyEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if s, ok := a.sources["file:"+path]; ok && s.modTime.Equal(info.ModTime()) {
+		return s.keys, nil
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	keys, err := parseAPIKeys(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the keys of file %s", path)
+	}
+
+	a.sources["file:"+path] = &apiKeySource{modTime: info.ModTime(), keys: keys}
+	return keys, nil
+}
+
+// remote returns the keys of the key store, fetching them again once they are older than ttl. If the key store can
+// not be reached, the keys fetched before are used until the next attempt.
+func (a *AuthenticatorAPIKey) remote(location string, ttl time.Duration) (map[string]*AuthenticatorAPIKeyEntry, error) {
+	a.Lock()
+	s, ok := a.sources["url:"+location]
+	a.Unlock()
+	if ok && time.Since(s.fetchedAt) < ttl {
+		return s.keys, nil
+	}
+
+	// Concurrent requests share one fetch, so it must not be canceled with the request which started it.
+	v, err, _ := a.flights.Do(location, func() (interface{}, error) {
+		ctx, cancel := x.WithOptionalTimeout(context.Background(), a.c.RemoteResponseTimeout())
+		defer cancel()
+		return a.fetch(ctx, location)
+	})
+
+	a.Lock()
+	defer a.Unlock()
+
+	if err != nil {
+		if ok {
+			a.sources["url:"+location] = &apiKeySource{fetchedAt: time.Now(), keys: s.keys}
+			return s.keys, nil
+		}
+		return nil, err
+	}
+
+	keys := v.(map[string]*AuthenticatorAPIKeyEntry)
+	a.sources["url:"+location] = &apiKeySource{fetchedAt: time.Now(), keys: keys}
+	return keys, nil
+}
+
+func (a *AuthenticatorAPIKey) fetch(ctx context.Context, location string) (map[string]*AuthenticatorAPIKeyEntry, error) {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("expected status code %d from key store %s but got %d", http.StatusOK, location, res.StatusCode)
+	}
+
+	raw, err := x.ReadResponse(res, a.c.RemoteResponseMaxBodySize())
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read the keys of key store %s", location)
+	}
+
+	keys, err := parseAPIKeys(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the keys of key store %s", location)
+	}
+	return keys, nil
+}
+
+// parseAPIKeys parses a JSON or YAML list of entries.
+func parseAPIKeys(raw []byte) (map[string]*AuthenticatorAPIKeyEntry, error) {
+	var entries []AuthenticatorAPIKeyEntry
+	if err := yaml.Unmarshal(raw, &entries); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return indexAPIKeys(entries)
+}
+
+// indexAPIKeys returns the entries by their lower-case hash.
+func indexAPIKeys(entries []AuthenticatorAPIKeyEntry) (map[string]*AuthenticatorAPIKeyEntry, error) {
+	keys := make(map[string]*AuthenticatorAPIKeyEntry, len(entries))
+	for k := range entries {
+		entry := entries[k]
+		entry.Hash = strings.ToLower(entry.Hash)
+		if raw, err := hex.DecodeString(entry.Hash); err != nil || len(raw) != sha256.Size {
+			return nil, errors.Errorf("the hash of key %d must be a hex-encoded SHA-256 hash", k)
+		}
+		if entry.Subject == "" {
+			return nil, errors.Errorf("the subject of key %d must be set", k)
+		}
+		if _, ok := keys[entry.Hash]; !ok {
+			keys[entry.Hash] = &entry
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,191 @@
+package authn_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorAPIKey(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "api_key", a.GetID())
+
+	hash := func(key string) string {
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:])
+	}
+
+	file, err := ioutil.TempFile("", "api-keys-*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("- hash: " + hash("file-key") + "\n  subject: reporting\n  extra:\n    team: analytics\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	require.NoError(t, os.Setenv("OATHKEEPER_TEST_API_KEYS", `[{"hash":"`+hash("env-key")+`","subject":"deployer"}]`))
+	defer os.Unsetenv("OATHKEEPER_TEST_API_KEYS")
+
+	var fetched int32
+	var storeDown int32
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		if atomic.LoadInt32(&storeDown) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `[{"hash":"%s","subject":"partner","extra":{"tier":"gold"}}]`, hash("remote-key"))
+	}))
+	defer store.Close()
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"hash": hash("inline-key"), "subject": "ci"},
+			{"hash": hash("file-key"), "subject": "shadowed"},
+		},
+		"keys_file": file.Name(),
+		"keys_env":  "OATHKEEPER_TEST_API_KEYS",
+		"keys_url":  store.URL,
+		"cache_ttl": "1h",
+	})
+	fileOnly, _ := json.Marshal(map[string]interface{}{"keys_file": file.Name(), "key_from": map[string]string{"query_parameter": "api_key"}})
+
+	request := func(key string) *http.Request {
+		return &http.Request{Header: http.Header{"X-Api-Key": {key}}}
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         json.RawMessage
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+			expectExtra    map[string]interface{}
+		}{
+			{
+				d:              "should not be responsible without a key",
+				r:              &http.Request{Header: http.Header{}},
+				config:         config,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass with an inline key",
+				r:             request("inline-key"),
+				config:        config,
+				expectSubject: "ci",
+				expectExtra:   map[string]interface{}{},
+			},
+			{
+				d:             "should prefer inline keys over the keys of the file",
+				r:             request("file-key"),
+				config:        config,
+				expectSubject: "shadowed",
+				expectExtra:   map[string]interface{}{},
+			},
+			{
+				d:             "should pass with a key of the environment",
+				r:             request("env-key"),
+				config:        config,
+				expectSubject: "deployer",
+				expectExtra:   map[string]interface{}{},
+			},
+			{
+				d:             "should pass with a key of the key store",
+				r:             request("remote-key"),
+				config:        config,
+				expectSubject: "partner",
+				expectExtra:   map[string]interface{}{"tier": "gold"},
+			},
+			{
+				d:          "should fail with an unknown key",
+				r:          request("unknown-key"),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:             "should read the key from the query parameter",
+				r:             &http.Request{Header: http.Header{}, URL: &url.URL{RawQuery: "api_key=file-key"}},
+				config:        fileOnly,
+				expectSubject: "reporting",
+				expectExtra:   map[string]interface{}{"team": "analytics"},
+			},
+			{
+				d:              "should not read the header if the key is read from the query parameter",
+				r:              request("file-key"),
+				config:         fileOnly,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, tc.config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				assert.Equal(t, tc.expectExtra, session.Extra)
+			})
+		}
+	})
+
+	t.Run("method=authenticate/case=the keys of the key store are cached", func(t *testing.T) {
+		before := atomic.LoadInt32(&fetched)
+		require.NoError(t, a.Authenticate(request("remote-key"), new(AuthenticationSession), config, nil))
+		require.Error(t, a.Authenticate(request("unknown-key"), new(AuthenticationSession), config, nil))
+		assert.Equal(t, before, atomic.LoadInt32(&fetched))
+	})
+
+	t.Run("method=authenticate/case=the keys fetched before are used while the key store is down", func(t *testing.T) {
+		atomic.StoreInt32(&storeDown, 1)
+		defer atomic.StoreInt32(&storeDown, 0)
+
+		expiring, _ := json.Marshal(map[string]interface{}{"keys_url": store.URL, "cache_ttl": "1ns"})
+		require.NoError(t, a.Authenticate(request("remote-key"), new(AuthenticationSession), expiring, nil))
+
+		unreachable, _ := json.Marshal(map[string]interface{}{"keys_url": store.URL + "/other", "cache_ttl": "1ns"})
+		require.Error(t, a.Authenticate(request("remote-key"), new(AuthenticationSession), unreachable, nil))
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorAPIKeyIsEnabled, true)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"keys":[{"hash":"plaintext","subject":"ci"}]}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"keys":[{"hash":"`+hash("key")+`"}]}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorAPIKeyIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
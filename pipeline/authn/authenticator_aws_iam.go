@@ -0,0 +1,199 @@
+package authn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/go-convenience/stringslice"
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/x"
+)
+
+// awsIAMTokenPrefix is the prefix of tokens generated by `aws eks get-token` and compatible tools.
+const awsIAMTokenPrefix = "k8s-aws-v1."
+
+// awsIAMAudienceHeader is the signed header binding a presigned request to an audience.
+const awsIAMAudienceHeader = "x-k8s-aws-id"
+
+// awsIAMMaxExpires is the longest validity STS accepts for presigned requests, in seconds.
+const awsIAMMaxExpires = 900
+
+type AuthenticatorAWSIAMConfiguration struct {
+	STSEndpoints        []string                    `json:"sts_endpoints"`
+	Audience            string                      `json:"target_audience"`
+	AllowedAccounts     []string                    `json:"allowed_accounts"`
+	AllowedARNs         []string                    `json:"allowed_arns"`
+	BearerTokenLocation *helper.BearerTokenLocation `json:"token_from"`
+}
+
+type awsCallerIdentity struct {
+	GetCallerIdentityResponse struct {
+		GetCallerIdentityResult struct {
+			Account string `json:"Account"`
+			Arn     string `json:"Arn"`
+			UserID  string `json:"UserId"`
+		} `json:"GetCallerIdentityResult"`
+	} `json:"GetCallerIdentityResponse"`
+}
+
+// AuthenticatorAWSIAM authenticates AWS workloads using presigned sts:GetCallerIdentity requests. The request is
+// forwarded to AWS STS which verifies the signature and returns the IAM identity of the signer.
+type AuthenticatorAWSIAM struct {
+	c configuration.Provider
+
+	client *http.Client
+}
+
+func NewAuthenticatorAWSIAM(c configuration.Provider) *AuthenticatorAWSIAM {
+	var rt http.RoundTripper
+
+	return &AuthenticatorAWSIAM{c: c, client: httpx.NewResilientClientLatencyToleranceSmall(rt)}
+}
+
+func (a *AuthenticatorAWSIAM) GetID() string {
+	return "aws_iam"
+}
+
+func (a *AuthenticatorAWSIAM) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorAWSIAM) Config(config json.RawMessage) (*AuthenticatorAWSIAMConfiguration, error) {
+	var c AuthenticatorAWSIAMConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if len(c.STSEndpoints) == 0 {
+		c.STSEndpoints = []string{"https://sts.amazonaws.com"}
+	}
+
+	for k, endpoint := range c.STSEndpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf("STS endpoint %s is not a valid URL", endpoint))
+		}
+		c.STSEndpoints[k] = u.Scheme + "://" + u.Host
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorAWSIAM) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	if !strings.HasPrefix(token, awsIAMTokenPrefix) {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
+
+	u, err := a.presignedURL(cf, strings.TrimPrefix(token, awsIAMTokenPrefix))
+	if err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason(err.Error()).WithTrace(err))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if cf.Audience != "" {
+		req.Header.Set(awsIAMAudienceHeader, cf.Audience)
+	}
+
+	resp, err := a.client.Do(req.WithContext(r.Context()))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusBadRequest {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf("AWS STS rejected the presigned request with status code %d.", resp.StatusCode))
+	} else if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("AWS STS returned status code %d but expected %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var identity awsCallerIdentity
+	if err := x.DecodeJSONResponse(resp, a.c.RemoteResponseMaxBodySize(), &identity); err != nil {
+		return err
+	}
+
+	result := identity.GetCallerIdentityResponse.GetCallerIdentityResult
+	if result.Arn == "" {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("AWS STS did not return the identity of the caller."))
+	}
+
+	if len(cf.AllowedAccounts) > 0 && !stringslice.Has(cf.AllowedAccounts, result.Account) {
+		return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("AWS account %s is not allowed.", result.Account)))
+	}
+
+	if len(cf.AllowedARNs) > 0 && !matchesAnyPattern(cf.AllowedARNs, result.Arn) {
+		return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("AWS principal %s is not allowed.", result.Arn)))
+	}
+
+	session.Subject = result.Arn
+	session.Extra = map[string]interface{}{
+		"account": result.Account,
+		"arn":     result.Arn,
+		"user_id": result.UserID,
+	}
+
+	return nil
+}
+
+// presignedURL decodes the token and makes sure it is a presigned sts:GetCallerIdentity request for one of the
+// configured endpoints. Anything else must never be sent to AWS on behalf of the client.
+func (a *AuthenticatorAWSIAM) presignedURL(cf *AuthenticatorAWSIAMConfiguration, token string) (*url.URL, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(token, "="))
+	if err != nil {
+		return nil, errors.New("the token is not base64url encoded")
+	}
+
+	u, err := url.Parse(string(raw))
+	if err != nil {
+		return nil, errors.New("the token does not contain a valid URL")
+	}
+
+	if !stringslice.Has(cf.STSEndpoints, u.Scheme+"://"+u.Host) || (u.Path != "" && u.Path != "/") || u.User != nil || u.Fragment != "" {
+		return nil, errors.Errorf("the token is not presigned for a trusted STS endpoint")
+	}
+
+	q := u.Query()
+	if q.Get("Action") != "GetCallerIdentity" || q.Get("Version") != "2011-06-15" || len(q["Action"]) != 1 {
+		return nil, errors.New("the token is not a presigned sts:GetCallerIdentity request")
+	}
+
+	if q.Get("X-Amz-Signature") == "" || q.Get("X-Amz-Credential") == "" {
+		return nil, errors.New("the token is not a presigned request")
+	}
+
+	if expires, err := strconv.Atoi(q.Get("X-Amz-Expires")); err != nil || expires <= 0 || expires > awsIAMMaxExpires {
+		return nil, errors.Errorf("the presigned request must expire within %d seconds", awsIAMMaxExpires)
+	}
+
+	if cf.Audience != "" && !stringslice.Has(strings.Split(q.Get("X-Amz-SignedHeaders"), ";"), awsIAMAudienceHeader) {
+		return nil, errors.Errorf("the presigned request is not bound to an audience using the %s header", awsIAMAudienceHeader)
+	}
+
+	return u, nil
+}
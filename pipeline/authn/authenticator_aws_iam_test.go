@@ -0,0 +1,171 @@
+package authn_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorAWSIAM(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("aws_iam")
+	require.NoError(t, err)
+	assert.Equal(t, "aws_iam", a.GetID())
+
+	const arn = "arn:aws:sts::123456789012:assumed-role/worker/i-0123456789"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("X-Amz-Signature") != "valid" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("X-Amz-SignedHeaders") == "host;x-k8s-aws-id" && r.Header.Get("x-k8s-aws-id") != "my-cluster" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		_, _ = fmt.Fprintf(w, `{"GetCallerIdentityResponse":{"GetCallerIdentityResult":{"Account":"123456789012","Arn":"%s","UserId":"AROAEXAMPLE:i-0123456789"}}}`, arn)
+	}))
+	defer ts.Close()
+
+	presign := func(host, query string) string {
+		return "k8s-aws-v1." + base64.RawURLEncoding.EncodeToString([]byte(host+"/?"+query))
+	}
+	query := func(signature, signedHeaders string) string {
+		return "Action=GetCallerIdentity&Version=2011-06-15&X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKIAEXAMPLE%2F20200101%2Fus-east-1%2Fsts%2Faws4_request&X-Amz-Date=20200101T000000Z&X-Amz-Expires=60&X-Amz-SignedHeaders=" + url.QueryEscape(signedHeaders) + "&X-Amz-Signature=" + signature
+	}
+	bearer := func(token string) *http.Request {
+		return &http.Request{Header: http.Header{"Authorization": {"Bearer " + token}}}
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         string
+			expectErr      bool
+			expectExactErr error
+			expectCode     int
+		}{
+			{
+				d:              "should not be responsible for requests without token",
+				r:              &http.Request{Header: http.Header{}},
+				expectErr:      true,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:              "should not be responsible for other tokens",
+				r:              bearer("eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28ifQ."),
+				expectErr:      true,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d: "should pass",
+				r: bearer(presign(ts.URL, query("valid", "host"))),
+			},
+			{
+				d:      "should pass with an audience and allowed account and ARN",
+				r:      bearer(presign(ts.URL, query("valid", "host;x-k8s-aws-id"))),
+				config: `{"target_audience":"my-cluster","allowed_accounts":["123456789012"],"allowed_arns":["arn:aws:sts::123456789012:assumed-role/worker/*"]}`,
+			},
+			{
+				d:          "should fail because STS rejects the signature",
+				r:          bearer(presign(ts.URL, query("invalid", "host"))),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the request is presigned for an untrusted host",
+				r:          bearer(presign("https://attacker.example.com", query("valid", "host"))),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the request is not GetCallerIdentity",
+				r:          bearer(presign(ts.URL, "Action=AssumeRole&Version=2011-06-15&X-Amz-Credential=x&X-Amz-Expires=60&X-Amz-Signature=valid")),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the request is valid for too long",
+				r:          bearer(presign(ts.URL, "Action=GetCallerIdentity&Version=2011-06-15&X-Amz-Credential=x&X-Amz-Expires=3600&X-Amz-Signature=valid")),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the audience header is not signed",
+				r:          bearer(presign(ts.URL, query("valid", "host"))),
+				config:     `{"target_audience":"my-cluster"}`,
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the account is not allowed",
+				r:          bearer(presign(ts.URL, query("valid", "host"))),
+				config:     `{"allowed_accounts":["210987654321"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the ARN is not allowed",
+				r:          bearer(presign(ts.URL, query("valid", "host"))),
+				config:     `{"allowed_arns":["arn:aws:sts::123456789012:assumed-role/admin/*"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				config := tc.config
+				if config == "" {
+					config = "{}"
+				}
+				var raw map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(config), &raw))
+				raw["sts_endpoints"] = []string{ts.URL}
+				cfg, err := json.Marshal(raw)
+				require.NoError(t, err)
+
+				session := new(AuthenticationSession)
+				err = a.Authenticate(tc.r, session, cfg, nil)
+				if tc.expectErr {
+					require.Error(t, err)
+					if tc.expectExactErr != nil {
+						assert.EqualError(t, err, tc.expectExactErr.Error())
+					}
+					if tc.expectCode != 0 {
+						assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode(), "%+v", err)
+					}
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, arn, session.Subject)
+				assert.Equal(t, "123456789012", session.Extra["account"])
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorAWSIAMIsEnabled, true)
+		require.NoError(t, a.Validate(json.RawMessage(`{}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorAWSIAMIsEnabled, false)
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+	})
+}
@@ -0,0 +1,151 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/go-convenience/jwtx"
+	"github.com/ory/go-convenience/stringslice"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+)
+
+type AuthenticatorAzureManagedIdentityConfiguration struct {
+	TenantID            string                      `json:"tenant_id"`
+	Audience            []string                    `json:"target_audience"`
+	AllowedObjectIDs    []string                    `json:"allowed_object_ids"`
+	AllowedResourceIDs  []string                    `json:"allowed_resource_ids"`
+	JWKSURL             string                      `json:"jwks_url"`
+	BearerTokenLocation *helper.BearerTokenLocation `json:"token_from"`
+	MaxTokenAge         string                      `json:"max_token_age"`
+}
+
+// AuthenticatorAzureManagedIdentity authenticates Azure workloads using the access tokens Azure Active Directory
+// issues to their managed identities.
+type AuthenticatorAzureManagedIdentity struct {
+	c configuration.Provider
+	r AuthenticatorJWTRegistry
+}
+
+func NewAuthenticatorAzureManagedIdentity(c configuration.Provider, r AuthenticatorJWTRegistry) *AuthenticatorAzureManagedIdentity {
+	return &AuthenticatorAzureManagedIdentity{c: c, r: r}
+}
+
+func (a *AuthenticatorAzureManagedIdentity) GetID() string {
+	return "azure_managed_identity"
+}
+
+func (a *AuthenticatorAzureManagedIdentity) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+// Stage implements the pipeline.Stager interface by making sure that the JSON Web Key Set is reachable.
+func (a *AuthenticatorAzureManagedIdentity) Stage(ctx context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	jwksu, err := a.c.ParseURLs([]string{cf.JWKSURL})
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.r.CredentialsFetcher().ResolveSets(ctx, jwksu); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AuthenticatorAzureManagedIdentity) Config(config json.RawMessage) (*AuthenticatorAzureManagedIdentityConfiguration, error) {
+	var c AuthenticatorAzureManagedIdentityConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if c.TenantID == "" {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("a tenant id must be configured"))
+	}
+
+	if len(c.Audience) == 0 {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("at least one target audience must be configured"))
+	}
+
+	if c.JWKSURL == "" {
+		c.JWKSURL = fmt.Sprintf("https://login.microsoftonline.com/%s/discovery/v2.0/keys", c.TenantID)
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorAzureManagedIdentity) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	if token == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
+
+	// Azure Active Directory issues v1.0 or v2.0 access tokens depending on the application manifest of the
+	// target audience.
+	issuers := []string{
+		fmt.Sprintf("https://sts.windows.net/%s/", cf.TenantID),
+		fmt.Sprintf("https://login.microsoftonline.com/%s/v2.0", cf.TenantID),
+	}
+
+	claims, err := verifyCloudIdentityToken(r.Context(), a.c, a.r, token, cf.JWKSURL, issuers, cf.Audience)
+	if err != nil {
+		return err
+	}
+
+	if tid, _ := claims["tid"].(string); tid != cf.TenantID {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The access token was not issued for the configured tenant."))
+	}
+
+	oid, _ := claims["oid"].(string)
+	if oid == "" {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The access token does not identify an object."))
+	}
+
+	if len(cf.AllowedObjectIDs) > 0 && !stringslice.Has(cf.AllowedObjectIDs, oid) {
+		return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Managed identity %s is not allowed.", oid)))
+	}
+
+	if len(cf.AllowedResourceIDs) > 0 {
+		// Only tokens of managed identities carry the resource id of the workload they are assigned to.
+		resource, _ := claims["xms_mirid"].(string)
+		if !stringslice.Has(cf.AllowedResourceIDs, resource) {
+			return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Azure resource %s is not allowed.", resource)))
+		}
+	}
+
+	parsed := jwtx.ParseMapStringInterfaceClaims(claims)
+	if a.r.RevocationStore().IsRevoked(r.Context(), oid, "", parsed.IssuedAt) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The managed identity was revoked."))
+	}
+
+	if err := validateTokenAge(cf.MaxTokenAge, parsed.IssuedAt); err != nil {
+		return err
+	}
+
+	session.Subject = oid
+	session.Extra = claims
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package authn_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorAzureManagedIdentity(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("azure_managed_identity")
+	require.NoError(t, err)
+	assert.Equal(t, "azure_managed_identity", a.GetID())
+
+	const tenant = "72f988bf-86f1-41af-91ab-2d7cd011db47"
+	const resource = "/subscriptions/sub/resourcegroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/worker"
+	jwks := "file://../../test/stub/jwks-rsa-single.json"
+	now := time.Now().UTC()
+	bearer := func(overrides jwt.MapClaims) *http.Request {
+		claims := jwt.MapClaims{
+			"iss":       "https://sts.windows.net/" + tenant + "/",
+			"aud":       "api://protected-api",
+			"tid":       tenant,
+			"oid":       "object-id",
+			"sub":       "object-id",
+			"xms_mirid": resource,
+			"iat":       now.Unix(),
+			"exp":       now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			claims[k] = v
+		}
+		token, err := reg.CredentialsSigner().Sign(context.Background(), urlx.ParseOrPanic(jwks), claims)
+		require.NoError(t, err)
+		return &http.Request{Header: http.Header{"Authorization": {"Bearer " + token}}}
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         string
+			expectErr      bool
+			expectExactErr error
+			expectCode     int
+		}{
+			{
+				d:              "should not be responsible for requests without token",
+				r:              &http.Request{Header: http.Header{}},
+				expectErr:      true,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d: "should pass with a v1.0 token",
+				r: bearer(nil),
+			},
+			{
+				d:      "should pass with a v2.0 token of an allowed identity and resource",
+				r:      bearer(jwt.MapClaims{"iss": "https://login.microsoftonline.com/" + tenant + "/v2.0"}),
+				config: `{"allowed_object_ids":["object-id"],"allowed_resource_ids":["` + resource + `"]}`,
+			},
+			{
+				d:          "should fail because the token was issued by another tenant",
+				r:          bearer(jwt.MapClaims{"iss": "https://sts.windows.net/other-tenant/", "tid": "other-tenant"}),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the tenant claim does not match",
+				r:          bearer(jwt.MapClaims{"tid": "other-tenant"}),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the audience does not match",
+				r:          bearer(jwt.MapClaims{"aud": "api://other-api"}),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the object is not allowed",
+				r:          bearer(nil),
+				config:     `{"allowed_object_ids":["other-object-id"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the token does not belong to a managed identity",
+				r:          bearer(jwt.MapClaims{"xms_mirid": nil}),
+				config:     `{"allowed_resource_ids":["` + resource + `"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				config := tc.config
+				if config == "" {
+					config = "{}"
+				}
+				var raw map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(config), &raw))
+				raw["jwks_url"] = jwks
+				raw["tenant_id"] = tenant
+				raw["target_audience"] = []string{"api://protected-api"}
+				cfg, err := json.Marshal(raw)
+				require.NoError(t, err)
+
+				session := new(AuthenticationSession)
+				err = a.Authenticate(tc.r, session, cfg, nil)
+				if tc.expectErr {
+					require.Error(t, err)
+					if tc.expectExactErr != nil {
+						assert.EqualError(t, err, tc.expectExactErr.Error())
+					}
+					if tc.expectCode != 0 {
+						assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode(), "%+v", err)
+					}
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, "object-id", session.Subject)
+				assert.Equal(t, tenant, session.Extra["tid"])
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorAzureManagedIdentityIsEnabled, true)
+		require.NoError(t, a.Validate(json.RawMessage(`{"tenant_id":"`+tenant+`","target_audience":["api://protected-api"]}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"target_audience":["api://protected-api"]}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorAzureManagedIdentityIsEnabled, false)
+		require.Error(t, a.Validate(json.RawMessage(`{"tenant_id":"`+tenant+`","target_audience":["api://protected-api"]}`)))
+	})
+}
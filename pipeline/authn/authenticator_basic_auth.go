@@ -0,0 +1,285 @@
+package authn
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+)
+
+type AuthenticatorBasicAuthConfiguration struct {
+	// HtpasswdFile is a file containing one "username:hash" entry per line.
+	HtpasswdFile string `json:"htpasswd_file"`
+
+	// Credentials are "username:hash" entries which take precedence over the entries of the file.
+	Credentials []string `json:"credentials"`
+}
+
+// basicAuthDummyHash is a bcrypt hash with the default cost. Passwords of unknown users are compared with it so that
+// the response time does not reveal which users exist.
+const basicAuthDummyHash = "$2a$10$jN3jbff9q58398nlil2bCeJewedLGwk4fSsUpZpYoxvHUxzx.UsC6"
+
+type htpasswdFile struct {
+	modTime time.Time
+	hashes  map[string]string
+}
+
+// AuthenticatorBasicAuth authenticates requests using HTTP Basic credentials which are checked against bcrypt or
+// Argon2 hashes.
+type AuthenticatorBasicAuth struct {
+	c configuration.Provider
+
+	sync.Mutex
+	files       map[string]*htpasswdFile
+	credentials map[string]map[string]string
+}
+
+func NewAuthenticatorBasicAuth(c configuration.Provider) *AuthenticatorBasicAuth {
+	return &AuthenticatorBasicAuth{c: c, files: map[string]*htpasswdFile{}, credentials: map[string]map[string]string{}}
+}
+
+func (a *AuthenticatorBasicAuth) GetID() string {
+	return "basic_auth"
+}
+
+func (a *AuthenticatorBasicAuth) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorBasicAuth) Config(config json.RawMessage) (*AuthenticatorBasicAuthConfiguration, error) {
+	var c AuthenticatorBasicAuthConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if c.HtpasswdFile == "" && len(c.Credentials) == 0 {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("either htpasswd_file or credentials must be set"))
+	}
+
+	if _, err := a.inline(c.Credentials); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorBasicAuth) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 6 || !strings.EqualFold(auth[:6], "basic ") {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The Basic credentials are malformed."))
+	}
+
+	hash, err := a.hash(cf, username)
+	if err != nil {
+		return err
+	} else if hash == "" {
+		_ = verifyPasswordHash(basicAuthDummyHash, password)
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The username or password is invalid."))
+	}
+
+	if err := verifyPasswordHash(hash, password); err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The username or password is invalid.").WithTrace(err))
+	}
+
+	session.Subject = username
+	return nil
+}
+
+// hash returns the hash of the user's password or an empty string if the user is unknown.
+func (a *AuthenticatorBasicAuth) hash(cf *AuthenticatorBasicAuthConfiguration, username string) (string, error) {
+	inline, err := a.inline(cf.Credentials)
+	if err != nil {
+		return "", err
+	}
+	if hash, ok := inline[username]; ok {
+		return hash, nil
+	}
+
+	if cf.HtpasswdFile == "" {
+		return "", nil
+	}
+
+	hashes, err := a.file(cf.HtpasswdFile)
+	if err != nil {
+		return "", err
+	}
+	return hashes[username], nil
+}
+
+// inline returns the entries of the inline credentials. They are parsed once per configuration.
+func (a *AuthenticatorBasicAuth) inline(credentials []string) (map[string]string, error) {
+	key := strings.Join(credentials, "\n")
+
+	a.Lock()
+	defer a.Unlock()
+
+	if hashes, ok := a.credentials[key]; ok {
+		return hashes, nil
+	}
+
+	hashes, err := parseHtpasswd([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials[key] = hashes
+	return hashes, nil
+}
+
+// file returns the entries of the htpasswd file. The file is parsed again once it was modified.
+func (a *AuthenticatorBasicAuth) file(path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if f, ok := a.files[path]; ok && f.modTime.Equal(info.ModTime()) {
+		return f.hashes, nil
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	hashes, err := parseHtpasswd(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse htpasswd file %s", path)
+	}
+
+	a.files[path] = &htpasswdFile{modTime: info.ModTime(), hashes: hashes}
+	return hashes, nil
+}
+
+// parseHtpasswd parses "username:hash" entries, one per line. Empty lines and lines starting with "#" are ignored.
+func parseHtpasswd(raw []byte) (map[string]string, error) {
+	hashes := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf(`line %d must be formatted as "username:hash"`, line)
+		}
+		if strings.HasPrefix(parts[1], "$argon2") {
+			if _, err := parseArgon2Hash(parts[1]); err != nil {
+				return nil, errors.Wrapf(err, "the hash of user %s is invalid", parts[0])
+			}
+		} else if !strings.HasPrefix(parts[1], "$2") {
+			return nil, errors.Errorf("the hash of user %s must be a bcrypt or Argon2 hash", parts[0])
+		}
+		hashes[parts[0]] = parts[1]
+	}
+	return hashes, errors.WithStack(scanner.Err())
+}
+
+// argon2Hash is an Argon2 hash in the PHC string format, for example "$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>".
+type argon2Hash struct {
+	variant     string
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// parseArgon2Hash parses an Argon2 hash. Hashes whose parameters would make the key derivation fail are rejected.
+func parseArgon2Hash(hash string) (*argon2Hash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, errors.New("the Argon2 hash is malformed")
+	}
+
+	h := &argon2Hash{variant: parts[1]}
+	if h.variant != "argon2id" && h.variant != "argon2i" {
+		return nil, errors.Errorf("the Argon2 variant %s is not supported", h.variant)
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errors.Errorf("the Argon2 version %s is not supported", parts[2])
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.iterations, &h.parallelism); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if h.iterations < 1 || h.parallelism < 1 {
+		return nil, errors.Errorf("the Argon2 parameters %s must use at least one iteration and one thread", parts[3])
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(h.salt) == 0 || len(h.key) == 0 {
+		return nil, errors.New("the salt and the hash of the Argon2 hash must not be empty")
+	}
+
+	return h, nil
+}
+
+// verifyPasswordHash compares the password with a bcrypt hash or an Argon2 hash in the PHC string format.
+func verifyPasswordHash(hash, password string) error {
+	if strings.HasPrefix(hash, "$2") {
+		return errors.WithStack(bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)))
+	}
+
+	h, err := parseArgon2Hash(hash)
+	if err != nil {
+		return err
+	}
+
+	var actual []byte
+	switch h.variant {
+	case "argon2id":
+		actual = argon2.IDKey([]byte(password), h.salt, h.iterations, h.memory, h.parallelism, uint32(len(h.key)))
+	case "argon2i":
+		actual = argon2.Key([]byte(password), h.salt, h.iterations, h.memory, h.parallelism, uint32(len(h.key)))
+	}
+
+	if subtle.ConstantTimeCompare(actual, h.key) != 1 {
+		return errors.New("the password does not match the hash")
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package authn_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorBasicAuth(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("basic_auth")
+	require.NoError(t, err)
+	assert.Equal(t, "basic_auth", a.GetID())
+
+	bcryptHash := func(password string) string {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		require.NoError(t, err)
+		return string(hash)
+	}
+	salt := []byte("0123456789abcdef")
+	argon2Hash := "$argon2id$v=19$m=1024,t=1,p=1$" + base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("argon-secret"), salt, 1, 1024, 1, 32))
+
+	htpasswd, err := ioutil.TempFile("", "htpasswd-*")
+	require.NoError(t, err)
+	defer os.Remove(htpasswd.Name())
+	_, err = htpasswd.WriteString("# users\nalice:" + bcryptHash("alice-secret") + "\ncarol:" + argon2Hash + "\n")
+	require.NoError(t, err)
+	require.NoError(t, htpasswd.Close())
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"htpasswd_file": htpasswd.Name(),
+		"credentials":   []string{"bob:" + bcryptHash("bob-secret")},
+	})
+	request := func(username, password string) *http.Request {
+		r := &http.Request{Header: http.Header{}}
+		r.SetBasicAuth(username, password)
+		return r
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+		}{
+			{
+				d:              "should not be responsible without credentials",
+				r:              &http.Request{Header: http.Header{}},
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:              "should not be responsible for bearer tokens",
+				r:              &http.Request{Header: http.Header{"Authorization": {"Bearer token"}}},
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass with a bcrypt hash of the file",
+				r:             request("alice", "alice-secret"),
+				expectSubject: "alice",
+			},
+			{
+				d:             "should pass with an Argon2 hash of the file",
+				r:             request("carol", "argon-secret"),
+				expectSubject: "carol",
+			},
+			{
+				d:             "should pass with inline credentials",
+				r:             request("bob", "bob-secret"),
+				expectSubject: "bob",
+			},
+			{
+				d:          "should fail with a wrong bcrypt password",
+				r:          request("alice", "bob-secret"),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with a wrong Argon2 password",
+				r:          request("carol", "alice-secret"),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an unknown user",
+				r:          request("mallory", "alice-secret"),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with malformed credentials",
+				r:          &http.Request{Header: http.Header{"Authorization": {"Basic not-base64"}}},
+				expectCode: http.StatusUnauthorized,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+			})
+		}
+	})
+
+	t.Run("method=authenticate/case=the file is read again once it changes", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(htpasswd.Name(), []byte("dave:"+bcryptHash("dave-secret")+"\n"), 0600))
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(htpasswd.Name(), later, later))
+
+		require.NoError(t, a.Authenticate(request("dave", "dave-secret"), new(AuthenticationSession), config, nil))
+		require.Error(t, a.Authenticate(request("alice", "alice-secret"), new(AuthenticationSession), config, nil))
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorBasicAuthIsEnabled, true)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"credentials":["alice:plaintext"]}`)))
+		for _, hash := range []string{
+			"$argon2id$v=19$m=1024,t=0,p=1$MDEyMzQ1Njc4OWFiY2RlZg$aGFzaA",
+			"$argon2id$v=19$m=1024,t=1,p=0$MDEyMzQ1Njc4OWFiY2RlZg$aGFzaA",
+			"$argon2id$v=19$m=1024,t=1,p=1$$aGFzaA",
+			"$argon2id$v=19$m=1024,t=1,p=1$MDEyMzQ1Njc4OWFiY2RlZg$",
+		} {
+			require.Error(t, a.Validate(json.RawMessage(`{"credentials":["alice:`+hash+`"]}`)), hash)
+		}
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorBasicAuthIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
@@ -0,0 +1,165 @@
+package authn
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/biscuit"
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+)
+
+type AuthenticatorBiscuitRegistry interface {
+	credentials.FetcherRegistry
+}
+
+type AuthenticatorBiscuitConfiguration struct {
+	JWKSURLs            []string                    `json:"jwks_urls"`
+	SubjectFact         string                      `json:"subject_fact"`
+	BearerTokenLocation *helper.BearerTokenLocation `json:"token_from"`
+}
+
+type AuthenticatorBiscuit struct {
+	c configuration.Provider
+	r AuthenticatorBiscuitRegistry
+}
+
+func NewAuthenticatorBiscuit(
+	c configuration.Provider,
+	r AuthenticatorBiscuitRegistry,
+) *AuthenticatorBiscuit {
+	return &AuthenticatorBiscuit{
+		c: c,
+		r: r,
+	}
+}
+
+func (a *AuthenticatorBiscuit) GetID() string {
+	return "biscuit"
+}
+
+func (a *AuthenticatorBiscuit) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	if _, err := a.Config(config); err != nil {
+		return err
+	}
+
+	if a.c.FIPSIsEnabled() {
+		return NewErrAuthenticatorMisconfigured(a, errors.New("Biscuit tokens are signed using Ed25519 which is not approved by the FIPS policy"))
+	}
+
+	return nil
+}
+
+// Stage implements the pipeline.Stager interface by making sure that all JSON Web Key Sets are reachable.
+func (a *AuthenticatorBiscuit) Stage(ctx context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	jwksu, err := a.c.ParseURLs(cf.JWKSURLs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.r.CredentialsFetcher().ResolveSets(ctx, jwksu); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AuthenticatorBiscuit) Config(config json.RawMessage) (*AuthenticatorBiscuitConfiguration, error) {
+	var c AuthenticatorBiscuitConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if c.SubjectFact == "" {
+		c.SubjectFact = "user"
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorBiscuit) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	if token == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
+
+	jwksu, err := a.c.ParseURLs(cf.JWKSURLs)
+	if err != nil {
+		return err
+	}
+
+	sets, err := a.r.CredentialsFetcher().ResolveSets(r.Context(), jwksu)
+	if err != nil {
+		return err
+	}
+
+	b, err := biscuit.Parse(token, credentials.Ed25519PublicKeys(sets, ""))
+	if errors.Cause(err) == biscuit.ErrMalformed {
+		// This allows chaining the biscuit authenticator with other bearer token authenticators.
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	} else if err != nil {
+		return helper.ErrUnauthorized.WithReason(err.Error()).WithTrace(err)
+	}
+
+	if err := b.Authorize(biscuitRequestFacts(r)); err != nil {
+		return helper.ErrForbidden.WithReason(err.Error()).WithTrace(err)
+	}
+
+	subject, facts := "", make([]string, 0, len(b.AuthorityFacts()))
+	for _, f := range b.AuthorityFacts() {
+		facts = append(facts, f.String())
+		if subject == "" && f.Name == cf.SubjectFact && len(f.Terms) == 1 && f.Terms[0].Kind == biscuit.TermString {
+			subject = f.Terms[0].Str
+		}
+	}
+
+	if subject == "" {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The authority block of the Biscuit does not contain a "%s" fact.`, cf.SubjectFact))
+	}
+
+	revocationIDs := make([]string, len(b.RevocationIDs))
+	for i, id := range b.RevocationIDs {
+		revocationIDs[i] = hex.EncodeToString(id)
+	}
+
+	session.Subject = subject
+	session.Extra = map[string]interface{}{
+		"facts":          facts,
+		"revocation_ids": revocationIDs,
+	}
+
+	return nil
+}
+
+// biscuitRequestFacts returns the facts describing the request which the checks of the Biscuit are evaluated
+// against.
+func biscuitRequestFacts(r *http.Request) []biscuit.Predicate {
+	return []biscuit.Predicate{
+		{Name: "method", Terms: []biscuit.Term{biscuit.String(r.Method)}},
+		{Name: "path", Terms: []biscuit.Term{biscuit.String(r.URL.Path)}},
+		{Name: "host", Terms: []biscuit.Term{biscuit.String(r.Host)}},
+		{Name: "time", Terms: []biscuit.Term{biscuit.Date(time.Now())}},
+	}
+}
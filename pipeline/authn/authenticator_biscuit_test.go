@@ -0,0 +1,160 @@
+package authn_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/biscuit"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/paseto"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorBiscuit(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("biscuit")
+	require.NoError(t, err)
+	assert.Equal(t, "biscuit", a.GetID())
+
+	sets, err := reg.CredentialsFetcher().ResolveSets(context.Background(), []url.URL{*urlx.ParseOrPanic("file://../../test/stub/jwks-ed25519.json")})
+	require.NoError(t, err)
+	root, _, err := paseto.PrivateKey(sets)
+	require.NoError(t, err)
+
+	fact := func(name string, values ...string) biscuit.Predicate {
+		p := biscuit.Predicate{Name: name}
+		for _, v := range values {
+			p.Terms = append(p.Terms, biscuit.String(v))
+		}
+		return p
+	}
+	checkIf := func(body []biscuit.Predicate, expressions ...biscuit.Expression) biscuit.Check {
+		return biscuit.Check{Queries: []biscuit.Rule{{Head: fact("query"), Body: body, Expressions: expressions}}}
+	}
+	prefix := func(variable, value string) biscuit.Expression {
+		return biscuit.Expression{biscuit.Value(biscuit.Variable(variable)), biscuit.Value(biscuit.String(value)), biscuit.BinaryOp(biscuit.BinaryPrefix)}
+	}
+
+	token, err := biscuit.Mint(root, &biscuit.Block{Facts: []biscuit.Predicate{fact("user", "alice"), fact("role", "admin")}})
+	require.NoError(t, err)
+
+	readOnly, err := biscuit.Attenuate(token, &biscuit.Block{Checks: []biscuit.Check{
+		checkIf([]biscuit.Predicate{fact("method", "GET")}),
+		checkIf([]biscuit.Predicate{{Name: "path", Terms: []biscuit.Term{biscuit.Variable("p")}}}, prefix("p", "/articles/")),
+	}})
+	require.NoError(t, err)
+
+	expired, err := biscuit.Attenuate(token, &biscuit.Block{Checks: []biscuit.Check{
+		checkIf([]biscuit.Predicate{{Name: "time", Terms: []biscuit.Term{biscuit.Variable("t")}}}, biscuit.Expression{
+			biscuit.Value(biscuit.Variable("t")), biscuit.Value(biscuit.Date(time.Now().Add(-time.Minute))), biscuit.BinaryOp(biscuit.BinaryLessThan),
+		}),
+	}})
+	require.NoError(t, err)
+
+	withoutSubject, err := biscuit.Mint(root, &biscuit.Block{Facts: []biscuit.Predicate{fact("role", "admin")}})
+	require.NoError(t, err)
+
+	request := func(method, path, token string) *http.Request {
+		return &http.Request{Method: method, URL: &url.URL{Path: path}, Header: http.Header{"Authorization": {"Bearer " + token}}}
+	}
+
+	config := json.RawMessage(`{"jwks_urls":["file://../../test/stub/jwks-ed25519.json"]}`)
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+		}{
+			{
+				d:              "should not be responsible without token",
+				r:              &http.Request{Header: http.Header{}},
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:              "should not be responsible for other tokens",
+				r:              request("GET", "/", "eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28ifQ."),
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass with the authority block",
+				r:             request("DELETE", "/users/bob", token),
+				expectSubject: "alice",
+			},
+			{
+				d:             "should pass with satisfied attenuation checks",
+				r:             request("GET", "/articles/1", readOnly),
+				expectSubject: "alice",
+			},
+			{
+				d:          "should fail because the method is restricted",
+				r:          request("POST", "/articles/1", readOnly),
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the path is restricted",
+				r:          request("GET", "/users/bob", readOnly),
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the token is expired",
+				r:          request("GET", "/", expired),
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail without subject",
+				r:          request("GET", "/", withoutSubject),
+				expectCode: http.StatusUnauthorized,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				assert.Equal(t, []string{`user("alice")`, `role("admin")`}, session.Extra["facts"])
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorBiscuitIsEnabled, true)
+		defer viper.Set(configuration.ViperKeyFIPSIsEnabled, false)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+
+		viper.Set(configuration.ViperKeyFIPSIsEnabled, true)
+		require.Error(t, a.Validate(config))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorBiscuitIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
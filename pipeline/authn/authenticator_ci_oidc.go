@@ -0,0 +1,195 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
+	"github.com/ory/go-convenience/jwtx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+)
+
+const (
+	CIOIDCProviderGitHub = "github"
+	CIOIDCProviderGitLab = "gitlab"
+)
+
+type AuthenticatorCIOIDCConfiguration struct {
+	Provider            string                      `json:"provider"`
+	Issuer              string                      `json:"issuer"`
+	JWKSURL             string                      `json:"jwks_url"`
+	Audience            []string                    `json:"target_audience"`
+	Repositories        []string                    `json:"repositories"`
+	Refs                []string                    `json:"refs"`
+	Environments        []string                    `json:"environments"`
+	RequiredClaims      map[string]string           `json:"required_claims"`
+	BearerTokenLocation *helper.BearerTokenLocation `json:"token_from"`
+}
+
+// ciOIDCProfile describes where a CI provider publishes its keys and which claims identify the repository and
+// the git reference of a job.
+type ciOIDCProfile struct {
+	issuer     string
+	jwksPath   string
+	repository string
+	ref        func(claims jwt.MapClaims) string
+}
+
+var ciOIDCProfiles = map[string]ciOIDCProfile{
+	CIOIDCProviderGitHub: {
+		issuer:     "https://token.actions.githubusercontent.com",
+		jwksPath:   "/.well-known/jwks",
+		repository: "repository",
+		ref: func(claims jwt.MapClaims) string {
+			ref, _ := claims["ref"].(string)
+			return ref
+		},
+	},
+	CIOIDCProviderGitLab: {
+		issuer:     "https://gitlab.com",
+		jwksPath:   "/oauth/discovery/keys",
+		repository: "project_path",
+		// GitLab only includes the name of the reference, it is qualified so rules look alike for all providers.
+		ref: func(claims jwt.MapClaims) string {
+			ref, _ := claims["ref"].(string)
+			switch refType, _ := claims["ref_type"].(string); refType {
+			case "branch":
+				return "refs/heads/" + ref
+			case "tag":
+				return "refs/tags/" + ref
+			}
+			return ref
+		},
+	},
+}
+
+// AuthenticatorCIOIDC authenticates CI jobs using the OpenID Connect ID tokens issued by GitHub Actions and GitLab
+// CI, making sure the job runs for an allowed repository and git reference.
+type AuthenticatorCIOIDC struct {
+	c configuration.Provider
+	r AuthenticatorJWTRegistry
+}
+
+func NewAuthenticatorCIOIDC(c configuration.Provider, r AuthenticatorJWTRegistry) *AuthenticatorCIOIDC {
+	return &AuthenticatorCIOIDC{c: c, r: r}
+}
+
+func (a *AuthenticatorCIOIDC) GetID() string {
+	return "ci_oidc"
+}
+
+func (a *AuthenticatorCIOIDC) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+// Stage implements the pipeline.Stager interface by making sure that the JSON Web Key Set is reachable.
+func (a *AuthenticatorCIOIDC) Stage(ctx context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	jwksu, err := a.c.ParseURLs([]string{cf.JWKSURL})
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.r.CredentialsFetcher().ResolveSets(ctx, jwksu); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AuthenticatorCIOIDC) Config(config json.RawMessage) (*AuthenticatorCIOIDCConfiguration, error) {
+	var c AuthenticatorCIOIDCConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	profile, ok := ciOIDCProfiles[c.Provider]
+	if !ok {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf("provider %s is not supported", c.Provider))
+	}
+
+	if len(c.Audience) == 0 {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("at least one target audience must be configured"))
+	}
+
+	// Repositories must be restricted explicitly, otherwise every repository hosted by the provider would be able
+	// to obtain a valid token.
+	if len(c.Repositories) == 0 {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("at least one repository must be configured"))
+	}
+
+	if c.Issuer == "" {
+		c.Issuer = profile.issuer
+	}
+
+	if c.JWKSURL == "" {
+		c.JWKSURL = strings.TrimRight(c.Issuer, "/") + profile.jwksPath
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorCIOIDC) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	if token == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
+
+	claims, err := verifyCloudIdentityToken(r.Context(), a.c, a.r, token, cf.JWKSURL, []string{cf.Issuer}, cf.Audience)
+	if err != nil {
+		return err
+	}
+
+	profile := ciOIDCProfiles[cf.Provider]
+	repository, _ := claims[profile.repository].(string)
+	if !matchesAnyPattern(cf.Repositories, repository) {
+		return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Repository %s is not allowed.", repository)))
+	}
+
+	if ref := profile.ref(claims); len(cf.Refs) > 0 && !matchesAnyPattern(cf.Refs, ref) {
+		return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Git reference %s is not allowed.", ref)))
+	}
+
+	if environment, _ := claims["environment"].(string); len(cf.Environments) > 0 && !matchesAnyPattern(cf.Environments, environment) {
+		return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Environment %s is not allowed.", environment)))
+	}
+
+	for claim, pattern := range cf.RequiredClaims {
+		if value, _ := claims[claim].(string); !matchesAnyPattern([]string{pattern}, value) {
+			return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Claim %s does not match %s.", claim, pattern)))
+		}
+	}
+
+	parsed := jwtx.ParseMapStringInterfaceClaims(claims)
+	if a.r.RevocationStore().IsRevoked(r.Context(), parsed.Subject, "", parsed.IssuedAt) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The session of this JSON Web Token was revoked."))
+	}
+
+	session.Subject = parsed.Subject
+	session.Extra = claims
+
+	return nil
+}
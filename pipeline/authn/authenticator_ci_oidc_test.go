@@ -0,0 +1,180 @@
+package authn_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorCIOIDC(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("ci_oidc")
+	require.NoError(t, err)
+	assert.Equal(t, "ci_oidc", a.GetID())
+
+	jwks := "file://../../test/stub/jwks-rsa-single.json"
+	now := time.Now().UTC()
+	bearer := func(claims jwt.MapClaims) *http.Request {
+		claims["aud"] = "https://deploy.example.com"
+		claims["iat"] = now.Unix()
+		claims["exp"] = now.Add(5 * time.Minute).Unix()
+		token, err := reg.CredentialsSigner().Sign(context.Background(), urlx.ParseOrPanic(jwks), claims)
+		require.NoError(t, err)
+		return &http.Request{Header: http.Header{"Authorization": {"Bearer " + token}}}
+	}
+	github := func(overrides jwt.MapClaims) *http.Request {
+		claims := jwt.MapClaims{
+			"iss":         "https://token.actions.githubusercontent.com",
+			"sub":         "repo:acme/shop:environment:production",
+			"repository":  "acme/shop",
+			"ref":         "refs/heads/main",
+			"environment": "production",
+			"workflow":    "deploy",
+		}
+		for k, v := range overrides {
+			claims[k] = v
+		}
+		return bearer(claims)
+	}
+	gitlab := func(overrides jwt.MapClaims) *http.Request {
+		claims := jwt.MapClaims{
+			"iss":          "https://gitlab.com",
+			"sub":          "project_path:acme/shop:ref_type:tag:ref:v1.2.3",
+			"project_path": "acme/shop",
+			"ref":          "v1.2.3",
+			"ref_type":     "tag",
+		}
+		for k, v := range overrides {
+			claims[k] = v
+		}
+		return bearer(claims)
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         string
+			expectErr      bool
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+		}{
+			{
+				d:              "should not be responsible for requests without token",
+				r:              &http.Request{Header: http.Header{}},
+				config:         `{"provider":"github","repositories":["acme/shop"]}`,
+				expectErr:      true,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass with a github token",
+				r:             github(nil),
+				config:        `{"provider":"github","repositories":["acme/*"],"refs":["refs/heads/main"],"environments":["production"],"required_claims":{"workflow":"deploy"}}`,
+				expectSubject: "repo:acme/shop:environment:production",
+			},
+			{
+				d:             "should pass with a gitlab token and a qualified tag",
+				r:             gitlab(nil),
+				config:        `{"provider":"gitlab","repositories":["acme/shop"],"refs":["refs/tags/v*"]}`,
+				expectSubject: "project_path:acme/shop:ref_type:tag:ref:v1.2.3",
+			},
+			{
+				d:          "should fail because the token was issued by another provider",
+				r:          gitlab(nil),
+				config:     `{"provider":"github","repositories":["acme/shop"]}`,
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the repository is not allowed",
+				r:          github(jwt.MapClaims{"repository": "evil/shop"}),
+				config:     `{"provider":"github","repositories":["acme/shop"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the ref is not allowed",
+				r:          github(jwt.MapClaims{"ref": "refs/heads/feature"}),
+				config:     `{"provider":"github","repositories":["acme/shop"],"refs":["refs/heads/main"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because a gitlab branch does not match a tag pattern",
+				r:          gitlab(jwt.MapClaims{"ref": "v1", "ref_type": "branch"}),
+				config:     `{"provider":"gitlab","repositories":["acme/shop"],"refs":["refs/tags/v*"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the environment is not allowed",
+				r:          github(jwt.MapClaims{"environment": "staging"}),
+				config:     `{"provider":"github","repositories":["acme/shop"],"environments":["production"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because a required claim does not match",
+				r:          github(jwt.MapClaims{"workflow": "test"}),
+				config:     `{"provider":"github","repositories":["acme/shop"],"required_claims":{"workflow":"deploy"}}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				var raw map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(tc.config), &raw))
+				raw["jwks_url"] = jwks
+				raw["target_audience"] = []string{"https://deploy.example.com"}
+				cfg, err := json.Marshal(raw)
+				require.NoError(t, err)
+
+				session := new(AuthenticationSession)
+				err = a.Authenticate(tc.r, session, cfg, nil)
+				if tc.expectErr {
+					require.Error(t, err)
+					if tc.expectExactErr != nil {
+						assert.EqualError(t, err, tc.expectExactErr.Error())
+					}
+					if tc.expectCode != 0 {
+						assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode(), "%+v", err)
+					}
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				assert.Equal(t, "acme/shop", session.Extra[map[string]string{"github": "repository", "gitlab": "project_path"}[raw["provider"].(string)]])
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorCIOIDCIsEnabled, true)
+		require.NoError(t, a.Validate(json.RawMessage(`{"provider":"github","target_audience":["api"],"repositories":["acme/shop"]}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"provider":"github","target_audience":["api"]}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"provider":"jenkins","target_audience":["api"],"repositories":["acme/shop"]}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorCIOIDCIsEnabled, false)
+		require.Error(t, a.Validate(json.RawMessage(`{"provider":"github","target_audience":["api"],"repositories":["acme/shop"]}`)))
+	})
+}
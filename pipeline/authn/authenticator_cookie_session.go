@@ -2,13 +2,14 @@ package authn
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 
+	"github.com/ory/go-convenience/stringslice"
 	"github.com/ory/go-convenience/stringsx"
 
 	"github.com/ory/herodot"
@@ -16,6 +17,7 @@ import (
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/helper"
 	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/x"
 )
 
 func init() {
@@ -33,6 +35,9 @@ type AuthenticatorCookieSessionConfiguration struct {
 	PreservePath    bool     `json:"preserve_path"`
 	ExtraFrom       string   `json:"extra_from"`
 	SubjectFrom     string   `json:"subject_from"`
+
+	// TLS configures how the certificate of the session store is verified.
+	TLS *x.TLSClientConfig `json:"tls"`
 }
 
 type AuthenticatorCookieSession struct {
@@ -85,7 +90,7 @@ func (a *AuthenticatorCookieSession) Authenticate(r *http.Request, session *Auth
 		return errors.WithStack(ErrAuthenticatorNotResponsible)
 	}
 
-	body, err := forwardRequestToSessionStore(r, cf.CheckSessionURL, cf.PreservePath)
+	body, err := forwardRequestToSessionStore(r, cf.CheckSessionURL, cf.PreservePath, cf.TLS, a.c.RemoteResponseTimeout(), a.c.RemoteResponseMaxBodySize())
 	if err != nil {
 		return err
 	}
@@ -108,9 +113,22 @@ func (a *AuthenticatorCookieSession) Authenticate(r *http.Request, session *Auth
 
 	session.Subject = subject
 	session.Extra = extra
+	session.ConsumeCookies(sessionCookies(r, cf.Only)...)
 	return nil
 }
 
+// sessionCookies returns the names of the session cookies of the request, the cookies listed in only or all cookies
+// if only is empty.
+func sessionCookies(r *http.Request, only []string) []string {
+	var names []string
+	for _, c := range r.Cookies() {
+		if len(only) == 0 || stringslice.Has(only, c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
 func cookieSessionResponsible(r *http.Request, only []string) bool {
 	if len(only) == 0 {
 		return true
@@ -123,7 +141,7 @@ func cookieSessionResponsible(r *http.Request, only []string) bool {
 	return false
 }
 
-func forwardRequestToSessionStore(r *http.Request, checkSessionURL string, preservePath bool) (json.RawMessage, error) {
+func forwardRequestToSessionStore(r *http.Request, checkSessionURL string, preservePath bool, tlsConfig *x.TLSClientConfig, timeout time.Duration, maxBodySize int64) (json.RawMessage, error) {
 	reqUrl, err := url.Parse(checkSessionURL)
 	if err != nil {
 		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to parse session check URL: %s", err))
@@ -133,17 +151,26 @@ func forwardRequestToSessionStore(r *http.Request, checkSessionURL string, prese
 		reqUrl.Path = r.URL.Path
 	}
 
-	res, err := http.DefaultClient.Do(&http.Request{
+	transport, err := x.TLSTransport(tlsConfig)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to configure TLS for the session check URL: %s", err))
+	}
+
+	ctx, cancel := x.WithOptionalTimeout(r.Context(), timeout)
+	defer cancel()
+
+	res, err := (&http.Client{Transport: transport}).Do((&http.Request{
 		Method: r.Method,
 		URL:    reqUrl,
 		Header: r.Header,
-	})
+	}).WithContext(ctx))
 	if err != nil {
 		return nil, helper.ErrForbidden.WithReason(err.Error()).WithTrace(err)
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode == 200 {
-		body, err := ioutil.ReadAll(res.Body)
+		body, err := x.ReadResponse(res, maxBodySize)
 		if err != nil {
 			return json.RawMessage{}, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to fetch cookie session context from remote: %+v", err))
 		}
@@ -16,6 +16,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/internal"
 	. "github.com/ory/oathkeeper/pipeline/authn"
 )
@@ -41,6 +44,7 @@ func TestAuthenticatorCookieSession(t *testing.T) {
 		})
 
 		t.Run("description=should pass because session store returned 200", func(t *testing.T) {
+			session := new(AuthenticationSession)
 			testServer, _ := makeServer(200, `{"subject": "123", "extra": {"foo": "bar"}}`)
 			err := pipelineAuthenticator.Authenticate(
 				makeRequest("GET", "/", map[string]string{"sessionid": "zyx"}, ""),
@@ -50,12 +54,14 @@ func TestAuthenticatorCookieSession(t *testing.T) {
 			)
 			require.NoError(t, err, "%#v", errors.Cause(err))
 			assert.Equal(t, &AuthenticationSession{
-				Subject: "123",
-				Extra:   map[string]interface{}{"foo": "bar"},
+				Subject:         "123",
+				Extra:           map[string]interface{}{"foo": "bar"},
+				ConsumedCookies: []string{"sessionid"},
 			}, session)
 		})
 
 		t.Run("description=should pass through method, path, and headers to auth server", func(t *testing.T) {
+			session := new(AuthenticationSession)
 			testServer, requestRecorder := makeServer(200, `{"subject": "123"}`)
 			err := pipelineAuthenticator.Authenticate(
 				makeRequest("PUT", "/users/123?query=string", map[string]string{"sessionid": "zyx"}, ""),
@@ -69,10 +75,11 @@ func TestAuthenticatorCookieSession(t *testing.T) {
 			assert.Equal(t, r.Method, "PUT")
 			assert.Equal(t, r.URL.Path, "/users/123?query=string")
 			assert.Equal(t, r.Header.Get("Cookie"), "sessionid=zyx")
-			assert.Equal(t, &AuthenticationSession{Subject: "123"}, session)
+			assert.Equal(t, &AuthenticationSession{Subject: "123", ConsumedCookies: []string{"sessionid"}}, session)
 		})
 
 		t.Run("description=should pass through method and headers ONLY to auth server when PreservePath is true", func(t *testing.T) {
+			session := new(AuthenticationSession)
 			testServer, requestRecorder := makeServer(200, `{"subject": "123"}`)
 			err := pipelineAuthenticator.Authenticate(
 				makeRequest("PUT", "/users/123?query=string", map[string]string{"sessionid": "zyx"}, ""),
@@ -86,7 +93,7 @@ func TestAuthenticatorCookieSession(t *testing.T) {
 			assert.Equal(t, r.Method, "PUT")
 			assert.Equal(t, r.URL.Path, "/")
 			assert.Equal(t, r.Header.Get("Cookie"), "sessionid=zyx")
-			assert.Equal(t, &AuthenticationSession{Subject: "123"}, session)
+			assert.Equal(t, &AuthenticationSession{Subject: "123", ConsumedCookies: []string{"sessionid"}}, session)
 		})
 
 		t.Run("description=does not pass request body through to auth server", func(t *testing.T) {
@@ -128,6 +135,7 @@ func TestAuthenticatorCookieSession(t *testing.T) {
 		})
 
 		t.Run("description=should work with nested extra keys", func(t *testing.T) {
+			session := new(AuthenticationSession)
 			testServer, _ := makeServer(200, `{"subject": "123", "session": {"foo": "bar"}}`)
 			err := pipelineAuthenticator.Authenticate(
 				makeRequest("GET", "/", map[string]string{"sessionid": "zyx"}, ""),
@@ -137,12 +145,14 @@ func TestAuthenticatorCookieSession(t *testing.T) {
 			)
 			require.NoError(t, err, "%#v", errors.Cause(err))
 			assert.Equal(t, &AuthenticationSession{
-				Subject: "123",
-				Extra:   map[string]interface{}{"foo": "bar"},
+				Subject:         "123",
+				Extra:           map[string]interface{}{"foo": "bar"},
+				ConsumedCookies: []string{"sessionid"},
 			}, session)
 		})
 
 		t.Run("description=should work with the root key for extra and a custom subject key", func(t *testing.T) {
+			session := new(AuthenticationSession)
 			testServer, _ := makeServer(200, `{"identity": {"id": "123"}, "session": {"foo": "bar"}}`)
 			err := pipelineAuthenticator.Authenticate(
 				makeRequest("GET", "/", map[string]string{"sessionid": "zyx"}, ""),
@@ -152,10 +162,25 @@ func TestAuthenticatorCookieSession(t *testing.T) {
 			)
 			require.NoError(t, err, "%#v", errors.Cause(err))
 			assert.Equal(t, &AuthenticationSession{
-				Subject: "123",
-				Extra:   map[string]interface{}{"session": map[string]interface{}{"foo": "bar"}, "identity": map[string]interface{}{"id": "123"}},
+				Subject:         "123",
+				Extra:           map[string]interface{}{"session": map[string]interface{}{"foo": "bar"}, "identity": map[string]interface{}{"id": "123"}},
+				ConsumedCookies: []string{"sessionid"},
 			}, session)
 		})
+
+		t.Run("description=should fail because the session store response is too large", func(t *testing.T) {
+			viper.Set(configuration.ViperKeyRemoteResponseMaxBodySize, 16)
+			defer viper.Set(configuration.ViperKeyRemoteResponseMaxBodySize, 1<<20)
+
+			testServer, _ := makeServer(200, `{"subject": "123", "extra": {"foo": "bar"}}`)
+			err := pipelineAuthenticator.Authenticate(
+				makeRequest("GET", "/", map[string]string{"sessionid": "zyx"}, ""),
+				new(AuthenticationSession),
+				json.RawMessage(fmt.Sprintf(`{"check_session_url": "%s"}`, testServer.URL)),
+				nil,
+			)
+			require.Error(t, err)
+		})
 	})
 }
 
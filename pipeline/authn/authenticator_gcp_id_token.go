@@ -0,0 +1,159 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
+	"github.com/ory/go-convenience/jwtx"
+	"github.com/ory/go-convenience/stringslice"
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+)
+
+const gcpCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+var gcpIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+type AuthenticatorGCPIDTokenConfiguration struct {
+	Audience               []string                    `json:"target_audience"`
+	AllowedServiceAccounts []string                    `json:"allowed_service_accounts"`
+	JWKSURL                string                      `json:"jwks_url"`
+	BearerTokenLocation    *helper.BearerTokenLocation `json:"token_from"`
+	MaxTokenAge            string                      `json:"max_token_age"`
+}
+
+// AuthenticatorGCPIDToken authenticates Google Cloud workloads using the ID tokens issued to their service
+// accounts by the metadata server.
+type AuthenticatorGCPIDToken struct {
+	c configuration.Provider
+	r AuthenticatorJWTRegistry
+}
+
+func NewAuthenticatorGCPIDToken(c configuration.Provider, r AuthenticatorJWTRegistry) *AuthenticatorGCPIDToken {
+	return &AuthenticatorGCPIDToken{c: c, r: r}
+}
+
+func (a *AuthenticatorGCPIDToken) GetID() string {
+	return "gcp_id_token"
+}
+
+func (a *AuthenticatorGCPIDToken) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+// Stage implements the pipeline.Stager interface by making sure that the JSON Web Key Set is reachable.
+func (a *AuthenticatorGCPIDToken) Stage(ctx context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	jwksu, err := a.c.ParseURLs([]string{cf.JWKSURL})
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.r.CredentialsFetcher().ResolveSets(ctx, jwksu); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AuthenticatorGCPIDToken) Config(config json.RawMessage) (*AuthenticatorGCPIDTokenConfiguration, error) {
+	var c AuthenticatorGCPIDTokenConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if len(c.Audience) == 0 {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("at least one target audience must be configured"))
+	}
+
+	if c.JWKSURL == "" {
+		c.JWKSURL = gcpCertsURL
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorGCPIDToken) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	if token == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
+
+	claims, err := verifyCloudIdentityToken(r.Context(), a.c, a.r, token, cf.JWKSURL, gcpIssuers, cf.Audience)
+	if err != nil {
+		return err
+	}
+
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); email == "" || !verified {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The ID token does not contain a verified service account email."))
+	}
+
+	if len(cf.AllowedServiceAccounts) > 0 && !stringslice.Has(cf.AllowedServiceAccounts, email) {
+		return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Service account %s is not allowed.", email)))
+	}
+
+	parsed := jwtx.ParseMapStringInterfaceClaims(claims)
+	if a.r.RevocationStore().IsRevoked(r.Context(), email, "", parsed.IssuedAt) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The service account was revoked."))
+	}
+
+	if err := validateTokenAge(cf.MaxTokenAge, parsed.IssuedAt); err != nil {
+		return err
+	}
+
+	session.Subject = email
+	session.Extra = claims
+
+	return nil
+}
+
+// verifyCloudIdentityToken verifies a RS256 signed OpenID Connect ID token issued by a cloud provider and returns
+// its claims.
+func verifyCloudIdentityToken(ctx context.Context, c configuration.Provider, r AuthenticatorJWTRegistry, token, jwksURL string, issuers, audiences []string) (jwt.MapClaims, error) {
+	jwksu, err := c.ParseURLs([]string{jwksURL})
+	if err != nil {
+		return nil, err
+	}
+
+	pt, err := r.CredentialsVerifier().Verify(ctx, token, &credentials.ValidationContext{
+		Algorithms: []string{"RS256"},
+		KeyURLs:    jwksu,
+		Issuers:    issuers,
+		Audiences:  audiences,
+	})
+	if err != nil {
+		return nil, helper.ErrUnauthorized.WithReason(err.Error()).WithTrace(err)
+	}
+
+	claims, ok := pt.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Expected JSON Web Token claims to be of type jwt.MapClaims but got: %T", pt.Claims))
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,153 @@
+package authn_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorGCPIDToken(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("gcp_id_token")
+	require.NoError(t, err)
+	assert.Equal(t, "gcp_id_token", a.GetID())
+
+	jwks := "file://../../test/stub/jwks-rsa-single.json"
+	now := time.Now().UTC()
+	bearer := func(claims jwt.MapClaims) *http.Request {
+		token, err := reg.CredentialsSigner().Sign(context.Background(), urlx.ParseOrPanic(jwks), claims)
+		require.NoError(t, err)
+		return &http.Request{Header: http.Header{"Authorization": {"Bearer " + token}}}
+	}
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":            "https://accounts.google.com",
+			"aud":            "https://api.example.com",
+			"email":          "worker@project.iam.gserviceaccount.com",
+			"email_verified": true,
+			"sub":            "1234567890",
+			"iat":            now.Unix(),
+			"exp":            now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         string
+			expectErr      bool
+			expectExactErr error
+			expectCode     int
+		}{
+			{
+				d:              "should not be responsible for requests without token",
+				r:              &http.Request{Header: http.Header{}},
+				expectErr:      true,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d: "should pass",
+				r: bearer(claims(nil)),
+			},
+			{
+				d:      "should pass with the issuer without scheme and an allowed service account",
+				r:      bearer(claims(jwt.MapClaims{"iss": "accounts.google.com"})),
+				config: `{"allowed_service_accounts":["worker@project.iam.gserviceaccount.com"]}`,
+			},
+			{
+				d:          "should fail because the issuer is not google",
+				r:          bearer(claims(jwt.MapClaims{"iss": "https://issuer.example.com"})),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the audience does not match",
+				r:          bearer(claims(jwt.MapClaims{"aud": "https://other.example.com"})),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the email is not verified",
+				r:          bearer(claims(jwt.MapClaims{"email_verified": false})),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the service account is not allowed",
+				r:          bearer(claims(nil)),
+				config:     `{"allowed_service_accounts":["other@project.iam.gserviceaccount.com"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the token is too old",
+				r:          bearer(claims(jwt.MapClaims{"iat": now.Add(-time.Hour).Unix()})),
+				config:     `{"max_token_age":"5m"}`,
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				config := tc.config
+				if config == "" {
+					config = "{}"
+				}
+				var raw map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(config), &raw))
+				raw["jwks_url"] = jwks
+				raw["target_audience"] = []string{"https://api.example.com"}
+				cfg, err := json.Marshal(raw)
+				require.NoError(t, err)
+
+				session := new(AuthenticationSession)
+				err = a.Authenticate(tc.r, session, cfg, nil)
+				if tc.expectErr {
+					require.Error(t, err)
+					if tc.expectExactErr != nil {
+						assert.EqualError(t, err, tc.expectExactErr.Error())
+					}
+					if tc.expectCode != 0 {
+						assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode(), "%+v", err)
+					}
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, "worker@project.iam.gserviceaccount.com", session.Subject)
+				assert.Equal(t, "1234567890", session.Extra["sub"])
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorGCPIDTokenIsEnabled, true)
+		require.NoError(t, a.Validate(json.RawMessage(`{"target_audience":["https://api.example.com"]}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorGCPIDTokenIsEnabled, false)
+		require.Error(t, a.Validate(json.RawMessage(`{"target_audience":["https://api.example.com"]}`)))
+	})
+}
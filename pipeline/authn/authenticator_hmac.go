@@ -0,0 +1,279 @@
+package authn
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+)
+
+// HMACAlgorithm is the scheme of the Authorization header of signed requests.
+const HMACAlgorithm = "HMAC-SHA256"
+
+// HMACTimestampFormat is the format of the timestamp of signed requests, for example "20200901T120000Z".
+const HMACTimestampFormat = "20060102T150405Z"
+
+// hmacDefaultTimestampHeader is the header the timestamp is read from unless timestamp_header is set.
+const hmacDefaultTimestampHeader = "X-Date"
+
+type AuthenticatorHMACConfiguration struct {
+	Clients         []AuthenticatorHMACClient `json:"clients"`
+	SignedHeaders   []string                  `json:"signed_headers"`
+	TimestampHeader string                    `json:"timestamp_header"`
+
+	// MaxSkew is how far the timestamp of a request may be in the past or the future.
+	MaxSkew string `json:"max_skew"`
+
+	maxSkew time.Duration
+}
+
+// AuthenticatorHMACClient is a client signing requests with a shared secret.
+type AuthenticatorHMACClient struct {
+	ID string `json:"id"`
+
+	// Secret or SecretEnv, the environment variable containing the secret, must be set.
+	Secret    string `json:"secret"`
+	SecretEnv string `json:"secret_env"`
+
+	// Subject is the subject of the session. It defaults to the ID of the client.
+	Subject string                 `json:"subject"`
+	Extra   map[string]interface{} `json:"extra"`
+}
+
+func (c *AuthenticatorHMACClient) secret() string {
+	if c.SecretEnv != "" {
+		return os.Getenv(c.SecretEnv)
+	}
+	return c.Secret
+}
+
+// hmacSignature is the parsed Authorization header of a signed request:
+//
+//	HMAC-SHA256 Credential=<client id>, SignedHeaders=host;x-date, Signature=<hex>
+type hmacSignature struct {
+	credential    string
+	signedHeaders []string
+	signature     []byte
+}
+
+// AuthenticatorHMAC authenticates machine clients signing their requests with a shared secret, similar to AWS
+// Signature Version 4. The signature covers the method, the path, the query, the timestamp and the signed headers of
+// the request. Requests are rejected if their timestamp is outside of the configured window, which limits how long a
+// captured request can be replayed.
+type AuthenticatorHMAC struct {
+	c configuration.Provider
+}
+
+func NewAuthenticatorHMAC(c configuration.Provider) *AuthenticatorHMAC {
+	return &AuthenticatorHMAC{c: c}
+}
+
+func (a *AuthenticatorHMAC) GetID() string {
+	return "hmac"
+}
+
+func (a *AuthenticatorHMAC) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorHMAC) Config(config json.RawMessage) (*AuthenticatorHMACConfiguration, error) {
+	var c AuthenticatorHMACConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if len(c.Clients) == 0 {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("at least one client must be set"))
+	}
+	for k, client := range c.Clients {
+		if client.ID == "" {
+			return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf("the id of client %d must be set", k))
+		}
+		if client.Secret == "" && client.SecretEnv == "" {
+			return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf("either secret or secret_env of client %s must be set", client.ID))
+		}
+	}
+
+	if c.TimestampHeader == "" {
+		c.TimestampHeader = hmacDefaultTimestampHeader
+	}
+	if len(c.SignedHeaders) == 0 {
+		c.SignedHeaders = []string{"host"}
+	}
+
+	if c.MaxSkew == "" {
+		c.MaxSkew = "5m"
+	}
+	skew, err := time.ParseDuration(c.MaxSkew)
+	if err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.WithStack(err))
+	}
+	c.maxSkew = skew
+
+	return &c, nil
+}
+
+func (a *AuthenticatorHMAC) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, HMACAlgorithm+" ") {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+
+	sig, err := parseHMACSignature(strings.TrimPrefix(auth, HMACAlgorithm+" "))
+	if err != nil {
+		return err
+	}
+
+	var client *AuthenticatorHMACClient
+	for k := range cf.Clients {
+		if cf.Clients[k].ID == sig.credential {
+			client = &cf.Clients[k]
+			break
+		}
+	}
+	if client == nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The request was signed by an unknown client."))
+	}
+
+	for _, h := range cf.SignedHeaders {
+		if !containsHeader(sig.signedHeaders, h) {
+			return errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The signature must cover header "%s".`, strings.ToLower(h)))
+		}
+	}
+	if !containsHeader(sig.signedHeaders, cf.TimestampHeader) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The signature must cover header "%s".`, strings.ToLower(cf.TimestampHeader)))
+	}
+
+	timestamp, err := time.Parse(HMACTimestampFormat, r.Header.Get(cf.TimestampHeader))
+	if err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The timestamp of header "%s" must have format "%s".`, cf.TimestampHeader, HMACTimestampFormat))
+	}
+	if skew := time.Since(timestamp); skew > cf.maxSkew || skew < -cf.maxSkew {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The timestamp of the request is outside of the accepted window."))
+	}
+
+	secret := client.secret()
+	if secret == "" {
+		return errors.Errorf("the secret of client %s is empty", client.ID)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(HMACStringToSign(r, sig.signedHeaders)))
+	if !hmac.Equal(mac.Sum(nil), sig.signature) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The signature of the request is invalid."))
+	}
+
+	session.Subject = client.ID
+	if client.Subject != "" {
+		session.Subject = client.Subject
+	}
+	session.Extra = map[string]interface{}{}
+	for k, v := range client.Extra {
+		session.Extra[k] = v
+	}
+	return nil
+}
+
+// HMACStringToSign returns the canonical form of the request which is signed by clients. It consists of the
+// following lines:
+//
+//	<method>
+//	<escaped path>
+//	<query, sorted by key and value>
+//	<lower-case name of each signed header>:<its values, trimmed and joined by ",">
+//	<lower-case names of the signed headers, joined by ";">
+//
+// The signed headers are sorted by name. Header "host" refers to the host of the request.
+func HMACStringToSign(r *http.Request, signedHeaders []string) string {
+	headers := make([]string, len(signedHeaders))
+	for k, h := range signedHeaders {
+		headers[k] = strings.ToLower(h)
+	}
+	sort.Strings(headers)
+
+	lines := []string{r.Method, r.URL.EscapedPath(), canonicalQuery(r.URL.Query())}
+	for _, h := range headers {
+		values := r.Header[http.CanonicalHeaderKey(h)]
+		if h == "host" {
+			values = []string{r.Host}
+		}
+		trimmed := make([]string, len(values))
+		for k, v := range values {
+			trimmed[k] = strings.TrimSpace(v)
+		}
+		lines = append(lines, h+":"+strings.Join(trimmed, ","))
+	}
+	lines = append(lines, strings.Join(headers, ";"))
+
+	return strings.Join(lines, "\n")
+}
+
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func parseHMACSignature(raw string) (*hmacSignature, error) {
+	var sig hmacSignature
+	for _, part := range strings.Split(raw, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.WithStack(helper.ErrUnauthorized.WithReason("The Authorization header is malformed."))
+		}
+
+		switch kv[0] {
+		case "Credential":
+			sig.credential = kv[1]
+		case "SignedHeaders":
+			sig.signedHeaders = strings.Split(kv[1], ";")
+		case "Signature":
+			signature, err := hex.DecodeString(kv[1])
+			if err != nil {
+				return nil, errors.WithStack(helper.ErrUnauthorized.WithReason("The signature must be hex-encoded."))
+			}
+			sig.signature = signature
+		}
+	}
+
+	if sig.credential == "" || len(sig.signedHeaders) == 0 || len(sig.signature) == 0 {
+		return nil, errors.WithStack(helper.ErrUnauthorized.WithReason("The Authorization header must contain Credential, SignedHeaders and Signature."))
+	}
+	return &sig, nil
+}
+
+func containsHeader(headers []string, header string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h, header) {
+			return true
+		}
+	}
+	return false
+}
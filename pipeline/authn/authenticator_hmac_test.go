@@ -0,0 +1,175 @@
+package authn_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorHMAC(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("hmac")
+	require.NoError(t, err)
+	assert.Equal(t, "hmac", a.GetID())
+
+	require.NoError(t, os.Setenv("OATHKEEPER_TEST_HMAC_SECRET", "env-secret"))
+	defer os.Unsetenv("OATHKEEPER_TEST_HMAC_SECRET")
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"clients": []map[string]interface{}{
+			{"id": "billing", "secret": "billing-secret", "subject": "billing-service", "extra": map[string]interface{}{"team": "payments"}},
+			{"id": "reporting", "secret_env": "OATHKEEPER_TEST_HMAC_SECRET"},
+		},
+		"signed_headers": []string{"host", "content-type"},
+		"max_skew":       "1m",
+	})
+
+	now := time.Now().UTC().Format(HMACTimestampFormat)
+	request := func(client, secret, timestamp string, signedHeaders ...string) *http.Request {
+		r := &http.Request{
+			Method: "POST",
+			Host:   "api.example.org",
+			URL:    &url.URL{Path: "/invoices/a b", RawQuery: "status=open&limit=10"},
+			Header: http.Header{"Content-Type": {"application/json"}, "X-Date": {timestamp}},
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		_, _ = mac.Write([]byte(HMACStringToSign(r, signedHeaders)))
+		r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s, SignedHeaders=%s, Signature=%s",
+			HMACAlgorithm, client, strings.Join(signedHeaders, ";"), hex.EncodeToString(mac.Sum(nil))))
+		return r
+	}
+	tampered := func(r *http.Request) *http.Request {
+		r.URL.RawQuery = "status=paid&limit=10"
+		return r
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+			expectExtra    map[string]interface{}
+		}{
+			{
+				d:              "should not be responsible without an Authorization header",
+				r:              &http.Request{Header: http.Header{}},
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:              "should not be responsible for other schemes",
+				r:              &http.Request{Header: http.Header{"Authorization": {"Bearer token"}}},
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass with a valid signature",
+				r:             request("billing", "billing-secret", now, "host", "content-type", "x-date"),
+				expectSubject: "billing-service",
+				expectExtra:   map[string]interface{}{"team": "payments"},
+			},
+			{
+				d:             "should pass with the secret of the environment",
+				r:             request("reporting", "env-secret", now, "x-date", "Content-Type", "host"),
+				expectSubject: "reporting",
+				expectExtra:   map[string]interface{}{},
+			},
+			{
+				d:          "should fail with a malformed Authorization header",
+				r:          &http.Request{Header: http.Header{"Authorization": {HMACAlgorithm + " Credential"}}},
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an unknown client",
+				r:          request("unknown", "billing-secret", now, "host", "content-type", "x-date"),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with the secret of another client",
+				r:          request("billing", "env-secret", now, "host", "content-type", "x-date"),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail if a required header is not signed",
+				r:          request("billing", "billing-secret", now, "host", "x-date"),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail if the timestamp is not signed",
+				r:          request("billing", "billing-secret", now, "host", "content-type"),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with a malformed timestamp",
+				r:          request("billing", "billing-secret", time.Now().Format(time.RFC3339), "host", "content-type", "x-date"),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with a timestamp outside of the window",
+				r:          request("billing", "billing-secret", time.Now().Add(-time.Hour).UTC().Format(HMACTimestampFormat), "host", "content-type", "x-date"),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail if the request was modified",
+				r:          tampered(request("billing", "billing-secret", now, "host", "content-type", "x-date")),
+				expectCode: http.StatusUnauthorized,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				assert.Equal(t, tc.expectExtra, session.Extra)
+			})
+		}
+	})
+
+	t.Run("method=string to sign", func(t *testing.T) {
+		r := request("billing", "billing-secret", "20200901T120000Z", "host", "content-type", "x-date")
+		assert.Equal(t, "POST\n/invoices/a%20b\nlimit=10&status=open\ncontent-type:application/json\nhost:api.example.org\nx-date:20200901T120000Z\ncontent-type;host;x-date",
+			HMACStringToSign(r, []string{"X-Date", "host", "Content-Type"}))
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorHMACIsEnabled, true)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"clients":[{"id":"billing"}]}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"clients":[{"id":"billing","secret":"secret"}],"max_skew":"5 minutes"}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorHMACIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
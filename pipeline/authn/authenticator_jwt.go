@@ -1,23 +1,36 @@
 package authn
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	"gopkg.in/square/go-jose.v2"
 
 	"github.com/ory/go-convenience/jwtx"
 	"github.com/ory/herodot"
+	"github.com/ory/x/httpx"
 
 	"github.com/ory/oathkeeper/credentials"
 	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/fips"
 	"github.com/ory/oathkeeper/helper"
 	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/revocation"
+	"github.com/ory/oathkeeper/x"
 )
 
 type AuthenticatorJWTRegistry interface {
 	credentials.VerifierRegistry
+	credentials.FetcherRegistry
+	revocation.Registry
 }
 
 type AuthenticatorOAuth2JWTConfiguration struct {
@@ -28,11 +41,46 @@ type AuthenticatorOAuth2JWTConfiguration struct {
 	JWKSURLs            []string                    `json:"jwks_urls"`
 	ScopeStrategy       string                      `json:"scope_strategy"`
 	BearerTokenLocation *helper.BearerTokenLocation `json:"token_from"`
+	MaxTokenAge         string                      `json:"max_token_age"`
+
+	// Leeway is the clock skew tolerated when validating the "exp", "iat" and "nbf" claims.
+	Leeway string `json:"leeway"`
+
+	// IssuerURL is the issuer whose OpenID Connect discovery document provides the JSON Web Key Set, the issuer and
+	// the signing algorithms if they are not configured.
+	IssuerURL    string `json:"issuer_url"`
+	DiscoveryTTL string `json:"discovery_ttl"`
+
+	// DecryptionJWKSURLs are the JSON Web Key Sets holding the private keys used to decrypt encrypted (JWE) tokens.
+	DecryptionJWKSURLs []string `json:"decryption_jwks_urls"`
+
+	// DPoP requires requests to prove possession of the key the token is bound to.
+	DPoP *DPoPConfiguration `json:"dpop"`
+
+	discoveryTTL time.Duration
+	leeway       time.Duration
+}
+
+// jwtDiscovery is the part of the OpenID Connect discovery document of an issuer used to verify its tokens.
+type jwtDiscovery struct {
+	Issuer     string   `json:"issuer"`
+	JWKSURI    string   `json:"jwks_uri"`
+	Algorithms []string `json:"id_token_signing_alg_values_supported"`
+
+	jwksURL   url.URL
+	fetchedAt time.Time
 }
 
 type AuthenticatorJWT struct {
 	c configuration.Provider
 	r AuthenticatorJWTRegistry
+
+	client  *http.Client
+	dpop    *dpopValidator
+	flights singleflight.Group
+
+	sync.Mutex
+	discoveries map[string]*jwtDiscovery
 }
 
 func NewAuthenticatorJWT(
@@ -40,8 +88,11 @@ func NewAuthenticatorJWT(
 	r AuthenticatorJWTRegistry,
 ) *AuthenticatorJWT {
 	return &AuthenticatorJWT{
-		c: c,
-		r: r,
+		c:           c,
+		r:           r,
+		client:      httpx.NewResilientClientLatencyToleranceSmall(nil),
+		dpop:        newDPoPValidator(),
+		discoveries: map[string]*jwtDiscovery{},
 	}
 }
 
@@ -54,8 +105,60 @@ func (a *AuthenticatorJWT) Validate(config json.RawMessage) error {
 		return NewErrAuthenticatorNotEnabled(a)
 	}
 
-	_, err := a.Config(config)
-	return err
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	if a.c.FIPSIsEnabled() {
+		algorithms := cf.AllowedAlgorithms
+		if len(algorithms) == 0 {
+			algorithms = []string{"RS256"}
+		}
+		if err := fips.ValidateAlgorithms(algorithms); err != nil {
+			return NewErrAuthenticatorMisconfigured(a, err)
+		}
+	}
+
+	return nil
+}
+
+// Stage implements the pipeline.Stager interface by making sure that the discovery document and all JSON Web Key
+// Sets are reachable.
+func (a *AuthenticatorJWT) Stage(ctx context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	jwksu, err := a.c.ParseURLs(cf.JWKSURLs)
+	if err != nil {
+		return err
+	}
+
+	if cf.IssuerURL != "" {
+		d, err := a.discover(cf.IssuerURL, cf.discoveryTTL)
+		if err != nil {
+			return err
+		}
+		jwksu = append(jwksu, d.jwksURL)
+	}
+
+	decryptionu, err := a.c.ParseURLs(cf.DecryptionJWKSURLs)
+	if err != nil {
+		return err
+	}
+	jwksu = append(jwksu, decryptionu...)
+
+	if len(jwksu) == 0 {
+		return nil
+	}
+
+	if _, err := a.r.CredentialsFetcher().ResolveSets(ctx, jwksu); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (a *AuthenticatorJWT) Config(config json.RawMessage) (*AuthenticatorOAuth2JWTConfiguration, error) {
@@ -64,6 +167,36 @@ func (a *AuthenticatorJWT) Config(config json.RawMessage) (*AuthenticatorOAuth2J
 		return nil, NewErrAuthenticatorMisconfigured(a, err)
 	}
 
+	if len(c.JWKSURLs) == 0 && c.IssuerURL == "" {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("either jwks_urls or issuer_url must be set"))
+	}
+
+	if c.IssuerURL != "" {
+		if u, err := url.Parse(c.IssuerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf(`issuer_url "%s" must be an http(s):// URL`, c.IssuerURL))
+		}
+	}
+
+	if c.DiscoveryTTL == "" {
+		c.DiscoveryTTL = "1h"
+	}
+	ttl, err := time.ParseDuration(c.DiscoveryTTL)
+	if err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.WithStack(err))
+	}
+	c.discoveryTTL = ttl
+
+	if c.Leeway != "" {
+		leeway, err := time.ParseDuration(c.Leeway)
+		if err != nil {
+			return nil, NewErrAuthenticatorMisconfigured(a, errors.WithStack(err))
+		}
+		if leeway < 0 {
+			return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf(`leeway "%s" must not be negative`, c.Leeway))
+		}
+		c.leeway = leeway
+	}
+
 	return &c, nil
 }
 
@@ -73,13 +206,17 @@ func (a *AuthenticatorJWT) Authenticate(r *http.Request, session *Authentication
 		return err
 	}
 
-	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
-	if token == "" {
+	raw := tokenFromRequest(r, cf.BearerTokenLocation, cf.DPoP)
+	if raw == "" {
 		return errors.WithStack(ErrAuthenticatorNotResponsible)
 	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
 
-	if len(cf.AllowedAlgorithms) == 0 {
-		cf.AllowedAlgorithms = []string{"RS256"}
+	token := raw
+	if isEncryptedToken(token) {
+		if token, err = a.decrypt(r.Context(), token, cf); err != nil {
+			return err
+		}
 	}
 
 	jwksu, err := a.c.ParseURLs(cf.JWKSURLs)
@@ -87,6 +224,25 @@ func (a *AuthenticatorJWT) Authenticate(r *http.Request, session *Authentication
 		return err
 	}
 
+	if cf.IssuerURL != "" {
+		d, err := a.discover(cf.IssuerURL, cf.discoveryTTL)
+		if err != nil {
+			return err
+		}
+
+		jwksu = append(jwksu, d.jwksURL)
+		if len(cf.Issuers) == 0 {
+			cf.Issuers = []string{d.Issuer}
+		}
+		if len(cf.AllowedAlgorithms) == 0 {
+			cf.AllowedAlgorithms = a.discoveredAlgorithms(d)
+		}
+	}
+
+	if len(cf.AllowedAlgorithms) == 0 {
+		cf.AllowedAlgorithms = []string{"RS256"}
+	}
+
 	pt, err := a.r.CredentialsVerifier().Verify(r.Context(), token, &credentials.ValidationContext{
 		Algorithms:    cf.AllowedAlgorithms,
 		KeyURLs:       jwksu,
@@ -94,6 +250,7 @@ func (a *AuthenticatorJWT) Authenticate(r *http.Request, session *Authentication
 		Issuers:       cf.Issuers,
 		Audiences:     cf.Audience,
 		ScopeStrategy: a.c.ToScopeStrategy(cf.ScopeStrategy, "authenticators.jwt.Config.scope_strategy"),
+		Leeway:        cf.leeway,
 	})
 	if err != nil {
 		return helper.ErrUnauthorized.WithReason(err.Error()).WithTrace(err)
@@ -104,8 +261,159 @@ func (a *AuthenticatorJWT) Authenticate(r *http.Request, session *Authentication
 		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Expected JSON Web Token claims to be of type jwt.MapClaims but got: %T", pt.Claims))
 	}
 
-	session.Subject = jwtx.ParseMapStringInterfaceClaims(claims).Subject
+	parsed := jwtx.ParseMapStringInterfaceClaims(claims)
+	sid, _ := claims["sid"].(string)
+	if a.r.RevocationStore().IsRevoked(r.Context(), parsed.Subject, sid, parsed.IssuedAt) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The session of this JSON Web Token was revoked."))
+	}
+
+	if err := validateTokenAge(cf.MaxTokenAge, parsed.IssuedAt); err != nil {
+		return err
+	}
+
+	if cf.DPoP.isEnabled() {
+		if err := a.dpop.validate(r, cf.DPoP, raw, confirmationFromClaims(claims)); err != nil {
+			return err
+		}
+	}
+
+	session.Subject = parsed.Subject
 	session.Extra = claims
 
 	return nil
 }
+
+// discover returns the discovery document of the issuer, fetching it again once it is older than ttl. If the document
+// can not be fetched, the document fetched before is used until the next attempt. Because a rotated JSON Web Key Set
+// is served at the jwks_uri of the document fetched last, keys are picked up without restarting.
+func (a *AuthenticatorJWT) discover(issuer string, ttl time.Duration) (*jwtDiscovery, error) {
+	a.Lock()
+	d, ok := a.discoveries[issuer]
+	a.Unlock()
+	if ok && time.Since(d.fetchedAt) < ttl {
+		return d, nil
+	}
+
+	// Concurrent requests share one fetch, so it must not be canceled with the request which started it.
+	v, err, _ := a.flights.Do(issuer, func() (interface{}, error) {
+		ctx, cancel := x.WithOptionalTimeout(context.Background(), a.c.RemoteResponseTimeout())
+		defer cancel()
+		return a.fetchDiscovery(ctx, issuer)
+	})
+
+	a.Lock()
+	defer a.Unlock()
+
+	if err != nil {
+		if ok {
+			stale := *d
+			stale.fetchedAt = time.Now()
+			a.discoveries[issuer] = &stale
+			return &stale, nil
+		}
+		return nil, err
+	}
+
+	fetched := v.(*jwtDiscovery)
+	a.discoveries[issuer] = fetched
+	return fetched, nil
+}
+
+func (a *AuthenticatorJWT) fetchDiscovery(ctx context.Context, issuer string) (*jwtDiscovery, error) {
+	location := strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res, err := a.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("expected status code %d from OpenID Connect discovery document %s but got %d", http.StatusOK, location, res.StatusCode)
+	}
+
+	var d jwtDiscovery
+	if err := x.DecodeJSONResponse(res, a.c.RemoteResponseMaxBodySize(), &d); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode the OpenID Connect discovery document %s", location)
+	}
+
+	// The issuer of the document must be identical to the issuer it was fetched from, see
+	// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfigurationValidation
+	if strings.TrimRight(d.Issuer, "/") != strings.TrimRight(issuer, "/") {
+		return nil, errors.Errorf(`the OpenID Connect discovery document %s is for issuer "%s" but was expected to be for "%s"`, location, d.Issuer, issuer)
+	}
+
+	jwksu, err := url.Parse(d.JWKSURI)
+	if err != nil || (jwksu.Scheme != "http" && jwksu.Scheme != "https") {
+		return nil, errors.Errorf(`the OpenID Connect discovery document %s has no valid jwks_uri`, location)
+	}
+
+	d.jwksURL = *jwksu
+	d.fetchedAt = time.Now()
+	return &d, nil
+}
+
+// discoveredAlgorithms returns the asymmetric signing algorithms supported by the issuer. If FIPS mode is enabled,
+// only approved algorithms are returned.
+func (a *AuthenticatorJWT) discoveredAlgorithms(d *jwtDiscovery) []string {
+	var algorithms []string
+	for _, alg := range d.Algorithms {
+		if alg == "none" || strings.HasPrefix(alg, "HS") {
+			continue
+		}
+		if a.c.FIPSIsEnabled() && fips.ValidateAlgorithms([]string{alg}) != nil {
+			continue
+		}
+		algorithms = append(algorithms, alg)
+	}
+	return algorithms
+}
+
+// isEncryptedToken returns true if the token uses the JWE compact serialization, which has five parts.
+func isEncryptedToken(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// decrypt returns the signed JSON Web Token nested in the encrypted token. The key is chosen by the "kid" header of the
+// encrypted token. Because anyone with the public key is able to encrypt a token, the payload must be a signed token
+// which is verified like any other token.
+func (a *AuthenticatorJWT) decrypt(ctx context.Context, token string, cf *AuthenticatorOAuth2JWTConfiguration) (string, error) {
+	if len(cf.DecryptionJWKSURLs) == 0 {
+		return "", errors.WithStack(helper.ErrUnauthorized.WithReason("The JSON Web Token is encrypted but no decryption keys are configured."))
+	}
+
+	locations, err := a.c.ParseURLs(cf.DecryptionJWKSURLs)
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, err := jose.ParseEncrypted(token)
+	if err != nil {
+		return "", errors.WithStack(helper.ErrUnauthorized.WithReason("The encrypted JSON Web Token is malformed.").WithDebug(err.Error()))
+	}
+
+	key, err := a.r.CredentialsFetcher().ResolveKey(ctx, locations, encrypted.Header.KeyID, "enc")
+	if err != nil {
+		return "", helper.ErrUnauthorized.WithReason(err.Error()).WithTrace(err)
+	}
+
+	if key.IsPublic() {
+		return "", errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The JSON Web Key with ID "%s" is a public key and can not be used to decrypt the JSON Web Token.`, key.KeyID))
+	}
+
+	payload, err := encrypted.Decrypt(key.Key)
+	if err != nil {
+		return "", errors.WithStack(helper.ErrUnauthorized.WithReason("Unable to decrypt the JSON Web Token.").WithDebug(err.Error()))
+	}
+
+	nested := string(payload)
+	if strings.Count(nested, ".") != 2 {
+		return "", errors.WithStack(helper.ErrUnauthorized.WithReason("The encrypted JSON Web Token does not contain a signed JSON Web Token."))
+	}
+
+	return nested, nil
+}
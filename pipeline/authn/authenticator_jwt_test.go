@@ -24,16 +24,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/tidwall/sjson"
+	"gopkg.in/square/go-jose.v2"
 
+	"github.com/ory/viper"
 	"github.com/ory/x/urlx"
 
 	"github.com/ory/herodot"
+	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/internal"
 	. "github.com/ory/oathkeeper/pipeline/authn"
 
@@ -48,6 +53,8 @@ func TestAuthenticatorJWT(t *testing.T) {
 		"file://../../test/stub/jwks-rsa-multiple.json",
 		"file://../../test/stub/jwks-rsa-single.json",
 		"file://../../test/stub/jwks-ecdsa.json",
+		"file://../../test/stub/jwks-ed25519.json",
+		"file://../../test/stub/jwks-ecdsa-p521.json",
 	}
 	conf := internal.NewConfigurationWithDefaults()
 	// viper.Set(configuration.ViperKeyAuthenticatorJWTJWKSURIs, keys)
@@ -238,6 +245,42 @@ func TestAuthenticatorJWT(t *testing.T) {
 					Extra:   map[string]interface{}{"scp": []string{}, "sub": "sub", "exp": float64(now.Add(time.Hour).Unix())},
 				},
 			},
+			{
+				d: "should pass because JWT is valid and EdDSA is allowed",
+				r: &http.Request{Header: http.Header{"Authorization": []string{"bearer " + gen(keys[4], jwt.MapClaims{
+					"sub": "sub",
+					"exp": now.Add(time.Hour).Unix(),
+				})}}},
+				expectErr: false,
+				config:    `{ "allowed_algorithms": ["EdDSA"] }`,
+				expectSess: &AuthenticationSession{
+					Subject: "sub",
+					Extra:   map[string]interface{}{"scp": []string{}, "sub": "sub", "exp": float64(now.Add(time.Hour).Unix())},
+				},
+			},
+			{
+				d: "should fail because EdDSA is not allowed",
+				r: &http.Request{Header: http.Header{"Authorization": []string{"bearer " + gen(keys[4], jwt.MapClaims{
+					"sub": "sub",
+					"exp": now.Add(time.Hour).Unix(),
+				})}}},
+				config:     `{}`,
+				expectErr:  true,
+				expectCode: 401,
+			},
+			{
+				d: "should pass because JWT is valid and ES512 is allowed",
+				r: &http.Request{Header: http.Header{"Authorization": []string{"bearer " + gen(keys[5], jwt.MapClaims{
+					"sub": "sub",
+					"exp": now.Add(time.Hour).Unix(),
+				})}}},
+				expectErr: false,
+				config:    `{ "allowed_algorithms": ["ES512"] }`,
+				expectSess: &AuthenticationSession{
+					Subject: "sub",
+					Extra:   map[string]interface{}{"scp": []string{}, "sub": "sub", "exp": float64(now.Add(time.Hour).Unix())},
+				},
+			},
 			{
 				d: "should pass because JWT is valid",
 				r: &http.Request{Header: http.Header{"Authorization": []string{"bearer " + gen(keys[1], jwt.MapClaims{
@@ -299,6 +342,37 @@ func TestAuthenticatorJWT(t *testing.T) {
 				config:    `{"required_audience": ["aud-1", "aud-2", "aud-3"]}`,
 				expectErr: true,
 			},
+			{
+				d: "should pass because JWT was issued recently",
+				r: &http.Request{Header: http.Header{"Authorization": []string{"bearer " + gen(keys[1], jwt.MapClaims{
+					"sub": "sub",
+					"exp": now.Add(time.Hour).Unix(),
+					"iat": now.Add(-time.Minute).Unix(),
+				})}}},
+				config:    `{"max_token_age": "5m"}`,
+				expectErr: false,
+			},
+			{
+				d: "should fail because JWT was not issued recently",
+				r: &http.Request{Header: http.Header{"Authorization": []string{"bearer " + gen(keys[1], jwt.MapClaims{
+					"sub": "sub",
+					"exp": now.Add(time.Hour).Unix(),
+					"iat": now.Add(-time.Hour).Unix(),
+				})}}},
+				config:     `{"max_token_age": "5m"}`,
+				expectErr:  true,
+				expectCode: 401,
+			},
+			{
+				d: "should fail because JWT iat is missing but a maximum token age is required",
+				r: &http.Request{Header: http.Header{"Authorization": []string{"bearer " + gen(keys[1], jwt.MapClaims{
+					"sub": "sub",
+					"exp": now.Add(time.Hour).Unix(),
+				})}}},
+				config:     `{"max_token_age": "5m"}`,
+				expectErr:  true,
+				expectCode: 401,
+			},
 			{
 				d: "should fail because JWT is expired",
 				r: &http.Request{Header: http.Header{"Authorization": []string{"bearer " + gen(keys[1], jwt.MapClaims{
@@ -336,4 +410,153 @@ func TestAuthenticatorJWT(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("method=authenticate with discovery", func(t *testing.T) {
+		jwksPath := "/jwks/single"
+		var ts *httptest.Server
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/.well-known/openid-configuration":
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"issuer":                                ts.URL,
+					"jwks_uri":                              ts.URL + jwksPath,
+					"id_token_signing_alg_values_supported": []string{"RS256", "HS256", "none"},
+				})
+			case "/other/.well-known/openid-configuration":
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"issuer": ts.URL, "jwks_uri": ts.URL + jwksPath})
+			case "/jwks/single", "/jwks/multiple":
+				raw, err := ioutil.ReadFile("../../test/stub/jwks-rsa-" + r.URL.Path[len("/jwks/"):] + ".json")
+				require.NoError(t, err)
+				_, _ = w.Write(raw)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer ts.Close()
+
+		token := func(l, iss string) *http.Request {
+			return &http.Request{Header: http.Header{"Authorization": []string{"bearer " + gen(l, jwt.MapClaims{
+				"sub": "sub",
+				"iss": iss,
+				"exp": now.Add(time.Hour).Unix(),
+			})}}}
+		}
+		config := func(issuer, ttl string) json.RawMessage {
+			c, _ := sjson.Set(`{}`, "issuer_url", issuer)
+			if ttl != "" {
+				c, _ = sjson.Set(c, "discovery_ttl", ttl)
+			}
+			return json.RawMessage(c)
+		}
+
+		t.Run("case=should pass with keys and issuer of the discovery document", func(t *testing.T) {
+			session := new(AuthenticationSession)
+			require.NoError(t, a.Authenticate(token(keys[2], ts.URL), session, config(ts.URL, ""), nil))
+			assert.Equal(t, "sub", session.Subject)
+		})
+
+		t.Run("case=should fail if the token is from another issuer", func(t *testing.T) {
+			err := a.Authenticate(token(keys[2], "https://not-the-issuer.com/"), new(AuthenticationSession), config(ts.URL, ""), nil)
+			require.Error(t, err)
+			assert.Equal(t, 401, herodot.ToDefaultError(err, "").StatusCode())
+		})
+
+		t.Run("case=should fail if the discovery document is for another issuer", func(t *testing.T) {
+			require.Error(t, a.Authenticate(token(keys[2], ts.URL), new(AuthenticationSession), config(ts.URL+"/other", ""), nil))
+		})
+
+		t.Run("case=should pick up keys rotated by the discovery document", func(t *testing.T) {
+			jwksPath = "/jwks/multiple"
+			require.NoError(t, a.Authenticate(token(keys[1], ts.URL), new(AuthenticationSession), config(ts.URL, "0s"), nil))
+
+			err := a.Authenticate(token(keys[2], ts.URL), new(AuthenticationSession), config(ts.URL, "0s"), nil)
+			require.Error(t, err)
+			assert.Equal(t, 401, herodot.ToDefaultError(err, "").StatusCode())
+		})
+	})
+
+	t.Run("method=authenticate encrypted", func(t *testing.T) {
+		raw, err := ioutil.ReadFile("../../test/stub/jwks-ecdh.json")
+		require.NoError(t, err)
+		var set jose.JSONWebKeySet
+		require.NoError(t, json.Unmarshal(raw, &set))
+		key := set.Keys[0]
+
+		encrypt := func(kid, payload string) *http.Request {
+			encrypter, err := jose.NewEncrypter(
+				jose.A256GCM,
+				jose.Recipient{Algorithm: jose.ECDH_ES_A256KW, Key: key.Public().Key, KeyID: kid},
+				(&jose.EncrypterOptions{}).WithContentType("JWT"),
+			)
+			require.NoError(t, err)
+			object, err := encrypter.Encrypt([]byte(payload))
+			require.NoError(t, err)
+			token, err := object.CompactSerialize()
+			require.NoError(t, err)
+			return &http.Request{Header: http.Header{"Authorization": []string{"bearer " + token}}}
+		}
+		signed := gen(keys[2], jwt.MapClaims{
+			"sub": "sub",
+			"exp": now.Add(time.Hour).Unix(),
+		})
+		config := func(decryption ...string) json.RawMessage {
+			c, _ := sjson.Set(`{}`, "jwks_urls", []string{keys[2]})
+			if len(decryption) > 0 {
+				c, _ = sjson.Set(c, "decryption_jwks_urls", decryption)
+			}
+			return json.RawMessage(c)
+		}
+
+		t.Run("case=should pass because the nested token is signed", func(t *testing.T) {
+			session := new(AuthenticationSession)
+			require.NoError(t, a.Authenticate(encrypt(key.KeyID, signed), session, config("file://../../test/stub/jwks-ecdh.json"), nil))
+			assert.Equal(t, "sub", session.Subject)
+		})
+
+		for _, tc := range []struct {
+			d      string
+			r      *http.Request
+			config json.RawMessage
+		}{
+			{d: "no decryption keys are configured", r: encrypt(key.KeyID, signed), config: config()},
+			{d: "the key is unknown", r: encrypt("not-a-key", signed), config: config("file://../../test/stub/jwks-ecdh.json")},
+			{d: "the payload is not signed", r: encrypt(key.KeyID, `{"sub":"sub"}`), config: config("file://../../test/stub/jwks-ecdh.json")},
+		} {
+			t.Run("case=should fail because "+tc.d, func(t *testing.T) {
+				err := a.Authenticate(tc.r, new(AuthenticationSession), tc.config, nil)
+				require.Error(t, err)
+				assert.Equal(t, 401, herodot.ToDefaultError(err, "").StatusCode())
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorJWTIsEnabled, true)
+		defer viper.Set(configuration.ViperKeyFIPSIsEnabled, false)
+
+		config := func(algorithms ...string) json.RawMessage {
+			c, _ := sjson.Set(`{}`, "jwks_urls", keys)
+			if len(algorithms) > 0 {
+				c, _ = sjson.Set(c, "allowed_algorithms", algorithms)
+			}
+			return json.RawMessage(c)
+		}
+
+		viper.Set(configuration.ViperKeyFIPSIsEnabled, false)
+		require.NoError(t, a.Validate(config("EdDSA")))
+		require.NoError(t, a.Validate(config("ES512")))
+		require.Error(t, a.Validate(config("none")))
+
+		viper.Set(configuration.ViperKeyFIPSIsEnabled, true)
+		require.NoError(t, a.Validate(config()))
+		require.NoError(t, a.Validate(config("RS256", "ES256")))
+		require.Error(t, a.Validate(config("RS256", "EdDSA")))
+
+		require.NoError(t, a.Validate(json.RawMessage(`{"issuer_url":"https://my-issuer.com/"}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"issuer_url":"file://my-issuer.com/"}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"issuer_url":"https://my-issuer.com/","discovery_ttl":"soon"}`)))
+
+		require.NoError(t, a.Validate(json.RawMessage(`{"issuer_url":"https://my-issuer.com/","leeway":"30s"}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"issuer_url":"https://my-issuer.com/","leeway":"-30s"}`)))
+	})
 }
@@ -0,0 +1,182 @@
+package authn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/service"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+)
+
+type AuthenticatorKerberosConfiguration struct {
+	// KeytabFile is the keytab containing the keys of the service principal.
+	KeytabFile string `json:"keytab_file"`
+
+	// ServicePrincipal is the principal of the keytab used to decrypt service tickets, for example
+	// "HTTP/api.example.org". It defaults to the service principal of the ticket.
+	ServicePrincipal string `json:"service_principal"`
+
+	// MaxSkew is how far the clock of a client may differ from the clock of ORY Oathkeeper.
+	MaxSkew string `json:"max_skew"`
+
+	maxSkew time.Duration
+}
+
+type kerberosKeytab struct {
+	modTime time.Time
+	keytab  *keytab.Keytab
+}
+
+// AuthenticatorKerberos authenticates requests carrying a Kerberos service ticket in an "Authorization: Negotiate"
+// header (SPNEGO), as sent by browsers and clients of Windows domains. Tickets are decrypted with the keys of a keytab,
+// no connection to the KDC is needed.
+type AuthenticatorKerberos struct {
+	c configuration.Provider
+
+	sync.Mutex
+	keytabs map[string]*kerberosKeytab
+}
+
+func NewAuthenticatorKerberos(c configuration.Provider) *AuthenticatorKerberos {
+	return &AuthenticatorKerberos{c: c, keytabs: map[string]*kerberosKeytab{}}
+}
+
+func (a *AuthenticatorKerberos) GetID() string {
+	return "kerberos"
+}
+
+func (a *AuthenticatorKerberos) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorKerberos) Config(config json.RawMessage) (*AuthenticatorKerberosConfiguration, error) {
+	var c AuthenticatorKerberosConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if c.KeytabFile == "" {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("keytab_file must be set"))
+	}
+
+	if c.MaxSkew == "" {
+		c.MaxSkew = "5m"
+	}
+	skew, err := time.ParseDuration(c.MaxSkew)
+	if err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.WithStack(err))
+	}
+	c.maxSkew = skew
+
+	return &c, nil
+}
+
+func (a *AuthenticatorKerberos) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 10 || !strings.EqualFold(auth[:10], "negotiate ") {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+
+	token, err := base64.StdEncoding.DecodeString(strings.TrimSpace(auth[10:]))
+	if err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The Negotiate token is not base64-encoded.").WithTrace(err))
+	}
+
+	apReq, err := kerberosAPReq(token)
+	if err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The Negotiate token does not contain a Kerberos service ticket.").WithTrace(err))
+	}
+
+	kt, err := a.keytab(cf.KeytabFile)
+	if err != nil {
+		return NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	settings := []func(*service.Settings){service.MaxClockSkew(cf.maxSkew), service.DecodePAC(false)}
+	if cf.ServicePrincipal != "" {
+		settings = append(settings, service.KeytabPrincipal(cf.ServicePrincipal))
+	}
+
+	ok, creds, err := service.VerifyAPREQ(&apReq.APReq, service.NewSettings(kt, settings...))
+	if err != nil || !ok {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The Kerberos service ticket is invalid.").WithTrace(err))
+	}
+
+	session.Subject = creds.CName().PrincipalNameString() + "@" + creds.Domain()
+	session.Extra = map[string]interface{}{
+		"principal": creds.CName().PrincipalNameString(),
+		"realm":     creds.Domain(),
+	}
+	return nil
+}
+
+// kerberosAPReq returns the Kerberos AP-REQ of a Negotiate token. Clients usually send a SPNEGO token wrapping the
+// AP-REQ, some send the raw Kerberos token instead.
+func kerberosAPReq(token []byte) (*spnego.KRB5Token, error) {
+	var st spnego.SPNEGOToken
+	if err := st.Unmarshal(token); err == nil {
+		if !st.Init || len(st.NegTokenInit.MechTokenBytes) == 0 {
+			return nil, errors.New("the SPNEGO token does not contain a mechanism token")
+		}
+		token = st.NegTokenInit.MechTokenBytes
+	}
+
+	var mt spnego.KRB5Token
+	if err := mt.Unmarshal(token); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !mt.IsAPReq() {
+		return nil, errors.New("the Kerberos token is not an AP-REQ")
+	}
+	return &mt, nil
+}
+
+// keytab returns the keytab of the file. The file is loaded again once it was modified, which allows rotating keys.
+func (a *AuthenticatorKerberos) keytab(path string) (*keytab.Keytab, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if k, ok := a.keytabs[path]; ok && k.modTime.Equal(info.ModTime()) {
+		return k.keytab, nil
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	kt := keytab.New()
+	if err := kt.Unmarshal(raw); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse keytab file %s", path)
+	}
+
+	a.keytabs[path] = &kerberosKeytab{modTime: info.ModTime(), keytab: kt}
+	return kt, nil
+}
@@ -0,0 +1,163 @@
+package authn_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorKerberos(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("kerberos")
+	require.NoError(t, err)
+	assert.Equal(t, "kerberos", a.GetID())
+
+	dir, err := ioutil.TempDir("", "kerberos")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeKeytab := func(name, password string) string {
+		kt := keytab.New()
+		require.NoError(t, kt.AddEntry("HTTP/api.example.org", "EXAMPLE.ORG", password, time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96))
+		raw, err := kt.Marshal()
+		require.NoError(t, err)
+		p := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(p, raw, 0600))
+		return p
+	}
+	serviceKeytab := writeKeytab("http.keytab", "service-password")
+	otherKeytab := writeKeytab("other.keytab", "other-password")
+
+	// negotiate returns a request with a Negotiate header carrying a service ticket issued as if by the KDC of EXAMPLE.ORG.
+	negotiate := func(user string, expiresIn time.Duration) *http.Request {
+		kt := keytab.New()
+		require.NoError(t, kt.AddEntry("HTTP/api.example.org", "EXAMPLE.ORG", "service-password", time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96))
+
+		now := time.Now().UTC()
+		ticket, sessionKey, err := messages.NewTicket(
+			types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, user), "EXAMPLE.ORG",
+			types.NewPrincipalName(nametype.KRB_NT_SRV_INST, "HTTP/api.example.org"), "EXAMPLE.ORG",
+			types.NewKrbFlags(), kt, etypeID.AES256_CTS_HMAC_SHA1_96, 1,
+			now, now, now.Add(expiresIn), now.Add(expiresIn),
+		)
+		require.NoError(t, err)
+
+		cl := client.NewWithPassword(user, "EXAMPLE.ORG", "user-password", krbconfig.New())
+		nti, err := spnego.NewNegTokenInitKRB5(cl, ticket, sessionKey)
+		require.NoError(t, err)
+		token, err := (&spnego.SPNEGOToken{Init: true, NegTokenInit: nti}).Marshal()
+		require.NoError(t, err)
+
+		return &http.Request{Header: http.Header{"Authorization": {"Negotiate " + base64.StdEncoding.EncodeToString(token)}}}
+	}
+
+	config, _ := json.Marshal(map[string]interface{}{"keytab_file": serviceKeytab})
+	principal, _ := json.Marshal(map[string]interface{}{"keytab_file": serviceKeytab, "service_principal": "HTTP/api.example.org"})
+	other, _ := json.Marshal(map[string]interface{}{"keytab_file": otherKeytab})
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         json.RawMessage
+			expectExactErr error
+			expectCode     int
+		}{
+			{
+				d:              "should not be responsible without a Negotiate header",
+				r:              &http.Request{Header: http.Header{"Authorization": {"Basic Zm9vOmJhcg=="}}},
+				config:         config,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:      "should pass with a valid service ticket",
+				r:      negotiate("alice", time.Hour),
+				config: config,
+			},
+			{
+				d:      "should pass with a valid service ticket and a service principal",
+				r:      negotiate("alice", time.Hour),
+				config: principal,
+			},
+			{
+				d:          "should fail with a service ticket encrypted with another key",
+				r:          negotiate("alice", time.Hour),
+				config:     other,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an expired service ticket",
+				r:          negotiate("alice", -time.Hour),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with a token which is not base64-encoded",
+				r:          &http.Request{Header: http.Header{"Authorization": {"Negotiate !"}}},
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with a NTLM token",
+				r:          &http.Request{Header: http.Header{"Authorization": {"Negotiate TlRMTVNTUAABAAAAB4IIogAAAAAAAAAAAAAAAAAAAAAKAGFKAAAADw=="}}},
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, tc.config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, "alice@EXAMPLE.ORG", session.Subject)
+				assert.Equal(t, map[string]interface{}{"principal": "alice", "realm": "EXAMPLE.ORG"}, session.Extra)
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorKerberosIsEnabled, true)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"keytab_file":"`+serviceKeytab+`","max_skew":"soon"}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorKerberosIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
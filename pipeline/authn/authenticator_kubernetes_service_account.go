@@ -0,0 +1,325 @@
+package authn
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
+	"github.com/ory/go-convenience/jwtx"
+	"github.com/ory/go-convenience/stringslice"
+	"github.com/ory/herodot"
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	KubernetesServiceAccountModeTokenReview = "token_review"
+	KubernetesServiceAccountModeOIDC        = "oidc"
+
+	kubernetesServiceAccountPrefix = "system:serviceaccount:"
+)
+
+type AuthenticatorKubernetesServiceAccountConfiguration struct {
+	Mode                   string                      `json:"mode"`
+	APIServerURL           string                      `json:"api_server_url"`
+	CAFile                 string                      `json:"ca_file"`
+	TokenFile              string                      `json:"token_file"`
+	Issuer                 string                      `json:"issuer"`
+	JWKSURL                string                      `json:"jwks_url"`
+	Audience               []string                    `json:"target_audience"`
+	AllowedNamespaces      []string                    `json:"allowed_namespaces"`
+	AllowedServiceAccounts []string                    `json:"allowed_service_accounts"`
+	BearerTokenLocation    *helper.BearerTokenLocation `json:"token_from"`
+}
+
+type kubernetesTokenReview struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Token     string   `json:"token"`
+		Audiences []string `json:"audiences,omitempty"`
+	} `json:"spec"`
+	Status struct {
+		Authenticated bool `json:"authenticated"`
+		User          struct {
+			Username string              `json:"username"`
+			UID      string              `json:"uid"`
+			Groups   []string            `json:"groups"`
+			Extra    map[string][]string `json:"extra"`
+		} `json:"user"`
+		Audiences []string `json:"audiences"`
+		Error     string   `json:"error"`
+	} `json:"status"`
+}
+
+// AuthenticatorKubernetesServiceAccount authenticates workloads running in Kubernetes using their service account
+// tokens. Tokens are validated using the TokenReview API of the cluster or offline using the keys published by the
+// service account issuer.
+type AuthenticatorKubernetesServiceAccount struct {
+	c configuration.Provider
+	r AuthenticatorJWTRegistry
+
+	sync.Mutex
+	clients map[string]*http.Client
+}
+
+func NewAuthenticatorKubernetesServiceAccount(c configuration.Provider, r AuthenticatorJWTRegistry) *AuthenticatorKubernetesServiceAccount {
+	return &AuthenticatorKubernetesServiceAccount{c: c, r: r, clients: map[string]*http.Client{}}
+}
+
+func (a *AuthenticatorKubernetesServiceAccount) GetID() string {
+	return "kubernetes_service_account"
+}
+
+func (a *AuthenticatorKubernetesServiceAccount) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+// Stage implements the pipeline.Stager interface by making sure that the JSON Web Key Set is reachable when
+// validating tokens offline.
+func (a *AuthenticatorKubernetesServiceAccount) Stage(ctx context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	} else if cf.Mode != KubernetesServiceAccountModeOIDC {
+		return nil
+	}
+
+	jwksu, err := a.c.ParseURLs([]string{cf.JWKSURL})
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.r.CredentialsFetcher().ResolveSets(ctx, jwksu); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AuthenticatorKubernetesServiceAccount) Config(config json.RawMessage) (*AuthenticatorKubernetesServiceAccountConfiguration, error) {
+	var c AuthenticatorKubernetesServiceAccountConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	switch c.Mode {
+	case "", KubernetesServiceAccountModeTokenReview:
+		c.Mode = KubernetesServiceAccountModeTokenReview
+		if c.APIServerURL == "" {
+			c.APIServerURL = "https://kubernetes.default.svc"
+		}
+		if c.CAFile == "" {
+			c.CAFile = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+		}
+		if c.TokenFile == "" {
+			c.TokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+		}
+	case KubernetesServiceAccountModeOIDC:
+		if c.Issuer == "" {
+			c.Issuer = "https://kubernetes.default.svc.cluster.local"
+		}
+		if c.JWKSURL == "" {
+			c.JWKSURL = strings.TrimRight(c.Issuer, "/") + "/openid/v1/jwks"
+		}
+	default:
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf("mode %s is not supported", c.Mode))
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorKubernetesServiceAccount) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	if token == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
+
+	var username string
+	var issuedAt time.Time
+	extra := map[string]interface{}{}
+	if cf.Mode == KubernetesServiceAccountModeOIDC {
+		claims, err := a.verify(r.Context(), cf, token)
+		if err != nil {
+			return err
+		}
+		parsed := jwtx.ParseMapStringInterfaceClaims(claims)
+		username, issuedAt = parsed.Subject, parsed.IssuedAt
+		extra["audiences"] = parsed.Audience
+		if k, ok := claims["kubernetes.io"].(map[string]interface{}); ok {
+			extra["kubernetes.io"] = k
+		}
+	} else {
+		review, err := a.review(r.Context(), cf, token)
+		if err != nil {
+			return err
+		}
+		username = review.Status.User.Username
+		extra["audiences"] = review.Status.Audiences
+		extra["uid"] = review.Status.User.UID
+		extra["groups"] = review.Status.User.Groups
+		extra["user_extra"] = review.Status.User.Extra
+	}
+
+	parts := strings.Split(strings.TrimPrefix(username, kubernetesServiceAccountPrefix), ":")
+	if !strings.HasPrefix(username, kubernetesServiceAccountPrefix) || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf("The token does not belong to a service account but to %s.", username))
+	}
+	namespace, name := parts[0], parts[1]
+
+	if len(cf.AllowedNamespaces) > 0 && !stringslice.Has(cf.AllowedNamespaces, namespace) {
+		return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Namespace %s is not allowed.", namespace)))
+	}
+
+	if len(cf.AllowedServiceAccounts) > 0 && !stringslice.Has(cf.AllowedServiceAccounts, namespace+":"+name) {
+		return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Service account %s:%s is not allowed.", namespace, name)))
+	}
+
+	// The TokenReview API does not tell when a token was issued. Revoking the subject therefore rejects all of its
+	// tokens until the revocation expires.
+	if a.r.RevocationStore().IsRevoked(r.Context(), username, "", issuedAt) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The service account was revoked."))
+	}
+
+	extra["namespace"] = namespace
+	extra["service_account"] = name
+	session.Subject = username
+	session.Extra = extra
+
+	return nil
+}
+
+func (a *AuthenticatorKubernetesServiceAccount) verify(ctx context.Context, cf *AuthenticatorKubernetesServiceAccountConfiguration, token string) (jwt.MapClaims, error) {
+	jwksu, err := a.c.ParseURLs([]string{cf.JWKSURL})
+	if err != nil {
+		return nil, err
+	}
+
+	pt, err := a.r.CredentialsVerifier().Verify(ctx, token, &credentials.ValidationContext{
+		Algorithms: []string{"RS256", "ES256"},
+		KeyURLs:    jwksu,
+		Issuers:    []string{cf.Issuer},
+		Audiences:  cf.Audience,
+	})
+	if err != nil {
+		return nil, helper.ErrUnauthorized.WithReason(err.Error()).WithTrace(err)
+	}
+
+	claims, ok := pt.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Expected JSON Web Token claims to be of type jwt.MapClaims but got: %T", pt.Claims))
+	}
+
+	return claims, nil
+}
+
+func (a *AuthenticatorKubernetesServiceAccount) review(ctx context.Context, cf *AuthenticatorKubernetesServiceAccountConfiguration, token string) (*kubernetesTokenReview, error) {
+	client, err := a.client(cf.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token of the reviewing service account is read on every request because Kubernetes rotates it.
+	credential, err := ioutil.ReadFile(cf.TokenFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	review := kubernetesTokenReview{APIVersion: "authentication.k8s.io/v1", Kind: "TokenReview"}
+	review.Spec.Token = token
+	review.Spec.Audiences = cf.Audience
+
+	body, err := json.Marshal(&review)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(cf.APIServerURL, "/")+"/apis/authentication.k8s.io/v1/tokenreviews", bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(credential)))
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("TokenReview returned status code %d but expected %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	if err := x.DecodeJSONResponse(resp, a.c.RemoteResponseMaxBodySize(), &review); err != nil {
+		return nil, err
+	}
+
+	if !review.Status.Authenticated {
+		reason := "The Kubernetes API server did not authenticate the token."
+		if review.Status.Error != "" {
+			reason = review.Status.Error
+		}
+		return nil, errors.WithStack(helper.ErrUnauthorized.WithReason(reason))
+	}
+
+	if len(cf.Audience) > 0 && len(review.Status.Audiences) == 0 {
+		return nil, errors.WithStack(helper.ErrUnauthorized.WithReason("The token is not intended for any of the target audiences."))
+	}
+
+	return &review, nil
+}
+
+// client returns a HTTP client trusting the certificate authorities of the file which is cached per file.
+func (a *AuthenticatorKubernetesServiceAccount) client(caFile string) (*http.Client, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if c, ok := a.clients[caFile]; ok {
+		return c, nil
+	}
+
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("unable to load certificate authorities from %s", caFile)
+	}
+
+	c := httpx.NewResilientClientLatencyToleranceSmall(&http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	})
+	a.clients[caFile] = c
+	return c, nil
+}
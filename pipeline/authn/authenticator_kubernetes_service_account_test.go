@@ -0,0 +1,243 @@
+package authn_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorKubernetesServiceAccount(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("kubernetes_service_account")
+	require.NoError(t, err)
+	assert.Equal(t, "kubernetes_service_account", a.GetID())
+
+	bearer := func(token string) *http.Request {
+		return &http.Request{Header: http.Header{"Authorization": {"Bearer " + token}}}
+	}
+	run := func(t *testing.T, base map[string]interface{}, cases []struct {
+		d              string
+		r              *http.Request
+		config         string
+		expectErr      bool
+		expectExactErr error
+		expectCode     int
+		expectSubject  string
+	}) {
+		for k, tc := range cases {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				config := tc.config
+				if config == "" {
+					config = "{}"
+				}
+				var raw map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(config), &raw))
+				for key, value := range base {
+					raw[key] = value
+				}
+				cfg, err := json.Marshal(raw)
+				require.NoError(t, err)
+
+				session := new(AuthenticationSession)
+				err = a.Authenticate(tc.r, session, cfg, nil)
+				if tc.expectErr {
+					require.Error(t, err)
+					if tc.expectExactErr != nil {
+						assert.EqualError(t, err, tc.expectExactErr.Error())
+					}
+					if tc.expectCode != 0 {
+						assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode(), "%+v", err)
+					}
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				assert.Equal(t, "default", session.Extra["namespace"])
+				assert.Equal(t, "worker", session.Extra["service_account"])
+			})
+		}
+	}
+
+	t.Run("mode=token_review", func(t *testing.T) {
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/apis/authentication.k8s.io/v1/tokenreviews", r.URL.Path)
+			assert.Equal(t, "Bearer reviewer-token", r.Header.Get("Authorization"))
+
+			var review map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&review))
+			spec := review["spec"].(map[string]interface{})
+
+			status := map[string]interface{}{"authenticated": false, "error": "invalid bearer token"}
+			switch spec["token"] {
+			case "worker-token":
+				status = map[string]interface{}{
+					"authenticated": true,
+					"user":          map[string]interface{}{"username": "system:serviceaccount:default:worker", "uid": "uid"},
+					"audiences":     spec["audiences"],
+				}
+			case "user-token":
+				status = map[string]interface{}{
+					"authenticated": true,
+					"user":          map[string]interface{}{"username": "jane"},
+				}
+			}
+			review["status"] = status
+			w.WriteHeader(http.StatusCreated)
+			require.NoError(t, json.NewEncoder(w).Encode(review))
+		}))
+		defer ts.Close()
+
+		dir, err := ioutil.TempDir("", "oathkeeper-kubernetes")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		caFile, tokenFile := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "token")
+		require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600))
+		require.NoError(t, ioutil.WriteFile(tokenFile, []byte("reviewer-token\n"), 0600))
+
+		run(t, map[string]interface{}{"api_server_url": ts.URL, "ca_file": caFile, "token_file": tokenFile}, []struct {
+			d              string
+			r              *http.Request
+			config         string
+			expectErr      bool
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+		}{
+			{
+				d:              "should not be responsible for requests without token",
+				r:              &http.Request{Header: http.Header{}},
+				expectErr:      true,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass",
+				r:             bearer("worker-token"),
+				expectSubject: "system:serviceaccount:default:worker",
+			},
+			{
+				d:             "should pass with an audience and allowed namespace and service account",
+				r:             bearer("worker-token"),
+				config:        `{"target_audience":["api"],"allowed_namespaces":["default"],"allowed_service_accounts":["default:worker"]}`,
+				expectSubject: "system:serviceaccount:default:worker",
+			},
+			{
+				d:          "should fail because the token is invalid",
+				r:          bearer("invalid-token"),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the token does not belong to a service account",
+				r:          bearer("user-token"),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the namespace is not allowed",
+				r:          bearer("worker-token"),
+				config:     `{"allowed_namespaces":["kube-system"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the service account is not allowed",
+				r:          bearer("worker-token"),
+				config:     `{"allowed_service_accounts":["default:other"]}`,
+				expectErr:  true,
+				expectCode: http.StatusForbidden,
+			},
+		})
+	})
+
+	t.Run("mode=oidc", func(t *testing.T) {
+		jwks := "file://../../test/stub/jwks-rsa-single.json"
+		now := time.Now().UTC()
+		gen := func(overrides jwt.MapClaims) *http.Request {
+			claims := jwt.MapClaims{
+				"iss": "https://kubernetes.default.svc.cluster.local",
+				"aud": []string{"api"},
+				"sub": "system:serviceaccount:default:worker",
+				"iat": now.Unix(),
+				"exp": now.Add(time.Hour).Unix(),
+				"kubernetes.io": map[string]interface{}{
+					"namespace":      "default",
+					"serviceaccount": map[string]interface{}{"name": "worker", "uid": "uid"},
+				},
+			}
+			for k, v := range overrides {
+				claims[k] = v
+			}
+			token, err := reg.CredentialsSigner().Sign(context.Background(), urlx.ParseOrPanic(jwks), claims)
+			require.NoError(t, err)
+			return bearer(token)
+		}
+
+		run(t, map[string]interface{}{"mode": "oidc", "jwks_url": jwks, "target_audience": []string{"api"}}, []struct {
+			d              string
+			r              *http.Request
+			config         string
+			expectErr      bool
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+		}{
+			{
+				d:             "should pass",
+				r:             gen(nil),
+				expectSubject: "system:serviceaccount:default:worker",
+			},
+			{
+				d:          "should fail because the issuer does not match",
+				r:          gen(jwt.MapClaims{"iss": "https://other-cluster"}),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the audience does not match",
+				r:          gen(jwt.MapClaims{"aud": []string{"other-api"}}),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the token is expired",
+				r:          gen(jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}),
+				expectErr:  true,
+				expectCode: http.StatusUnauthorized,
+			},
+		})
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorKubernetesServiceAccountIsEnabled, true)
+		require.NoError(t, a.Validate(json.RawMessage(`{}`)))
+		require.NoError(t, a.Validate(json.RawMessage(`{"mode":"oidc","issuer":"https://cluster.example.com"}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"mode":"unknown"}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorKubernetesServiceAccountIsEnabled, false)
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+	})
+}
@@ -0,0 +1,332 @@
+package authn
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+)
+
+// Locations the ldap authenticator reads the credentials from.
+const (
+	LDAPCredentialsFromBasic = "basic"
+	LDAPCredentialsFromForm  = "form"
+
+	// ldapMaxFormSize limits the size of form bodies read to find the credentials.
+	ldapMaxFormSize = 1 << 20
+)
+
+type AuthenticatorLDAPConfiguration struct {
+	// URL is the address of the server, for example "ldaps://ldap.example.org:636".
+	URL      string `json:"url"`
+	StartTLS bool   `json:"start_tls"`
+	CAFile   string `json:"ca_file"`
+	Timeout  string `json:"timeout"`
+
+	// UserDN, if set, is the DN of the user which is bound to directly. "{username}" is replaced with the escaped
+	// username, for example "uid={username},ou=people,dc=example,dc=org".
+	UserDN string `json:"user_dn"`
+
+	// BindDN and BindPassword are the credentials of the service account used to search for users and groups.
+	BindDN       string                          `json:"bind_dn"`
+	BindPassword string                          `json:"bind_password"`
+	UserSearch   *AuthenticatorLDAPSearch        `json:"user_search"`
+	GroupSearch  *AuthenticatorLDAPSearch        `json:"group_search"`
+	Credentials  *AuthenticatorLDAPCredentialsIn `json:"credentials_from"`
+
+	timeout time.Duration
+}
+
+// AuthenticatorLDAPSearch is a search below BaseDN. In the filter of user searches "{username}" is replaced with the
+// escaped username, in the filter of group searches "{dn}" is replaced with the escaped DN of the user and
+// "{username}" with the escaped username.
+type AuthenticatorLDAPSearch struct {
+	BaseDN    string `json:"base_dn"`
+	Filter    string `json:"filter"`
+	Attribute string `json:"attribute"`
+}
+
+// AuthenticatorLDAPCredentialsIn defines where the username and the password are read from.
+type AuthenticatorLDAPCredentialsIn struct {
+	Source        string `json:"source"`
+	UsernameField string `json:"username_field"`
+	PasswordField string `json:"password_field"`
+}
+
+// AuthenticatorLDAP authenticates users by binding to an LDAP server, for example Active Directory, with their
+// username and password.
+type AuthenticatorLDAP struct {
+	c configuration.Provider
+}
+
+func NewAuthenticatorLDAP(c configuration.Provider) *AuthenticatorLDAP {
+	return &AuthenticatorLDAP{c: c}
+}
+
+func (a *AuthenticatorLDAP) GetID() string {
+	return "ldap"
+}
+
+func (a *AuthenticatorLDAP) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorLDAP) Config(config json.RawMessage) (*AuthenticatorLDAPConfiguration, error) {
+	var c AuthenticatorLDAPConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf(`url "%s" must be an ldap:// or ldaps:// URL`, c.URL))
+	}
+
+	if (c.UserDN == "") == (c.UserSearch == nil) {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("exactly one of user_dn and user_search must be set"))
+	}
+
+	if c.Timeout == "" {
+		c.Timeout = "5s"
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.WithStack(err))
+	}
+	c.timeout = timeout
+
+	if c.Credentials == nil {
+		c.Credentials = new(AuthenticatorLDAPCredentialsIn)
+	}
+	switch c.Credentials.Source {
+	case "":
+		c.Credentials.Source = LDAPCredentialsFromBasic
+	case LDAPCredentialsFromBasic, LDAPCredentialsFromForm:
+	default:
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf("credentials_from.source %s is not supported", c.Credentials.Source))
+	}
+	if c.Credentials.UsernameField == "" {
+		c.Credentials.UsernameField = "username"
+	}
+	if c.Credentials.PasswordField == "" {
+		c.Credentials.PasswordField = "password"
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorLDAP) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	username, password, err := ldapCredentials(r, cf.Credentials)
+	if err != nil {
+		return err
+	} else if username == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	} else if password == "" {
+		// Servers treat binds without a password as anonymous binds which succeed.
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The username or password is invalid."))
+	}
+
+	conn, err := a.dial(cf)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	dn, err := a.userDN(conn, cf, username)
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Bind(dn, password); ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The username or password is invalid.").WithTrace(err))
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+
+	session.Subject = username
+	session.Extra = map[string]interface{}{"dn": dn}
+
+	if cf.GroupSearch == nil {
+		return nil
+	}
+
+	if cf.BindDN != "" {
+		// Search groups with the service account, users may not be allowed to read group memberships.
+		if err := conn.Bind(cf.BindDN, cf.BindPassword); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	filter := strings.NewReplacer("{dn}", ldap.EscapeFilter(dn), "{username}", ldap.EscapeFilter(username)).Replace(cf.GroupSearch.Filter)
+	attribute := cf.GroupSearch.Attribute
+	if attribute == "" {
+		attribute = "cn"
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(cf.GroupSearch.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(cf.timeout/time.Second), false, filter, []string{attribute}, nil))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.GetAttributeValues(attribute)...)
+	}
+	session.Extra["groups"] = groups
+
+	return nil
+}
+
+// dial connects to the server, upgrading the connection using StartTLS if configured.
+func (a *AuthenticatorLDAP) dial(cf *AuthenticatorLDAPConfiguration) (*ldap.Conn, error) {
+	u, _ := url.Parse(cf.URL)
+	host := u.Host
+	if u.Port() == "" {
+		port := "389"
+		if u.Scheme == "ldaps" {
+			port = "636"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	tlsConfig := &tls.Config{ServerName: u.Hostname()}
+	if cf.CAFile != "" {
+		raw, err := ioutil.ReadFile(cf.CAFile)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(raw) {
+			return nil, errors.Errorf("unable to load certificate authorities from %s", cf.CAFile)
+		}
+	}
+
+	var conn *ldap.Conn
+	var err error
+	if u.Scheme == "ldaps" {
+		conn, err = ldap.DialTLS("tcp", host, tlsConfig)
+	} else {
+		conn, err = ldap.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	conn.SetTimeout(cf.timeout)
+
+	if cf.StartTLS && u.Scheme == "ldap" {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return conn, nil
+}
+
+// userDN returns the DN of the user, either from the template or by searching for the user with the service account.
+func (a *AuthenticatorLDAP) userDN(conn *ldap.Conn, cf *AuthenticatorLDAPConfiguration, username string) (string, error) {
+	if cf.UserDN != "" {
+		return strings.Replace(cf.UserDN, "{username}", escapeDNValue(username), -1), nil
+	}
+
+	if cf.BindDN != "" {
+		if err := conn.Bind(cf.BindDN, cf.BindPassword); err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+
+	filter := strings.Replace(cf.UserSearch.Filter, "{username}", ldap.EscapeFilter(username), -1)
+	result, err := conn.Search(ldap.NewSearchRequest(cf.UserSearch.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(cf.timeout/time.Second), false, filter, []string{"1.1"}, nil))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if len(result.Entries) != 1 {
+		return "", errors.WithStack(helper.ErrUnauthorized.WithReason("The username or password is invalid.").WithDebugf("The user search returned %d entries.", len(result.Entries)))
+	}
+	return result.Entries[0].DN, nil
+}
+
+// ldapCredentials returns the username and password of the request. Form bodies are restored after reading them.
+func ldapCredentials(r *http.Request, in *AuthenticatorLDAPCredentialsIn) (string, string, error) {
+	if in.Source == LDAPCredentialsFromBasic {
+		auth := r.Header.Get("Authorization")
+		if len(auth) < 6 || !strings.EqualFold(auth[:6], "basic ") {
+			return "", "", nil
+		}
+
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return "", "", errors.WithStack(helper.ErrUnauthorized.WithReason("The Basic credentials are malformed."))
+		}
+		return username, password, nil
+	}
+
+	if r.Body == nil {
+		return "", "", nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/x-www-form-urlencoded" {
+		return "", "", nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, ldapMaxFormSize+1))
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if len(body) > ldapMaxFormSize {
+		return "", "", errors.WithStack(helper.ErrBadRequest.WithReason("The form is too large."))
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", "", errors.WithStack(helper.ErrBadRequest.WithReasonf("Unable to parse the form: %s", err))
+	}
+	return form.Get(in.UsernameField), form.Get(in.PasswordField), nil
+}
+
+// escapeDNValue escapes the special characters of an attribute value of a DN as defined by RFC 4514.
+func escapeDNValue(value string) string {
+	var b strings.Builder
+	for i, c := range value {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, c),
+			i == 0 && (c == ' ' || c == '#'),
+			i == len(value)-1 && c == ' ':
+			b.WriteRune('\\')
+			b.WriteRune(c)
+		case c == 0:
+			b.WriteString(`\00`)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,253 @@
+package authn_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+// fakeLDAPServer answers simple binds against passwords and searches against entries keyed by their filter.
+type fakeLDAPServer struct {
+	passwords map[string]string
+	entries   map[string][]*ldap.Entry
+}
+
+func (s *fakeLDAPServer) serve(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+
+	return l.Addr().String(), func() { _ = l.Close() }
+}
+
+func (s *fakeLDAPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, password := op.Children[1].Value.(string), op.Children[2].Data.String()
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if expected, ok := s.passwords[dn]; (ok && expected == password) || (dn == "" && password == "") {
+				code = ldap.LDAPResultSuccess
+			}
+			_, _ = conn.Write(fakeLDAPResponse(id, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			for _, entry := range s.entries[filter] {
+				result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+				result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN"))
+				attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+				for _, attribute := range entry.Attributes {
+					a := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+					a.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute.Name, "Type"))
+					values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+					for _, value := range attribute.Values {
+						values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+					}
+					a.AppendChild(values)
+					attributes.AppendChild(a)
+				}
+				result.AppendChild(attributes)
+				_, _ = conn.Write(fakeLDAPMessage(id, result).Bytes())
+			}
+			_, _ = conn.Write(fakeLDAPResponse(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func fakeLDAPMessage(id int64, op *ber.Packet) *ber.Packet {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	message.AppendChild(op)
+	return message
+}
+
+func fakeLDAPResponse(id int64, tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return fakeLDAPMessage(id, op)
+}
+
+func TestAuthenticatorLDAP(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("ldap")
+	require.NoError(t, err)
+	assert.Equal(t, "ldap", a.GetID())
+
+	aliceDN := "uid=alice,ou=people,dc=example,dc=org"
+	server := &fakeLDAPServer{
+		passwords: map[string]string{
+			aliceDN: "alice-secret",
+			"cn=oathkeeper,ou=services,dc=example,dc=org": "service-secret",
+		},
+		entries: map[string][]*ldap.Entry{
+			"(uid=alice)": {ldap.NewEntry(aliceDN, nil)},
+			"(member=" + aliceDN + ")": {
+				ldap.NewEntry("cn=admins,ou=groups,dc=example,dc=org", map[string][]string{"cn": {"admins"}}),
+				ldap.NewEntry("cn=developers,ou=groups,dc=example,dc=org", map[string][]string{"cn": {"developers"}}),
+			},
+		},
+	}
+	addr, stop := server.serve(t)
+	defer stop()
+
+	direct := json.RawMessage(fmt.Sprintf(`{"url":"ldap://%s","user_dn":"uid={username},ou=people,dc=example,dc=org"}`, addr))
+	search := json.RawMessage(fmt.Sprintf(`{
+		"url":"ldap://%s",
+		"bind_dn":"cn=oathkeeper,ou=services,dc=example,dc=org",
+		"bind_password":"service-secret",
+		"user_search":{"base_dn":"ou=people,dc=example,dc=org","filter":"(uid={username})"},
+		"group_search":{"base_dn":"ou=groups,dc=example,dc=org","filter":"(member={dn})"},
+		"credentials_from":{"source":"form","username_field":"user"}
+	}`, addr))
+
+	basic := func(username, password string) *http.Request {
+		r := &http.Request{Header: http.Header{}}
+		r.SetBasicAuth(username, password)
+		return r
+	}
+	form := func(body string) *http.Request {
+		return &http.Request{
+			Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			Body:   ioutil.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         json.RawMessage
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+			expectGroups   []string
+		}{
+			{
+				d:              "should not be responsible without credentials",
+				r:              &http.Request{Header: http.Header{}},
+				config:         direct,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should bind to the DN of the template",
+				r:             basic("alice", "alice-secret"),
+				config:        direct,
+				expectSubject: "alice",
+			},
+			{
+				d:          "should fail with a wrong password",
+				r:          basic("alice", "bob-secret"),
+				config:     direct,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an empty password instead of binding anonymously",
+				r:          basic("alice", ""),
+				config:     direct,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:             "should search the user and the groups with the credentials of the form",
+				r:             form("user=alice&password=alice-secret"),
+				config:        search,
+				expectSubject: "alice",
+				expectGroups:  []string{"admins", "developers"},
+			},
+			{
+				d:          "should fail if the user search finds no entry",
+				r:          form("user=bob&password=bob-secret"),
+				config:     search,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d: "should not be responsible for bodies which are not forms",
+				r: &http.Request{
+					Header: http.Header{"Content-Type": {"application/json"}},
+					Body:   ioutil.NopCloser(strings.NewReader(`{"user":"alice"}`)),
+				},
+				config:         search,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, tc.config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				if tc.expectGroups != nil {
+					assert.Equal(t, tc.expectGroups, session.Extra["groups"])
+				}
+			})
+		}
+	})
+
+	t.Run("method=authenticate/case=the form is passed on to the upstream", func(t *testing.T) {
+		r := form("user=alice&password=alice-secret&remember=true")
+		require.NoError(t, a.Authenticate(r, new(AuthenticationSession), search, nil))
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "user=alice&password=alice-secret&remember=true", string(body))
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorLDAPIsEnabled, true)
+		require.NoError(t, a.Validate(direct))
+		require.NoError(t, a.Validate(search))
+		require.Error(t, a.Validate(json.RawMessage(`{"url":"https://ldap.example.org","user_dn":"uid={username}"}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"url":"ldap://ldap.example.org"}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorLDAPIsEnabled, false)
+		require.Error(t, a.Validate(direct))
+	})
+}
@@ -0,0 +1,153 @@
+package authn
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/stringslice"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/macaroon"
+	"github.com/ory/oathkeeper/pipeline"
+)
+
+// First-party caveats understood by the macaroon authenticator. Each caveat is a condition of the form
+// "<name> <value>", macaroons carrying any other caveat are rejected.
+const (
+	MacaroonCaveatTimeBefore = "time-before"
+	MacaroonCaveatPathPrefix = "path-prefix"
+	MacaroonCaveatMethod     = "method"
+	MacaroonCaveatSubject    = "subject"
+)
+
+type AuthenticatorMacaroonConfiguration struct {
+	RootKeys            []string                    `json:"root_keys"`
+	BearerTokenLocation *helper.BearerTokenLocation `json:"token_from"`
+}
+
+type AuthenticatorMacaroon struct {
+	c configuration.Provider
+}
+
+func NewAuthenticatorMacaroon(c configuration.Provider) *AuthenticatorMacaroon {
+	return &AuthenticatorMacaroon{c: c}
+}
+
+func (a *AuthenticatorMacaroon) GetID() string {
+	return "macaroon"
+}
+
+func (a *AuthenticatorMacaroon) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorMacaroon) Config(config json.RawMessage) (*AuthenticatorMacaroonConfiguration, error) {
+	var c AuthenticatorMacaroonConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorMacaroon) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	if token == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
+
+	m, err := macaroon.Decode(token)
+	if err != nil {
+		// This allows chaining the macaroon authenticator with other bearer token authenticators.
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+
+	if err := a.verify(m, cf.RootKeys); err != nil {
+		return helper.ErrUnauthorized.WithReason(err.Error()).WithTrace(err)
+	}
+
+	subject, err := a.checkCaveats(r, m.Conditions())
+	if err != nil {
+		return err
+	}
+
+	session.Subject = subject
+	session.Extra = map[string]interface{}{
+		"identifier": string(m.ID),
+		"location":   m.Location,
+		"caveats":    m.Conditions(),
+	}
+
+	return nil
+}
+
+// verify accepts the macaroon if it was minted using any of the root keys which allows rotating root keys.
+func (a *AuthenticatorMacaroon) verify(m *macaroon.Macaroon, keys []string) error {
+	err := errors.New("no root key is configured")
+	for _, key := range keys {
+		if err = m.Verify([]byte(key)); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (a *AuthenticatorMacaroon) checkCaveats(r *http.Request, conditions []string) (string, error) {
+	var subject string
+	for _, condition := range conditions {
+		parts := strings.SplitN(condition, " ", 2)
+		if len(parts) != 2 {
+			return "", errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The macaroon caveat "%s" is malformed.`, condition))
+		}
+
+		name, value := parts[0], strings.TrimSpace(parts[1])
+		switch name {
+		case MacaroonCaveatTimeBefore:
+			before, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return "", errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The macaroon caveat "%s" is malformed.`, condition))
+			}
+			if !time.Now().Before(before) {
+				return "", errors.WithStack(helper.ErrUnauthorized.WithReason("The macaroon is expired."))
+			}
+		case MacaroonCaveatPathPrefix:
+			if !strings.HasPrefix(r.URL.Path, value) {
+				return "", errors.WithStack(helper.ErrForbidden.WithReasonf(`The macaroon is restricted to paths starting with "%s".`, value))
+			}
+		case MacaroonCaveatMethod:
+			if !stringslice.Has(strings.Fields(strings.ToUpper(value)), strings.ToUpper(r.Method)) {
+				return "", errors.WithStack(helper.ErrForbidden.WithReasonf(`The macaroon is restricted to the methods "%s".`, value))
+			}
+		case MacaroonCaveatSubject:
+			// Caveats can only restrict a macaroon, so a subject must not be replaced by another one.
+			if subject != "" && subject != value {
+				return "", errors.WithStack(helper.ErrUnauthorized.WithReason("The macaroon contains conflicting subject caveats."))
+			}
+			subject = value
+		default:
+			return "", errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The macaroon caveat "%s" is not supported.`, name))
+		}
+	}
+
+	if subject == "" {
+		return "", errors.WithStack(helper.ErrUnauthorized.WithReason("The macaroon does not contain a subject caveat."))
+	}
+
+	return subject, nil
+}
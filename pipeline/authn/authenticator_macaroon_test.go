@@ -0,0 +1,139 @@
+package authn_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/macaroon"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorMacaroon(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("macaroon")
+	require.NoError(t, err)
+	assert.Equal(t, "macaroon", a.GetID())
+
+	gen := func(key string, caveats ...string) string {
+		m := macaroon.New([]byte(key), []byte("macaroon-id"), "https://my-service/")
+		for _, c := range caveats {
+			m.AddFirstPartyCaveat(c)
+		}
+		return m.Encode()
+	}
+	request := func(method, path, token string) *http.Request {
+		return &http.Request{Method: method, URL: &url.URL{Path: path}, Header: http.Header{"Authorization": {"Bearer " + token}}}
+	}
+
+	inAnHour := "time-before " + time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	anHourAgo := "time-before " + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	config := json.RawMessage(`{"root_keys":["new-key","old-key"]}`)
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+		}{
+			{
+				d:              "should not be responsible without token",
+				r:              &http.Request{Header: http.Header{}},
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:              "should not be responsible for other tokens",
+				r:              request("GET", "/", "some.jwt.token"),
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass with all caveats satisfied",
+				r:             request("GET", "/users/alice/profile", gen("new-key", "subject alice", inAnHour, "path-prefix /users/alice/", "method GET HEAD")),
+				expectSubject: "alice",
+			},
+			{
+				d:             "should pass with a rotated root key",
+				r:             request("POST", "/", gen("old-key", "subject alice")),
+				expectSubject: "alice",
+			},
+			{
+				d:          "should fail with an unknown root key",
+				r:          request("GET", "/", gen("unknown-key", "subject alice")),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the macaroon is expired",
+				r:          request("GET", "/", gen("new-key", "subject alice", anHourAgo)),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail because the path does not match",
+				r:          request("GET", "/users/bob/profile", gen("new-key", "subject alice", "path-prefix /users/alice/")),
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the method does not match",
+				r:          request("DELETE", "/", gen("new-key", "subject alice", "method GET HEAD")),
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail without subject caveat",
+				r:          request("GET", "/", gen("new-key", inAnHour)),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with conflicting subject caveats",
+				r:          request("GET", "/", gen("new-key", "subject alice", "subject bob")),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with unknown caveats",
+				r:          request("GET", "/", gen("new-key", "subject alice", "account = 3735928559")),
+				expectCode: http.StatusUnauthorized,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				assert.Equal(t, "macaroon-id", session.Extra["identifier"])
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorMacaroonIsEnabled, true)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(json.RawMessage(`{"root_keys":[]}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorMacaroonIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
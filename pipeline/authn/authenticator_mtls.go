@@ -0,0 +1,262 @@
+package authn
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/go-convenience/stringslice"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/x"
+)
+
+// Fields of the client certificate the subject of the session can be taken from.
+const (
+	MTLSSubjectFromCommonName = "common_name"
+	MTLSSubjectFromDNSSAN     = "dns_san"
+	MTLSSubjectFromURISAN     = "uri_san"
+	MTLSSubjectFromEmailSAN   = "email_san"
+)
+
+type AuthenticatorMTLSConfiguration struct {
+	CAFile          string   `json:"ca_file"`
+	SubjectFrom     string   `json:"subject_from"`
+	AllowedSubjects []string `json:"allowed_subjects"`
+
+	// CertificateHeader, if set, is the header a terminating proxy forwards the client certificate in. It is only read
+	// if the request was sent by a trusted proxy.
+	CertificateHeader string `json:"certificate_header"`
+}
+
+// AuthenticatorMTLS authenticates clients using TLS client certificates, either presented to ORY Oathkeeper directly
+// or forwarded by a trusted proxy which terminates TLS.
+type AuthenticatorMTLS struct {
+	c configuration.Provider
+
+	sync.Mutex
+	pools map[string]*x509.CertPool
+}
+
+func NewAuthenticatorMTLS(c configuration.Provider) *AuthenticatorMTLS {
+	return &AuthenticatorMTLS{c: c, pools: map[string]*x509.CertPool{}}
+}
+
+func (a *AuthenticatorMTLS) GetID() string {
+	return "mtls"
+}
+
+func (a *AuthenticatorMTLS) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorMTLS) Config(config json.RawMessage) (*AuthenticatorMTLSConfiguration, error) {
+	var c AuthenticatorMTLSConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	switch c.SubjectFrom {
+	case "":
+		c.SubjectFrom = MTLSSubjectFromCommonName
+	case MTLSSubjectFromCommonName, MTLSSubjectFromDNSSAN, MTLSSubjectFromURISAN, MTLSSubjectFromEmailSAN:
+	default:
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf("subject_from %s is not supported", c.SubjectFrom))
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorMTLS) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	chain, err := a.certificates(r, cf)
+	if err != nil {
+		return err
+	} else if len(chain) == 0 {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+
+	roots, err := a.pool(cf.CAFile)
+	if err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+
+	cert := chain[0]
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf("The client certificate is not trusted: %s", err).WithTrace(err))
+	}
+
+	subject := mtlsSubject(cert, cf.SubjectFrom)
+	if subject == "" {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf("The client certificate does not contain a %s.", cf.SubjectFrom))
+	}
+
+	if len(cf.AllowedSubjects) > 0 && !stringslice.Has(cf.AllowedSubjects, subject) {
+		return errors.WithStack(helper.ErrForbidden.WithReasonf("Subject %s is not allowed.", subject))
+	}
+
+	uris := make([]string, len(cert.URIs))
+	for k, u := range cert.URIs {
+		uris[k] = u.String()
+	}
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	session.Subject = subject
+	session.Extra = map[string]interface{}{
+		"common_name":     cert.Subject.CommonName,
+		"dns_names":       cert.DNSNames,
+		"uris":            uris,
+		"email_addresses": cert.EmailAddresses,
+		"issuer":          cert.Issuer.String(),
+		"serial_number":   cert.SerialNumber.String(),
+		"fingerprint":     hex.EncodeToString(fingerprint[:]),
+		"not_after":       cert.NotAfter.UTC().Format(time.RFC3339),
+	}
+
+	return nil
+}
+
+// certificates returns the certificate chain of the client, leaf first. Certificates of the TLS connection take
+// precedence over certificates forwarded by a trusted proxy.
+func (a *AuthenticatorMTLS) certificates(r *http.Request, cf *AuthenticatorMTLSConfiguration) ([]*x509.Certificate, error) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates, nil
+	}
+
+	if cf.CertificateHeader == "" {
+		return nil, nil
+	}
+
+	value := r.Header.Get(cf.CertificateHeader)
+	if value == "" {
+		return nil, nil
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip == nil || !x.ContainsIP(a.c.TrustedProxies(), ip) {
+		return nil, errors.WithStack(helper.ErrUnauthorized.WithReasonf("The client certificate header %s was not sent by a trusted proxy.", cf.CertificateHeader))
+	}
+
+	chain, err := parseForwardedCertificates(value)
+	if err != nil {
+		return nil, errors.WithStack(helper.ErrUnauthorized.WithReasonf("Unable to parse the client certificate header %s: %s", cf.CertificateHeader, err).WithTrace(err))
+	}
+	return chain, nil
+}
+
+// parseForwardedCertificates parses a certificate chain forwarded by a proxy, either as URL-encoded PEM (e.g. the
+// $ssl_client_escaped_cert variable of NGINX) or as base64-encoded DER.
+func parseForwardedCertificates(value string) ([]*x509.Certificate, error) {
+	if unescaped, err := url.QueryUnescape(value); err == nil && strings.Contains(unescaped, "-----BEGIN") {
+		var chain []*x509.Certificate
+		rest := []byte(unescaped)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			chain = append(chain, cert)
+		}
+		if len(chain) == 0 {
+			return nil, errors.New("the header does not contain a PEM-encoded certificate")
+		}
+		return chain, nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	chain, err := x509.ParseCertificates(der)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return chain, nil
+}
+
+// mtlsSubject returns the field of the certificate the subject is taken from, using the first value of subject
+// alternative names.
+func mtlsSubject(cert *x509.Certificate, from string) string {
+	switch from {
+	case MTLSSubjectFromDNSSAN:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case MTLSSubjectFromURISAN:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case MTLSSubjectFromEmailSAN:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	default:
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
+// pool returns the certificate authorities of the file which are cached per file.
+func (a *AuthenticatorMTLS) pool(caFile string) (*x509.CertPool, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if p, ok := a.pools[caFile]; ok {
+		return p, nil
+	}
+
+	raw, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	p := x509.NewCertPool()
+	if !p.AppendCertsFromPEM(raw) {
+		return nil, errors.Errorf("unable to load certificate authorities from %s", caFile)
+	}
+	a.pools[caFile] = p
+	return p, nil
+}
@@ -0,0 +1,211 @@
+package authn_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorMTLS(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("mtls")
+	require.NoError(t, err)
+	assert.Equal(t, "mtls", a.GetID())
+
+	newCA := func() (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "Test CA"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		raw, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+		require.NoError(t, err)
+		ca, err := x509.ParseCertificate(raw)
+		require.NoError(t, err)
+		return ca, key
+	}
+	issue := func(ca *x509.Certificate, caKey *ecdsa.PrivateKey, usage x509.ExtKeyUsage) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		u, _ := url.Parse("spiffe://cluster.local/ns/shop/sa/checkout")
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "checkout"},
+			DNSNames:     []string{"checkout.shop.svc"},
+			URIs:         []*url.URL{u},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		raw, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(raw)
+		require.NoError(t, err)
+		return cert
+	}
+
+	ca, caKey := newCA()
+	otherCA, otherCAKey := newCA()
+	client := issue(ca, caKey, x509.ExtKeyUsageClientAuth)
+	server := issue(ca, caKey, x509.ExtKeyUsageServerAuth)
+	untrusted := issue(otherCA, otherCAKey, x509.ExtKeyUsageClientAuth)
+
+	caFile, err := ioutil.TempFile("", "mtls-ca-*.pem")
+	require.NoError(t, err)
+	defer os.Remove(caFile.Name())
+	require.NoError(t, pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	require.NoError(t, caFile.Close())
+
+	withTLS := func(certs ...*x509.Certificate) *http.Request {
+		return &http.Request{RemoteAddr: "198.51.100.1:1234", Header: http.Header{}, TLS: &tls.ConnectionState{PeerCertificates: certs}}
+	}
+	withHeader := func(remote, value string) *http.Request {
+		return &http.Request{RemoteAddr: remote, Header: http.Header{"X-Ssl-Client-Cert": {value}}}
+	}
+	escapedPEM := url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: client.Raw})))
+
+	config := func(extra string) json.RawMessage {
+		return json.RawMessage(fmt.Sprintf(`{"ca_file":%q,"certificate_header":"X-SSL-Client-Cert"%s}`, caFile.Name(), extra))
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         json.RawMessage
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+		}{
+			{
+				d:              "should not be responsible without certificate",
+				r:              &http.Request{RemoteAddr: "127.0.0.1:1234", Header: http.Header{}},
+				config:         config(""),
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass with a certificate of the connection",
+				r:             withTLS(client),
+				config:        config(""),
+				expectSubject: "checkout",
+			},
+			{
+				d:             "should take the subject from the URI SAN",
+				r:             withTLS(client),
+				config:        config(`,"subject_from":"uri_san"`),
+				expectSubject: "spiffe://cluster.local/ns/shop/sa/checkout",
+			},
+			{
+				d:          "should fail because the DNS SAN is not allowed",
+				r:          withTLS(client),
+				config:     config(`,"subject_from":"dns_san","allowed_subjects":["orders.shop.svc"]`),
+				expectCode: http.StatusForbidden,
+			},
+			{
+				d:          "should fail because the email SAN is missing",
+				r:          withTLS(client),
+				config:     config(`,"subject_from":"email_san"`),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with a certificate of another certificate authority",
+				r:          withTLS(untrusted),
+				config:     config(""),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with a server certificate",
+				r:          withTLS(server),
+				config:     config(""),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:             "should pass with a URL-encoded PEM certificate forwarded by a trusted proxy",
+				r:             withHeader("127.0.0.1:1234", escapedPEM),
+				config:        config(""),
+				expectSubject: "checkout",
+			},
+			{
+				d:             "should pass with a base64-encoded DER certificate forwarded by a trusted proxy",
+				r:             withHeader("10.0.0.1:1234", base64.StdEncoding.EncodeToString(client.Raw)),
+				config:        config(""),
+				expectSubject: "checkout",
+			},
+			{
+				d:          "should fail with a certificate forwarded by an untrusted client",
+				r:          withHeader("198.51.100.1:1234", escapedPEM),
+				config:     config(""),
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with a malformed forwarded certificate",
+				r:          withHeader("127.0.0.1:1234", "not-a-certificate"),
+				config:     config(""),
+				expectCode: http.StatusUnauthorized,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, tc.config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				assert.Equal(t, "checkout", session.Extra["common_name"])
+				assert.Equal(t, "2", session.Extra["serial_number"])
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorMTLSIsEnabled, true)
+		require.NoError(t, a.Validate(config("")))
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+		require.Error(t, a.Validate(config(`,"subject_from":"organization"`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorMTLSIsEnabled, false)
+		require.Error(t, a.Validate(config("")))
+	})
+}
@@ -4,32 +4,51 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/ory/go-convenience/stringslice"
+	"github.com/ory/herodot"
 	"github.com/ory/x/httpx"
 
+	"github.com/ory/oathkeeper/credentials"
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/helper"
 	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/revocation"
+	"github.com/ory/oathkeeper/x"
 )
 
 type AuthenticatorOAuth2IntrospectionConfiguration struct {
-	Scopes                      []string                                              `json:"required_scope"`
-	Audience                    []string                                              `json:"target_audience"`
-	Issuers                     []string                                              `json:"trusted_issuers"`
-	PreAuth                     *AuthenticatorOAuth2IntrospectionPreAuthConfiguration `json:"pre_authorization"`
-	ScopeStrategy               string                                                `json:"scope_strategy"`
-	IntrospectionURL            string                                                `json:"introspection_url"`
-	BearerTokenLocation         *helper.BearerTokenLocation                           `json:"token_from"`
-	IntrospectionRequestHeaders map[string]string                                     `json:"introspection_request_headers"`
-	Retry                       *AuthenticatorOAuth2IntrospectionRetryConfiguration   `json:"retry"`
+	Scopes                      []string                                                     `json:"required_scope"`
+	Audience                    []string                                                     `json:"target_audience"`
+	Issuers                     []string                                                     `json:"trusted_issuers"`
+	PreAuth                     *AuthenticatorOAuth2IntrospectionPreAuthConfiguration        `json:"pre_authorization"`
+	ScopeStrategy               string                                                       `json:"scope_strategy"`
+	IntrospectionURL            string                                                       `json:"introspection_url"`
+	BearerTokenLocation         *helper.BearerTokenLocation                                  `json:"token_from"`
+	IntrospectionRequestHeaders map[string]string                                            `json:"introspection_request_headers"`
+	Retry                       *AuthenticatorOAuth2IntrospectionRetryConfiguration          `json:"retry"`
+	MaxTokenAge                 string                                                       `json:"max_token_age"`
+	Cache                       *AuthenticatorOAuth2IntrospectionCacheConfiguration          `json:"cache"`
+	DPoP                        *DPoPConfiguration                                           `json:"dpop"`
+	TLS                         *x.TLSClientConfig                                           `json:"tls"`
+	CircuitBreaker              *AuthenticatorOAuth2IntrospectionCircuitBreakerConfiguration `json:"circuit_breaker"`
+	OnError                     string                                                       `json:"on_error"`
+	RequiredClaims              map[string]ClaimAssertion                                    `json:"required_claims"`
+	JWTResponse                 *AuthenticatorOAuth2IntrospectionJWTResponseConfiguration    `json:"jwt_response"`
 }
 
 type AuthenticatorOAuth2IntrospectionPreAuthConfiguration struct {
@@ -45,16 +64,69 @@ type AuthenticatorOAuth2IntrospectionRetryConfiguration struct {
 	MaxWait string `json:"give_up_after"`
 }
 
+type AuthenticatorOAuth2IntrospectionCacheConfiguration struct {
+	Enabled bool   `json:"enabled"`
+	TTL     string `json:"ttl"`
+	MaxSize int64  `json:"max_size"`
+}
+
+type AuthenticatorOAuth2IntrospectionCircuitBreakerConfiguration struct {
+	Enabled          bool   `json:"enabled"`
+	FailureThreshold int    `json:"failure_threshold"`
+	OpenDuration     string `json:"open_duration"`
+}
+
+// AuthenticatorOAuth2IntrospectionJWTResponseConfiguration configures the verification of JWT-secured introspection
+// responses (RFC 9701).
+type AuthenticatorOAuth2IntrospectionJWTResponseConfiguration struct {
+	Enabled           bool     `json:"enabled"`
+	JWKSURLs          []string `json:"jwks_urls"`
+	AllowedAlgorithms []string `json:"allowed_algorithms"`
+	Issuer            string   `json:"issuer"`
+	Audience          string   `json:"audience"`
+}
+
+// introspectionJWTContentType is the media type of JWT-secured introspection responses, see
+// https://www.rfc-editor.org/rfc/rfc9701#section-5
+const introspectionJWTContentType = "application/token-introspection+jwt"
+
+const (
+	// introspectionOnErrorDeny denies requests if the introspection endpoint is unavailable.
+	introspectionOnErrorDeny = "deny"
+
+	// introspectionOnErrorAllowCached accepts the cached result of a token, even if it is older than the TTL of the
+	// cache, as long as the token has not expired.
+	introspectionOnErrorAllowCached = "allow_cached"
+
+	// introspectionOnErrorAllow accepts requests without a subject.
+	introspectionOnErrorAllow = "allow"
+)
+
+// ErrIntrospectionUnavailable is returned if the introspection endpoint can not be reached, responds with a server
+// error, or its circuit breaker is open.
+var ErrIntrospectionUnavailable = &herodot.DefaultError{
+	ErrorField:  "The token introspection endpoint is unavailable, try again later",
+	CodeField:   http.StatusServiceUnavailable,
+	StatusField: http.StatusText(http.StatusServiceUnavailable),
+}
+
 type AuthenticatorOAuth2Introspection struct {
 	c configuration.Provider
+	r AuthenticatorJWTRegistry
+
+	client   *http.Client
+	flights  singleflight.Group
+	dpop     *dpopValidator
+	breakers *circuitBreakers
 
-	client *http.Client
+	tokenCache     *ristretto.Cache
+	tokenCacheLock sync.Mutex
 }
 
-func NewAuthenticatorOAuth2Introspection(c configuration.Provider) *AuthenticatorOAuth2Introspection {
+func NewAuthenticatorOAuth2Introspection(c configuration.Provider, r AuthenticatorJWTRegistry) *AuthenticatorOAuth2Introspection {
 	var rt http.RoundTripper
 
-	return &AuthenticatorOAuth2Introspection{c: c, client: httpx.NewResilientClientLatencyToleranceSmall(rt)}
+	return &AuthenticatorOAuth2Introspection{c: c, r: r, client: httpx.NewResilientClientLatencyToleranceSmall(rt), dpop: newDPoPValidator(), breakers: newCircuitBreakers()}
 }
 
 func (a *AuthenticatorOAuth2Introspection) GetID() string {
@@ -71,48 +143,42 @@ type AuthenticatorOAuth2IntrospectionResult struct {
 	Issuer    string                 `json:"iss"`
 	ClientID  string                 `json:"client_id,omitempty"`
 	Scope     string                 `json:"scope,omitempty"`
+	IssuedAt  int64                  `json:"iat,omitempty"`
+	ExpiresAt int64                  `json:"exp,omitempty"`
+
+	// Confirmation is set if the token is bound to a key, e.g. by DPoP.
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+
+	// Raw is the introspection response, which required claims are asserted against.
+	Raw json.RawMessage `json:"-"`
+}
+
+type oauth2IntrospectionCacheContainer struct {
+	ExpiresAt time.Time
+	Key       string
+	Result    AuthenticatorOAuth2IntrospectionResult
 }
 
 func (a *AuthenticatorOAuth2Introspection) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
-	var i AuthenticatorOAuth2IntrospectionResult
 	cf, err := a.Config(config)
 	if err != nil {
 		return err
 	}
 
-	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	token := tokenFromRequest(r, cf.BearerTokenLocation, cf.DPoP)
 	if token == "" {
 		return errors.WithStack(ErrAuthenticatorNotResponsible)
 	}
-
-	body := url.Values{"token": {token}}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
 
 	ss := a.c.ToScopeStrategy(cf.ScopeStrategy, "authenticators.oauth2_introspection.scope_strategy")
-	if ss == nil {
-		body.Add("scope", strings.Join(cf.Scopes, " "))
-	}
 
-	introspectReq, err := http.NewRequest(http.MethodPost, cf.IntrospectionURL, strings.NewReader(body.Encode()))
-	if err != nil {
-		return errors.WithStack(err)
-	}
-	for key, value := range cf.IntrospectionRequestHeaders {
-		introspectReq.Header.Set(key, value)
-	}
-	// set/override the content-type header
-	introspectReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := a.client.Do(introspectReq)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("Introspection returned status code %d but expected %d", resp.StatusCode, http.StatusOK)
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&i); err != nil {
-		return errors.WithStack(err)
+	i, err := a.introspect(cf, token, introspectionBody(token, cf.Scopes, ss == nil))
+	if errors.Cause(err) == ErrIntrospectionUnavailable && cf.OnError == introspectionOnErrorAllow {
+		session.Subject = ""
+		return nil
+	} else if err != nil {
+		return err
 	}
 
 	if len(i.TokenType) > 0 && i.TokenType != "access_token" {
@@ -143,10 +209,32 @@ func (a *AuthenticatorOAuth2Introspection) Authenticate(r *http.Request, session
 		}
 	}
 
-	if len(i.Extra) == 0 {
-		i.Extra = map[string]interface{}{}
+	var issuedAt time.Time
+	if i.IssuedAt > 0 {
+		issuedAt = time.Unix(i.IssuedAt, 0)
 	}
 
+	if err := validateTokenAge(cf.MaxTokenAge, issuedAt); err != nil {
+		return err
+	}
+
+	if err := validateClaimAssertions(cf.RequiredClaims, i.Raw); err != nil {
+		return err
+	}
+
+	if cf.DPoP.isEnabled() {
+		if err := a.dpop.validate(r, cf.DPoP, token, i.Confirmation); err != nil {
+			return err
+		}
+	}
+
+	// The result may be shared with concurrent requests for the same token, so the extra claims are copied.
+	extra := make(map[string]interface{}, len(i.Extra)+3)
+	for k, v := range i.Extra {
+		extra[k] = v
+	}
+	i.Extra = extra
+
 	i.Extra["username"] = i.Username
 	i.Extra["client_id"] = i.ClientID
 	i.Extra["scope"] = i.Scope
@@ -157,6 +245,301 @@ func (a *AuthenticatorOAuth2Introspection) Authenticate(r *http.Request, session
 	return nil
 }
 
+// introspect sends the introspection request. Identical concurrent requests, e.g. a burst of requests carrying the
+// same token, are collapsed into one request whose result is shared. If the cache is enabled, results of active
+// tokens are cached and subsequent requests carrying the same token are not sent at all. If the circuit breaker is
+// enabled, no requests are sent while the endpoint is failing and ErrIntrospectionUnavailable is returned instead.
+func (a *AuthenticatorOAuth2Introspection) introspect(cf *AuthenticatorOAuth2IntrospectionConfiguration, token, body string) (AuthenticatorOAuth2IntrospectionResult, error) {
+	key := []string{cf.IntrospectionURL, body}
+	if cf.PreAuth != nil && cf.PreAuth.Enabled {
+		key = append(key, cf.PreAuth.TokenURL, cf.PreAuth.ClientID, cf.PreAuth.ClientSecret, strings.Join(cf.PreAuth.Scope, " "))
+	}
+	headers := make([]string, 0, len(cf.IntrospectionRequestHeaders))
+	for name, value := range cf.IntrospectionRequestHeaders {
+		headers = append(headers, name+": "+value)
+	}
+	sort.Strings(headers)
+	key = append(key, headers...)
+	if jr := cf.JWTResponse; jr != nil && jr.Enabled {
+		key = append(key, "jwt_response", jr.Issuer, jr.Audience, strings.Join(jr.AllowedAlgorithms, " "), strings.Join(jr.JWKSURLs, " "))
+	}
+	flight := x.FlightKey(key...)
+
+	ttl, cache, err := a.cache(cf)
+	if err != nil {
+		return AuthenticatorOAuth2IntrospectionResult{}, err
+	}
+
+	hash := revocation.TokenHash(token)
+	var cached *oauth2IntrospectionCacheContainer
+	if cache != nil {
+		if item, found := cache.Get(hash); found {
+			// The token may be introspected with different settings, e.g. by another endpoint, which is a cache miss.
+			if container := item.(*oauth2IntrospectionCacheContainer); container.Key == flight {
+				if container.ExpiresAt.After(time.Now()) {
+					return container.Result, nil
+				}
+				cached = container
+			}
+		}
+	}
+
+	i, err := a.introspectRemote(cf, flight, body)
+	if errors.Cause(err) == ErrIntrospectionUnavailable && cf.OnError == introspectionOnErrorAllowCached &&
+		cached != nil && (cached.Result.ExpiresAt == 0 || time.Unix(cached.Result.ExpiresAt, 0).After(time.Now())) {
+		return cached.Result, nil
+	} else if err != nil {
+		return AuthenticatorOAuth2IntrospectionResult{}, err
+	}
+
+	if cache != nil && i.Active {
+		expiresAt := time.Now().Add(ttl)
+		if i.ExpiresAt > 0 && time.Unix(i.ExpiresAt, 0).Before(expiresAt) {
+			expiresAt = time.Unix(i.ExpiresAt, 0)
+		}
+		cache.Set(hash, &oauth2IntrospectionCacheContainer{ExpiresAt: expiresAt, Key: flight, Result: i}, 1)
+	}
+
+	return i, nil
+}
+
+// introspectRemote sends the introspection request, unless the circuit of the introspection endpoint is open.
+// Failures to reach the endpoint and server errors are returned as ErrIntrospectionUnavailable.
+func (a *AuthenticatorOAuth2Introspection) introspectRemote(cf *AuthenticatorOAuth2IntrospectionConfiguration, flight, body string) (AuthenticatorOAuth2IntrospectionResult, error) {
+	client, err := a.httpClient(cf)
+	if err != nil {
+		return AuthenticatorOAuth2IntrospectionResult{}, err
+	}
+
+	breaker := cf.CircuitBreaker
+	if breaker != nil && breaker.Enabled && !a.breakers.allow(cf.IntrospectionURL, breaker.FailureThreshold, time.Now()) {
+		return AuthenticatorOAuth2IntrospectionResult{}, errors.Wrap(ErrIntrospectionUnavailable, "the circuit breaker of the introspection endpoint is open")
+	}
+
+	v, err, _ := a.flights.Do(flight, func() (interface{}, error) {
+		var i AuthenticatorOAuth2IntrospectionResult
+
+		ctx, cancel := x.WithOptionalTimeout(context.Background(), a.c.RemoteResponseTimeout())
+		defer cancel()
+
+		introspectReq, err := http.NewRequest(http.MethodPost, cf.IntrospectionURL, strings.NewReader(body))
+		if err != nil {
+			return i, errors.WithStack(err)
+		}
+		introspectReq = introspectReq.WithContext(ctx)
+		for name, value := range cf.IntrospectionRequestHeaders {
+			introspectReq.Header.Set(name, value)
+		}
+		// set/override the content-type header
+		introspectReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cf.JWTResponse != nil && cf.JWTResponse.Enabled {
+			introspectReq.Header.Set("Accept", introspectionJWTContentType)
+		}
+		resp, err := client.Do(introspectReq)
+		if err != nil {
+			a.recordIntrospection(cf, true)
+			return i, errors.Wrap(ErrIntrospectionUnavailable, err.Error())
+		}
+		defer resp.Body.Close()
+
+		unavailable := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+		a.recordIntrospection(cf, unavailable)
+
+		if unavailable {
+			return i, errors.Wrapf(ErrIntrospectionUnavailable, "Introspection returned status code %d but expected %d", resp.StatusCode, http.StatusOK)
+		} else if resp.StatusCode != http.StatusOK {
+			return i, errors.Errorf("Introspection returned status code %d but expected %d", resp.StatusCode, http.StatusOK)
+		}
+
+		raw, err := a.decodeIntrospectionResponse(ctx, cf, resp)
+		if err != nil {
+			return i, err
+		}
+		if err := json.Unmarshal(raw, &i); err != nil {
+			return i, errors.WithStack(err)
+		}
+		i.Raw = raw
+		return i, nil
+	})
+	if err != nil {
+		return AuthenticatorOAuth2IntrospectionResult{}, err
+	}
+
+	return v.(AuthenticatorOAuth2IntrospectionResult), nil
+}
+
+// decodeIntrospectionResponse returns the introspection response as JSON. If JWT-secured responses are enabled, the
+// response must be a JSON Web Token signed by the authorization server, and the introspection response is taken from
+// its "token_introspection" claim once the signature is verified.
+func (a *AuthenticatorOAuth2Introspection) decodeIntrospectionResponse(ctx context.Context, cf *AuthenticatorOAuth2IntrospectionConfiguration, resp *http.Response) (json.RawMessage, error) {
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	jr := cf.JWTResponse
+	if jr == nil || !jr.Enabled {
+		if contentType == introspectionJWTContentType {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("The introspection response is a JSON Web Token, enable jwt_response to verify it."))
+		}
+
+		var raw json.RawMessage
+		if err := x.DecodeJSONResponse(resp, a.c.RemoteResponseMaxBodySize(), &raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	}
+
+	if contentType != introspectionJWTContentType {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Expected the introspection response to be of type "%s" but got "%s".`, introspectionJWTContentType, contentType))
+	}
+
+	body, err := x.ReadResponse(resp, a.c.RemoteResponseMaxBodySize())
+	if err != nil {
+		return nil, err
+	}
+
+	jwksu, err := a.c.ParseURLs(jr.JWKSURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithms := jr.AllowedAlgorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{"RS256"}
+	}
+
+	v := &credentials.ValidationContext{Algorithms: algorithms, KeyURLs: jwksu}
+	if jr.Issuer != "" {
+		v.Issuers = []string{jr.Issuer}
+	}
+	if jr.Audience != "" {
+		v.Audiences = []string{jr.Audience}
+	}
+
+	token, err := a.r.CredentialsVerifier().Verify(ctx, strings.TrimSpace(string(body)), v)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The signature of the introspection response is invalid: %s", err).WithTrace(err))
+	}
+
+	// The type prevents other JSON Web Tokens of the authorization server from being used as introspection responses.
+	if typ, _ := token.Header["typ"].(string); !strings.EqualFold(strings.TrimPrefix(strings.ToLower(typ), "application/"), "token-introspection+jwt") {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Expected the introspection response to be of type "token-introspection+jwt" but got "%s".`, typ))
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Expected JSON Web Token claims to be of type jwt.MapClaims but got: %T", token.Claims))
+	}
+
+	introspection, ok := claims["token_introspection"].(map[string]interface{})
+	if !ok {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason(`The introspection response does not contain the "token_introspection" claim.`))
+	}
+
+	raw, err := json.Marshal(introspection)
+	return raw, errors.WithStack(err)
+}
+
+func (a *AuthenticatorOAuth2Introspection) recordIntrospection(cf *AuthenticatorOAuth2IntrospectionConfiguration, failed bool) {
+	breaker := cf.CircuitBreaker
+	if breaker == nil || !breaker.Enabled {
+		return
+	}
+
+	// The duration is validated when the configuration is loaded.
+	openFor, _ := time.ParseDuration(breaker.OpenDuration)
+	a.breakers.record(cf.IntrospectionURL, failed, breaker.FailureThreshold, openFor, time.Now())
+}
+
+// httpClient returns the client sending the introspection request. With pre-authorization, the client is created
+// with the configuration, including the TLS settings.
+func (a *AuthenticatorOAuth2Introspection) httpClient(cf *AuthenticatorOAuth2IntrospectionConfiguration) (*http.Client, error) {
+	if cf.TLS.IsZero() || (cf.PreAuth != nil && cf.PreAuth.Enabled) {
+		return a.client, nil
+	}
+
+	transport, err := x.TLSTransport(cf.TLS)
+	if err != nil {
+		return nil, err
+	}
+	return httpx.NewResilientClientLatencyToleranceSmall(transport), nil
+}
+
+// cache returns the TTL and the cache of introspection results, or nil if caching is disabled. The cache is shared
+// by all access rules and created on first use, so its size should be set in the global configuration.
+func (a *AuthenticatorOAuth2Introspection) cache(cf *AuthenticatorOAuth2IntrospectionConfiguration) (time.Duration, *ristretto.Cache, error) {
+	if cf.Cache == nil || !cf.Cache.Enabled {
+		return 0, nil, nil
+	}
+
+	ttl, err := time.ParseDuration(cf.Cache.TTL)
+	if err != nil {
+		return 0, nil, errors.WithStack(err)
+	} else if ttl <= 0 {
+		return 0, nil, nil
+	}
+
+	a.tokenCacheLock.Lock()
+	defer a.tokenCacheLock.Unlock()
+
+	if a.tokenCache == nil {
+		cache, err := ristretto.NewCache(&ristretto.Config{
+			NumCounters: cf.Cache.MaxSize * 10,
+			MaxCost:     cf.Cache.MaxSize,
+			BufferItems: 64,
+		})
+		if err != nil {
+			return 0, nil, errors.WithStack(err)
+		}
+		a.tokenCache = cache
+	}
+
+	return ttl, a.tokenCache, nil
+}
+
+// HealthCheck returns an error while the circuit breaker of an introspection endpoint is open.
+func (a *AuthenticatorOAuth2Introspection) HealthCheck() error {
+	if open := a.breakers.open(); len(open) > 0 {
+		return errors.Errorf("the circuit breaker of the introspection endpoint %s is open", strings.Join(open, ", "))
+	}
+	return nil
+}
+
+// InvalidateToken implements the revocation.TokenInvalidator interface by discarding the cached introspection result
+// of the token.
+func (a *AuthenticatorOAuth2Introspection) InvalidateToken(hash string) {
+	a.tokenCacheLock.Lock()
+	defer a.tokenCacheLock.Unlock()
+
+	if a.tokenCache != nil {
+		a.tokenCache.Del(hash)
+	}
+}
+
+// Invalidate implements the revocation.Invalidator interface. The cached results are not indexed by subject or
+// session, so all of them are discarded.
+func (a *AuthenticatorOAuth2Introspection) Invalidate(_ revocation.Event) {
+	a.tokenCacheLock.Lock()
+	defer a.tokenCacheLock.Unlock()
+
+	if a.tokenCache != nil {
+		a.tokenCache.Clear()
+	}
+}
+
+// introspectionBody encodes the form of the introspection request like url.Values would, using a pooled buffer. The
+// body is copied into a string because the transport may still read the body after the response was received.
+func introspectionBody(token string, scopes []string, withScope bool) string {
+	b := x.AcquireBuffer()
+	defer x.ReleaseBuffer(b)
+
+	if withScope {
+		b.WriteString("scope=")
+		b.WriteString(url.QueryEscape(strings.Join(scopes, " ")))
+		b.WriteByte('&')
+	}
+	b.WriteString("token=")
+	b.WriteString(url.QueryEscape(token))
+	return b.String()
+}
+
 func (a *AuthenticatorOAuth2Introspection) Validate(config json.RawMessage) error {
 	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
 		return NewErrAuthenticatorNotEnabled(a)
@@ -172,6 +555,41 @@ func (a *AuthenticatorOAuth2Introspection) Config(config json.RawMessage) (*Auth
 		return nil, NewErrAuthenticatorMisconfigured(a, err)
 	}
 
+	if c.Cache != nil {
+		if c.Cache.TTL == "" {
+			c.Cache.TTL = "1m"
+		}
+		if c.Cache.MaxSize <= 0 {
+			c.Cache.MaxSize = 10000
+		}
+	}
+
+	for _, assertion := range c.RequiredClaims {
+		if err := assertion.validate(); err != nil {
+			return nil, NewErrAuthenticatorMisconfigured(a, err)
+		}
+	}
+
+	if c.JWTResponse != nil && c.JWTResponse.Enabled && len(c.JWTResponse.JWKSURLs) == 0 {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("jwt_response requires at least one JSON Web Key Set URL"))
+	}
+
+	if c.OnError == "" {
+		c.OnError = introspectionOnErrorDeny
+	}
+
+	if c.CircuitBreaker != nil {
+		if c.CircuitBreaker.FailureThreshold <= 0 {
+			c.CircuitBreaker.FailureThreshold = 5
+		}
+		if c.CircuitBreaker.OpenDuration == "" {
+			c.CircuitBreaker.OpenDuration = "30s"
+		}
+		if _, err := time.ParseDuration(c.CircuitBreaker.OpenDuration); err != nil {
+			return nil, NewErrAuthenticatorMisconfigured(a, err)
+		}
+	}
+
 	if c.PreAuth != nil && c.PreAuth.Enabled {
 		if c.Retry == nil {
 			c.Retry = &AuthenticatorOAuth2IntrospectionRetryConfiguration{Timeout: "500ms", MaxWait: "1s"}
@@ -194,6 +612,11 @@ func (a *AuthenticatorOAuth2Introspection) Config(config json.RawMessage) (*Auth
 			return nil, err
 		}
 
+		transport, err := x.TLSTransport(c.TLS)
+		if err != nil {
+			return nil, NewErrAuthenticatorMisconfigured(a, err)
+		}
+
 		a.client = httpx.NewResilientClientLatencyToleranceConfigurable(
 			(&clientcredentials.Config{
 				ClientID:     c.PreAuth.ClientID,
@@ -201,7 +624,7 @@ func (a *AuthenticatorOAuth2Introspection) Config(config json.RawMessage) (*Auth
 				Scopes:       c.PreAuth.Scope,
 				TokenURL:     c.PreAuth.TokenURL,
 			}).
-				Client(context.Background()).
+				Client(context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})).
 				Transport,
 			timeout,
 			maxWait,
@@ -23,18 +23,27 @@ package authn_test
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tidwall/sjson"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/herodot"
 
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/internal"
 	. "github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/revocation"
 	"github.com/ory/viper"
 )
 
@@ -433,6 +442,40 @@ func TestAuthenticatorOAuth2Introspection(t *testing.T) {
 				},
 				expectErr: true,
 			},
+			{
+				d:      "should pass because active and issued recently",
+				r:      &http.Request{Header: http.Header{"Authorization": {"bearer token"}}},
+				config: []byte(`{ "required_scope": ["scope-a"], "max_token_age": "5m" }`),
+				setup: func(t *testing.T, m *httprouter.Router) {
+					m.POST("/oauth2/introspect", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+						require.NoError(t, r.ParseForm())
+						require.NoError(t, json.NewEncoder(w).Encode(&AuthenticatorOAuth2IntrospectionResult{
+							Active:   true,
+							Scope:    "scope-a",
+							Subject:  "subject",
+							IssuedAt: time.Now().Add(-time.Minute).Unix(),
+						}))
+					})
+				},
+				expectErr: false,
+			},
+			{
+				d:      "should fail because active but not issued recently",
+				r:      &http.Request{Header: http.Header{"Authorization": {"bearer token"}}},
+				config: []byte(`{ "required_scope": ["scope-a"], "max_token_age": "5m" }`),
+				setup: func(t *testing.T, m *httprouter.Router) {
+					m.POST("/oauth2/introspect", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+						require.NoError(t, r.ParseForm())
+						require.NoError(t, json.NewEncoder(w).Encode(&AuthenticatorOAuth2IntrospectionResult{
+							Active:   true,
+							Scope:    "scope-a",
+							Subject:  "subject",
+							IssuedAt: time.Now().Add(-time.Hour).Unix(),
+						}))
+					})
+				},
+				expectErr: true,
+			},
 			{
 				d:      "should pass",
 				r:      &http.Request{Header: http.Header{"Authorization": {"bearer token"}}},
@@ -481,6 +524,260 @@ func TestAuthenticatorOAuth2Introspection(t *testing.T) {
 		}
 	})
 
+	t.Run("method=authenticate/case=concurrent requests for the same token are collapsed", func(t *testing.T) {
+		var requests int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			time.Sleep(time.Millisecond * 100)
+			require.NoError(t, json.NewEncoder(w).Encode(&AuthenticatorOAuth2IntrospectionResult{
+				Active:  true,
+				Subject: "subject",
+				Extra:   map[string]interface{}{"foo": "bar"},
+			}))
+		}))
+		defer ts.Close()
+
+		config, _ := sjson.SetBytes([]byte(`{}`), "introspection_url", ts.URL)
+		sessions := make([]*AuthenticationSession, 10)
+
+		var wg sync.WaitGroup
+		for k := range sessions {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				sessions[k] = new(AuthenticationSession)
+				assert.NoError(t, a.Authenticate(&http.Request{Header: http.Header{"Authorization": {"bearer concurrent"}}}, sessions[k], config, nil))
+			}(k)
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+		for _, sess := range sessions {
+			assert.Equal(t, "subject", sess.Subject)
+			assert.Equal(t, "bar", sess.Extra["foo"])
+		}
+
+		// The extra claims of the sessions are not shared.
+		sessions[0].Extra["foo"] = "baz"
+		assert.Equal(t, "bar", sessions[1].Extra["foo"])
+	})
+
+	t.Run("method=authenticate/case=results of active tokens are cached", func(t *testing.T) {
+		var requests int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			require.NoError(t, r.ParseForm())
+			require.NoError(t, json.NewEncoder(w).Encode(&AuthenticatorOAuth2IntrospectionResult{
+				Active:    r.PostForm.Get("token") != "inactive",
+				Subject:   "subject",
+				ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			}))
+		}))
+		defer ts.Close()
+
+		config, _ := sjson.SetBytes([]byte(`{"cache":{"enabled":true,"ttl":"1m"}}`), "introspection_url", ts.URL)
+		authenticate := func(token string) error {
+			err := a.Authenticate(&http.Request{Header: http.Header{"Authorization": {"bearer " + token}}}, new(AuthenticationSession), config, nil)
+			time.Sleep(time.Millisecond * 100) // give the cache buffers some time
+			return err
+		}
+
+		require.NoError(t, authenticate("cached"))
+		require.NoError(t, authenticate("cached"))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+		require.Error(t, authenticate("inactive"))
+		require.Error(t, authenticate("inactive"))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+		a.(*AuthenticatorOAuth2Introspection).InvalidateToken(revocation.TokenHash("cached"))
+		require.NoError(t, authenticate("cached"))
+		assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+		disabled, _ := sjson.SetBytes(config, "cache.enabled", false)
+		require.NoError(t, a.Authenticate(&http.Request{Header: http.Header{"Authorization": {"bearer cached"}}}, new(AuthenticationSession), disabled, nil))
+		assert.Equal(t, int32(5), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("method=authenticate/case=failures of the introspection endpoint", func(t *testing.T) {
+		var requests, down int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			if atomic.LoadInt32(&down) == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(&AuthenticatorOAuth2IntrospectionResult{
+				Active:    true,
+				Subject:   "subject",
+				ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			}))
+		}))
+		defer ts.Close()
+
+		authenticate := func(config json.RawMessage, token string) (*AuthenticationSession, error) {
+			session := new(AuthenticationSession)
+			return session, a.Authenticate(&http.Request{Header: http.Header{"Authorization": {"bearer " + token}}}, session, config, nil)
+		}
+
+		t.Run("case=the circuit opens after consecutive failures", func(t *testing.T) {
+			config, _ := sjson.SetBytes([]byte(`{"circuit_breaker":{"enabled":true,"failure_threshold":2,"open_duration":"200ms"}}`), "introspection_url", ts.URL)
+
+			atomic.StoreInt32(&down, 1)
+			for k := 0; k < 3; k++ {
+				_, err := authenticate(config, "token")
+				require.Error(t, err)
+				assert.Equal(t, http.StatusServiceUnavailable, herodot.ToDefaultError(err, "").StatusCode())
+			}
+			assert.Equal(t, int32(2), atomic.LoadInt32(&requests), "requests are not sent while the circuit is open")
+
+			atomic.StoreInt32(&down, 0)
+			time.Sleep(time.Millisecond * 250)
+			_, err := authenticate(config, "token")
+			require.NoError(t, err)
+			assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+		})
+
+		t.Run("case=on_error allow accepts requests without a subject", func(t *testing.T) {
+			config, _ := sjson.SetBytes([]byte(`{"on_error":"allow"}`), "introspection_url", ts.URL)
+
+			atomic.StoreInt32(&down, 1)
+			defer atomic.StoreInt32(&down, 0)
+			session, err := authenticate(config, "token")
+			require.NoError(t, err)
+			assert.Empty(t, session.Subject)
+		})
+
+		t.Run("case=on_error allow_cached accepts stale results", func(t *testing.T) {
+			config, _ := sjson.SetBytes([]byte(`{"on_error":"allow_cached","cache":{"enabled":true,"ttl":"10ms"}}`), "introspection_url", ts.URL)
+
+			_, err := authenticate(config, "stale")
+			require.NoError(t, err)
+			time.Sleep(time.Millisecond * 100) // give the cache buffers some time and let the result become stale
+
+			atomic.StoreInt32(&down, 1)
+			defer atomic.StoreInt32(&down, 0)
+			session, err := authenticate(config, "stale")
+			require.NoError(t, err)
+			assert.Equal(t, "subject", session.Subject)
+
+			_, err = authenticate(config, "unknown")
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("method=authenticate/case=required claims", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"active":true,"sub":"subject","client_id":"app-a","aud":["orders","billing"],"ext":{"tenant":"acme","email":"alice@example.com","level":3}}`))
+		}))
+		defer ts.Close()
+
+		for k, tc := range []struct {
+			claims string
+			pass   bool
+		}{
+			{claims: `{"ext.tenant":"acme"}`, pass: true},
+			{claims: `{"ext.tenant":"other"}`, pass: false},
+			{claims: `{"ext.level":3}`, pass: true},
+			{claims: `{"client_id":["app-a","app-b"]}`, pass: true},
+			{claims: `{"client_id":["app-b","app-c"]}`, pass: false},
+			{claims: `{"aud":"billing"}`, pass: true},
+			{claims: `{"aud":{"in":["shipping","orders"]}}`, pass: true},
+			{claims: `{"ext.email":{"matches":"@example\\.com$"}}`, pass: true},
+			{claims: `{"ext.email":{"matches":"@example\\.org$"}}`, pass: false},
+			{claims: `{"ext.missing":"acme"}`, pass: false},
+			{claims: `{"ext.tenant":"acme","client_id":"app-b"}`, pass: false},
+		} {
+			t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+				config, _ := sjson.SetBytes([]byte(`{}`), "introspection_url", ts.URL)
+				config, _ = sjson.SetRawBytes(config, "required_claims", []byte(tc.claims))
+
+				err := a.Authenticate(&http.Request{Header: http.Header{"Authorization": {"bearer token"}}}, new(AuthenticationSession), config, nil)
+				if tc.pass {
+					require.NoError(t, err)
+				} else {
+					require.Error(t, err)
+					assert.Equal(t, http.StatusForbidden, herodot.ToDefaultError(err, "").StatusCode())
+				}
+			})
+		}
+	})
+
+	t.Run("method=authenticate/case=JWT-secured introspection responses", func(t *testing.T) {
+		raw, err := ioutil.ReadFile("../../test/stub/jwks-rsa-single.json")
+		require.NoError(t, err)
+		var set jose.JSONWebKeySet
+		require.NoError(t, json.Unmarshal(raw, &set))
+		key := set.Keys[0]
+
+		sign := func(typ string, claims jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			token.Header["kid"] = key.KeyID
+			if typ != "" {
+				token.Header["typ"] = typ
+			}
+			signed, err := token.SignedString(key.Key)
+			require.NoError(t, err)
+			return signed
+		}
+
+		response := func(token string) jwt.MapClaims {
+			return jwt.MapClaims{
+				"iss": "https://idp.example.com",
+				"aud": "oathkeeper",
+				"iat": time.Now().Unix(),
+				"token_introspection": map[string]interface{}{
+					"active": token != "inactive",
+					"sub":    "subject",
+				},
+			}
+		}
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			token := r.PostForm.Get("token")
+			if token == "plain" || r.Header.Get("Accept") != "application/token-introspection+jwt" {
+				require.NoError(t, json.NewEncoder(w).Encode(&AuthenticatorOAuth2IntrospectionResult{Active: true, Subject: "subject"}))
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/token-introspection+jwt")
+			switch token {
+			case "untyped":
+				_, _ = w.Write([]byte(sign("", response(token))))
+			case "other-issuer":
+				claims := response(token)
+				claims["iss"] = "https://idp.example.org"
+				_, _ = w.Write([]byte(sign("token-introspection+jwt", claims)))
+			case "tampered":
+				signed := sign("token-introspection+jwt", response(token))
+				_, _ = w.Write([]byte(signed[:len(signed)-4] + "AAAA"))
+			default:
+				_, _ = w.Write([]byte(sign("token-introspection+jwt", response(token))))
+			}
+		}))
+		defer ts.Close()
+
+		config, _ := sjson.SetBytes([]byte(`{"jwt_response":{"enabled":true,"issuer":"https://idp.example.com","audience":"oathkeeper","jwks_urls":["file://../../test/stub/jwks-rsa-single.json"]}}`), "introspection_url", ts.URL)
+		authenticate := func(token string) (*AuthenticationSession, error) {
+			session := new(AuthenticationSession)
+			return session, a.Authenticate(&http.Request{Header: http.Header{"Authorization": {"bearer " + token}}}, session, config, nil)
+		}
+
+		session, err := authenticate("valid")
+		require.NoError(t, err)
+		assert.Equal(t, "subject", session.Subject)
+
+		_, err = authenticate("inactive")
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, herodot.ToDefaultError(err, "").StatusCode())
+
+		for _, token := range []string{"plain", "untyped", "other-issuer", "tampered"} {
+			_, err = authenticate(token)
+			require.Error(t, err, token)
+		}
+	})
+
 	t.Run("method=validate", func(t *testing.T) {
 		viper.Set(configuration.ViperKeyAuthenticatorOAuth2TokenIntrospectionIsEnabled, false)
 		require.Error(t, a.Validate(json.RawMessage(`{"introspection_url":""}`)))
@@ -496,5 +793,10 @@ func TestAuthenticatorOAuth2Introspection(t *testing.T) {
 		viper.Reset()
 		viper.Set(configuration.ViperKeyAuthenticatorOAuth2TokenIntrospectionIsEnabled, true)
 		require.Error(t, a.Validate(json.RawMessage(`{"introspection_url":"/oauth2/token"}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorOAuth2TokenIntrospectionIsEnabled, true)
+		require.NoError(t, a.Validate(json.RawMessage(`{"introspection_url":"http://localhost/oauth2/token","required_claims":{"ext.email":{"matches":"@example\\.com$"}}}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"introspection_url":"http://localhost/oauth2/token","required_claims":{"ext.email":{"matches":"("}}}`)))
 	})
 }
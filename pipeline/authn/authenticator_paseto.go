@@ -0,0 +1,232 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/x/stringslice"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/paseto"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/revocation"
+)
+
+type AuthenticatorPASETORegistry interface {
+	credentials.FetcherRegistry
+	revocation.Registry
+}
+
+type AuthenticatorPASETOConfiguration struct {
+	JWKSURLs            []string                    `json:"jwks_urls"`
+	AllowedVersions     []string                    `json:"allowed_versions"`
+	Issuers             []string                    `json:"trusted_issuers"`
+	Audience            []string                    `json:"target_audience"`
+	BearerTokenLocation *helper.BearerTokenLocation `json:"token_from"`
+	MaxTokenAge         string                      `json:"max_token_age"`
+
+	// RequiredFooterClaims are the claims which the JSON footer of the token must contain.
+	RequiredFooterClaims []string `json:"required_footer_claims"`
+}
+
+type AuthenticatorPASETO struct {
+	c configuration.Provider
+	r AuthenticatorPASETORegistry
+}
+
+func NewAuthenticatorPASETO(
+	c configuration.Provider,
+	r AuthenticatorPASETORegistry,
+) *AuthenticatorPASETO {
+	return &AuthenticatorPASETO{
+		c: c,
+		r: r,
+	}
+}
+
+func (a *AuthenticatorPASETO) GetID() string {
+	return "paseto"
+}
+
+func (a *AuthenticatorPASETO) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	if _, err := a.Config(config); err != nil {
+		return err
+	}
+
+	if a.c.FIPSIsEnabled() {
+		return NewErrAuthenticatorMisconfigured(a, errors.New("PASETO tokens are signed using Ed25519 and encrypted using XChaCha20 which are not approved by the FIPS policy"))
+	}
+
+	return nil
+}
+
+// Stage implements the pipeline.Stager interface by making sure that all JSON Web Key Sets are reachable.
+func (a *AuthenticatorPASETO) Stage(ctx context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	jwksu, err := a.c.ParseURLs(cf.JWKSURLs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.r.CredentialsFetcher().ResolveSets(ctx, jwksu); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AuthenticatorPASETO) Config(config json.RawMessage) (*AuthenticatorPASETOConfiguration, error) {
+	var c AuthenticatorPASETOConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if len(c.AllowedVersions) == 0 {
+		c.AllowedVersions = append(append([]string{}, paseto.Versions...), paseto.LocalVersions...)
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorPASETO) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	if !isPASETO(token) {
+		// This allows chaining the jwt and paseto authenticators.
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
+
+	jwksu, err := a.c.ParseURLs(cf.JWKSURLs)
+	if err != nil {
+		return err
+	}
+
+	// resolve returns the JSON Web Key Sets and the ID of the key identified by the footer.
+	resolve := func(footer []byte) ([]jose.JSONWebKeySet, string, error) {
+		var f paseto.Footer
+		if len(footer) > 0 {
+			// Footers which are no JSON objects do not identify a key, all keys are tried in that case.
+			_ = json.Unmarshal(footer, &f)
+		}
+
+		sets, err := a.r.CredentialsFetcher().ResolveSets(r.Context(), jwksu)
+		return sets, f.KeyID, err
+	}
+
+	var t *paseto.Token
+	if isLocalPASETO(token) {
+		t, err = paseto.Decrypt(token, cf.AllowedVersions, func(footer []byte) ([][]byte, error) {
+			sets, kid, err := resolve(footer)
+			if err != nil {
+				return nil, err
+			}
+			return credentials.SymmetricKeys(sets, kid), nil
+		})
+	} else {
+		t, err = paseto.Verify(token, cf.AllowedVersions, func(footer []byte) ([]ed25519.PublicKey, error) {
+			sets, kid, err := resolve(footer)
+			if err != nil {
+				return nil, err
+			}
+			return credentials.Ed25519PublicKeys(sets, kid), nil
+		})
+	}
+	if err != nil {
+		return helper.ErrUnauthorized.WithReason(err.Error()).WithTrace(err)
+	}
+
+	if len(cf.RequiredFooterClaims) > 0 {
+		footer := map[string]interface{}{}
+		if err := json.Unmarshal(t.Footer, &footer); err != nil {
+			return errors.WithStack(helper.ErrUnauthorized.WithReason("The footer of the token must be a JSON object."))
+		}
+		for _, claim := range cf.RequiredFooterClaims {
+			if _, ok := footer[claim]; !ok {
+				return errors.WithStack(helper.ErrUnauthorized.WithReasonf("The footer of the token is missing the required claim %s.", claim))
+			}
+		}
+	}
+
+	issuer, _ := t.Claims["iss"].(string)
+	if len(cf.Issuers) > 0 && !stringslice.Has(cf.Issuers, issuer) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("Token issuer does not match any trusted issuer."))
+	}
+
+	audience := pasetoAudience(t.Claims["aud"])
+	for _, aud := range cf.Audience {
+		if !stringslice.Has(audience, aud) {
+			return errors.WithStack(helper.ErrUnauthorized.WithReasonf("Token audience %v is not intended for target audience %s.", audience, aud))
+		}
+	}
+
+	subject, _ := t.Claims["sub"].(string)
+	sid, _ := t.Claims["sid"].(string)
+	issuedAt, _ := paseto.Time(t.Claims, "iat")
+	if a.r.RevocationStore().IsRevoked(r.Context(), subject, sid, issuedAt) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The session of this PASETO token was revoked."))
+	}
+
+	if err := validateTokenAge(cf.MaxTokenAge, issuedAt); err != nil {
+		return err
+	}
+
+	session.Subject = subject
+	session.Extra = t.Claims
+
+	return nil
+}
+
+func isPASETO(token string) bool {
+	return isLocalPASETO(token) || hasPASETOVersion(token, paseto.Versions)
+}
+
+func isLocalPASETO(token string) bool {
+	return hasPASETOVersion(token, paseto.LocalVersions)
+}
+
+func hasPASETOVersion(token string, versions []string) bool {
+	for _, v := range versions {
+		if strings.HasPrefix(token, v+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// pasetoAudience returns the audience which PASETO defines as a string but which is also accepted as a list.
+func pasetoAudience(aud interface{}) []string {
+	switch v := aud.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		audience := make([]string, 0, len(v))
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return []string{}
+}
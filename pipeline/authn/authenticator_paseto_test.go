@@ -0,0 +1,216 @@
+package authn_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/paseto"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/revocation"
+)
+
+func TestAuthenticatorPASETO(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("paseto")
+	require.NoError(t, err)
+	assert.Equal(t, "paseto", a.GetID())
+
+	sets, err := reg.CredentialsFetcher().ResolveSets(context.Background(), []url.URL{*urlx.ParseOrPanic("file://../../test/stub/jwks-ed25519.json")})
+	require.NoError(t, err)
+	key, kid, err := paseto.PrivateKey(sets)
+	require.NoError(t, err)
+
+	localSets, err := reg.CredentialsFetcher().ResolveSets(context.Background(), []url.URL{*urlx.ParseOrPanic("file://../../test/stub/jwks-paseto-local.json")})
+	require.NoError(t, err)
+	localKey := credentials.SymmetricKeys(localSets, "paseto-local")[0]
+
+	now := time.Now().UTC()
+	gen := func(version string, claims map[string]interface{}) string {
+		token, err := paseto.Sign(version, key, claims, []byte(fmt.Sprintf(`{"kid":"%s"}`, kid)))
+		require.NoError(t, err)
+		return token
+	}
+	encrypt := func(version string, claims map[string]interface{}, footer string) string {
+		token, err := paseto.Encrypt(version, localKey, claims, []byte(footer))
+		require.NoError(t, err)
+		return token
+	}
+	bearer := func(token string) *http.Request {
+		return &http.Request{Header: http.Header{"Authorization": {"Bearer " + token}}}
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         string
+			expectErr      bool
+			expectExactErr error
+			expectSubject  string
+		}{
+			{
+				d:              "should not be responsible for requests without token",
+				r:              &http.Request{Header: http.Header{}},
+				expectErr:      true,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:              "should not be responsible for JSON Web Tokens",
+				r:              bearer("eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28ifQ."),
+				expectErr:      true,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass with v4.public",
+				r:             bearer(gen(paseto.V4Public, map[string]interface{}{"sub": "foo", "exp": now.Add(time.Hour).Format(time.RFC3339)})),
+				expectSubject: "foo",
+			},
+			{
+				d:             "should pass with v2.public and trusted issuer and audience",
+				r:             bearer(gen(paseto.V2Public, map[string]interface{}{"sub": "foo", "iss": "https://issuer/", "aud": "api"})),
+				config:        `{"trusted_issuers":["https://issuer/"],"target_audience":["api"]}`,
+				expectSubject: "foo",
+			},
+			{
+				d:             "should pass with v4.local",
+				r:             bearer(encrypt(paseto.V4Local, map[string]interface{}{"sub": "foo", "exp": now.Add(time.Hour).Format(time.RFC3339)}, `{"kid":"paseto-local"}`)),
+				expectSubject: "foo",
+			},
+			{
+				d:             "should pass with v2.local without footer",
+				r:             bearer(encrypt(paseto.V2Local, map[string]interface{}{"sub": "foo"}, "")),
+				expectSubject: "foo",
+			},
+			{
+				d:             "should pass with the required footer claims",
+				r:             bearer(gen(paseto.V4Public, map[string]interface{}{"sub": "foo"})),
+				config:        `{"required_footer_claims":["kid"]}`,
+				expectSubject: "foo",
+			},
+			{
+				d:         "should fail because a required footer claim is missing",
+				r:         bearer(encrypt(paseto.V4Local, map[string]interface{}{"sub": "foo"}, `{"kid":"paseto-local"}`)),
+				config:    `{"required_footer_claims":["kid","wpk"]}`,
+				expectErr: true,
+			},
+			{
+				d:         "should fail because the footer is required but missing",
+				r:         bearer(encrypt(paseto.V4Local, map[string]interface{}{"sub": "foo"}, "")),
+				config:    `{"required_footer_claims":["kid"]}`,
+				expectErr: true,
+			},
+			{
+				d: "should fail because the local token was encrypted with an unknown key",
+				r: bearer(func() string {
+					token, err := paseto.Encrypt(paseto.V4Local, make([]byte, paseto.LocalKeySize), map[string]interface{}{"sub": "foo"}, nil)
+					require.NoError(t, err)
+					return token
+				}()),
+				expectErr: true,
+			},
+			{
+				d:         "should fail because the local token is expired",
+				r:         bearer(encrypt(paseto.V2Local, map[string]interface{}{"sub": "foo", "exp": now.Add(-time.Hour).Format(time.RFC3339)}, "")),
+				expectErr: true,
+			},
+			{
+				d:         "should fail because the local version is not allowed",
+				r:         bearer(encrypt(paseto.V2Local, map[string]interface{}{"sub": "foo"}, "")),
+				config:    `{"allowed_versions":["v4.public","v4.local"]}`,
+				expectErr: true,
+			},
+			{
+				d:         "should fail because the version is not allowed",
+				r:         bearer(gen(paseto.V2Public, map[string]interface{}{"sub": "foo"})),
+				config:    `{"allowed_versions":["v4.public"]}`,
+				expectErr: true,
+			},
+			{
+				d:         "should fail because the token is expired",
+				r:         bearer(gen(paseto.V4Public, map[string]interface{}{"sub": "foo", "exp": now.Add(-time.Hour).Format(time.RFC3339)})),
+				expectErr: true,
+			},
+			{
+				d:         "should fail because the issuer is not trusted",
+				r:         bearer(gen(paseto.V4Public, map[string]interface{}{"sub": "foo", "iss": "https://other-issuer/"})),
+				config:    `{"trusted_issuers":["https://issuer/"]}`,
+				expectErr: true,
+			},
+			{
+				d:         "should fail because the audience does not match",
+				r:         bearer(gen(paseto.V4Public, map[string]interface{}{"sub": "foo", "aud": "other-api"})),
+				config:    `{"target_audience":["api"]}`,
+				expectErr: true,
+			},
+			{
+				d:         "should fail because the token is too old",
+				r:         bearer(gen(paseto.V4Public, map[string]interface{}{"sub": "foo", "iat": now.Add(-time.Hour).Format(time.RFC3339)})),
+				config:    `{"max_token_age":"5m"}`,
+				expectErr: true,
+			},
+			{
+				d:         "should fail because the session was revoked",
+				r:         bearer(gen(paseto.V4Public, map[string]interface{}{"sub": "revoked", "iat": now.Add(-time.Minute).Format(time.RFC3339)})),
+				expectErr: true,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				require.NoError(t, reg.RevocationStore().Revoke(context.Background(), revocation.Event{Subject: "revoked", Time: now}))
+
+				config := tc.config
+				if config == "" {
+					config = "{}"
+				}
+				var raw map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(config), &raw))
+				raw["jwks_urls"] = []string{"file://../../test/stub/jwks-ed25519.json", "file://../../test/stub/jwks-paseto-local.json"}
+				cfg, err := json.Marshal(raw)
+				require.NoError(t, err)
+
+				session := new(AuthenticationSession)
+				err = a.Authenticate(tc.r, session, cfg, nil)
+				if tc.expectErr {
+					require.Error(t, err)
+					if tc.expectExactErr != nil {
+						assert.EqualError(t, err, tc.expectExactErr.Error())
+					}
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				assert.Equal(t, tc.expectSubject, session.Extra["sub"])
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorPASETOIsEnabled, true)
+		defer viper.Set(configuration.ViperKeyFIPSIsEnabled, false)
+		require.NoError(t, a.Validate(json.RawMessage(`{"jwks_urls":["file://../../test/stub/jwks-ed25519.json"]}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"jwks_urls":["file://../../test/stub/jwks-ed25519.json"],"allowed_versions":["v1.local"]}`)))
+
+		viper.Set(configuration.ViperKeyFIPSIsEnabled, true)
+		require.Error(t, a.Validate(json.RawMessage(`{"jwks_urls":["file://../../test/stub/jwks-ed25519.json"]}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorPASETOIsEnabled, false)
+		require.Error(t, a.Validate(json.RawMessage(`{"jwks_urls":["file://../../test/stub/jwks-ed25519.json"]}`)))
+	})
+}
@@ -0,0 +1,235 @@
+package authn
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/go-convenience/stringsx"
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/x"
+)
+
+type AuthenticatorRemoteConfiguration struct {
+	URL string `json:"url"`
+
+	// Headers and Cookies are the names of the headers and cookies carrying credentials.
+	Headers []string `json:"headers"`
+	Cookies []string `json:"cookies"`
+
+	// BodySize is the number of bytes of the request body forwarded to the remote, none if zero.
+	BodySize int64 `json:"body_size"`
+
+	SubjectFrom string                            `json:"subject_from"`
+	ExtraFrom   string                            `json:"extra_from"`
+	Signing     *credentials.RequestSigningConfig `json:"signing"`
+}
+
+// AuthenticatorRemotePayload is the payload sent to the remote.
+type AuthenticatorRemotePayload struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers http.Header       `json:"headers"`
+	Cookies map[string]string `json:"cookies"`
+
+	// Body is the beginning of the request body, if forwarded.
+	Body string `json:"body,omitempty"`
+}
+
+type authenticatorRemoteDependencies interface {
+	credentials.SignerRegistry
+}
+
+// AuthenticatorRemote authenticates requests by posting their credentials to a remote which answers with the
+// session. Unlike the cookie_session authenticator it makes no assumptions about the format of the credentials.
+type AuthenticatorRemote struct {
+	c configuration.Provider
+	d authenticatorRemoteDependencies
+
+	client *http.Client
+}
+
+func NewAuthenticatorRemote(c configuration.Provider, d authenticatorRemoteDependencies) *AuthenticatorRemote {
+	return &AuthenticatorRemote{
+		c:      c,
+		d:      d,
+		client: httpx.NewResilientClientLatencyToleranceSmall(nil),
+	}
+}
+
+func (a *AuthenticatorRemote) GetID() string {
+	return "remote"
+}
+
+func (a *AuthenticatorRemote) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorRemote) Config(config json.RawMessage) (*AuthenticatorRemoteConfiguration, error) {
+	var c AuthenticatorRemoteConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if c.URL == "" {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("url must be set"))
+	}
+
+	if c.Headers == nil {
+		c.Headers = []string{"Authorization"}
+	}
+
+	if len(c.ExtraFrom) == 0 {
+		c.ExtraFrom = "extra"
+	}
+
+	if len(c.SubjectFrom) == 0 {
+		c.SubjectFrom = "subject"
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorRemote) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	payload, err := remotePayload(r, cf)
+	if err != nil {
+		return err
+	} else if len(payload.Headers) == 0 && len(payload.Cookies) == 0 && payload.Body == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+
+	body, err := a.ask(r, cf, payload)
+	if err != nil {
+		return err
+	}
+
+	var (
+		subject string
+		extra   map[string]interface{}
+
+		subjectRaw = []byte(stringsx.Coalesce(gjson.GetBytes(body, cf.SubjectFrom).Raw, "null"))
+		extraRaw   = []byte(stringsx.Coalesce(gjson.GetBytes(body, cf.ExtraFrom).Raw, "null"))
+	)
+
+	if err = json.Unmarshal(subjectRaw, &subject); err != nil {
+		return helper.ErrForbidden.WithReasonf("The configured subject_from GJSON path returned an error on JSON output: %s", err.Error()).WithDebugf("GJSON path: %s\nBody: %s\nResult: %s", cf.SubjectFrom, body, subjectRaw).WithTrace(err)
+	}
+
+	if err = json.Unmarshal(extraRaw, &extra); err != nil {
+		return helper.ErrForbidden.WithReasonf("The configured extra_from GJSON path returned an error on JSON output: %s", err.Error()).WithDebugf("GJSON path: %s\nBody: %s\nResult: %s", cf.ExtraFrom, body, extraRaw).WithTrace(err)
+	}
+
+	if subject == "" {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The remote did not return a subject."))
+	}
+
+	session.Subject = subject
+	session.Extra = extra
+	session.ConsumeCookies(cf.Cookies...)
+	return nil
+}
+
+// ask posts the payload to the remote and returns the body of its response.
+func (a *AuthenticatorRemote) ask(r *http.Request, cf *AuthenticatorRemoteConfiguration, payload *AuthenticatorRemotePayload) ([]byte, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ctx, cancel := x.WithOptionalTimeout(r.Context(), a.c.RemoteResponseTimeout())
+	defer cancel()
+
+	req, err := http.NewRequest("POST", cf.URL, bytes.NewReader(raw))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	if cf.Signing != nil {
+		if err := credentials.SignRequest(r.Context(), a.signer(), req, raw, cf.Signing); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return x.ReadResponse(res, a.c.RemoteResponseMaxBodySize())
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, errors.WithStack(helper.ErrUnauthorized.WithReason("The remote rejected the credentials."))
+	default:
+		return nil, errors.Errorf("expected status code %d but got %d", http.StatusOK, res.StatusCode)
+	}
+}
+
+func (a *AuthenticatorRemote) signer() credentials.Signer {
+	if a.d == nil {
+		return nil
+	}
+	return a.d.CredentialsSigner()
+}
+
+// remotePayload returns the credentials of the request. The body is restored after reading it.
+func remotePayload(r *http.Request, cf *AuthenticatorRemoteConfiguration) (*AuthenticatorRemotePayload, error) {
+	payload := &AuthenticatorRemotePayload{
+		Method:  r.Method,
+		Headers: http.Header{},
+		Cookies: map[string]string{},
+	}
+	if r.URL != nil {
+		payload.URL = r.URL.String()
+	}
+
+	for _, h := range cf.Headers {
+		if values := r.Header[http.CanonicalHeaderKey(h)]; len(values) > 0 {
+			payload.Headers[http.CanonicalHeaderKey(h)] = values
+		}
+	}
+
+	for _, name := range cf.Cookies {
+		if c, err := r.Cookie(name); err == nil {
+			payload.Cookies[name] = c.Value
+		}
+	}
+
+	if cf.BodySize > 0 && r.Body != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, cf.BodySize))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		payload.Body = string(body)
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,186 @@
+package authn_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorRemote(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("remote")
+	require.NoError(t, err)
+	assert.Equal(t, "remote", a.GetID())
+
+	var received AuthenticatorRemotePayload
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received = AuthenticatorRemotePayload{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+
+		switch received.Headers.Get("Authorization") + received.Cookies["session"] + received.Body {
+		case "Token valid", "valid-session", "token=valid":
+			_, _ = w.Write([]byte(`{"subject":"alice","extra":{"team":"payments"},"identity":{"id":"alice-id"}}`))
+		case "Token empty":
+			_, _ = w.Write([]byte(`{}`))
+		case "Token broken":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer remote.Close()
+
+	config, _ := json.Marshal(map[string]interface{}{"url": remote.URL})
+	cookies, _ := json.Marshal(map[string]interface{}{"url": remote.URL, "headers": []string{}, "cookies": []string{"session"}})
+	body, _ := json.Marshal(map[string]interface{}{"url": remote.URL, "headers": []string{}, "body_size": 11})
+	paths, _ := json.Marshal(map[string]interface{}{"url": remote.URL, "subject_from": "identity.id", "extra_from": "@this"})
+
+	token := func(token string) *http.Request {
+		return &http.Request{
+			Method: "GET",
+			URL:    &url.URL{Scheme: "https", Host: "api.example.org", Path: "/invoices"},
+			Header: http.Header{"Authorization": {"Token " + token}, "X-Other": {"not forwarded"}},
+		}
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         json.RawMessage
+			expectExactErr error
+			expectCode     int
+			expectSubject  string
+			expectExtra    map[string]interface{}
+		}{
+			{
+				d:              "should not be responsible without credentials",
+				r:              &http.Request{Header: http.Header{}, URL: &url.URL{}},
+				config:         config,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass if the remote accepts the header",
+				r:             token("valid"),
+				config:        config,
+				expectSubject: "alice",
+				expectExtra:   map[string]interface{}{"team": "payments"},
+			},
+			{
+				d:          "should fail if the remote rejects the header",
+				r:          token("invalid"),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail if the remote returns no subject",
+				r:          token("empty"),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail if the remote fails",
+				r:          token("broken"),
+				config:     config,
+				expectCode: http.StatusInternalServerError,
+			},
+			{
+				d:             "should pass if the remote accepts the cookie",
+				r:             &http.Request{Header: http.Header{"Cookie": {"session=valid-session"}}, URL: &url.URL{}},
+				config:        cookies,
+				expectSubject: "alice",
+				expectExtra:   map[string]interface{}{"team": "payments"},
+			},
+			{
+				d:              "should not be responsible if only the cookie is forwarded but missing",
+				r:              token("valid"),
+				config:         cookies,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:             "should pass if the remote accepts the beginning of the body",
+				r:             &http.Request{Header: http.Header{}, URL: &url.URL{}, Body: ioutil.NopCloser(strings.NewReader("token=valid&more=data"))},
+				config:        body,
+				expectSubject: "alice",
+				expectExtra:   map[string]interface{}{"team": "payments"},
+			},
+			{
+				d:             "should use the configured paths",
+				r:             token("valid"),
+				config:        paths,
+				expectSubject: "alice-id",
+				expectExtra: map[string]interface{}{
+					"subject":  "alice",
+					"extra":    map[string]interface{}{"team": "payments"},
+					"identity": map[string]interface{}{"id": "alice-id"},
+				},
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, tc.config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectSubject, session.Subject)
+				assert.Equal(t, tc.expectExtra, session.Extra)
+			})
+		}
+	})
+
+	t.Run("method=authenticate/case=only the configured credentials are forwarded", func(t *testing.T) {
+		require.NoError(t, a.Authenticate(token("valid"), new(AuthenticationSession), config, nil))
+		assert.Equal(t, "GET", received.Method)
+		assert.Equal(t, "https://api.example.org/invoices", received.URL)
+		assert.Equal(t, http.Header{"Authorization": {"Token valid"}}, received.Headers)
+		assert.Empty(t, received.Cookies)
+		assert.Empty(t, received.Body)
+	})
+
+	t.Run("method=authenticate/case=the body is restored", func(t *testing.T) {
+		r := &http.Request{Header: http.Header{}, URL: &url.URL{}, Body: ioutil.NopCloser(strings.NewReader("token=valid&more=data"))}
+		require.NoError(t, a.Authenticate(r, new(AuthenticationSession), body, nil))
+
+		restored, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "token=valid&more=data", string(restored))
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorRemoteIsEnabled, true)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"url":"`+remote.URL+`","body_size":-1}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorRemoteIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
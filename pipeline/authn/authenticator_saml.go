@@ -0,0 +1,515 @@
+package authn
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/pkg/errors"
+	dsig "github.com/russellhaering/goxmldsig"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/x"
+)
+
+// Locations the saml authenticator reads the assertion from.
+const (
+	SAMLAssertionFromHeader = "header"
+	SAMLAssertionFromForm   = "form"
+
+	// samlMaxFormSize limits the size of form bodies read to find the assertion.
+	samlMaxFormSize = 1 << 20
+)
+
+// samlBearerMethod is the subject confirmation method of bearer assertions.
+const samlBearerMethod = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+type AuthenticatorSAMLConfiguration struct {
+	// IDPMetadataURL is the location of the metadata of the identity provider, either http(s):// or file://.
+	IDPMetadataURL string `json:"idp_metadata_url"`
+	MetadataTTL    string `json:"metadata_ttl"`
+
+	// Audience is the entity ID assertions must be restricted to. Recipient, if set, must be the recipient of the
+	// bearer subject confirmation.
+	Audience  string `json:"audience"`
+	Recipient string `json:"recipient"`
+
+	// MaxSkew is the tolerated difference between the clocks of the identity provider and ORY Oathkeeper.
+	MaxSkew   string                        `json:"max_skew"`
+	Assertion *AuthenticatorSAMLAssertionIn `json:"assertion_from"`
+
+	metadataTTL time.Duration
+	maxSkew     time.Duration
+}
+
+// AuthenticatorSAMLAssertionIn defines where the base64-encoded assertion is read from.
+type AuthenticatorSAMLAssertionIn struct {
+	Source    string `json:"source"`
+	Header    string `json:"header"`
+	FormField string `json:"form_field"`
+}
+
+type samlMetadata struct {
+	EntityID          string `xml:"entityID,attr"`
+	IDPSSODescriptors []struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+type samlIdentityProvider struct {
+	fetchedAt    time.Time
+	entityID     string
+	certificates []*x509.Certificate
+}
+
+// AuthenticatorSAML authenticates partners sending SAML 2.0 bearer assertions, either bare or wrapped in a
+// response. The signature of the assertion, or of the response containing it, is verified against the certificates
+// of the metadata of the identity provider. Encrypted assertions are not supported.
+type AuthenticatorSAML struct {
+	c configuration.Provider
+
+	client  *http.Client
+	flights singleflight.Group
+
+	sync.Mutex
+	providers map[string]*samlIdentityProvider
+}
+
+func NewAuthenticatorSAML(c configuration.Provider) *AuthenticatorSAML {
+	return &AuthenticatorSAML{
+		c:         c,
+		client:    httpx.NewResilientClientLatencyToleranceSmall(nil),
+		providers: map[string]*samlIdentityProvider{},
+	}
+}
+
+func (a *AuthenticatorSAML) GetID() string {
+	return "saml"
+}
+
+func (a *AuthenticatorSAML) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *AuthenticatorSAML) Config(config json.RawMessage) (*AuthenticatorSAMLConfiguration, error) {
+	var c AuthenticatorSAMLConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if u, err := url.Parse(c.IDPMetadataURL); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf(`idp_metadata_url "%s" must be an http(s):// or file:// URL`, c.IDPMetadataURL))
+	}
+
+	if c.Audience == "" {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("audience must be set"))
+	}
+
+	if c.MetadataTTL == "" {
+		c.MetadataTTL = "1h"
+	}
+	ttl, err := time.ParseDuration(c.MetadataTTL)
+	if err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.WithStack(err))
+	}
+	c.metadataTTL = ttl
+
+	if c.MaxSkew == "" {
+		c.MaxSkew = "1m"
+	}
+	skew, err := time.ParseDuration(c.MaxSkew)
+	if err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.WithStack(err))
+	}
+	c.maxSkew = skew
+
+	if c.Assertion == nil {
+		c.Assertion = new(AuthenticatorSAMLAssertionIn)
+	}
+	switch c.Assertion.Source {
+	case "":
+		c.Assertion.Source = SAMLAssertionFromHeader
+	case SAMLAssertionFromHeader, SAMLAssertionFromForm:
+	default:
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf(`assertion_from.source must be "%s" or "%s"`, SAMLAssertionFromHeader, SAMLAssertionFromForm))
+	}
+	if c.Assertion.Header == "" {
+		c.Assertion.Header = "Authorization"
+	}
+	if c.Assertion.FormField == "" {
+		c.Assertion.FormField = "assertion"
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorSAML) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := samlAssertion(r, cf.Assertion)
+	if err != nil {
+		return err
+	} else if encoded == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+
+	idp, err := a.identityProvider(cf.IDPMetadataURL, cf.metadataTTL)
+	if err != nil {
+		return err
+	}
+
+	assertion, err := verifySAMLAssertion(encoded, idp)
+	if err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The SAML assertion is invalid.").WithDebug(err.Error()))
+	}
+
+	if err := checkSAMLAssertion(assertion, idp, cf, time.Now()); err != nil {
+		return err
+	}
+
+	subject := samlChild(assertion, "Subject", "NameID")
+	if subject == nil || strings.TrimSpace(subject.Text()) == "" {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The SAML assertion has no NameID."))
+	}
+
+	session.Subject = strings.TrimSpace(subject.Text())
+	session.Extra = map[string]interface{}{
+		"issuer":     idp.entityID,
+		"attributes": samlAttributes(assertion),
+	}
+	return nil
+}
+
+// identityProvider returns the identity provider of the metadata, fetching it again once it is older than ttl. If the
+// metadata can not be fetched, the metadata fetched before is used until the next attempt.
+func (a *AuthenticatorSAML) identityProvider(location string, ttl time.Duration) (*samlIdentityProvider, error) {
+	a.Lock()
+	idp, ok := a.providers[location]
+	a.Unlock()
+	if ok && time.Since(idp.fetchedAt) < ttl {
+		return idp, nil
+	}
+
+	// Concurrent requests share one fetch, so it must not be canceled with the request which started it.
+	v, err, _ := a.flights.Do(location, func() (interface{}, error) {
+		ctx, cancel := x.WithOptionalTimeout(context.Background(), a.c.RemoteResponseTimeout())
+		defer cancel()
+		return a.fetchMetadata(ctx, location)
+	})
+
+	a.Lock()
+	defer a.Unlock()
+
+	if err != nil {
+		if ok {
+			stale := *idp
+			stale.fetchedAt = time.Now()
+			a.providers[location] = &stale
+			return &stale, nil
+		}
+		return nil, err
+	}
+
+	fetched := v.(*samlIdentityProvider)
+	a.providers[location] = fetched
+	return fetched, nil
+}
+
+func (a *AuthenticatorSAML) fetchMetadata(ctx context.Context, location string) (*samlIdentityProvider, error) {
+	var raw []byte
+	if strings.HasPrefix(location, "file://") {
+		var err error
+		raw, err = ioutil.ReadFile(strings.TrimPrefix(location, "file://"))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	} else {
+		req, err := http.NewRequest("GET", location, nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		res, err := a.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("expected status code %d from SAML metadata %s but got %d", http.StatusOK, location, res.StatusCode)
+		}
+
+		raw, err = x.ReadResponse(res, a.c.RemoteResponseMaxBodySize())
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read the SAML metadata %s", location)
+		}
+	}
+
+	idp, err := parseSAMLMetadata(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the SAML metadata %s", location)
+	}
+	return idp, nil
+}
+
+// parseSAMLMetadata returns the entity ID and the signing certificates of the identity provider. Key descriptors
+// without a use are used for signing as well.
+func parseSAMLMetadata(raw []byte) (*samlIdentityProvider, error) {
+	var m samlMetadata
+	if err := xml.Unmarshal(raw, &m); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if m.EntityID == "" {
+		return nil, errors.New("the metadata has no entityID")
+	}
+
+	idp := &samlIdentityProvider{fetchedAt: time.Now(), entityID: m.EntityID}
+	for _, d := range m.IDPSSODescriptors {
+		for _, k := range d.KeyDescriptors {
+			if k.Use != "" && k.Use != "signing" {
+				continue
+			}
+			for _, encoded := range k.Certificates {
+				der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				cert, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				idp.certificates = append(idp.certificates, cert)
+			}
+		}
+	}
+
+	if len(idp.certificates) == 0 {
+		return nil, errors.New("the metadata has no signing certificate")
+	}
+	return idp, nil
+}
+
+// verifySAMLAssertion decodes the assertion and verifies its signature or the signature of the response containing
+// it. Only the signed elements are returned, which protects against signature wrapping.
+func verifySAMLAssertion(encoded string, idp *samlIdentityProvider) (*etree.Element, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		if raw, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "=")); err != nil {
+			return nil, errors.New("the assertion is not base64-encoded")
+		}
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	root := doc.Root()
+	if root == nil {
+		return nil, errors.New("the document is empty")
+	}
+
+	ctx := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: idp.certificates})
+	switch root.Tag {
+	case "Assertion":
+		return ctx.Validate(root)
+	case "Response":
+		if samlChild(root, "Signature") == nil {
+			assertion := samlChild(root, "Assertion")
+			if assertion == nil {
+				return nil, errors.New("the response contains no assertion")
+			}
+			return ctx.Validate(assertion)
+		}
+
+		response, err := ctx.Validate(root)
+		if err != nil {
+			return nil, err
+		}
+		assertion := samlChild(response, "Assertion")
+		if assertion == nil {
+			return nil, errors.New("the response contains no assertion")
+		}
+		return assertion, nil
+	default:
+		return nil, errors.Errorf("expected an Assertion or a Response but got %s", root.Tag)
+	}
+}
+
+// checkSAMLAssertion checks the issuer, the conditions and the bearer subject confirmation of the verified assertion.
+func checkSAMLAssertion(assertion *etree.Element, idp *samlIdentityProvider, cf *AuthenticatorSAMLConfiguration, now time.Time) error {
+	if issuer := samlChild(assertion, "Issuer"); issuer == nil || strings.TrimSpace(issuer.Text()) != idp.entityID {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The SAML assertion was not issued by the identity provider."))
+	}
+
+	conditions := samlChild(assertion, "Conditions")
+	if conditions == nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The SAML assertion has no conditions."))
+	}
+	if !samlTimeValid(conditions, now, cf.maxSkew) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The SAML assertion is not valid at this time."))
+	}
+
+	var audienceOK bool
+	for _, restriction := range samlChildren(conditions, "AudienceRestriction") {
+		audienceOK = false
+		for _, audience := range samlChildren(restriction, "Audience") {
+			if strings.TrimSpace(audience.Text()) == cf.Audience {
+				audienceOK = true
+			}
+		}
+		if !audienceOK {
+			break
+		}
+	}
+	if !audienceOK {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The SAML assertion is not intended for this audience."))
+	}
+
+	subject := samlChild(assertion, "Subject")
+	if subject == nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The SAML assertion has no subject."))
+	}
+	for _, confirmation := range samlChildren(subject, "SubjectConfirmation") {
+		if confirmation.SelectAttrValue("Method", "") != samlBearerMethod {
+			continue
+		}
+		data := samlChild(confirmation, "SubjectConfirmationData")
+		if data == nil || !samlTimeValid(data, now, cf.maxSkew) {
+			continue
+		}
+		if cf.Recipient != "" && data.SelectAttrValue("Recipient", "") != cf.Recipient {
+			continue
+		}
+		return nil
+	}
+	return errors.WithStack(helper.ErrUnauthorized.WithReason("The SAML assertion has no valid bearer subject confirmation."))
+}
+
+// samlTimeValid checks the NotBefore and NotOnOrAfter attributes of the element, if set.
+func samlTimeValid(el *etree.Element, now time.Time, skew time.Duration) bool {
+	if v := el.SelectAttrValue("NotBefore", ""); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil || now.Add(skew).Before(t) {
+			return false
+		}
+	}
+	if v := el.SelectAttrValue("NotOnOrAfter", ""); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil || !now.Add(-skew).Before(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// samlAttributes returns the values of the attributes of the assertion by their names.
+func samlAttributes(assertion *etree.Element) map[string]interface{} {
+	attributes := map[string]interface{}{}
+	for _, statement := range samlChildren(assertion, "AttributeStatement") {
+		for _, attribute := range samlChildren(statement, "Attribute") {
+			name := attribute.SelectAttrValue("Name", "")
+			if name == "" {
+				continue
+			}
+			values := []interface{}{}
+			if existing, ok := attributes[name].([]interface{}); ok {
+				values = existing
+			}
+			for _, v := range samlChildren(attribute, "AttributeValue") {
+				values = append(values, strings.TrimSpace(v.Text()))
+			}
+			attributes[name] = values
+		}
+	}
+	return attributes
+}
+
+// samlChild returns the first element following the path of local names, ignoring namespaces.
+func samlChild(el *etree.Element, path ...string) *etree.Element {
+	for _, tag := range path {
+		children := samlChildren(el, tag)
+		if len(children) == 0 {
+			return nil
+		}
+		el = children[0]
+	}
+	return el
+}
+
+func samlChildren(el *etree.Element, tag string) []*etree.Element {
+	var children []*etree.Element
+	for _, c := range el.ChildElements() {
+		if c.Tag == tag {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+// samlAssertion returns the encoded assertion of the request. Form bodies are restored after reading them.
+func samlAssertion(r *http.Request, in *AuthenticatorSAMLAssertionIn) (string, error) {
+	if in.Source == SAMLAssertionFromHeader {
+		value := r.Header.Get(in.Header)
+		if !strings.EqualFold(in.Header, "Authorization") {
+			return strings.TrimSpace(value), nil
+		}
+		if len(value) < 5 || !strings.EqualFold(value[:5], "saml ") {
+			return "", nil
+		}
+		return strings.TrimSpace(value[5:]), nil
+	}
+
+	if r.Body == nil {
+		return "", nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/x-www-form-urlencoded" {
+		return "", nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, samlMaxFormSize+1))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if len(body) > samlMaxFormSize {
+		return "", errors.WithStack(helper.ErrBadRequest.WithReason("The form is too large."))
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", errors.WithStack(helper.ErrBadRequest.WithReasonf("Unable to parse the form: %s", err))
+	}
+	return form.Get(in.FormField), nil
+}
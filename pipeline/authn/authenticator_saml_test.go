@@ -0,0 +1,256 @@
+package authn_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+const samlTestMetadata = `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.org">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="encryption">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>not a certificate</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+
+type samlTestAssertion struct {
+	issuer    string
+	audience  string
+	recipient string
+	expiresIn time.Duration
+}
+
+func (s samlTestAssertion) element() *etree.Element {
+	now := time.Now().UTC()
+	expires := now.Add(s.expiresIn).Format(time.RFC3339)
+
+	el := etree.NewElement("saml:Assertion")
+	el.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	el.CreateAttr("ID", "_d71a3a8e9fcc45c9e9d248ef7049393fc8f04e5f75")
+	el.CreateAttr("Version", "2.0")
+	el.CreateAttr("IssueInstant", now.Format(time.RFC3339))
+	el.CreateElement("saml:Issuer").SetText(s.issuer)
+
+	subject := el.CreateElement("saml:Subject")
+	subject.CreateElement("saml:NameID").SetText("partner@example.org")
+	confirmation := subject.CreateElement("saml:SubjectConfirmation")
+	confirmation.CreateAttr("Method", "urn:oasis:names:tc:SAML:2.0:cm:bearer")
+	data := confirmation.CreateElement("saml:SubjectConfirmationData")
+	data.CreateAttr("Recipient", s.recipient)
+	data.CreateAttr("NotOnOrAfter", expires)
+
+	conditions := el.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", now.Add(-time.Minute).Format(time.RFC3339))
+	conditions.CreateAttr("NotOnOrAfter", expires)
+	conditions.CreateElement("saml:AudienceRestriction").CreateElement("saml:Audience").SetText(s.audience)
+
+	attribute := el.CreateElement("saml:AttributeStatement").CreateElement("saml:Attribute")
+	attribute.CreateAttr("Name", "groups")
+	attribute.CreateElement("saml:AttributeValue").SetText("billing")
+	attribute.CreateElement("saml:AttributeValue").SetText("reporting")
+	return el
+}
+
+func TestAuthenticatorSAML(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("saml")
+	require.NoError(t, err)
+	assert.Equal(t, "saml", a.GetID())
+
+	keys := dsig.RandomKeyStoreForTest()
+	_, cert, err := keys.GetKeyPair()
+	require.NoError(t, err)
+
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, samlTestMetadata, base64.StdEncoding.EncodeToString(cert))
+	}))
+	defer idp.Close()
+
+	sign := func(el *etree.Element, keys dsig.X509KeyStore) *etree.Element {
+		ctx := dsig.NewDefaultSigningContext(keys)
+		ctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+		signed, err := ctx.SignEnveloped(el)
+		require.NoError(t, err)
+		return signed
+	}
+	encode := func(el *etree.Element) string {
+		doc := etree.NewDocument()
+		doc.SetRoot(el)
+		raw, err := doc.WriteToBytes()
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(raw)
+	}
+	valid := samlTestAssertion{issuer: "https://idp.example.org", audience: "https://api.example.org", recipient: "https://api.example.org/saml", expiresIn: time.Minute}
+	header := func(encoded string) *http.Request {
+		return &http.Request{Header: http.Header{"Authorization": {"SAML " + encoded}}}
+	}
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"idp_metadata_url": idp.URL,
+		"audience":         "https://api.example.org",
+		"recipient":        "https://api.example.org/saml",
+	})
+	form, _ := json.Marshal(map[string]interface{}{
+		"idp_metadata_url": idp.URL,
+		"audience":         "https://api.example.org",
+		"assertion_from":   map[string]string{"source": "form"},
+	})
+
+	tampered := sign(valid.element(), keys)
+	samlChildElement(tampered, "Subject", "NameID").SetText("admin@example.org")
+
+	response := etree.NewElement("samlp:Response")
+	response.CreateAttr("xmlns:samlp", "urn:oasis:names:tc:SAML:2.0:protocol")
+	response.AddChild(sign(valid.element(), keys))
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		for k, tc := range []struct {
+			d              string
+			r              *http.Request
+			config         json.RawMessage
+			expectExactErr error
+			expectCode     int
+		}{
+			{
+				d:              "should not be responsible without an assertion",
+				r:              &http.Request{Header: http.Header{"Authorization": {"Bearer token"}}},
+				config:         config,
+				expectExactErr: ErrAuthenticatorNotResponsible,
+			},
+			{
+				d:      "should pass with a signed assertion",
+				r:      header(encode(sign(valid.element(), keys))),
+				config: config,
+			},
+			{
+				d:      "should pass with a signed assertion in a response",
+				r:      header(encode(response)),
+				config: config,
+			},
+			{
+				d:      "should pass with a signed assertion in a form",
+				r:      &http.Request{Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, Body: ioutil.NopCloser(strings.NewReader(url.Values{"assertion": {encode(sign(valid.element(), keys))}}.Encode()))},
+				config: form,
+			},
+			{
+				d:          "should fail with an unsigned assertion",
+				r:          header(encode(valid.element())),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an assertion signed by another key",
+				r:          header(encode(sign(valid.element(), dsig.RandomKeyStoreForTest()))),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with a modified assertion",
+				r:          header(encode(tampered)),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an expired assertion",
+				r:          header(encode(sign(samlTestAssertion{issuer: valid.issuer, audience: valid.audience, recipient: valid.recipient, expiresIn: -time.Hour}.element(), keys))),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an assertion for another audience",
+				r:          header(encode(sign(samlTestAssertion{issuer: valid.issuer, audience: "https://other.example.org", recipient: valid.recipient, expiresIn: time.Minute}.element(), keys))),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an assertion for another recipient",
+				r:          header(encode(sign(samlTestAssertion{issuer: valid.issuer, audience: valid.audience, recipient: "https://other.example.org/saml", expiresIn: time.Minute}.element(), keys))),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an assertion of another issuer",
+				r:          header(encode(sign(samlTestAssertion{issuer: "https://other-idp.example.org", audience: valid.audience, recipient: valid.recipient, expiresIn: time.Minute}.element(), keys))),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+			{
+				d:          "should fail with an assertion which is not base64-encoded",
+				r:          header("<saml:Assertion/>"),
+				config:     config,
+				expectCode: http.StatusUnauthorized,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+				session := new(AuthenticationSession)
+				err := a.Authenticate(tc.r, session, tc.config, nil)
+				if tc.expectExactErr != nil {
+					assert.EqualError(t, err, tc.expectExactErr.Error())
+					return
+				}
+				if tc.expectCode != 0 {
+					require.Error(t, err)
+					assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, "partner@example.org", session.Subject)
+				assert.Equal(t, map[string]interface{}{
+					"issuer":     "https://idp.example.org",
+					"attributes": map[string]interface{}{"groups": []interface{}{"billing", "reporting"}},
+				}, session.Extra)
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorSAMLIsEnabled, true)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"idp_metadata_url":"`+idp.URL+`"}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"idp_metadata_url":"ftp://idp.example.org","audience":"https://api.example.org"}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"idp_metadata_url":"`+idp.URL+`","audience":"https://api.example.org","assertion_from":{"source":"query"}}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorSAMLIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
+
+func samlChildElement(el *etree.Element, path ...string) *etree.Element {
+	for _, tag := range path {
+		for _, c := range el.ChildElements() {
+			if c.Tag == tag {
+				el = c
+				break
+			}
+		}
+	}
+	return el
+}
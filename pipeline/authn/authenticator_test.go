@@ -2,6 +2,7 @@ package authn_test
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -39,3 +40,48 @@ func TestSetHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestSessionPool(t *testing.T) {
+	shared := map[string]interface{}{"cached": true}
+
+	s := authn.AcquireSession()
+	s.Subject = "foo"
+	s.Extra = shared
+	s.SetHeader("X-User", "foo")
+	header := s.Header
+	authn.ReleaseSession(s)
+	authn.ReleaseSession(nil)
+
+	assert.Equal(t, authn.AuthenticationSession{}, *s)
+	// Maps assigned to the session are dropped but not cleared as they might be shared.
+	assert.Equal(t, map[string]interface{}{"cached": true}, shared)
+	assert.Equal(t, "foo", header.Get("X-User"))
+
+	t.Run("case=concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					s := authn.AcquireSession()
+					assert.Empty(t, s.Subject)
+					assert.Nil(t, s.Extra)
+					assert.Nil(t, s.Header)
+					assert.Nil(t, s.MatchContext.URL)
+
+					subject := fmt.Sprintf("subject-%d-%d", i, j)
+					s.Subject = subject
+					s.Extra = map[string]interface{}{"subject": subject}
+					s.SetHeader("X-User", subject)
+					s.MatchContext.RegexpCaptureGroups = []string{subject}
+
+					assert.Equal(t, subject, s.Header.Get("X-User"))
+					assert.Equal(t, subject, s.Extra["subject"])
+					authn.ReleaseSession(s)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
@@ -0,0 +1,357 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/ory/go-convenience/jwtx"
+	"github.com/ory/herodot"
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/x"
+)
+
+// tokenExchangeGrantType is the grant type of token exchange requests, see
+// https://www.rfc-editor.org/rfc/rfc8693#section-2.1
+const tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+type AuthenticatorTokenExchangeConfiguration struct {
+	TokenURL            string                                        `json:"token_url"`
+	ClientID            string                                        `json:"client_id"`
+	ClientSecret        string                                        `json:"client_secret"`
+	SubjectTokenType    string                                        `json:"subject_token_type"`
+	RequestedTokenType  string                                        `json:"requested_token_type"`
+	Audience            []string                                      `json:"audience"`
+	Resource            []string                                      `json:"resource"`
+	Scope               []string                                      `json:"scope"`
+	JWKSURLs            []string                                      `json:"jwks_urls"`
+	AllowedAlgorithms   []string                                      `json:"allowed_algorithms"`
+	Issuers             []string                                      `json:"trusted_issuers"`
+	TargetAudience      []string                                      `json:"target_audience"`
+	BearerTokenLocation *helper.BearerTokenLocation                   `json:"token_from"`
+	Cache               *AuthenticatorTokenExchangeCacheConfiguration `json:"cache"`
+}
+
+type AuthenticatorTokenExchangeCacheConfiguration struct {
+	Enabled bool   `json:"enabled"`
+	TTL     string `json:"ttl"`
+	MaxSize int64  `json:"max_size"`
+}
+
+// tokenExchangeResponse is the successful response of the token endpoint, see
+// https://www.rfc-editor.org/rfc/rfc8693#section-2.2.1
+type tokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+}
+
+// tokenExchangeError is the error response of the token endpoint, see
+// https://www.rfc-editor.org/rfc/rfc6749#section-5.2
+type tokenExchangeError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type tokenExchangeCacheContainer struct {
+	ExpiresAt time.Time
+	Claims    jwt.MapClaims
+}
+
+// AuthenticatorTokenExchange exchanges the token of the request for a token of another trust domain at the token
+// endpoint of that domain (RFC 8693) and authenticates the request with the claims of the issued token.
+type AuthenticatorTokenExchange struct {
+	c configuration.Provider
+	r AuthenticatorJWTRegistry
+
+	client  *http.Client
+	flights singleflight.Group
+
+	cacheLock sync.Mutex
+	cache     *ristretto.Cache
+}
+
+func NewAuthenticatorTokenExchange(c configuration.Provider, r AuthenticatorJWTRegistry) *AuthenticatorTokenExchange {
+	return &AuthenticatorTokenExchange{c: c, r: r, client: httpx.NewResilientClientLatencyToleranceSmall(nil)}
+}
+
+func (a *AuthenticatorTokenExchange) GetID() string {
+	return "token_exchange"
+}
+
+func (a *AuthenticatorTokenExchange) Validate(config json.RawMessage) error {
+	if !a.c.AuthenticatorIsEnabled(a.GetID()) {
+		return NewErrAuthenticatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+// Stage implements the pipeline.Stager interface by making sure that the JSON Web Key Sets are reachable.
+func (a *AuthenticatorTokenExchange) Stage(ctx context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	jwksu, err := a.c.ParseURLs(cf.JWKSURLs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.r.CredentialsFetcher().ResolveSets(ctx, jwksu); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AuthenticatorTokenExchange) Config(config json.RawMessage) (*AuthenticatorTokenExchangeConfiguration, error) {
+	var c AuthenticatorTokenExchangeConfiguration
+	if err := a.c.AuthenticatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthenticatorMisconfigured(a, err)
+	}
+
+	if u, err := url.Parse(c.TokenURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.Errorf(`token_url "%s" must be an http(s):// URL`, c.TokenURL))
+	}
+
+	// The issued token is only trusted if its signature is verified, the token endpoint alone does not suffice
+	// because the token may as well be forwarded to the upstream.
+	if len(c.JWKSURLs) == 0 {
+		return nil, NewErrAuthenticatorMisconfigured(a, errors.New("jwks_urls must be set to verify the issued tokens"))
+	}
+
+	if c.SubjectTokenType == "" {
+		c.SubjectTokenType = "urn:ietf:params:oauth:token-type:access_token"
+	}
+
+	if len(c.AllowedAlgorithms) == 0 {
+		c.AllowedAlgorithms = []string{"RS256"}
+	}
+
+	if c.Cache != nil {
+		if c.Cache.TTL == "" {
+			c.Cache.TTL = "1m"
+		}
+		if c.Cache.MaxSize <= 0 {
+			c.Cache.MaxSize = 10000
+		}
+	}
+
+	return &c, nil
+}
+
+func (a *AuthenticatorTokenExchange) Authenticate(r *http.Request, session *AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	cf, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	token := helper.BearerTokenFromRequest(r, cf.BearerTokenLocation)
+	if token == "" {
+		return errors.WithStack(ErrAuthenticatorNotResponsible)
+	}
+	session.ConsumeCookies(cf.BearerTokenLocation.CookieName())
+
+	claims, err := a.exchange(cf, token)
+	if err != nil {
+		return err
+	}
+
+	parsed := jwtx.ParseMapStringInterfaceClaims(claims)
+	sid, _ := claims["sid"].(string)
+	if a.r.RevocationStore().IsRevoked(r.Context(), parsed.Subject, sid, parsed.IssuedAt) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The session of the exchanged token was revoked."))
+	}
+
+	// The claims may be shared with concurrent requests for the same token, so they are copied.
+	extra := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		extra[k] = v
+	}
+
+	session.Subject = parsed.Subject
+	session.Extra = extra
+
+	return nil
+}
+
+// exchange returns the verified claims of the token issued in exchange for the subject token. Identical concurrent
+// exchanges are collapsed into one request. If the cache is enabled, the claims are cached until the issued token
+// expires and subsequent requests carrying the same token are not exchanged again.
+func (a *AuthenticatorTokenExchange) exchange(cf *AuthenticatorTokenExchangeConfiguration, token string) (jwt.MapClaims, error) {
+	form := url.Values{
+		"grant_type":         {tokenExchangeGrantType},
+		"subject_token":      {token},
+		"subject_token_type": {cf.SubjectTokenType},
+	}
+	if cf.RequestedTokenType != "" {
+		form.Set("requested_token_type", cf.RequestedTokenType)
+	}
+	if len(cf.Scope) > 0 {
+		form.Set("scope", strings.Join(cf.Scope, " "))
+	}
+	for _, audience := range cf.Audience {
+		form.Add("audience", audience)
+	}
+	for _, resource := range cf.Resource {
+		form.Add("resource", resource)
+	}
+	body := form.Encode()
+
+	flight := x.FlightKey(cf.TokenURL, cf.ClientID, cf.ClientSecret, body,
+		strings.Join(cf.JWKSURLs, " "), strings.Join(cf.AllowedAlgorithms, " "),
+		strings.Join(cf.Issuers, " "), strings.Join(cf.TargetAudience, " "))
+
+	ttl, cache, err := a.tokenCache(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		if item, found := cache.Get(flight); found {
+			if container := item.(*tokenExchangeCacheContainer); container.ExpiresAt.After(time.Now()) {
+				return container.Claims, nil
+			}
+		}
+	}
+
+	v, err, _ := a.flights.Do(flight, func() (interface{}, error) {
+		// The result is shared, so the exchange must not be canceled with the request which started it.
+		ctx := context.Background()
+
+		issued, err := a.requestToken(ctx, cf, body)
+		if err != nil {
+			return nil, err
+		}
+
+		jwksu, err := a.c.ParseURLs(cf.JWKSURLs)
+		if err != nil {
+			return nil, err
+		}
+
+		pt, err := a.r.CredentialsVerifier().Verify(ctx, issued, &credentials.ValidationContext{
+			Algorithms: cf.AllowedAlgorithms,
+			KeyURLs:    jwksu,
+			Issuers:    cf.Issuers,
+			Audiences:  cf.TargetAudience,
+		})
+		if err != nil {
+			return nil, helper.ErrUnauthorized.WithReason(fmt.Sprintf("The exchanged token is invalid: %s", err)).WithTrace(err)
+		}
+
+		claims, ok := pt.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Expected JSON Web Token claims to be of type jwt.MapClaims but got: %T", pt.Claims))
+		}
+		return claims, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := v.(jwt.MapClaims)
+	if cache != nil {
+		expiresAt := time.Now().Add(ttl)
+		if exp := jwtx.ParseMapStringInterfaceClaims(claims).ExpiresAt; !exp.IsZero() && exp.Before(expiresAt) {
+			expiresAt = exp
+		}
+		cache.Set(flight, &tokenExchangeCacheContainer{ExpiresAt: expiresAt, Claims: claims}, 1)
+	}
+
+	return claims, nil
+}
+
+// requestToken sends the token exchange request and returns the issued token. Requests rejected by the token
+// endpoint, e.g. because the subject token is invalid, are unauthorized.
+func (a *AuthenticatorTokenExchange) requestToken(ctx context.Context, cf *AuthenticatorTokenExchangeConfiguration, body string) (string, error) {
+	ctx, cancel := x.WithOptionalTimeout(ctx, a.c.RemoteResponseTimeout())
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, cf.TokenURL, strings.NewReader(body))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cf.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(cf.ClientID), url.QueryEscape(cf.ClientSecret))
+	}
+
+	res, err := a.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusUnauthorized:
+		var e tokenExchangeError
+		_ = x.DecodeJSONResponse(res, a.c.RemoteResponseMaxBodySize(), &e)
+		return "", errors.WithStack(helper.ErrUnauthorized.
+			WithReasonf(`The token exchange was rejected with error "%s".`, e.Error).
+			WithDebug(e.ErrorDescription))
+	default:
+		return "", errors.Errorf("token exchange returned status code %d but expected %d", res.StatusCode, http.StatusOK)
+	}
+
+	var t tokenExchangeResponse
+	if err := x.DecodeJSONResponse(res, a.c.RemoteResponseMaxBodySize(), &t); err != nil {
+		return "", err
+	}
+
+	if t.AccessToken == "" {
+		return "", errors.WithStack(helper.ErrUnauthorized.WithReason("The token exchange did not issue a token."))
+	}
+
+	return t.AccessToken, nil
+}
+
+// tokenCache returns the TTL and the cache of exchanged tokens, or nil if caching is disabled. The cache is shared by
+// all access rules and created on first use, so its size should be set in the global configuration.
+func (a *AuthenticatorTokenExchange) tokenCache(cf *AuthenticatorTokenExchangeConfiguration) (time.Duration, *ristretto.Cache, error) {
+	if cf.Cache == nil || !cf.Cache.Enabled {
+		return 0, nil, nil
+	}
+
+	ttl, err := time.ParseDuration(cf.Cache.TTL)
+	if err != nil {
+		return 0, nil, errors.WithStack(err)
+	} else if ttl <= 0 {
+		return 0, nil, nil
+	}
+
+	a.cacheLock.Lock()
+	defer a.cacheLock.Unlock()
+
+	if a.cache == nil {
+		cache, err := ristretto.NewCache(&ristretto.Config{
+			NumCounters: cf.Cache.MaxSize * 10,
+			MaxCost:     cf.Cache.MaxSize,
+			BufferItems: 64,
+		})
+		if err != nil {
+			return 0, nil, errors.WithStack(err)
+		}
+		a.cache = cache
+	}
+
+	return ttl, a.cache, nil
+}
@@ -0,0 +1,153 @@
+package authn_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestAuthenticatorTokenExchange(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineAuthenticator("token_exchange")
+	require.NoError(t, err)
+	assert.Equal(t, "token_exchange", a.GetID())
+
+	jwks := "file://../../test/stub/jwks-rsa-single.json"
+	issue := func(subject string) string {
+		token, err := reg.CredentialsSigner().Sign(context.Background(), urlx.ParseOrPanic(jwks), jwt.MapClaims{
+			"iss": "https://idp.other-domain.com",
+			"aud": "orders",
+			"sub": subject,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		require.NoError(t, err)
+		return token
+	}
+
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:token-exchange", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "urn:ietf:params:oauth:token-type:access_token", r.PostForm.Get("subject_token_type"))
+		assert.Equal(t, "orders", r.PostForm.Get("audience"))
+
+		if id, secret, ok := r.BasicAuth(); !ok || id != "oathkeeper" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+
+		switch token := r.PostForm.Get("subject_token"); token {
+		case "valid":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":      issue("exchanged-subject"),
+				"issued_token_type": "urn:ietf:params:oauth:token-type:access_token",
+				"token_type":        "Bearer",
+			})
+		case "unsigned":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "not-a-jwt"})
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "The subject token is invalid."})
+		}
+	}))
+	defer ts.Close()
+
+	config, _ := sjson.SetBytes([]byte(`{
+  "client_id": "oathkeeper",
+  "client_secret": "secret",
+  "audience": ["orders"],
+  "trusted_issuers": ["https://idp.other-domain.com"],
+  "target_audience": ["orders"]
+}`), "token_url", ts.URL)
+	config, _ = sjson.SetBytes(config, "jwks_urls", []string{jwks})
+
+	bearer := func(token string) *http.Request {
+		return &http.Request{Header: http.Header{"Authorization": {"Bearer " + token}}}
+	}
+
+	t.Run("method=authenticate", func(t *testing.T) {
+		t.Run("case=should pass with the claims of the exchanged token", func(t *testing.T) {
+			session := new(AuthenticationSession)
+			require.NoError(t, a.Authenticate(bearer("valid"), session, config, nil))
+			assert.Equal(t, "exchanged-subject", session.Subject)
+			assert.Equal(t, "https://idp.other-domain.com", session.Extra["iss"])
+		})
+
+		t.Run("case=should not be responsible without a token", func(t *testing.T) {
+			err := a.Authenticate(&http.Request{Header: http.Header{}}, new(AuthenticationSession), config, nil)
+			assert.Equal(t, ErrAuthenticatorNotResponsible, errors.Cause(err))
+		})
+
+		for _, tc := range []struct {
+			d      string
+			token  string
+			config json.RawMessage
+			code   int
+		}{
+			{d: "the token is rejected by the token endpoint", token: "invalid", config: config, code: 401},
+			{d: "the client is rejected by the token endpoint", token: "valid", config: withConfig(config, "client_secret", "wrong"), code: 401},
+			{d: "the exchanged token is not signed", token: "unsigned", config: config, code: 401},
+			{d: "the exchanged token is from an untrusted issuer", token: "valid", config: withConfig(config, "trusted_issuers", []string{"https://idp.example.com"}), code: 401},
+			{d: "the token endpoint fails", token: "broken", config: config, code: 500},
+		} {
+			t.Run("case=should fail because "+tc.d, func(t *testing.T) {
+				err := a.Authenticate(bearer(tc.token), new(AuthenticationSession), tc.config, nil)
+				require.Error(t, err)
+				assert.Equal(t, tc.code, herodot.ToDefaultError(err, "").StatusCode())
+			})
+		}
+	})
+
+	t.Run("method=authenticate/case=exchanges are cached", func(t *testing.T) {
+		cached := withConfig(config, "cache", map[string]interface{}{"enabled": true, "ttl": "1m"})
+		authenticate := func() {
+			require.NoError(t, a.Authenticate(bearer("valid"), new(AuthenticationSession), cached, nil))
+			time.Sleep(time.Millisecond * 100) // give the cache buffers some time
+		}
+
+		before := atomic.LoadInt32(&requests)
+		authenticate()
+		authenticate()
+		assert.Equal(t, before+1, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyAuthenticatorTokenExchangeIsEnabled, true)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(withConfig(config, "jwks_urls", []string{})))
+		require.Error(t, a.Validate(withConfig(config, "token_url", "ftp://idp.other-domain.com/token")))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyAuthenticatorTokenExchangeIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
+
+func withConfig(config json.RawMessage, path string, value interface{}) json.RawMessage {
+	c, _ := sjson.SetBytes(config, path, value)
+	return c
+}
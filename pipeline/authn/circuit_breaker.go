@@ -0,0 +1,86 @@
+package authn
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// circuitBreakers track the failures of remote endpoints by URL. After a number of consecutive failures the circuit of
+// an endpoint opens and requests to it fail immediately instead of waiting for the endpoint. Once the circuit was open
+// for a while, a single request is let through to probe the endpoint: if it succeeds the circuit closes again,
+// otherwise it stays open for another period.
+type circuitBreakers struct {
+	sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreakers() *circuitBreakers {
+	return &circuitBreakers{circuits: map[string]*circuit{}}
+}
+
+// allow returns false if the circuit of the endpoint is open, in which case no request must be sent.
+func (b *circuitBreakers) allow(url string, threshold int, now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	c, ok := b.circuits[url]
+	if !ok || c.failures < threshold {
+		return true
+	}
+
+	if now.Before(c.openUntil) || c.probing {
+		return false
+	}
+
+	c.probing = true
+	return true
+}
+
+// record records the outcome of a request to the endpoint. The circuit opens for openFor once the number of
+// consecutive failures reaches the threshold.
+func (b *circuitBreakers) record(url string, failed bool, threshold int, openFor time.Duration, now time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	c, ok := b.circuits[url]
+	if !ok {
+		if !failed {
+			return
+		}
+		c = new(circuit)
+		b.circuits[url] = c
+	}
+
+	c.probing = false
+	if !failed {
+		delete(b.circuits, url)
+		return
+	}
+
+	c.failures++
+	if c.failures >= threshold {
+		c.openUntil = now.Add(openFor)
+	}
+}
+
+// open returns the sorted URLs of the endpoints whose circuit is open or waiting for a successful probe.
+func (b *circuitBreakers) open() []string {
+	b.Lock()
+	defer b.Unlock()
+
+	var urls []string
+	for url, c := range b.circuits {
+		if !c.openUntil.IsZero() {
+			urls = append(urls, url)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -0,0 +1,136 @@
+package authn
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/oathkeeper/helper"
+)
+
+// ClaimAssertion asserts the value of a claim. In the configuration, a plain value asserts that the claim equals it,
+// a list asserts that the claim is one of its values, and an object sets the operator explicitly:
+//
+//	required_claims:
+//	  ext.tenant: acme
+//	  client_id: [app-a, app-b]
+//	  ext.email: { matches: "@example\\.com$" }
+//
+// If the claim is a list, e.g. "aud", the assertion holds if it holds for one of its values.
+type ClaimAssertion struct {
+	// Equals asserts that the claim equals the value.
+	Equals interface{} `json:"equals,omitempty"`
+
+	// In asserts that the claim equals one of the values.
+	In []interface{} `json:"in,omitempty"`
+
+	// Matches asserts that the claim, which must be a string, matches the regular expression.
+	Matches string `json:"matches,omitempty"`
+}
+
+var claimPatterns sync.Map
+
+// UnmarshalJSON decodes the short forms of assertions, plain values and lists, as well as objects.
+func (c *ClaimAssertion) UnmarshalJSON(raw []byte) error {
+	switch bytes.TrimSpace(raw)[0] {
+	case '{':
+		type assertion ClaimAssertion
+		return errors.WithStack(json.Unmarshal(raw, (*assertion)(c)))
+	case '[':
+		return errors.WithStack(json.Unmarshal(raw, &c.In))
+	default:
+		return errors.WithStack(json.Unmarshal(raw, &c.Equals))
+	}
+}
+
+func (c *ClaimAssertion) pattern() (*regexp.Regexp, error) {
+	if p, ok := claimPatterns.Load(c.Matches); ok {
+		return p.(*regexp.Regexp), nil
+	}
+
+	p, err := regexp.Compile(c.Matches)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	claimPatterns.Store(c.Matches, p)
+	return p, nil
+}
+
+func (c *ClaimAssertion) validate() error {
+	operators := 0
+	if c.Equals != nil {
+		operators++
+	}
+	if len(c.In) > 0 {
+		operators++
+	}
+	if c.Matches != "" {
+		operators++
+		if _, err := c.pattern(); err != nil {
+			return err
+		}
+	}
+
+	if operators != 1 {
+		return errors.New("a claim assertion requires exactly one of equals, in and matches")
+	}
+	return nil
+}
+
+func (c *ClaimAssertion) holds(value interface{}) bool {
+	switch {
+	case c.Matches != "":
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		p, err := c.pattern()
+		return err == nil && p.MatchString(s)
+	case len(c.In) > 0:
+		for _, v := range c.In {
+			if reflect.DeepEqual(v, value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return reflect.DeepEqual(c.Equals, value)
+	}
+}
+
+// validateClaimAssertions validates the assertions of the claims at the GJSON paths against a JSON document and returns
+// ErrForbidden if one does not hold. Missing claims never satisfy an assertion.
+func validateClaimAssertions(assertions map[string]ClaimAssertion, document []byte) error {
+	for path, assertion := range assertions {
+		result := gjson.GetBytes(document, path)
+		if !result.Exists() {
+			return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Claim %s is required but missing.", path)))
+		}
+
+		values := []interface{}{result.Value()}
+		if result.IsArray() {
+			values = values[:0]
+			for _, v := range result.Array() {
+				values = append(values, v.Value())
+			}
+		}
+
+		holds := false
+		for _, v := range values {
+			if assertion.holds(v) {
+				holds = true
+				break
+			}
+		}
+		if !holds {
+			return errors.WithStack(helper.ErrForbidden.WithReason(fmt.Sprintf("Claim %s does not have the required value.", path)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package authn
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/go-convenience/stringslice"
+
+	"github.com/ory/oathkeeper/helper"
+)
+
+// dpopProofType is the "typ" header of DPoP proofs, see https://www.rfc-editor.org/rfc/rfc9449#section-4.2
+const dpopProofType = "dpop+jwt"
+
+// defaultDPoPAlgorithms are the algorithms of DPoP proofs accepted if none are configured. Proofs must be signed with
+// an asymmetric algorithm.
+var defaultDPoPAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// DPoPConfiguration configures the validation of DPoP proofs (RFC 9449). A DPoP proof demonstrates that the client
+// holds the private key its access token is bound to, so that a leaked token can not be used by anyone else.
+type DPoPConfiguration struct {
+	// Enabled requires every request to carry a DPoP proof for an access token bound to the key of the proof.
+	Enabled bool `json:"enabled"`
+
+	// ProofMaxAge is how far the "iat" claim of a proof may be in the past or in the future.
+	ProofMaxAge string `json:"proof_max_age"`
+
+	// AllowedAlgorithms are the signing algorithms of proofs which are accepted.
+	AllowedAlgorithms []string `json:"allowed_algorithms"`
+}
+
+func (c *DPoPConfiguration) isEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Confirmation is the confirmation claim ("cnf") of an access token which is bound to a key.
+type Confirmation struct {
+	// JWKThumbprint is the base64url encoded SHA-256 JWK thumbprint of the key the token is bound to.
+	JWKThumbprint string `json:"jkt,omitempty"`
+}
+
+// confirmationFromClaims returns the confirmation claim of a JSON Web Token, or nil if the token is not bound to a key.
+func confirmationFromClaims(claims map[string]interface{}) *Confirmation {
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	jkt, _ := cnf["jkt"].(string)
+	if jkt == "" {
+		return nil
+	}
+	return &Confirmation{JWKThumbprint: jkt}
+}
+
+type dpopClaims struct {
+	JTI string  `json:"jti"`
+	HTM string  `json:"htm"`
+	HTU string  `json:"htu"`
+	IAT float64 `json:"iat"`
+	ATH string  `json:"ath"`
+}
+
+// dpopValidator validates DPoP proofs and remembers the IDs of the proofs it accepted, so that a proof can not be
+// replayed while its "iat" claim is within the accepted window.
+type dpopValidator struct {
+	sync.Mutex
+	seen     map[string]time.Time
+	prunedAt time.Time
+}
+
+func newDPoPValidator() *dpopValidator {
+	return &dpopValidator{seen: map[string]time.Time{}}
+}
+
+// tokenFromRequest returns the access token of the request. If DPoP is enabled and the token is read from the
+// default location, the "DPoP" authorization scheme is accepted as well as the "Bearer" scheme.
+func tokenFromRequest(r *http.Request, location *helper.BearerTokenLocation, dpop *DPoPConfiguration) string {
+	if token := helper.BearerTokenFromRequest(r, location); token != "" || !dpop.isEnabled() || location != nil {
+		return token
+	}
+
+	split := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(split) != 2 || !strings.EqualFold(split[0], "dpop") {
+		return ""
+	}
+	return split[1]
+}
+
+// validate validates the DPoP proof of the request for the access token, which must be bound to the key of the proof
+// by the confirmation claim cnf.
+func (v *dpopValidator) validate(r *http.Request, c *DPoPConfiguration, token string, cnf *Confirmation) error {
+	maxAge := time.Minute
+	if c.ProofMaxAge != "" {
+		d, err := time.ParseDuration(c.ProofMaxAge)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		maxAge = d
+	}
+
+	algorithms := c.AllowedAlgorithms
+	if len(algorithms) == 0 {
+		algorithms = defaultDPoPAlgorithms
+	}
+
+	proofs := r.Header[http.CanonicalHeaderKey("DPoP")]
+	if len(proofs) != 1 {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The request must contain exactly one DPoP proof."))
+	}
+
+	proof, err := jose.ParseSigned(proofs[0])
+	if err != nil || len(proof.Signatures) != 1 {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The DPoP proof is malformed."))
+	}
+
+	header := proof.Signatures[0].Protected
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != dpopProofType {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The DPoP proof must be of type "%s".`, dpopProofType))
+	}
+
+	if !stringslice.Has(algorithms, header.Algorithm) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The DPoP proof is signed with algorithm "%s" which is not allowed.`, header.Algorithm))
+	}
+
+	if header.JSONWebKey == nil || !header.JSONWebKey.IsPublic() {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The DPoP proof must contain the public key it is signed with."))
+	}
+
+	payload, err := proof.Verify(header.JSONWebKey)
+	if err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The signature of the DPoP proof is invalid.").WithDebug(err.Error()))
+	}
+
+	var claims dpopClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The claims of the DPoP proof are malformed.").WithDebug(err.Error()))
+	}
+
+	if claims.JTI == "" {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason(`The DPoP proof must contain the "jti" claim.`))
+	}
+
+	if claims.HTM != r.Method {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The DPoP proof is for method "%s" but the request uses "%s".`, claims.HTM, r.Method))
+	}
+
+	if !dpopTargetMatches(claims.HTU, r) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReasonf(`The DPoP proof is for URL "%s" which is not the URL of the request.`, claims.HTU))
+	}
+
+	now := time.Now()
+	issuedAt := time.Unix(int64(claims.IAT), 0)
+	if claims.IAT == 0 || issuedAt.Before(now.Add(-maxAge)) || issuedAt.After(now.Add(maxAge)) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The DPoP proof was not issued recently."))
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	if claims.ATH != base64.RawURLEncoding.EncodeToString(hash[:]) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The DPoP proof was not issued for the access token."))
+	}
+
+	thumbprint, err := header.JSONWebKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The public key of the DPoP proof is invalid.").WithDebug(err.Error()))
+	}
+
+	if cnf == nil || cnf.JWKThumbprint != base64.RawURLEncoding.EncodeToString(thumbprint) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The access token is not bound to the key of the DPoP proof."))
+	}
+
+	// The proof is accepted at most once while it is valid, see https://www.rfc-editor.org/rfc/rfc9449#section-11.1
+	if !v.use(claims.JTI, issuedAt.Add(maxAge), now) {
+		return errors.WithStack(helper.ErrUnauthorized.WithReason("The DPoP proof was used before."))
+	}
+
+	return nil
+}
+
+// use returns false if the proof ID was seen before. Otherwise the ID is remembered until it expires.
+func (v *dpopValidator) use(jti string, expiresAt, now time.Time) bool {
+	v.Lock()
+	defer v.Unlock()
+
+	if now.Sub(v.prunedAt) > time.Minute {
+		for id, exp := range v.seen {
+			if now.After(exp) {
+				delete(v.seen, id)
+			}
+		}
+		v.prunedAt = now
+	}
+
+	if exp, ok := v.seen[jti]; ok && !now.After(exp) {
+		return false
+	}
+	v.seen[jti] = expiresAt
+	return true
+}
+
+// dpopTargetMatches returns true if the "htu" claim is the URL of the request without query and fragment.
+func dpopTargetMatches(htu string, r *http.Request) bool {
+	target, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	return strings.EqualFold(target.Scheme, scheme) &&
+		strings.EqualFold(target.Host, host) &&
+		target.EscapedPath() == r.URL.EscapedPath()
+}
@@ -0,0 +1,194 @@
+package authn_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/sjson"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/pipeline/authn"
+)
+
+func TestDPoP(t *testing.T) {
+	raw, err := ioutil.ReadFile("../../test/stub/jwks-ecdsa.json")
+	require.NoError(t, err)
+	var set jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(raw, &set))
+	key := set.Keys[1]
+
+	pub := key.Public()
+	thumbprint, err := pub.Thumbprint(crypto.SHA256)
+	require.NoError(t, err)
+	jkt := base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	type proofClaims struct {
+		JTI string `json:"jti,omitempty"`
+		HTM string `json:"htm"`
+		HTU string `json:"htu"`
+		IAT int64  `json:"iat"`
+		ATH string `json:"ath"`
+	}
+
+	ath := func(token string) string {
+		hash := sha256.Sum256([]byte(token))
+		return base64.RawURLEncoding.EncodeToString(hash[:])
+	}
+
+	sign := func(typ string, claims proofClaims) string {
+		signer, err := jose.NewSigner(
+			jose.SigningKey{Algorithm: jose.ES256, Key: key.Key},
+			(&jose.SignerOptions{EmbedJWK: true}).WithType(jose.ContentType(typ)),
+		)
+		require.NoError(t, err)
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err)
+		object, err := signer.Sign(payload)
+		require.NoError(t, err)
+		proof, err := object.CompactSerialize()
+		require.NoError(t, err)
+		return proof
+	}
+
+	proof := func(token string) proofClaims {
+		return proofClaims{JTI: uuid.New().String(), HTM: "GET", HTU: "http://api.example.com/users", IAT: time.Now().Unix(), ATH: ath(token)}
+	}
+
+	request := func(scheme, token string, proofs ...string) *http.Request {
+		r := httptest.NewRequest("GET", "http://api.example.com/users?page=2", nil)
+		r.Header.Set("Authorization", scheme+" "+token)
+		for _, p := range proofs {
+			r.Header.Add("DPoP", p)
+		}
+		return r
+	}
+
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	t.Run("authenticator=oauth2_introspection", func(t *testing.T) {
+		a, err := reg.PipelineAuthenticator("oauth2_introspection")
+		require.NoError(t, err)
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			result := &AuthenticatorOAuth2IntrospectionResult{Active: true, Subject: "subject"}
+			if r.PostForm.Get("token") == "bound" {
+				result.Confirmation = &Confirmation{JWKThumbprint: jkt}
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(result))
+		}))
+		defer ts.Close()
+
+		config, _ := sjson.SetBytes([]byte(`{"dpop":{"enabled":true}}`), "introspection_url", ts.URL)
+
+		t.Run("case=should pass with a valid proof", func(t *testing.T) {
+			session := new(AuthenticationSession)
+			require.NoError(t, a.Authenticate(request("DPoP", "bound", sign("dpop+jwt", proof("bound"))), session, config, nil))
+			assert.Equal(t, "subject", session.Subject)
+
+			require.NoError(t, a.Authenticate(request("bearer", "bound", sign("dpop+jwt", proof("bound"))), new(AuthenticationSession), config, nil))
+		})
+
+		t.Run("case=should pass without a proof if dpop is disabled", func(t *testing.T) {
+			disabled, _ := sjson.SetBytes(config, "dpop.enabled", false)
+			require.NoError(t, a.Authenticate(request("bearer", "unbound"), new(AuthenticationSession), disabled, nil))
+		})
+
+		t.Run("case=should fail if the proof is replayed", func(t *testing.T) {
+			p := sign("dpop+jwt", proof("bound"))
+			require.NoError(t, a.Authenticate(request("DPoP", "bound", p), new(AuthenticationSession), config, nil))
+
+			err := a.Authenticate(request("DPoP", "bound", p), new(AuthenticationSession), config, nil)
+			require.Error(t, err)
+			assert.Equal(t, 401, herodot.ToDefaultError(err, "").StatusCode())
+		})
+
+		for _, tc := range []struct {
+			d string
+			r func() *http.Request
+		}{
+			{d: "the proof is missing", r: func() *http.Request { return request("DPoP", "bound") }},
+			{d: "there are two proofs", r: func() *http.Request {
+				return request("DPoP", "bound", sign("dpop+jwt", proof("bound")), sign("dpop+jwt", proof("bound")))
+			}},
+			{d: "the proof has the wrong type", r: func() *http.Request { return request("DPoP", "bound", sign("JWT", proof("bound"))) }},
+			{d: "the proof has no id", r: func() *http.Request {
+				p := proof("bound")
+				p.JTI = ""
+				return request("DPoP", "bound", sign("dpop+jwt", p))
+			}},
+			{d: "the proof is for another method", r: func() *http.Request {
+				p := proof("bound")
+				p.HTM = "POST"
+				return request("DPoP", "bound", sign("dpop+jwt", p))
+			}},
+			{d: "the proof is for another URL", r: func() *http.Request {
+				p := proof("bound")
+				p.HTU = "http://api.example.com/admin"
+				return request("DPoP", "bound", sign("dpop+jwt", p))
+			}},
+			{d: "the proof is too old", r: func() *http.Request {
+				p := proof("bound")
+				p.IAT = time.Now().Add(-time.Hour).Unix()
+				return request("DPoP", "bound", sign("dpop+jwt", p))
+			}},
+			{d: "the proof is for another token", r: func() *http.Request {
+				return request("DPoP", "bound", sign("dpop+jwt", proof("other")))
+			}},
+			{d: "the token is not bound to the key", r: func() *http.Request {
+				return request("DPoP", "unbound", sign("dpop+jwt", proof("unbound")))
+			}},
+		} {
+			t.Run("case=should fail because "+tc.d, func(t *testing.T) {
+				err := a.Authenticate(tc.r(), new(AuthenticationSession), config, nil)
+				require.Error(t, err)
+				assert.Equal(t, 401, herodot.ToDefaultError(err, "").StatusCode())
+			})
+		}
+	})
+
+	t.Run("authenticator=jwt", func(t *testing.T) {
+		a, err := reg.PipelineAuthenticator("jwt")
+		require.NoError(t, err)
+
+		keys := "file://../../test/stub/jwks-rsa-single.json"
+		config, _ := sjson.SetBytes([]byte(`{"dpop":{"enabled":true}}`), "jwks_urls", []string{keys})
+
+		gen := func(claims jwt.MapClaims) string {
+			token, err := reg.CredentialsSigner().Sign(context.Background(), urlx.ParseOrPanic(keys), claims)
+			require.NoError(t, err)
+			return token
+		}
+
+		bound := gen(jwt.MapClaims{"sub": "sub", "exp": time.Now().Add(time.Hour).Unix(), "cnf": map[string]interface{}{"jkt": jkt}})
+		session := new(AuthenticationSession)
+		require.NoError(t, a.Authenticate(request("DPoP", bound, sign("dpop+jwt", proof(bound))), session, config, nil))
+		assert.Equal(t, "sub", session.Subject)
+
+		unbound := gen(jwt.MapClaims{"sub": "sub", "exp": time.Now().Add(time.Hour).Unix()})
+		err = a.Authenticate(request("DPoP", unbound, sign("dpop+jwt", proof(unbound))), new(AuthenticationSession), config, nil)
+		require.Error(t, err)
+		assert.Equal(t, 401, herodot.ToDefaultError(err, "").StatusCode())
+
+		err = a.Authenticate(request("DPoP", bound), new(AuthenticationSession), config, nil)
+		require.Error(t, err)
+		assert.Equal(t, 401, herodot.ToDefaultError(err, "").StatusCode())
+	})
+}
@@ -0,0 +1,269 @@
+package authz
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/x"
+)
+
+// AuthorizerDevicePostureConfiguration represents a configuration for the device_posture authorizer.
+type AuthorizerDevicePostureConfiguration struct {
+	Remote         string                            `json:"remote"`
+	Payload        string                            `json:"payload"`
+	DeviceIDHeader string                            `json:"device_id_header"`
+	CompliantPath  string                            `json:"compliant_path"`
+	CacheTTL       string                            `json:"cache_ttl"`
+	Signing        *credentials.RequestSigningConfig `json:"signing"`
+}
+
+// PayloadTemplateID returns a string with which to associate the payload template.
+func (c *AuthorizerDevicePostureConfiguration) PayloadTemplateID() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(c.Payload)))
+}
+
+// DevicePostureSession is the object the payload template of the device_posture authorizer is applied to.
+type DevicePostureSession struct {
+	*authn.AuthenticationSession
+
+	// DeviceID is the value of the device ID header.
+	DeviceID string
+
+	// CertificateFingerprint is the hex encoded SHA-256 fingerprint of the client certificate.
+	CertificateFingerprint string
+}
+
+type devicePostureVerdict struct {
+	Compliant bool
+	ExpiresAt time.Time
+}
+
+type authorizerDevicePostureDependencies interface {
+	credentials.SignerRegistry
+}
+
+// AuthorizerDevicePosture asks a device trust or mobile device management API whether the device sending the
+// request is compliant.
+type AuthorizerDevicePosture struct {
+	c configuration.Provider
+	d authorizerDevicePostureDependencies
+
+	client   *http.Client
+	t        *template.Template
+	verdicts *ristretto.Cache
+}
+
+// NewAuthorizerDevicePosture creates a new AuthorizerDevicePosture.
+func NewAuthorizerDevicePosture(c configuration.Provider, d authorizerDevicePostureDependencies) *AuthorizerDevicePosture {
+	cache, _ := ristretto.NewCache(&ristretto.Config{
+		NumCounters: 10000,
+		MaxCost:     1000,
+		BufferItems: 64,
+	})
+	return &AuthorizerDevicePosture{
+		c:        c,
+		d:        d,
+		client:   httpx.NewResilientClientLatencyToleranceSmall(nil),
+		t:        x.NewTemplate("device_posture"),
+		verdicts: cache,
+	}
+}
+
+// GetID implements the Authorizer interface.
+func (a *AuthorizerDevicePosture) GetID() string {
+	return "device_posture"
+}
+
+// Authorize implements the Authorizer interface.
+func (a *AuthorizerDevicePosture) Authorize(r *http.Request, session *authn.AuthenticationSession, config json.RawMessage, _ pipeline.Rule) error {
+	c, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	device := &DevicePostureSession{AuthenticationSession: session, DeviceID: r.Header.Get(c.DeviceIDHeader)}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		fingerprint := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+		device.CertificateFingerprint = hex.EncodeToString(fingerprint[:])
+	}
+
+	if device.DeviceID == "" && device.CertificateFingerprint == "" {
+		return errors.WithStack(helper.ErrForbidden.WithReason("The request does not identify a device."))
+	}
+
+	ttl, err := time.ParseDuration(c.CacheTTL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	payload, err := a.payload(c, device)
+	if err != nil {
+		return err
+	}
+
+	// The payload identifies the device as well as the subject, so verdicts are never shared between subjects.
+	key := x.FlightKey(c.Remote, string(payload), c.CompliantPath)
+	if item, found := a.verdicts.Get(key); found && ttl > 0 {
+		if verdict := item.(*devicePostureVerdict); verdict.ExpiresAt.After(time.Now()) {
+			return a.verdict(verdict.Compliant)
+		}
+		a.verdicts.Del(key)
+	}
+
+	compliant, err := a.check(r.Context(), c, payload)
+	if err != nil {
+		return err
+	}
+
+	if ttl > 0 {
+		a.verdicts.Set(key, &devicePostureVerdict{Compliant: compliant, ExpiresAt: time.Now().Add(ttl)}, 1)
+	}
+
+	return a.verdict(compliant)
+}
+
+func (a *AuthorizerDevicePosture) verdict(compliant bool) error {
+	if !compliant {
+		return errors.WithStack(helper.ErrForbidden.WithReason("The device is not compliant."))
+	}
+	return nil
+}
+
+func (a *AuthorizerDevicePosture) payload(c *AuthorizerDevicePostureConfiguration, device *DevicePostureSession) ([]byte, error) {
+	t, err := a.template(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	if err := t.Execute(&body, device); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var j json.RawMessage
+	if err := json.Unmarshal(body.Bytes(), &j); err != nil {
+		return nil, errors.Wrap(err, "payload is not a JSON text")
+	}
+
+	return body.Bytes(), nil
+}
+
+func (a *AuthorizerDevicePosture) check(ctx context.Context, c *AuthorizerDevicePostureConfiguration, payload []byte) (bool, error) {
+	req, err := http.NewRequest("POST", c.Remote, bytes.NewReader(payload))
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	if c.Signing != nil {
+		if err := credentials.SignRequest(ctx, a.signer(), req, payload, c.Signing); err != nil {
+			return false, err
+		}
+	}
+
+	res, err := a.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusNotFound {
+		// Unknown devices are treated like non-compliant ones.
+		return false, nil
+	} else if res.StatusCode != http.StatusOK {
+		return false, errors.Errorf("expected status code %d but got %d", http.StatusOK, res.StatusCode)
+	}
+
+	response, err := x.ReadResponse(res, a.c.RemoteResponseMaxBodySize())
+	if err != nil {
+		return false, err
+	}
+
+	return gjson.GetBytes(response, c.CompliantPath).Bool(), nil
+}
+
+// Stage implements the pipeline.Stager interface by compiling the payload template.
+func (a *AuthorizerDevicePosture) Stage(_ context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	c, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.template(c)
+	return err
+}
+
+func (a *AuthorizerDevicePosture) signer() credentials.Signer {
+	if a.d == nil {
+		return nil
+	}
+	return a.d.CredentialsSigner()
+}
+
+func (a *AuthorizerDevicePosture) template(c *AuthorizerDevicePostureConfiguration) (*template.Template, error) {
+	templateID := c.PayloadTemplateID()
+	t := a.t.Lookup(templateID)
+	if t == nil {
+		var err error
+		t, err = a.t.New(templateID).Parse(c.Payload)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return t, nil
+}
+
+// Validate implements the Authorizer interface.
+func (a *AuthorizerDevicePosture) Validate(config json.RawMessage) error {
+	if !a.c.AuthorizerIsEnabled(a.GetID()) {
+		return NewErrAuthorizerNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+// Config merges config and the authorizer's configuration and validates the
+// resulting configuration. It reports an error if the configuration is invalid.
+func (a *AuthorizerDevicePosture) Config(config json.RawMessage) (*AuthorizerDevicePostureConfiguration, error) {
+	var c AuthorizerDevicePostureConfiguration
+	if err := a.c.AuthorizerConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrAuthorizerMisconfigured(a, err)
+	}
+
+	if c.Payload == "" {
+		c.Payload = `{"device_id":{{ .DeviceID | toJson }},"certificate_fingerprint":{{ .CertificateFingerprint | toJson }},"subject":{{ .Subject | toJson }}}`
+	}
+
+	if c.DeviceIDHeader == "" {
+		c.DeviceIDHeader = "X-Device-Id"
+	}
+
+	if c.CompliantPath == "" {
+		c.CompliantPath = "compliant"
+	}
+
+	if c.CacheTTL == "" {
+		c.CacheTTL = "1m"
+	}
+
+	return &c, nil
+}
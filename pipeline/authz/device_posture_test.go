@@ -0,0 +1,200 @@
+package authz_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	. "github.com/ory/oathkeeper/pipeline/authz"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestAuthorizerDevicePostureAuthorize(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var device struct {
+			DeviceID    string `json:"device_id"`
+			Fingerprint string `json:"certificate_fingerprint"`
+			Subject     string `json:"subject"`
+		}
+		require.NoError(t, json.Unmarshal(body, &device))
+
+		switch {
+		case device.DeviceID == "compliant" || device.Fingerprint == "03d66dd08835c1ca3f128cceacd1f31ac94163096b20f445ae84285bc0832d72":
+			_, _ = w.Write([]byte(`{"compliant":true,"device":{"managed":true}}`))
+		case device.DeviceID == "shared":
+			_, _ = fmt.Fprintf(w, `{"compliant":%t}`, device.Subject == "alice")
+		case device.DeviceID == "unmanaged":
+			_, _ = w.Write([]byte(`{"compliant":true,"device":{"managed":false}}`))
+		case device.DeviceID == "unknown":
+			w.WriteHeader(http.StatusNotFound)
+		case device.DeviceID == "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = w.Write([]byte(`{"compliant":false}`))
+		}
+	}))
+	defer server.Close()
+
+	device := func(id string) *http.Request {
+		return &http.Request{Header: http.Header{"X-Device-Id": {id}}}
+	}
+
+	tests := []struct {
+		name    string
+		r       *http.Request
+		config  json.RawMessage
+		wantErr bool
+	}{
+		{
+			name:    "no device identity",
+			r:       &http.Request{Header: http.Header{}},
+			config:  json.RawMessage(`{}`),
+			wantErr: true,
+		},
+		{
+			name:   "compliant device",
+			r:      device("compliant"),
+			config: json.RawMessage(`{}`),
+		},
+		{
+			name:    "non-compliant device",
+			r:       device("jailbroken"),
+			config:  json.RawMessage(`{}`),
+			wantErr: true,
+		},
+		{
+			name:    "unknown device",
+			r:       device("unknown"),
+			config:  json.RawMessage(`{}`),
+			wantErr: true,
+		},
+		{
+			name:    "unexpected status code",
+			r:       device("broken"),
+			config:  json.RawMessage(`{"cache_ttl":"0s"}`),
+			wantErr: true,
+		},
+		{
+			name:    "custom compliant path",
+			r:       device("unmanaged"),
+			config:  json.RawMessage(`{"compliant_path":"device.managed"}`),
+			wantErr: true,
+		},
+		{
+			name:   "custom device id header",
+			r:      &http.Request{Header: http.Header{"X-Mdm-Device": {"compliant"}}},
+			config: json.RawMessage(`{"device_id_header":"X-Mdm-Device"}`),
+		},
+		{
+			name: "client certificate",
+			r: &http.Request{Header: http.Header{}, TLS: &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Raw: []byte("certificate")}},
+			}},
+			config: json.RawMessage(`{}`),
+		},
+		{
+			name:    "invalid payload",
+			r:       device("compliant"),
+			config:  json.RawMessage(`{"payload":"{"}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config, _ = sjson.SetBytes(tt.config, "remote", server.URL)
+
+			p := configuration.NewViperProvider(logrus.New())
+			a := NewAuthorizerDevicePosture(p, nil)
+			if err := a.Authorize(tt.r, &authn.AuthenticationSession{Subject: "alice"}, tt.config, &rule.Rule{}); (err != nil) != tt.wantErr {
+				t.Errorf("Authorize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("caches verdicts per device", func(t *testing.T) {
+		config, _ := sjson.SetBytes(json.RawMessage(`{}`), "remote", server.URL)
+		a := NewAuthorizerDevicePosture(configuration.NewViperProvider(logrus.New()), nil)
+
+		before := atomic.LoadInt32(&requests)
+		require.NoError(t, a.Authorize(device("compliant"), &authn.AuthenticationSession{}, config, &rule.Rule{}))
+		time.Sleep(time.Millisecond * 100) // give the cache buffers some time
+		require.NoError(t, a.Authorize(device("compliant"), &authn.AuthenticationSession{}, config, &rule.Rule{}))
+		assert.Equal(t, before+1, atomic.LoadInt32(&requests))
+
+		require.Error(t, a.Authorize(device("jailbroken"), &authn.AuthenticationSession{}, config, &rule.Rule{}))
+		assert.Equal(t, before+2, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("does not share verdicts between subjects of the same device", func(t *testing.T) {
+		config, _ := sjson.SetBytes(json.RawMessage(`{}`), "remote", server.URL)
+		a := NewAuthorizerDevicePosture(configuration.NewViperProvider(logrus.New()), nil)
+
+		require.NoError(t, a.Authorize(device("shared"), &authn.AuthenticationSession{Subject: "alice"}, config, &rule.Rule{}))
+		time.Sleep(time.Millisecond * 100) // give the cache buffers some time
+		require.Error(t, a.Authorize(device("shared"), &authn.AuthenticationSession{Subject: "mallory"}, config, &rule.Rule{}))
+		require.NoError(t, a.Authorize(device("shared"), &authn.AuthenticationSession{Subject: "alice"}, config, &rule.Rule{}))
+	})
+}
+
+func TestAuthorizerDevicePostureValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		config  json.RawMessage
+		wantErr bool
+	}{
+		{
+			name:    "disabled",
+			config:  json.RawMessage(`{"remote":"http://host/path"}`),
+			wantErr: true,
+		},
+		{
+			name:    "missing remote",
+			enabled: true,
+			config:  json.RawMessage(`{}`),
+			wantErr: true,
+		},
+		{
+			name:    "invalid cache ttl",
+			enabled: true,
+			config:  json.RawMessage(`{"remote":"http://host/path","cache_ttl":"forever"}`),
+			wantErr: true,
+		},
+		{
+			name:    "valid configuration",
+			enabled: true,
+			config:  json.RawMessage(`{"remote":"http://host/path"}`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := configuration.NewViperProvider(logrus.New())
+			a := NewAuthorizerDevicePosture(p, nil)
+			viper.Set(configuration.ViperKeyAuthorizerDevicePostureIsEnabled, tt.enabled)
+			if err := a.Validate(tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -40,7 +40,6 @@ import (
 	"github.com/ory/x/urlx"
 
 	"github.com/pkg/errors"
-	"github.com/tomasen/realip"
 
 	"github.com/ory/oathkeeper/helper"
 )
@@ -79,7 +78,7 @@ func NewAuthorizerKetoEngineACPORY(c configuration.Provider) *AuthorizerKetoEngi
 		client: httpx.NewResilientClientLatencyToleranceSmall(nil),
 		contextCreator: func(r *http.Request) map[string]interface{} {
 			return map[string]interface{}{
-				"remoteIpAddress": realip.RealIP(r),
+				"remoteIpAddress": x.ClientIP(r, c.TrustedProxies()),
 				"requestedAt":     time.Now().UTC(),
 			}
 		},
@@ -154,10 +153,14 @@ func (a *AuthorizerKetoEngineACPORY) Authorize(r *http.Request, session *authn.A
 		return errors.WithStack(err)
 	}
 
+	ctx, cancel := x.WithOptionalTimeout(r.Context(), a.c.RemoteResponseTimeout())
+	defer cancel()
+
 	req, err := http.NewRequest("POST", urlx.AppendPaths(baseURL, "/engines/acp/ory", flavor, "/allowed").String(), &b)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	req = req.WithContext(ctx)
 	req.Header.Add("Content-Type", "application/json")
 
 	res, err := a.client.Do(req)
@@ -175,8 +178,8 @@ func (a *AuthorizerKetoEngineACPORY) Authorize(r *http.Request, session *authn.A
 	var result struct {
 		Allowed bool `json:"allowed"`
 	}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return errors.WithStack(err)
+	if err := x.DecodeJSONResponse(res, a.c.RemoteResponseMaxBodySize(), &result); err != nil {
+		return err
 	}
 
 	if !result.Allowed {
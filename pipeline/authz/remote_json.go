@@ -2,6 +2,7 @@ package authz
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -9,9 +10,11 @@ import (
 	"text/template"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/ory/x/httpx"
 
+	"github.com/ory/oathkeeper/credentials"
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/helper"
 	"github.com/ory/oathkeeper/pipeline"
@@ -21,8 +24,12 @@ import (
 
 // AuthorizerRemoteJSONConfiguration represents a configuration for the remote_json authorizer.
 type AuthorizerRemoteJSONConfiguration struct {
-	Remote  string `json:"remote"`
-	Payload string `json:"payload"`
+	Remote  string                            `json:"remote"`
+	Payload string                            `json:"payload"`
+	Signing *credentials.RequestSigningConfig `json:"signing"`
+
+	// TLS configures how the certificate of the remote is verified.
+	TLS *x.TLSClientConfig `json:"tls"`
 }
 
 // PayloadTemplateID returns a string with which to associate the payload template.
@@ -30,18 +37,25 @@ func (c *AuthorizerRemoteJSONConfiguration) PayloadTemplateID() string {
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(c.Payload)))
 }
 
+type authorizerRemoteJSONDependencies interface {
+	credentials.SignerRegistry
+}
+
 // AuthorizerRemoteJSON implements the Authorizer interface.
 type AuthorizerRemoteJSON struct {
 	c configuration.Provider
+	d authorizerRemoteJSONDependencies
 
-	client *http.Client
-	t      *template.Template
+	client  *http.Client
+	t       *template.Template
+	flights singleflight.Group
 }
 
 // NewAuthorizerRemoteJSON creates a new AuthorizerRemoteJSON.
-func NewAuthorizerRemoteJSON(c configuration.Provider) *AuthorizerRemoteJSON {
+func NewAuthorizerRemoteJSON(c configuration.Provider, d authorizerRemoteJSONDependencies) *AuthorizerRemoteJSON {
 	return &AuthorizerRemoteJSON{
 		c:      c,
+		d:      d,
 		client: httpx.NewResilientClientLatencyToleranceSmall(nil),
 		t:      x.NewTemplate("remote_json"),
 	}
@@ -59,14 +73,9 @@ func (a *AuthorizerRemoteJSON) Authorize(_ *http.Request, session *authn.Authent
 		return err
 	}
 
-	templateID := c.PayloadTemplateID()
-	t := a.t.Lookup(templateID)
-	if t == nil {
-		var err error
-		t, err = a.t.New(templateID).Parse(c.Payload)
-		if err != nil {
-			return errors.WithStack(err)
-		}
+	t, err := a.template(c)
+	if err != nil {
+		return err
 	}
 
 	var body bytes.Buffer
@@ -79,25 +88,86 @@ func (a *AuthorizerRemoteJSON) Authorize(_ *http.Request, session *authn.Authent
 		return errors.Wrap(err, "payload is not a JSON text")
 	}
 
-	req, err := http.NewRequest("POST", c.Remote, &body)
+	payload := body.Bytes()
+	signing, err := json.Marshal(c.Signing)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	req.Header.Add("Content-Type", "application/json")
 
-	res, err := a.client.Do(req)
+	client := a.client
+	if !c.TLS.IsZero() {
+		transport, err := x.TLSTransport(c.TLS)
+		if err != nil {
+			return err
+		}
+		client = httpx.NewResilientClientLatencyToleranceSmall(transport)
+	}
+
+	// Identical concurrent checks are collapsed into one request to the remote. The result is shared, so the request
+	// must not be canceled with the request which started it.
+	_, err, _ = a.flights.Do(x.FlightKey(c.Remote, string(payload), string(signing)), func() (interface{}, error) {
+		ctx, cancel := x.WithOptionalTimeout(context.Background(), a.c.RemoteResponseTimeout())
+		defer cancel()
+
+		req, err := http.NewRequest("POST", c.Remote, bytes.NewReader(payload))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		req = req.WithContext(ctx)
+		req.Header.Add("Content-Type", "application/json")
+
+		if c.Signing != nil {
+			if err := credentials.SignRequest(ctx, a.signer(), req, payload, c.Signing); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode == http.StatusForbidden {
+			return nil, errors.WithStack(helper.ErrForbidden)
+		} else if res.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("expected status code %d but got %d", http.StatusOK, res.StatusCode)
+		}
+
+		return nil, nil
+	})
+	return err
+}
+
+// Stage implements the pipeline.Stager interface by compiling the payload template.
+func (a *AuthorizerRemoteJSON) Stage(_ context.Context, config json.RawMessage, _ pipeline.Rule) error {
+	c, err := a.Config(config)
 	if err != nil {
-		return errors.WithStack(err)
+		return err
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode == http.StatusForbidden {
-		return errors.WithStack(helper.ErrForbidden)
-	} else if res.StatusCode != http.StatusOK {
-		return errors.Errorf("expected status code %d but got %d", http.StatusOK, res.StatusCode)
+	_, err = a.template(c)
+	return err
+}
+
+func (a *AuthorizerRemoteJSON) signer() credentials.Signer {
+	if a.d == nil {
+		return nil
 	}
+	return a.d.CredentialsSigner()
+}
 
-	return nil
+func (a *AuthorizerRemoteJSON) template(c *AuthorizerRemoteJSONConfiguration) (*template.Template, error) {
+	templateID := c.PayloadTemplateID()
+	t := a.t.Lookup(templateID)
+	if t == nil {
+		var err error
+		t, err = a.t.New(templateID).Parse(c.Payload)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return t, nil
 }
 
 // Validate implements the Authorizer interface.
@@ -5,7 +5,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -127,6 +130,23 @@ func TestAuthorizerRemoteJSONAuthorize(t *testing.T) {
 			session: &authn.AuthenticationSession{},
 			config:  json.RawMessage(`{"payload":"[\"foo\",\"bar\"]"}`),
 		},
+		{
+			name: "signed request",
+			setup: func(t *testing.T) *httptest.Server {
+				return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					assert.Regexp(t, `^t=[0-9]+,v1=[0-9a-f]{64}$`, r.Header.Get("X-Signature"))
+					w.WriteHeader(http.StatusOK)
+				}))
+			},
+			session: &authn.AuthenticationSession{},
+			config:  json.RawMessage(`{"payload":"{}","signing":{"type":"hmac","secret":"secret","header":"X-Signature"}}`),
+		},
+		{
+			name:    "invalid signing configuration",
+			session: &authn.AuthenticationSession{},
+			config:  json.RawMessage(`{"remote":"http://host/path","payload":"{}","signing":{"type":"hmac"}}`),
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -137,7 +157,7 @@ func TestAuthorizerRemoteJSONAuthorize(t *testing.T) {
 			}
 
 			p := configuration.NewViperProvider(logrus.New())
-			a := NewAuthorizerRemoteJSON(p)
+			a := NewAuthorizerRemoteJSON(p, nil)
 			if err := a.Authorize(&http.Request{}, tt.session, tt.config, &rule.Rule{}); (err != nil) != tt.wantErr {
 				t.Errorf("Authorize() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -145,6 +165,31 @@ func TestAuthorizerRemoteJSONAuthorize(t *testing.T) {
 	}
 }
 
+func TestAuthorizerRemoteJSONAuthorizeCollapsesConcurrentChecks(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(time.Millisecond * 100)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	config, _ := sjson.SetBytes(json.RawMessage(`{"payload":"{\"subject\":\"{{ .Subject }}\"}"}`), "remote", server.URL)
+	a := NewAuthorizerRemoteJSON(configuration.NewViperProvider(logrus.New()), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Error(t, a.Authorize(&http.Request{}, &authn.AuthenticationSession{Subject: "alice"}, config, &rule.Rule{}))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
 func TestAuthorizerRemoteJSONValidate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -190,7 +235,7 @@ func TestAuthorizerRemoteJSONValidate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := configuration.NewViperProvider(logrus.New())
-			a := NewAuthorizerRemoteJSON(p)
+			a := NewAuthorizerRemoteJSON(p, nil)
 			viper.Set(configuration.ViperKeyAuthorizerRemoteJSONIsEnabled, tt.enabled)
 			if err := a.Validate(tt.config); (err != nil) != tt.wantErr {
 				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
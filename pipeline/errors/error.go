@@ -2,6 +2,7 @@ package errors
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/pkg/errors"
@@ -36,3 +37,13 @@ func NewErrErrorHandlerMisconfigured(a Handler, err error) *herodot.DefaultError
 		err,
 	)
 }
+
+// setDocsURLLink links the documentation of the rule, if any, in the Link header of the response and returns its URL.
+func setDocsURLLink(w http.ResponseWriter, rl pipeline.Rule) string {
+	if rl == nil || rl.GetDocsURL() == "" {
+		return ""
+	}
+
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="help"`, rl.GetDocsURL()))
+	return rl.GetDocsURL()
+}
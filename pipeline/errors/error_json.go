@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/pkg/errors"
+
 	"github.com/ory/herodot"
 	"github.com/ory/x/errorsx"
 
@@ -34,7 +36,7 @@ func NewErrorJSON(
 	return &ErrorJSON{c: c, d: d}
 }
 
-func (a *ErrorJSON) Handle(w http.ResponseWriter, r *http.Request, config json.RawMessage, _ pipeline.Rule, handleError error) error {
+func (a *ErrorJSON) Handle(w http.ResponseWriter, r *http.Request, config json.RawMessage, rl pipeline.Rule, handleError error) error {
 	c, err := a.Config(config)
 	if err != nil {
 		return err
@@ -63,6 +65,14 @@ func (a *ErrorJSON) Handle(w http.ResponseWriter, r *http.Request, config json.R
 		}
 	}
 
+	if u := setDocsURLLink(w, rl); u != "" {
+		var e *herodot.DefaultError
+		if !errors.As(handleError, &e) {
+			e = herodot.ToDefaultError(handleError, "")
+		}
+		handleError = e.WithDetail("docs_url", u)
+	}
+
 	a.d.Writer().WriteError(w, r, handleError)
 	return nil
 }
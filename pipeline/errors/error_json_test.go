@@ -13,6 +13,8 @@ import (
 	"github.com/ory/herodot"
 
 	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/rule"
 )
 
 func TestErrorJSON(t *testing.T) {
@@ -29,6 +31,7 @@ func TestErrorJSON(t *testing.T) {
 			config      string
 			expectError error
 			givenError  error
+			rule        pipeline.Rule
 			assert      func(t *testing.T, recorder *httptest.ResponseRecorder)
 		}{
 			{
@@ -62,12 +65,24 @@ func TestErrorJSON(t *testing.T) {
 					assert.Equal(t, int64(404), gjson.Get(body, "error.code").Int())
 				},
 			},
+			{
+				d:          "should link the documentation of the rule",
+				givenError: herodot.ErrForbidden.WithReasonf("this should not show up in the response"),
+				rule:       &rule.Rule{ID: "payments", DocsURL: "https://runbooks.example.org/payments"},
+				assert: func(t *testing.T, rw *httptest.ResponseRecorder) {
+					body := rw.Body.String()
+					assert.Equal(t, `<https://runbooks.example.org/payments>; rel="help"`, rw.Header().Get("Link"))
+					assert.Equal(t, "https://runbooks.example.org/payments", gjson.Get(body, "error.details.docs_url").String())
+					assert.Empty(t, gjson.Get(body, "error.reason").String())
+					assert.Equal(t, int64(403), gjson.Get(body, "error.code").Int())
+				},
+			},
 		} {
 			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
 				w := httptest.NewRecorder()
 				r := httptest.NewRequest("GET", "/test", nil)
 
-				err := a.Handle(w, r, json.RawMessage(tc.config), nil, tc.givenError)
+				err := a.Handle(w, r, json.RawMessage(tc.config), tc.rule, tc.givenError)
 				if tc.expectError != nil {
 					require.EqualError(t, err, tc.expectError.Error(), "%+v", err)
 					return
@@ -14,7 +14,8 @@ var _ Handler = new(ErrorWWWAuthenticate)
 
 type (
 	ErrorWWWAuthenticateConfig struct {
-		Realm string `json:"realm"`
+		Realm  string `json:"realm"`
+		Scheme string `json:"scheme"`
 	}
 	ErrorWWWAuthenticate struct {
 		c configuration.Provider
@@ -32,13 +33,19 @@ func NewErrorWWWAuthenticate(
 	return &ErrorWWWAuthenticate{c: c, d: d}
 }
 
-func (a *ErrorWWWAuthenticate) Handle(w http.ResponseWriter, r *http.Request, config json.RawMessage, _ pipeline.Rule, _ error) error {
+func (a *ErrorWWWAuthenticate) Handle(w http.ResponseWriter, r *http.Request, config json.RawMessage, rl pipeline.Rule, _ error) error {
 	c, err := a.Config(config)
 	if err != nil {
 		return err
 	}
 
-	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%s`, c.Realm))
+	if c.Scheme == "Negotiate" {
+		// The Negotiate (SPNEGO) challenge has no parameters.
+		w.Header().Set("WWW-Authenticate", c.Scheme)
+	} else {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`%s realm=%s`, c.Scheme, c.Realm))
+	}
+	setDocsURLLink(w, rl)
 	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 	return nil
 }
@@ -61,6 +68,10 @@ func (a *ErrorWWWAuthenticate) Config(config json.RawMessage) (*ErrorWWWAuthenti
 		c.Realm = "Please authenticate."
 	}
 
+	if c.Scheme == "" {
+		c.Scheme = "Basic"
+	}
+
 	return &c, nil
 }
 
@@ -49,6 +49,15 @@ func TestErrorWWWAuthenticate(t *testing.T) {
 					assert.Equal(t, "Basic realm=foobar", rw.Header().Get("WWW-Authenticate"))
 				},
 			},
+			{
+				d:          "should respond with a 401 negotiate challenge",
+				config:     `{"scheme": "Negotiate"}`,
+				givenError: &herodot.ErrNotFound,
+				assert: func(t *testing.T, rw *httptest.ResponseRecorder) {
+					assert.Equal(t, 401, rw.Code)
+					assert.Equal(t, "Negotiate", rw.Header().Get("WWW-Authenticate"))
+				},
+			},
 		} {
 			t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
 				w := httptest.NewRecorder()
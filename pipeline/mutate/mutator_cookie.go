@@ -2,6 +2,7 @@ package mutate
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -53,16 +54,9 @@ func (a *MutatorCookie) Mutate(r *http.Request, session *authn.AuthenticationSes
 	}
 
 	for cookie, templateString := range cfg.Cookies {
-		var tmpl *template.Template
-		var err error
-
-		templateId := fmt.Sprintf("%s:%s", rl.GetID(), cookie)
-		tmpl = a.t.Lookup(templateId)
-		if tmpl == nil {
-			tmpl, err = a.t.New(templateId).Parse(templateString)
-			if err != nil {
-				return errors.Wrapf(err, `error parsing cookie template "%s" in rule "%s"`, templateString, rl.GetID())
-			}
+		tmpl, err := a.template(rl, cookie, templateString)
+		if err != nil {
+			return err
 		}
 
 		cookieValue := bytes.Buffer{}
@@ -93,6 +87,35 @@ func (a *MutatorCookie) Mutate(r *http.Request, session *authn.AuthenticationSes
 	return nil
 }
 
+// Stage implements the pipeline.Stager interface by compiling all cookie templates.
+func (a *MutatorCookie) Stage(_ context.Context, config json.RawMessage, rl pipeline.Rule) error {
+	cfg, err := a.config(config)
+	if err != nil {
+		return err
+	}
+
+	for cookie, templateString := range cfg.Cookies {
+		if _, err := a.template(rl, cookie, templateString); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *MutatorCookie) template(rl pipeline.Rule, cookie, templateString string) (*template.Template, error) {
+	templateId := fmt.Sprintf("%s:%s", rl.GetID(), cookie)
+	tmpl := a.t.Lookup(templateId)
+	if tmpl == nil {
+		var err error
+		tmpl, err = a.t.New(templateId).Parse(templateString)
+		if err != nil {
+			return nil, errors.Wrapf(err, `error parsing cookie template "%s" in rule "%s"`, templateString, rl.GetID())
+		}
+	}
+	return tmpl, nil
+}
+
 func (a *MutatorCookie) Validate(config json.RawMessage) error {
 	if !a.c.MutatorIsEnabled(a.GetID()) {
 		return NewErrMutatorNotEnabled(a)
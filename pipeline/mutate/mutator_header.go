@@ -1,11 +1,14 @@
 package mutate
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"text/template"
+	"text/template/parse"
 
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/pipeline"
@@ -22,10 +25,35 @@ type MutatorHeaderConfig struct {
 type MutatorHeader struct {
 	c configuration.Provider
 	t *template.Template
+
+	sync.RWMutex
+	compiled map[headerKey]*compiledHeader
+}
+
+type headerKey struct {
+	rule, header, template string
+}
+
+// compiledHeader is a parsed header template. The value of templates without actions is computed once.
+type compiledHeader struct {
+	tmpl   *template.Template
+	static bool
+	value  string
+
+	// size is the largest value rendered so far and is used to size the buffer of the next execution.
+	size int64
+}
+
+// headerBuffer is an io.Writer appending to a byte slice, it avoids the bookkeeping of bytes.Buffer.
+type headerBuffer []byte
+
+func (b *headerBuffer) Write(p []byte) (int, error) {
+	*b = append(*b, p...)
+	return len(p), nil
 }
 
 func NewMutatorHeader(c configuration.Provider) *MutatorHeader {
-	return &MutatorHeader{c: c, t: x.NewTemplate("header")}
+	return &MutatorHeader{c: c, t: x.NewTemplate("header"), compiled: map[headerKey]*compiledHeader{}}
 }
 
 func (a *MutatorHeader) GetID() string {
@@ -33,7 +61,10 @@ func (a *MutatorHeader) GetID() string {
 }
 
 func (a *MutatorHeader) WithCache(t *template.Template) {
+	a.Lock()
+	defer a.Unlock()
 	a.t = t
+	a.compiled = map[headerKey]*compiledHeader{}
 }
 
 func (a *MutatorHeader) Mutate(r *http.Request, session *authn.AuthenticationSession, config json.RawMessage, rl pipeline.Rule) error {
@@ -43,29 +74,105 @@ func (a *MutatorHeader) Mutate(r *http.Request, session *authn.AuthenticationSes
 	}
 
 	for hdr, templateString := range cfg.Headers {
-		var tmpl *template.Template
-		var err error
+		compiled, err := a.compile(rl, hdr, templateString)
+		if err != nil {
+			return err
+		}
 
-		templateId := fmt.Sprintf("%s:%s", rl.GetID(), hdr)
-		tmpl = a.t.Lookup(templateId)
-		if tmpl == nil {
-			tmpl, err = a.t.New(templateId).Parse(templateString)
-			if err != nil {
-				return errors.Wrapf(err, `error parsing headers template "%s" in rule "%s"`, templateString, rl.GetID())
-			}
+		if compiled.static {
+			session.SetHeader(hdr, compiled.value)
+			continue
 		}
 
-		headerValue := bytes.Buffer{}
-		err = tmpl.Execute(&headerValue, session)
-		if err != nil {
+		headerValue := make(headerBuffer, 0, atomic.LoadInt64(&compiled.size))
+		if err := compiled.tmpl.Execute(&headerValue, session); err != nil {
 			return errors.Wrapf(err, `error executing headers template "%s" in rule "%s"`, templateString, rl.GetID())
 		}
-		session.SetHeader(hdr, headerValue.String())
+		if size := int64(len(headerValue)); size > atomic.LoadInt64(&compiled.size) {
+			atomic.StoreInt64(&compiled.size, size)
+		}
+		session.SetHeader(hdr, string(headerValue))
+	}
+
+	return nil
+}
+
+// Stage implements the pipeline.Stager interface by compiling all header templates.
+func (a *MutatorHeader) Stage(_ context.Context, config json.RawMessage, rl pipeline.Rule) error {
+	cfg, err := a.config(config)
+	if err != nil {
+		return err
+	}
+
+	for hdr, templateString := range cfg.Headers {
+		if _, err := a.compile(rl, hdr, templateString); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// compile returns the compiled template of a header, parsing it on first use.
+func (a *MutatorHeader) compile(rl pipeline.Rule, hdr, templateString string) (*compiledHeader, error) {
+	key := headerKey{rule: rl.GetID(), header: hdr, template: templateString}
+
+	a.RLock()
+	compiled, ok := a.compiled[key]
+	a.RUnlock()
+	if ok {
+		return compiled, nil
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	if compiled, ok := a.compiled[key]; ok {
+		return compiled, nil
+	}
+
+	tmpl, err := a.template(rl, hdr, templateString)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled = &compiledHeader{tmpl: tmpl}
+	if value, ok := staticValue(tmpl); ok {
+		compiled.static = true
+		compiled.value = value
+	}
+	a.compiled[key] = compiled
+	return compiled, nil
+}
+
+// staticValue returns the value of a template consisting only of text.
+func staticValue(tmpl *template.Template) (string, bool) {
+	if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+		return "", false
+	}
+	switch nodes := tmpl.Tree.Root.Nodes; len(nodes) {
+	case 0:
+		return "", true
+	case 1:
+		if text, ok := nodes[0].(*parse.TextNode); ok {
+			return string(text.Text), true
+		}
+	}
+	return "", false
+}
+
+func (a *MutatorHeader) template(rl pipeline.Rule, hdr, templateString string) (*template.Template, error) {
+	templateId := fmt.Sprintf("%s:%s", rl.GetID(), hdr)
+	tmpl := a.t.Lookup(templateId)
+	if tmpl == nil {
+		var err error
+		tmpl, err = a.t.New(templateId).Parse(templateString)
+		if err != nil {
+			return nil, errors.Wrapf(err, `error parsing headers template "%s" in rule "%s"`, templateString, rl.GetID())
+		}
+	}
+	return tmpl, nil
+}
+
 func (a *MutatorHeader) Validate(config json.RawMessage) error {
 	if !a.c.MutatorIsEnabled(a.GetID()) {
 		return NewErrMutatorNotEnabled(a)
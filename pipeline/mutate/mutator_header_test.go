@@ -226,3 +226,47 @@ func TestCredentialsIssuerHeaders(t *testing.T) {
 		require.Error(t, a.Validate(json.RawMessage(`{"headers":{}}`)))
 	})
 }
+
+func BenchmarkMutatorHeader(b *testing.B) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineMutator("header")
+	require.NoError(b, err)
+
+	session := &authn.AuthenticationSession{Subject: "foo", Extra: map[string]interface{}{"iss": "issuer"}}
+	for name, config := range map[string]json.RawMessage{
+		"static":  json.RawMessage(`{"headers":{"X-Tenant":"example"}}`),
+		"dynamic": json.RawMessage(`{"headers":{"X-User":"{{ print .Subject }}","X-Issuer":"{{ print .Extra.iss }}"}}`),
+	} {
+		b.Run("headers="+name, func(b *testing.B) {
+			rl := &rule.Rule{ID: "benchmark-" + name}
+			r := &http.Request{Header: http.Header{}}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := a.Mutate(r, session, config, rl); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestMutatorHeaderStatic(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineMutator("header")
+	require.NoError(t, err)
+
+	session := &authn.AuthenticationSession{Subject: "foo"}
+	config := json.RawMessage(`{"headers":{"X-Tenant":"example","X-Empty":"","X-User":"{{ print .Subject }}"}}`)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, a.Mutate(&http.Request{Header: http.Header{}}, session, config, &rule.Rule{ID: "static-rule"}))
+		assert.Equal(t, "example", session.Header.Get("X-Tenant"))
+		assert.Equal(t, "", session.Header.Get("X-Empty"))
+		assert.Equal(t, "foo", session.Header.Get("X-User"))
+	}
+}
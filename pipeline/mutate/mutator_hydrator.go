@@ -34,6 +34,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/ory/oathkeeper/credentials"
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/pipeline"
 )
@@ -72,9 +73,11 @@ type retryConfig struct {
 }
 
 type externalAPIConfig struct {
-	URL   string       `json:"url"`
-	Auth  *auth        `json:"auth"`
-	Retry *retryConfig `json:"retry"`
+	URL     string                            `json:"url"`
+	Auth    *auth                             `json:"auth"`
+	Retry   *retryConfig                      `json:"retry"`
+	Signing *credentials.RequestSigningConfig `json:"signing"`
+	TLS     *x.TLSClientConfig                `json:"tls"`
 }
 
 type MutatorHydratorConfig struct {
@@ -83,6 +86,7 @@ type MutatorHydratorConfig struct {
 
 type mutatorHydratorDependencies interface {
 	x.RegistryLogger
+	credentials.SignerRegistry
 }
 
 func NewMutatorHydrator(c configuration.Provider, d mutatorHydratorDependencies) *MutatorHydrator {
@@ -109,10 +113,15 @@ func (a *MutatorHydrator) Mutate(r *http.Request, session *authn.AuthenticationS
 	} else if _, err := url.ParseRequestURI(cfg.Api.URL); err != nil {
 		return errors.New(ErrInvalidAPIURL)
 	}
+	payload := b.Bytes()
+	ctx, cancel := x.WithOptionalTimeout(r.Context(), a.c.RemoteResponseTimeout())
+	defer cancel()
+
 	req, err := http.NewRequest("POST", cfg.Api.URL, &b)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	req = req.WithContext(ctx)
 	for key, values := range r.Header {
 		for _, value := range values {
 			req.Header.Add(key, value)
@@ -123,8 +132,21 @@ func (a *MutatorHydrator) Mutate(r *http.Request, session *authn.AuthenticationS
 		req.SetBasicAuth(credentials.Username, credentials.Password)
 	}
 	req.Header.Set(contentTypeHeaderKey, contentTypeJSONHeaderValue)
+	if cfg.Api.Signing != nil {
+		if err := credentials.SignRequest(r.Context(), a.d.CredentialsSigner(), req, payload, cfg.Api.Signing); err != nil {
+			return err
+		}
+	}
 
 	var client http.Client
+	transport := a.client.Transport
+	if !cfg.Api.TLS.IsZero() {
+		if transport, err = x.TLSTransport(cfg.Api.TLS); err != nil {
+			return err
+		}
+		client.Transport = transport
+	}
+
 	if cfg.Api.Retry != nil {
 		maxRetryDelay := time.Second
 		giveUpAfter := time.Millisecond * 50
@@ -143,7 +165,7 @@ func (a *MutatorHydrator) Mutate(r *http.Request, session *authn.AuthenticationS
 			}
 		}
 
-		client.Transport = httpx.NewResilientRoundTripper(a.client.Transport, maxRetryDelay, giveUpAfter)
+		client.Transport = httpx.NewResilientRoundTripper(transport, maxRetryDelay, giveUpAfter)
 	}
 
 	res, err := client.Do(req)
@@ -165,9 +187,8 @@ func (a *MutatorHydrator) Mutate(r *http.Request, session *authn.AuthenticationS
 	}
 
 	sessionFromUpstream := authn.AuthenticationSession{}
-	err = json.NewDecoder(res.Body).Decode(&sessionFromUpstream)
-	if err != nil {
-		return errors.WithStack(err)
+	if err := x.DecodeJSONResponse(res, a.c.RemoteResponseMaxBodySize(), &sessionFromUpstream); err != nil {
+		return err
 	}
 	if sessionFromUpstream.Subject != session.Subject {
 		return errors.New(ErrMalformedResponseFromUpstreamAPI)
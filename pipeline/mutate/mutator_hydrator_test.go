@@ -324,6 +324,25 @@ func TestMutatorHydrator(t *testing.T) {
 				Match:   newAuthenticationSession(setExtra(sampleKey, sampleValue)),
 				Err:     nil,
 			},
+			"Signed Request": {
+				Setup: func(t *testing.T) http.Handler {
+					router := httprouter.New()
+					router.POST("/", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+						assert.Regexp(t, `^t=[0-9]+,v1=[0-9a-f]{64}$`, r.Header.Get("X-Oathkeeper-Signature"))
+						h := defaultRouterSetup(setExtra(sampleKey, sampleValue))(t)
+						h.ServeHTTP(w, r)
+					})
+					return router
+				},
+				Session: newAuthenticationSession(),
+				Rule:    &rule.Rule{ID: "test-rule"},
+				Config: func(s *httptest.Server) json.RawMessage {
+					return []byte(fmt.Sprintf(`{"api": {"url": "%s", "signing": {"type": "hmac", "secret": "secret"}}}`, s.URL))
+				},
+				Request: &http.Request{},
+				Match:   newAuthenticationSession(setExtra(sampleKey, sampleValue)),
+				Err:     nil,
+			},
 		}
 
 		for testName, specs := range testMap {
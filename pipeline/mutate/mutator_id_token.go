@@ -22,11 +22,13 @@ package mutate
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
 	"text/template"
 	"time"
 
@@ -41,11 +43,13 @@ import (
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/pipeline"
 	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/revocation"
 	"github.com/ory/oathkeeper/x"
 )
 
 type MutatorIDTokenRegistry interface {
 	credentials.SignerRegistry
+	credentials.FetcherRegistry
 }
 
 type MutatorIDToken struct {
@@ -55,6 +59,11 @@ type MutatorIDToken struct {
 
 	tokenCache        *ristretto.Cache
 	tokenCacheEnabled bool
+
+	// generations is incremented per subject whenever its sessions are revoked which renders
+	// previously cached tokens of the subject unreachable.
+	generations     map[string]int
+	generationsLock sync.RWMutex
 }
 
 type CredentialsIDTokenConfig struct {
@@ -74,7 +83,7 @@ func NewMutatorIDToken(c configuration.Provider, r MutatorIDTokenRegistry) *Muta
 		MaxCost:     1 << 25,
 		BufferItems: 64,
 	})
-	return &MutatorIDToken{r: r, c: c, templates: x.NewTemplate("id_token"), tokenCache: cache, tokenCacheEnabled: true}
+	return &MutatorIDToken{r: r, c: c, templates: x.NewTemplate("id_token"), tokenCache: cache, tokenCacheEnabled: true, generations: map[string]int{}}
 }
 
 func (a *MutatorIDToken) GetID() string {
@@ -96,11 +105,28 @@ type idTokenCacheContainer struct {
 }
 
 func (a *MutatorIDToken) cacheKey(config *CredentialsIDTokenConfig, ttl time.Duration, claims []byte, session *authn.AuthenticationSession) string {
+	a.generationsLock.RLock()
+	generation := a.generations[session.Subject]
+	a.generationsLock.RUnlock()
+
 	return fmt.Sprintf("%x",
-		md5.Sum([]byte(fmt.Sprintf("%s|%s|%s|%s|%s", config.IssuerURL, ttl, config.JWKSURL, claims, session.Subject))),
+		md5.Sum([]byte(fmt.Sprintf("%s|%s|%s|%s|%s|%d", config.IssuerURL, ttl, config.JWKSURL, claims, session.Subject, generation))),
 	)
 }
 
+// Invalidate implements the revocation.Invalidator interface by discarding all cached tokens of the revoked subject.
+func (a *MutatorIDToken) Invalidate(e revocation.Event) {
+	if e.Subject == "" {
+		// The session ID is not part of the cache key, so we can not tell which tokens belong to the session.
+		a.tokenCache.Clear()
+		return
+	}
+
+	a.generationsLock.Lock()
+	a.generations[e.Subject]++
+	a.generationsLock.Unlock()
+}
+
 func (a *MutatorIDToken) tokenFromCache(config *CredentialsIDTokenConfig, session *authn.AuthenticationSession, claims []byte, ttl time.Duration) (string, bool) {
 	if !a.tokenCacheEnabled {
 		return "", false
@@ -149,13 +175,9 @@ func (a *MutatorIDToken) Mutate(r *http.Request, session *authn.AuthenticationSe
 
 	var templateClaims []byte
 	if len(c.Claims) > 0 {
-		t := a.templates.Lookup(c.ClaimsTemplateID())
-		if t == nil {
-			var err error
-			t, err = a.templates.New(c.ClaimsTemplateID()).Parse(c.Claims)
-			if err != nil {
-				return errors.Wrapf(err, `error parsing claims template in rule "%s"`, rl.GetID())
-			}
+		t, err := a.claimsTemplate(c, rl)
+		if err != nil {
+			return err
 		}
 
 		var b bytes.Buffer
@@ -198,6 +220,48 @@ func (a *MutatorIDToken) Mutate(r *http.Request, session *authn.AuthenticationSe
 	return nil
 }
 
+// Stage implements the pipeline.Stager interface by compiling the claims template and
+// by making sure that the signing key is available.
+func (a *MutatorIDToken) Stage(ctx context.Context, config json.RawMessage, rl pipeline.Rule) error {
+	c, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	if _, err := time.ParseDuration(c.TTL); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(c.Claims) > 0 {
+		if _, err := a.claimsTemplate(c, rl); err != nil {
+			return err
+		}
+	}
+
+	jwks, err := url.Parse(c.JWKSURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err := a.r.CredentialsFetcher().ResolveSets(ctx, []url.URL{*jwks}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *MutatorIDToken) claimsTemplate(c *CredentialsIDTokenConfig, rl pipeline.Rule) (*template.Template, error) {
+	t := a.templates.Lookup(c.ClaimsTemplateID())
+	if t == nil {
+		var err error
+		t, err = a.templates.New(c.ClaimsTemplateID()).Parse(c.Claims)
+		if err != nil {
+			return nil, errors.Wrapf(err, `error parsing claims template in rule "%s"`, rl.GetID())
+		}
+	}
+	return t, nil
+}
+
 func (a *MutatorIDToken) Validate(config json.RawMessage) error {
 	if !a.c.MutatorIsEnabled(a.GetID()) {
 		return NewErrMutatorNotEnabled(a)
@@ -48,6 +48,7 @@ import (
 	"github.com/ory/oathkeeper/internal"
 	"github.com/ory/oathkeeper/pipeline/authn"
 	. "github.com/ory/oathkeeper/pipeline/mutate"
+	"github.com/ory/oathkeeper/revocation"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -324,6 +325,13 @@ func TestMutatorIDToken(t *testing.T) {
 				config, _ := sjson.SetBytes(config, "jwks_url", "file://../../test/stub/jwks-hs.json")
 				assert.NotEqual(t, prev, mutate(t, *session, config))
 			})
+
+			t.Run("subcase=different tokens because subject was invalidated", func(t *testing.T) {
+				prev := mutate(t, *session, config)
+				time.Sleep(time.Second)
+				a.(*MutatorIDToken).Invalidate(revocation.Event{Subject: session.Subject})
+				assert.NotEqual(t, prev, mutate(t, *session, config))
+			})
 		})
 
 		t.Run("case=ensure template cache", func(t *testing.T) {
@@ -0,0 +1,168 @@
+package mutate
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/x"
+)
+
+const (
+	// JWTPayloadFormatPayload is the base64url encoded JSON payload, as in Istio's `x-jwt-payload` header.
+	JWTPayloadFormatPayload = "payload"
+
+	// JWTPayloadFormatUnsignedJWT is a JSON Web Token using the "none" algorithm, i.e. without a signature.
+	JWTPayloadFormatUnsignedJWT = "unsigned_jwt"
+)
+
+// unsignedJWTHeader is the base64url encoded JOSE header `{"alg":"none","typ":"JWT"}`.
+var unsignedJWTHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+
+type MutatorJWTPayloadConfig struct {
+	Header    string `json:"header"`
+	Format    string `json:"format"`
+	Claims    string `json:"claims"`
+	IssuerURL string `json:"issuer_url"`
+	TTL       string `json:"ttl"`
+}
+
+func (c *MutatorJWTPayloadConfig) ClaimsTemplateID() string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(c.Claims)))
+}
+
+// MutatorJWTPayload forwards the claims of the session in a header without signing them. It must only be used if
+// the upstream can not be reached without passing ORY Oathkeeper.
+type MutatorJWTPayload struct {
+	c         configuration.Provider
+	templates *template.Template
+}
+
+func NewMutatorJWTPayload(c configuration.Provider) *MutatorJWTPayload {
+	return &MutatorJWTPayload{c: c, templates: x.NewTemplate("jwt_payload")}
+}
+
+func (a *MutatorJWTPayload) GetID() string {
+	return "jwt_payload"
+}
+
+func (a *MutatorJWTPayload) WithCache(t *template.Template) {
+	a.templates = t
+}
+
+func (a *MutatorJWTPayload) Mutate(_ *http.Request, session *authn.AuthenticationSession, config json.RawMessage, rl pipeline.Rule) error {
+	c, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	ttl, err := time.ParseDuration(c.TTL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	claims := map[string]interface{}{}
+	if len(c.Claims) > 0 {
+		t, err := a.claimsTemplate(c, rl)
+		if err != nil {
+			return err
+		}
+
+		var b bytes.Buffer
+		if err := t.Execute(&b, session); err != nil {
+			return errors.Wrapf(err, `error executing claims template in rule "%s"`, rl.GetID())
+		}
+
+		if err := json.NewDecoder(&b).Decode(&claims); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	now := time.Now().UTC()
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["sub"] = session.Subject
+	if c.IssuerURL != "" {
+		claims["iss"] = c.IssuerURL
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(payload)
+	if c.Format == JWTPayloadFormatUnsignedJWT {
+		value = unsignedJWTHeader + "." + value + "."
+	}
+
+	session.SetHeader(c.Header, value)
+	return nil
+}
+
+// Stage implements the pipeline.Stager interface by compiling the claims template.
+func (a *MutatorJWTPayload) Stage(_ context.Context, config json.RawMessage, rl pipeline.Rule) error {
+	c, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	if len(c.Claims) > 0 {
+		if _, err := a.claimsTemplate(c, rl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *MutatorJWTPayload) claimsTemplate(c *MutatorJWTPayloadConfig, rl pipeline.Rule) (*template.Template, error) {
+	t := a.templates.Lookup(c.ClaimsTemplateID())
+	if t == nil {
+		var err error
+		t, err = a.templates.New(c.ClaimsTemplateID()).Parse(c.Claims)
+		if err != nil {
+			return nil, errors.Wrapf(err, `error parsing claims template in rule "%s"`, rl.GetID())
+		}
+	}
+	return t, nil
+}
+
+func (a *MutatorJWTPayload) Validate(config json.RawMessage) error {
+	if !a.c.MutatorIsEnabled(a.GetID()) {
+		return NewErrMutatorNotEnabled(a)
+	}
+
+	_, err := a.Config(config)
+	return err
+}
+
+func (a *MutatorJWTPayload) Config(config json.RawMessage) (*MutatorJWTPayloadConfig, error) {
+	var c MutatorJWTPayloadConfig
+	if err := a.c.MutatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrMutatorMisconfigured(a, err)
+	}
+
+	if c.Header == "" {
+		c.Header = "X-Jwt-Payload"
+	}
+	if c.Format == "" {
+		c.Format = JWTPayloadFormatPayload
+	}
+	if c.TTL == "" {
+		c.TTL = "15m"
+	}
+
+	return &c, nil
+}
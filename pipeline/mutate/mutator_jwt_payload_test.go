@@ -0,0 +1,112 @@
+package mutate_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestMutatorJWTPayload(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineMutator("jwt_payload")
+	require.NoError(t, err)
+	assert.Equal(t, "jwt_payload", a.GetID())
+
+	decode := func(t *testing.T, payload string) map[string]interface{} {
+		raw, err := base64.RawURLEncoding.DecodeString(payload)
+		require.NoError(t, err)
+
+		var claims map[string]interface{}
+		require.NoError(t, json.Unmarshal(raw, &claims))
+		return claims
+	}
+
+	t.Run("method=mutate", func(t *testing.T) {
+		for k, tc := range []struct {
+			config json.RawMessage
+			header string
+			jwt    bool
+			expect map[string]interface{}
+			err    bool
+		}{
+			{
+				config: json.RawMessage(`{}`),
+				header: "X-Jwt-Payload",
+				expect: map[string]interface{}{"sub": "foo"},
+			},
+			{
+				config: json.RawMessage(`{"header":"X-User","issuer_url":"https://oathkeeper/","claims":"{\"aud\":[\"api\"],\"email\":\"{{ print .Extra.email }}\"}"}`),
+				header: "X-User",
+				expect: map[string]interface{}{"sub": "foo", "iss": "https://oathkeeper/", "aud": []interface{}{"api"}, "email": "foo@bar.com"},
+			},
+			{
+				config: json.RawMessage(`{"format":"unsigned_jwt"}`),
+				header: "X-Jwt-Payload",
+				jwt:    true,
+				expect: map[string]interface{}{"sub": "foo"},
+			},
+			{
+				config: json.RawMessage(`{"claims":"not json"}`),
+				err:    true,
+			},
+			{
+				config: json.RawMessage(`{"foo":"bar"}`),
+				err:    true,
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+				session := &authn.AuthenticationSession{Subject: "foo", Extra: map[string]interface{}{"email": "foo@bar.com"}}
+				err := a.Mutate(&http.Request{Header: http.Header{}}, session, tc.config, &rule.Rule{ID: "test-rule"})
+				if tc.err {
+					require.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+
+				value := session.Header.Get(tc.header)
+				require.NotEmpty(t, value)
+
+				if tc.jwt {
+					parts := strings.Split(value, ".")
+					require.Len(t, parts, 3)
+					assert.Equal(t, map[string]interface{}{"alg": "none", "typ": "JWT"}, decode(t, parts[0]))
+					assert.Empty(t, parts[2])
+					value = parts[1]
+				}
+
+				claims := decode(t, value)
+				assert.InDelta(t, time.Now().Unix(), claims["iat"], 5)
+				assert.InDelta(t, time.Now().Add(15*time.Minute).Unix(), claims["exp"], 5)
+				delete(claims, "iat")
+				delete(claims, "exp")
+				assert.Equal(t, tc.expect, claims)
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyMutatorJWTPayloadIsEnabled, true)
+		require.NoError(t, a.Validate(json.RawMessage(`{}`)))
+		require.Error(t, a.Validate(json.RawMessage(`{"format":"foo"}`)))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyMutatorJWTPayloadIsEnabled, false)
+		require.Error(t, a.Validate(json.RawMessage(`{}`)))
+	})
+}
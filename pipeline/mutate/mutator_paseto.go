@@ -0,0 +1,203 @@
+package mutate
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/paseto"
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/x"
+)
+
+type MutatorPASETORegistry interface {
+	credentials.FetcherRegistry
+}
+
+type MutatorPASETOConfig struct {
+	Version   string `json:"version"`
+	Claims    string `json:"claims"`
+	IssuerURL string `json:"issuer_url"`
+	JWKSURL   string `json:"jwks_url"`
+	TTL       string `json:"ttl"`
+}
+
+func (c *MutatorPASETOConfig) ClaimsTemplateID() string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(c.Claims)))
+}
+
+// MutatorPASETO mints a PASETO token, the counterpart of the id_token mutator for teams using PASETO instead of JWT.
+type MutatorPASETO struct {
+	c         configuration.Provider
+	r         MutatorPASETORegistry
+	templates *template.Template
+}
+
+func NewMutatorPASETO(c configuration.Provider, r MutatorPASETORegistry) *MutatorPASETO {
+	return &MutatorPASETO{r: r, c: c, templates: x.NewTemplate("paseto")}
+}
+
+func (a *MutatorPASETO) GetID() string {
+	return "paseto"
+}
+
+func (a *MutatorPASETO) WithCache(t *template.Template) {
+	a.templates = t
+}
+
+func (a *MutatorPASETO) Mutate(r *http.Request, session *authn.AuthenticationSession, config json.RawMessage, rl pipeline.Rule) error {
+	var claims = map[string]interface{}{}
+	c, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	ttl, err := time.ParseDuration(c.TTL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(c.Claims) > 0 {
+		t, err := a.claimsTemplate(c, rl)
+		if err != nil {
+			return err
+		}
+
+		var b bytes.Buffer
+		if err := t.Execute(&b, session); err != nil {
+			return errors.Wrapf(err, `error executing claims template in rule "%s"`, rl.GetID())
+		}
+
+		if err := json.NewDecoder(&b).Decode(&claims); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	// PASETO encodes registered time claims as RFC 3339 strings instead of Unix timestamps.
+	now := time.Now().UTC()
+	claims["exp"] = now.Add(ttl).Format(time.RFC3339)
+	claims["jti"] = uuid.New()
+	claims["iat"] = now.Format(time.RFC3339)
+	claims["iss"] = c.IssuerURL
+	claims["nbf"] = now.Format(time.RFC3339)
+	claims["sub"] = session.Subject
+
+	jwks, err := url.Parse(c.JWKSURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	sets, err := a.r.CredentialsFetcher().ResolveSets(r.Context(), []url.URL{*jwks})
+	if err != nil {
+		return err
+	}
+
+	key, kid, err := paseto.PrivateKey(sets)
+	if err != nil {
+		return err
+	}
+
+	footer, err := json.Marshal(&paseto.Footer{KeyID: kid})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	token, err := paseto.Sign(c.Version, key, claims, footer)
+	if err != nil {
+		return err
+	}
+
+	session.SetHeader("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Stage implements the pipeline.Stager interface by compiling the claims template and
+// by making sure that the signing key is available.
+func (a *MutatorPASETO) Stage(ctx context.Context, config json.RawMessage, rl pipeline.Rule) error {
+	c, err := a.Config(config)
+	if err != nil {
+		return err
+	}
+
+	if _, err := time.ParseDuration(c.TTL); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(c.Claims) > 0 {
+		if _, err := a.claimsTemplate(c, rl); err != nil {
+			return err
+		}
+	}
+
+	jwks, err := url.Parse(c.JWKSURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	sets, err := a.r.CredentialsFetcher().ResolveSets(ctx, []url.URL{*jwks})
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := paseto.PrivateKey(sets); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *MutatorPASETO) claimsTemplate(c *MutatorPASETOConfig, rl pipeline.Rule) (*template.Template, error) {
+	t := a.templates.Lookup(c.ClaimsTemplateID())
+	if t == nil {
+		var err error
+		t, err = a.templates.New(c.ClaimsTemplateID()).Parse(c.Claims)
+		if err != nil {
+			return nil, errors.Wrapf(err, `error parsing claims template in rule "%s"`, rl.GetID())
+		}
+	}
+	return t, nil
+}
+
+func (a *MutatorPASETO) Validate(config json.RawMessage) error {
+	if !a.c.MutatorIsEnabled(a.GetID()) {
+		return NewErrMutatorNotEnabled(a)
+	}
+
+	if _, err := a.Config(config); err != nil {
+		return err
+	}
+
+	if a.c.FIPSIsEnabled() {
+		return NewErrMutatorMisconfigured(a, errors.New("PASETO tokens are signed using Ed25519 which is not approved by the FIPS policy"))
+	}
+
+	return nil
+}
+
+func (a *MutatorPASETO) Config(config json.RawMessage) (*MutatorPASETOConfig, error) {
+	var c MutatorPASETOConfig
+	if err := a.c.MutatorConfig(a.GetID(), config, &c); err != nil {
+		return nil, NewErrMutatorMisconfigured(a, err)
+	}
+
+	if c.Version == "" {
+		c.Version = paseto.V4Public
+	}
+	if c.TTL == "" {
+		c.TTL = "15m"
+	}
+
+	return &c, nil
+}
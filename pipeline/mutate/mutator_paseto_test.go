@@ -0,0 +1,78 @@
+package mutate_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/ory/viper"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/oathkeeper/credentials"
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/paseto"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestMutatorPASETO(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	a, err := reg.PipelineMutator("paseto")
+	require.NoError(t, err)
+	assert.Equal(t, "paseto", a.GetID())
+
+	sets, err := reg.CredentialsFetcher().ResolveSets(context.Background(), []url.URL{*urlx.ParseOrPanic("file://../../test/stub/jwks-ed25519.json")})
+	require.NoError(t, err)
+
+	t.Run("method=mutate", func(t *testing.T) {
+		for _, version := range paseto.Versions {
+			t.Run("version="+version, func(t *testing.T) {
+				session := &authn.AuthenticationSession{Subject: "foo", Extra: map[string]interface{}{"email": "foo@bar.com"}}
+				config := json.RawMessage(`{"version":"` + version + `","issuer_url":"https://oathkeeper/","jwks_url":"file://../../test/stub/jwks-ed25519.json","claims":"{\"email\":\"{{ print .Extra.email }}\"}"}`)
+				require.NoError(t, a.Mutate(&http.Request{Header: http.Header{}}, session, config, &rule.Rule{ID: "test-rule"}))
+
+				token := strings.TrimPrefix(session.Header.Get("Authorization"), "Bearer ")
+				assert.True(t, strings.HasPrefix(token, version+"."), token)
+
+				verified, err := paseto.Verify(token, paseto.Versions, func(footer []byte) ([]ed25519.PublicKey, error) {
+					var f paseto.Footer
+					require.NoError(t, json.Unmarshal(footer, &f))
+					return credentials.Ed25519PublicKeys(sets, f.KeyID), nil
+				})
+				require.NoError(t, err)
+
+				assert.Equal(t, "foo", verified.Claims["sub"])
+				assert.Equal(t, "https://oathkeeper/", verified.Claims["iss"])
+				assert.Equal(t, "foo@bar.com", verified.Claims["email"])
+				_, ok := paseto.Time(verified.Claims, "exp")
+				assert.True(t, ok)
+			})
+		}
+	})
+
+	t.Run("method=validate", func(t *testing.T) {
+		config := json.RawMessage(`{"issuer_url":"https://oathkeeper/","jwks_url":"file://../../test/stub/jwks-ed25519.json"}`)
+
+		viper.Set(configuration.ViperKeyMutatorPASETOIsEnabled, true)
+		defer viper.Set(configuration.ViperKeyFIPSIsEnabled, false)
+		require.NoError(t, a.Validate(config))
+		require.Error(t, a.Validate(json.RawMessage(`{"issuer_url":"https://oathkeeper/","jwks_url":"file://../../test/stub/jwks-ed25519.json","version":"v3.public"}`)))
+
+		viper.Set(configuration.ViperKeyFIPSIsEnabled, true)
+		require.Error(t, a.Validate(config))
+
+		viper.Reset()
+		viper.Set(configuration.ViperKeyMutatorPASETOIsEnabled, false)
+		require.Error(t, a.Validate(config))
+	})
+}
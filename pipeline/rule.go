@@ -6,6 +6,8 @@ import (
 
 type Rule interface {
 	GetID() string
+	// GetDocsURL returns the URL of the rule's documentation or an empty string if the rule has none.
+	GetDocsURL() string
 	// ReplaceAllString searches the input string and replaces each match (with the rule's pattern)
 	// found with the replacement text.
 	ReplaceAllString(strategy configuration.MatchingStrategy, input, replacement string) (string, error)
@@ -0,0 +1,14 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Stager is implemented by pipeline handlers which are able to prepare a rule before it is activated,
+// for example by compiling templates or by checking that remote resources (e.g. JSON Web Key Sets) are reachable.
+//
+// Handlers that do not implement this interface are considered to be staged once their configuration validates.
+type Stager interface {
+	Stage(ctx context.Context, config json.RawMessage, rule Rule) error
+}
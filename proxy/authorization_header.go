@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/ory/oathkeeper/rule"
+)
+
+// ApplyAuthorizationHeaderMode sets the Authorization header of h according to the rule's authorization header
+// mode. The value original is the Authorization header of the incoming request and mutated the Authorization
+// header set by the mutators, both may be empty. Without a mode, h is left untouched which means that the
+// Authorization header set by mutators, if any, takes precedence over the original one.
+func ApplyAuthorizationHeaderMode(h http.Header, original, mutated string, rl *rule.Rule) {
+	c := rl.Upstream.AuthorizationHeader
+	if c == nil {
+		return
+	}
+
+	set := func(key, value string) {
+		if value == "" {
+			h.Del(key)
+			return
+		}
+		h.Set(key, value)
+	}
+
+	switch c.Mode {
+	case rule.AuthorizationHeaderKeep:
+		set("Authorization", original)
+	case rule.AuthorizationHeaderStrip:
+		h.Del("Authorization")
+	case rule.AuthorizationHeaderMove:
+		header := c.Header
+		if header == "" {
+			header = rule.DefaultAuthorizationHeaderMoveTo
+		}
+		set(header, original)
+		set("Authorization", mutated)
+	case rule.AuthorizationHeaderReplace:
+		set("Authorization", mutated)
+	}
+}
@@ -0,0 +1,64 @@
+package proxy_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/oathkeeper/proxy"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestApplyAuthorizationHeaderMode(t *testing.T) {
+	for k, tc := range []struct {
+		c        *rule.UpstreamAuthorizationHeader
+		mutated  string
+		expected http.Header
+	}{
+		{
+			c:        nil,
+			mutated:  "bearer mutated",
+			expected: http.Header{"Authorization": {"bearer mutated"}},
+		},
+		{
+			c:        &rule.UpstreamAuthorizationHeader{Mode: rule.AuthorizationHeaderKeep},
+			mutated:  "bearer mutated",
+			expected: http.Header{"Authorization": {"bearer original"}},
+		},
+		{
+			c:        &rule.UpstreamAuthorizationHeader{Mode: rule.AuthorizationHeaderStrip},
+			mutated:  "bearer mutated",
+			expected: http.Header{},
+		},
+		{
+			c:        &rule.UpstreamAuthorizationHeader{Mode: rule.AuthorizationHeaderMove},
+			expected: http.Header{"X-Original-Authorization": {"bearer original"}},
+		},
+		{
+			c:        &rule.UpstreamAuthorizationHeader{Mode: rule.AuthorizationHeaderMove, Header: "X-Forwarded-Authorization"},
+			mutated:  "bearer mutated",
+			expected: http.Header{"Authorization": {"bearer mutated"}, "X-Forwarded-Authorization": {"bearer original"}},
+		},
+		{
+			c:        &rule.UpstreamAuthorizationHeader{Mode: rule.AuthorizationHeaderReplace},
+			expected: http.Header{},
+		},
+		{
+			c:        &rule.UpstreamAuthorizationHeader{Mode: rule.AuthorizationHeaderReplace},
+			mutated:  "bearer mutated",
+			expected: http.Header{"Authorization": {"bearer mutated"}},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			h := http.Header{"Authorization": {"bearer original"}}
+			if tc.mutated != "" {
+				h.Set("Authorization", tc.mutated)
+			}
+
+			proxy.ApplyAuthorizationHeaderMode(h, "bearer original", tc.mutated, &rule.Rule{Upstream: rule.Upstream{AuthorizationHeader: tc.c}})
+			assert.Equal(t, tc.expected, h)
+		})
+	}
+}
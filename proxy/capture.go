@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ory/oathkeeper/capture"
+	"github.com/ory/oathkeeper/rule"
+)
+
+// NewCaptureSnapshot returns a snapshot of the incoming request if requests matching the rule are captured
+// and nil otherwise.
+func NewCaptureSnapshot(ctx context.Context, recorder capture.Recorder, r *http.Request, rl *rule.Rule) *capture.Snapshot {
+	if rl == nil || !recorder.IsCapturing(ctx, rl.ID) {
+		return nil
+	}
+
+	return &capture.Snapshot{
+		Time: time.Now().UTC(),
+		Request: capture.Request{
+			Method: r.Method,
+			URL:    r.URL.String(),
+			Header: r.Header.Clone(),
+		},
+	}
+}
+
+// recordCapture completes and records the snapshot of the request, if any.
+func (d *Proxy) recordCapture(r *http.Request, rl *rule.Rule, decision capture.Decision, code int, header http.Header) {
+	s, ok := r.Context().Value(contextKeyCapture).(*capture.Snapshot)
+	if !ok || s == nil || rl == nil {
+		return
+	}
+
+	s.Decision = decision
+	s.Response = capture.Response{StatusCode: code, Header: header}
+	d.r.CaptureRecorder().Record(r.Context(), rl.ID, *s)
+}
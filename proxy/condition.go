@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/rule"
+)
+
+// ConditionData is the data the conditions of mutators are evaluated against. Besides the fields of the
+// authentication session (e.g. `.Subject` or `.Extra`), it exposes the request as `.Request`.
+type ConditionData struct {
+	*authn.AuthenticationSession
+
+	Request ConditionRequest
+}
+
+// ConditionRequest describes the request a condition is evaluated for.
+type ConditionRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+}
+
+// conditionHolds returns true if the condition is empty or renders "true".
+func (d *RequestHandler) conditionHolds(condition string, r *http.Request, session *authn.AuthenticationSession) (bool, error) {
+	if condition == "" {
+		return true, nil
+	}
+
+	t, err := d.conditionTemplate(condition)
+	if err != nil {
+		return false, err
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, &ConditionData{
+		AuthenticationSession: session,
+		Request:               ConditionRequest{Method: r.Method, URL: r.URL, Header: r.Header},
+	}); err != nil {
+		return false, errors.Wrapf(err, `error executing condition "%s"`, condition)
+	}
+
+	return strings.TrimSpace(b.String()) == "true", nil
+}
+
+// conditionTemplate returns the parsed condition. Templates are cached by their source.
+func (d *RequestHandler) conditionTemplate(condition string) (*template.Template, error) {
+	d.conditionsLock.Lock()
+	defer d.conditionsLock.Unlock()
+
+	if t := d.conditions.Lookup(condition); t != nil {
+		return t, nil
+	}
+
+	t, err := d.conditions.New(condition).Parse(condition)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error parsing condition "%s"`, condition)
+	}
+	return t, nil
+}
+
+// whenMatches returns true if no predicates are set or if r matches at least one of them.
+func (d *RequestHandler) whenMatches(whens []rule.HandlerWhen, r *http.Request) (bool, error) {
+	if len(whens) == 0 {
+		return true, nil
+	}
+
+	for _, w := range whens {
+		matches, err := d.patternMatches(w.Path, r.URL.Path)
+		if err != nil {
+			return false, err
+		} else if !matches {
+			continue
+		}
+
+		for name, pattern := range w.Header {
+			if matches, err = d.patternMatches(pattern, r.Header.Get(name)); err != nil {
+				return false, err
+			} else if !matches {
+				break
+			}
+		}
+
+		if matches {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// patternMatches returns true if the value matches the regular expression. Regular expressions are cached by their source.
+func (d *RequestHandler) patternMatches(pattern, value string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+
+	if p, ok := d.patterns.Load(pattern); ok {
+		return p.(*regexp.Regexp).MatchString(value), nil
+	}
+
+	p, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	d.patterns.Store(pattern, p)
+
+	return p.MatchString(value), nil
+}
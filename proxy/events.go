@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/ory/oathkeeper/capture"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/rule"
+)
+
+// decisionStart is the state of the incoming request needed to publish the decision event.
+type decisionStart struct {
+	time   time.Time
+	method string
+	url    string
+}
+
+// NewDecisionEvent returns the event of an access control decision made through the given interface ("proxy" or
+// "decisions").
+func NewDecisionEvent(iface string, r *http.Request, rl *rule.Rule, decision capture.Decision, code int, latency time.Duration) events.Event {
+	e := events.Event{
+		Type: events.TypeDecision,
+		Time: time.Now().UTC(),
+		Decision: &events.Decision{
+			Interface:     iface,
+			Subject:       decision.Subject,
+			Method:        r.Method,
+			URL:           r.URL.String(),
+			Granted:       decision.Granted,
+			StatusCode:    code,
+			LatencyMS:     float64(latency) / float64(time.Millisecond),
+			Error:         decision.Error,
+			UpstreamError: decision.UpstreamError,
+			TraceID:       traceID(r),
+		},
+	}
+	if rl != nil {
+		e.Decision.RuleID = rl.ID
+		e.Decision.RuleOwner = rl.Owner
+		e.Decision.RuleLabels = rl.Labels()
+	}
+	return e
+}
+
+// traceID returns the ID of the trace of the request. The tracing backends do not expose the ID through the
+// OpenTracing API, so it is read from the headers the span context is propagated with instead.
+func traceID(r *http.Request) string {
+	span := opentracing.SpanFromContext(r.Context())
+	if span == nil {
+		return ""
+	}
+
+	header := http.Header{}
+	if err := span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)); err != nil {
+		return ""
+	}
+
+	if v := header.Get("traceparent"); v != "" {
+		// W3C Trace Context: version-traceid-parentid-flags
+		if parts := strings.Split(v, "-"); len(parts) == 4 {
+			return parts[1]
+		}
+	}
+	if v := header.Get("uber-trace-id"); v != "" {
+		// Jaeger: traceid:spanid:parentid:flags
+		if unescaped, err := url.QueryUnescape(v); err == nil {
+			return strings.Split(unescaped, ":")[0]
+		}
+	}
+	return header.Get("X-B3-TraceId")
+}
+
+// publishDecision publishes the decision event of the request if it was started by the director. The decision was
+// reached at decided, the round trip to the upstream is not part of the latency.
+func (d *Proxy) publishDecision(r *http.Request, rl *rule.Rule, decision capture.Decision, code int, decided time.Time) {
+	s, ok := r.Context().Value(contextKeyEvent).(*decisionStart)
+	if !ok || s == nil {
+		return
+	}
+
+	// The director rewrites the URL of granted requests to the upstream.
+	e := NewDecisionEvent("proxy", r, rl, decision, code, decided.Sub(s.time))
+	e.Decision.Method, e.Decision.URL = s.method, s.url
+	d.r.EventBus().Publish(e)
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/urfave/negroni"
+
+	"github.com/ory/oathkeeper/explain"
+	"github.com/ory/oathkeeper/rule"
+)
+
+// Steps of explanations which are not measured as stages of the pipeline.
+const (
+	StepRequestValidation = "request_validation"
+	StepLockout           = "lockout"
+	StepOverload          = "overload"
+	StepRisk              = "risk"
+)
+
+// SampleExplanation returns the request with a trace of its pipeline in the context if explanations are enabled and
+// the request was sampled. Otherwise the request is returned as is.
+func (d *RequestHandler) SampleExplanation(r *http.Request) *http.Request {
+	rate := d.c.ExplanationsSampleRate()
+	if rate <= 0 || rand.Float64() >= rate {
+		return r
+	}
+	return r.WithContext(explain.WithTrace(r.Context(), explain.NewTrace(r)))
+}
+
+// explainError answers the request with its ID and returns a response writer which records the explanation of the
+// denial once the error handler wrote the response. It returns the response writer as is if the request is not
+// traced.
+func (d *RequestHandler) explainError(w http.ResponseWriter, r *http.Request, rl *rule.Rule, handleErr error) (http.ResponseWriter, func()) {
+	trace := explain.TraceFromContext(r.Context())
+	if trace == nil {
+		return w, func() {}
+	}
+
+	w.Header().Set(explain.RequestIDHeader, trace.RequestID())
+	rw := negroni.NewResponseWriter(w)
+	return rw, func() {
+		e := trace.Explanation(rl.ID, handleErr, rw.Status())
+		if err := d.r.ExplanationRecorder().Record(r.Context(), e); err != nil {
+			d.r.Logger().WithError(err).
+				WithField("request_id", e.RequestID).
+				Warn("Unable to record the explanation of the denied request")
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/x"
+)
+
+// SetForwardedHeader adds an element describing the request as received by ORY Oathkeeper to the Forwarded header
+// (RFC 7239) of the request. It must be called before the host of the request is changed to the upstream's.
+//
+// In "append" mode, the Forwarded header of the request is kept if the request was sent by a trusted proxy and
+// removed otherwise, so that clients can not spoof it. In "replace" mode, it is always removed and the element
+// describes the client's IP address as derived using the trusted proxies.
+func (d *RequestHandler) SetForwardedHeader(r *http.Request) {
+	mode := d.c.ProxyForwardedMode()
+	if mode == configuration.ForwardedModeOff {
+		return
+	}
+
+	trusted := d.c.TrustedProxies()
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+
+	var elements []string
+	if mode == configuration.ForwardedModeReplace {
+		peer = x.ClientIP(r, trusted)
+	} else if ip := net.ParseIP(peer); ip != nil && x.ContainsIP(trusted, ip) {
+		elements = r.Header["Forwarded"]
+	}
+
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+
+	by := d.c.ProxyForwardedBy()
+	if by == "" {
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			by = addr.String()
+		}
+	}
+
+	element := "for=" + forwardedNode(peer)
+	if by != "" {
+		element += ";by=" + forwardedNode(by)
+	}
+	element += ";proto=" + proto
+	if r.Host != "" {
+		element += ";host=" + forwardedValue(r.Host)
+	}
+
+	r.Header["Forwarded"] = append(append([]string{}, elements...), element)
+}
+
+// forwardedNode formats an IP address, optionally with a port, or an obfuscated identifier as a node of the Forwarded
+// header. IPv6 addresses are enclosed in brackets.
+func forwardedNode(node string) string {
+	if ip := net.ParseIP(node); ip != nil && ip.To4() == nil {
+		return forwardedValue("[" + node + "]")
+	} else if host, port, err := net.SplitHostPort(node); err == nil {
+		if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			return forwardedValue("[" + host + "]:" + port)
+		}
+	}
+	return forwardedValue(node)
+}
+
+// forwardedValue quotes the value unless it is a token.
+func forwardedValue(value string) string {
+	for _, c := range value {
+		if !isTokenChar(c) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	return value
+}
+
+func isTokenChar(c rune) bool {
+	return c < 127 && c > 32 && !strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c)
+}
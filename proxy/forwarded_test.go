@@ -0,0 +1,77 @@
+package proxy_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+)
+
+func TestSetForwardedHeader(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	for k, tc := range []struct {
+		mode      string
+		by        string
+		remote    string
+		header    http.Header
+		tls       bool
+		localAddr net.Addr
+		expect    []string
+	}{
+		{
+			remote: "203.0.113.7:1234",
+			header: http.Header{"Forwarded": {"for=198.51.100.1"}},
+			expect: []string{`for=203.0.113.7;proto=http;host=api.example.com`},
+		},
+		{
+			remote:    "10.0.0.2:1234",
+			header:    http.Header{"Forwarded": {"for=198.51.100.1"}},
+			tls:       true,
+			localAddr: &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 4455},
+			expect:    []string{"for=198.51.100.1", `for=10.0.0.2;by="10.0.0.5:4455";proto=https;host=api.example.com`},
+		},
+		{
+			mode:   configuration.ForwardedModeReplace,
+			by:     "_oathkeeper",
+			remote: "10.0.0.2:1234",
+			header: http.Header{"Forwarded": {`for="[2001:db8::1]";proto=https`}},
+			expect: []string{`for="[2001:db8::1]";by=_oathkeeper;proto=http;host=api.example.com`},
+		},
+		{
+			mode:   configuration.ForwardedModeOff,
+			remote: "203.0.113.7:1234",
+			header: http.Header{"Forwarded": {"for=198.51.100.1"}},
+			expect: []string{"for=198.51.100.1"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			viper.Set(configuration.ViperKeyProxyForwardedMode, tc.mode)
+			viper.Set(configuration.ViperKeyProxyForwardedBy, tc.by)
+
+			r := newTestRequest("http://api.example.com/users")
+			r.Host = "api.example.com"
+			r.RemoteAddr = tc.remote
+			r.Header = tc.header
+			if tc.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if tc.localAddr != nil {
+				r = r.WithContext(context.WithValue(context.Background(), http.LocalAddrContextKey, tc.localAddr))
+			}
+
+			reg.ProxyRequestHandler().SetForwardedHeader(r)
+			assert.Equal(t, tc.expect, r.Header["Forwarded"])
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/ory/x/healthx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+)
+
+// NewHealthMiddleware returns a middleware of the proxy listeners serving the health checks at the paths configured
+// at `serve.proxy.health`, so that load balancers can check the proxy. Requests to these paths are answered before
+// they are matched against access rules. Error details are never shared with clients of the proxy.
+func NewHealthMiddleware(c configuration.Provider, h *healthx.Handler) func(http.ResponseWriter, *http.Request, http.HandlerFunc) {
+	router := httprouter.New()
+	h.SetRoutes(router, false)
+
+	return func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if !c.ProxyHealthEnabled() || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next(w, r)
+			return
+		}
+
+		var path string
+		switch r.URL.Path {
+		case c.ProxyHealthAlivePath():
+			path = healthx.AliveCheckPath
+		case c.ProxyHealthReadyPath():
+			path = healthx.ReadyCheckPath
+		default:
+			next(w, r)
+			return
+		}
+
+		// The health checks are registered at their default paths and for GET only, the server omits the body of
+		// responses to HEAD requests.
+		check := r.Clone(r.Context())
+		check.Method = http.MethodGet
+		check.URL.Path = path
+		check.URL.RawPath = ""
+		router.ServeHTTP(w, check)
+	}
+}
@@ -0,0 +1,57 @@
+package proxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/proxy"
+)
+
+func TestHealthMiddleware(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+	defer viper.Set(configuration.ViperKeyProxyHealthEnabled, false)
+
+	m := proxy.NewHealthMiddleware(conf, reg.HealthHandler())
+	serve := func(method, path string) (*httptest.ResponseRecorder, bool) {
+		var proxied bool
+		w := httptest.NewRecorder()
+		m(w, httptest.NewRequest(method, path, nil), func(w http.ResponseWriter, r *http.Request) {
+			proxied = true
+		})
+		return w, proxied
+	}
+
+	_, proxied := serve("GET", "/health/alive")
+	assert.True(t, proxied, "health checks are not served unless enabled")
+
+	viper.Set(configuration.ViperKeyProxyHealthEnabled, true)
+	w, proxied := serve("GET", "/health/alive")
+	assert.False(t, proxied)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w, proxied = serve("HEAD", "/health/alive")
+	assert.False(t, proxied)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, proxied = serve("POST", "/health/alive")
+	assert.True(t, proxied)
+
+	_, proxied = serve("GET", "/api/users")
+	assert.True(t, proxied)
+
+	viper.Set(configuration.ViperKeyProxyHealthAlivePath, "/_oathkeeper/alive")
+	defer viper.Set(configuration.ViperKeyProxyHealthAlivePath, "/health/alive")
+	_, proxied = serve("GET", "/health/alive")
+	assert.True(t, proxied)
+	w, proxied = serve("GET", "/_oathkeeper/alive")
+	assert.False(t, proxied)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+// UpstreamHost renders the Host header of the upstream request if the rule sets `upstream.host`, or returns an empty
+// string otherwise. The template is evaluated against the same data as conditions, before the request is rewritten
+// for the upstream.
+func (d *RequestHandler) UpstreamHost(r *http.Request, rl *rule.Rule, session *authn.AuthenticationSession) (string, error) {
+	if rl.Upstream.Host == "" {
+		return "", nil
+	}
+
+	t, err := d.hostTemplate(rl.Upstream.Host)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, &ConditionData{
+		AuthenticationSession: session,
+		Request:               ConditionRequest{Method: r.Method, URL: r.URL, Header: r.Header},
+	}); err != nil {
+		return "", errors.Wrapf(err, `error executing upstream host "%s"`, rl.Upstream.Host)
+	}
+
+	host := strings.TrimSpace(b.String())
+	if host == "" || strings.ContainsAny(host, " /\\\r\n") {
+		return "", errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`The upstream host "%s" rendered by the access rule is not a valid host.`, host))
+	}
+
+	return host, nil
+}
+
+// hostTemplate returns the parsed upstream host template. Templates are cached by their source.
+func (d *RequestHandler) hostTemplate(host string) (*template.Template, error) {
+	if t, ok := d.hosts.Load(host); ok {
+		return t.(*template.Template), nil
+	}
+
+	t, err := x.NewTemplate("host").Parse(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, `error parsing upstream host "%s"`, host)
+	}
+	d.hosts.Store(host, t)
+
+	return t, nil
+}
@@ -0,0 +1,49 @@
+package proxy_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestUpstreamHost(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	session := &authn.AuthenticationSession{
+		Subject:      "alice",
+		MatchContext: authn.MatchContext{RegexpCaptureGroups: []string{"tenant-a"}},
+	}
+
+	for k, tc := range []struct {
+		host      string
+		expect    string
+		expectErr bool
+	}{
+		{host: "", expect: ""},
+		{host: "users.intranet", expect: "users.intranet"},
+		{host: "{{ printIndex .MatchContext.RegexpCaptureGroups 0 }}.intranet:8080", expect: "tenant-a.intranet:8080"},
+		{host: `{{ .Request.Header.Get "X-Tenant" }}.tenants.intranet`, expect: "tenant-b.tenants.intranet"},
+		{host: `{{ .Request.Header.Get "X-Missing" }}`, expectErr: true},
+		{host: "{{ .Subject }}/evil", expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			r := newTestRequest("http://api.example.com/tenant-a/users")
+			r.Header = map[string][]string{"X-Tenant": {"tenant-b"}}
+
+			host, err := reg.ProxyRequestHandler().UpstreamHost(r, &rule.Rule{Upstream: rule.Upstream{Host: tc.host}}, session)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, host)
+		})
+	}
+}
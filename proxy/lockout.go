@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/helper"
+)
+
+// lockoutKeys returns the keys failed authentication attempts of the request are counted by.
+func (d *RequestHandler) lockoutKeys(r *http.Request) (keys []string) {
+	for _, kind := range d.c.LockoutKeys() {
+		switch kind {
+		case "ip":
+			if ip := d.ClientIP(r); ip != "" {
+				keys = append(keys, "ip:"+ip)
+			}
+		case "token_prefix":
+			token := helper.BearerTokenFromRequest(r, nil)
+			if token == "" {
+				continue
+			}
+			if n := d.c.LockoutTokenPrefixLength(); n > 0 && len(token) > n {
+				token = token[:n]
+			}
+			keys = append(keys, "token_prefix:"+token)
+		case "subject":
+			// Only the username of HTTP Basic Authorization identifies a subject before it was authenticated.
+			if username, _, ok := r.BasicAuth(); ok && username != "" {
+				keys = append(keys, "subject:"+username)
+			}
+		}
+	}
+	return keys
+}
+
+// checkLockout delays or rejects the request if its keys failed to authenticate too often.
+func (d *RequestHandler) checkLockout(r *http.Request) error {
+	if !d.c.LockoutIsEnabled() {
+		return nil
+	}
+	return d.r.LockoutTracker().Check(r.Context(), d.lockoutKeys(r))
+}
+
+// recordLockoutFailure counts err as a failed authentication attempt if it indicates invalid credentials.
+func (d *RequestHandler) recordLockoutFailure(r *http.Request, err error) {
+	if !d.c.LockoutIsEnabled() || herodot.ToDefaultError(err, "").StatusCode() != http.StatusUnauthorized {
+		return
+	}
+
+	if err := d.r.LockoutTracker().Fail(r.Context(), d.lockoutKeys(r)); err != nil {
+		d.r.Logger().WithError(err).Warn("Unable to record failed authentication attempt")
+	}
+}
+
+// resetLockout forgets the failed attempts of the token and subject of a request which was authenticated. Failed
+// attempts of the IP address are kept because many clients may share it.
+func (d *RequestHandler) resetLockout(r *http.Request) {
+	if !d.c.LockoutIsEnabled() {
+		return
+	}
+
+	var keys []string
+	for _, key := range d.lockoutKeys(r) {
+		if !strings.HasPrefix(key, "ip:") {
+			keys = append(keys, key)
+		}
+	}
+
+	if err := d.r.LockoutTracker().Reset(r.Context(), keys); err != nil {
+		d.r.Logger().WithError(err).Warn("Unable to reset failed authentication attempts")
+	}
+}
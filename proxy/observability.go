@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ory/oathkeeper/rule"
+)
+
+// RuleLogger returns the logger for requests matching the rule. If the rule overrides the log level, the returned
+// logger writes to the same output as the default logger but uses the rule's log level.
+func (d *RequestHandler) RuleLogger(rl *rule.Rule) logrus.FieldLogger {
+	l := d.r.Logger()
+	if rl == nil || rl.Observability == nil || rl.Observability.LogLevel == "" {
+		return l
+	}
+
+	level, err := logrus.ParseLevel(rl.Observability.LogLevel)
+	if err != nil {
+		return l
+	}
+
+	base, ok := l.(*logrus.Logger)
+	if !ok {
+		return l
+	}
+
+	if derived, ok := d.loggers.Load(level); ok {
+		return derived.(*logrus.Logger)
+	}
+
+	derived := &logrus.Logger{
+		Out:          base.Out,
+		Hooks:        base.Hooks,
+		Formatter:    base.Formatter,
+		ReportCaller: base.ReportCaller,
+		Level:        level,
+		ExitFunc:     base.ExitFunc,
+	}
+	d.loggers.Store(level, derived)
+	return derived
+}
+
+// sampleTrace overrides the sampling decision for the span of the request if the rule sets a trace sampling rate.
+func sampleTrace(r *http.Request, rl *rule.Rule) {
+	if rl.Observability == nil || rl.Observability.TraceSampling == nil {
+		return
+	}
+
+	span := opentracing.SpanFromContext(r.Context())
+	if span == nil {
+		return
+	}
+
+	var priority uint16
+	if rand.Float64() < *rl.Observability.TraceSampling {
+		priority = 1
+	}
+	ext.SamplingPriority.Set(span, priority)
+}
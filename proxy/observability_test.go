@@ -0,0 +1,58 @@
+package proxy_test
+
+import (
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestRuleObservability(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	reg := internal.NewRegistry(conf)
+
+	t.Run("case=log level", func(t *testing.T) {
+		l := reg.ProxyRequestHandler().RuleLogger(&rule.Rule{Observability: &rule.Observability{LogLevel: "debug"}})
+		require.IsType(t, new(logrus.Logger), l)
+		assert.Equal(t, logrus.DebugLevel, l.(*logrus.Logger).Level)
+
+		assert.Equal(t, reg.Logger(), reg.ProxyRequestHandler().RuleLogger(&rule.Rule{}))
+	})
+
+	t.Run("case=trace sampling", func(t *testing.T) {
+		for _, tc := range []struct {
+			rate    float64
+			sampled bool
+		}{
+			{rate: 0, sampled: false},
+			{rate: 1, sampled: true},
+		} {
+			tracer := mocktracer.New()
+			span := tracer.StartSpan("test")
+
+			r := newTestRequest("http://localhost/")
+			r = r.WithContext(opentracing.ContextWithSpan(r.Context(), span))
+
+			_, err := reg.ProxyRequestHandler().HandleRequest(r, &rule.Rule{
+				Authenticators: []rule.Handler{{Handler: "anonymous"}},
+				Authorizer:     rule.Handler{Handler: "allow"},
+				Mutators:       []rule.Handler{{Handler: "noop"}},
+				Observability:  &rule.Observability{TraceSampling: &tc.rate},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.sampled, span.Context().(mocktracer.MockSpanContext).Sampled)
+		}
+	})
+}
@@ -26,7 +26,13 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/ory/oathkeeper/capture"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/explain"
+	"github.com/ory/oathkeeper/overload"
 	"github.com/ory/oathkeeper/pipeline/authn"
 	"github.com/ory/oathkeeper/x"
 
@@ -39,6 +45,10 @@ type proxyRegistry interface {
 	x.RegistryLogger
 	x.RegistryWriter
 
+	capture.Registry
+	events.Registry
+	overload.Registry
+
 	ProxyRequestHandler() *RequestHandler
 	RuleMatcher() rule.Matcher
 }
@@ -48,7 +58,9 @@ func NewProxy(r proxyRegistry) *Proxy {
 }
 
 type Proxy struct {
-	r proxyRegistry
+	r              proxyRegistry
+	tokens         upstreamTokenSources
+	errorResponses sync.Map
 }
 
 type key int
@@ -57,6 +69,10 @@ const (
 	director key = iota + 1
 	ContextKeyMatchedRule
 	ContextKeySession
+	contextKeyCapture
+	contextKeyEvent
+	contextKeyStageTimings
+	contextKeyFallback
 )
 
 func (d *Proxy) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -81,6 +97,9 @@ func (d *Proxy) RoundTrip(r *http.Request) (*http.Response, error) {
 			Warn("Access request denied")
 
 		d.r.ProxyRequestHandler().HandleError(rw, r, rl, err)
+		d.r.ProxyRequestHandler().ApplySecurityHeaders(rw.header, r, rl)
+		d.recordCapture(r, rl, capture.Decision{Error: err.Error()}, rw.code, rw.header)
+		d.publishDecision(r, rl, capture.Decision{Error: err.Error()}, rw.code, time.Now())
 
 		return &http.Response{
 			StatusCode: rw.code,
@@ -88,19 +107,48 @@ func (d *Proxy) RoundTrip(r *http.Request) (*http.Response, error) {
 			Header:     rw.header,
 		}, nil
 	} else if err == nil {
-		res, err := http.DefaultTransport.RoundTrip(r)
-		if err != nil {
+		decision := capture.Decision{Granted: true}
+		if sess, ok := r.Context().Value(ContextKeySession).(*authn.AuthenticationSession); ok {
+			decision.Subject = sess.Subject
+			decision.Header = sess.Header
+			// The session is not used after the decision was recorded.
+			defer authn.ReleaseSession(sess)
+		}
+
+		decided := time.Now()
+		res, err := d.roundTripUpstream(r)
+		if err != nil && errors.Is(r.Context().Err(), context.Canceled) {
+			// The client went away, so there is nobody to respond to.
+			decision.Error = err.Error()
+			d.recordCapture(r, rl, decision, http.StatusBadGateway, nil)
+			d.publishDecision(r, rl, decision, http.StatusBadGateway, decided)
+		} else if err != nil {
+			reason := ClassifyUpstreamError(err)
 			d.r.Logger().
 				WithError(errors.WithStack(err)).
 				WithField("granted", false).
+				WithField("upstream_error", reason).
 				WithFields(fields).
 				Warn("Access request denied because roundtrip failed")
-			// don't need to return because covered in next line
+			if fallback := d.fallbackResponse(r, rl); fallback != nil {
+				d.r.ProxyRequestHandler().ApplySecurityHeaders(fallback.Header, r, rl)
+				res = fallback
+			} else {
+				res = d.upstreamErrorResponse(r, rl, reason)
+			}
+			decision.Error = err.Error()
+			decision.UpstreamError = reason
+			d.recordCapture(r, rl, decision, res.StatusCode, res.Header)
+			d.publishDecision(r, rl, decision, res.StatusCode, decided)
+			return res, nil
 		} else {
+			d.r.ProxyRequestHandler().ApplySecurityHeaders(res.Header, r, rl)
 			d.r.Logger().
 				WithField("granted", true).
 				WithFields(fields).
 				Warn("Access request granted")
+			d.recordCapture(r, rl, decision, res.StatusCode, res.Header)
+			d.publishDecision(r, rl, decision, res.StatusCode, decided)
 		}
 
 		return res, err
@@ -114,6 +162,7 @@ func (d *Proxy) RoundTrip(r *http.Request) (*http.Response, error) {
 		Warn("Unable to type assert context")
 
 	d.r.ProxyRequestHandler().HandleError(rw, r, rl, err)
+	d.r.ProxyRequestHandler().ApplySecurityHeaders(rw.header, r, rl)
 
 	return &http.Response{
 		StatusCode: rw.code,
@@ -124,13 +173,26 @@ func (d *Proxy) RoundTrip(r *http.Request) (*http.Response, error) {
 
 func (d *Proxy) Director(r *http.Request) {
 	EnrichRequestedURL(r)
+	*r = *d.r.ProxyRequestHandler().SampleExplanation(r)
+	if d.r.EventBus().HasSubscribers() {
+		*r = *r.WithContext(context.WithValue(r.Context(), contextKeyEvent, &decisionStart{
+			time:   time.Now(),
+			method: r.Method,
+			url:    r.URL.String(),
+		}))
+	}
 	rl, err := d.r.RuleMatcher().Match(r.Context(), r.Method, r.URL)
 	if err != nil {
+		explain.TraceFromContext(r.Context()).Step(StageMatch, "", explain.ResultFailed, err)
 		*r = *r.WithContext(context.WithValue(r.Context(), director, err))
 		return
 	}
+	explain.TraceFromContext(r.Context()).Step(StageMatch, "", explain.ResultPassed, nil)
 
 	*r = *r.WithContext(context.WithValue(r.Context(), ContextKeyMatchedRule, rl))
+	if snapshot := NewCaptureSnapshot(r.Context(), d.r.CaptureRecorder(), r, rl); snapshot != nil {
+		*r = *r.WithContext(context.WithValue(r.Context(), contextKeyCapture, snapshot))
+	}
 	s, err := d.r.ProxyRequestHandler().HandleRequest(r, rl)
 	if err != nil {
 		*r = *r.WithContext(context.WithValue(r.Context(), director, err))
@@ -138,15 +200,42 @@ func (d *Proxy) Director(r *http.Request) {
 	}
 	*r = *r.WithContext(context.WithValue(r.Context(), ContextKeySession, s))
 
+	original := r.Header.Get("Authorization")
+	FilterUpstreamHeaders(r.Header, rl)
+	StripAuthCookies(r.Header, s, rl)
 	for h := range s.Header {
 		r.Header.Set(h, s.Header.Get(h))
 	}
+	ApplyAuthorizationHeaderMode(r.Header, original, s.Header.Get("Authorization"), rl)
+
+	d.r.ProxyRequestHandler().SetForwardedHeader(r)
+	host, err := d.r.ProxyRequestHandler().UpstreamHost(r, rl, s)
+	if err != nil {
+		*r = *r.WithContext(context.WithValue(r.Context(), director, err))
+		return
+	}
+
+	fallback, err := configureFallback(r, rl)
+	if err != nil {
+		*r = *r.WithContext(context.WithValue(r.Context(), director, err))
+		return
+	}
+	*r = *fallback
 
 	if err := ConfigureBackendURL(r, rl); err != nil {
 		*r = *r.WithContext(context.WithValue(r.Context(), director, err))
 		return
 	}
 
+	if host != "" {
+		r.Host = host
+	}
+
+	if err := d.ConfigureUpstreamCredentials(r, rl); err != nil {
+		*r = *r.WithContext(context.WithValue(r.Context(), director, err))
+		return
+	}
+
 	var en error // need to set it to error but with nil value
 	*r = *r.WithContext(context.WithValue(r.Context(), director, en))
 }
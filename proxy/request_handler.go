@@ -23,6 +23,9 @@ package proxy
 import (
 	"encoding/json"
 	"net/http"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/ory/herodot"
 	"github.com/ory/x/errorsx"
@@ -30,10 +33,16 @@ import (
 	"github.com/ory/oathkeeper/driver/configuration"
 	"github.com/ory/oathkeeper/x"
 
+	"github.com/ory/oathkeeper/explain"
+	"github.com/ory/oathkeeper/honeypot"
+	"github.com/ory/oathkeeper/lockout"
+	"github.com/ory/oathkeeper/overload"
 	"github.com/ory/oathkeeper/pipeline/authn"
 	"github.com/ory/oathkeeper/pipeline/authz"
 	pe "github.com/ory/oathkeeper/pipeline/errors"
 	"github.com/ory/oathkeeper/pipeline/mutate"
+	"github.com/ory/oathkeeper/redaction"
+	"github.com/ory/oathkeeper/risk"
 
 	"github.com/pkg/errors"
 
@@ -49,11 +58,23 @@ type requestHandlerRegistry interface {
 	authz.Registry
 	mutate.Registry
 	pe.Registry
+	redaction.Registry
+	risk.Registry
+	lockout.Registry
+	overload.Registry
+	honeypot.Registry
+	explain.Registry
 }
 
 type RequestHandler struct {
 	r requestHandlerRegistry
 	c configuration.Provider
+
+	conditions     *template.Template
+	conditionsLock sync.Mutex
+	patterns       sync.Map
+	hosts          sync.Map
+	loggers        sync.Map
 }
 
 type whenConfig struct {
@@ -61,7 +82,7 @@ type whenConfig struct {
 }
 
 func NewRequestHandler(r requestHandlerRegistry, c configuration.Provider) *RequestHandler {
-	return &RequestHandler{r: r, c: c}
+	return &RequestHandler{r: r, c: c, conditions: x.NewTemplate("conditions")}
 }
 
 // matchesWhen
@@ -89,6 +110,15 @@ func (d *RequestHandler) HandleError(w http.ResponseWriter, r *http.Request, rl
 		rl = new(rule.Rule)
 	}
 
+	// Error handlers may return the error to the client, so secrets must be removed first.
+	handleErr = d.r.Redactor().Error(handleErr)
+
+	// Denials are delayed before the error handler runs so that all error handlers are delayed alike.
+	d.tarpit(r, rl, handleErr)
+
+	w, explained := d.explainError(w, r, rl, handleErr)
+	defer explained()
+
 	var h pe.Handler
 	var config json.RawMessage
 	for _, re := range rl.Errors {
@@ -119,7 +149,7 @@ func (d *RequestHandler) HandleError(w http.ResponseWriter, r *http.Request, rl
 	}
 
 	if h == nil {
-		for _, name := range d.c.ErrorHandlerFallbackSpecificity() {
+		for _, name := range d.c.ErrorHandlerFallbackSpecificityFor(rule.ListenerFromContext(r.Context())) {
 			if !d.c.ErrorHandlerIsEnabled(name) {
 				d.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrInternalServerError.WithReasonf(
 					`Fallback error handler "%s" was requested but is disabled or unknown. This is a configuration issue and should be reported to the administrator.`, name,
@@ -170,20 +200,63 @@ func (d *RequestHandler) HandleError(w http.ResponseWriter, r *http.Request, rl
 func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session *authn.AuthenticationSession, err error) {
 	var found bool
 
+	sampleTrace(r, rl)
+	logger := d.RuleLogger(rl)
+	trace := explain.TraceFromContext(r.Context())
+
 	fields := map[string]interface{}{
 		"http_method":     r.Method,
 		"http_url":        r.URL.String(),
 		"http_host":       r.Host,
 		"http_user_agent": r.UserAgent(),
+		"client_ip":       d.ClientIP(r),
 		"rule_id":         rl.ID,
 	}
+	if rl.Owner != "" {
+		fields["rule_owner"] = rl.Owner
+	}
+
+	if rl.Honeypot {
+		d.r.HoneypotNotifier().Notify(honeypot.Alert{
+			Time:      time.Now().UTC(),
+			RuleID:    rl.ID,
+			Method:    r.Method,
+			URL:       r.URL.String(),
+			Host:      r.Host,
+			RemoteIP:  d.ClientIP(r),
+			UserAgent: r.UserAgent(),
+			Header:    r.Header.Clone(),
+		})
+	}
+
+	if d.c.OverloadIsEnabled() {
+		done, err := d.r.OverloadShedder().Admit(rl.Priority)
+		if err != nil {
+			trace.Step(StepOverload, "", explain.ResultFailed, err)
+			// Shed requests are only logged at debug level, logging each of them would add to the overload.
+			logger.WithError(err).
+				WithFields(fields).
+				WithField("granted", false).
+				WithField("reason_id", "overloaded").
+				Debug("The request was shed because the service is overloaded")
+			return nil, err
+		}
+		defer done()
+	}
 
 	// initialize the session used during all the flow
 	session = d.InitializeAuthnSession(r, rl)
+	initialized := session
+	defer func() {
+		// The session is only handed to the caller if the request was granted.
+		if err != nil {
+			authn.ReleaseSession(initialized)
+		}
+	}()
 
 	if len(rl.Authenticators) == 0 {
 		err = errors.New("No authentication handler was set in the rule")
-		d.r.Logger().WithError(err).
+		logger.WithError(err).
 			WithFields(fields).
 			WithField("granted", false).
 			WithField("reason_id", "authentication_handler_missing").
@@ -191,10 +264,55 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 		return nil, err
 	}
 
+	if err := validateRequest(r, rl.RequestValidation); err != nil {
+		trace.Step(StepRequestValidation, "", explain.ResultFailed, err)
+		logger.WithError(err).
+			WithFields(fields).
+			WithField("granted", false).
+			WithField("reason_id", "request_validation_error").
+			Warn("The request violates the request validation of the rule")
+		return nil, err
+	}
+
+	if err := d.checkLockout(r); err != nil {
+		trace.Step(StepLockout, "", explain.ResultFailed, err)
+		logger.WithError(err).
+			WithFields(fields).
+			WithField("granted", false).
+			WithField("reason_id", "authentication_locked").
+			Warn("Too many failed authentication attempts")
+		return nil, err
+	}
+
+	timer := NewStageTimer(r)
+	defer timer.Stop()
+
+	timer.Start(StageAuthentication)
 	for _, a := range rl.Authenticators {
+		matches, err := d.whenMatches(a.When, r)
+		if err != nil {
+			trace.Step(StageAuthentication, a.Handler, explain.ResultFailed, err)
+			logger.WithError(err).
+				WithFields(fields).
+				WithField("granted", false).
+				WithField("authentication_handler", a.Handler).
+				WithField("reason_id", "authentication_handler_when_error").
+				Warn("Unable to evaluate the predicates of the authentication handler")
+			return nil, err
+		} else if !matches {
+			// The request does not match the predicates of the authentication handler, skip to the next handler
+			trace.Step(StageAuthentication, a.Handler, explain.ResultSkipped, nil)
+			logger.
+				WithFields(fields).
+				WithField("authentication_handler", a.Handler).
+				Debug("Skipping authentication handler because the request does not match its predicates")
+			continue
+		}
+
 		anh, err := d.r.PipelineAuthenticator(a.Handler)
 		if err != nil {
-			d.r.Logger().WithError(err).
+			trace.Step(StageAuthentication, a.Handler, explain.ResultFailed, err)
+			logger.WithError(err).
 				WithFields(fields).
 				WithField("granted", false).
 				WithField("authentication_handler", a.Handler).
@@ -204,7 +322,8 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 		}
 
 		if err := anh.Validate(a.Config); err != nil {
-			d.r.Logger().WithError(err).
+			trace.Step(StageAuthentication, a.Handler, explain.ResultFailed, err)
+			logger.WithError(err).
 				WithFields(fields).
 				WithField("granted", false).
 				WithField("authentication_handler", a.Handler).
@@ -218,31 +337,40 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 			switch errors.Cause(err).Error() {
 			case authn.ErrAuthenticatorNotResponsible.Error():
 				// The authentication handler is not responsible for handling this request, skip to the next handler
+				trace.Step(StageAuthentication, a.Handler, explain.ResultNotResponsible, nil)
+				logger.
+					WithFields(fields).
+					WithField("authentication_handler", a.Handler).
+					Debug("The authentication handler is not responsible for handling the request")
 				break
 			// case ErrAuthenticatorBypassed.Error():
 			// The authentication handler says that no further authentication/authorization is required, and the request should
 			// be forwarded to its final destination.
 			// return nil
 			default:
-				d.r.Logger().WithError(err).
+				trace.Step(StageAuthentication, a.Handler, explain.ResultFailed, err)
+				logger.WithError(err).
 					WithFields(fields).
 					WithField("granted", false).
 					WithField("authentication_handler", a.Handler).
 					WithField("reason_id", "authentication_handler_error").
 					Warn("The authentication handler encountered an error")
+				d.recordLockoutFailure(r, err)
 				return nil, err
 			}
 		} else {
 			// The first authenticator that matches must return the session
+			trace.Step(StageAuthentication, a.Handler, explain.ResultPassed, nil)
 			found = true
 			fields["subject"] = session.Subject
+			d.resetLockout(r)
 			break
 		}
 	}
 
 	if !found {
 		err := errors.WithStack(helper.ErrUnauthorized)
-		d.r.Logger().WithError(err).
+		logger.WithError(err).
 			WithFields(fields).
 			WithField("granted", false).
 			WithField("reason_id", "authentication_handler_no_match").
@@ -250,9 +378,29 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 		return nil, err
 	}
 
+	timer.Start(StageAuthorization)
+	if assessment, err := d.assessRisk(r, rl, session); err != nil {
+		trace.Step(StepRisk, "", explain.ResultFailed, err)
+		l := logger.WithError(err).
+			WithFields(fields).
+			WithField("granted", false).
+			WithField("reason_id", "risk_error")
+		if assessment != nil {
+			l = l.WithField("reason_id", "risk_too_high").
+				WithField("risk_score", assessment.Score).
+				WithField("risk_reasons", assessment.Reasons)
+		}
+		l.Warn("The risk stage denied the request")
+		return nil, err
+	} else if assessment != nil {
+		trace.Step(StepRisk, "", explain.ResultPassed, nil)
+		fields["risk_score"] = assessment.Score
+	}
+
 	azh, err := d.r.PipelineAuthorizer(rl.Authorizer.Handler)
 	if err != nil {
-		d.r.Logger().WithError(err).
+		trace.Step(StageAuthorization, rl.Authorizer.Handler, explain.ResultFailed, err)
+		logger.WithError(err).
 			WithFields(fields).
 			WithField("granted", false).
 			WithField("authorization_handler", rl.Authorizer.Handler).
@@ -262,7 +410,8 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 	}
 
 	if err := azh.Validate(rl.Authorizer.Config); err != nil {
-		d.r.Logger().WithError(err).
+		trace.Step(StageAuthorization, rl.Authorizer.Handler, explain.ResultFailed, err)
+		logger.WithError(err).
 			WithFields(fields).
 			WithField("granted", false).
 			WithField("authorization_handler", rl.Authorizer.Handler).
@@ -272,7 +421,8 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 	}
 
 	if err := azh.Authorize(r, session, rl.Authorizer.Config, rl); err != nil {
-		d.r.Logger().
+		trace.Step(StageAuthorization, rl.Authorizer.Handler, explain.ResultFailed, err)
+		logger.
 			WithError(err).
 			WithFields(fields).
 			WithField("granted", false).
@@ -281,10 +431,11 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 			Warn("The authorization handler encountered an error")
 		return nil, err
 	}
+	trace.Step(StageAuthorization, rl.Authorizer.Handler, explain.ResultPassed, nil)
 
 	if len(rl.Mutators) == 0 {
 		err = errors.New("No mutation handler was set in the rule")
-		d.r.Logger().WithError(err).
+		logger.WithError(err).
 			WithFields(fields).
 			WithField("granted", false).
 			WithField("reason_id", "mutation_handler_missing").
@@ -292,10 +443,31 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 		return nil, err
 	}
 
+	timer.Start(StageMutation)
 	for _, m := range rl.Mutators {
+		holds, err := d.conditionHolds(m.Condition, r, session)
+		if err != nil {
+			trace.Step(StageMutation, m.Handler, explain.ResultFailed, err)
+			logger.WithError(err).
+				WithFields(fields).
+				WithField("granted", false).
+				WithField("mutation_handler", m.Handler).
+				WithField("reason_id", "mutation_handler_condition_error").
+				Warn("Unable to evaluate the condition of the mutator")
+			return nil, err
+		} else if !holds {
+			trace.Step(StageMutation, m.Handler, explain.ResultSkipped, nil)
+			logger.
+				WithFields(fields).
+				WithField("mutation_handler", m.Handler).
+				Debug("Skipping mutator because its condition does not hold")
+			continue
+		}
+
 		sh, err := d.r.PipelineMutator(m.Handler)
 		if err != nil {
-			d.r.Logger().WithError(err).
+			trace.Step(StageMutation, m.Handler, explain.ResultFailed, err)
+			logger.WithError(err).
 				WithFields(fields).
 				WithField("granted", false).
 				WithField("access_url", r.URL.String()).
@@ -306,7 +478,8 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 		}
 
 		if err := sh.Validate(m.Config); err != nil {
-			d.r.Logger().WithError(err).
+			trace.Step(StageMutation, m.Handler, explain.ResultFailed, err)
+			logger.WithError(err).
 				WithFields(fields).
 				WithField("granted", false).
 				WithField("mutation_handler", m.Handler).
@@ -316,7 +489,8 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 		}
 
 		if err := sh.Mutate(r, session, m.Config, rl); err != nil {
-			d.r.Logger().WithError(err).
+			trace.Step(StageMutation, m.Handler, explain.ResultFailed, err)
+			logger.WithError(err).
 				WithFields(fields).
 				WithField("granted", false).
 				WithField("mutation_handler", m.Handler).
@@ -324,17 +498,25 @@ func (d *RequestHandler) HandleRequest(r *http.Request, rl *rule.Rule) (session
 				Warn("The mutation handler encountered an error")
 			return nil, err
 		}
+		trace.Step(StageMutation, m.Handler, explain.ResultPassed, nil)
 	}
 
+	logger.
+		WithFields(fields).
+		Debug("The request passed all authentication, authorization, and mutation handlers")
+
 	return session, nil
 }
 
-// InitializeAuthnSession reates an authentication session and initializes it with a Match context if possible
-func (d *RequestHandler) InitializeAuthnSession(r *http.Request, rl *rule.Rule) *authn.AuthenticationSession {
+// ClientIP returns the IP address of the client which sent the request, derived using the trusted proxies.
+func (d *RequestHandler) ClientIP(r *http.Request) string {
+	return x.ClientIP(r, d.c.TrustedProxies())
+}
 
-	session := &authn.AuthenticationSession{
-		Subject: "",
-	}
+// InitializeAuthnSession creates an authentication session and initializes it with a Match context if possible. The
+// session is taken from a pool and may be returned to it using authn.ReleaseSession.
+func (d *RequestHandler) InitializeAuthnSession(r *http.Request, rl *rule.Rule) *authn.AuthenticationSession {
+	session := authn.AcquireSession()
 
 	values, err := rl.ExtractRegexGroups(d.c.AccessRuleMatchingStrategy(), r.URL)
 	if err != nil {
@@ -347,6 +529,7 @@ func (d *RequestHandler) InitializeAuthnSession(r *http.Request, rl *rule.Rule)
 		session.MatchContext = authn.MatchContext{
 			RegexpCaptureGroups: values,
 			URL:                 r.URL,
+			ClientIP:            d.ClientIP(r),
 		}
 	}
 
@@ -549,3 +549,84 @@ func TestInitializeSession(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestHandlerMutatorCondition(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorHeaderIsEnabled, true)
+	reg := internal.NewRegistry(conf)
+
+	rl := &rule.Rule{
+		Authenticators: []rule.Handler{{Handler: "anonymous"}},
+		Authorizer:     rule.Handler{Handler: "allow"},
+		Mutators: []rule.Handler{
+			{Handler: "header", Config: []byte(`{"headers":{"X-Always":"true"}}`)},
+			{Handler: "header", Config: []byte(`{"headers":{"X-Admin":"true"}}`), Condition: `{{ hasPrefix "/admin" .Request.URL.Path }}`},
+			{Handler: "header", Config: []byte(`{"headers":{"X-Subject":"true"}}`), Condition: `{{ eq .Subject "anonymous" }}`},
+		},
+	}
+
+	for k, tc := range []struct {
+		url    string
+		expect http.Header
+	}{
+		{url: "http://localhost/users", expect: http.Header{"X-Always": {"true"}, "X-Subject": {"true"}}},
+		{url: "http://localhost/admin/users", expect: http.Header{"X-Always": {"true"}, "X-Admin": {"true"}, "X-Subject": {"true"}}},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			s, err := reg.ProxyRequestHandler().HandleRequest(newTestRequest(tc.url), rl)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, s.Header)
+		})
+	}
+
+	t.Run("case=invalid condition", func(t *testing.T) {
+		_, err := reg.ProxyRequestHandler().HandleRequest(newTestRequest("http://localhost/users"), &rule.Rule{
+			Authenticators: []rule.Handler{{Handler: "anonymous"}},
+			Authorizer:     rule.Handler{Handler: "allow"},
+			Mutators:       []rule.Handler{{Handler: "header", Config: []byte(`{"headers":{"X-Foo":"bar"}}`), Condition: `{{ .Subject `}},
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestRequestHandlerAuthenticatorWhen(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthenticatorUnauthorizedIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	reg := internal.NewRegistry(conf)
+
+	rl := &rule.Rule{
+		Authenticators: []rule.Handler{
+			{Handler: "unauthorized", When: []rule.HandlerWhen{{Header: map[string]string{"Accept": "text/html"}}, {Path: "^/admin/"}}},
+			{Handler: "anonymous"},
+		},
+		Authorizer: rule.Handler{Handler: "allow"},
+		Mutators:   []rule.Handler{{Handler: "noop"}},
+	}
+
+	for k, tc := range []struct {
+		url       string
+		header    http.Header
+		expectErr bool
+	}{
+		{url: "http://localhost/users", header: http.Header{"Accept": {"application/json"}}},
+		{url: "http://localhost/users", header: http.Header{"Accept": {"text/html,application/xhtml+xml"}}, expectErr: true},
+		{url: "http://localhost/admin/users", header: http.Header{}, expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			r := newTestRequest(tc.url)
+			r.Header = tc.header
+
+			_, err := reg.ProxyRequestHandler().HandleRequest(r, rl)
+			if tc.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
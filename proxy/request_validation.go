@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/rule"
+)
+
+var (
+	errRequestURITooLong = &herodot.DefaultError{
+		ErrorField:  "The request URI is too long",
+		CodeField:   http.StatusRequestURITooLong,
+		StatusField: http.StatusText(http.StatusRequestURITooLong),
+	}
+	errRequestHeaderFieldsTooLarge = &herodot.DefaultError{
+		ErrorField:  "The request headers are too large",
+		CodeField:   http.StatusRequestHeaderFieldsTooLarge,
+		StatusField: http.StatusText(http.StatusRequestHeaderFieldsTooLarge),
+	}
+	errMethodNotAllowed = &herodot.DefaultError{
+		ErrorField:  "The request method is not allowed",
+		CodeField:   http.StatusMethodNotAllowed,
+		StatusField: http.StatusText(http.StatusMethodNotAllowed),
+	}
+	errUnsupportedMediaType = &herodot.DefaultError{
+		ErrorField:  "The content type of the request is not supported",
+		CodeField:   http.StatusUnsupportedMediaType,
+		StatusField: http.StatusText(http.StatusUnsupportedMediaType),
+	}
+)
+
+// validateRequest rejects the request if it violates the limits of the rule. It only looks at the request line and
+// the headers, the body is never read.
+func validateRequest(r *http.Request, rv *rule.RequestValidation) error {
+	if rv == nil {
+		return nil
+	}
+
+	for _, method := range rv.DisallowedMethods {
+		if strings.EqualFold(method, r.Method) {
+			return errors.WithStack(errMethodNotAllowed.WithReasonf("Method %s is not allowed.", r.Method))
+		}
+	}
+
+	uri := r.URL.RequestURI()
+	if rv.MaxURLLength > 0 && len(uri) > rv.MaxURLLength {
+		return errors.WithStack(errRequestURITooLong.WithReasonf("The request URI must not be longer than %d characters.", rv.MaxURLLength))
+	}
+
+	var count, size int
+	for name, values := range r.Header {
+		for _, value := range values {
+			count++
+			size += len(name) + len(value)
+			if rv.RejectNullBytes && strings.ContainsRune(value, 0) {
+				return errors.WithStack(helper.ErrBadRequest.WithReasonf("Header %s contains a null byte.", name))
+			}
+		}
+	}
+
+	if rv.MaxHeaderCount > 0 && count > rv.MaxHeaderCount {
+		return errors.WithStack(errRequestHeaderFieldsTooLarge.WithReasonf("The request must not have more than %d headers.", rv.MaxHeaderCount))
+	}
+
+	if rv.MaxHeaderSize > 0 && size > rv.MaxHeaderSize {
+		return errors.WithStack(errRequestHeaderFieldsTooLarge.WithReasonf("The request headers must not be larger than %d bytes.", rv.MaxHeaderSize))
+	}
+
+	if rv.RejectNullBytes && containsNullByte(uri) {
+		return errors.WithStack(helper.ErrBadRequest.WithReason("The request URI contains a null byte."))
+	}
+
+	if rv.RejectPathTraversal && containsPathTraversal(r.URL.EscapedPath()) {
+		return errors.WithStack(helper.ErrBadRequest.WithReason("The request path must not contain \"..\" segments."))
+	}
+
+	if len(rv.AllowedContentTypes) > 0 && hasBody(r) {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !allowsMediaType(rv.AllowedContentTypes, mediaType) {
+			return errors.WithStack(errUnsupportedMediaType.WithReasonf("Content type %s is not allowed.", r.Header.Get("Content-Type")))
+		}
+	}
+
+	return nil
+}
+
+func hasBody(r *http.Request) bool {
+	return r.ContentLength != 0 || len(r.TransferEncoding) > 0 || r.Header.Get("Content-Type") != ""
+}
+
+func containsNullByte(uri string) bool {
+	if strings.ContainsRune(uri, 0) {
+		return true
+	}
+
+	decoded, err := url.PathUnescape(uri)
+	return err == nil && strings.ContainsRune(decoded, 0)
+}
+
+// containsPathTraversal returns true if the path contains a ".." segment. Percent-encoding is decoded repeatedly so
+// that double encoded segments are found as well.
+func containsPathTraversal(path string) bool {
+	for i := 0; i <= 3; i++ {
+		for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+			if segment == ".." {
+				return true
+			}
+		}
+
+		decoded, err := url.PathUnescape(path)
+		if err != nil || decoded == path {
+			return false
+		}
+		path = decoded
+	}
+	return false
+}
+
+func allowsMediaType(allowed []string, mediaType string) bool {
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == mediaType || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+	return false
+}
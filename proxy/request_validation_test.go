@@ -0,0 +1,82 @@
+package proxy_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestRequestValidation(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorAnonymousIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	reg := internal.NewRegistry(conf)
+
+	rl := &rule.Rule{
+		Authenticators: []rule.Handler{{Handler: "anonymous"}},
+		Authorizer:     rule.Handler{Handler: "allow"},
+		Mutators:       []rule.Handler{{Handler: "noop"}},
+		RequestValidation: &rule.RequestValidation{
+			MaxHeaderCount:      3,
+			MaxHeaderSize:       100,
+			MaxURLLength:        40,
+			DisallowedMethods:   []string{"TRACE"},
+			AllowedContentTypes: []string{"application/json", "text/*"},
+			RejectNullBytes:     true,
+			RejectPathTraversal: true,
+		},
+	}
+
+	request := func(method, u string, header http.Header) *http.Request {
+		r := newTestRequest(u)
+		r.Method = method
+		r.Header = header
+		if r.Header == nil {
+			r.Header = http.Header{}
+		}
+		return r
+	}
+
+	for k, tc := range []struct {
+		d          string
+		r          *http.Request
+		expectCode int
+	}{
+		{d: "valid request", r: request("GET", "http://localhost/api/users", nil)},
+		{d: "valid body", r: request("POST", "http://localhost/api/users", http.Header{"Content-Type": {"application/json; charset=utf-8"}})},
+		{d: "wildcard content type", r: request("POST", "http://localhost/api/users", http.Header{"Content-Type": {"text/plain"}})},
+		{d: "disallowed method", r: request("TRACE", "http://localhost/api/users", nil), expectCode: http.StatusMethodNotAllowed},
+		{d: "url too long", r: request("GET", "http://localhost/api/users?filter="+strings.Repeat("a", 40), nil), expectCode: http.StatusRequestURITooLong},
+		{d: "too many headers", r: request("GET", "http://localhost/api/users", http.Header{"A": {"1", "2"}, "B": {"3", "4"}}), expectCode: http.StatusRequestHeaderFieldsTooLarge},
+		{d: "headers too large", r: request("GET", "http://localhost/api/users", http.Header{"A": {strings.Repeat("a", 100)}}), expectCode: http.StatusRequestHeaderFieldsTooLarge},
+		{d: "disallowed content type", r: request("POST", "http://localhost/api/users", http.Header{"Content-Type": {"application/xml"}}), expectCode: http.StatusUnsupportedMediaType},
+		{d: "null byte in header", r: request("GET", "http://localhost/api/users", http.Header{"A": {"a\x00"}}), expectCode: http.StatusBadRequest},
+		{d: "encoded null byte", r: request("GET", "http://localhost/api/users%00.json", nil), expectCode: http.StatusBadRequest},
+		{d: "path traversal", r: request("GET", "http://localhost/api/../admin", nil), expectCode: http.StatusBadRequest},
+		{d: "encoded path traversal", r: request("GET", "http://localhost/api/%2e%2e/admin", nil), expectCode: http.StatusBadRequest},
+		{d: "double encoded path traversal", r: request("GET", "http://localhost/api/%252e%252e/admin", nil), expectCode: http.StatusBadRequest},
+		{d: "dots in file names", r: request("GET", "http://localhost/api/file..txt", nil)},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			_, err := reg.ProxyRequestHandler().HandleRequest(tc.r, rl)
+			if tc.expectCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+		})
+	}
+}
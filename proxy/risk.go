@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/risk"
+	"github.com/ory/oathkeeper/rule"
+)
+
+// assessRisk scores the risk of an authenticated request if the rule asks for it. The score is added to the session
+// so that mutators can forward it upstream. An error is returned if the request must be denied or requires step-up
+// authentication.
+func (d *RequestHandler) assessRisk(r *http.Request, rl *rule.Rule, session *authn.AuthenticationSession) (*risk.Assessment, error) {
+	if rl.Risk == nil {
+		return nil, nil
+	}
+
+	if !d.c.RiskIsEnabled() {
+		return nil, errors.WithStack(helper.ErrRuleFeatureDisabled.WithReason("Risk scoring was requested by the rule but is disabled."))
+	}
+
+	signals := &risk.Signals{
+		IP:        d.ClientIP(r),
+		Subject:   session.Subject,
+		UserAgent: r.UserAgent(),
+		Method:    r.Method,
+		URL:       r.URL.String(),
+		RuleID:    rl.ID,
+	}
+
+	for key, header := range d.c.RiskGeoHeaders() {
+		if value := r.Header.Get(header); value != "" {
+			if signals.Geo == nil {
+				signals.Geo = map[string]string{}
+			}
+			signals.Geo[key] = value
+		}
+	}
+
+	assessment, err := d.r.RiskScorer().Score(r.Context(), signals)
+	if err != nil {
+		if d.c.RiskFailOpen() {
+			d.RuleLogger(rl).WithError(err).
+				WithField("rule_id", rl.ID).
+				Warn("Unable to score the risk of the request, continuing because risk.fail_open is enabled")
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if session.Extra == nil {
+		session.Extra = map[string]interface{}{}
+	}
+	session.Extra[d.c.RiskClaim()] = assessment.Score
+
+	policy := risk.Policy{DenyAbove: d.c.RiskDenyAbove(), StepUpAbove: d.c.RiskStepUpAbove()}
+	if rl.Risk.DenyAbove != nil {
+		policy.DenyAbove = *rl.Risk.DenyAbove
+	}
+	if rl.Risk.StepUpAbove != nil {
+		policy.StepUpAbove = *rl.Risk.StepUpAbove
+	}
+
+	return assessment, policy.Enforce(assessment)
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/rule"
+)
+
+// SecurityHeaders returns the security headers of the proxy listener serving the request with the overrides of the
+// rule applied.
+func (d *RequestHandler) SecurityHeaders(r *http.Request, rl *rule.Rule) http.Header {
+	listener := d.c.ProxySecurityHeaders(rule.ListenerFromContext(r.Context()))
+	h := listener.Headers()
+	if rl == nil || rl.SecurityHeaders == nil {
+		return h
+	}
+
+	o := rl.SecurityHeaders
+	if o.Profile != "" {
+		h = configuration.SecurityHeadersProfile(o.Profile)
+	}
+
+	for name, value := range map[string]*string{
+		"Strict-Transport-Security": o.StrictTransportSecurity,
+		"X-Content-Type-Options":    o.ContentTypeOptions,
+		"Referrer-Policy":           o.ReferrerPolicy,
+		"X-Frame-Options":           o.FrameOptions,
+	} {
+		if value == nil {
+			continue
+		} else if *value == "" {
+			h.Del(name)
+		} else {
+			h.Set(name, *value)
+		}
+	}
+
+	return h
+}
+
+// ApplySecurityHeaders adds the security headers to the headers of a response. Headers set by the upstream are kept.
+func (d *RequestHandler) ApplySecurityHeaders(header http.Header, r *http.Request, rl *rule.Rule) {
+	for name, values := range d.SecurityHeaders(r, rl) {
+		if _, ok := header[name]; !ok {
+			header[name] = values
+		}
+	}
+}
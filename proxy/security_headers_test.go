@@ -0,0 +1,82 @@
+package proxy_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	empty := ""
+	sameOrigin := "SAMEORIGIN"
+	for k, tc := range []struct {
+		config  map[string]string
+		rule    *rule.Rule
+		present map[string]string
+		absent  []string
+	}{
+		{
+			absent: []string{"Strict-Transport-Security", "X-Frame-Options"},
+		},
+		{
+			config: map[string]string{"profile": "strict", "referrer_policy": "no-referrer"},
+			present: map[string]string{
+				"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "no-referrer",
+				"X-Frame-Options":           "DENY",
+			},
+		},
+		{
+			config:  map[string]string{"frame_options": "DENY"},
+			present: map[string]string{"X-Frame-Options": "DENY"},
+			absent:  []string{"Strict-Transport-Security"},
+		},
+		{
+			config:  map[string]string{"profile": "strict"},
+			rule:    &rule.Rule{SecurityHeaders: &rule.SecurityHeaders{FrameOptions: &empty, ReferrerPolicy: &sameOrigin}},
+			present: map[string]string{"X-Content-Type-Options": "nosniff", "Referrer-Policy": "SAMEORIGIN"},
+			absent:  []string{"X-Frame-Options"},
+		},
+		{
+			config: map[string]string{"profile": "strict"},
+			rule:   &rule.Rule{SecurityHeaders: &rule.SecurityHeaders{Profile: "none"}},
+			absent: []string{"Strict-Transport-Security", "X-Content-Type-Options", "Referrer-Policy", "X-Frame-Options"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			for _, key := range []string{"profile", "strict_transport_security", "content_type_options", "referrer_policy", "frame_options"} {
+				viper.Set(configuration.ViperKeyProxySecurityHeaders+"."+key, tc.config[key])
+			}
+
+			h := reg.ProxyRequestHandler().SecurityHeaders(newTestRequest("http://localhost"), tc.rule)
+			for name, value := range tc.present {
+				assert.Equal(t, value, h.Get(name), name)
+			}
+			for _, name := range tc.absent {
+				assert.Empty(t, h.Get(name), name)
+			}
+		})
+	}
+
+	t.Run("case=headers of the upstream are kept", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyProxySecurityHeaders+".profile", "strict")
+		defer viper.Set(configuration.ViperKeyProxySecurityHeaders+".profile", "")
+
+		h := http.Header{"X-Frame-Options": {"SAMEORIGIN"}}
+		reg.ProxyRequestHandler().ApplySecurityHeaders(h, newTestRequest("http://localhost"), nil)
+		assert.Equal(t, "SAMEORIGIN", h.Get("X-Frame-Options"))
+		assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+	})
+}
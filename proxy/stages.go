@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	StageMatch          = "match"
+	StageAuthentication = "authentication"
+	StageAuthorization  = "authorization"
+	StageMutation       = "mutation"
+)
+
+// StageTimings are the durations of the stages of the pipeline a request passed, keyed by the name of the stage.
+type StageTimings map[string]time.Duration
+
+// WithStageTimings returns a context which makes the pipeline record the durations of its stages into the timings.
+// Stages are not measured for requests without it.
+func WithStageTimings(ctx context.Context, timings StageTimings) context.Context {
+	return context.WithValue(ctx, contextKeyStageTimings, timings)
+}
+
+// StageTimer measures consecutive stages of a request. It does nothing if the context of the request does not carry
+// stage timings.
+type StageTimer struct {
+	timings StageTimings
+	stage   string
+	started time.Time
+}
+
+func NewStageTimer(r *http.Request) *StageTimer {
+	timings, _ := r.Context().Value(contextKeyStageTimings).(StageTimings)
+	return &StageTimer{timings: timings}
+}
+
+// Start stops the current stage and starts the given one.
+func (t *StageTimer) Start(stage string) {
+	if t.timings == nil {
+		return
+	}
+	t.Stop()
+	t.stage, t.started = stage, time.Now()
+}
+
+// Stop stops the current stage.
+func (t *StageTimer) Stop() {
+	if t.timings == nil || t.stage == "" {
+		return
+	}
+	t.timings[t.stage] += time.Since(t.started)
+	t.stage = ""
+}
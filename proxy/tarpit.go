@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/rule"
+)
+
+// tarpit delays the response if the rule has a tarpit and the request was denied with status code 401 or 403.
+func (d *RequestHandler) tarpit(r *http.Request, rl *rule.Rule, err error) {
+	if rl.Tarpit == nil {
+		return
+	}
+
+	if code := herodot.ToDefaultError(err, "").StatusCode(); code != http.StatusUnauthorized && code != http.StatusForbidden {
+		return
+	}
+
+	min, max, perr := rl.Tarpit.Delays()
+	if perr != nil {
+		d.r.Logger().WithError(perr).WithField("rule_id", rl.ID).Warn("Unable to parse the tarpit delays of the rule")
+		return
+	}
+
+	delay := min
+	if max > min {
+		delay += time.Duration(rand.Int63n(int64(max - min)))
+	}
+
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,46 @@
+package proxy_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestTarpit(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+
+	tarpit := &rule.Tarpit{MinDelay: "50ms", MaxDelay: "60ms"}
+	for k, tc := range []struct {
+		err     error
+		rule    *rule.Rule
+		delayed bool
+	}{
+		{err: &herodot.ErrUnauthorized, rule: &rule.Rule{Tarpit: tarpit}, delayed: true},
+		{err: &herodot.ErrForbidden, rule: &rule.Rule{Tarpit: tarpit}, delayed: true},
+		{err: &herodot.ErrNotFound, rule: &rule.Rule{Tarpit: tarpit}},
+		{err: &herodot.ErrUnauthorized, rule: &rule.Rule{}},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			start := time.Now()
+			reg.ProxyRequestHandler().HandleError(w, newTestRequest("http://localhost"), tc.rule, tc.err)
+			elapsed := time.Since(start)
+
+			assert.Equal(t, herodot.ToDefaultError(tc.err, "").StatusCode(), w.Code)
+			if tc.delayed {
+				assert.True(t, elapsed >= 50*time.Millisecond, "%s", elapsed)
+			} else {
+				assert.True(t, elapsed < 50*time.Millisecond, "%s", elapsed)
+			}
+		})
+	}
+}
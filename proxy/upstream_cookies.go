@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ory/go-convenience/stringslice"
+
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/rule"
+)
+
+// StripAuthCookies removes the cookies the authenticators read credentials from if the rule asks for it. The other
+// cookies are forwarded as is. It must be called before the headers set by mutators are added because those are
+// always forwarded.
+func StripAuthCookies(h http.Header, s *authn.AuthenticationSession, rl *rule.Rule) {
+	if !rl.Upstream.StripAuthCookies || len(s.ConsumedCookies) == 0 {
+		return
+	}
+
+	var kept []string
+	for _, line := range h["Cookie"] {
+		for _, pair := range strings.Split(line, ";") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			name := strings.SplitN(pair, "=", 2)[0]
+			if !stringslice.Has(s.ConsumedCookies, name) {
+				kept = append(kept, pair)
+			}
+		}
+	}
+
+	if len(kept) == 0 {
+		h.Del("Cookie")
+		return
+	}
+	h.Set("Cookie", strings.Join(kept, "; "))
+}
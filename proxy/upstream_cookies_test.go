@@ -0,0 +1,56 @@
+package proxy_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/proxy"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestStripAuthCookies(t *testing.T) {
+	for k, tc := range []struct {
+		strip    bool
+		consumed []string
+		cookies  []string
+		expect   []string
+	}{
+		{
+			consumed: []string{"session"},
+			cookies:  []string{"session=secret; theme=dark"},
+			expect:   []string{"session=secret; theme=dark"},
+		},
+		{
+			strip:   true,
+			cookies: []string{"session=secret; theme=dark"},
+			expect:  []string{"session=secret; theme=dark"},
+		},
+		{
+			strip:    true,
+			consumed: []string{"session"},
+			cookies:  []string{"session=secret; theme=dark"},
+			expect:   []string{"theme=dark"},
+		},
+		{
+			strip:    true,
+			consumed: []string{"session", "token"},
+			cookies:  []string{"session=secret; theme=dark", "token=secret;lang=en"},
+			expect:   []string{"theme=dark; lang=en"},
+		},
+		{
+			strip:    true,
+			consumed: []string{"session"},
+			cookies:  []string{"session=secret"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			h := http.Header{"Cookie": tc.cookies}
+			proxy.StripAuthCookies(h, &authn.AuthenticationSession{ConsumedCookies: tc.consumed}, &rule.Rule{Upstream: rule.Upstream{StripAuthCookies: tc.strip}})
+			assert.Equal(t, tc.expect, h["Cookie"])
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/rule"
+)
+
+const (
+	awsSigV4Algorithm       = "AWS4-HMAC-SHA256"
+	awsSigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// upstreamTokenSources caches OAuth 2.0 token sources so that access tokens are reused until they expire.
+type upstreamTokenSources struct {
+	sync.Mutex
+	sources map[string]oauth2.TokenSource
+}
+
+func (s *upstreamTokenSources) get(c *rule.UpstreamOAuth2ClientCredentials) (oauth2.TokenSource, error) {
+	key, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.sources == nil {
+		s.sources = map[string]oauth2.TokenSource{}
+	}
+
+	if ts, ok := s.sources[string(key)]; ok {
+		return ts, nil
+	}
+
+	cc := &clientcredentials.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		TokenURL:     c.TokenURL,
+		Scopes:       c.Scopes,
+		AuthStyle:    oauth2.AuthStyleInHeader,
+	}
+	if c.Audience != "" {
+		cc.EndpointParams = url.Values{"audience": {c.Audience}}
+	}
+
+	ts := cc.TokenSource(context.WithValue(
+		context.Background(),
+		oauth2.HTTPClient,
+		httpx.NewResilientClientLatencyToleranceMedium(nil),
+	))
+	s.sources[string(key)] = ts
+	return ts, nil
+}
+
+// ConfigureUpstreamCredentials authenticates the request forwarded to the upstream as configured by
+// the rule's upstream credentials. It must be called after ConfigureBackendURL.
+func (d *Proxy) ConfigureUpstreamCredentials(r *http.Request, rl *rule.Rule) error {
+	c := rl.Upstream.Credentials
+	if c == nil {
+		return nil
+	}
+
+	if c.AWSSigV4 != nil {
+		return SignAWSSigV4(r, c.AWSSigV4, time.Now())
+	}
+
+	if c.OAuth2ClientCredentials != nil {
+		ts, err := d.tokens.get(c.OAuth2ClientCredentials)
+		if err != nil {
+			return err
+		}
+
+		token, err := ts.Token()
+		if err != nil {
+			return errors.Wrap(err, "unable to obtain access token for upstream")
+		}
+
+		r.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	}
+
+	return nil
+}
+
+// SignAWSSigV4 signs the request using AWS Signature Version 4.
+func SignAWSSigV4(r *http.Request, c *rule.UpstreamAWSSigV4, now time.Time) error {
+	accessKeyID := coalesce(c.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID"))
+	secretAccessKey := coalesce(c.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	sessionToken := coalesce(c.SessionToken, os.Getenv("AWS_SESSION_TOKEN"))
+	if accessKeyID == "" || secretAccessKey == "" {
+		return errors.New("unable to sign upstream request because no AWS credentials are configured")
+	}
+
+	payloadHash := awsSigV4UnsignedPayload
+	if !c.UnsignedPayload {
+		var body []byte
+		if r.Body != nil {
+			var err error
+			if body, err = ioutil.ReadAll(r.Body); err != nil {
+				return errors.WithStack(err)
+			}
+			_ = r.Body.Close()
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		payloadHash = awsHash(body)
+	}
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	r.Header.Set("X-Amz-Date", amzDate)
+	if c.Service == "s3" {
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name := range r.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.Join(strings.Fields(r.Header.Get(name)), " ")
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	path = awsEscape(path, false)
+	if c.Service != "s3" {
+		// All services except S3 expect the path to be encoded twice.
+		path = awsEscape(path, false)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		path,
+		awsCanonicalQuery(r.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, c.Region, c.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{awsSigV4Algorithm, amzDate, scope, awsHash([]byte(canonicalRequest))}, "\n")
+
+	key := awsHMAC([]byte("AWS4"+secretAccessKey), date)
+	key = awsHMAC(key, c.Region)
+	key = awsHMAC(key, c.Service)
+	key = awsHMAC(key, "aws4_request")
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSigV4Algorithm, accessKeyID, scope, signedHeaders, hex.EncodeToString(awsHMAC(key, stringToSign))))
+
+	return nil
+}
+
+func awsCanonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	encoded := make(url.Values, len(q))
+	for key, values := range q {
+		k := awsEscape(key, true)
+		keys = append(keys, k)
+		for _, value := range values {
+			encoded[k] = append(encoded[k], awsEscape(value, true))
+		}
+		sort.Strings(encoded[k])
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range encoded[key] {
+			pairs = append(pairs, key+"="+value)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape URI-encodes every byte except the unreserved characters defined in RFC 3986.
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func awsHash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func awsHMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func coalesce(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
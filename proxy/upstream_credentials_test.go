@@ -0,0 +1,93 @@
+package proxy_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/proxy"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestSignAWSSigV4(t *testing.T) {
+	// Test vectors are taken from the AWS Signature Version 4 test suite.
+	now, err := time.Parse("20060102T150405Z", "20150830T123600Z")
+	require.NoError(t, err)
+
+	c := &rule.UpstreamAWSSigV4{
+		Region:          "us-east-1",
+		Service:         "service",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+
+	for k, tc := range []struct {
+		url       string
+		signature string
+	}{
+		{url: "https://example.amazonaws.com/", signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{url: "https://example.amazonaws.com/?Param2=value2&Param1=value1", signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			r, err := http.NewRequest("GET", tc.url, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, proxy.SignAWSSigV4(r, c, now))
+			assert.Equal(t, "20150830T123600Z", r.Header.Get("X-Amz-Date"))
+			assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature="+tc.signature, r.Header.Get("Authorization"))
+		})
+	}
+
+	t.Run("case=s3", func(t *testing.T) {
+		r, err := http.NewRequest("GET", "https://bucket.s3.amazonaws.com/key", nil)
+		require.NoError(t, err)
+
+		require.NoError(t, proxy.SignAWSSigV4(r, &rule.UpstreamAWSSigV4{Region: "us-east-1", Service: "s3", AccessKeyID: "a", SecretAccessKey: "b", UnsignedPayload: true}, now))
+		assert.Equal(t, "UNSIGNED-PAYLOAD", r.Header.Get("X-Amz-Content-Sha256"))
+		assert.Contains(t, r.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date,")
+	})
+}
+
+func TestConfigureUpstreamCredentials(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "https://api/", r.PostForm.Get("audience"))
+
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client", user)
+		assert.Equal(t, "secret", password)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"access_token":"upstream-token","token_type":"bearer","expires_in":3600}`)
+	}))
+	defer ts.Close()
+
+	p := proxy.NewProxy(nil)
+	rl := &rule.Rule{Upstream: rule.Upstream{Credentials: &rule.UpstreamCredentials{
+		OAuth2ClientCredentials: &rule.UpstreamOAuth2ClientCredentials{
+			TokenURL:     ts.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+			Audience:     "https://api/",
+		},
+	}}}
+
+	for i := 0; i < 2; i++ {
+		r, err := http.NewRequest("GET", "https://upstream/", nil)
+		require.NoError(t, err)
+		require.NoError(t, p.ConfigureUpstreamCredentials(r, rl))
+		assert.Equal(t, "Bearer upstream-token", r.Header.Get("Authorization"))
+	}
+
+	// The token is cached until it expires.
+	assert.Equal(t, 1, requests)
+}
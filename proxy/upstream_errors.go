@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/oathkeeper/overload"
+	"github.com/ory/oathkeeper/rule"
+	"github.com/ory/oathkeeper/x"
+)
+
+// Reasons the upstream could not be reached.
+const (
+	UpstreamErrorConnect    = "connect"
+	UpstreamErrorTimeout    = "timeout"
+	UpstreamErrorTLS        = "tls"
+	UpstreamErrorOverloaded = "overloaded"
+	UpstreamErrorUnknown    = "unknown"
+)
+
+var (
+	errUpstreamTimeout = &herodot.DefaultError{
+		ErrorField:  "The upstream did not respond in time",
+		CodeField:   http.StatusGatewayTimeout,
+		StatusField: http.StatusText(http.StatusGatewayTimeout),
+	}
+	errUpstreamUnavailable = &herodot.DefaultError{
+		ErrorField:  "The upstream is unavailable",
+		CodeField:   http.StatusBadGateway,
+		StatusField: http.StatusText(http.StatusBadGateway),
+	}
+)
+
+// ClassifyUpstreamError returns why the round trip to the upstream failed: the upstream timed out, the connection
+// could not be established, the TLS handshake failed, or the concurrency limit of the upstream was reached.
+func ClassifyUpstreamError(err error) string {
+	var limitErr *upstreamLimitError
+	if errors.As(err, &limitErr) {
+		return UpstreamErrorOverloaded
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return UpstreamErrorTimeout
+	}
+
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) || strings.Contains(err.Error(), "tls: ") {
+		return UpstreamErrorTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return UpstreamErrorConnect
+	}
+
+	return UpstreamErrorUnknown
+}
+
+// UpstreamErrorData is the data the body of a rule's upstream error response is rendered with.
+type UpstreamErrorData struct {
+	// Reason is one of "connect", "timeout", "tls", "overloaded", and "unknown".
+	Reason     string
+	StatusCode int
+	RuleID     string
+	// DocsURL is the URL of the rule's documentation or empty if the rule has none.
+	DocsURL string
+}
+
+// upstreamErrorResponse returns the response sent to the client if the upstream could not be reached. Unless the
+// rule defines its own response, the error handlers of the rule are used.
+func (d *Proxy) upstreamErrorResponse(r *http.Request, rl *rule.Rule, reason string) *http.Response {
+	code, herr := http.StatusBadGateway, errUpstreamUnavailable
+	switch reason {
+	case UpstreamErrorTimeout:
+		code, herr = http.StatusGatewayTimeout, errUpstreamTimeout
+	case UpstreamErrorOverloaded:
+		code, herr = http.StatusServiceUnavailable, overload.ErrUpstreamOverloaded
+	}
+
+	rw := NewSimpleResponseWriter()
+	if rl != nil && rl.Upstream.ErrorResponse != nil {
+		if err := d.renderUpstreamErrorResponse(rw, rl, reason, code); err != nil {
+			d.r.Logger().WithError(err).WithField("rule_id", rl.ID).Error("Unable to render the upstream error response of the access rule")
+			rw = NewSimpleResponseWriter()
+			d.r.ProxyRequestHandler().HandleError(rw, r, rl, errors.WithStack(herr.WithDetail("upstream_error", reason)))
+		}
+	} else {
+		d.r.ProxyRequestHandler().HandleError(rw, r, rl, errors.WithStack(herr.WithDetail("upstream_error", reason)))
+	}
+	d.r.ProxyRequestHandler().ApplySecurityHeaders(rw.header, r, rl)
+
+	return &http.Response{
+		StatusCode: rw.code,
+		Body:       ioutil.NopCloser(rw.buffer),
+		Header:     rw.header,
+	}
+}
+
+func (d *Proxy) renderUpstreamErrorResponse(rw *simpleResponseWriter, rl *rule.Rule, reason string, code int) error {
+	c := rl.Upstream.ErrorResponse
+	if c.StatusCode != 0 {
+		code = c.StatusCode
+	}
+
+	t, err := d.errorResponseTemplate(c.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := t.Execute(rw, &UpstreamErrorData{Reason: reason, StatusCode: code, RuleID: rl.ID, DocsURL: rl.DocsURL}); err != nil {
+		return errors.Wrapf(err, `error executing upstream error response of rule "%s"`, rl.ID)
+	}
+
+	contentType := c.ContentType
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+	rw.header.Set("Content-Type", contentType)
+	rw.WriteHeader(code)
+	return nil
+}
+
+// errorResponseTemplate returns the parsed body of an upstream error response. Templates are cached by their source.
+func (d *Proxy) errorResponseTemplate(body string) (*template.Template, error) {
+	if t, ok := d.errorResponses.Load(body); ok {
+		return t.(*template.Template), nil
+	}
+
+	t, err := x.NewTemplate("upstream_error_response").Parse(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing upstream error response")
+	}
+	d.errorResponses.Store(body, t)
+
+	return t, nil
+}
@@ -0,0 +1,134 @@
+package proxy_test
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/proxy"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestClassifyUpstreamError(t *testing.T) {
+	for k, tc := range []struct {
+		err    error
+		expect string
+	}{
+		{err: context.DeadlineExceeded, expect: proxy.UpstreamErrorTimeout},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expect: proxy.UpstreamErrorConnect},
+		{err: x509.UnknownAuthorityError{}, expect: proxy.UpstreamErrorTLS},
+		{err: errors.New("remote error: tls: handshake failure"), expect: proxy.UpstreamErrorTLS},
+		{err: errors.New("EOF"), expect: proxy.UpstreamErrorUnknown},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			assert.Equal(t, tc.expect, proxy.ClassifyUpstreamError(tc.err))
+		})
+	}
+}
+
+func TestProxyUpstreamErrors(t *testing.T) {
+	// The upstream is closed right away so that connections are refused.
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backend.Close()
+
+	conf := internal.NewConfigurationWithDefaults()
+	reg := internal.NewRegistry(conf)
+	viper.Set(configuration.ViperKeyAuthenticatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+
+	d := reg.Proxy()
+	ts := httptest.NewServer(&httputil.ReverseProxy{Director: d.Director, Transport: d})
+	defer ts.Close()
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "fallback "+r.URL.Path)
+	}))
+	defer fallback.Close()
+
+	newRule := func(id string, upstream rule.Upstream) rule.Rule {
+		upstream.URL = backend.URL
+		return rule.Rule{
+			ID:             id,
+			Match:          &rule.Match{Methods: []string{"GET", "POST"}, URL: ts.URL + "/" + id},
+			Authenticators: []rule.Handler{{Handler: "noop"}},
+			Authorizer:     rule.Handler{Handler: "allow"},
+			Mutators:       []rule.Handler{{Handler: "noop"}},
+			Upstream:       upstream,
+		}
+	}
+	static := &rule.UpstreamFallbackResponse{Body: `{"items":[]}`}
+	require.NoError(t, reg.RuleRepository().Set(context.Background(), []rule.Rule{
+		newRule("default", rule.Upstream{}),
+		newRule("custom", rule.Upstream{ErrorResponse: &rule.UpstreamErrorResponse{StatusCode: 503, Body: "{{ .RuleID }} is down ({{ .Reason }}, {{ .StatusCode }})"}}),
+		newRule("fallback-url", rule.Upstream{Fallback: &rule.UpstreamFallback{URL: fallback.URL, Response: static}}),
+		newRule("fallback-static", rule.Upstream{Fallback: &rule.UpstreamFallback{URL: backend.URL, Response: static}}),
+	}))
+
+	t.Run("case=error handlers respond", func(t *testing.T) {
+		res, err := http.Get(ts.URL + "/default")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+		assert.Contains(t, string(body), `"upstream_error":"connect"`)
+	})
+
+	t.Run("case=custom error response", func(t *testing.T) {
+		res, err := http.Get(ts.URL + "/custom")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		assert.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+		assert.Equal(t, "custom is down (connect, 503)", string(body))
+	})
+
+	for k, tc := range []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{method: "GET", path: "/fallback-url", code: http.StatusOK, body: "fallback /fallback-url"},
+		{method: "POST", path: "/fallback-url", code: http.StatusOK, body: `{"items":[]}`},
+		{method: "GET", path: "/fallback-static", code: http.StatusOK, body: `{"items":[]}`},
+	} {
+		t.Run(fmt.Sprintf("case=fallback-%d", k), func(t *testing.T) {
+			var body io.Reader
+			if tc.method == "POST" {
+				body = strings.NewReader("body")
+			}
+			req, err := http.NewRequest(tc.method, ts.URL+tc.path, body)
+			require.NoError(t, err)
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			out, err := ioutil.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.code, res.StatusCode)
+			assert.Equal(t, tc.body, string(out))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/rule"
+)
+
+// fallbackTarget is where requests are sent if the upstream of the rule can not be reached.
+type fallbackTarget struct {
+	url  *url.URL
+	host string
+}
+
+// configureFallback computes the URL of the rule's fallback upstream, if any, before the request is rewritten for
+// the primary upstream. Requests with a body are never retried, so the fallback upstream is only used for requests
+// without one.
+func configureFallback(r *http.Request, rl *rule.Rule) (*http.Request, error) {
+	if rl.Upstream.Fallback == nil || rl.Upstream.Fallback.URL == "" || hasBody(r) {
+		return r, nil
+	}
+
+	fr := r.Clone(r.Context())
+	fallback := *rl
+	fallback.Upstream.URL = rl.Upstream.Fallback.URL
+	if err := ConfigureBackendURL(fr, &fallback); err != nil {
+		return nil, err
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), contextKeyFallback, &fallbackTarget{url: fr.URL, host: fr.Host})), nil
+}
+
+// fallbackResponse returns the response of the rule's fallback upstream or its static fallback response. It returns
+// nil if the rule has no fallback or if the fallback upstream can not be reached either and there is no static
+// response.
+func (d *Proxy) fallbackResponse(r *http.Request, rl *rule.Rule) *http.Response {
+	if rl == nil || rl.Upstream.Fallback == nil {
+		return nil
+	}
+
+	if t, ok := r.Context().Value(contextKeyFallback).(*fallbackTarget); ok && t != nil {
+		fr := r.Clone(r.Context())
+		fr.URL, fr.Host = t.url, t.host
+
+		res, err := http.DefaultTransport.RoundTrip(fr)
+		if err == nil {
+			return res
+		}
+
+		d.r.Logger().
+			WithError(errors.WithStack(err)).
+			WithField("rule_id", rl.ID).
+			WithField("upstream_error", ClassifyUpstreamError(err)).
+			Warn("Unable to reach the fallback upstream")
+	}
+
+	c := rl.Upstream.Fallback.Response
+	if c == nil {
+		return nil
+	}
+
+	code, contentType := http.StatusOK, "application/json"
+	if c.StatusCode != 0 {
+		code = c.StatusCode
+	}
+	if c.ContentType != "" {
+		contentType = c.ContentType
+	}
+
+	return &http.Response{
+		StatusCode: code,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(c.Body)),
+		Header:     http.Header{"Content-Type": {contentType}},
+	}
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ory/oathkeeper/rule"
+)
+
+// FilterUpstreamHeaders removes the headers of the incoming request which the rule does not forward to the upstream.
+// It must be called before the headers set by mutators are added because those are always forwarded.
+func FilterUpstreamHeaders(h http.Header, rl *rule.Rule) {
+	c := rl.Upstream.Headers
+	if c == nil {
+		return
+	}
+
+	for name := range h {
+		if (len(c.Allow) > 0 && !matchesHeaderName(c.Allow, name)) || matchesHeaderName(c.Deny, name) {
+			delete(h, name)
+		}
+	}
+}
+
+// matchesHeaderName returns true if the name equals one of the names, ignoring case. Names ending with "*" match all
+// headers with that prefix.
+func matchesHeaderName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.HasSuffix(n, "*") {
+			prefix := strings.TrimSuffix(n, "*")
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
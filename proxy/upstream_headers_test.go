@@ -0,0 +1,56 @@
+package proxy_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/oathkeeper/proxy"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestFilterUpstreamHeaders(t *testing.T) {
+	incoming := func() http.Header {
+		return http.Header{
+			"Accept":          {"application/json"},
+			"Accept-Language": {"en"},
+			"Cookie":          {"session=secret"},
+			"X-Internal-Id":   {"1"},
+			"X-Request-Id":    {"2"},
+		}
+	}
+
+	for k, tc := range []struct {
+		headers *rule.UpstreamHeaders
+		expect  []string
+	}{
+		{
+			expect: []string{"Accept", "Accept-Language", "Cookie", "X-Internal-Id", "X-Request-Id"},
+		},
+		{
+			headers: &rule.UpstreamHeaders{Allow: []string{"accept*", "x-request-id"}},
+			expect:  []string{"Accept", "Accept-Language", "X-Request-Id"},
+		},
+		{
+			headers: &rule.UpstreamHeaders{Deny: []string{"Cookie", "X-Internal-*"}},
+			expect:  []string{"Accept", "Accept-Language", "X-Request-Id"},
+		},
+		{
+			headers: &rule.UpstreamHeaders{Allow: []string{"Accept*"}, Deny: []string{"Accept-Language"}},
+			expect:  []string{"Accept"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			h := incoming()
+			proxy.FilterUpstreamHeaders(h, &rule.Rule{Upstream: rule.Upstream{Headers: tc.headers}})
+
+			var names []string
+			for name := range h {
+				names = append(names, name)
+			}
+			assert.ElementsMatch(t, tc.expect, names)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// upstreamLimitError is returned if the concurrency limit of the upstream is reached.
+type upstreamLimitError struct {
+	error
+}
+
+func (e *upstreamLimitError) Unwrap() error {
+	return e.error
+}
+
+// limitedBody releases the concurrency limit of the upstream once the response body is closed.
+type limitedBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *limitedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// roundTripUpstream sends the request to the upstream within the concurrency limit of the upstream. The request is in
+// flight until the body of the response is closed, its latency is the time until the response headers arrived.
+func (d *Proxy) roundTripUpstream(r *http.Request) (*http.Response, error) {
+	release, err := d.r.UpstreamLimiter().Acquire(r.URL.Host)
+	if err != nil {
+		return nil, &upstreamLimitError{error: err}
+	}
+
+	started := time.Now()
+	res, err := http.DefaultTransport.RoundTrip(r)
+	latency := time.Since(started)
+	if err != nil {
+		if errors.Is(r.Context().Err(), context.Canceled) {
+			// Requests canceled by the client tell nothing about the upstream.
+			release(0, false)
+		} else {
+			release(latency, true)
+		}
+		return nil, err
+	}
+
+	failed := res.StatusCode == http.StatusServiceUnavailable || res.StatusCode == http.StatusGatewayTimeout
+	res.Body = &limitedBody{ReadCloser: res.Body, release: func() { release(latency, failed) }}
+	return res, nil
+}
@@ -0,0 +1,54 @@
+package redaction
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+var _ logrus.Hook = new(Hook)
+
+// Hook redacts secrets from the message and fields of log entries.
+type Hook struct {
+	r *Redactor
+}
+
+func NewHook(r *Redactor) *Hook {
+	return &Hook{r: r}
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.r.String(entry.Message)
+
+	// The fields may be shared with other entries, so they are copied instead of being modified in place.
+	data := make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		switch value := v.(type) {
+		case string:
+			if h.r.IsSensitiveHeader(k) {
+				data[k] = RedactedValue
+				continue
+			}
+			data[k] = h.r.String(value)
+		case []string:
+			redacted := make([]string, len(value))
+			for i, s := range value {
+				redacted[i] = h.r.String(s)
+			}
+			data[k] = redacted
+		case http.Header:
+			data[k] = h.r.Header(value)
+		case error:
+			data[k] = h.r.Error(value)
+		default:
+			data[k] = v
+		}
+	}
+	entry.Data = data
+
+	return nil
+}
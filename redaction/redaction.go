@@ -0,0 +1,126 @@
+package redaction
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+)
+
+// RedactedValue replaces secrets.
+const RedactedValue = "[REDACTED]"
+
+// DefaultHeaders are the headers whose values are always redacted.
+var DefaultHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// defaultPatterns match secrets in text. The text matched by the first group, if any, is kept.
+var defaultPatterns = []*regexp.Regexp{
+	// JSON Web Tokens
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	// Credentials of the Bearer and Basic authorization schemes. Short words such as in "bearer token" are kept.
+	regexp.MustCompile(`(?i)(\b(?:bearer|basic)\s+)(?:[A-Za-z.]*[0-9_~+/=-][A-Za-z0-9._~+/=-]*|[A-Za-z]{16,})`),
+	// Credentials in query strings and form bodies
+	regexp.MustCompile(`(?i)(\b(?:access_token|refresh_token|id_token|logout_token|token|client_secret|password|api_key|apikey)=)[^&\s"]+`),
+}
+
+// Redactor removes secrets from headers, text and errors before they are logged, returned or recorded.
+type Redactor struct {
+	headers  map[string]bool
+	patterns []*regexp.Regexp
+}
+
+type Registry interface {
+	Redactor() *Redactor
+}
+
+// NewRedactor creates a Redactor which redacts the default headers and patterns as well as the given headers and
+// regular expressions. If an expression contains a group, the text matched by the first group is kept.
+func NewRedactor(headers, patterns []string) (*Redactor, error) {
+	r := &Redactor{
+		headers:  map[string]bool{},
+		patterns: append([]*regexp.Regexp{}, defaultPatterns...),
+	}
+
+	for _, h := range append(append([]string{}, DefaultHeaders...), headers...) {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, `unable to compile redaction pattern "%s"`, p)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// String returns s with all matches of the redaction patterns replaced.
+func (r *Redactor) String(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, "${1}"+RedactedValue)
+	}
+	return s
+}
+
+// IsSensitiveHeader returns true if the values of the header are redacted entirely.
+func (r *Redactor) IsSensitiveHeader(name string) bool {
+	return r.headers[http.CanonicalHeaderKey(name)]
+}
+
+// Header returns a copy of h in which the values of sensitive headers are replaced and secrets in all other values
+// are redacted.
+func (r *Redactor) Header(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+
+	redacted := make(http.Header, len(h))
+	for k, values := range h {
+		if r.IsSensitiveHeader(k) {
+			redacted[k] = []string{RedactedValue}
+			continue
+		}
+
+		redacted[k] = make([]string, len(values))
+		for i, v := range values {
+			redacted[k][i] = r.String(v)
+		}
+	}
+
+	return redacted
+}
+
+// Error returns err with secrets redacted from its message. The status code and type of herodot errors are kept.
+// If err contains no secrets, it is returned unchanged.
+func (r *Redactor) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if e, ok := errors.Cause(err).(*herodot.DefaultError); ok {
+		redacted := *e
+		redacted.ReasonField = r.String(e.ReasonField)
+		redacted.DebugField = r.String(e.DebugField)
+		redacted.ErrorField = r.String(e.ErrorField)
+		if redacted.ReasonField == e.ReasonField && redacted.DebugField == e.DebugField && redacted.ErrorField == e.ErrorField {
+			return err
+		}
+		return errors.WithStack(&redacted)
+	}
+
+	if message := r.String(err.Error()); message != err.Error() {
+		return errors.New(message)
+	}
+
+	return err
+}
@@ -0,0 +1,130 @@
+package revocation
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EventTypeBackChannelLogout is the event type of OpenID Connect Back-Channel Logout Tokens.
+	EventTypeBackChannelLogout = "http://schemas.openid.net/event/backchannel-logout"
+
+	// EventTypeCAEPSessionRevoked is the CAEP event type signaling that a session was revoked.
+	EventTypeCAEPSessionRevoked = "https://schemas.openid.net/secevent/caep/event-type/session-revoked"
+
+	// EventTypeRISCSessionsRevoked is the RISC event type signaling that all sessions of a subject were revoked.
+	EventTypeRISCSessionsRevoked = "https://schemas.openid.net/secevent/risc/event-type/sessions-revoked"
+
+	// EventTypeRISCAccountDisabled is the RISC event type signaling that an account was disabled.
+	EventTypeRISCAccountDisabled = "https://schemas.openid.net/secevent/risc/event-type/account-disabled"
+)
+
+var securityEventTypes = []string{
+	EventTypeCAEPSessionRevoked,
+	EventTypeRISCSessionsRevoked,
+	EventTypeRISCAccountDisabled,
+}
+
+// EventFromLogoutToken returns the event described by the claims of an OpenID Connect Back-Channel Logout Token.
+// The token's signature must have been verified already.
+func EventFromLogoutToken(claims map[string]interface{}) (*Event, error) {
+	events, _ := claims["events"].(map[string]interface{})
+	if _, ok := events[EventTypeBackChannelLogout]; !ok {
+		return nil, errors.Errorf(`revocation: logout token must contain the event "%s"`, EventTypeBackChannelLogout)
+	}
+
+	if _, ok := claims["nonce"]; ok {
+		return nil, errors.New(`revocation: logout token must not contain the "nonce" claim`)
+	}
+
+	e := &Event{Time: timeClaim(claims["iat"])}
+	e.Subject, _ = claims["sub"].(string)
+	e.SessionID, _ = claims["sid"].(string)
+	if e.Subject == "" && e.SessionID == "" {
+		return nil, errors.New(`revocation: logout token must contain either the "sub" or the "sid" claim`)
+	}
+
+	return e, nil
+}
+
+// EventsFromSecurityEventToken returns the events described by the claims of a Security Event Token (RFC 8417)
+// carrying CAEP or RISC events. Events of other types are ignored. The token's signature must have been
+// verified already.
+func EventsFromSecurityEventToken(claims map[string]interface{}) ([]Event, error) {
+	events, ok := claims["events"].(map[string]interface{})
+	if !ok || len(events) == 0 {
+		return nil, errors.New(`revocation: security event token must contain the "events" claim`)
+	}
+
+	var result []Event
+	for _, eventType := range securityEventTypes {
+		raw, ok := events[eventType]
+		if !ok {
+			continue
+		}
+
+		payload, _ := raw.(map[string]interface{})
+
+		e := Event{Time: timeClaim(claims["iat"])}
+		if ts, ok := payload["event_timestamp"]; ok {
+			e.Time = timeClaim(ts)
+		}
+
+		// Older drafts of the specifications place the subject identifier in the event itself.
+		subject, _ := claims["sub_id"].(map[string]interface{})
+		if s, ok := payload["subject"].(map[string]interface{}); ok {
+			subject = s
+		}
+		parseSubjectIdentifier(subject, &e)
+
+		if e.Subject == "" {
+			e.Subject, _ = claims["sub"].(string)
+		}
+
+		if e.Subject == "" && e.SessionID == "" {
+			return nil, errors.Errorf(`revocation: security event "%s" does not identify a subject or a session`, eventType)
+		}
+
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// parseSubjectIdentifier supports the "opaque", "iss_sub", and "complex" subject identifier formats.
+func parseSubjectIdentifier(id map[string]interface{}, e *Event) {
+	if id == nil {
+		return
+	}
+
+	if user, ok := id["user"].(map[string]interface{}); ok {
+		parseSubjectIdentifier(user, e)
+	}
+
+	if session, ok := id["session"].(map[string]interface{}); ok {
+		e.SessionID, _ = session["id"].(string)
+	}
+
+	switch id["format"] {
+	case "iss_sub":
+		e.Subject, _ = id["sub"].(string)
+	case "opaque":
+		e.Subject, _ = id["id"].(string)
+	}
+}
+
+func timeClaim(v interface{}) time.Time {
+	switch t := v.(type) {
+	case float64:
+		return time.Unix(int64(t), 0)
+	case int64:
+		return time.Unix(t, 0)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return time.Unix(i, 0)
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,81 @@
+package revocation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFromLogoutToken(t *testing.T) {
+	events := map[string]interface{}{EventTypeBackChannelLogout: map[string]interface{}{}}
+
+	e, err := EventFromLogoutToken(map[string]interface{}{"sub": "alice", "sid": "session-1", "iat": float64(1600000000), "events": events})
+	require.NoError(t, err)
+	assert.Equal(t, &Event{Subject: "alice", SessionID: "session-1", Time: time.Unix(1600000000, 0)}, e)
+
+	for k, claims := range []map[string]interface{}{
+		{"sub": "alice"},
+		{"sub": "alice", "events": events, "nonce": "foo"},
+		{"events": events},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			_, err := EventFromLogoutToken(claims)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestEventsFromSecurityEventToken(t *testing.T) {
+	for k, tc := range []struct {
+		claims    map[string]interface{}
+		expect    []Event
+		expectErr bool
+	}{
+		{
+			claims: map[string]interface{}{
+				"iat":    float64(1600000000),
+				"sub_id": map[string]interface{}{"format": "iss_sub", "iss": "https://idp/", "sub": "alice"},
+				"events": map[string]interface{}{EventTypeCAEPSessionRevoked: map[string]interface{}{"event_timestamp": float64(1600000001)}},
+			},
+			expect: []Event{{Subject: "alice", Time: time.Unix(1600000001, 0)}},
+		},
+		{
+			claims: map[string]interface{}{
+				"iat": float64(1600000000),
+				"events": map[string]interface{}{EventTypeRISCSessionsRevoked: map[string]interface{}{
+					"subject": map[string]interface{}{
+						"user":    map[string]interface{}{"format": "opaque", "id": "bob"},
+						"session": map[string]interface{}{"format": "opaque", "id": "session-1"},
+					},
+				}},
+			},
+			expect: []Event{{Subject: "bob", SessionID: "session-1", Time: time.Unix(1600000000, 0)}},
+		},
+		{
+			claims: map[string]interface{}{
+				"events": map[string]interface{}{"https://schemas.openid.net/secevent/caep/event-type/token-claims-change": map[string]interface{}{}},
+			},
+		},
+		{
+			claims:    map[string]interface{}{"events": map[string]interface{}{EventTypeRISCAccountDisabled: map[string]interface{}{}}},
+			expectErr: true,
+		},
+		{
+			claims:    map[string]interface{}{},
+			expectErr: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			events, err := EventsFromSecurityEventToken(tc.claims)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, events)
+		})
+	}
+}
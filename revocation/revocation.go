@@ -0,0 +1,58 @@
+package revocation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Event revokes all sessions of a subject or a single session which were established before Time.
+type Event struct {
+	// Subject is the subject whose sessions are revoked. It may be empty if SessionID is set.
+	Subject string `json:"subject,omitempty"`
+
+	// SessionID is the ID of the session (the "sid" claim) which is revoked. It may be empty if Subject is set.
+	SessionID string `json:"session_id,omitempty"`
+
+	// Time is the point in time at which the sessions were revoked.
+	Time time.Time `json:"time"`
+}
+
+// Store keeps track of revoked subjects and sessions.
+type Store interface {
+	// Revoke records the event and notifies all invalidators.
+	Revoke(ctx context.Context, e Event) error
+
+	// IsRevoked returns true if the subject or session was revoked after issuedAt.
+	IsRevoked(ctx context.Context, subject, sessionID string, issuedAt time.Time) bool
+
+	// Invalidate notifies all invalidators without revoking the subject or session.
+	Invalidate(ctx context.Context, e Event)
+
+	// InvalidateToken notifies all token invalidators that results cached for the token with the given hash
+	// must be purged.
+	InvalidateToken(ctx context.Context, hash string)
+}
+
+// Invalidator may be implemented by components which cache authentication or authorization results
+// in order to purge them once the session they belong to is revoked.
+type Invalidator interface {
+	Invalidate(e Event)
+}
+
+// TokenInvalidator may be implemented by components which cache authentication or authorization results
+// per token in order to purge them on request.
+type TokenInvalidator interface {
+	InvalidateToken(hash string)
+}
+
+// TokenHash returns the hash identifying a token, the hex encoded SHA-256 hash of the token.
+func TokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+type Registry interface {
+	RevocationStore() Store
+}
@@ -0,0 +1,111 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var _ Store = new(StoreMemory)
+
+// StoreMemory keeps revoked subjects and sessions in memory. Entries are forgotten after the configured
+// TTL which should exceed the lifetime of the credentials accepted by ORY Oathkeeper.
+type StoreMemory struct {
+	sync.RWMutex
+
+	ttl          func() time.Duration
+	invalidators func() []interface{}
+
+	subjects map[string]time.Time
+	sessions map[string]time.Time
+}
+
+// NewStoreMemory creates a new StoreMemory. The invalidators function returns all components which
+// may implement Invalidator or TokenInvalidator.
+func NewStoreMemory(ttl func() time.Duration, invalidators func() []interface{}) *StoreMemory {
+	return &StoreMemory{
+		ttl:          ttl,
+		invalidators: invalidators,
+		subjects:     map[string]time.Time{},
+		sessions:     map[string]time.Time{},
+	}
+}
+
+func (s *StoreMemory) Revoke(ctx context.Context, e Event) error {
+	if e.Subject == "" && e.SessionID == "" {
+		return errors.New("revocation: event must reference a subject or a session")
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	s.Lock()
+	s.gc()
+	if e.Subject != "" && e.Time.After(s.subjects[e.Subject]) {
+		s.subjects[e.Subject] = e.Time
+	}
+	if e.SessionID != "" && e.Time.After(s.sessions[e.SessionID]) {
+		s.sessions[e.SessionID] = e.Time
+	}
+	s.Unlock()
+
+	s.Invalidate(ctx, e)
+	return nil
+}
+
+func (s *StoreMemory) Invalidate(_ context.Context, e Event) {
+	if s.invalidators == nil {
+		return
+	}
+
+	for _, c := range s.invalidators() {
+		if i, ok := c.(Invalidator); ok {
+			i.Invalidate(e)
+		}
+	}
+}
+
+func (s *StoreMemory) InvalidateToken(_ context.Context, hash string) {
+	if s.invalidators == nil {
+		return
+	}
+
+	for _, c := range s.invalidators() {
+		if i, ok := c.(TokenInvalidator); ok {
+			i.InvalidateToken(hash)
+		}
+	}
+}
+
+func (s *StoreMemory) IsRevoked(_ context.Context, subject, sessionID string, issuedAt time.Time) bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	if revokedAt, ok := s.subjects[subject]; ok && subject != "" && !issuedAt.After(revokedAt) {
+		return true
+	}
+
+	if revokedAt, ok := s.sessions[sessionID]; ok && sessionID != "" && !issuedAt.After(revokedAt) {
+		return true
+	}
+
+	return false
+}
+
+// gc removes entries older than the TTL, the caller must hold the write lock.
+func (s *StoreMemory) gc() {
+	deadline := time.Now().Add(-s.ttl())
+	for key, revokedAt := range s.subjects {
+		if revokedAt.Before(deadline) {
+			delete(s.subjects, key)
+		}
+	}
+	for key, revokedAt := range s.sessions {
+		if revokedAt.Before(deadline) {
+			delete(s.sessions, key)
+		}
+	}
+}
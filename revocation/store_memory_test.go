@@ -0,0 +1,76 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type invalidatorFunc func(e Event)
+
+func (f invalidatorFunc) Invalidate(e Event) {
+	f(e)
+}
+
+type tokenInvalidatorFunc func(hash string)
+
+func (f tokenInvalidatorFunc) InvalidateToken(hash string) {
+	f(hash)
+}
+
+func TestStoreMemory(t *testing.T) {
+	var invalidated []Event
+	var invalidatedTokens []string
+	s := NewStoreMemory(
+		func() time.Duration { return time.Hour },
+		func() []interface{} {
+			return []interface{}{
+				invalidatorFunc(func(e Event) { invalidated = append(invalidated, e) }),
+				tokenInvalidatorFunc(func(hash string) { invalidatedTokens = append(invalidatedTokens, hash) }),
+				"not an invalidator",
+			}
+		},
+	)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	require.Error(t, s.Revoke(ctx, Event{}))
+
+	require.NoError(t, s.Revoke(ctx, Event{Subject: "alice", Time: now}))
+	require.NoError(t, s.Revoke(ctx, Event{SessionID: "session-1", Time: now}))
+	require.NoError(t, s.Revoke(ctx, Event{Subject: "expired", Time: now.Add(-time.Hour * 2)}))
+	require.Len(t, invalidated, 3)
+
+	for k, tc := range []struct {
+		subject, sid string
+		iat          time.Time
+		expect       bool
+	}{
+		{subject: "alice", iat: now.Add(-time.Minute), expect: true},
+		{subject: "alice", iat: now, expect: true},
+		{subject: "alice", iat: now.Add(time.Minute), expect: false},
+		{subject: "alice", expect: true},
+		{subject: "bob", iat: now.Add(-time.Minute), expect: false},
+		{subject: "bob", sid: "session-1", iat: now.Add(-time.Minute), expect: true},
+		{subject: "bob", sid: "session-2", iat: now.Add(-time.Minute), expect: false},
+		{subject: "", sid: "", expect: false},
+	} {
+		assert.Equal(t, tc.expect, s.IsRevoked(ctx, tc.subject, tc.sid, tc.iat), "case %d", k)
+	}
+
+	// Invalidating purges caches without revoking anything.
+	s.Invalidate(ctx, Event{Subject: "bob"})
+	require.Len(t, invalidated, 4)
+	assert.False(t, s.IsRevoked(ctx, "bob", "", now.Add(-time.Minute)))
+
+	s.InvalidateToken(ctx, TokenHash("token"))
+	assert.Equal(t, []string{"3c469e9d6c5875d37a43f353d4f88e61fcf812c66eee3457465a40b0da4153e0"}, invalidatedTokens)
+
+	// Entries older than the TTL are garbage collected on the next revocation.
+	require.NoError(t, s.Revoke(ctx, Event{Subject: "bob", Time: now}))
+	assert.False(t, s.IsRevoked(ctx, "expired", "", now.Add(-time.Hour*3)))
+}
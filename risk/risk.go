@@ -0,0 +1,131 @@
+// Package risk scores authenticated requests using an external risk engine. Depending on the score, requests are
+// denied, asked for step-up authentication or forwarded with the score added to the session.
+package risk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/helper"
+	"github.com/ory/oathkeeper/x"
+)
+
+// ErrStepUpRequired is returned if the risk of a request is too high to accept the credentials it was authenticated
+// with, but the subject may try again using a stronger authentication method.
+var ErrStepUpRequired = &herodot.DefaultError{
+	ErrorField:  "Step-up authentication is required to access this resource",
+	CodeField:   http.StatusUnauthorized,
+	StatusField: http.StatusText(http.StatusUnauthorized),
+}
+
+// Signals are the properties of a request which are sent to the risk engine.
+type Signals struct {
+	IP        string            `json:"ip"`
+	Subject   string            `json:"subject"`
+	UserAgent string            `json:"user_agent"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	RuleID    string            `json:"rule_id"`
+	Geo       map[string]string `json:"geo,omitempty"`
+}
+
+// Assessment is the response of the risk engine.
+type Assessment struct {
+	// Score is the risk of the request between 0 (no risk) and 1 (certainly malicious).
+	Score float64 `json:"score"`
+
+	// Reasons optionally explain the score.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Scorer assesses the risk of requests.
+type Scorer interface {
+	Score(ctx context.Context, s *Signals) (*Assessment, error)
+}
+
+type Registry interface {
+	RiskScorer() Scorer
+}
+
+var _ Scorer = new(ScorerRemote)
+
+// ScorerRemote sends the signals as JSON to a remote risk engine and expects an Assessment in return.
+type ScorerRemote struct {
+	remote      func() string
+	maxBodySize func() int64
+	client      *http.Client
+}
+
+// NewScorerRemote creates a new ScorerRemote. The remote function returns the URL of the risk engine, the maxBodySize
+// function the maximum size of its responses in bytes.
+func NewScorerRemote(remote func() string, maxBodySize func() int64) *ScorerRemote {
+	return &ScorerRemote{remote: remote, maxBodySize: maxBodySize, client: httpx.NewResilientClientLatencyToleranceSmall(nil)}
+}
+
+func (s *ScorerRemote) Score(ctx context.Context, signals *Signals) (*Assessment, error) {
+	body, err := json.Marshal(signals)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.remote(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("expected status code %d but got %d", http.StatusOK, res.StatusCode)
+	}
+
+	var a Assessment
+	if err := x.DecodeJSONResponse(res, s.maxBodySize(), &a); err != nil {
+		return nil, err
+	}
+
+	if a.Score < 0 || a.Score > 1 {
+		return nil, errors.Errorf("risk score %v is not between 0 and 1", a.Score)
+	}
+
+	return &a, nil
+}
+
+// Policy decides what happens to a request depending on its risk score.
+type Policy struct {
+	// DenyAbove denies requests with a higher score.
+	DenyAbove float64
+
+	// StepUpAbove requires step-up authentication for requests with a higher score.
+	StepUpAbove float64
+}
+
+// Enforce returns an error if the assessment is not acceptable according to the policy.
+func (p Policy) Enforce(a *Assessment) error {
+	if a.Score > p.DenyAbove {
+		return errors.WithStack(helper.ErrForbidden.
+			WithReason("The risk of this request is too high.").
+			WithDetail("risk_score", strconv.FormatFloat(a.Score, 'f', -1, 64)))
+	}
+
+	if a.Score > p.StepUpAbove {
+		return errors.WithStack(ErrStepUpRequired.
+			WithReason("The risk of this request requires a stronger authentication method.").
+			WithDetail("risk_score", strconv.FormatFloat(a.Score, 'f', -1, 64)))
+	}
+
+	return nil
+}
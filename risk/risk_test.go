@@ -0,0 +1,80 @@
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+)
+
+func TestScorerRemote(t *testing.T) {
+	var response string
+	var status int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s Signals
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&s))
+		assert.Equal(t, "alice", s.Subject)
+		assert.Equal(t, "DE", s.Geo["country"])
+
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	s := NewScorerRemote(func() string { return server.URL }, func() int64 { return 1 << 20 })
+	signals := &Signals{IP: "127.0.0.1", Subject: "alice", Geo: map[string]string{"country": "DE"}}
+
+	for k, tc := range []struct {
+		d         string
+		status    int
+		response  string
+		expectErr bool
+		expect    *Assessment
+	}{
+		{d: "valid score", status: http.StatusOK, response: `{"score":0.4,"reasons":["new device"]}`, expect: &Assessment{Score: 0.4, Reasons: []string{"new device"}}},
+		{d: "unexpected status code", status: http.StatusInternalServerError, response: `{"score":0.4}`, expectErr: true},
+		{d: "score out of range", status: http.StatusOK, response: `{"score":4}`, expectErr: true},
+		{d: "invalid json", status: http.StatusOK, response: `{`, expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			status, response = tc.status, tc.response
+			a, err := s.Score(context.Background(), signals)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, a)
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := Policy{DenyAbove: 0.8, StepUpAbove: 0.5}
+	for k, tc := range []struct {
+		score      float64
+		expectCode int
+	}{
+		{score: 0.2},
+		{score: 0.5},
+		{score: 0.6, expectCode: http.StatusUnauthorized},
+		{score: 0.9, expectCode: http.StatusForbidden},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			err := p.Enforce(&Assessment{Score: tc.score})
+			if tc.expectCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tc.expectCode, herodot.ToDefaultError(err, "").StatusCode())
+		})
+	}
+}
@@ -3,12 +3,15 @@ package rule
 import (
 	"bytes"
 	"hash/crc64"
+	"strings"
 
 	"github.com/gobwas/glob"
 )
 
 type globMatchingEngine struct {
 	compiled glob.Glob
+	pattern  string
+	literal  bool
 	checksum uint64
 	table    *crc64.Table
 }
@@ -23,6 +26,9 @@ func (ge *globMatchingEngine) IsMatching(pattern, matchAgainst string) (bool, er
 	if err := ge.compile(pattern); err != nil {
 		return false, err
 	}
+	if ge.literal {
+		return pattern == matchAgainst, nil
+	}
 	return ge.compiled.Match(matchAgainst), nil
 }
 
@@ -37,10 +43,13 @@ func (ge *globMatchingEngine) FindStringSubmatch(pattern, matchAgainst string) (
 }
 
 func (ge *globMatchingEngine) compile(pattern string) error {
+	if ge.compiled != nil && ge.pattern == pattern {
+		return nil
+	}
 	if ge.table == nil {
 		ge.table = crc64.MakeTable(polynomial)
 	}
-	if checksum := crc64.Checksum([]byte(pattern), ge.table); checksum != ge.checksum {
+	if checksum := crc64.Checksum([]byte(pattern), ge.table); checksum != ge.checksum || ge.compiled == nil {
 		compiled, err := compileGlob(pattern, '<', '>')
 		if err != nil {
 			return err
@@ -48,6 +57,9 @@ func (ge *globMatchingEngine) compile(pattern string) error {
 		ge.checksum = checksum
 		ge.compiled = compiled
 	}
+	ge.pattern = pattern
+	// Patterns without globs only match themselves.
+	ge.literal = !strings.ContainsRune(pattern, '<')
 	return nil
 }
 
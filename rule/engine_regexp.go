@@ -3,6 +3,7 @@ package rule
 import (
 	"errors"
 	"hash/crc64"
+	"strings"
 
 	"github.com/dlclark/regexp2"
 
@@ -11,15 +12,20 @@ import (
 
 type regexpMatchingEngine struct {
 	compiled *regexp2.Regexp
+	pattern  string
+	literal  bool
 	checksum uint64
 	table    *crc64.Table
 }
 
 func (re *regexpMatchingEngine) compile(pattern string) error {
+	if re.compiled != nil && re.pattern == pattern {
+		return nil
+	}
 	if re.table == nil {
 		re.table = crc64.MakeTable(polynomial)
 	}
-	if checksum := crc64.Checksum([]byte(pattern), re.table); checksum != re.checksum {
+	if checksum := crc64.Checksum([]byte(pattern), re.table); checksum != re.checksum || re.compiled == nil {
 		compiled, err := compiler.CompileRegex(pattern, '<', '>')
 		if err != nil {
 			return err
@@ -27,6 +33,9 @@ func (re *regexpMatchingEngine) compile(pattern string) error {
 		re.compiled = compiled
 		re.checksum = checksum
 	}
+	re.pattern = pattern
+	// Patterns without regular expressions only match themselves.
+	re.literal = !strings.ContainsRune(pattern, '<')
 	return nil
 }
 
@@ -40,6 +49,9 @@ func (re *regexpMatchingEngine) IsMatching(pattern, matchAgainst string) (bool,
 	if err := re.compile(pattern); err != nil {
 		return false, err
 	}
+	if re.literal {
+		return pattern == matchAgainst, nil
+	}
 	return re.compiled.MatchString(matchAgainst)
 }
 
@@ -1,9 +1,11 @@
 package rule
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFindStringSubmatch(t *testing.T) {
@@ -67,3 +69,25 @@ func TestFindStringSubmatch(t *testing.T) {
 		})
 	}
 }
+
+func TestRegexpIsMatchingLiteral(t *testing.T) {
+	for k, tc := range []struct {
+		pattern      string
+		matchAgainst string
+		expect       bool
+	}{
+		{pattern: "https://localhost/users", matchAgainst: "https://localhost/users", expect: true},
+		{pattern: "https://localhost/users", matchAgainst: "https://localhost/users/1", expect: false},
+		{pattern: "https://localhost/users.json", matchAgainst: "https://localhost/usersxjson", expect: false},
+		{pattern: "https://localhost/<.*>", matchAgainst: "https://localhost/users", expect: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			engine := new(regexpMatchingEngine)
+			for i := 0; i < 2; i++ {
+				matched, err := engine.IsMatching(tc.pattern, tc.matchAgainst)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expect, matched)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package rule
+
+import (
+	"sort"
+	"time"
+)
+
+// ExpiredRule is an access rule whose expiry date has passed.
+type ExpiredRule struct {
+	// ID is the ID of the access rule.
+	ID string `json:"id"`
+
+	// Owner is the owner of the access rule, if any.
+	Owner string `json:"owner,omitempty"`
+
+	// ExpiresAt is when the access rule expired.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ExpiredRules returns the rules which are expired at now, the longest expired first.
+func ExpiredRules(rules []Rule, now time.Time) []ExpiredRule {
+	var expired []ExpiredRule
+	for k := range rules {
+		if rl := &rules[k]; rl.Expired(now) {
+			expired = append(expired, ExpiredRule{ID: rl.ID, Owner: rl.Owner, ExpiresAt: rl.ExpiresAt.UTC()})
+		}
+	}
+
+	sort.SliceStable(expired, func(i, j int) bool {
+		return expired[i].ExpiresAt.Before(expired[j].ExpiresAt)
+	})
+	return expired
+}
@@ -24,4 +24,10 @@ import "context"
 
 type Fetcher interface {
 	Watch(ctx context.Context) error
+
+	// Status returns the state of all configured access rule repositories.
+	Status() []RepositoryStatus
+
+	// Conflicts returns the IDs shared by more than one of the loaded access rules.
+	Conflicts() []Conflict
 }
@@ -24,7 +24,10 @@ import (
 	"github.com/ory/x/httpx"
 	"github.com/ory/x/viperx"
 
+	"github.com/ory/oathkeeper/credentials"
 	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
+	"github.com/ory/oathkeeper/persistence/sqlite"
 	"github.com/ory/oathkeeper/x"
 
 	"github.com/ghodss/yaml"
@@ -43,13 +46,22 @@ const (
 	eventRepositoryConfigChanged eventType = iota
 	eventFileChanged
 	eventMatchingStrategyChanged
+	eventNotificationsChanged
+	eventExpiredRulesChanged
 )
 
 var _ Fetcher = new(FetcherDefault)
 
+// inlineConfigSource is the source of the access rules defined in `access_rules.inline`.
+var inlineConfigSource = url.URL{Scheme: "config", Host: configuration.ViperKeyAccessRuleInline}
+
 type fetcherRegistry interface {
 	x.RegistryLogger
 	RuleRepository() Repository
+	RuleStager() Stager
+	ManagedRegistry
+	events.Registry
+	credentials.FetcherRegistry
 }
 
 type FetcherDefault struct {
@@ -57,10 +69,13 @@ type FetcherDefault struct {
 	r  fetcherRegistry
 	hc *http.Client
 
-	cache map[string][]Rule
+	cache  map[string][]Rule
+	status map[string]*RepositoryStatus
 
 	directoriesBeingWatched []string
 	filesBeingWatched       []string
+	stopKVWatchers          context.CancelFunc
+	stopNotifications       context.CancelFunc
 
 	lock sync.Mutex
 	wg   sync.WaitGroup
@@ -71,10 +86,11 @@ func NewFetcherDefault(
 	r fetcherRegistry,
 ) *FetcherDefault {
 	return &FetcherDefault{
-		r:     r,
-		c:     c,
-		hc:    httpx.NewResilientClientLatencyToleranceHigh(nil),
-		cache: map[string][]Rule{},
+		r:      r,
+		c:      c,
+		hc:     httpx.NewResilientClientLatencyToleranceHigh(nil),
+		cache:  map[string][]Rule{},
+		status: map[string]*RepositoryStatus{},
 	}
 }
 
@@ -82,12 +98,22 @@ func (f *FetcherDefault) configUpdate(ctx context.Context, watcher *fsnotify.Wat
 	var directoriesToWatch []string
 	var filesBeingWatched []string
 	for _, fileToWatch := range replace {
-		if fileToWatch.Scheme == "file" {
-			p := filepath.Clean(strings.Replace(fileToWatch.String(), "file://", "", 1))
-			filesBeingWatched = append(filesBeingWatched, p)
-			directoryToWatch, _ := filepath.Split(p)
-			directoriesToWatch = append(directoriesToWatch, directoryToWatch)
+		var p string
+		switch fileToWatch.Scheme {
+		case "file":
+			p = filepath.Clean(strings.Replace(fileToWatch.String(), "file://", "", 1))
+		case sqlite.Scheme:
+			p = sqlite.Path(&fileToWatch)
+		default:
+			continue
+		}
+
+		filesBeingWatched = append(filesBeingWatched, p)
+		if fileToWatch.Scheme == "file" && f.signaturesRequired() {
+			filesBeingWatched = append(filesBeingWatched, p+SignatureSuffix)
 		}
+		directoryToWatch, _ := filepath.Split(p)
+		directoriesToWatch = append(directoriesToWatch, directoryToWatch)
 	}
 	directoriesToWatch = stringslice.Unique(directoriesToWatch)
 
@@ -125,11 +151,29 @@ func (f *FetcherDefault) configUpdate(ctx context.Context, watcher *fsnotify.Wat
 
 	// And we need to reset the rule cache
 	f.cache = make(map[string][]Rule)
+
+	// Key value stores, Kubernetes and the managed access rules are watched using their native watch APIs, so we
+	// restart those watchers as well
+	if f.stopKVWatchers != nil {
+		f.stopKVWatchers()
+	}
+	kvCtx, cancel := context.WithCancel(ctx)
+	f.stopKVWatchers = cancel
+	for _, source := range replace {
+		if hasWatchAPI(source.Scheme) {
+			f.wg.Add(1)
+			go func(source url.URL) {
+				defer f.wg.Done()
+				f.watchKV(kvCtx, source, events)
+			}(source)
+		}
+	}
 	f.lock.Unlock()
 
 	// If there are no more sources to watch we reset the rule repository as a whole
 	if len(replace) == 0 {
 		f.r.Logger().WithField("repos", viper.AllSettings()).Warn("No access rule repositories have been defined in the updated config.")
+		f.c.ResetPipelineConfigCache()
 		if err := f.r.RuleRepository().Set(ctx, []Rule{}); err != nil {
 			return err
 		}
@@ -143,7 +187,7 @@ func (f *FetcherDefault) configUpdate(ctx context.Context, watcher *fsnotify.Wat
 	return nil
 }
 
-func (f *FetcherDefault) sourceUpdate(e event) ([]Rule, error) {
+func (f *FetcherDefault) sourceUpdate(ctx context.Context, e event) ([]Rule, error) {
 	if e.path.Scheme == "file" {
 		u, err := url.Parse("file://" + filepath.Clean(strings.TrimPrefix(e.path.String(), "file://")))
 		if err != nil {
@@ -157,16 +201,33 @@ func (f *FetcherDefault) sourceUpdate(e event) ([]Rule, error) {
 	if err != nil {
 		return nil, err
 	}
+	rules = namespaceRules(rules, f.c.AccessRuleNamespace(e.path))
 
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	f.cache[e.path.String()] = rules
+	if err := f.checkDuplicates(e.path, rules); err != nil {
+		return nil, err
+	}
 
 	var total []Rule
-	for _, items := range f.cache {
+	for source, items := range f.cache {
+		if source == e.path.String() {
+			continue
+		}
 		total = append(total, items...)
 	}
+	total = append(total, rules...)
+
+	if f.c.AccessRuleStagingIsEnabled() {
+		// The cache is only updated once staging succeeded. This ensures that a failed reload is never
+		// partially applied when another repository changes later on.
+		if err := f.r.RuleStager().Stage(ctx, total); err != nil {
+			return nil, err
+		}
+	}
+
+	f.cache[e.path.String()] = rules
 
 	return total, nil
 }
@@ -181,6 +242,15 @@ func (f *FetcherDefault) Watch(ctx context.Context) error {
 	events := make(chan event)
 	err = f.watch(ctx, watcher, events)
 
+	f.lock.Lock()
+	if f.stopKVWatchers != nil {
+		f.stopKVWatchers()
+	}
+	if f.stopNotifications != nil {
+		f.stopNotifications()
+	}
+	f.lock.Unlock()
+
 	// Close the channel only when all child goroutines exit
 	f.wg.Wait()
 	close(events)
@@ -217,6 +287,33 @@ func (f *FetcherDefault) watch(ctx context.Context, watcher *fsnotify.Watcher, e
 	})
 	f.enqueueEvent(events, event{et: eventMatchingStrategyChanged, source: "entrypoint"})
 
+	var expired interface{}
+	viperx.AddWatcher(func(e fsnotify.Event) error {
+		if reflect.DeepEqual(expired, viper.Get(configuration.ViperKeyAccessRuleExpiredRules)) {
+			f.r.Logger().
+				Debug("Not updating the handling of expired access rules because configuration value has not changed.")
+			return nil
+		}
+
+		expired = viper.Get(configuration.ViperKeyAccessRuleExpiredRules)
+		f.enqueueEvent(events, event{et: eventExpiredRulesChanged, source: "viper_watcher"})
+		return nil
+	})
+	f.enqueueEvent(events, event{et: eventExpiredRulesChanged, source: "entrypoint"})
+
+	var notifications map[string]interface{}
+	viperx.AddWatcher(func(e fsnotify.Event) error {
+		if reflect.DeepEqual(notifications, viper.Get(configuration.ViperKeyAccessRuleNotifications)) {
+			f.r.Logger().
+				Debug("Not reloading access rule notifications because configuration value has not changed.")
+			return nil
+		}
+
+		f.enqueueEvent(events, event{et: eventNotificationsChanged, source: "viper_watcher"})
+		return nil
+	})
+	f.enqueueEvent(events, event{et: eventNotificationsChanged, source: "entrypoint"})
+
 	for {
 		select {
 		case e, ok := <-watcher.Events:
@@ -265,7 +362,7 @@ func (f *FetcherDefault) watch(ctx context.Context, watcher *fsnotify.Watcher, e
 					WithField("event", "config_change").
 					WithField("source", e.source).
 					Debugf("Viper detected a configuration change, reloading config.")
-				if err := f.configUpdate(ctx, watcher, f.c.AccessRuleRepositories(), events); err != nil {
+				if err := f.configUpdate(ctx, watcher, f.repositories(), events); err != nil {
 					return err
 				}
 			case eventMatchingStrategyChanged:
@@ -276,6 +373,20 @@ func (f *FetcherDefault) watch(ctx context.Context, watcher *fsnotify.Watcher, e
 				if err := f.r.RuleRepository().SetMatchingStrategy(ctx, f.c.AccessRuleMatchingStrategy()); err != nil {
 					return errors.Wrapf(err, "unable to update matching strategy")
 				}
+			case eventExpiredRulesChanged:
+				f.r.Logger().
+					WithField("event", "expired_rules_config_change").
+					WithField("source", e.source).
+					Debugf("Viper detected a configuration change, updating the handling of expired access rules")
+				if err := f.r.RuleRepository().SetExpiredRules(ctx, f.c.AccessRuleExpiredRules()); err != nil {
+					return errors.Wrapf(err, "unable to update the handling of expired access rules")
+				}
+			case eventNotificationsChanged:
+				f.r.Logger().
+					WithField("event", "notifications_config_change").
+					WithField("source", e.source).
+					Debugf("Viper detected a configuration change, updating access rule notifications.")
+				f.notificationsUpdate(ctx, events)
 			case eventFileChanged:
 				f.r.Logger().
 					WithField("event", "repository_change").
@@ -283,22 +394,61 @@ func (f *FetcherDefault) watch(ctx context.Context, watcher *fsnotify.Watcher, e
 					WithField("file", e.path.String()).
 					Debugf("One or more access rule repositories changed, reloading access rules.")
 
-				rules, err := f.sourceUpdate(e)
+				rules, err := f.sourceUpdate(ctx, e)
+				f.recordStatus(e.path, err)
 				if err != nil {
 					f.r.Logger().WithError(err).
 						WithField("file", e.path.String()).
 						Error("Unable to update access rules from given location, changes will be ignored. Check the configuration or restart the service if the issue persists.")
+					f.publishReload(e.path, err)
 					continue
 				}
 
+				// Configurations of the previous rules are cached while validating, drop them before the new rules
+				// are validated and cached.
+				f.c.ResetPipelineConfigCache()
 				if err := f.r.RuleRepository().Set(ctx, rules); err != nil {
 					return errors.Wrapf(err, "unable to reset access rule repository")
 				}
+				f.publishReload(e.path, nil)
 			}
 		}
 	}
 }
 
+// repositories returns all configured access rule repositories including the rules defined in the configuration itself.
+func (f *FetcherDefault) repositories() []url.URL {
+	repositories := f.c.AccessRuleRepositories()
+	if f.c.AccessRuleInline() != nil {
+		repositories = append(repositories, inlineConfigSource)
+	}
+	return repositories
+}
+
+func (f *FetcherDefault) notificationsUpdate(ctx context.Context, events chan event) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.stopNotifications != nil {
+		f.stopNotifications()
+		f.stopNotifications = nil
+	}
+
+	u := f.c.AccessRuleNATSURL()
+	if u == nil {
+		return
+	}
+
+	nctx, cancel := context.WithCancel(ctx)
+	f.stopNotifications = cancel
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.watchNATS(nctx, u, f.c.AccessRuleNATSSubject(), events)
+	}()
+}
+
 func (f *FetcherDefault) enqueueEvent(events chan event, evt event) {
 	f.wg.Add(1)
 	go func() {
@@ -313,6 +463,12 @@ func (f *FetcherDefault) fetch(source url.URL) ([]Rule, error) {
 		WithField("location", source.String()).
 		Debugf("Fetching access rules from given location because something changed.")
 
+	if f.signaturesRequired() {
+		if err := checkSignatureScheme(source); err != nil {
+			return nil, err
+		}
+	}
+
 	switch source.Scheme {
 	case "http":
 		fallthrough
@@ -324,6 +480,16 @@ func (f *FetcherDefault) fetch(source url.URL) ([]Rule, error) {
 			return f.fetchFile(p)
 		}
 		return f.fetchDir(p)
+	case sqlite.Scheme:
+		return f.fetchSQLite(source)
+	case schemeConsul, schemeEtcd:
+		return f.fetchKV(context.Background(), source)
+	case schemeKubernetes:
+		return f.fetchKubernetes(context.Background(), source)
+	case schemeManaged:
+		return f.r.RuleManagedRepository().List(), nil
+	case inlineConfigSource.Scheme:
+		return f.decode(bytes.NewReader(f.c.AccessRuleInline()))
 	case "inline":
 		src, err := base64.StdEncoding.DecodeString(strings.Replace(source.String(), "inline://", "", 1))
 		if err != nil {
@@ -344,7 +510,42 @@ func (f *FetcherDefault) fetchRemote(source string) ([]Rule, error) {
 		return nil, errors.Errorf("rule: expected http response status code 200 but got %d when fetching: %s", res.StatusCode, source)
 	}
 
-	return f.decode(res.Body)
+	if !f.signaturesRequired() {
+		return f.decode(res.Body)
+	}
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source)
+	}
+	signature, err := f.fetchRemoteSignature(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.verifySignature(source, content, signature); err != nil {
+		return nil, err
+	}
+	return f.decode(bytes.NewReader(content))
+}
+
+func (f *FetcherDefault) fetchSQLite(source url.URL) ([]Rule, error) {
+	p, err := sqlite.Open(&source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source.String())
+	}
+	defer p.Close()
+
+	stored, err := p.AccessRules(context.Background())
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source.String())
+	}
+
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return f.decode(bytes.NewReader(b))
 }
 
 func (f *FetcherDefault) fetchDir(source string) ([]Rule, error) {
@@ -353,7 +554,7 @@ func (f *FetcherDefault) fetchDir(source string) ([]Rule, error) {
 		if err != nil {
 			return errors.Wrapf(err, "rule: %s", source)
 		}
-		if info.IsDir() {
+		if info.IsDir() || strings.HasSuffix(path, SignatureSuffix) {
 			return nil
 		}
 
@@ -378,7 +579,22 @@ func (f *FetcherDefault) fetchFile(source string) ([]Rule, error) {
 	}
 	defer fp.Close()
 
-	return f.decode(fp)
+	if !f.signaturesRequired() {
+		return f.decode(fp)
+	}
+
+	content, err := ioutil.ReadAll(fp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source)
+	}
+	signature, err := f.fetchFileSignature(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.verifySignature(source, content, signature); err != nil {
+		return nil, err
+	}
+	return f.decode(bytes.NewReader(content))
 }
 
 func (f *FetcherDefault) decode(r io.Reader) ([]Rule, error) {
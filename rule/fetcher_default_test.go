@@ -23,6 +23,7 @@ import (
 	"github.com/ory/x/viperx"
 
 	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/events"
 	"github.com/ory/oathkeeper/internal"
 )
 
@@ -160,6 +161,7 @@ func TestFetcherWatchConfig(t *testing.T) {
 		expectIDs        []string
 		expectNone       bool
 		expectedStrategy configuration.MatchingStrategy
+		expectConflicts  []string
 	}{
 		{config: ""},
 		{
@@ -201,6 +203,96 @@ access_rules:
 `,
 			expectedStrategy: configuration.Regexp,
 		},
+		{
+			config: `
+access_rules:
+  repositories:
+    - file://../test/stub/rules.yaml
+  inline:
+    - id: inline-rule-1
+      match:
+        url: http://localhost/inline
+        methods: [GET]
+      authenticators:
+        - handler: noop
+      authorizer:
+        handler: allow
+      mutators:
+        - handler: noop
+`,
+			expectIDs: []string{"test-rule-1-yaml", "inline-rule-1"},
+		},
+		{
+			config: `
+access_rules:
+  inline:
+    - id: inline-rule-2
+`,
+			expectIDs: []string{"inline-rule-2"},
+		},
+		{
+			config: `
+access_rules:
+  repositories:
+    - file://../test/stub/rules.yaml
+  inline:
+    - id: test-rule-1-yaml
+      match:
+        url: http://localhost/inline
+        methods: [GET]
+      authenticators:
+        - handler: noop
+      authorizer:
+        handler: allow
+      mutators:
+        - handler: noop
+`,
+			expectIDs:       []string{"test-rule-1-yaml", "test-rule-1-yaml"},
+			expectConflicts: []string{"test-rule-1-yaml"},
+		},
+		{
+			config: `
+access_rules:
+  repositories:
+    - file://../test/stub/rules.yaml
+  namespaces:
+    - repository: file://../test/stub/rules.yaml
+      namespace: team-a
+  inline:
+    - id: test-rule-1-yaml
+      match:
+        url: http://localhost/inline
+        methods: [GET]
+      authenticators:
+        - handler: noop
+      authorizer:
+        handler: allow
+      mutators:
+        - handler: noop
+`,
+			expectIDs: []string{"team-a:test-rule-1-yaml", "test-rule-1-yaml"},
+		},
+		{
+			config: `
+access_rules:
+  repositories:
+    - file://../test/stub/rules.yaml
+  duplicate_ids: error
+  inline:
+    - id: test-rule-1-yaml
+      match:
+        url: http://localhost/inline
+        methods: [GET]
+      authenticators:
+        - handler: noop
+      authorizer:
+        handler: allow
+      mutators:
+        - handler: noop
+`,
+			// Whichever repository is loaded last is rejected.
+			expectIDs: []string{"test-rule-1-yaml"},
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
 			require.NoError(t, ioutil.WriteFile(configFile, []byte(tc.config), 0666))
@@ -222,6 +314,12 @@ access_rules:
 			for _, id := range tc.expectIDs {
 				assert.True(t, stringslice.Has(ids, id), "\nexpected: %v\nactual: %v", tc.expectIDs, ids)
 			}
+
+			var conflicts []string
+			for _, c := range r.RuleFetcher().Conflicts() {
+				conflicts = append(conflicts, c.ID)
+			}
+			assert.Equal(t, tc.expectConflicts, conflicts)
 		})
 	}
 }
@@ -246,6 +344,9 @@ access_rules:
 	viperx.InitializeConfig("oathkeeper-"+id, os.TempDir(), nil)
 	viperx.WatchConfig(nil, nil)
 
+	reloads := r.EventBus().Subscribe(100, events.Filter{Type: events.TypeRuleReload})
+	defer reloads.Close()
+
 	go func() {
 		require.NoError(t, r.RuleFetcher().Watch(context.TODO()))
 	}()
@@ -275,6 +376,15 @@ access_rules:
 			for _, id := range tc.expectIDs {
 				assert.True(t, stringslice.Has(ids, id), "\nexpected: %v\nactual: %v", tc.expectIDs, ids)
 			}
+
+			require.NotEmpty(t, reloads.Events())
+			var reload events.Event
+			for len(reloads.Events()) > 0 {
+				reload = <-reloads.Events()
+			}
+			assert.Equal(t, "file://"+repository, reload.RuleReload.Repository)
+			assert.Equal(t, len(tc.expectIDs), reload.RuleReload.Rules)
+			assert.Empty(t, reload.RuleReload.Error)
 		})
 	}
 }
@@ -0,0 +1,102 @@
+package rule
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+)
+
+// Conflict is an ID shared by more than one access rule. Only one of these access rules can be retrieved by its
+// ID and they may shadow each other when matching requests.
+type Conflict struct {
+	// ID is the ID shared by the access rules.
+	ID string `json:"id"`
+
+	// Repositories are the repositories defining the access rules with credentials removed. A repository is listed
+	// once per access rule, so it is listed more than once if it defines the ID itself more than once.
+	Repositories []string `json:"repositories"`
+}
+
+// Conflicts returns the IDs shared by more than one of the loaded access rules.
+func (f *FetcherDefault) Conflicts() []Conflict {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return ruleConflicts(f.cache)
+}
+
+// checkDuplicates logs or, depending on the configured severity, rejects IDs of the access rules of the source which
+// are shared with other access rules. The caller must hold the lock.
+func (f *FetcherDefault) checkDuplicates(source url.URL, rules []Rule) error {
+	candidate := make(map[string][]Rule, len(f.cache)+1)
+	for key, items := range f.cache {
+		candidate[key] = items
+	}
+	candidate[source.String()] = rules
+
+	ids := make(map[string]bool, len(rules))
+	for _, rl := range rules {
+		ids[rl.ID] = true
+	}
+
+	var duplicates []string
+	for _, c := range ruleConflicts(candidate) {
+		if !ids[c.ID] {
+			// The conflict was not introduced by this repository.
+			continue
+		}
+
+		duplicates = append(duplicates, c.ID)
+		f.r.Logger().
+			WithField("rule_id", c.ID).
+			WithField("repositories", c.Repositories).
+			Warn("The access rule ID is used by more than one access rule, only one of them can be retrieved by its ID and they may shadow each other. Rename the access rules or namespace the repositories using access_rules.namespaces.")
+	}
+
+	if len(duplicates) > 0 && f.c.AccessRuleDuplicateIDs() == configuration.DuplicateRuleIDsError {
+		return errors.Errorf("rule: the access rule IDs %s are used by more than one access rule", strings.Join(duplicates, ", "))
+	}
+	return nil
+}
+
+// ruleConflicts returns the IDs shared by more than one access rule of the sources, sorted by ID.
+func ruleConflicts(sources map[string][]Rule) []Conflict {
+	repositories := map[string][]string{}
+	for source, rules := range sources {
+		redacted := source
+		if u, err := url.Parse(source); err == nil {
+			redacted = redactRepository(*u)
+		}
+		for _, rl := range rules {
+			repositories[rl.ID] = append(repositories[rl.ID], redacted)
+		}
+	}
+
+	var conflicts []Conflict
+	for id, names := range repositories {
+		if len(names) > 1 {
+			sort.Strings(names)
+			conflicts = append(conflicts, Conflict{ID: id, Repositories: names})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].ID < conflicts[j].ID })
+	return conflicts
+}
+
+// namespaceRules returns a copy of the access rules whose IDs are prefixed with the namespace.
+func namespaceRules(rules []Rule, namespace string) []Rule {
+	if namespace == "" {
+		return rules
+	}
+
+	namespaced := make([]Rule, len(rules))
+	for k, rl := range rules {
+		rl.ID = namespace + ":" + rl.ID
+		namespaced[k] = rl
+	}
+	return namespaced
+}
@@ -0,0 +1,618 @@
+package rule
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/httpx"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+)
+
+const (
+	schemeKubernetes = "kubernetes"
+
+	// kubernetesAnnotationPrefix prefixes all annotations ORY Oathkeeper reads from Ingress and HTTPRoute objects.
+	kubernetesAnnotationPrefix = "oathkeeper.ory.sh/"
+
+	kubernetesDefaultAPIServer = "https://kubernetes.default.svc"
+	kubernetesDefaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	kubernetesDefaultCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// kubernetesResource is a kind of object access rules are synthesized from.
+type kubernetesResource struct {
+	kind  string
+	group string
+	name  string
+
+	// optional resources may not be installed in the cluster, for example the Gateway API CRDs.
+	optional bool
+}
+
+var kubernetesResources = []kubernetesResource{
+	{kind: "ingress", group: "networking.k8s.io/v1", name: "ingresses"},
+	{kind: "httproute", group: "gateway.networking.k8s.io/v1", name: "httproutes", optional: true},
+}
+
+type kubernetesObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Annotations     map[string]string `json:"annotations"`
+}
+
+type kubernetesList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []json.RawMessage `json:"items"`
+}
+
+type kubernetesWatchEvent struct {
+	Type   string `json:"type"`
+	Object struct {
+		Metadata kubernetesObjectMeta `json:"metadata"`
+		Message  string               `json:"message"`
+	} `json:"object"`
+}
+
+type kubernetesIngressBackend struct {
+	Service *struct {
+		Name string `json:"name"`
+		Port struct {
+			Number int    `json:"number"`
+			Name   string `json:"name"`
+		} `json:"port"`
+	} `json:"service"`
+}
+
+type kubernetesIngress struct {
+	Metadata kubernetesObjectMeta `json:"metadata"`
+	Spec     struct {
+		DefaultBackend *kubernetesIngressBackend `json:"defaultBackend"`
+		Rules          []struct {
+			Host string `json:"host"`
+			HTTP *struct {
+				Paths []struct {
+					Path     string                   `json:"path"`
+					PathType string                   `json:"pathType"`
+					Backend  kubernetesIngressBackend `json:"backend"`
+				} `json:"paths"`
+			} `json:"http"`
+		} `json:"rules"`
+	} `json:"spec"`
+}
+
+type kubernetesHTTPRoute struct {
+	Metadata kubernetesObjectMeta `json:"metadata"`
+	Spec     struct {
+		Hostnames []string `json:"hostnames"`
+		Rules     []struct {
+			Matches []struct {
+				Path *struct {
+					Type  string `json:"type"`
+					Value string `json:"value"`
+				} `json:"path"`
+				Method string `json:"method"`
+			} `json:"matches"`
+			BackendRefs []struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+				Port      int    `json:"port"`
+			} `json:"backendRefs"`
+		} `json:"rules"`
+	} `json:"spec"`
+}
+
+// kubernetesEndpoint returns the base URL of the Kubernetes API server referenced by source. Without a host, the
+// in-cluster API server is used. HTTPS is used per default which can be changed by setting the `tls` query parameter
+// to `false`.
+func kubernetesEndpoint(source url.URL) string {
+	if source.Host == "" {
+		return kubernetesDefaultAPIServer
+	}
+
+	scheme := "https"
+	if source.Query().Get("tls") == "false" {
+		scheme = "http"
+	}
+	return scheme + "://" + source.Host
+}
+
+// kubernetesFile returns the value of the query parameter or the default path. The boolean is true if the file was
+// configured explicitly and must therefore exist.
+func kubernetesFile(source url.URL, parameter, fallback string) (string, bool) {
+	if p := source.Query().Get(parameter); p != "" {
+		return p, true
+	}
+	return fallback, false
+}
+
+// kubernetesClient returns a HTTP client trusting the certificate authorities of the cluster. Watches are kept open
+// for several minutes, so they can not use a client with a short timeout.
+func (f *FetcherDefault) kubernetesClient(source url.URL, watch bool) (*http.Client, error) {
+	var transport *http.Transport
+	if strings.HasPrefix(kubernetesEndpoint(source), "https://") {
+		caFile, explicit := kubernetesFile(source, "ca_file", kubernetesDefaultCAFile)
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil && (explicit || !os.IsNotExist(err)) {
+			return nil, errors.WithStack(err)
+		} else if err == nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.Errorf("unable to load certificate authorities from %s", caFile)
+			}
+			transport = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: &tls.Config{RootCAs: pool}}
+		}
+	}
+
+	switch {
+	case watch && transport != nil:
+		return &http.Client{Transport: transport}, nil
+	case watch:
+		return &http.Client{}, nil
+	case transport != nil:
+		return httpx.NewResilientClientLatencyToleranceHigh(transport), nil
+	}
+	return f.hc, nil
+}
+
+// kubernetesGet sends a GET request to the Kubernetes API server. The caller must close the response body.
+func (f *FetcherDefault) kubernetesGet(ctx context.Context, hc *http.Client, source url.URL, path string, query url.Values) (*http.Response, error) {
+	u := kubernetesEndpoint(source) + path
+	if len(query) > 0 {
+		u = urlWithQuery(u, query)
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	// The token is read on every request because Kubernetes rotates it.
+	tokenFile, explicit := kubernetesFile(source, "token_file", kubernetesDefaultTokenFile)
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil && (explicit || !os.IsNotExist(err)) {
+		return nil, errors.WithStack(err)
+	} else if err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	return hc.Do(req)
+}
+
+// kubernetesPath returns the API path of the resource, limited to the namespace given in the `namespace` query
+// parameter of source.
+func kubernetesPath(source url.URL, resource kubernetesResource) string {
+	if namespace := source.Query().Get("namespace"); namespace != "" {
+		return "/apis/" + resource.group + "/namespaces/" + url.PathEscape(namespace) + "/" + resource.name
+	}
+	return "/apis/" + resource.group + "/" + resource.name
+}
+
+// kubernetesList lists all objects of the resource. Optional resources which are not installed are reported as an
+// empty list without resource version.
+func (f *FetcherDefault) kubernetesList(ctx context.Context, hc *http.Client, source url.URL, resource kubernetesResource) (*kubernetesList, error) {
+	res, err := f.kubernetesGet(ctx, hc, source, kubernetesPath(source, resource), nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound && resource.optional {
+		return &kubernetesList{}, nil
+	} else if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("expected http response status code 200 but got %d when listing %s", res.StatusCode, resource.name)
+	}
+
+	var list kubernetesList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &list, nil
+}
+
+func (f *FetcherDefault) fetchKubernetes(ctx context.Context, source url.URL) ([]Rule, error) {
+	hc, err := f.kubernetesClient(source, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source.String())
+	}
+
+	strategy := f.c.AccessRuleMatchingStrategy()
+	rules := []Rule{}
+	for _, resource := range kubernetesResources {
+		list, err := f.kubernetesList(ctx, hc, source, resource)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule: %s", source.String())
+		}
+
+		for _, item := range list.Items {
+			synthesized, warnings, err := kubernetesRules(resource, item, strategy)
+			if err != nil {
+				return nil, errors.Wrapf(err, "rule: %s", source.String())
+			}
+			for _, warning := range warnings {
+				f.r.Logger().
+					WithField("repository", source.String()).
+					Warn(warning)
+			}
+			rules = append(rules, synthesized...)
+		}
+	}
+
+	return rules, nil
+}
+
+// watchKubernetes uses the watch API of the Kubernetes API server to wait for changes of Ingress and HTTPRoute
+// objects.
+func (f *FetcherDefault) watchKubernetes(ctx context.Context, source url.URL, changed func()) error {
+	hc, err := f.kubernetesClient(source, true)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(kubernetesResources))
+	for _, resource := range kubernetesResources {
+		go func(resource kubernetesResource) {
+			errs <- f.watchKubernetesResource(ctx, hc, source, resource, changed)
+		}(resource)
+	}
+	return <-errs
+}
+
+func (f *FetcherDefault) watchKubernetesResource(ctx context.Context, hc *http.Client, source url.URL, resource kubernetesResource, changed func()) error {
+	list, err := f.kubernetesList(ctx, hc, source, resource)
+	if err != nil {
+		return err
+	}
+
+	version := list.Metadata.ResourceVersion
+	if version == "" {
+		// The resource is not installed in the cluster.
+		<-ctx.Done()
+		return errors.WithStack(ctx.Err())
+	}
+
+	for {
+		res, err := f.kubernetesGet(ctx, hc, source, kubernetesPath(source, resource), url.Values{
+			"watch":           {"true"},
+			"resourceVersion": {version},
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return errors.Errorf("expected http response status code 200 but got %d when watching %s", res.StatusCode, resource.name)
+		}
+
+		d := json.NewDecoder(res.Body)
+		for {
+			var e kubernetesWatchEvent
+			if err := d.Decode(&e); err == io.EOF {
+				// The API server closes watches after a while, so we just start a new one.
+				break
+			} else if err != nil {
+				res.Body.Close()
+				return errors.WithStack(err)
+			}
+
+			if e.Type == "ERROR" {
+				// This usually means that the resource version is too old, which is resolved by fetching the rules
+				// again.
+				res.Body.Close()
+				return errors.Errorf("kubernetes watch of %s failed: %s", resource.name, e.Object.Message)
+			}
+
+			if e.Object.Metadata.ResourceVersion != "" {
+				version = e.Object.Metadata.ResourceVersion
+			}
+			if e.Type != "BOOKMARK" {
+				changed()
+			}
+		}
+		res.Body.Close()
+	}
+}
+
+// kubernetesRules synthesizes access rules from an Ingress or HTTPRoute object. Objects without the authenticators
+// annotation are not protected by ORY Oathkeeper and yield no rules.
+func kubernetesRules(resource kubernetesResource, raw json.RawMessage, strategy configuration.MatchingStrategy) (rules []Rule, warnings []string, err error) {
+	var meta struct {
+		Metadata kubernetesObjectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	annotations := meta.Metadata.Annotations
+	if _, ok := annotations[kubernetesAnnotationPrefix+"authenticators"]; !ok {
+		return nil, nil, nil
+	}
+
+	object := fmt.Sprintf("%s %s/%s", resource.kind, meta.Metadata.Namespace, meta.Metadata.Name)
+	template, err := kubernetesRuleTemplate(annotations)
+	if err != nil {
+		// A single misconfigured object must not take down the access rules of all other objects.
+		return nil, []string{fmt.Sprintf("Ignoring %s because its annotations are invalid: %s", object, err)}, nil
+	}
+
+	var routes []kubernetesRoute
+	switch resource.kind {
+	case "ingress":
+		var ingress kubernetesIngress
+		if err := json.Unmarshal(raw, &ingress); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		routes = ingress.routes()
+	case "httproute":
+		var route kubernetesHTTPRoute
+		if err := json.Unmarshal(raw, &route); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		routes = route.routes()
+	}
+
+	for k, route := range routes {
+		id := fmt.Sprintf("kubernetes:%s:%s/%s:%d", resource.kind, meta.Metadata.Namespace, meta.Metadata.Name, k)
+
+		matcher, err := kubernetesMatcher(route.host, route.path, route.pathType, strategy)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Ignoring path %s of %s: %s", route.path, object, err))
+			continue
+		}
+
+		upstream := template.Upstream.URL
+		if upstream == "" {
+			if route.service == "" {
+				warnings = append(warnings, fmt.Sprintf("Ignoring path %s of %s because it has no service backend and the upstream annotation is not set", route.path, object))
+				continue
+			}
+			upstream = kubernetesServiceURL(route.service, route.namespace, route.port)
+		}
+
+		methods := template.Match.Methods
+		if route.method != "" {
+			methods = []string{route.method}
+		}
+
+		r := template
+		r.ID = id
+		r.Description = fmt.Sprintf("Synthesized from %s", object)
+		r.Match = &Match{Methods: methods, URL: matcher}
+		r.Upstream.URL = upstream
+		rules = append(rules, r)
+	}
+
+	return rules, warnings, nil
+}
+
+// kubernetesRuleTemplate builds the parts of the access rules which are the same for all paths of an object from its
+// annotations.
+func kubernetesRuleTemplate(annotations map[string]string) (r Rule, err error) {
+	annotation := func(key string) string {
+		return strings.TrimSpace(annotations[kubernetesAnnotationPrefix+key])
+	}
+
+	if r.Authenticators, err = kubernetesHandlers(annotation("authenticators")); err != nil {
+		return r, errors.Wrap(err, "authenticators")
+	} else if len(r.Authenticators) == 0 {
+		return r, errors.New("authenticators must not be empty")
+	}
+
+	authorizer := annotation("authorizer")
+	if authorizer == "" {
+		authorizer = "allow"
+	}
+	if strings.HasPrefix(authorizer, "{") {
+		if err := json.Unmarshal([]byte(authorizer), &r.Authorizer); err != nil {
+			return r, errors.Wrap(err, "authorizer")
+		}
+	} else {
+		r.Authorizer = Handler{Handler: authorizer}
+	}
+
+	mutators := annotation("mutators")
+	if mutators == "" {
+		mutators = "noop"
+	}
+	if r.Mutators, err = kubernetesHandlers(mutators); err != nil {
+		return r, errors.Wrap(err, "mutators")
+	}
+
+	if errs := annotation("errors"); errs != "" {
+		handlers, err := kubernetesHandlers(errs)
+		if err != nil {
+			return r, errors.Wrap(err, "errors")
+		}
+		for _, h := range handlers {
+			r.Errors = append(r.Errors, ErrorHandler{Handler: h.Handler, Config: h.Config})
+		}
+	}
+
+	r.Match = &Match{Methods: openAPIMethods}
+	if methods := annotation("methods"); methods != "" {
+		r.Match.Methods = nil
+		for _, method := range strings.Split(methods, ",") {
+			r.Match.Methods = append(r.Match.Methods, strings.ToUpper(strings.TrimSpace(method)))
+		}
+	}
+
+	r.Upstream.URL = annotation("upstream")
+	r.Upstream.StripPath = annotation("strip-path")
+	r.Upstream.Host = annotation("upstream-host")
+	if preserveHost := annotation("preserve-host"); preserveHost != "" {
+		if r.Upstream.PreserveHost, err = strconv.ParseBool(preserveHost); err != nil {
+			return r, errors.Wrap(err, "preserve-host")
+		}
+	}
+
+	return r, nil
+}
+
+// kubernetesHandlers parses a comma separated list of handler names or a JSON array of handlers including their
+// configuration.
+func kubernetesHandlers(value string) ([]Handler, error) {
+	if strings.HasPrefix(value, "[") {
+		var handlers []Handler
+		if err := json.Unmarshal([]byte(value), &handlers); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return handlers, nil
+	}
+
+	var handlers []Handler
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			handlers = append(handlers, Handler{Handler: name})
+		}
+	}
+	return handlers, nil
+}
+
+// kubernetesRoute is a host and path of an Ingress or HTTPRoute object and the service it is routed to.
+type kubernetesRoute struct {
+	host      string
+	path      string
+	pathType  string
+	method    string
+	service   string
+	namespace string
+	port      int
+}
+
+func (i *kubernetesIngress) routes() (routes []kubernetesRoute) {
+	backend := func(route kubernetesRoute, b *kubernetesIngressBackend) kubernetesRoute {
+		route.namespace = i.Metadata.Namespace
+		if b != nil && b.Service != nil {
+			route.service = b.Service.Name
+			route.port = b.Service.Port.Number
+		}
+		return route
+	}
+
+	for _, rule := range i.Spec.Rules {
+		if rule.HTTP == nil {
+			routes = append(routes, backend(kubernetesRoute{host: rule.Host, path: "/", pathType: "Prefix"}, i.Spec.DefaultBackend))
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			b := p.Backend
+			routes = append(routes, backend(kubernetesRoute{host: rule.Host, path: p.Path, pathType: p.PathType}, &b))
+		}
+	}
+
+	if len(i.Spec.Rules) == 0 && i.Spec.DefaultBackend != nil {
+		routes = append(routes, backend(kubernetesRoute{path: "/", pathType: "Prefix"}, i.Spec.DefaultBackend))
+	}
+
+	return routes
+}
+
+func (h *kubernetesHTTPRoute) routes() (routes []kubernetesRoute) {
+	hosts := h.Spec.Hostnames
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+
+	for _, host := range hosts {
+		for _, rule := range h.Spec.Rules {
+			template := kubernetesRoute{host: host, path: "/", pathType: "PathPrefix", namespace: h.Metadata.Namespace}
+			if len(rule.BackendRefs) > 0 {
+				ref := rule.BackendRefs[0]
+				template.service, template.port = ref.Name, ref.Port
+				if ref.Namespace != "" {
+					template.namespace = ref.Namespace
+				}
+			}
+
+			if len(rule.Matches) == 0 {
+				routes = append(routes, template)
+				continue
+			}
+
+			for _, match := range rule.Matches {
+				route := template
+				route.method = match.Method
+				if match.Path != nil {
+					route.path, route.pathType = match.Path.Value, match.Path.Type
+				}
+				routes = append(routes, route)
+			}
+		}
+	}
+
+	return routes
+}
+
+// kubernetesMatcher converts the host and path of a route to a matcher of the matching strategy. Both HTTP and HTTPS
+// are matched because TLS may be terminated in front of ORY Oathkeeper.
+func kubernetesMatcher(host, path, pathType string, strategy configuration.MatchingStrategy) (string, error) {
+	if path == "" {
+		path = "/"
+	}
+
+	quote, scheme, anyHost, anyLabel, anySuffix := regexp.QuoteMeta, "<https?>", "<[^/]+>", "<[^/.]+>", "<(/.*)?>"
+	if strategy == configuration.Glob {
+		quote = func(s string) string {
+			return strings.NewReplacer("*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`, "{", `\{`, "}", `\}`).Replace(s)
+		}
+		scheme, anyHost, anyLabel, anySuffix = "<{http,https}>", "<*>", "<*>", "<{,/**}>"
+	}
+
+	var matcher strings.Builder
+	matcher.WriteString(scheme + "://")
+	switch {
+	case host == "":
+		matcher.WriteString(anyHost)
+	case strings.HasPrefix(host, "*."):
+		matcher.WriteString(anyLabel + quote(strings.TrimPrefix(host, "*")))
+	default:
+		matcher.WriteString(quote(host))
+	}
+
+	switch pathType {
+	case "Exact":
+		matcher.WriteString(quote(path))
+	case "", "Prefix", "PathPrefix", "ImplementationSpecific":
+		matcher.WriteString(quote(strings.TrimRight(path, "/")) + anySuffix)
+	case "RegularExpression":
+		if strategy == configuration.Glob {
+			return "", errors.New("regular expression paths require the regexp matching strategy")
+		}
+		matcher.WriteString("<" + path + ">")
+	default:
+		return "", errors.Errorf("unknown path type %s", pathType)
+	}
+
+	return matcher.String(), nil
+}
+
+// kubernetesServiceURL returns the in-cluster URL of a service.
+func kubernetesServiceURL(service, namespace string, port int) string {
+	u := "http://" + service + "." + namespace + ".svc"
+	if port > 0 {
+		u += ":" + strconv.Itoa(port)
+	}
+	return u
+}
@@ -0,0 +1,210 @@
+package rule_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/viperx"
+
+	"github.com/ory/oathkeeper/internal"
+)
+
+type fakeKubernetes struct {
+	sync.Mutex
+	ingresses []map[string]interface{}
+	version   int
+	changed   chan struct{}
+}
+
+func (k *fakeKubernetes) set(ingresses ...map[string]interface{}) {
+	k.Lock()
+	defer k.Unlock()
+	k.ingresses = ingresses
+	k.version++
+	close(k.changed)
+	k.changed = make(chan struct{})
+}
+
+func (k *fakeKubernetes) get() ([]map[string]interface{}, string, chan struct{}) {
+	k.Lock()
+	defer k.Unlock()
+	return k.ingresses, strconv.Itoa(k.version), k.changed
+}
+
+func (k *fakeKubernetes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer service-account-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path != "/apis/networking.k8s.io/v1/ingresses" {
+		// The Gateway API is not installed.
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	ingresses, version, changed := k.get()
+	if r.URL.Query().Get("watch") != "true" {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"metadata": map[string]string{"resourceVersion": version},
+			"items":    ingresses,
+		})
+		return
+	}
+
+	w.(http.Flusher).Flush()
+	for {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		_, version, changed = k.get()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"type":   "MODIFIED",
+			"object": map[string]interface{}{"metadata": map[string]string{"resourceVersion": version}},
+		})
+		w.(http.Flusher).Flush()
+	}
+}
+
+func newIngress(name string, annotations map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{"name": name, "namespace": "default", "annotations": annotations},
+		"spec": map[string]interface{}{
+			"rules": []interface{}{map[string]interface{}{
+				"host": "api.example.com",
+				"http": map[string]interface{}{"paths": []interface{}{
+					map[string]interface{}{
+						"path":     "/" + name,
+						"pathType": "Prefix",
+						"backend": map[string]interface{}{
+							"service": map[string]interface{}{"name": name, "port": map[string]interface{}{"number": 8080}},
+						},
+					},
+				}},
+			}},
+		},
+	}
+}
+
+func TestFetcherWatchRepositoryFromKubernetes(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults() // this resets viper!!
+	r := internal.NewRegistry(conf)
+
+	kube := &fakeKubernetes{changed: make(chan struct{})}
+	kube.set(
+		newIngress("users", map[string]string{"oathkeeper.ory.sh/authenticators": "jwt,anonymous"}),
+		newIngress("unprotected", nil),
+	)
+	ts := httptest.NewServer(kube)
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	id := uuid.New().String()
+	tokenFile := filepath.Join(os.TempDir(), ".oathkeeper-"+id+".token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("service-account-token\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(os.TempDir(), ".oathkeeper-"+id+".yml"), []byte(`
+access_rules:
+  repositories:
+  - kubernetes://`+u.Host+`?tls=false&token_file=`+url.QueryEscape(tokenFile)+`
+`), 0777))
+
+	viperx.InitializeConfig("oathkeeper-"+id, os.TempDir(), nil)
+	viperx.WatchConfig(nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		require.NoError(t, r.RuleFetcher().Watch(ctx))
+	}()
+
+	for k, tc := range []struct {
+		ingresses []map[string]interface{}
+		expectIDs []string
+	}{
+		{expectIDs: []string{"kubernetes:ingress:default/users:0"}},
+		{
+			ingresses: []map[string]interface{}{
+				newIngress("users", map[string]string{"oathkeeper.ory.sh/authenticators": "jwt,anonymous"}),
+				newIngress("orders", map[string]string{
+					"oathkeeper.ory.sh/authenticators": `[{"handler":"oauth2_introspection","config":{"required_scope":["orders"]}}]`,
+					"oathkeeper.ory.sh/authorizer":     "deny",
+					"oathkeeper.ory.sh/mutators":       "header",
+					"oathkeeper.ory.sh/methods":        "get, post",
+					"oathkeeper.ory.sh/upstream":       "http://orders-v2.default.svc:9000",
+				}),
+			},
+			expectIDs: []string{"kubernetes:ingress:default/users:0", "kubernetes:ingress:default/orders:0"},
+		},
+		{ingresses: []map[string]interface{}{newIngress("users", map[string]string{"oathkeeper.ory.sh/authenticators": "[{"})}},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			if tc.ingresses != nil {
+				kube.set(tc.ingresses...)
+			}
+			time.Sleep(time.Millisecond * 500)
+
+			rules, err := r.RuleRepository().List(context.Background(), 500, 0)
+			require.NoError(t, err)
+			require.Len(t, rules, len(tc.expectIDs))
+
+			for k, id := range tc.expectIDs {
+				assert.Equal(t, id, rules[k].ID)
+			}
+		})
+	}
+
+	t.Run("case=synthesized rules", func(t *testing.T) {
+		kube.set(
+			newIngress("users", map[string]string{"oathkeeper.ory.sh/authenticators": "jwt,anonymous"}),
+			newIngress("orders", map[string]string{
+				"oathkeeper.ory.sh/authenticators": `[{"handler":"oauth2_introspection","config":{"required_scope":["orders"]}}]`,
+				"oathkeeper.ory.sh/authorizer":     "deny",
+				"oathkeeper.ory.sh/mutators":       "header",
+				"oathkeeper.ory.sh/methods":        "get, post",
+				"oathkeeper.ory.sh/upstream":       "http://orders-v2.default.svc:9000",
+			}),
+		)
+		time.Sleep(time.Millisecond * 500)
+
+		users, err := r.RuleRepository().Get(context.Background(), "kubernetes:ingress:default/users:0")
+		require.NoError(t, err)
+		assert.Equal(t, `<https?>://api\.example\.com/users<(/.*)?>`, users.Match.URL)
+		assert.Len(t, users.Match.Methods, 8)
+		require.Len(t, users.Authenticators, 2)
+		assert.Equal(t, "jwt", users.Authenticators[0].Handler)
+		assert.Equal(t, "anonymous", users.Authenticators[1].Handler)
+		assert.Equal(t, "allow", users.Authorizer.Handler)
+		require.Len(t, users.Mutators, 1)
+		assert.Equal(t, "noop", users.Mutators[0].Handler)
+		assert.Equal(t, "http://users.default.svc:8080", users.Upstream.URL)
+
+		orders, err := r.RuleRepository().Get(context.Background(), "kubernetes:ingress:default/orders:0")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"GET", "POST"}, orders.Match.Methods)
+		require.Len(t, orders.Authenticators, 1)
+		assert.Equal(t, "oauth2_introspection", orders.Authenticators[0].Handler)
+		assert.JSONEq(t, `{"required_scope":["orders"]}`, string(orders.Authenticators[0].Config))
+		assert.Equal(t, "deny", orders.Authorizer.Handler)
+		assert.Equal(t, "header", orders.Mutators[0].Handler)
+		assert.Equal(t, "http://orders-v2.default.svc:9000", orders.Upstream.URL)
+	})
+}
@@ -0,0 +1,301 @@
+package rule
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	schemeConsul = "consul"
+	schemeEtcd   = "etcd"
+
+	kvWatchRetryInterval = time.Second * 5
+)
+
+func isKVScheme(scheme string) bool {
+	return scheme == schemeConsul || scheme == schemeEtcd
+}
+
+// hasWatchAPI returns true if changes of repositories of the scheme are detected using a native watch API.
+func hasWatchAPI(scheme string) bool {
+	return isKVScheme(scheme) || scheme == schemeKubernetes || scheme == schemeManaged
+}
+
+// kvEndpoint returns the HTTP(s) base URL of the key value store referenced by source. Per default, plain
+// HTTP is used which can be changed by setting the `tls` query parameter to `true`.
+func kvEndpoint(source url.URL) string {
+	scheme := "http"
+	if source.Query().Get("tls") == "true" {
+		scheme = "https"
+	}
+	return scheme + "://" + source.Host
+}
+
+// kvKey returns the key referenced by source.
+func kvKey(source url.URL) string {
+	return strings.TrimPrefix(source.Path, "/")
+}
+
+func (f *FetcherDefault) fetchKV(ctx context.Context, source url.URL) ([]Rule, error) {
+	var value []byte
+	var err error
+	switch source.Scheme {
+	case schemeConsul:
+		value, _, err = f.consulGet(ctx, f.hc, source, 0)
+	case schemeEtcd:
+		value, _, err = f.etcdRange(ctx, source)
+	default:
+		err = errors.Errorf("unknown key value store scheme: %s", source.Scheme)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source.String())
+	}
+
+	if len(value) == 0 {
+		return []Rule{}, nil
+	}
+
+	return f.decode(bytes.NewReader(value))
+}
+
+// watchKV uses the native watch API of the key value store referenced by source and emits an event
+// whenever the key changes. It returns once ctx is canceled.
+func (f *FetcherDefault) watchKV(ctx context.Context, source url.URL, events chan event) {
+	var watch func(ctx context.Context, source url.URL, changed func()) error
+	switch source.Scheme {
+	case schemeConsul:
+		watch = f.watchConsul
+	case schemeEtcd:
+		watch = f.watchEtcd
+	case schemeKubernetes:
+		watch = f.watchKubernetes
+	case schemeManaged:
+		watch = f.watchManaged
+	default:
+		return
+	}
+
+	for {
+		err := watch(ctx, source, func() {
+			f.enqueueEvent(events, event{et: eventFileChanged, path: source, source: source.Scheme + "_watcher"})
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		f.r.Logger().WithError(err).
+			WithField("repository", source.String()).
+			Errorf("Unable to watch access rule repository, retrying in %s.", kvWatchRetryInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(kvWatchRetryInterval):
+			// After reconnecting we might have missed changes, so let's fetch the rules again.
+			f.enqueueEvent(events, event{et: eventFileChanged, path: source, source: source.Scheme + "_watcher"})
+		}
+	}
+}
+
+func (f *FetcherDefault) consulGet(ctx context.Context, hc *http.Client, source url.URL, index uint64) ([]byte, uint64, error) {
+	u := urlWithQuery(kvEndpoint(source)+"/v1/kv/"+kvKey(source), url.Values{"raw": {""}})
+	if index > 0 {
+		u = urlWithQuery(u, url.Values{"index": {strconv.FormatUint(index, 10)}, "wait": {"5m"}})
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	if token := source.Query().Get("token"); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	newIndex, _ := strconv.ParseUint(res.Header.Get("X-Consul-Index"), 10, 64)
+	if res.StatusCode == http.StatusNotFound {
+		return nil, newIndex, nil
+	} else if res.StatusCode != http.StatusOK {
+		return nil, 0, errors.Errorf("expected http response status code 200 but got %d", res.StatusCode)
+	}
+
+	var b bytes.Buffer
+	if _, err := io.Copy(&b, res.Body); err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+
+	return b.Bytes(), newIndex, nil
+}
+
+// watchConsul uses Consul's blocking queries to wait for changes of the key.
+func (f *FetcherDefault) watchConsul(ctx context.Context, source url.URL, changed func()) error {
+	// Blocking queries may take several minutes to return, so we can not use a client with a short timeout here.
+	hc := &http.Client{}
+
+	_, index, err := f.consulGet(ctx, hc, source, 0)
+	if err != nil {
+		return err
+	}
+
+	for {
+		_, next, err := f.consulGet(ctx, hc, source, index)
+		if err != nil {
+			return err
+		}
+
+		if next < index {
+			// The index went backwards which happens e.g. when the Consul cluster was restored. We need to
+			// reset the index as recommended by Consul's documentation.
+			index = 0
+			changed()
+			continue
+		} else if next != index {
+			index = next
+			changed()
+		}
+	}
+}
+
+type etcdKeyValue struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+type etcdRangeResponse struct {
+	Header struct {
+		Revision string `json:"revision"`
+	} `json:"header"`
+	KVs []etcdKeyValue `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Events []struct {
+			Type string       `json:"type"`
+			KV   etcdKeyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (f *FetcherDefault) etcdPost(ctx context.Context, hc *http.Client, source url.URL, path string, body interface{}) (*http.Response, error) {
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(body); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest("POST", kvEndpoint(source)+path, &b)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if token := source.Query().Get("token"); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, errors.Errorf("expected http response status code 200 but got %d", res.StatusCode)
+	}
+
+	return res, nil
+}
+
+// etcdRange fetches the key using etcd's v3 JSON gRPC gateway.
+func (f *FetcherDefault) etcdRange(ctx context.Context, source url.URL) ([]byte, int64, error) {
+	res, err := f.etcdPost(ctx, f.hc, source, "/v3/kv/range", map[string]string{
+		"key": base64.StdEncoding.EncodeToString([]byte(kvKey(source))),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	var rr etcdRangeResponse
+	if err := json.NewDecoder(res.Body).Decode(&rr); err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+
+	revision, _ := strconv.ParseInt(rr.Header.Revision, 10, 64)
+	if len(rr.KVs) == 0 {
+		return nil, revision, nil
+	}
+
+	value, err := base64.StdEncoding.DecodeString(rr.KVs[0].Value)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+
+	return value, revision, nil
+}
+
+// watchEtcd uses etcd's v3 watch API (streamed through the JSON gRPC gateway) to wait for changes of the key.
+func (f *FetcherDefault) watchEtcd(ctx context.Context, source url.URL, changed func()) error {
+	_, revision, err := f.etcdRange(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	// The watch stream is kept open indefinitely, so we can not use a client with a short timeout here.
+	res, err := f.etcdPost(ctx, &http.Client{}, source, "/v3/watch", map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            base64.StdEncoding.EncodeToString([]byte(kvKey(source))),
+			"start_revision": strconv.FormatInt(revision+1, 10),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	d := json.NewDecoder(res.Body)
+	for {
+		var wr etcdWatchResponse
+		if err := d.Decode(&wr); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if wr.Error != nil {
+			return errors.Errorf("etcd watch failed: %s", wr.Error.Message)
+		}
+
+		if len(wr.Result.Events) > 0 {
+			changed()
+		}
+	}
+}
+
+func urlWithQuery(u string, q url.Values) string {
+	if strings.Contains(u, "?") {
+		return u + "&" + q.Encode()
+	}
+	return u + "?" + q.Encode()
+}
@@ -0,0 +1,161 @@
+package rule_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/viperx"
+
+	"github.com/ory/oathkeeper/internal"
+)
+
+type fakeKV struct {
+	sync.Mutex
+	value   string
+	index   int
+	changed chan struct{}
+}
+
+func newFakeKV(value string) *fakeKV {
+	return &fakeKV{value: value, index: 1, changed: make(chan struct{})}
+}
+
+func (kv *fakeKV) set(value string) {
+	kv.Lock()
+	defer kv.Unlock()
+	kv.value = value
+	kv.index++
+	close(kv.changed)
+	kv.changed = make(chan struct{})
+}
+
+func (kv *fakeKV) get() (string, int, chan struct{}) {
+	kv.Lock()
+	defer kv.Unlock()
+	return kv.value, kv.index, kv.changed
+}
+
+func (kv *fakeKV) consul() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, index, changed := kv.get()
+		if wait, _ := strconv.Atoi(r.URL.Query().Get("index")); wait > 0 && wait == index {
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+			value, index, _ = kv.get()
+		}
+
+		w.Header().Set("X-Consul-Index", strconv.Itoa(index))
+		_, _ = w.Write([]byte(value))
+	})
+}
+
+func (kv *fakeKV) etcd() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, index, changed := kv.get()
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"header": map[string]string{"revision": strconv.Itoa(index)},
+				"kvs":    []map[string]string{{"value": base64.StdEncoding.EncodeToString([]byte(value))}},
+			})
+		case "/v3/watch":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"created": true}})
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case <-changed:
+				case <-r.Context().Done():
+					return
+				}
+				_, _, changed = kv.get()
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{
+					"events": []map[string]interface{}{{"kv": map[string]string{}}},
+				}})
+				w.(http.Flusher).Flush()
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestFetcherWatchRepositoryFromKV(t *testing.T) {
+	for _, scheme := range []string{"consul", "etcd"} {
+		t.Run("scheme="+scheme, func(t *testing.T) {
+			conf := internal.NewConfigurationWithDefaults() // this resets viper!!
+			r := internal.NewRegistry(conf)
+
+			kv := newFakeKV(`[{"id":"1"}]`)
+			var handler http.Handler
+			if scheme == "consul" {
+				handler = kv.consul()
+			} else {
+				handler = kv.etcd()
+			}
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			u, err := url.Parse(ts.URL)
+			require.NoError(t, err)
+
+			id := uuid.New().String()
+			require.NoError(t, ioutil.WriteFile(filepath.Join(os.TempDir(), ".oathkeeper-"+id+".yml"), []byte(`
+access_rules:
+  repositories:
+  - `+scheme+`://`+u.Host+`/oathkeeper/rules
+`), 0777))
+
+			viperx.InitializeConfig("oathkeeper-"+id, os.TempDir(), nil)
+			viperx.WatchConfig(nil, nil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				require.NoError(t, r.RuleFetcher().Watch(ctx))
+			}()
+
+			for k, tc := range []struct {
+				content   string
+				expectIDs []string
+			}{
+				{content: `[{"id":"1"}]`, expectIDs: []string{"1"}},
+				{content: `[{"id":"1"},{"id":"2"}]`, expectIDs: []string{"1", "2"}},
+				{content: `[]`},
+			} {
+				t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+					if k > 0 {
+						kv.set(tc.content)
+					}
+					time.Sleep(time.Millisecond * 500)
+
+					rules, err := r.RuleRepository().List(context.Background(), 500, 0)
+					require.NoError(t, err)
+					require.Len(t, rules, len(tc.expectIDs))
+
+					for k, id := range tc.expectIDs {
+						assert.Equal(t, id, rules[k].ID)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package rule
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const natsRetryInterval = time.Second * 5
+
+// watchNATS subscribes to the configured NATS subject and enqueues a reload of all access rule repositories
+// whenever a message arrives. It returns once ctx is canceled.
+func (f *FetcherDefault) watchNATS(ctx context.Context, u *url.URL, subject string, events chan event) {
+	for {
+		err := natsSubscribe(ctx, u, subject, func() {
+			f.r.Logger().
+				WithField("event", "nats_notification").
+				WithField("subject", subject).
+				Debugf("Received access rule change notification, reloading access rules.")
+
+			for _, source := range f.repositories() {
+				f.enqueueEvent(events, event{et: eventFileChanged, path: source, source: "nats"})
+			}
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		f.r.Logger().WithError(err).
+			WithField("subject", subject).
+			Errorf("Unable to subscribe to NATS access rule notifications, retrying in %s.", natsRetryInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(natsRetryInterval):
+		}
+	}
+}
+
+// natsSubscribe implements the subset of the NATS client protocol required to subscribe to a subject.
+// It blocks until the connection fails or ctx is canceled.
+func natsSubscribe(ctx context.Context, u *url.URL, subject string, onMessage func()) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return errors.WithStack(err)
+	} else if !strings.HasPrefix(line, "INFO ") {
+		return errors.Errorf("expected INFO from NATS server but got: %s", strings.TrimSpace(line))
+	}
+
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return errors.WithStack(err)
+	} else if info.TLSRequired {
+		return errors.New("NATS server requires TLS which is not supported")
+	}
+
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "oathkeeper", "lang": "go"}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options["user"] = u.User.Username()
+			options["pass"] = password
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nSUB %s 1\r\nPING\r\n", connect, subject); err != nil {
+		return errors.WithStack(err)
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			if _, err := io.WriteString(conn, "PONG\r\n"); err != nil {
+				return errors.WithStack(err)
+			}
+		case "-ERR":
+			return errors.Errorf("NATS server returned an error: %s", strings.TrimSpace(strings.TrimPrefix(line, fields[0])))
+		case "MSG":
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			// Skip the payload and the trailing CRLF, we only care about the notification itself.
+			if _, err := io.CopyN(ioutil.Discard, r, int64(size+2)); err != nil {
+				return errors.WithStack(err)
+			}
+
+			onMessage()
+		}
+	}
+}
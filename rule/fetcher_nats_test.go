@@ -0,0 +1,76 @@
+package rule
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSSubscribe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	received := make(chan string, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, _ = fmt.Fprint(conn, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			received <- strings.TrimSpace(line)
+
+			if strings.HasPrefix(line, "PING") {
+				_, _ = fmt.Fprint(conn, "PONG\r\nPING\r\nMSG rules.changed 1 5\r\nhello\r\nMSG rules.changed 1 reply 0\r\n\r\n")
+			}
+		}
+	}()
+
+	u, err := url.Parse("nats://token@" + l.Addr().String())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := make(chan struct{}, 10)
+	done := make(chan error)
+	go func() {
+		done <- natsSubscribe(ctx, u, "rules.changed", func() {
+			messages <- struct{}{}
+		})
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-messages:
+		case <-time.After(time.Second):
+			t.Fatal("expected a message to be received")
+		}
+	}
+
+	assert.Contains(t, <-received, `"auth_token":"token"`)
+	assert.Equal(t, "SUB rules.changed 1", <-received)
+	assert.Equal(t, "PING", <-received)
+	assert.Equal(t, "PONG", <-received)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected subscription to stop after the context was canceled")
+	}
+}
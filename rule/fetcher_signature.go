@@ -0,0 +1,117 @@
+package rule
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/x/stringslice"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+)
+
+// SignatureSuffix is appended to the location of a file of access rules to get the location of its signature. The
+// signature of "https://example.org/rules.json" is "https://example.org/rules.json.jws".
+const SignatureSuffix = ".jws"
+
+// signaturesRequired returns true if files of access rules, fetched from file and http(s) repositories, must be signed.
+func (f *FetcherDefault) signaturesRequired() bool {
+	return len(f.c.AccessRuleVerificationJWKSURLs()) > 0
+}
+
+// signatureSchemes are the schemes of the repositories whose files of access rules can be signed.
+var signatureSchemes = []string{"file", "http", "https"}
+
+// ValidateSignatureSchemes returns an error if files of access rules must be signed but a repository, including the
+// access rules defined in the configuration itself, can not be verified because its access rules are not signed.
+func ValidateSignatureSchemes(c configuration.Provider) error {
+	if len(c.AccessRuleVerificationJWKSURLs()) == 0 {
+		return nil
+	}
+
+	repositories := c.AccessRuleRepositories()
+	if c.AccessRuleInline() != nil {
+		repositories = append(repositories, inlineConfigSource)
+	}
+	for _, source := range repositories {
+		if err := checkSignatureScheme(source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkSignatureScheme(source url.URL) error {
+	if stringslice.Has(signatureSchemes, source.Scheme) {
+		return nil
+	}
+	return errors.Errorf("rule: the access rules of %s can not be verified because only file, http and https repositories are signed", source.String())
+}
+
+// verifySignature verifies the compact JSON Web Signature with detached payload, "<header>..<signature>", of the
+// content of the file at location. The key is resolved by the kid of the signature from the configured JSON Web Key
+// Sets.
+func (f *FetcherDefault) verifySignature(location string, content, signature []byte) error {
+	jws, err := jose.ParseSigned(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return errors.Wrapf(err, "rule: unable to parse the signature of %s", location)
+	}
+	if len(jws.Signatures) != 1 {
+		return errors.Errorf("rule: expected one signature of %s but got %d", location, len(jws.Signatures))
+	}
+
+	kid := jws.Signatures[0].Header.KeyID
+	if kid == "" {
+		return errors.Errorf("rule: the signature of %s must have a kid header", location)
+	}
+
+	key, err := f.r.CredentialsFetcher().ResolveKey(context.Background(), f.c.AccessRuleVerificationJWKSURLs(), kid, "sig")
+	if err != nil {
+		return errors.Wrapf(err, "rule: unable to resolve the key verifying the signature of %s", location)
+	}
+
+	verificationKey := interface{}(key)
+	if _, ok := key.Key.([]byte); !ok && !key.IsPublic() {
+		public := key.Public()
+		verificationKey = &public
+	}
+
+	if err := jws.DetachedVerify(content, verificationKey); err != nil {
+		return errors.Wrapf(err, "rule: the signature of %s is invalid", location)
+	}
+	return nil
+}
+
+// fetchRemoteSignature fetches the signature of the access rules at source.
+func (f *FetcherDefault) fetchRemoteSignature(source string) ([]byte, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source)
+	}
+	u.Path += SignatureSuffix
+
+	res, err := f.hc.Get(u.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", u.String())
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("rule: expected http response status code 200 but got %d when fetching the signature: %s", res.StatusCode, u.String())
+	}
+
+	return ioutil.ReadAll(res.Body)
+}
+
+// fetchFileSignature reads the signature of the access rules of the file.
+func (f *FetcherDefault) fetchFileSignature(source string) ([]byte, error) {
+	signature, err := ioutil.ReadFile(source + SignatureSuffix)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: unable to read the signature of %s", source)
+	}
+	return signature, nil
+}
@@ -0,0 +1,133 @@
+package rule_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	"github.com/ory/oathkeeper/rule"
+)
+
+func TestFetcherVerifiesSignatures(t *testing.T) {
+	viper.Reset()
+	conf := internal.NewConfigurationWithDefaults() // this must be at the top because it resets viper
+	r := internal.NewRegistry(conf)
+
+	dir, err := ioutil.TempDir("", "signed-rules")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "rules", Use: "sig", Algorithm: "ES256"}}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "jwks.json"), jwks, 0600))
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sign := func(content string, key *ecdsa.PrivateKey) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: key, KeyID: "rules"}}, nil)
+		require.NoError(t, err)
+		jws, err := signer.Sign([]byte(content))
+		require.NoError(t, err)
+		signature, err := jws.DetachedCompactSerialize()
+		require.NoError(t, err)
+		return signature
+	}
+	write := func(name, content, signature string) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(p, []byte(content), 0600))
+		if signature != "" {
+			require.NoError(t, ioutil.WriteFile(p+rule.SignatureSuffix, []byte(signature), 0600))
+		}
+		return "file://" + p
+	}
+
+	signed := write("signed.json", `[{"id":"signed"}]`, sign(`[{"id":"signed"}]`, key))
+	unsigned := write("unsigned.json", `[{"id":"unsigned"}]`, "")
+	foreign := write("foreign.json", `[{"id":"foreign"}]`, sign(`[{"id":"foreign"}]`, other))
+	tampered := write("tampered.json", `[{"id":"tampered","upstream":{"url":"https://attacker.example.org"}}]`, sign(`[{"id":"tampered"}]`, key))
+
+	// Signature files in directories are not parsed as access rules.
+	directory := filepath.Join(dir, "directory")
+	require.NoError(t, os.Mkdir(directory, 0700))
+	write(filepath.Join("directory", "rules.json"), `[{"id":"directory"}]`, sign(`[{"id":"directory"}]`, key))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rules.json":
+			_, _ = w.Write([]byte(`[{"id":"remote"}]`))
+		case "/rules.json" + rule.SignatureSuffix:
+			_, _ = w.Write([]byte(sign(`[{"id":"remote"}]`, key)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	viper.Set(configuration.ViperKeyAccessRuleVerificationJWKS, []string{"file://" + filepath.Join(dir, "jwks.json")})
+	viper.Set(configuration.ViperKeyAccessRuleRepositories, []string{signed, unsigned, foreign, tampered, "file://" + directory, server.URL + "/rules.json"})
+
+	go func() {
+		require.NoError(t, r.RuleFetcher().Watch(context.TODO()))
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	rules, err := r.RuleRepository().List(context.Background(), 500, 0)
+	require.NoError(t, err)
+	ids := make([]string, len(rules))
+	for k, rl := range rules {
+		ids[k] = rl.ID
+	}
+	sort.Strings(ids)
+	assert.Equal(t, []string{"directory", "remote", "signed"}, ids)
+
+	failed := map[string]bool{}
+	for _, s := range r.RuleFetcher().Status() {
+		failed[s.URL] = s.Error != ""
+	}
+	assert.Equal(t, map[string]bool{
+		signed:                     false,
+		unsigned:                   true,
+		foreign:                    true,
+		tampered:                   true,
+		"file://" + directory:      false,
+		server.URL + "/rules.json": false,
+	}, failed)
+}
+
+func TestValidateSignatureSchemes(t *testing.T) {
+	viper.Reset()
+	conf := internal.NewConfigurationWithDefaults()
+
+	viper.Set(configuration.ViperKeyAccessRuleRepositories, []string{"file:///etc/rules.json", "inline://W10="})
+	require.NoError(t, rule.ValidateSignatureSchemes(conf))
+
+	viper.Set(configuration.ViperKeyAccessRuleVerificationJWKS, []string{"file:///etc/jwks.json"})
+	require.Error(t, rule.ValidateSignatureSchemes(conf))
+
+	viper.Set(configuration.ViperKeyAccessRuleRepositories, []string{"file:///etc/rules.json", "https://rules.example.org/rules.json"})
+	require.NoError(t, rule.ValidateSignatureSchemes(conf))
+
+	viper.Set(configuration.ViperKeyAccessRuleInline, []interface{}{map[string]interface{}{"id": "inline"}})
+	require.Error(t, rule.ValidateSignatureSchemes(conf))
+}
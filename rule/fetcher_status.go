@@ -0,0 +1,115 @@
+package rule
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ory/oathkeeper/events"
+)
+
+// RepositoryStatus is the state of an access rule repository.
+type RepositoryStatus struct {
+	// URL is the location of the repository with credentials removed.
+	URL string `json:"url"`
+
+	// Rules is the number of access rules loaded from the repository.
+	Rules int `json:"rules"`
+
+	// UpdatedAt is when the access rules of the repository were loaded last.
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+
+	// Error is the reason the last reload of the repository failed, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Status returns the state of all configured access rule repositories.
+func (f *FetcherDefault) Status() []RepositoryStatus {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	repositories := f.repositories()
+	statuses := make([]RepositoryStatus, len(repositories))
+	for k, source := range repositories {
+		statuses[k] = RepositoryStatus{URL: redactRepository(source)}
+		if s, ok := f.status[source.String()]; ok {
+			statuses[k].Rules = s.Rules
+			statuses[k].UpdatedAt = s.UpdatedAt
+			statuses[k].Error = s.Error
+		}
+	}
+	return statuses
+}
+
+// recordStatus remembers the outcome of reloading the repository.
+func (f *FetcherDefault) recordStatus(source url.URL, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	s, ok := f.status[source.String()]
+	if !ok {
+		s = &RepositoryStatus{}
+		f.status[source.String()] = s
+	}
+
+	if err != nil {
+		s.Error = err.Error()
+		return
+	}
+
+	now := time.Now().UTC()
+	s.Error = ""
+	s.UpdatedAt = &now
+	s.Rules = len(f.cache[sourceKey(source)])
+}
+
+// publishReload publishes the outcome of reloading the repository on the event bus.
+func (f *FetcherDefault) publishReload(source url.URL, err error) {
+	reload := &events.RuleReload{Repository: redactRepository(source)}
+	if err != nil {
+		reload.Error = err.Error()
+	} else {
+		f.lock.Lock()
+		reload.Rules = len(f.cache[sourceKey(source)])
+		f.lock.Unlock()
+	}
+
+	f.r.EventBus().Publish(events.Event{Type: events.TypeRuleReload, Time: time.Now().UTC(), RuleReload: reload})
+}
+
+// sourceKey returns the key of the source in the rule cache.
+func sourceKey(source url.URL) string {
+	if source.Scheme == "file" {
+		if u, err := url.Parse("file://" + filepath.Clean(strings.TrimPrefix(source.String(), "file://"))); err == nil {
+			return u.String()
+		}
+	}
+	return source.String()
+}
+
+// redactRepository removes credentials from the URL of a repository so that it can be shown to operators.
+func redactRepository(source url.URL) string {
+	switch source.Scheme {
+	case "inline":
+		return "inline://"
+	case inlineConfigSource.Scheme:
+		return source.String()
+	}
+
+	if source.User != nil {
+		source.User = url.User(source.User.Username())
+	}
+
+	query := source.Query()
+	for key := range query {
+		for _, secret := range []string{"token", "secret", "password", "key"} {
+			if strings.Contains(strings.ToLower(key), secret) {
+				query.Set(key, "REDACTED")
+			}
+		}
+	}
+	source.RawQuery = query.Encode()
+
+	return source.String()
+}
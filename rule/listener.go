@@ -0,0 +1,31 @@
+package rule
+
+import (
+	"context"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+)
+
+type listenerContextKey struct{}
+
+// WithListener returns a copy of ctx which carries the name of the proxy listener serving the request.
+func WithListener(ctx context.Context, listener string) context.Context {
+	return context.WithValue(ctx, listenerContextKey{}, listener)
+}
+
+// ListenerFromContext returns the name of the proxy listener serving the request or the default listener's name.
+func ListenerFromContext(ctx context.Context) string {
+	if l, ok := ctx.Value(listenerContextKey{}).(string); ok && l != "" {
+		return l
+	}
+	return configuration.DefaultProxyListener
+}
+
+// IsServedBy returns true if the rule is served by the given proxy listener. Rules which do not
+// name any listener are served by the default listener only.
+func (r *Rule) IsServedBy(listener string) bool {
+	if len(r.Listeners) == 0 {
+		return listener == configuration.DefaultProxyListener
+	}
+	return stringInSlice(listener, r.Listeners)
+}
@@ -0,0 +1,267 @@
+package rule
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/helper"
+)
+
+// schemeManaged is the scheme of the access rule repository holding the access rules managed through the admin API.
+const schemeManaged = "api"
+
+// IsManagedRepository returns true if the access rule repository holds the access rules managed through the admin
+// API.
+func IsManagedRepository(scheme string) bool {
+	return scheme == schemeManaged
+}
+
+// ETag returns a strong entity tag of the access rule which changes whenever the access rule changes.
+func (r *Rule) ETag() string {
+	b, _ := json.Marshal(r)
+	return etag(b)
+}
+
+func etag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Preconditions are the conditional request headers of a write request.
+type Preconditions struct {
+	// IfMatch is the value of the If-Match header.
+	IfMatch string
+
+	// IfNoneMatch is the value of the If-None-Match header.
+	IfNoneMatch string
+}
+
+func matchesETag(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns ErrPreconditionFailed if the preconditions do not hold for a resource with the given entity tag.
+// Resources which do not exist have an empty entity tag.
+func (p Preconditions) check(tag string) error {
+	if p.IfMatch != "" && (tag == "" || !matchesETag(p.IfMatch, tag)) {
+		return errors.WithStack(helper.ErrPreconditionFailed.
+			WithReasonf("The If-Match header %s does not match the current entity tag %s.", p.IfMatch, tag))
+	}
+
+	if p.IfNoneMatch != "" && tag != "" && matchesETag(p.IfNoneMatch, tag) {
+		return errors.WithStack(helper.ErrPreconditionFailed.
+			WithReasonf("The If-None-Match header %s matches the current entity tag %s.", p.IfNoneMatch, tag))
+	}
+
+	return nil
+}
+
+// ManagedDiff describes the changes of replacing the managed access rules.
+type ManagedDiff struct {
+	// Created are the IDs of access rules which did not exist.
+	Created []string `json:"created"`
+
+	// Updated are the IDs of access rules which changed.
+	Updated []string `json:"updated"`
+
+	// Deleted are the IDs of access rules which were removed.
+	Deleted []string `json:"deleted"`
+
+	// Unchanged are the IDs of access rules which stayed the same.
+	Unchanged []string `json:"unchanged"`
+}
+
+// ManagedRepository holds the access rules managed through the admin API. They are activated by adding the "api://"
+// repository to the access rule repositories which is reloaded whenever the managed access rules change.
+type ManagedRepository struct {
+	sync.RWMutex
+	rules   map[string]Rule
+	changed chan struct{}
+}
+
+type ManagedRegistry interface {
+	RuleManagedRepository() *ManagedRepository
+}
+
+func NewManagedRepository() *ManagedRepository {
+	return &ManagedRepository{rules: map[string]Rule{}, changed: make(chan struct{})}
+}
+
+// List returns all managed access rules ordered by their ID.
+func (m *ManagedRepository) List() []Rule {
+	m.RLock()
+	defer m.RUnlock()
+	return m.list()
+}
+
+func (m *ManagedRepository) list() []Rule {
+	rules := make([]Rule, 0, len(m.rules))
+	for _, r := range m.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// Get returns the managed access rule or ErrResourceNotFound.
+func (m *ManagedRepository) Get(id string) (*Rule, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	r, ok := m.rules[id]
+	if !ok {
+		return nil, errors.WithStack(helper.ErrResourceNotFound)
+	}
+	return &r, nil
+}
+
+// ETag returns a strong entity tag of all managed access rules.
+func (m *ManagedRepository) ETag() string {
+	m.RLock()
+	defer m.RUnlock()
+	return m.etag()
+}
+
+func (m *ManagedRepository) etag() string {
+	b, _ := json.Marshal(m.list())
+	return etag(b)
+}
+
+// Changed returns a channel which is closed the next time the managed access rules change.
+func (m *ManagedRepository) Changed() <-chan struct{} {
+	m.RLock()
+	defer m.RUnlock()
+	return m.changed
+}
+
+func (m *ManagedRepository) notify() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// Put creates or replaces the access rule with the ID of r and returns true if it was created. Putting an access rule
+// which is already stored is a no-op.
+func (m *ManagedRepository) Put(r Rule, p Preconditions) (created bool, err error) {
+	if r.ID == "" {
+		return false, errors.WithStack(helper.ErrBadRequest.WithReason("The access rule ID must be set."))
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	var tag string
+	current, ok := m.rules[r.ID]
+	if ok {
+		tag = current.ETag()
+	}
+
+	if err := p.check(tag); err != nil {
+		return false, err
+	}
+
+	if ok && tag == r.ETag() {
+		return false, nil
+	}
+
+	m.rules[r.ID] = r
+	m.notify()
+	return !ok, nil
+}
+
+// Delete removes the managed access rule or returns ErrResourceNotFound.
+func (m *ManagedRepository) Delete(id string, p Preconditions) error {
+	m.Lock()
+	defer m.Unlock()
+
+	current, ok := m.rules[id]
+	if !ok {
+		return errors.WithStack(helper.ErrResourceNotFound)
+	}
+
+	if err := p.check(current.ETag()); err != nil {
+		return err
+	}
+
+	delete(m.rules, id)
+	m.notify()
+	return nil
+}
+
+// Replace replaces all managed access rules and returns what changed. The preconditions are checked against the
+// entity tag of all managed access rules. If dryRun is true, the changes are only computed.
+func (m *ManagedRepository) Replace(rules []Rule, p Preconditions, dryRun bool) (*ManagedDiff, error) {
+	next := make(map[string]Rule, len(rules))
+	for _, r := range rules {
+		if r.ID == "" {
+			return nil, errors.WithStack(helper.ErrBadRequest.WithReason("The access rule ID must be set."))
+		}
+		if _, ok := next[r.ID]; ok {
+			return nil, errors.WithStack(helper.ErrBadRequest.WithReasonf("The access rule ID %s is used more than once.", r.ID))
+		}
+		next[r.ID] = r
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if err := p.check(m.etag()); err != nil {
+		return nil, err
+	}
+
+	diff := &ManagedDiff{Created: []string{}, Updated: []string{}, Deleted: []string{}, Unchanged: []string{}}
+	for _, r := range rules {
+		current, ok := m.rules[r.ID]
+		switch {
+		case !ok:
+			diff.Created = append(diff.Created, r.ID)
+		case current.ETag() == r.ETag():
+			diff.Unchanged = append(diff.Unchanged, r.ID)
+		default:
+			diff.Updated = append(diff.Updated, r.ID)
+		}
+	}
+	for id := range m.rules {
+		if _, ok := next[id]; !ok {
+			diff.Deleted = append(diff.Deleted, id)
+		}
+	}
+	sort.Strings(diff.Created)
+	sort.Strings(diff.Updated)
+	sort.Strings(diff.Deleted)
+	sort.Strings(diff.Unchanged)
+
+	if dryRun || len(diff.Created)+len(diff.Updated)+len(diff.Deleted) == 0 {
+		return diff, nil
+	}
+
+	m.rules = next
+	m.notify()
+	return diff, nil
+}
+
+// watchManaged waits for changes of the access rules managed through the admin API.
+func (f *FetcherDefault) watchManaged(ctx context.Context, _ url.URL, changed func()) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-f.r.RuleManagedRepository().Changed():
+			changed()
+		}
+	}
+}
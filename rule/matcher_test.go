@@ -24,7 +24,9 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -183,3 +185,139 @@ func TestMatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestMatcherListeners(t *testing.T) {
+	matcher := NewRepositoryMemory(new(mockRepositoryRegistry))
+	require.NoError(t, matcher.Set(context.Background(), []Rule{
+		{ID: "public", Match: &Match{URL: "https://localhost/public", Methods: []string{"GET"}}},
+		{ID: "internal", Match: &Match{URL: "https://localhost/<.*>", Methods: []string{"GET"}}, Listeners: []string{"internal"}},
+		{ID: "both", Match: &Match{URL: "https://localhost/both", Methods: []string{"GET"}}, Listeners: []string{"default", "internal"}},
+	}))
+
+	for k, tc := range []struct {
+		listener  string
+		url       string
+		expectID  string
+		expectErr bool
+	}{
+		{url: "https://localhost/public", expectID: "public"},
+		{url: "https://localhost/both", expectID: "both"},
+		{url: "https://localhost/internal", expectErr: true},
+		{listener: "default", url: "https://localhost/public", expectID: "public"},
+		{listener: "internal", url: "https://localhost/internal", expectID: "internal"},
+		{listener: "internal", url: "https://localhost/both", expectErr: true}, // matches "internal" and "both"
+		{listener: "unknown", url: "https://localhost/public", expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			ctx := context.Background()
+			if tc.listener != "" {
+				ctx = WithListener(ctx, tc.listener)
+			}
+
+			r, err := matcher.Match(ctx, "GET", mustParseURL(t, tc.url))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectID, r.ID)
+		})
+	}
+}
+
+func TestMatcherExpiredRules(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	registry := new(mockRepositoryRegistry)
+	matcher := NewRepositoryMemory(registry)
+	require.NoError(t, matcher.Set(context.Background(), []Rule{
+		{ID: "expired", Match: &Match{URL: "https://localhost/expired", Methods: []string{"GET"}}, ExpiresAt: &past},
+		{ID: "active", Match: &Match{URL: "https://localhost/active", Methods: []string{"GET"}}, ExpiresAt: &future},
+	}))
+
+	t.Run("case=expired rules match with a warning", func(t *testing.T) {
+		require.NoError(t, matcher.SetExpiredRules(context.Background(), configuration.ExpiredRulesWarn))
+		for i := 0; i < 2; i++ {
+			r, err := matcher.Match(context.Background(), "GET", mustParseURL(t, "https://localhost/expired"))
+			require.NoError(t, err)
+			assert.Equal(t, "expired", r.ID)
+		}
+		assert.Equal(t, 1, registry.loggerCalled, "the warning is only logged once")
+	})
+
+	t.Run("case=expired rules do not match when disabled", func(t *testing.T) {
+		require.NoError(t, matcher.SetExpiredRules(context.Background(), configuration.ExpiredRulesDisable))
+		_, err := matcher.Match(context.Background(), "GET", mustParseURL(t, "https://localhost/expired"))
+		require.Error(t, err)
+
+		r, err := matcher.Match(context.Background(), "GET", mustParseURL(t, "https://localhost/active"))
+		require.NoError(t, err)
+		assert.Equal(t, "active", r.ID)
+	})
+
+	t.Run("case=expired rules are listed", func(t *testing.T) {
+		rules, err := matcher.List(context.Background(), 10, 0)
+		require.NoError(t, err)
+		expired := ExpiredRules(rules, time.Now())
+		require.Len(t, expired, 1)
+		assert.Equal(t, "expired", expired[0].ID)
+		assert.Empty(t, ExpiredRules(rules, past.Add(-time.Minute)))
+	})
+}
+
+func TestMatcherActivationWindow(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	matcher := NewRepositoryMemory(new(mockRepositoryRegistry))
+	require.NoError(t, matcher.Set(context.Background(), []Rule{
+		{ID: "before-launch", Match: &Match{URL: "https://localhost/launch", Methods: []string{"GET"}}, ActiveUntil: &past},
+		{ID: "launch", Match: &Match{URL: "https://localhost/launch", Methods: []string{"GET"}}, ActiveFrom: &past, ActiveUntil: &future},
+		{ID: "after-launch", Match: &Match{URL: "https://localhost/launch", Methods: []string{"GET"}}, ActiveFrom: &future},
+	}))
+
+	r, err := matcher.Match(context.Background(), "GET", mustParseURL(t, "https://localhost/launch"))
+	require.NoError(t, err)
+	assert.Equal(t, "launch", r.ID)
+
+	rl := Rule{ActiveFrom: &past, ActiveUntil: &future}
+	assert.False(t, rl.IsActive(past.Add(-time.Second)))
+	assert.True(t, rl.IsActive(past))
+	assert.True(t, rl.IsActive(time.Now()))
+	assert.False(t, rl.IsActive(future))
+	assert.True(t, new(Rule).IsActive(time.Now()))
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	for _, strategy := range []configuration.MatchingStrategy{configuration.Regexp, configuration.Glob} {
+		b.Run("strategy="+string(strategy), func(b *testing.B) {
+			rules := make([]Rule, 0, 100)
+			for i := 0; i < 50; i++ {
+				rules = append(rules,
+					Rule{ID: fmt.Sprintf("static-%d", i), Match: &Match{URL: fmt.Sprintf("https://localhost/static/%d", i), Methods: []string{"GET"}}},
+					Rule{ID: fmt.Sprintf("pattern-%d", i), Match: &Match{URL: fmt.Sprintf("https://localhost/pattern/%d/<*>", i), Methods: []string{"GET"}}},
+				)
+			}
+			if strategy == configuration.Regexp {
+				for k := range rules {
+					rules[k].Match.URL = strings.Replace(rules[k].Match.URL, "<*>", "<.*>", 1)
+				}
+			}
+
+			matcher := NewRepositoryMemory(new(mockRepositoryRegistry))
+			require.NoError(b, matcher.SetMatchingStrategy(context.Background(), strategy))
+			matcher.WithRules(rules)
+
+			for _, path := range []string{"/static/42", "/pattern/42/users"} {
+				u, err := url.Parse("https://localhost" + path)
+				require.NoError(b, err)
+
+				b.Run("path="+path, func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						if _, err := matcher.Match(context.Background(), "GET", u); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/helper"
+)
+
+var (
+	openAPIMethods       = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"}
+	openAPIPathParameter = regexp.MustCompile(`\{[^}/]+\}`)
+	openAPIInvalidIDChar = regexp.MustCompile(`[^a-zA-Z0-9_.:-]+`)
+)
+
+// OpenAPIOptions configures how access rules are generated from an OpenAPI document.
+type OpenAPIOptions struct {
+	// BaseURL is the URL the API is served at, for example "https://api.example.com/v1". If empty, the first server
+	// of an OpenAPI 3 document or the host and base path of a Swagger 2 document are used.
+	BaseURL string
+
+	// Upstream is the URL requests are forwarded to.
+	Upstream string
+
+	// MatchingStrategy is the matching strategy the rules are generated for. Defaults to regexp.
+	MatchingStrategy configuration.MatchingStrategy
+
+	// IDPrefix is prepended to the ID of each rule.
+	IDPrefix string
+
+	// Authorizer is the authorizer of each rule. Defaults to "allow".
+	Authorizer string
+
+	// Mutators are the mutators of each rule. Defaults to "noop".
+	Mutators []string
+
+	// Authenticators maps security schemes to authenticators, overriding the default mapping.
+	Authenticators map[string]string
+}
+
+type openAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat"`
+	In           string `json:"in"`
+	Name         string `json:"name"`
+}
+
+type openAPIOperation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary"`
+	Security    *[]map[string][]string `json:"security"`
+}
+
+type openAPIDocument struct {
+	Swagger  string   `json:"swagger"`
+	Host     string   `json:"host"`
+	BasePath string   `json:"basePath"`
+	Schemes  []string `json:"schemes"`
+	Servers  []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Security            []map[string][]string            `json:"security"`
+	SecurityDefinitions map[string]openAPISecurityScheme `json:"securityDefinitions"`
+	Components          struct {
+		SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+	} `json:"components"`
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// GenerateFromOpenAPI generates an access rule for each operation of an OpenAPI 3 or Swagger 2 document in JSON or
+// YAML format. Path templates become matchers and security requirements become authenticators. Operations without
+// security requirements use the anonymous authenticator, operations whose security schemes can not be mapped use the
+// unauthorized authenticator so that they stay closed. Warnings describe everything which could not be mapped.
+func GenerateFromOpenAPI(document []byte, o OpenAPIOptions) (rules []Rule, warnings []string, err error) {
+	raw, err := yaml.YAMLToJSON(document)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	if o.Upstream == "" {
+		return nil, nil, errors.New("the upstream URL must be set")
+	}
+
+	base := strings.TrimRight(o.BaseURL, "/")
+	if base == "" {
+		base, err = doc.baseURL()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	schemes := doc.Components.SecuritySchemes
+	if doc.Swagger != "" {
+		schemes = doc.SecurityDefinitions
+	}
+
+	authorizer := o.Authorizer
+	if authorizer == "" {
+		authorizer = "allow"
+	}
+
+	mutators := o.Mutators
+	if len(mutators) == 0 {
+		mutators = []string{"noop"}
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	ids := map[string]bool{}
+	for _, path := range paths {
+		for _, method := range openAPIMethods {
+			rawOperation, ok := doc.Paths[path][strings.ToLower(method)]
+			if !ok {
+				continue
+			}
+
+			var op openAPIOperation
+			if err := json.Unmarshal(rawOperation, &op); err != nil {
+				return nil, nil, errors.Wrapf(err, "unable to decode operation %s %s", method, path)
+			}
+
+			id := op.OperationID
+			if id == "" {
+				id = strings.ToLower(method) + "-" + strings.Trim(openAPIInvalidIDChar.ReplaceAllString(path, "-"), "-")
+			}
+			id = o.IDPrefix + id
+			if ids[id] {
+				return nil, nil, errors.Errorf("access rule ID %s is used more than once", id)
+			}
+			ids[id] = true
+
+			security := doc.Security
+			if op.Security != nil {
+				security = *op.Security
+			}
+
+			authenticators, w := openAPIAuthenticators(schemes, security, o.Authenticators)
+			for _, warning := range w {
+				warnings = append(warnings, fmt.Sprintf("%s %s: %s", method, path, warning))
+			}
+
+			r := Rule{
+				ID:             id,
+				Description:    op.Summary,
+				Match:          &Match{Methods: []string{method}, URL: openAPIMatcher(base+path, o.MatchingStrategy)},
+				Authenticators: authenticators,
+				Authorizer:     Handler{Handler: authorizer},
+				Upstream:       Upstream{URL: o.Upstream},
+			}
+			for _, m := range mutators {
+				r.Mutators = append(r.Mutators, Handler{Handler: m})
+			}
+			rules = append(rules, r)
+		}
+	}
+
+	return rules, warnings, nil
+}
+
+func (doc *openAPIDocument) baseURL() (string, error) {
+	if doc.Swagger != "" {
+		if doc.Host == "" {
+			return "", errors.New("the document does not define a host, the base URL must be set")
+		}
+		scheme := "https"
+		if len(doc.Schemes) > 0 {
+			scheme = doc.Schemes[0]
+		}
+		return scheme + "://" + doc.Host + strings.TrimRight(doc.BasePath, "/"), nil
+	}
+
+	if len(doc.Servers) == 0 || !strings.Contains(doc.Servers[0].URL, "://") {
+		return "", errors.New("the document does not define an absolute server URL, the base URL must be set")
+	}
+	return strings.TrimRight(doc.Servers[0].URL, "/"), nil
+}
+
+// openAPIMatcher converts a URL template such as "https://api.example.com/users/{id}" to a matcher of the matching
+// strategy.
+func openAPIMatcher(template string, strategy configuration.MatchingStrategy) string {
+	parameter, quote := "<[^/]+>", regexp.QuoteMeta
+	if strategy == configuration.Glob {
+		parameter, quote = "<*>", func(s string) string {
+			return strings.NewReplacer("*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`, "{", `\{`, "}", `\}`).Replace(s)
+		}
+	}
+
+	var matcher strings.Builder
+	last := 0
+	for _, loc := range openAPIPathParameter.FindAllStringIndex(template, -1) {
+		matcher.WriteString(quote(template[last:loc[0]]))
+		matcher.WriteString(parameter)
+		last = loc[1]
+	}
+	matcher.WriteString(quote(template[last:]))
+	return matcher.String()
+}
+
+// openAPIAuthenticators maps security requirements to authenticators. Each requirement is an alternative, so each
+// becomes one authenticator.
+func openAPIAuthenticators(schemes map[string]openAPISecurityScheme, security []map[string][]string, overrides map[string]string) (authenticators []Handler, warnings []string) {
+	if len(security) == 0 {
+		return []Handler{{Handler: "anonymous"}}, nil
+	}
+
+	var optional bool
+	for _, requirement := range security {
+		if len(requirement) == 0 {
+			// An empty requirement makes security optional.
+			optional = true
+			continue
+		}
+
+		names := make([]string, 0, len(requirement))
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if len(names) > 1 {
+			warnings = append(warnings, fmt.Sprintf("security schemes %v must all be satisfied which access rules can not express, only %s is used", names, names[0]))
+		}
+
+		name := names[0]
+		scheme, ok := schemes[name]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("security scheme %s is not defined", name))
+			continue
+		}
+
+		handler, ok := overrides[name]
+		if !ok {
+			handler = openAPIDefaultAuthenticator(scheme)
+		}
+		if handler == "" {
+			warnings = append(warnings, fmt.Sprintf("security scheme %s of type %s can not be mapped to an authenticator", name, scheme.Type))
+			continue
+		}
+
+		config := map[string]interface{}{}
+		if scopes := requirement[name]; len(scopes) > 0 && (handler == "jwt" || handler == "oauth2_introspection") {
+			config["required_scope"] = scopes
+		}
+		if scheme.Type == "apiKey" && handler != "cookie_session" {
+			switch scheme.In {
+			case "header":
+				config["token_from"] = helper.BearerTokenLocation{Header: &scheme.Name}
+			case "query":
+				config["token_from"] = helper.BearerTokenLocation{QueryParameter: &scheme.Name}
+			}
+		}
+
+		h := Handler{Handler: handler}
+		if len(config) > 0 {
+			h.Config, _ = json.Marshal(config)
+		}
+		authenticators = append(authenticators, h)
+	}
+
+	if optional {
+		// The anonymous authenticator is responsible for every request, so it must come last.
+		authenticators = append(authenticators, Handler{Handler: "anonymous"})
+	} else if len(authenticators) == 0 {
+		return []Handler{{Handler: "unauthorized"}}, warnings
+	}
+	return authenticators, warnings
+}
+
+func openAPIDefaultAuthenticator(s openAPISecurityScheme) string {
+	switch {
+	case s.Type == "http" && strings.EqualFold(s.Scheme, "bearer") && strings.EqualFold(s.BearerFormat, "jwt"):
+		return "jwt"
+	case s.Type == "http" && strings.EqualFold(s.Scheme, "bearer"):
+		return "oauth2_introspection"
+	case s.Type == "oauth2":
+		return "oauth2_introspection"
+	case s.Type == "openIdConnect":
+		return "jwt"
+	case s.Type == "apiKey" && s.In == "cookie":
+		return "cookie_session"
+	}
+	return ""
+}
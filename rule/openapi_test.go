@@ -0,0 +1,119 @@
+package rule
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+)
+
+const openAPIDocumentYAML = `
+openapi: 3.0.0
+servers:
+  - url: https://api.example.com/v1
+security:
+  - bearer: []
+components:
+  securitySchemes:
+    bearer:
+      type: http
+      scheme: bearer
+      bearerFormat: JWT
+    oauth:
+      type: oauth2
+    basic:
+      type: http
+      scheme: basic
+    api_key:
+      type: apiKey
+      in: header
+      name: X-Api-Key
+paths:
+  /users/{id}:
+    get:
+      operationId: getUser
+      summary: Returns a user
+    delete:
+      security:
+        - oauth: [users.delete]
+  /health:
+    get:
+      operationId: health
+      security: []
+  /legacy:
+    get:
+      operationId: legacy
+      security:
+        - basic: []
+  /reports:
+    get:
+      operationId: reports
+      security:
+        - api_key: []
+        - {}
+`
+
+func TestGenerateFromOpenAPI(t *testing.T) {
+	t.Run("case=openapi 3", func(t *testing.T) {
+		rules, warnings, err := GenerateFromOpenAPI([]byte(openAPIDocumentYAML), OpenAPIOptions{
+			Upstream:       "http://backend",
+			IDPrefix:       "api:",
+			Authenticators: map[string]string{"api_key": "oauth2_introspection"},
+		})
+		require.NoError(t, err)
+
+		byID := map[string]Rule{}
+		for _, r := range rules {
+			byID[r.ID] = r
+			assert.Equal(t, "http://backend", r.Upstream.URL)
+			assert.Equal(t, "allow", r.Authorizer.Handler)
+			assert.Equal(t, "noop", r.Mutators[0].Handler)
+		}
+		require.Len(t, byID, 5)
+
+		r := byID["api:getUser"]
+		assert.Equal(t, []string{"GET"}, r.Match.Methods)
+		assert.Equal(t, `https://api\.example\.com/v1/users/<[^/]+>`, r.Match.URL)
+		assert.Equal(t, "Returns a user", r.Description)
+		assert.Equal(t, "jwt", r.Authenticators[0].Handler)
+
+		matches, err := r.IsMatching(configuration.Regexp, "GET", &url.URL{Scheme: "https", Host: "api.example.com", Path: "/v1/users/1234"})
+		require.NoError(t, err)
+		assert.True(t, matches)
+
+		r = byID["api:delete-users-id"]
+		assert.Equal(t, "oauth2_introspection", r.Authenticators[0].Handler)
+		assert.JSONEq(t, `{"required_scope":["users.delete"]}`, string(r.Authenticators[0].Config))
+
+		assert.Equal(t, "anonymous", byID["api:health"].Authenticators[0].Handler)
+		assert.Equal(t, "unauthorized", byID["api:legacy"].Authenticators[0].Handler)
+
+		r = byID["api:reports"]
+		require.Len(t, r.Authenticators, 2)
+		assert.JSONEq(t, `{"token_from":{"header":"X-Api-Key","query_parameter":null,"cookie":null}}`, string(r.Authenticators[0].Config))
+		assert.Equal(t, "anonymous", r.Authenticators[1].Handler)
+
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "GET /legacy: security scheme basic of type http can not be mapped")
+	})
+
+	t.Run("case=swagger 2 and glob", func(t *testing.T) {
+		rules, _, err := GenerateFromOpenAPI([]byte(`{"swagger":"2.0","host":"api.example.com","basePath":"/v2","schemes":["http"],"paths":{"/pets/{petId}":{"put":{}}}}`), OpenAPIOptions{
+			Upstream:         "http://backend",
+			MatchingStrategy: configuration.Glob,
+		})
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, "put-pets-petId", rules[0].ID)
+		assert.Equal(t, "http://api.example.com/v2/pets/<*>", rules[0].Match.URL)
+		assert.Equal(t, "anonymous", rules[0].Authenticators[0].Handler)
+	})
+
+	t.Run("case=missing base url", func(t *testing.T) {
+		_, _, err := GenerateFromOpenAPI([]byte(`{"openapi":"3.0.0","servers":[{"url":"/v1"}],"paths":{}}`), OpenAPIOptions{Upstream: "http://backend"})
+		require.Error(t, err)
+	})
+}
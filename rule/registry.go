@@ -2,6 +2,7 @@ package rule
 
 type Registry interface {
 	RuleValidator() Validator
+	RuleStager() Stager
 	RuleFetcher() Fetcher
 	RuleRepository() Repository
 	RuleMatcher() Matcher
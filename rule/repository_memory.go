@@ -24,6 +24,7 @@ import (
 	"context"
 	"net/url"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -47,7 +48,25 @@ type RepositoryMemory struct {
 	sync.RWMutex
 	rules            []Rule
 	matchingStrategy configuration.MatchingStrategy
+	expiredRules     string
 	r                repositoryMemoryRegistry
+	activated        bool
+
+	// expiryWarned are the IDs of the expired rules a warning was logged for since the rules were set.
+	expiryWarned map[string]bool
+}
+
+// ErrRulesNotActivated is returned by RepositoryMemory.ReadyChecker if no rule set has been activated yet.
+var ErrRulesNotActivated = errors.New("no access rules have been activated yet")
+
+// ReadyChecker returns an error if no rule set has been activated yet.
+func (m *RepositoryMemory) ReadyChecker() error {
+	m.RLock()
+	defer m.RUnlock()
+	if !m.activated {
+		return errors.WithStack(ErrRulesNotActivated)
+	}
+	return nil
 }
 
 // MatchingStrategy returns current MatchingStrategy.
@@ -65,10 +84,19 @@ func (m *RepositoryMemory) SetMatchingStrategy(_ context.Context, ms configurati
 	return nil
 }
 
+// SetExpiredRules updates how expired rules are handled, either "warn" or "disable".
+func (m *RepositoryMemory) SetExpiredRules(_ context.Context, mode string) error {
+	m.Lock()
+	defer m.Unlock()
+	m.expiredRules = mode
+	return nil
+}
+
 func NewRepositoryMemory(r repositoryMemoryRegistry) *RepositoryMemory {
 	return &RepositoryMemory{
-		r:     r,
-		rules: make([]Rule, 0),
+		r:            r,
+		rules:        make([]Rule, 0),
+		expiryWarned: map[string]bool{},
 	}
 }
 
@@ -76,6 +104,7 @@ func NewRepositoryMemory(r repositoryMemoryRegistry) *RepositoryMemory {
 func (m *RepositoryMemory) WithRules(rules []Rule) {
 	m.Lock()
 	m.rules = rules
+	m.expiryWarned = map[string]bool{}
 	m.Unlock()
 }
 
@@ -114,34 +143,77 @@ func (m *RepositoryMemory) Set(ctx context.Context, rules []Rule) error {
 				WithField("rule_id", check.ID).
 				Errorf("A Rule uses a malformed configuration and all URLs matching this rule will not work. You should resolve this issue now.")
 		}
+
+		for _, w := range m.r.RuleValidator().Warnings(&check) {
+			m.r.Logger().
+				WithField("rule_id", w.Rule).
+				WithField("field", w.Field).
+				Warnf("An access rule has a problem which does not prevent it from being used: %s", w.Message)
+		}
 	}
 
 	m.Lock()
 	m.rules = rules
+	m.activated = true
+	m.expiryWarned = map[string]bool{}
 	m.Unlock()
 	return nil
 }
 
-func (m *RepositoryMemory) Match(_ context.Context, method string, u *url.URL) (*Rule, error) {
+func (m *RepositoryMemory) Match(ctx context.Context, method string, u *url.URL) (*Rule, error) {
 	m.Lock()
 	defer m.Unlock()
 
-	var rules []Rule
+	listener := ListenerFromContext(ctx)
+	against := matchURL(u)
+	now := time.Now()
+
+	var matched *Rule
+	var count int
 	for k := range m.rules {
 		r := &m.rules[k]
-		if matched, err := r.IsMatching(m.matchingStrategy, method, u); err != nil {
+		if !r.IsServedBy(listener) || !r.IsActive(now) {
+			continue
+		}
+
+		expired := r.Expired(now)
+		if expired && m.expiredRules == configuration.ExpiredRulesDisable {
+			continue
+		}
+
+		if ok, err := r.isMatching(m.matchingStrategy, method, against); err != nil {
 			return nil, errors.WithStack(err)
-		} else if matched {
-			rules = append(rules, *r)
+		} else if ok {
+			if count == 0 {
+				matched = r
+			}
+			count++
+			if expired {
+				m.warnExpired(r)
+			}
 		}
-		m.rules[k] = *r
 	}
 
-	if len(rules) == 0 {
+	if count == 0 {
 		return nil, errors.WithStack(helper.ErrMatchesNoRule)
-	} else if len(rules) != 1 {
+	} else if count != 1 {
 		return nil, errors.WithStack(helper.ErrMatchesMoreThanOneRule)
 	}
 
-	return &rules[0], nil
+	rl := *matched
+	return &rl, nil
+}
+
+// warnExpired logs a warning the first time an expired rule matches a request after the rules were set. The caller
+// must hold the lock.
+func (m *RepositoryMemory) warnExpired(r *Rule) {
+	if m.expiryWarned[r.ID] {
+		return
+	}
+	m.expiryWarned[r.ID] = true
+
+	m.r.Logger().
+		WithField("rule_id", r.ID).
+		WithField("expires_at", r.ExpiresAt.UTC().Format(time.RFC3339)).
+		Warn("An expired access rule matched a request. Remove the access rule, extend its expiry date, or set access_rules.expired_rules to disable to stop matching expired access rules.")
 }
@@ -22,13 +22,14 @@ package rule
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/x"
 )
 
 type Match struct {
@@ -60,6 +61,24 @@ type Handler struct {
 	// Config contains the configuration for the handler. Please read the user
 	// guide for a complete list of each handler's available settings.
 	Config json.RawMessage `json:"config"`
+
+	// Condition is a Go template which is evaluated against the authentication session and the request. If set,
+	// the handler only runs if the condition renders "true". Conditions are only supported by mutators.
+	Condition string `json:"condition,omitempty"`
+
+	// When, if set, restricts the handler to requests matching at least one of the predicates. Predicates are only
+	// supported by authenticators.
+	When []HandlerWhen `json:"when,omitempty"`
+}
+
+// HandlerWhen is a predicate over the request. All fields which are set must match.
+type HandlerWhen struct {
+	// Path is a regular expression the path of the request URL must match.
+	Path string `json:"path,omitempty"`
+
+	// Header maps header names to regular expressions the header values must match. Missing headers have an
+	// empty value.
+	Header map[string]string `json:"header,omitempty"`
 }
 
 type ErrorHandler struct {
@@ -92,6 +111,27 @@ type Rule struct {
 	// Description is a human readable description of this rule.
 	Description string `json:"description"`
 
+	// Owner is the team or person owning this rule, for example "payments". It is added to the decision events and
+	// metrics of requests matching this rule.
+	Owner string `json:"owner,omitempty"`
+
+	// Metadata is free-form information about this rule.
+	Metadata *Metadata `json:"metadata,omitempty"`
+
+	// DocsURL, if set, is the URL of the documentation of this rule, for example a runbook. Error responses to requests
+	// denied by this rule link to it.
+	DocsURL string `json:"docs_url,omitempty"`
+
+	// ExpiresAt, if set, is the time this rule expires at. Depending on the configuration, expired rules either keep
+	// matching requests with a warning or stop matching requests.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// ActiveFrom, if set, is the time this rule starts matching requests at.
+	ActiveFrom *time.Time `json:"active_from,omitempty"`
+
+	// ActiveUntil, if set, is the time this rule stops matching requests at.
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+
 	// Match defines the URL that this rule should match.
 	Match *Match `json:"match"`
 
@@ -121,7 +161,185 @@ type Rule struct {
 	// Upstream is the location of the server where requests matching this rule should be forwarded to.
 	Upstream Upstream `json:"upstream"`
 
+	// Listeners is a list of proxy listener names (see `serve.proxy.listeners`) which serve this rule. If empty,
+	// the rule is only served by the default proxy listener (`serve.proxy`).
+	Listeners []string `json:"listeners,omitempty"`
+
+	// Observability, if set, overrides the log level and the trace sampling for requests matching this rule and
+	// declares its service level objectives.
+	Observability *Observability `json:"observability,omitempty"`
+
+	// Risk, if set, scores the risk of authenticated requests matching this rule before they are authorized.
+	Risk *Risk `json:"risk,omitempty"`
+
+	// Tarpit, if set, delays responses denying requests matching this rule.
+	Tarpit *Tarpit `json:"tarpit,omitempty"`
+
+	// Honeypot, if true, sends an alert whenever a request matches this rule, regardless of whether it is granted.
+	Honeypot bool `json:"honeypot,omitempty"`
+
+	// Priority is the priority of requests matching this rule when the service is overloaded, either "low", "normal"
+	// (default) or "critical". Requests of rules with a lower priority are shed first, requests of critical rules are
+	// never shed.
+	Priority string `json:"priority,omitempty"`
+
+	// RequestValidation, if set, rejects malformed requests matching this rule before they are authenticated.
+	RequestValidation *RequestValidation `json:"request_validation,omitempty"`
+
+	// SecurityHeaders, if set, overrides the security headers the proxy listener adds to responses to requests
+	// matching this rule.
+	SecurityHeaders *SecurityHeaders `json:"security_headers,omitempty"`
+
 	matchingEngine MatchingEngine
+
+	// unknownFields are the paths of the fields of the decoded rule which are not known, they are rejected in strict
+	// mode.
+	unknownFields []string
+
+	// deprecations are the deprecated fields which were migrated when the rule was decoded.
+	deprecations []Warning
+}
+
+// Metadata is free-form information about a rule.
+type Metadata struct {
+	// Labels are key-value pairs rules can be selected by, for example "tier: critical". Labels are added to the
+	// decision events of requests matching the rule.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Labels returns the labels of the rule, if any.
+func (r *Rule) Labels() map[string]string {
+	if r.Metadata == nil {
+		return nil
+	}
+	return r.Metadata.Labels
+}
+
+// Expired returns true if the rule has an expiry date which is not after now.
+func (r *Rule) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// IsActive returns true if now is within the activation window of the rule, which starts at ActiveFrom and ends right
+// before ActiveUntil. Rules without an activation window are always active.
+func (r *Rule) IsActive(now time.Time) bool {
+	if r.ActiveFrom != nil && now.Before(*r.ActiveFrom) {
+		return false
+	}
+	return r.ActiveUntil == nil || now.Before(*r.ActiveUntil)
+}
+
+// Observability overrides the log level and the trace sampling for requests matching a rule.
+type Observability struct {
+	// LogLevel is the log level used when handling requests matching the rule, for example "debug".
+	LogLevel string `json:"log_level,omitempty"`
+
+	// TraceSampling is the probability, between 0 and 1, that requests matching the rule are traced. It overrides
+	// the sampling decision of the tracer.
+	TraceSampling *float64 `json:"trace_sampling,omitempty"`
+
+	// SLO, if set, declares the service level objectives of the rule. The metrics sink reports the service level
+	// indicators, burn rates and remaining error budgets of the objectives.
+	SLO *SLO `json:"slo,omitempty"`
+}
+
+// SLO are the service level objectives of a rule.
+type SLO struct {
+	// Availability is the share, between 0 and 1, of requests which must not fail with a 5xx status code, for
+	// example 0.999.
+	Availability *float64 `json:"availability,omitempty"`
+
+	// Latency is the time within which ORY Oathkeeper must reach LatencyTarget of its decisions, for example "50ms".
+	Latency string `json:"latency,omitempty"`
+
+	// LatencyTarget is the share, between 0 and 1, of decisions which must be reached within Latency, for example
+	// 0.99.
+	LatencyTarget *float64 `json:"latency_target,omitempty"`
+}
+
+// Risk configures the risk stage for requests matching a rule. Unset thresholds default to the global configuration.
+type Risk struct {
+	// DenyAbove denies requests with a higher risk score.
+	DenyAbove *float64 `json:"deny_above,omitempty"`
+
+	// StepUpAbove requires step-up authentication for requests with a higher risk score.
+	StepUpAbove *float64 `json:"step_up_above,omitempty"`
+}
+
+// Tarpit delays 401 and 403 responses of a rule by a random interval to slow down credential stuffing and
+// enumeration attacks.
+type Tarpit struct {
+	// MinDelay is the minimum delay, for example "500ms".
+	MinDelay string `json:"min_delay"`
+
+	// MaxDelay is the maximum delay, for example "3s".
+	MaxDelay string `json:"max_delay"`
+}
+
+// SecurityHeaders overrides the security headers of the proxy listener. Unset values default to the configuration of
+// the listener, empty values remove the respective header.
+type SecurityHeaders struct {
+	// Profile, if set, replaces the headers of the listener with the headers of the profile, either "none" or
+	// "strict".
+	Profile string `json:"profile,omitempty"`
+
+	StrictTransportSecurity *string `json:"strict_transport_security,omitempty"`
+	ContentTypeOptions      *string `json:"content_type_options,omitempty"`
+	ReferrerPolicy          *string `json:"referrer_policy,omitempty"`
+	FrameOptions            *string `json:"frame_options,omitempty"`
+}
+
+// RequestValidation limits the requests matching a rule. Zero values disable the respective limit.
+type RequestValidation struct {
+	// MaxHeaderCount is the maximum number of header values.
+	MaxHeaderCount int `json:"max_header_count,omitempty"`
+
+	// MaxHeaderSize is the maximum size of all header names and values in bytes.
+	MaxHeaderSize int `json:"max_header_size,omitempty"`
+
+	// MaxURLLength is the maximum length of the request URI including the query.
+	MaxURLLength int `json:"max_url_length,omitempty"`
+
+	// DisallowedMethods are HTTP methods which are rejected even if the rule matches them.
+	DisallowedMethods []string `json:"disallowed_methods,omitempty"`
+
+	// AllowedContentTypes are the media types, for example "application/json" or "text/*", requests with a body
+	// may have.
+	AllowedContentTypes []string `json:"allowed_content_types,omitempty"`
+
+	// RejectNullBytes rejects requests with null bytes in the URL or in headers.
+	RejectNullBytes bool `json:"reject_null_bytes,omitempty"`
+
+	// RejectPathTraversal rejects requests whose path contains ".." segments, also if they are encoded.
+	RejectPathTraversal bool `json:"reject_path_traversal,omitempty"`
+}
+
+// Delays parses the minimum and the maximum delay.
+func (t *Tarpit) Delays() (min, max time.Duration, err error) {
+	if t.MinDelay != "" {
+		if min, err = time.ParseDuration(t.MinDelay); err != nil {
+			return 0, 0, errors.WithStack(err)
+		}
+	}
+
+	if max, err = time.ParseDuration(t.MaxDelay); err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+
+	return min, max, nil
+}
+
+// LatencyThreshold parses the latency of the objective. It returns 0 if no latency objective is set.
+func (s *SLO) LatencyThreshold() (time.Duration, error) {
+	if s.Latency == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s.Latency)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return d, nil
 }
 
 type Upstream struct {
@@ -129,30 +347,195 @@ type Upstream struct {
 	// hostname of the API's upstream's URL. Setting this flag to true instructs ORY Oathkeeper not to do so.
 	PreserveHost bool `json:"preserve_host"`
 
+	// Host, if set, is a Go template rendering the upstream request's Host header, for example
+	// "{{ printIndex .MatchContext.RegexpCaptureGroups 0 }}.internal". It may not be used with PreserveHost.
+	Host string `json:"host,omitempty"`
+
 	// StripPath if set, replaces the provided path prefix when forwarding the requested URL to the upstream URL.
 	StripPath string `json:"strip_path"`
 
 	// URL is the URL the request will be proxied to.
 	URL string `json:"url"`
+
+	// Credentials, if set, instructs ORY Oathkeeper to authenticate the request it forwards to the upstream.
+	Credentials *UpstreamCredentials `json:"credentials,omitempty"`
+
+	// AuthorizationHeader, if set, controls what happens to the Authorization header of the original request
+	// once all mutators have run.
+	AuthorizationHeader *UpstreamAuthorizationHeader `json:"authorization_header,omitempty"`
+
+	// ErrorResponse, if set, is sent instead of the response of the error handlers if the upstream can not be
+	// reached.
+	ErrorResponse *UpstreamErrorResponse `json:"error_response,omitempty"`
+
+	// Fallback, if set, is used if the upstream can not be reached. It takes precedence over ErrorResponse.
+	Fallback *UpstreamFallback `json:"fallback,omitempty"`
+
+	// Headers, if set, restricts which headers of the incoming request are forwarded to the upstream. Headers set
+	// by mutators are always forwarded.
+	Headers *UpstreamHeaders `json:"headers,omitempty"`
+
+	// StripAuthCookies, if true, removes the cookies the authenticators read credentials from, e.g. the session
+	// cookies of the cookie_session authenticator, from the request forwarded to the upstream.
+	StripAuthCookies bool `json:"strip_auth_cookies,omitempty"`
+}
+
+// UpstreamHeaders filters the headers of the incoming request. Names are case-insensitive and names ending with "*"
+// match all headers with that prefix, for example "X-Internal-*".
+type UpstreamHeaders struct {
+	// Allow, if not empty, are the only headers which are forwarded.
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny are headers which are never forwarded, even if they are allowed.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// UpstreamFallback degrades gracefully if the upstream can not be reached. The fallback upstream is tried first, the
+// static response is sent if it can not be reached either.
+type UpstreamFallback struct {
+	// URL, if set, is the upstream requests without a body are sent to instead.
+	URL string `json:"url,omitempty"`
+
+	// Response, if set, is sent as is.
+	Response *UpstreamFallbackResponse `json:"response,omitempty"`
+}
+
+// UpstreamFallbackResponse is a static response.
+type UpstreamFallbackResponse struct {
+	// StatusCode defaults to 200.
+	StatusCode int `json:"status_code,omitempty"`
+
+	// ContentType defaults to "application/json".
+	ContentType string `json:"content_type,omitempty"`
+
+	Body string `json:"body"`
+}
+
+// UpstreamErrorResponse is the response sent if the upstream can not be reached.
+type UpstreamErrorResponse struct {
+	// StatusCode overrides the status code, which is 504 if the upstream timed out and 502 otherwise.
+	StatusCode int `json:"status_code,omitempty"`
+
+	// ContentType is the content type of the body, defaults to "text/html; charset=utf-8".
+	ContentType string `json:"content_type,omitempty"`
+
+	// Body is a Go template rendering the body. It has access to `.Reason` ("connect", "timeout", "tls", or
+	// "unknown"), `.StatusCode`, and `.RuleID`.
+	Body string `json:"body"`
+}
+
+const (
+	// AuthorizationHeaderKeep forwards the original Authorization header and ignores the one set by mutators.
+	AuthorizationHeaderKeep = "keep"
+
+	// AuthorizationHeaderStrip forwards no Authorization header at all.
+	AuthorizationHeaderStrip = "strip"
+
+	// AuthorizationHeaderMove forwards the original Authorization header in another header and the Authorization
+	// header set by mutators, if any, as the Authorization header.
+	AuthorizationHeaderMove = "move"
+
+	// AuthorizationHeaderReplace forwards the Authorization header set by mutators, if any, and never the
+	// original one.
+	AuthorizationHeaderReplace = "replace"
+
+	// DefaultAuthorizationHeaderMoveTo is the header the original Authorization header is moved to by default.
+	DefaultAuthorizationHeaderMoveTo = "X-Original-Authorization"
+)
+
+// UpstreamAuthorizationHeader configures what happens to the Authorization header of the original request.
+type UpstreamAuthorizationHeader struct {
+	// Mode is one of "keep", "strip", "move", or "replace".
+	Mode string `json:"mode"`
+
+	// Header is the header the original Authorization header is moved to if Mode is "move". Defaults to
+	// "X-Original-Authorization".
+	Header string `json:"header,omitempty"`
+}
+
+// UpstreamCredentials configures how ORY Oathkeeper authenticates against the upstream. Only one of its
+// fields may be set.
+type UpstreamCredentials struct {
+	// AWSSigV4 signs the request using AWS Signature Version 4.
+	AWSSigV4 *UpstreamAWSSigV4 `json:"aws_sigv4,omitempty"`
+
+	// OAuth2ClientCredentials attaches an access token obtained using the OAuth 2.0 Client Credentials Grant.
+	OAuth2ClientCredentials *UpstreamOAuth2ClientCredentials `json:"oauth2_client_credentials,omitempty"`
+}
+
+// UpstreamAWSSigV4 configures AWS Signature Version 4 request signing.
+type UpstreamAWSSigV4 struct {
+	// Region is the AWS region of the upstream, for example "eu-central-1".
+	Region string `json:"region"`
+
+	// Service is the AWS service name of the upstream, for example "execute-api" or "s3".
+	Service string `json:"service"`
+
+	// AccessKeyID defaults to the value of the AWS_ACCESS_KEY_ID environment variable.
+	AccessKeyID string `json:"access_key_id,omitempty"`
+
+	// SecretAccessKey defaults to the value of the AWS_SECRET_ACCESS_KEY environment variable.
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+
+	// SessionToken defaults to the value of the AWS_SESSION_TOKEN environment variable.
+	SessionToken string `json:"session_token,omitempty"`
+
+	// UnsignedPayload, if true, does not include the request body in the signature which avoids
+	// buffering the body in memory. This is only supported by some services, for example S3.
+	UnsignedPayload bool `json:"unsigned_payload,omitempty"`
+}
+
+// UpstreamOAuth2ClientCredentials configures the OAuth 2.0 Client Credentials Grant.
+type UpstreamOAuth2ClientCredentials struct {
+	// TokenURL is the OAuth 2.0 Token Endpoint.
+	TokenURL string `json:"token_url"`
+
+	// ClientID is the OAuth 2.0 Client ID.
+	ClientID string `json:"client_id"`
+
+	// ClientSecret is the OAuth 2.0 Client Secret.
+	ClientSecret string `json:"client_secret"`
+
+	// Scopes are the OAuth 2.0 Scopes requested.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Audience, if set, is sent as the "audience" parameter of the token request.
+	Audience string `json:"audience,omitempty"`
 }
 
 var _ json.Unmarshaler = new(Rule)
 
 func (r *Rule) UnmarshalJSON(raw []byte) error {
 	var rr struct {
-		ID             string         `json:"id"`
-		Version        string         `json:"version"`
-		Description    string         `json:"description"`
-		Match          *Match         `json:"match"`
-		Authenticators []Handler      `json:"authenticators"`
-		Authorizer     Handler        `json:"authorizer"`
-		Mutators       []Handler      `json:"mutators"`
-		Errors         []ErrorHandler `json:"errors"`
-		Upstream       Upstream       `json:"upstream"`
-		matchingEngine MatchingEngine
-	}
-
-	transformed, err := migrateRuleJSON(raw)
+		ID                string             `json:"id"`
+		Version           string             `json:"version"`
+		Description       string             `json:"description"`
+		Owner             string             `json:"owner,omitempty"`
+		Metadata          *Metadata          `json:"metadata,omitempty"`
+		DocsURL           string             `json:"docs_url,omitempty"`
+		ExpiresAt         *time.Time         `json:"expires_at,omitempty"`
+		ActiveFrom        *time.Time         `json:"active_from,omitempty"`
+		ActiveUntil       *time.Time         `json:"active_until,omitempty"`
+		Match             *Match             `json:"match"`
+		Authenticators    []Handler          `json:"authenticators"`
+		Authorizer        Handler            `json:"authorizer"`
+		Mutators          []Handler          `json:"mutators"`
+		Errors            []ErrorHandler     `json:"errors"`
+		Upstream          Upstream           `json:"upstream"`
+		Listeners         []string           `json:"listeners,omitempty"`
+		Observability     *Observability     `json:"observability,omitempty"`
+		Risk              *Risk              `json:"risk,omitempty"`
+		Tarpit            *Tarpit            `json:"tarpit,omitempty"`
+		Honeypot          bool               `json:"honeypot,omitempty"`
+		Priority          string             `json:"priority,omitempty"`
+		RequestValidation *RequestValidation `json:"request_validation,omitempty"`
+		SecurityHeaders   *SecurityHeaders   `json:"security_headers,omitempty"`
+		matchingEngine    MatchingEngine
+		unknownFields     []string
+		deprecations      []Warning
+	}
+
+	transformed, deprecations, err := migrateRuleJSON(raw)
 	if err != nil {
 		return err
 	}
@@ -161,6 +544,13 @@ func (r *Rule) UnmarshalJSON(raw []byte) error {
 		return err
 	}
 
+	unknown, err := x.UnknownFields(transformed, r)
+	if err != nil {
+		return err
+	}
+	rr.unknownFields = unknown
+	rr.deprecations = deprecations
+
 	*r = rr
 	return nil
 }
@@ -170,19 +560,33 @@ func (r *Rule) GetID() string {
 	return r.ID
 }
 
+// GetDocsURL returns the URL of the rule's documentation, if any.
+func (r *Rule) GetDocsURL() string {
+	return r.DocsURL
+}
+
 // IsMatching checks whether the provided url and method match the rule.
 // An error will be returned if a regexp matching strategy is selected and regexp timeout occurs.
 func (r *Rule) IsMatching(strategy configuration.MatchingStrategy, method string, u *url.URL) (bool, error) {
+	return r.isMatching(strategy, method, matchURL(u))
+}
+
+// isMatching is like IsMatching but accepts the URL in the form produced by matchURL, so it can be computed once
+// when matching many rules.
+func (r *Rule) isMatching(strategy configuration.MatchingStrategy, method string, matchAgainst string) (bool, error) {
 	if !stringInSlice(method, r.Match.Methods) {
 		return false, nil
 	}
 	if err := ensureMatchingEngine(r, strategy); err != nil {
 		return false, err
 	}
-	matchAgainst := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Path)
 	return r.matchingEngine.IsMatching(r.Match.URL, matchAgainst)
 }
 
+func matchURL(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
 // ReplaceAllString searches the input string and replaces each match (with the rule's pattern)
 // found with the replacement text.
 func (r *Rule) ReplaceAllString(strategy configuration.MatchingStrategy, input, replacement string) (string, error) {
@@ -228,8 +632,7 @@ func (r *Rule) ExtractRegexGroups(strategy configuration.MatchingStrategy, u *ur
 		return []string{}, nil
 	}
 
-	matchAgainst := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Path)
-	groups, err := r.matchingEngine.FindStringSubmatch(r.Match.URL, matchAgainst)
+	groups, err := r.matchingEngine.FindStringSubmatch(r.Match.URL, matchURL(u))
 	if err != nil {
 		return nil, err
 	}
@@ -16,7 +16,9 @@ import (
 	"github.com/ory/oathkeeper/x"
 )
 
-func migrateRuleJSON(raw []byte) ([]byte, error) {
+// migrateRuleJSON migrates the access rule from the format of the version it was written for to the current one. The
+// returned warnings name the deprecated fields which were migrated.
+func migrateRuleJSON(raw []byte) ([]byte, []Warning, error) {
 	rv := strings.TrimPrefix(
 		stringsx.Coalesce(
 			gjson.GetBytes(raw, "version").String(),
@@ -27,17 +29,19 @@ func migrateRuleJSON(raw []byte) ([]byte, error) {
 	)
 
 	if rv == x.UnknownVersion {
-		return raw, nil
+		return raw, nil, nil
 	}
 
 	version, err := semver.Make(rv)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, nil, errors.WithStack(err)
 	}
 
+	var warnings []Warning
+
 	raw, err = sjson.SetBytes(raw, "version", strings.Split(x.Version, "+")[0])
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, nil, errors.WithStack(err)
 	}
 
 	if semver.MustParseRange("<=0.32.0-beta.1")(version) {
@@ -58,24 +62,26 @@ func migrateRuleJSON(raw []byte) ([]byte, error) {
 				var retries = int64(3)
 				var err error
 				if dj.Exists() {
+					warnings = append(warnings, Warning{Field: fmt.Sprintf("mutators[%d].config.retry.delay_in_milliseconds", key), Message: `Value is deprecated, use "max_delay" instead.`})
 					delay = dj.Int()
 					if raw, err = sjson.SetBytes(raw, fmt.Sprintf(`mutators.%d.config.retry.max_delay`, key), fmt.Sprintf("%dms", delay)); err != nil {
-						return nil, errors.WithStack(err)
+						return nil, nil, errors.WithStack(err)
 					}
 
 					if raw, err = sjson.DeleteBytes(raw, fmt.Sprintf(`mutators.%d.config.retry.delay_in_milliseconds`, key)); err != nil {
-						return nil, errors.WithStack(err)
+						return nil, nil, errors.WithStack(err)
 					}
 				}
 
 				if rj.Exists() {
+					warnings = append(warnings, Warning{Field: fmt.Sprintf("mutators[%d].config.retry.number_of_retries", key), Message: `Value is deprecated, use "give_up_after" instead.`})
 					retries = rj.Int()
 					if raw, err = sjson.SetBytes(raw, fmt.Sprintf(`mutators.%d.config.retry.give_up_after`, key), fmt.Sprintf("%dms", retries*delay)); err != nil {
-						return nil, errors.WithStack(err)
+						return nil, nil, errors.WithStack(err)
 					}
 
 					if raw, err = sjson.DeleteBytes(raw, fmt.Sprintf(`mutators.%d.config.retry.number_of_retries`, key)); err != nil {
-						return nil, errors.WithStack(err)
+						return nil, nil, errors.WithStack(err)
 					}
 				}
 			}
@@ -98,16 +104,22 @@ func migrateRuleJSON(raw []byte) ([]byte, error) {
 				re := regexp.MustCompile(`\$([0-9]+)`)
 				var err error
 				if aj.Exists() {
+					if re.MatchString(aj.Str) {
+						warnings = append(warnings, Warning{Field: "authorizer.config.required_action", Message: `The "$1" replacement syntax is deprecated, use "{{ printIndex .MatchContext.RegexpCaptureGroups 0 }}" instead.`})
+					}
 					result := re.ReplaceAllString(aj.Str, "{{ printIndex .MatchContext.RegexpCaptureGroups (sub $1 1 | int)}}")
 					if raw, err = sjson.SetBytes(raw, `authorizer.config.required_action`, result); err != nil {
-						return nil, errors.WithStack(err)
+						return nil, nil, errors.WithStack(err)
 					}
 				}
 
 				if rj.Exists() {
+					if re.MatchString(rj.Str) {
+						warnings = append(warnings, Warning{Field: "authorizer.config.required_resource", Message: `The "$1" replacement syntax is deprecated, use "{{ printIndex .MatchContext.RegexpCaptureGroups 0 }}" instead.`})
+					}
 					result := re.ReplaceAllString(rj.Str, "{{ printIndex .MatchContext.RegexpCaptureGroups (sub $1 1 | int)}}")
 					if raw, err = sjson.SetBytes(raw, `authorizer.config.required_resource`, result); err != nil {
-						return nil, errors.WithStack(err)
+						return nil, nil, errors.WithStack(err)
 					}
 				}
 			}
@@ -117,8 +129,8 @@ func migrateRuleJSON(raw []byte) ([]byte, error) {
 	}
 
 	if semver.MustParseRange(">=0.37.0")(version) {
-		return raw, nil
+		return raw, warnings, nil
 	}
 
-	return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unknown access rule version %s, unable to migrate.", version.String()))
+	return nil, nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unknown access rule version %s, unable to migrate.", version.String()))
 }
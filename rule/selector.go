@@ -0,0 +1,94 @@
+package rule
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxLabels is the maximum number of labels of a rule.
+	MaxLabels = 32
+
+	// MaxLabelValueLength is the maximum length of label values and of the owner of a rule. It keeps the cardinality
+	// of the metrics and the size of the decision events of rules bounded.
+	MaxLabelValueLength = 63
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.\-/]{0,61}[A-Za-z0-9])?$`)
+
+// ValidLabelKey returns true if the key starts and ends with an alphanumeric character, contains only alphanumeric
+// characters, "-", "_", "." and "/" in between and is at most 63 characters long.
+func ValidLabelKey(key string) bool {
+	return labelKeyPattern.MatchString(key)
+}
+
+type labelRequirement struct {
+	key       string
+	value     string
+	negate    bool
+	existence bool
+}
+
+// LabelSelector selects rules by their labels. All requirements must be satisfied.
+type LabelSelector struct {
+	requirements []labelRequirement
+}
+
+// ParseLabelSelector parses a comma-separated list of requirements: "key=value" (or "key==value") requires the label
+// to have the value, "key!=value" requires it to not have the value, "key" requires the label to be set and "!key"
+// requires it to be unset. An empty selector selects all rules.
+func ParseLabelSelector(selector string) (*LabelSelector, error) {
+	s := new(LabelSelector)
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var req labelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2)
+			req = labelRequirement{key: parts[0], value: parts[1], negate: true}
+		case strings.Contains(term, "=="):
+			parts := strings.SplitN(term, "==", 2)
+			req = labelRequirement{key: parts[0], value: parts[1]}
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(term, "=", 2)
+			req = labelRequirement{key: parts[0], value: parts[1]}
+		case strings.HasPrefix(term, "!"):
+			req = labelRequirement{key: term[1:], negate: true, existence: true}
+		default:
+			req = labelRequirement{key: term, existence: true}
+		}
+
+		req.key, req.value = strings.TrimSpace(req.key), strings.TrimSpace(req.value)
+		if !ValidLabelKey(req.key) {
+			return nil, errors.Errorf(`label selector "%s" contains the invalid label key "%s"`, selector, req.key)
+		}
+		s.requirements = append(s.requirements, req)
+	}
+	return s, nil
+}
+
+// Matches returns true if the labels satisfy all requirements of the selector.
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range s.requirements {
+		value, ok := labels[req.key]
+		matches := ok
+		if !req.existence {
+			matches = ok && value == req.value
+		}
+		if matches == req.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty returns true if the selector selects all rules.
+func (s *LabelSelector) Empty() bool {
+	return len(s.requirements) == 0
+}
@@ -0,0 +1,45 @@
+package rule_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/oathkeeper/rule"
+)
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"tier": "critical", "app.kubernetes.io/part-of": "shop"}
+
+	for k, tc := range []struct {
+		selector string
+		expect   bool
+	}{
+		{selector: "", expect: true},
+		{selector: "tier=critical", expect: true},
+		{selector: "tier==critical", expect: true},
+		{selector: "tier=best-effort", expect: false},
+		{selector: "tier!=best-effort", expect: true},
+		{selector: "tier!=critical", expect: false},
+		{selector: "tier", expect: true},
+		{selector: "!tier", expect: false},
+		{selector: "!region", expect: true},
+		{selector: "region", expect: false},
+		{selector: "region!=eu", expect: true},
+		{selector: "tier=critical, app.kubernetes.io/part-of=shop", expect: true},
+		{selector: "tier=critical,app.kubernetes.io/part-of=checkout", expect: false},
+	} {
+		t.Run(fmt.Sprintf("case=%d/selector=%s", k, tc.selector), func(t *testing.T) {
+			s, err := ParseLabelSelector(tc.selector)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, s.Matches(labels))
+		})
+	}
+
+	for _, selector := range []string{"=critical", "tier?=critical", "!"} {
+		_, err := ParseLabelSelector(selector)
+		assert.Error(t, err, selector)
+	}
+}
@@ -0,0 +1,90 @@
+package rule
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/oathkeeper/pipeline"
+	"github.com/ory/oathkeeper/pipeline/authn"
+	"github.com/ory/oathkeeper/pipeline/authz"
+	"github.com/ory/oathkeeper/pipeline/mutate"
+)
+
+type stagerRegistry interface {
+	authn.Registry
+	authz.Registry
+	mutate.Registry
+	RuleValidator() Validator
+}
+
+// Stager prepares a set of rules before they are activated. If staging fails for any rule,
+// the whole set must be discarded.
+type Stager interface {
+	Stage(ctx context.Context, rules []Rule) error
+}
+
+var _ Stager = new(StagerDefault)
+
+type StagerDefault struct {
+	r stagerRegistry
+}
+
+func NewStagerDefault(r stagerRegistry) *StagerDefault {
+	return &StagerDefault{r: r}
+}
+
+func (s *StagerDefault) Stage(ctx context.Context, rules []Rule) error {
+	for k := range rules {
+		rl := &rules[k]
+		if err := s.r.RuleValidator().Validate(rl); err != nil {
+			return errors.Wrapf(err, `unable to stage rule "%s"`, rl.ID)
+		}
+
+		if err := s.stageRule(ctx, rl); err != nil {
+			return errors.Wrapf(err, `unable to stage rule "%s"`, rl.ID)
+		}
+	}
+
+	return nil
+}
+
+func (s *StagerDefault) stageRule(ctx context.Context, rl *Rule) error {
+	for _, h := range rl.Authenticators {
+		a, err := s.r.PipelineAuthenticator(h.Handler)
+		if err != nil {
+			return err
+		}
+		if err := stageHandler(ctx, a, h.Config, rl); err != nil {
+			return err
+		}
+	}
+
+	a, err := s.r.PipelineAuthorizer(rl.Authorizer.Handler)
+	if err != nil {
+		return err
+	}
+	if err := stageHandler(ctx, a, rl.Authorizer.Config, rl); err != nil {
+		return err
+	}
+
+	for _, h := range rl.Mutators {
+		m, err := s.r.PipelineMutator(h.Handler)
+		if err != nil {
+			return err
+		}
+		if err := stageHandler(ctx, m, h.Config, rl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func stageHandler(ctx context.Context, handler interface{}, config json.RawMessage, rl *Rule) error {
+	if s, ok := handler.(pipeline.Stager); ok {
+		return s.Stage(ctx, config, rl)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package rule_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/viper"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/internal"
+	. "github.com/ory/oathkeeper/rule"
+)
+
+func TestStagerDefault(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	r := internal.NewRegistry(conf)
+
+	viper.Set(configuration.ViperKeyAuthenticatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthenticatorJWTIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorHeaderIsEnabled, true)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer ts.Close()
+
+	var newRule = func(authn Handler, mutator Handler) Rule {
+		return Rule{
+			ID:             "stage-rule",
+			Match:          &Match{URL: "https://www.ory.sh", Methods: []string{"GET"}},
+			Authenticators: []Handler{authn},
+			Authorizer:     Handler{Handler: "allow"},
+			Mutators:       []Handler{mutator},
+		}
+	}
+
+	for k, tc := range []struct {
+		d         string
+		rules     []Rule
+		expectErr bool
+	}{
+		{
+			d:     "should pass with handlers which do not need staging",
+			rules: []Rule{newRule(Handler{Handler: "noop"}, Handler{Handler: "noop"})},
+		},
+		{
+			d:         "should fail if a rule does not validate",
+			rules:     []Rule{newRule(Handler{Handler: "noop"}, Handler{Handler: "does-not-exist"})},
+			expectErr: true,
+		},
+		{
+			d: "should pass if header templates compile",
+			rules: []Rule{newRule(Handler{Handler: "noop"},
+				Handler{Handler: "header", Config: json.RawMessage(`{"headers":{"X-User":"{{ print .Subject }}"}}`)})},
+		},
+		{
+			d: "should fail if header templates do not compile",
+			rules: []Rule{newRule(Handler{Handler: "noop"},
+				Handler{Handler: "header", Config: json.RawMessage(`{"headers":{"X-User":"{{ print .Subject "}}`)})},
+			expectErr: true,
+		},
+		{
+			d: "should pass if the JSON Web Key Set is reachable",
+			rules: []Rule{newRule(Handler{Handler: "jwt", Config: json.RawMessage(`{"jwks_urls":["` + ts.URL + `"]}`)},
+				Handler{Handler: "noop"})},
+		},
+		{
+			d: "should fail if the JSON Web Key Set is not reachable",
+			rules: []Rule{newRule(Handler{Handler: "jwt", Config: json.RawMessage(`{"jwks_urls":["http://127.0.0.1:1/does-not-exist"]}`)},
+				Handler{Handler: "noop"})},
+			expectErr: true,
+		},
+	} {
+		t.Run(tc.d, func(t *testing.T) {
+			err := r.RuleStager().Stage(context.Background(), tc.rules)
+			if tc.expectErr {
+				require.Error(t, err, "%d", k)
+				assert.Contains(t, err.Error(), `unable to stage rule "stage-rule"`)
+			} else {
+				require.NoError(t, err, "%d", k)
+			}
+		})
+	}
+}
+
+func TestRepositoryMemoryReadyChecker(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	r := internal.NewRegistry(conf)
+	repo := NewRepositoryMemory(r)
+
+	assert.Error(t, repo.ReadyChecker())
+	require.NoError(t, repo.Set(context.Background(), []Rule{}))
+	assert.NoError(t, repo.ReadyChecker())
+}
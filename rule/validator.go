@@ -21,16 +21,26 @@
 package rule
 
 import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"time"
+
 	"github.com/asaskevich/govalidator"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/ory/go-convenience/stringslice"
 	"github.com/ory/herodot"
 
+	"github.com/ory/oathkeeper/driver/configuration"
+	"github.com/ory/oathkeeper/overload"
 	"github.com/ory/oathkeeper/pipeline/authn"
 	"github.com/ory/oathkeeper/pipeline/authz"
 	pe "github.com/ory/oathkeeper/pipeline/errors"
 	"github.com/ory/oathkeeper/pipeline/mutate"
+	"github.com/ory/oathkeeper/x"
 )
 
 var methods = []string{
@@ -54,16 +64,20 @@ type validatorRegistry interface {
 
 type Validator interface {
 	Validate(r *Rule) error
+
+	// Warnings returns the problems of the access rule which do not prevent it from being used.
+	Warnings(r *Rule) []Warning
 }
 
 var _ Validator = new(ValidatorDefault)
 
 type ValidatorDefault struct {
+	c configuration.Provider
 	r validatorRegistry
 }
 
-func NewValidatorDefault(r validatorRegistry) *ValidatorDefault {
-	return &ValidatorDefault{r: r}
+func NewValidatorDefault(c configuration.Provider, r validatorRegistry) *ValidatorDefault {
+	return &ValidatorDefault{c: c, r: r}
 }
 
 func (v *ValidatorDefault) validateAuthenticators(r *Rule) error {
@@ -80,6 +94,30 @@ func (v *ValidatorDefault) validateAuthenticators(r *Rule) error {
 		if err := auth.Validate(a.Config); err != nil {
 			return err
 		}
+
+		if a.Condition != "" {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "condition" of "authenticators[%d]" is not supported, only mutators support conditions.`, k))
+		}
+
+		if err := v.validateWhen(a.When, fmt.Sprintf("authenticators[%d]", k)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateWhen(whens []HandlerWhen, path string) error {
+	for k, w := range whens {
+		if _, err := regexp.Compile(w.Path); err != nil {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "%s.when[%d].path" is not a valid regular expression: %s`, w.Path, path, k, err))
+		}
+
+		for name, pattern := range w.Header {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "%s.when[%d].header.%s" is not a valid regular expression: %s`, pattern, path, k, name, err))
+			}
+		}
 	}
 
 	return nil
@@ -95,6 +133,14 @@ func (v *ValidatorDefault) validateAuthorizer(r *Rule) error {
 		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "authorizer.handler" is not in list of supported authorizers: %v`, r.Authorizer.Handler, v.r.AvailablePipelineAuthorizers()).WithTrace(err).WithDebug(err.Error()))
 	}
 
+	if r.Authorizer.Condition != "" {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReason(`Value "condition" of "authorizer" is not supported, only mutators support conditions.`))
+	}
+
+	if len(r.Authorizer.When) > 0 {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReason(`Value "when" of "authorizer" is not supported, only authenticators support predicates.`))
+	}
+
 	return auth.Validate(r.Authorizer.Config)
 }
 
@@ -113,6 +159,16 @@ func (v *ValidatorDefault) validateMutators(r *Rule) error {
 		if err := mutator.Validate(m.Config); err != nil {
 			return err
 		}
+
+		if m.Condition != "" {
+			if _, err := x.NewTemplate("condition").Parse(m.Condition); err != nil {
+				return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "condition" of "mutators[%d]" is not a valid template: %s`, k, err))
+			}
+		}
+
+		if len(m.When) > 0 {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "when" of "mutators[%d]" is not supported, only authenticators support predicates.`, k))
+		}
 	}
 
 	return nil
@@ -134,7 +190,266 @@ func (v *ValidatorDefault) validateErrorHandlers(r *Rule) error {
 	return nil
 }
 
+func (v *ValidatorDefault) validateUpstreamCredentials(r *Rule) error {
+	c := r.Upstream.Credentials
+	if c == nil {
+		return nil
+	}
+
+	switch {
+	case c.AWSSigV4 != nil && c.OAuth2ClientCredentials != nil:
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Only one of "upstream.credentials.aws_sigv4" and "upstream.credentials.oauth2_client_credentials" may be set.`))
+	case c.AWSSigV4 != nil:
+		if c.AWSSigV4.Region == "" || c.AWSSigV4.Service == "" {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Values "region" and "service" of "upstream.credentials.aws_sigv4" must be set.`))
+		}
+	case c.OAuth2ClientCredentials != nil:
+		if !govalidator.IsURL(c.OAuth2ClientCredentials.TokenURL) {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "upstream.credentials.oauth2_client_credentials.token_url" is not a valid url.`, c.OAuth2ClientCredentials.TokenURL))
+		} else if c.OAuth2ClientCredentials.ClientID == "" {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "client_id" of "upstream.credentials.oauth2_client_credentials" must be set.`))
+		}
+	default:
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "upstream.credentials" must define either "aws_sigv4" or "oauth2_client_credentials".`))
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateUpstreamAuthorizationHeader(r *Rule) error {
+	c := r.Upstream.AuthorizationHeader
+	if c == nil {
+		return nil
+	}
+
+	switch c.Mode {
+	case AuthorizationHeaderKeep, AuthorizationHeaderStrip, AuthorizationHeaderReplace:
+		if c.Header != "" {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "upstream.authorization_header.header" may only be set if mode is "%s".`, AuthorizationHeaderMove))
+		}
+	case AuthorizationHeaderMove:
+		if strings.EqualFold(c.Header, "Authorization") {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "upstream.authorization_header.header" must not be "Authorization".`))
+		}
+	default:
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "upstream.authorization_header.mode" is not valid, valid modes are: %v`, c.Mode, []string{AuthorizationHeaderKeep, AuthorizationHeaderStrip, AuthorizationHeaderMove, AuthorizationHeaderReplace}))
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateUpstreamHost(r *Rule) error {
+	if r.Upstream.Host == "" {
+		return nil
+	}
+
+	if r.Upstream.PreserveHost {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "upstream.host" may not be set if "upstream.preserve_host" is true.`))
+	}
+
+	if _, err := x.NewTemplate("host").Parse(r.Upstream.Host); err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "upstream.host" is not a valid template: %s`, r.Upstream.Host, err))
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateUpstreamErrorResponse(r *Rule) error {
+	c := r.Upstream.ErrorResponse
+	if c == nil {
+		return nil
+	}
+
+	if c.StatusCode != 0 && (c.StatusCode < 200 || c.StatusCode > 599) {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%d" of "upstream.error_response.status_code" is not a valid status code.`, c.StatusCode))
+	}
+
+	if _, err := x.NewTemplate("upstream_error_response").Parse(c.Body); err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value of "upstream.error_response.body" is not a valid template: %s`, err))
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateUpstreamFallback(r *Rule) error {
+	f := r.Upstream.Fallback
+	if f == nil {
+		return nil
+	}
+
+	if f.URL == "" && f.Response == nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "upstream.fallback" must set "url" or "response".`))
+	}
+
+	if f.URL != "" && !govalidator.IsURL(f.URL) {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "upstream.fallback.url" is not a valid url.`, f.URL))
+	}
+
+	if f.Response != nil && f.Response.StatusCode != 0 && (f.Response.StatusCode < 200 || f.Response.StatusCode > 599) {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%d" of "upstream.fallback.response.status_code" is not a valid status code.`, f.Response.StatusCode))
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateUpstreamHeaders(r *Rule) error {
+	c := r.Upstream.Headers
+	if c == nil {
+		return nil
+	}
+
+	for key, names := range map[string][]string{"upstream.headers.allow": c.Allow, "upstream.headers.deny": c.Deny} {
+		for k, name := range names {
+			if name == "" || strings.Contains(strings.TrimSuffix(name, "*"), "*") {
+				return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "%s[%d]" must be a header name, optionally ending with "*".`, name, key, k))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateObservability(r *Rule) error {
+	o := r.Observability
+	if o == nil {
+		return nil
+	}
+
+	if o.LogLevel != "" {
+		if _, err := logrus.ParseLevel(o.LogLevel); err != nil {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "observability.log_level" is not a valid log level.`, o.LogLevel))
+		}
+	}
+
+	if o.TraceSampling != nil && (*o.TraceSampling < 0 || *o.TraceSampling > 1) {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%v" of "observability.trace_sampling" must be between 0 and 1.`, *o.TraceSampling))
+	}
+
+	if o.SLO == nil {
+		return nil
+	}
+
+	for key, target := range map[string]*float64{"observability.slo.availability": o.SLO.Availability, "observability.slo.latency_target": o.SLO.LatencyTarget} {
+		if target != nil && (*target <= 0 || *target >= 1) {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%v" of "%s" must be greater than 0 and less than 1.`, *target, key))
+		}
+	}
+
+	if (o.SLO.Latency == "") != (o.SLO.LatencyTarget == nil) {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReason(`Values "observability.slo.latency" and "observability.slo.latency_target" must be set together.`))
+	}
+
+	if d, err := o.SLO.LatencyThreshold(); err != nil || d < 0 || (o.SLO.Latency != "" && d == 0) {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "observability.slo.latency" must be a positive duration, for example "50ms".`, o.SLO.Latency))
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateMetadata(r *Rule) error {
+	if len(r.Owner) > MaxLabelValueLength {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "owner" must be at most %d characters long.`, r.Owner, MaxLabelValueLength))
+	}
+
+	if r.DocsURL != "" && !govalidator.IsRequestURL(r.DocsURL) {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "docs_url" is not a valid absolute url.`, r.DocsURL))
+	}
+
+	if r.ActiveFrom != nil && r.ActiveUntil != nil && !r.ActiveUntil.After(*r.ActiveFrom) {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "active_until" must be after "active_from".`, r.ActiveUntil.UTC().Format(time.RFC3339)))
+	}
+
+	labels := r.Labels()
+	if len(labels) > MaxLabels {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "metadata.labels" must not contain more than %d labels.`, MaxLabels))
+	}
+
+	for key, value := range labels {
+		if !ValidLabelKey(key) {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Key "%s" of "metadata.labels" must be at most 63 characters long, start and end with an alphanumeric character and contain only alphanumeric characters, "-", "_", "." and "/".`, key))
+		}
+		if len(value) > MaxLabelValueLength {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "metadata.labels.%s" must be at most %d characters long.`, value, key, MaxLabelValueLength))
+		}
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateRisk(r *Rule) error {
+	if r.Risk == nil {
+		return nil
+	}
+
+	for key, threshold := range map[string]*float64{"risk.deny_above": r.Risk.DenyAbove, "risk.step_up_above": r.Risk.StepUpAbove} {
+		if threshold != nil && (*threshold < 0 || *threshold > 1) {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%v" of "%s" must be between 0 and 1.`, *threshold, key))
+		}
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateTarpit(r *Rule) error {
+	if r.Tarpit == nil {
+		return nil
+	}
+
+	min, max, err := r.Tarpit.Delays()
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Values "%s" and "%s" of "tarpit.min_delay" and "tarpit.max_delay" must be durations, for example "500ms": %s`, r.Tarpit.MinDelay, r.Tarpit.MaxDelay, err))
+	}
+
+	if min < 0 || max < min {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "tarpit.max_delay" must not be less than "tarpit.min_delay" which must not be negative.`, r.Tarpit.MaxDelay))
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateRequestValidation(r *Rule) error {
+	rv := r.RequestValidation
+	if rv == nil {
+		return nil
+	}
+
+	for key, limit := range map[string]int{
+		"request_validation.max_header_count": rv.MaxHeaderCount,
+		"request_validation.max_header_size":  rv.MaxHeaderSize,
+		"request_validation.max_url_length":   rv.MaxURLLength,
+	} {
+		if limit < 0 {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%d" of "%s" must not be negative.`, limit, key))
+		}
+	}
+
+	for k, contentType := range rv.AllowedContentTypes {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "request_validation.allowed_content_types[%d]" is not a valid media type: %s`, contentType, k, err))
+		}
+	}
+
+	return nil
+}
+
+func (v *ValidatorDefault) validateSecurityHeaders(r *Rule) error {
+	if r.SecurityHeaders == nil {
+		return nil
+	}
+
+	switch r.SecurityHeaders.Profile {
+	case "", configuration.SecurityHeadersProfileNone, configuration.SecurityHeadersProfileStrict:
+		return nil
+	}
+
+	return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "security_headers.profile" must be one of "%s" and "%s".`, r.SecurityHeaders.Profile, configuration.SecurityHeadersProfileNone, configuration.SecurityHeadersProfileStrict))
+}
+
 func (v *ValidatorDefault) Validate(r *Rule) error {
+	if v.c.StrictModeIsEnabled() && len(r.unknownFields) > 0 {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Strict mode is enabled but the access rule contains unknown fields: %s`, strings.Join(r.unknownFields, ", ")))
+	}
+
 	if r.Match == nil {
 		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "match" is empty but must be set.`))
 	}
@@ -155,6 +470,58 @@ func (v *ValidatorDefault) Validate(r *Rule) error {
 		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "upstream.url" is not a valid url.`, r.Upstream.URL))
 	}
 
+	if err := v.validateUpstreamCredentials(r); err != nil {
+		return err
+	}
+
+	if err := v.validateUpstreamAuthorizationHeader(r); err != nil {
+		return err
+	}
+
+	if err := v.validateUpstreamHost(r); err != nil {
+		return err
+	}
+
+	if err := v.validateUpstreamErrorResponse(r); err != nil {
+		return err
+	}
+
+	if err := v.validateUpstreamFallback(r); err != nil {
+		return err
+	}
+
+	if err := v.validateUpstreamHeaders(r); err != nil {
+		return err
+	}
+
+	if err := v.validateObservability(r); err != nil {
+		return err
+	}
+
+	if err := v.validateMetadata(r); err != nil {
+		return err
+	}
+
+	if err := v.validateRisk(r); err != nil {
+		return err
+	}
+
+	if err := v.validateTarpit(r); err != nil {
+		return err
+	}
+
+	if r.Priority != "" && !stringslice.Has(overload.Priorities, r.Priority) {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf(`Value "%s" of "priority" is not a valid priority, valid priorities are: %v`, r.Priority, overload.Priorities))
+	}
+
+	if err := v.validateRequestValidation(r); err != nil {
+		return err
+	}
+
+	if err := v.validateSecurityHeaders(r); err != nil {
+		return err
+	}
+
 	if err := v.validateAuthenticators(r); err != nil {
 		return err
 	}
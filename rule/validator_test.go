@@ -21,9 +21,11 @@
 package rule_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ory/viper"
 
@@ -71,6 +73,153 @@ func TestValidateRule(t *testing.T) {
 			},
 			expectErr: `Value of "authenticators" must be set and can not be an empty array.`,
 		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh", Credentials: &UpstreamCredentials{}},
+			},
+			expectErr: `Value "upstream.credentials" must define either "aws_sigv4" or "oauth2_client_credentials".`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh", Credentials: &UpstreamCredentials{AWSSigV4: &UpstreamAWSSigV4{Region: "eu-central-1"}}},
+			},
+			expectErr: `Values "region" and "service" of "upstream.credentials.aws_sigv4" must be set.`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh", Credentials: &UpstreamCredentials{OAuth2ClientCredentials: &UpstreamOAuth2ClientCredentials{TokenURL: "not-a-url"}}},
+			},
+			expectErr: `Value "not-a-url" of "upstream.credentials.oauth2_client_credentials.token_url" is not a valid url.`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh", AuthorizationHeader: &UpstreamAuthorizationHeader{Mode: "drop"}},
+			},
+			expectErr: `Value "drop" of "upstream.authorization_header.mode" is not valid, valid modes are: [keep strip move replace]`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh", AuthorizationHeader: &UpstreamAuthorizationHeader{Mode: "strip", Header: "X-Foo"}},
+			},
+			expectErr: `Value "upstream.authorization_header.header" may only be set if mode is "move".`,
+		},
+		{
+			r: &Rule{
+				Match:         &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:      Upstream{URL: "https://www.ory.sh"},
+				Observability: &Observability{LogLevel: "verbose"},
+			},
+			expectErr: `Value "verbose" of "observability.log_level" is not a valid log level.`,
+		},
+		{
+			r: &Rule{
+				Match:         &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:      Upstream{URL: "https://www.ory.sh"},
+				Observability: &Observability{TraceSampling: func(f float64) *float64 { return &f }(1.5)},
+			},
+			expectErr: `Value "1.5" of "observability.trace_sampling" must be between 0 and 1.`,
+		},
+		{
+			r: &Rule{
+				Match:         &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:      Upstream{URL: "https://www.ory.sh"},
+				Observability: &Observability{SLO: &SLO{Availability: func(f float64) *float64 { return &f }(1)}},
+			},
+			expectErr: `Value "1" of "observability.slo.availability" must be greater than 0 and less than 1.`,
+		},
+		{
+			r: &Rule{
+				Match:         &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:      Upstream{URL: "https://www.ory.sh"},
+				Observability: &Observability{SLO: &SLO{Latency: "50ms"}},
+			},
+			expectErr: `Values "observability.slo.latency" and "observability.slo.latency_target" must be set together.`,
+		},
+		{
+			r: &Rule{
+				Match:         &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:      Upstream{URL: "https://www.ory.sh"},
+				Observability: &Observability{SLO: &SLO{Latency: "fast", LatencyTarget: func(f float64) *float64 { return &f }(0.99)}},
+			},
+			expectErr: `Value "fast" of "observability.slo.latency" must be a positive duration, for example "50ms".`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh"},
+				Metadata: &Metadata{Labels: map[string]string{"-tier": "critical"}},
+			},
+			expectErr: `Key "-tier" of "metadata.labels" must be at most 63 characters long, start and end with an alphanumeric character and contain only alphanumeric characters, "-", "_", "." and "/".`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh"},
+				Metadata: &Metadata{Labels: map[string]string{"tier": strings.Repeat("a", 64)}},
+			},
+			expectErr: `Value "` + strings.Repeat("a", 64) + `" of "metadata.labels.tier" must be at most 63 characters long.`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh"},
+				DocsURL:  "runbooks/payments",
+			},
+			expectErr: `Value "runbooks/payments" of "docs_url" is not a valid absolute url.`,
+		},
+		{
+			r: &Rule{
+				Match:       &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:    Upstream{URL: "https://www.ory.sh"},
+				ActiveFrom:  func(t time.Time) *time.Time { return &t }(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)),
+				ActiveUntil: func(t time.Time) *time.Time { return &t }(time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)),
+			},
+			expectErr: `Value "2021-05-01T00:00:00Z" of "active_until" must be after "active_from".`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh"},
+				Risk:     &Risk{DenyAbove: func(f float64) *float64 { return &f }(-0.1)},
+			},
+			expectErr: `Value "-0.1" of "risk.deny_above" must be between 0 and 1.`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh"},
+				Priority: "urgent",
+			},
+			expectErr: `Value "urgent" of "priority" is not a valid priority, valid priorities are: [low normal critical]`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh"},
+				Tarpit:   &Tarpit{MinDelay: "2s", MaxDelay: "1s"},
+			},
+			expectErr: `Value "1s" of "tarpit.max_delay" must not be less than "tarpit.min_delay" which must not be negative.`,
+		},
+		{
+			r: &Rule{
+				Match:    &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream: Upstream{URL: "https://www.ory.sh", PreserveHost: true, Host: "users.intranet"},
+			},
+			expectErr: `Value "upstream.host" may not be set if "upstream.preserve_host" is true.`,
+		},
+		{
+			r: &Rule{
+				Match:           &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:        Upstream{URL: "https://www.ory.sh"},
+				SecurityHeaders: &SecurityHeaders{Profile: "relaxed"},
+			},
+			expectErr: `Value "relaxed" of "security_headers.profile" must be one of "none" and "strict".`,
+		},
 		{
 			setup: prep(true, false, false),
 			r: &Rule{
@@ -139,6 +288,70 @@ func TestValidateRule(t *testing.T) {
 				Mutators:       []Handler{{Handler: "noop"}},
 			},
 		},
+		{
+			setup: prep(true, true, true),
+			r: &Rule{
+				Match:          &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:       Upstream{URL: "https://www.ory.sh"},
+				Authenticators: []Handler{{Handler: "noop"}},
+				Authorizer:     Handler{Handler: "allow"},
+				Mutators:       []Handler{{Handler: "noop", Condition: `{{ eq .Subject "foo" }}`}},
+			},
+		},
+		{
+			setup: prep(true, true, true),
+			r: &Rule{
+				Match:          &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:       Upstream{URL: "https://www.ory.sh"},
+				Authenticators: []Handler{{Handler: "noop"}},
+				Authorizer:     Handler{Handler: "allow"},
+				Mutators:       []Handler{{Handler: "noop", Condition: `{{ eq .Subject "foo" }`}},
+			},
+			expectErr: `Value "condition" of "mutators[0]" is not a valid template: `,
+		},
+		{
+			setup: prep(true, true, true),
+			r: &Rule{
+				Match:          &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:       Upstream{URL: "https://www.ory.sh"},
+				Authenticators: []Handler{{Handler: "noop"}},
+				Authorizer:     Handler{Handler: "allow", Condition: `{{ eq .Subject "foo" }}`},
+				Mutators:       []Handler{{Handler: "noop"}},
+			},
+			expectErr: `Value "condition" of "authorizer" is not supported, only mutators support conditions.`,
+		},
+		{
+			setup: prep(true, true, true),
+			r: &Rule{
+				Match:          &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:       Upstream{URL: "https://www.ory.sh"},
+				Authenticators: []Handler{{Handler: "noop", When: []HandlerWhen{{Path: "^/api/", Header: map[string]string{"Accept": "text/html"}}}}},
+				Authorizer:     Handler{Handler: "allow"},
+				Mutators:       []Handler{{Handler: "noop"}},
+			},
+		},
+		{
+			setup: prep(true, true, true),
+			r: &Rule{
+				Match:          &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:       Upstream{URL: "https://www.ory.sh"},
+				Authenticators: []Handler{{Handler: "noop", When: []HandlerWhen{{Header: map[string]string{"Accept": "text/(html"}}}}},
+				Authorizer:     Handler{Handler: "allow"},
+				Mutators:       []Handler{{Handler: "noop"}},
+			},
+			expectErr: `Value "text/(html" of "authenticators[0].when[0].header.Accept" is not a valid regular expression: `,
+		},
+		{
+			setup: prep(true, true, true),
+			r: &Rule{
+				Match:          &Match{URL: "https://www.ory.sh", Methods: []string{"POST"}},
+				Upstream:       Upstream{URL: "https://www.ory.sh"},
+				Authenticators: []Handler{{Handler: "noop"}},
+				Authorizer:     Handler{Handler: "allow"},
+				Mutators:       []Handler{{Handler: "noop", When: []HandlerWhen{{Path: "^/api/"}}}},
+			},
+			expectErr: `Value "when" of "mutators[0]" is not supported, only authenticators support predicates.`,
+		},
 		{
 			setup: prep(true, true, false),
 			r: &Rule{
@@ -158,7 +371,7 @@ func TestValidateRule(t *testing.T) {
 			}
 
 			r := internal.NewRegistry(conf)
-			v := NewValidatorDefault(r)
+			v := NewValidatorDefault(conf, r)
 
 			err := v.Validate(tc.r)
 			if tc.expectErr == "" {
@@ -173,6 +386,110 @@ func TestValidateRule(t *testing.T) {
 	}
 }
 
+func TestValidateRuleStrictMode(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	viper.Set(configuration.ViperKeyAuthenticatorNoopIsEnabled, true)
+	viper.Set(configuration.ViperKeyAuthorizerAllowIsEnabled, true)
+	viper.Set(configuration.ViperKeyMutatorNoopIsEnabled, true)
+	v := NewValidatorDefault(conf, internal.NewRegistry(conf))
+
+	var r Rule
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"match": {"url": "https://www.ory.sh", "methods": ["GET"]},
+		"authenticators": [{"handler": "noop", "confg": {}}],
+		"authorizer": {"handler": "allow"},
+		"mutators": [{"handler": "noop"}],
+		"upstreams": {"url": "https://www.ory.sh"}
+	}`), &r))
+
+	// Unknown fields are ignored unless strict mode is enabled.
+	require.NoError(t, v.Validate(&r))
+
+	viper.Set(configuration.ViperKeyStrictModeIsEnabled, true)
+	assertReason(t, v.Validate(&r), `contains unknown fields: authenticators[0].confg, upstreams`)
+
+	var valid Rule
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"match": {"url": "https://www.ory.sh", "methods": ["GET"]},
+		"authenticators": [{"handler": "noop"}],
+		"authorizer": {"handler": "allow"},
+		"mutators": [{"handler": "noop"}],
+		"upstream": {"url": "https://www.ory.sh"}
+	}`), &valid))
+	require.NoError(t, v.Validate(&valid))
+}
+
+func TestRuleWarnings(t *testing.T) {
+	conf := internal.NewConfigurationWithDefaults()
+	v := NewValidatorDefault(conf, internal.NewRegistry(conf))
+
+	for k, tc := range []struct {
+		d      string
+		rule   string
+		expect []string
+	}{
+		{
+			d:    "should not warn about a rule without problems",
+			rule: `{"id":"r","match":{"url":"https://api.example.com/<.*>"},"authenticators":[{"handler":"jwt"},{"handler":"noop"}]}`,
+		},
+		{
+			d:      "should warn about unknown fields",
+			rule:   `{"id":"r","upstreams":{"url":"https://www.ory.sh"}}`,
+			expect: []string{"upstreams"},
+		},
+		{
+			d:      "should warn about patterns matching any host",
+			rule:   `{"id":"r","match":{"url":"https://<.*>/api"}}`,
+			expect: []string{"match.url"},
+		},
+		{
+			d:      "should warn about unescaped dots",
+			rule:   `{"id":"r","match":{"url":"https://<api.example.com|www.example.com>/<.*>"}}`,
+			expect: []string{"match.url"},
+		},
+		{
+			d:    "should not warn about escaped dots",
+			rule: `{"id":"r","match":{"url":"https://<api\\.example\\.com|www[.]example[.]com>/<.*>"}}`,
+		},
+		{
+			d:      "should warn about authenticators which are never used",
+			rule:   `{"id":"r","authenticators":[{"handler":"anonymous"},{"handler":"noop"},{"handler":"jwt"},{"handler":"anonymous"}]}`,
+			expect: []string{"authenticators[2]", "authenticators[3]"},
+		},
+		{
+			d:    "should not warn about authenticators after one with predicates",
+			rule: `{"id":"r","authenticators":[{"handler":"noop","when":[{"path":"^/public/"}]},{"handler":"jwt"}]}`,
+		},
+		{
+			d:      "should warn about deprecated fields",
+			rule:   `{"id":"r","version":"v0.30.0-beta.1","mutators":[{"handler":"hydrator","config":{"retry":{"delay_in_milliseconds":500,"number_of_retries":5}}}]}`,
+			expect: []string{"mutators[0].config.retry.delay_in_milliseconds", "mutators[0].config.retry.number_of_retries"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			var r Rule
+			require.NoError(t, json.Unmarshal([]byte(tc.rule), &r))
+
+			var fields []string
+			for _, w := range v.Warnings(&r) {
+				assert.Equal(t, "r", w.Rule)
+				assert.NotEmpty(t, w.Message)
+				fields = append(fields, w.Field)
+			}
+			assert.Equal(t, tc.expect, fields)
+		})
+	}
+
+	t.Run("case=unknown fields are errors in strict mode", func(t *testing.T) {
+		viper.Set(configuration.ViperKeyStrictModeIsEnabled, true)
+		defer viper.Set(configuration.ViperKeyStrictModeIsEnabled, false)
+
+		var r Rule
+		require.NoError(t, json.Unmarshal([]byte(`{"id":"r","upstreams":{"url":"https://www.ory.sh"}}`), &r))
+		assert.Empty(t, v.Warnings(&r))
+	})
+}
+
 func assertReason(t *testing.T, err error, sub string) {
 	require.Error(t, err)
 	reason := errors.Cause(err).(*herodot.DefaultError).ReasonField
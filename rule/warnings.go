@@ -0,0 +1,154 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ory/oathkeeper/driver/configuration"
+)
+
+// Warning is a problem of an access rule which, unlike a validation error, does not prevent the access rule from
+// being used.
+//
+// swagger:model ruleWarning
+type Warning struct {
+	// Rule is the ID of the access rule.
+	Rule string `json:"rule"`
+
+	// Field is the path of the field the warning refers to, for example "authenticators[1]".
+	Field string `json:"field,omitempty"`
+
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Field == "" {
+		return fmt.Sprintf(`Access rule "%s": %s`, w.Rule, w.Message)
+	}
+	return fmt.Sprintf(`Access rule "%s", field "%s": %s`, w.Rule, w.Field, w.Message)
+}
+
+// catchAllAuthenticators handle every request they see, authenticators listed after them without predicates are never
+// used.
+var catchAllAuthenticators = []string{"noop", "unauthorized"}
+
+// Warnings returns the problems of the access rule which do not prevent it from being used: deprecated fields,
+// fields which are not known and ignored, suspicious regular expressions and handlers which are never used.
+func (v *ValidatorDefault) Warnings(r *Rule) []Warning {
+	var warnings []Warning
+	warn := func(field, format string, args ...interface{}) {
+		warnings = append(warnings, Warning{Rule: r.ID, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, d := range r.deprecations {
+		warn(d.Field, "%s", d.Message)
+	}
+
+	// In strict mode unknown fields are rejected by Validate.
+	if !v.c.StrictModeIsEnabled() {
+		for _, field := range r.unknownFields {
+			warn(field, "Field is not known and ignored, it may be misspelled.")
+		}
+	}
+
+	if r.ActiveUntil != nil && !time.Now().Before(*r.ActiveUntil) {
+		warn("active_until", "The access rule is no longer active and never matches requests again.")
+	}
+
+	if r.Match != nil {
+		strategy := v.c.AccessRuleMatchingStrategy()
+		for _, p := range urlPatterns(r.Match.URL) {
+			if p.host && isCatchAllPattern(p.pattern) {
+				warn("match.url", `Pattern "<%s>" matches any host, including hosts of other services.`, p.pattern)
+			}
+			if strategy != configuration.Glob && hasUnescapedDot(p.pattern) {
+				warn("match.url", `Pattern "<%s>" contains an unescaped "." which matches any character, use "\." to match a dot.`, p.pattern)
+			}
+		}
+	}
+
+	for k, a := range r.Authenticators {
+		if len(a.When) > 0 || !stringInSlice(a.Handler, catchAllAuthenticators) {
+			continue
+		}
+		for i := k + 1; i < len(r.Authenticators); i++ {
+			warn(fmt.Sprintf("authenticators[%d]", i), `Authenticator "%s" is never used because authenticator "%s" of "authenticators[%d]" handles every request.`, r.Authenticators[i].Handler, a.Handler, k)
+		}
+		break
+	}
+
+	return warnings
+}
+
+// Warnings returns the warnings of all rules.
+func Warnings(v Validator, rules []Rule) []Warning {
+	var warnings []Warning
+	for k := range rules {
+		warnings = append(warnings, v.Warnings(&rules[k])...)
+	}
+	return warnings
+}
+
+type urlPattern struct {
+	pattern string
+
+	// host is true if the pattern is part of the scheme or host of the URL.
+	host bool
+}
+
+// urlPatterns returns the patterns of the match URL which are enclosed in < and >.
+func urlPatterns(u string) []urlPattern {
+	var patterns []urlPattern
+	var depth, start int
+	host, afterScheme := true, false
+	for i := 0; i < len(u); i++ {
+		switch c := u[i]; {
+		case c == '<':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case c == '>' && depth > 0:
+			depth--
+			if depth == 0 {
+				patterns = append(patterns, urlPattern{pattern: u[start:i], host: host})
+			}
+		case depth > 0:
+		case !afterScheme && strings.HasPrefix(u[i:], "://"):
+			afterScheme = true
+			i += 2
+		case afterScheme && c == '/':
+			host = false
+		}
+	}
+	return patterns
+}
+
+func isCatchAllPattern(pattern string) bool {
+	return stringInSlice(pattern, []string{".*", ".+", "*", "**"})
+}
+
+// hasUnescapedDot returns true if the regular expression contains a dot outside of a character class which is
+// followed by a letter or digit. Such a dot is usually meant to match a dot only, as in "<api.example.com>".
+func hasUnescapedDot(pattern string) bool {
+	class := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case c == '[':
+			class = true
+		case c == ']':
+			class = false
+		case c == '.' && !class && i+1 < len(pattern) && isAlphanumeric(pattern[i+1]):
+			return true
+		}
+	}
+	return false
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
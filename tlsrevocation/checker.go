@@ -0,0 +1,273 @@
+package tlsrevocation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// PolicySoftFail accepts certificates whose revocation status can not be determined.
+	PolicySoftFail = "soft_fail"
+
+	// PolicyHardFail rejects certificates whose revocation status can not be determined.
+	PolicyHardFail = "hard_fail"
+)
+
+// ErrRevoked is returned for certificates which were revoked by their issuer.
+var ErrRevoked = errors.New("certificate has been revoked")
+
+// Checker determines the revocation status of certificates using OCSP and certificate revocation lists (CRLs).
+// Responses and revocation lists are cached until they must be refreshed, but at most for the configured TTL.
+type Checker struct {
+	sync.RWMutex
+
+	l      logrus.FieldLogger
+	client *http.Client
+	policy string
+	ocsp   bool
+	crl    bool
+	ttl    time.Duration
+
+	statuses map[string]status
+	crls     map[string]revocationList
+}
+
+type status struct {
+	revoked bool
+	expires time.Time
+}
+
+type revocationList struct {
+	list    *pkix.CertificateList
+	expires time.Time
+}
+
+// NewChecker creates a new Checker. At least one of useOCSP and useCRL should be true, otherwise the revocation
+// status of all certificates is unknown.
+func NewChecker(l logrus.FieldLogger, policy string, useOCSP, useCRL bool, ttl time.Duration) *Checker {
+	return &Checker{
+		l:        l,
+		client:   &http.Client{Timeout: time.Second * 5},
+		policy:   policy,
+		ocsp:     useOCSP,
+		crl:      useCRL,
+		ttl:      ttl,
+		statuses: map[string]status{},
+		crls:     map[string]revocationList{},
+	}
+}
+
+// VerifyPeerCertificate can be used as tls.Config.VerifyPeerCertificate. It rejects the connection if the peer's
+// certificate was revoked, or if its revocation status can not be determined and the policy is to fail hard. It
+// requires the certificate chains to be verified by crypto/tls.
+func (c *Checker) VerifyPeerCertificate(_ [][]byte, chains [][]*x509.Certificate) error {
+	for _, chain := range chains {
+		if len(chain) < 2 {
+			continue
+		}
+		return c.Check(chain[0], chain[1])
+	}
+	return nil
+}
+
+// Check returns an error if the certificate issued by issuer was revoked, or if its revocation status can not be
+// determined and the policy is to fail hard.
+func (c *Checker) Check(cert, issuer *x509.Certificate) error {
+	revoked, err := c.isRevoked(cert, issuer)
+	if err != nil {
+		if c.policy == PolicyHardFail {
+			return errors.Wrap(err, "unable to determine the revocation status of the certificate")
+		}
+		c.l.WithError(err).
+			WithField("subject", cert.Subject.String()).
+			WithField("serial", cert.SerialNumber.String()).
+			Warn("Unable to determine the revocation status of the certificate, accepting it because the policy is to fail soft.")
+		return nil
+	}
+
+	if revoked {
+		return errors.WithStack(ErrRevoked)
+	}
+	return nil
+}
+
+func (c *Checker) isRevoked(cert, issuer *x509.Certificate) (bool, error) {
+	key := fingerprint(cert)
+
+	c.RLock()
+	s, ok := c.statuses[key]
+	c.RUnlock()
+	if ok && time.Now().Before(s.expires) {
+		return s.revoked, nil
+	}
+
+	var errs []error
+	if c.ocsp && len(cert.OCSPServer) > 0 {
+		revoked, expires, err := c.queryOCSP(cert, issuer)
+		if err == nil {
+			c.remember(key, revoked, expires)
+			return revoked, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if c.crl && len(cert.CRLDistributionPoints) > 0 {
+		revoked, expires, err := c.queryCRL(cert, issuer)
+		if err == nil {
+			c.remember(key, revoked, expires)
+			return revoked, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return false, errs[0]
+	}
+	return false, errors.New("the certificate does not name an OCSP responder or a CRL distribution point")
+}
+
+func (c *Checker) remember(key string, revoked bool, expires time.Time) {
+	c.Lock()
+	c.statuses[key] = status{revoked: revoked, expires: expires}
+	c.Unlock()
+}
+
+// OCSP queries the certificate's OCSP responders and returns the first valid response.
+func (c *Checker) OCSP(cert, issuer *x509.Certificate) (*ocsp.Response, []byte, error) {
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	var lastErr error
+	for _, server := range cert.OCSPServer {
+		res, err := c.client.Post(server, "application/ocsp-request", bytes.NewReader(req))
+		if err != nil {
+			lastErr = errors.WithStack(err)
+			continue
+		}
+
+		body, err := ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			lastErr = errors.WithStack(err)
+			continue
+		} else if res.StatusCode != http.StatusOK {
+			lastErr = errors.Errorf("OCSP responder %s returned status code %d", server, res.StatusCode)
+			continue
+		}
+
+		parsed, err := ocsp.ParseResponseForCert(body, cert, issuer)
+		if err != nil {
+			lastErr = errors.WithStack(err)
+			continue
+		}
+
+		return parsed, body, nil
+	}
+
+	return nil, nil, lastErr
+}
+
+func (c *Checker) queryOCSP(cert, issuer *x509.Certificate) (bool, time.Time, error) {
+	res, _, err := c.OCSP(cert, issuer)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	switch res.Status {
+	case ocsp.Good:
+		return false, c.expiry(res.NextUpdate), nil
+	case ocsp.Revoked:
+		return true, c.expiry(res.NextUpdate), nil
+	default:
+		return false, time.Time{}, errors.New("OCSP responder does not know the certificate")
+	}
+}
+
+func (c *Checker) queryCRL(cert, issuer *x509.Certificate) (bool, time.Time, error) {
+	var lastErr error
+	for _, location := range cert.CRLDistributionPoints {
+		list, expires, err := c.fetchCRL(location, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, revoked := range list.TBSCertList.RevokedCertificates {
+			if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true, expires, nil
+			}
+		}
+		return false, expires, nil
+	}
+
+	return false, time.Time{}, lastErr
+}
+
+func (c *Checker) fetchCRL(location string, issuer *x509.Certificate) (*pkix.CertificateList, time.Time, error) {
+	c.RLock()
+	cached, ok := c.crls[location]
+	c.RUnlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.list, cached.expires, nil
+	}
+
+	res, err := c.client.Get(location)
+	if err != nil {
+		return nil, time.Time{}, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, time.Time{}, errors.Errorf("CRL distribution point %s returned status code %d", location, res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, time.Time{}, errors.WithStack(err)
+	}
+
+	list, err := x509.ParseCRL(body)
+	if err != nil {
+		return nil, time.Time{}, errors.WithStack(err)
+	}
+
+	if err := issuer.CheckCRLSignature(list); err != nil {
+		return nil, time.Time{}, errors.Wrapf(err, "CRL from %s is not signed by the issuer", location)
+	} else if list.HasExpired(time.Now()) {
+		return nil, time.Time{}, errors.Errorf("CRL from %s has expired", location)
+	}
+
+	expires := c.expiry(list.TBSCertList.NextUpdate)
+	c.Lock()
+	c.crls[location] = revocationList{list: list, expires: expires}
+	c.Unlock()
+
+	return list, expires, nil
+}
+
+// expiry returns until when a response valid until nextUpdate may be cached.
+func (c *Checker) expiry(nextUpdate time.Time) time.Time {
+	expires := time.Now().Add(c.ttl)
+	if !nextUpdate.IsZero() && nextUpdate.Before(expires) {
+		return nextUpdate
+	}
+	return expires
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(h[:])
+}
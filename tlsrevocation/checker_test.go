@@ -0,0 +1,200 @@
+package tlsrevocation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+type pki struct {
+	ca      *x509.Certificate
+	key     crypto.Signer
+	revoked map[int64]bool
+
+	ocspRequests int32
+	crlRequests  int32
+}
+
+func newPKI(t *testing.T) *pki {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(raw)
+	require.NoError(t, err)
+
+	return &pki{ca: ca, key: key, revoked: map[int64]bool{}}
+}
+
+func (p *pki) issue(t *testing.T, serial int64, ocspServer, crlDistributionPoint string) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	if ocspServer != "" {
+		template.OCSPServer = []string{ocspServer}
+	}
+	if crlDistributionPoint != "" {
+		template.CRLDistributionPoints = []string{crlDistributionPoint}
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, p.ca, key.Public(), p.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(raw)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func (p *pki) ocspHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&p.ocspRequests, 1)
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(t, err)
+
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}
+		if p.revoked[req.SerialNumber.Int64()] {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Now().Add(-time.Minute)
+		}
+
+		res, err := ocsp.CreateResponse(p.ca, p.ca, template, p.key)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(res)
+	}
+}
+
+func (p *pki) crlHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&p.crlRequests, 1)
+		var revoked []pkix.RevokedCertificate
+		for serial := range p.revoked {
+			revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: big.NewInt(serial), RevocationTime: time.Now().Add(-time.Minute)})
+		}
+
+		crl, err := p.ca.CreateCRL(rand.Reader, p.key, revoked, time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		_, _ = w.Write(crl)
+	}
+}
+
+func TestChecker(t *testing.T) {
+	p := newPKI(t)
+	p.revoked[3] = true
+
+	ocspServer := httptest.NewServer(p.ocspHandler(t))
+	defer ocspServer.Close()
+	crlServer := httptest.NewServer(p.crlHandler(t))
+	defer crlServer.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	t.Run("method=ocsp", func(t *testing.T) {
+		c := NewChecker(logrus.New(), PolicyHardFail, true, false, time.Minute)
+		good, _ := p.issue(t, 2, ocspServer.URL, "")
+		revoked, _ := p.issue(t, 3, ocspServer.URL, "")
+
+		require.NoError(t, c.Check(good, p.ca))
+		assert.Equal(t, ErrRevoked, errors.Cause(c.Check(revoked, p.ca)))
+
+		requests := atomic.LoadInt32(&p.ocspRequests)
+		require.NoError(t, c.Check(good, p.ca))
+		assert.Equal(t, requests, atomic.LoadInt32(&p.ocspRequests), "the status must be cached")
+	})
+
+	t.Run("method=crl", func(t *testing.T) {
+		c := NewChecker(logrus.New(), PolicyHardFail, false, true, time.Minute)
+		good, _ := p.issue(t, 4, "", crlServer.URL)
+		revoked, _ := p.issue(t, 3, "", crlServer.URL)
+
+		require.NoError(t, c.Check(good, p.ca))
+		assert.Equal(t, ErrRevoked, errors.Cause(c.Check(revoked, p.ca)))
+		assert.EqualValues(t, 1, atomic.LoadInt32(&p.crlRequests), "the revocation list must be cached")
+	})
+
+	t.Run("case=falls back to crl", func(t *testing.T) {
+		c := NewChecker(logrus.New(), PolicyHardFail, true, true, time.Minute)
+		revoked, _ := p.issue(t, 3, down.URL, crlServer.URL)
+		assert.Equal(t, ErrRevoked, errors.Cause(c.Check(revoked, p.ca)))
+	})
+
+	t.Run("case=unknown status", func(t *testing.T) {
+		cert, _ := p.issue(t, 5, down.URL, down.URL)
+		require.NoError(t, NewChecker(logrus.New(), PolicySoftFail, true, true, time.Minute).Check(cert, p.ca))
+		require.Error(t, NewChecker(logrus.New(), PolicyHardFail, true, true, time.Minute).Check(cert, p.ca))
+
+		unchecked, _ := p.issue(t, 6, "", "")
+		require.Error(t, NewChecker(logrus.New(), PolicyHardFail, true, true, time.Minute).Check(unchecked, p.ca))
+	})
+
+	t.Run("method=VerifyPeerCertificate", func(t *testing.T) {
+		c := NewChecker(logrus.New(), PolicyHardFail, true, true, time.Minute)
+		revoked, _ := p.issue(t, 3, ocspServer.URL, "")
+		require.Error(t, c.VerifyPeerCertificate(nil, [][]*x509.Certificate{{revoked, p.ca}}))
+		require.NoError(t, c.VerifyPeerCertificate(nil, nil))
+	})
+}
+
+func TestStapler(t *testing.T) {
+	p := newPKI(t)
+	ocspServer := httptest.NewServer(p.ocspHandler(t))
+	defer ocspServer.Close()
+
+	leaf, key := p.issue(t, 2, ocspServer.URL, "")
+	s, err := NewStapler(NewChecker(logrus.New(), PolicySoftFail, true, false, time.Hour), []tls.Certificate{{
+		Certificate: [][]byte{leaf.Raw, p.ca.Raw},
+		PrivateKey:  key,
+	}})
+	require.NoError(t, err)
+
+	cert, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+	require.NoError(t, err)
+	require.NotEmpty(t, cert.OCSPStaple)
+
+	res, err := ocsp.ParseResponseForCert(cert.OCSPStaple, leaf, p.ca)
+	require.NoError(t, err)
+	assert.Equal(t, ocsp.Good, res.Status)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&p.ocspRequests))
+}
@@ -0,0 +1,125 @@
+package tlsrevocation
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Stapler staples OCSP responses to the certificates of a TLS server. Responses are refreshed in the background
+// halfway through their validity.
+type Stapler struct {
+	sync.Mutex
+
+	c       *Checker
+	certs   []tls.Certificate
+	issuers []*x509.Certificate
+	staples []staple
+}
+
+type staple struct {
+	response   []byte
+	nextUpdate time.Time
+	refreshAt  time.Time
+	refreshing bool
+}
+
+// NewStapler creates a new Stapler for the certificates. Certificates whose chain does not contain the issuer are
+// served without a staple. OCSP responses are fetched before NewStapler returns.
+func NewStapler(c *Checker, certs []tls.Certificate) (*Stapler, error) {
+	s := &Stapler{
+		c:       c,
+		certs:   make([]tls.Certificate, len(certs)),
+		issuers: make([]*x509.Certificate, len(certs)),
+		staples: make([]staple, len(certs)),
+	}
+
+	for i, cert := range certs {
+		if len(cert.Certificate) == 0 {
+			return nil, errors.New("certificate must not be empty")
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		cert.Leaf = leaf
+		s.certs[i] = cert
+
+		if len(cert.Certificate) < 2 {
+			c.l.WithField("subject", leaf.Subject.String()).Warn("Unable to staple OCSP responses because the certificate chain does not contain the issuer.")
+			continue
+		}
+
+		if s.issuers[i], err = x509.ParseCertificate(cert.Certificate[1]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		s.refresh(i)
+	}
+
+	return s, nil
+}
+
+// GetCertificate can be used as tls.Config.GetCertificate.
+func (s *Stapler) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if len(s.certs) == 0 {
+		return nil, errors.New("no certificates configured")
+	}
+
+	i := s.match(hello.ServerName)
+	cert := s.certs[i]
+
+	s.Lock()
+	st := s.staples[i]
+	if s.issuers[i] != nil && !st.refreshing && time.Now().After(st.refreshAt) {
+		s.staples[i].refreshing = true
+		go s.refresh(i)
+	}
+	s.Unlock()
+
+	if st.response != nil && time.Now().Before(st.nextUpdate) {
+		cert.OCSPStaple = st.response
+	}
+	return &cert, nil
+}
+
+// match returns the index of the first certificate valid for the server name, or the first certificate.
+func (s *Stapler) match(serverName string) int {
+	if serverName == "" {
+		return 0
+	}
+	for i, cert := range s.certs {
+		if cert.Leaf.VerifyHostname(serverName) == nil {
+			return i
+		}
+	}
+	return 0
+}
+
+func (s *Stapler) refresh(i int) {
+	res, raw, err := s.c.OCSP(s.certs[i].Leaf, s.issuers[i])
+
+	s.Lock()
+	defer s.Unlock()
+
+	st := &s.staples[i]
+	st.refreshing = false
+	if err != nil {
+		s.c.l.WithError(err).WithField("subject", s.certs[i].Leaf.Subject.String()).Warn("Unable to fetch the OCSP response to staple.")
+		st.refreshAt = time.Now().Add(time.Minute)
+		return
+	}
+
+	st.response = raw
+	st.nextUpdate = res.NextUpdate
+	if res.NextUpdate.IsZero() {
+		// Responses without a next update are always valid, but they are refreshed regularly nevertheless.
+		st.nextUpdate = time.Now().Add(s.c.ttl * 2)
+		st.refreshAt = time.Now().Add(s.c.ttl)
+		return
+	}
+	st.refreshAt = res.ThisUpdate.Add(res.NextUpdate.Sub(res.ThisUpdate) / 2)
+}
@@ -0,0 +1,126 @@
+package x
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP derives the IP address of the client which sent the request.
+//
+// Headers are only considered if the request was sent by a trusted proxy. The forwarded addresses are taken from the
+// "Forwarded" header, or the "X-Forwarded-For" header if it is absent, and walked from right to left, skipping
+// trusted proxies. The first address which is not trusted is the client's address. If all addresses are trusted, the
+// leftmost one is used. If an entry is not an IP address, for example because it is obfuscated, the address of the
+// proxy which added it is used. The "X-Real-IP" header is only used if neither of the other headers is set.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	client := remoteIP(r.RemoteAddr)
+	if client == nil {
+		return r.RemoteAddr
+	}
+
+	if !ContainsIP(trusted, client) {
+		return client.String()
+	}
+
+	forwarded := forwardedFor(r.Header)
+	if len(forwarded) == 0 {
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			return ip.String()
+		}
+		return client.String()
+	}
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(forwarded[i])
+		if ip == nil {
+			break
+		}
+
+		client = ip
+		if !ContainsIP(trusted, ip) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// ParseTrustedProxies parses IP addresses and CIDR ranges. Single IP addresses are treated as ranges containing only
+// that address.
+func ParseTrustedProxies(networks []string) ([]*net.IPNet, []string) {
+	var parsed []*net.IPNet
+	var invalid []string
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+		if !strings.Contains(network, "/") {
+			if ip := net.ParseIP(network); ip != nil {
+				bits := 8 * net.IPv6len
+				if v4 := ip.To4(); v4 != nil {
+					ip, bits = v4, 8*net.IPv4len
+				}
+				parsed = append(parsed, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		} else if _, n, err := net.ParseCIDR(network); err == nil {
+			parsed = append(parsed, n)
+			continue
+		}
+		invalid = append(invalid, network)
+	}
+	return parsed, invalid
+}
+
+// ContainsIP returns true if one of the networks contains the IP address.
+func ContainsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
+
+// forwardedFor returns the forwarded addresses of the "Forwarded" header or, if it is absent, of the
+// "X-Forwarded-For" header, from left to right. Ports and the brackets of IPv6 addresses are removed.
+func forwardedFor(h http.Header) []string {
+	var addresses []string
+	if values := h["Forwarded"]; len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				var address string
+				for _, pair := range strings.Split(element, ";") {
+					kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+					if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+						address = nodeAddress(strings.Trim(kv[1], `"`))
+					}
+				}
+				addresses = append(addresses, address)
+			}
+		}
+		return addresses
+	}
+
+	for _, value := range h["X-Forwarded-For"] {
+		for _, address := range strings.Split(value, ",") {
+			addresses = append(addresses, nodeAddress(strings.TrimSpace(address)))
+		}
+	}
+	return addresses
+}
+
+// nodeAddress removes the port and the brackets of IPv6 addresses from a node as used by the "Forwarded" header,
+// for example "[2001:db8::1]:4711".
+func nodeAddress(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+}
@@ -0,0 +1,56 @@
+package x
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, invalid := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32", "not-an-ip"})
+	assert.Equal(t, []string{"not-an-ip"}, invalid)
+	assert.Len(t, trusted, 3)
+
+	for k, tc := range []struct {
+		remote string
+		header http.Header
+		expect string
+	}{
+		{remote: "198.51.100.1:1234", expect: "198.51.100.1"},
+		{remote: "198.51.100.1:1234", header: http.Header{"X-Forwarded-For": {"203.0.113.1"}}, expect: "198.51.100.1"},
+		{remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"203.0.113.1"}}, expect: "203.0.113.1"},
+		{remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"203.0.113.7, 203.0.113.1, 10.0.0.2"}}, expect: "203.0.113.1"},
+		{remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"203.0.113.7", "192.0.2.1"}}, expect: "203.0.113.7"},
+		{remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, expect: "10.0.0.3"},
+		{remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"garbage, 10.0.0.2"}}, expect: "10.0.0.2"},
+		{remote: "10.0.0.1:1234", header: http.Header{"X-Real-Ip": {"203.0.113.1"}}, expect: "203.0.113.1"},
+		{
+			remote: "10.0.0.1:1234",
+			header: http.Header{"X-Forwarded-For": {"203.0.113.1"}, "X-Real-Ip": {"203.0.113.2"}},
+			expect: "203.0.113.1",
+		},
+		{
+			remote: "10.0.0.1:1234",
+			header: http.Header{"Forwarded": {`for=203.0.113.9;proto=https, for="[2001:db8::1]:4711"`}, "X-Forwarded-For": {"203.0.113.1"}},
+			expect: "203.0.113.9",
+		},
+		{remote: "10.0.0.1:1234", header: http.Header{"Forwarded": {"for=_hidden, for=10.0.0.2"}}, expect: "10.0.0.2"},
+		{remote: "[2001:db8::2]:1234", header: http.Header{"X-Forwarded-For": {"2001:db9::1"}}, expect: "2001:db9::1"},
+		{remote: "invalid", expect: "invalid"},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tc.remote, Header: tc.header}
+			if r.Header == nil {
+				r.Header = http.Header{}
+			}
+			assert.Equal(t, tc.expect, ClientIP(r, trusted))
+		})
+	}
+
+	t.Run("case=no proxy is trusted", func(t *testing.T) {
+		r := &http.Request{RemoteAddr: "10.0.0.1:1234", Header: http.Header{"X-Forwarded-For": {"203.0.113.1"}}}
+		assert.Equal(t, "10.0.0.1", ClientIP(r, nil))
+	})
+}
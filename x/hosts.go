@@ -0,0 +1,50 @@
+package x
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// DialContextFunc dials a connection like net.Dialer.DialContext.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// OverrideHost returns the address which is dialed instead of addr ("host:port"). The overrides map a hostname, or a
+// hostname and port ("host:port"), to an IP address or hostname, optionally with a port, like an entry of /etc/hosts.
+// Overrides of a hostname and port take precedence. If the override has no port, the port of addr is kept. If no
+// override matches, addr is returned.
+func OverrideHost(overrides map[string]string, addr string) string {
+	if len(overrides) == 0 {
+		return addr
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	target, ok := overrides[strings.ToLower(net.JoinHostPort(host, port))]
+	if !ok {
+		if target, ok = overrides[strings.ToLower(host)]; !ok {
+			return addr
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(strings.Trim(target, "[]"), port)
+}
+
+// OverrideHostsDialer wraps dial so that connections are made to the overridden addresses, see OverrideHost. The
+// overrides are read on every dial, so changes apply to new connections. Because only the dialed address changes,
+// the Host header and the server name verified by TLS remain those of the original host.
+func OverrideHostsDialer(overrides func() map[string]string, dial DialContextFunc) DialContextFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dial(ctx, network, OverrideHost(overrides(), addr))
+	}
+}
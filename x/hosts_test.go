@@ -0,0 +1,55 @@
+package x
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverrideHost(t *testing.T) {
+	overrides := map[string]string{
+		"idp.example.com":      "10.0.0.1",
+		"idp.example.com:8443": "10.0.0.2:443",
+		"api.example.com":      "api.staging.internal:8080",
+		"v6.example.com":       "::1",
+		"v6-port.example.com":  "[::1]:8080",
+	}
+
+	for k, tc := range []struct {
+		addr   string
+		expect string
+	}{
+		{addr: "idp.example.com:443", expect: "10.0.0.1:443"},
+		{addr: "IDP.example.com:443", expect: "10.0.0.1:443"},
+		{addr: "idp.example.com:8443", expect: "10.0.0.2:443"},
+		{addr: "api.example.com:80", expect: "api.staging.internal:8080"},
+		{addr: "v6.example.com:443", expect: "[::1]:443"},
+		{addr: "v6-port.example.com:443", expect: "[::1]:8080"},
+		{addr: "other.example.com:443", expect: "other.example.com:443"},
+		{addr: "not-an-address", expect: "not-an-address"},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			assert.Equal(t, tc.expect, OverrideHost(overrides, tc.addr))
+		})
+	}
+
+	assert.Equal(t, "idp.example.com:443", OverrideHost(nil, "idp.example.com:443"))
+}
+
+func TestOverrideHostsDialer(t *testing.T) {
+	var dialed string
+	dial := OverrideHostsDialer(func() map[string]string {
+		return map[string]string{"idp.example.com": "127.0.0.1"}
+	}, func(_ context.Context, _, addr string) (net.Conn, error) {
+		dialed = addr
+		return nil, nil
+	})
+
+	_, err := dial(context.Background(), "tcp", "idp.example.com:443")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:443", dialed)
+}
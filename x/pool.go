@@ -0,0 +1,39 @@
+package x
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize is the capacity above which buffers are not returned to the pool, so that a single large
+// response does not keep memory allocated for the lifetime of the process.
+const maxPooledBufferSize = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// AcquireBuffer returns an empty buffer from the pool. The buffer must be released using ReleaseBuffer once its
+// contents are no longer referenced.
+func AcquireBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// ReleaseBuffer resets the buffer and returns it to the pool. Neither the buffer nor slices returned by its Bytes
+// method may be used afterwards.
+func ReleaseBuffer(b *bytes.Buffer) {
+	if b == nil || b.Cap() > maxPooledBufferSize {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// DecodeJSON reads r using a pooled buffer and decodes the JSON document into v. The decoded value does not reference
+// the buffer, strings and raw messages are copied by the decoder.
+func DecodeJSON(r io.Reader, v interface{}) error {
+	return DecodeJSONLimited(r, 0, v)
+}
@@ -0,0 +1,68 @@
+package x
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseBuffer(t *testing.T) {
+	b := AcquireBuffer()
+	b.WriteString("secret")
+	ReleaseBuffer(b)
+
+	// A buffer taken from the pool is always empty.
+	assert.Equal(t, 0, AcquireBuffer().Len())
+
+	large := AcquireBuffer()
+	large.Grow(maxPooledBufferSize + 1)
+	ReleaseBuffer(large)
+	ReleaseBuffer(nil)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	var v struct {
+		Subject string                 `json:"sub"`
+		Extra   map[string]interface{} `json:"ext"`
+		Raw     json.RawMessage        `json:"raw"`
+	}
+	require.NoError(t, DecodeJSON(strings.NewReader(`{"sub":"foo","ext":{"a":"b"},"raw":{"c":1}}`), &v))
+	assert.Equal(t, "foo", v.Subject)
+	assert.Equal(t, map[string]interface{}{"a": "b"}, v.Extra)
+	assert.JSONEq(t, `{"c":1}`, string(v.Raw))
+
+	assert.Error(t, DecodeJSON(strings.NewReader(`{"sub":`), &v))
+}
+
+func TestDecodeJSONConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				expected := fmt.Sprintf("subject-%d-%d", i, j)
+
+				var v struct {
+					Subject string          `json:"sub"`
+					Raw     json.RawMessage `json:"raw"`
+				}
+				if !assert.NoError(t, DecodeJSON(strings.NewReader(fmt.Sprintf(`{"sub":"%s","raw":"%s"}`, expected, expected)), &v)) {
+					return
+				}
+
+				// Values decoded by other goroutines reuse the buffer, the decoded values must not change.
+				for k := 0; k < 10; k++ {
+					assert.Equal(t, expected, v.Subject)
+					assert.Equal(t, `"`+expected+`"`, string(v.Raw))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,84 @@
+package x
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrResponseTooLarge is returned if the body of a response of a remote service exceeds the configured maximum size.
+var ErrResponseTooLarge = errors.New("the response body exceeds the maximum size")
+
+// DecodeJSONLimited is like DecodeJSON but fails with ErrResponseTooLarge as soon as more than limit bytes were read.
+// A limit of zero or less disables the limit.
+func DecodeJSONLimited(r io.Reader, limit int64, v interface{}) error {
+	b := AcquireBuffer()
+	defer ReleaseBuffer(b)
+
+	if err := readLimited(b, r, limit); err != nil {
+		return err
+	}
+	return errors.WithStack(json.Unmarshal(b.Bytes(), v))
+}
+
+// ReadResponse reads the body of a response of a remote service. Responses announcing a larger body than limit are
+// rejected before reading, others fail with ErrResponseTooLarge as soon as more than limit bytes were read. A limit
+// of zero or less disables the limit.
+func ReadResponse(res *http.Response, limit int64) ([]byte, error) {
+	if err := CheckContentLength(res, limit); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := readLimited(&b, res.Body, limit); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// DecodeJSONResponse decodes the JSON body of a response of a remote service into v, applying the limit like
+// ReadResponse.
+func DecodeJSONResponse(res *http.Response, limit int64, v interface{}) error {
+	if err := CheckContentLength(res, limit); err != nil {
+		return err
+	}
+	return DecodeJSONLimited(res.Body, limit, v)
+}
+
+// CheckContentLength returns ErrResponseTooLarge if the response announces a body larger than limit.
+func CheckContentLength(res *http.Response, limit int64) error {
+	if limit > 0 && res.ContentLength > limit {
+		return errors.WithStack(ErrResponseTooLarge)
+	}
+	return nil
+}
+
+func readLimited(b *bytes.Buffer, r io.Reader, limit int64) error {
+	if limit <= 0 {
+		_, err := b.ReadFrom(r)
+		return errors.WithStack(err)
+	}
+
+	// Reading one byte more than allowed tells bodies of exactly limit bytes apart from larger ones.
+	n, err := b.ReadFrom(io.LimitReader(r, limit+1))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n > limit {
+		return errors.WithStack(ErrResponseTooLarge)
+	}
+	return nil
+}
+
+// WithOptionalTimeout is like context.WithTimeout but does not set a deadline if timeout is zero or less.
+func WithOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,64 @@
+package x
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func response(body string, contentLength int64) *http.Response {
+	return &http.Response{Body: ioutil.NopCloser(strings.NewReader(body)), ContentLength: contentLength}
+}
+
+func TestReadResponse(t *testing.T) {
+	for k, tc := range []struct {
+		body          string
+		contentLength int64
+		limit         int64
+		expectErr     bool
+	}{
+		{body: `{"sub":"foo"}`, contentLength: -1, limit: 0},
+		{body: `{"sub":"foo"}`, contentLength: -1, limit: 13},
+		{body: `{"sub":"foo"}`, contentLength: -1, limit: 12, expectErr: true},
+		{body: `{"sub":"foo"}`, contentLength: 13, limit: 12, expectErr: true},
+		// The announced length is only used to reject responses early.
+		{body: `{"sub":"foo"}`, contentLength: 5, limit: 12, expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			body, err := ReadResponse(response(tc.body, tc.contentLength), tc.limit)
+			if tc.expectErr {
+				assert.Equal(t, ErrResponseTooLarge, errors.Cause(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(body))
+
+			var v struct {
+				Subject string `json:"sub"`
+			}
+			require.NoError(t, DecodeJSONResponse(response(tc.body, tc.contentLength), tc.limit, &v))
+			assert.Equal(t, "foo", v.Subject)
+		})
+	}
+}
+
+func TestWithOptionalTimeout(t *testing.T) {
+	ctx, cancel := WithOptionalTimeout(context.Background(), 0)
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+	cancel()
+	assert.Error(t, ctx.Err())
+
+	ctx, cancel = WithOptionalTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.True(t, ok)
+}
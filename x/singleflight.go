@@ -0,0 +1,18 @@
+package x
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// FlightKey returns the key used to deduplicate identical concurrent calls to remote services with singleflight. The
+// parts are length prefixed so that different calls never share a key, and hashed so that tokens are not kept in
+// memory as is.
+func FlightKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		_, _ = fmt.Fprintf(h, "%d:%s", len(p), p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
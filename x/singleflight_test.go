@@ -0,0 +1,14 @@
+package x
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightKey(t *testing.T) {
+	assert.Equal(t, FlightKey("a", "bc"), FlightKey("a", "bc"))
+	assert.NotEqual(t, FlightKey("a", "bc"), FlightKey("ab", "c"))
+	assert.NotEqual(t, FlightKey("a"), FlightKey("a", ""))
+	assert.NotContains(t, FlightKey("secret-token"), "secret-token")
+}
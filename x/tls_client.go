@@ -0,0 +1,139 @@
+package x
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// TLSClientConfig configures how the certificate of a remote service is verified.
+type TLSClientConfig struct {
+	// CAFile is the path of a PEM encoded bundle of certificate authorities which are trusted in addition to those of
+	// the system.
+	CAFile string `json:"ca_file"`
+
+	// CAPEM is a PEM encoded bundle of certificate authorities, like CAFile but inline.
+	CAPEM string `json:"ca_pem"`
+
+	// ServerName is the name the certificate must be valid for, if it is not the hostname of the URL.
+	ServerName string `json:"server_name"`
+
+	// CertFile and KeyFile are the paths of the PEM encoded client certificate and private key presented to services
+	// requiring mutual TLS.
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+
+	// CertPEM and KeyPEM are the PEM encoded client certificate and private key, like CertFile and KeyFile but inline.
+	CertPEM string `json:"cert_pem"`
+	KeyPEM  string `json:"key_pem"`
+
+	// InsecureSkipVerify disables the verification of the certificate. Never use it in production.
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+var tlsTransports sync.Map
+
+// IsZero returns true if nothing is configured, in which case the default transport is used.
+func (c *TLSClientConfig) IsZero() bool {
+	return c == nil || *c == TLSClientConfig{}
+}
+
+// TLSTransport returns a transport verifying the certificates of remote services as configured. It is a clone of
+// http.DefaultTransport, so the process-wide settings such as the FIPS policy and the host overrides still apply.
+// Transports are shared by all callers with identical settings, so that connections are reused. If nothing is
+// configured, http.DefaultTransport is returned.
+func TLSTransport(c *TLSClientConfig) (http.RoundTripper, error) {
+	if c.IsZero() {
+		return http.DefaultTransport, nil
+	}
+
+	key := fmt.Sprintf("%q %q %q %q %q %q %q %t", c.CAFile, c.CAPEM, c.ServerName, c.CertFile, c.KeyFile, c.CertPEM, c.KeyPEM, c.InsecureSkipVerify)
+	if t, ok := tlsTransports.Load(key); ok {
+		return t.(http.RoundTripper), nil
+	}
+
+	config, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.Errorf("expected http.DefaultTransport to be of type *http.Transport but got %T", http.DefaultTransport)
+	}
+
+	t := base.Clone()
+	t.TLSClientConfig = config
+	actual, _ := tlsTransports.LoadOrStore(key, t)
+	return actual.(http.RoundTripper), nil
+}
+
+func (c *TLSClientConfig) tlsConfig() (*tls.Config, error) {
+	config := new(tls.Config)
+	if base, ok := http.DefaultTransport.(*http.Transport); ok && base.TLSClientConfig != nil {
+		config = base.TLSClientConfig.Clone()
+	}
+
+	config.ServerName = c.ServerName
+	config.InsecureSkipVerify = c.InsecureSkipVerify
+
+	if err := c.loadClientCertificate(config); err != nil {
+		return nil, err
+	}
+
+	if c.CAFile == "" && c.CAPEM == "" {
+		return config, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if c.CAFile != "" {
+		raw, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read the certificate authorities of %s", c.CAFile)
+		}
+		if !pool.AppendCertsFromPEM(raw) {
+			return nil, errors.Errorf("the certificate authorities of %s do not contain a PEM encoded certificate", c.CAFile)
+		}
+	}
+
+	if c.CAPEM != "" && !pool.AppendCertsFromPEM([]byte(c.CAPEM)) {
+		return nil, errors.New("the inline certificate authorities do not contain a PEM encoded certificate")
+	}
+
+	config.RootCAs = pool
+	return config, nil
+}
+
+// loadClientCertificate adds the client certificate, if one is configured, to the TLS configuration.
+func (c *TLSClientConfig) loadClientCertificate(config *tls.Config) error {
+	var (
+		certificate tls.Certificate
+		err         error
+	)
+
+	switch {
+	case c.CertFile == "" && c.KeyFile == "" && c.CertPEM == "" && c.KeyPEM == "":
+		return nil
+	case c.CertFile != "" && c.KeyFile != "":
+		certificate, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	case c.CertPEM != "" && c.KeyPEM != "":
+		certificate, err = tls.X509KeyPair([]byte(c.CertPEM), []byte(c.KeyPEM))
+	default:
+		return errors.New("the client certificate requires either cert_file and key_file or cert_pem and key_pem")
+	}
+	if err != nil {
+		return errors.Wrap(err, "unable to load the client certificate")
+	}
+
+	config.Certificates = []tls.Certificate{certificate}
+	return nil
+}
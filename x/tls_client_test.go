@@ -0,0 +1,148 @@
+package x
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSTransport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}))
+
+	file, err := ioutil.TempFile("", "ca-*.pem")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(ca)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	get := func(c *TLSClientConfig) error {
+		transport, err := TLSTransport(c)
+		require.NoError(t, err)
+		res, err := (&http.Client{Transport: transport}).Get(ts.URL)
+		if err != nil {
+			return err
+		}
+		return res.Body.Close()
+	}
+
+	t.Run("case=uses the default transport if nothing is configured", func(t *testing.T) {
+		assert.True(t, (*TLSClientConfig)(nil).IsZero())
+		assert.True(t, new(TLSClientConfig).IsZero())
+
+		transport, err := TLSTransport(nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.DefaultTransport, transport)
+
+		require.Error(t, get(nil))
+	})
+
+	for k, tc := range []struct {
+		d    string
+		c    *TLSClientConfig
+		pass bool
+	}{
+		{d: "inline certificate authorities", c: &TLSClientConfig{CAPEM: ca}, pass: true},
+		{d: "certificate authorities file", c: &TLSClientConfig{CAFile: file.Name()}, pass: true},
+		{d: "matching server name", c: &TLSClientConfig{CAPEM: ca, ServerName: "example.com"}, pass: true},
+		{d: "other server name", c: &TLSClientConfig{CAPEM: ca, ServerName: "idp.example.org"}, pass: false},
+		{d: "skipped verification", c: &TLSClientConfig{InsecureSkipVerify: true}, pass: true},
+		{d: "untrusted certificate", c: &TLSClientConfig{ServerName: "example.com"}, pass: false},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			if tc.pass {
+				require.NoError(t, get(tc.c))
+			} else {
+				require.Error(t, get(tc.c))
+			}
+		})
+	}
+
+	t.Run("case=transports are shared", func(t *testing.T) {
+		a, err := TLSTransport(&TLSClientConfig{CAPEM: ca})
+		require.NoError(t, err)
+		b, err := TLSTransport(&TLSClientConfig{CAPEM: ca})
+		require.NoError(t, err)
+		assert.True(t, a == b)
+	})
+
+	t.Run("case=fails with invalid certificate authorities", func(t *testing.T) {
+		_, err := TLSTransport(&TLSClientConfig{CAPEM: "not a certificate"})
+		require.Error(t, err)
+		_, err = TLSTransport(&TLSClientConfig{CAFile: file.Name() + ".missing"})
+		require.Error(t, err)
+	})
+}
+
+func TestTLSTransportClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "oathkeeper"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}))
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	get := func(c *TLSClientConfig) (string, error) {
+		transport, err := TLSTransport(c)
+		if err != nil {
+			return "", err
+		}
+		res, err := (&http.Client{Transport: transport}).Get(ts.URL)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		return string(body), err
+	}
+
+	subject, err := get(&TLSClientConfig{InsecureSkipVerify: true, CertPEM: certPEM, KeyPEM: keyPEM})
+	require.NoError(t, err)
+	assert.Equal(t, "oathkeeper", subject)
+
+	_, err = get(&TLSClientConfig{InsecureSkipVerify: true})
+	require.Error(t, err, "the server requires a client certificate")
+
+	_, err = get(&TLSClientConfig{InsecureSkipVerify: true, CertPEM: certPEM})
+	require.Error(t, err, "the private key is missing")
+}
@@ -0,0 +1,131 @@
+package x
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// UnknownFields returns the paths of all fields of the JSON document raw which are not decoded into the type of v,
+// for example `authenticators[0].confg`. Fields are matched like encoding/json does: case-insensitively and including
+// the fields of embedded structs. Values decoded by a json.Unmarshaler other than v itself, such as json.RawMessage,
+// and values decoded into interface{} are not inspected.
+func UnknownFields(raw []byte, v interface{}) ([]string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var unknown []string
+	collectUnknownFields(doc, reflect.TypeOf(v), "", true, &unknown)
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+func collectUnknownFields(doc interface{}, t reflect.Type, path string, root bool, unknown *[]string) {
+	if t == nil {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !root && reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := doc.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		for key, value := range object {
+			field, ok := lookupJSONField(fields, key)
+			if !ok {
+				*unknown = append(*unknown, joinFieldPath(path, key))
+				continue
+			}
+			collectUnknownFields(value, field.Type, joinFieldPath(path, key), false, unknown)
+		}
+	case reflect.Map:
+		object, ok := doc.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, value := range object {
+			collectUnknownFields(value, t.Elem(), joinFieldPath(path, key), false, unknown)
+		}
+	case reflect.Slice, reflect.Array:
+		array, ok := doc.([]interface{})
+		if !ok {
+			return
+		}
+		for k, value := range array {
+			collectUnknownFields(value, t.Elem(), fmt.Sprintf("%s[%d]", path, k), false, unknown)
+		}
+	}
+}
+
+// jsonFields returns the fields of struct type t by their JSON name, including the promoted fields of embedded
+// structs.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				for n, ef := range jsonFields(et) {
+					if _, ok := fields[n]; !ok {
+						fields[n] = ef
+					}
+				}
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func lookupJSONField(fields map[string]reflect.StructField, key string) (reflect.StructField, bool) {
+	if f, ok := fields[key]; ok {
+		return f, true
+	}
+	for name, f := range fields {
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
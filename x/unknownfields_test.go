@@ -0,0 +1,53 @@
+package x
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unknownFieldsEmbedded struct {
+	Embedded string `json:"embedded"`
+}
+
+type unknownFieldsHandler struct {
+	Handler string          `json:"handler"`
+	Config  json.RawMessage `json:"config"`
+}
+
+type unknownFieldsDocument struct {
+	unknownFieldsEmbedded
+	ID       string                           `json:"id"`
+	Handlers []unknownFieldsHandler           `json:"handlers"`
+	Upstream *struct{ URL string }            `json:"upstream"`
+	Labels   map[string]*unknownFieldsHandler `json:"labels"`
+	Extra    interface{}                      `json:"extra"`
+}
+
+func TestUnknownFields(t *testing.T) {
+	for k, tc := range []struct {
+		raw    string
+		expect []string
+	}{
+		{raw: `{}`},
+		{raw: `{"id":"a","embedded":"b","handlers":[{"handler":"noop","config":{"anything":true}}],"extra":{"anything":true}}`},
+		{raw: `{"ID":"a","upstream":{"url":"https://www.ory.sh"}}`},
+		{
+			raw:    `{"idd":"a","handlers":[{"handler":"noop"},{"handlr":"noop","confg":{}}]}`,
+			expect: []string{"handlers[1].confg", "handlers[1].handlr", "idd"},
+		},
+		{
+			raw:    `{"upstream":{"uri":"https://www.ory.sh"},"labels":{"a":{"handler":"noop","when":[]}}}`,
+			expect: []string{"labels.a.when", "upstream.uri"},
+		},
+	} {
+		unknown, err := UnknownFields([]byte(tc.raw), new(unknownFieldsDocument))
+		require.NoError(t, err, "%d", k)
+		assert.Equal(t, tc.expect, unknown, "%d", k)
+	}
+
+	_, err := UnknownFields([]byte(`{`), new(unknownFieldsDocument))
+	require.Error(t, err)
+}